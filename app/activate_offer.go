@@ -24,13 +24,21 @@ func validateActivateOfferRequest(request *activateOfferRequest) (bool, string)
 	return true, ""
 }
 
-// ActivateOfferHandler creates a new product
+// ActivateOfferHandler creates a new product. With the async query
+// parameter set to true the offer prices are calculated by a background
+// job.
 func (a *App) ActivateOfferHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ActivateOfferHandler called")
 
+		async, err := asyncFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+
 		request := activateOfferRequest{}
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
@@ -45,7 +53,14 @@ func (a *App) ActivateOfferHandler() http.HandlerFunc {
 			return
 		}
 
-		priceList, err := a.Service.ActivateOffer(ctx, request.PromoRuleID)
+		var priceList *service.Offer
+		var job *service.Job
+		if async {
+			userID := ctx.Value(ecomUIDKey).(string)
+			job, err = a.Service.ActivateOfferAsync(ctx, userID, request.PromoRuleID)
+		} else {
+			priceList, err = a.Service.ActivateOffer(ctx, request.PromoRuleID)
+		}
 		if err == service.ErrPromoRuleNotFound {
 			clientError(w, http.StatusConflict, ErrCodePromoRuleNotFound, "promo rule not found")
 			return
@@ -60,6 +75,10 @@ func (a *App) ActivateOfferHandler() http.HandlerFunc {
 			w.WriteHeader(http.StatusInternalServerError) // 500 Internal Server Error
 			return
 		}
+		if job != nil {
+			jobAccepted(w, job)
+			return
+		}
 		w.WriteHeader(http.StatusCreated) // 201 Created
 		json.NewEncoder(w).Encode(priceList)
 	}
@@ -9,9 +9,10 @@ import (
 )
 
 type addProductToCartRequestBody struct {
-	CartID    *string `json:"cart_id"`
-	ProductID *string `json:"product_id"`
-	Qty       *int    `json:"qty"`
+	CartID    *string           `json:"cart_id"`
+	ProductID *string           `json:"product_id"`
+	Options   map[string]string `json:"options"`
+	Qty       *int              `json:"qty"`
 }
 
 func validateAddProductRequestBody(request *addProductToCartRequestBody) (bool, string) {
@@ -64,7 +65,7 @@ func (a *App) AddProductToCartHandler() http.HandlerFunc {
 		}
 
 		userID := ctx.Value(ecomUIDKey).(string)
-		product, err := a.Service.AddProductToCart(ctx, userID, *request.CartID, *request.ProductID, *request.Qty)
+		product, err := a.Service.AddProductToCart(ctx, userID, *request.CartID, *request.ProductID, request.Options, *request.Qty)
 		if err == service.ErrCartNotFound {
 			clientError(w, http.StatusNotFound, ErrCodeCartNotFound, "cart not found")
 			return
@@ -79,6 +80,16 @@ func (a *App) AddProductToCartHandler() http.HandlerFunc {
 			clientError(w, http.StatusConflict, ErrCodeProductNotFound, "failed to add product with given id to the cart as the product cannot be found")
 			return
 		}
+		if err == service.ErrVariantNotFound {
+			// 409 Conflict
+			clientError(w, http.StatusConflict, ErrCodeVariantNotFound, "failed to add product to the cart as no variant has the given options")
+			return
+		}
+		if err == service.ErrProductHasVariants {
+			// 409 Conflict
+			clientError(w, http.StatusConflict, ErrCodeProductHasVariants, "product has variants so options must be set to select a variant")
+			return
+		}
 		if err == service.ErrDefaultPriceListNotFound {
 			// 500 Internal Server Error
 			contextLogger.Error("ErrDefaultPriceListNotFound")
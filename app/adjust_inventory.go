@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+type adjustInventoryRequestBody struct {
+	Location *string `json:"location"`
+	Delta    *int    `json:"delta"`
+	Note     *string `json:"note"`
+}
+
+func validateAdjustInventoryRequestBody(o *adjustInventoryRequestBody) error {
+	if o.Delta == nil {
+		return errors.New("delta attribute must be set")
+	}
+	if *o.Delta == 0 {
+		return errors.New("delta attribute must be a non-zero integer")
+	}
+	if o.Note != nil && len(*o.Note) > 1024 {
+		return errors.New("note attribute must be no more than 1024 characters")
+	}
+	return nil
+}
+
+// AdjustInventoryHandler returns a http.HandlerFunc that changes the
+// onhand of inventory by a delta.
+func (a *App) AdjustInventoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: AdjustInventoryHandler called")
+
+		inventoryID := chi.URLParam(r, "id")
+		if !IsValidUUID(inventoryID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		o := adjustInventoryRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&o); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		if err := validateAdjustInventoryRequestBody(&o); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		userID := ctx.Value(ecomUIDKey).(string)
+		inventory, err := a.Service.AdjustInventory(ctx, userID, inventoryID, o.Location, *o.Delta, o.Note)
+		if err == service.ErrInventoryNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeInventoryNotFound,
+				"inventory not found") // 404
+			return
+		}
+		if err == service.ErrLocationNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeLocationNotFound,
+				"location not found") // 404
+			return
+		}
+		if err == service.ErrInventoryBelowZero {
+			clientError(w, http.StatusConflict, ErrCodeInventoryBelowZero,
+				"adjustment would take inventory onhand below zero") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.AdjustInventory(ctx, userID=%q, inventoryID=%q, delta=%d, ...) failed: %+v",
+				userID, inventoryID, *o.Delta, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(&inventory)
+	}
+}
@@ -13,6 +13,32 @@ const (
 	// ErrCodeInventoryNotFound is sent when failing to retrive any inventory for both list
 	// and get operations.
 	ErrCodeInventoryNotFound string = "inventory/inventory-not-found"
+
+	// ErrCodeInventoryBelowZero is sent when an adjustment would take
+	// the onhand of inventory below zero.
+	ErrCodeInventoryBelowZero string = "inventory/inventory-below-zero"
+
+	// ErrCodeInventoryMovementNotFound is sent when the start_after
+	// or end_before movement cannot be found.
+	ErrCodeInventoryMovementNotFound string = "inventory/inventory-movement-not-found"
+
+	// ErrCodeInventoryLocationForbidden is sent when a caller without
+	// admin privileges asks for the onhand at a location.
+	ErrCodeInventoryLocationForbidden string = "inventory/location-forbidden"
+)
+
+// Locations
+const (
+	OpCreateLocation string = "OpCreateLocation"
+	OpGetLocation    string = "OpGetLocation"
+	OpListLocations  string = "OpListLocations"
+	OpUpdateLocation string = "OpUpdateLocation"
+
+	// ErrCodeLocationNotFound error
+	ErrCodeLocationNotFound string = "locations/location-not-found"
+
+	// ErrCodeLocationCodeExists error
+	ErrCodeLocationCodeExists string = "locations/location-code-exists"
 )
 
 // Shipping Tariffs
@@ -24,6 +50,20 @@ const (
 	ErrCodeShippingTariffNotFound string = "shipping-tariffs/shipping-tariff-not-found"
 )
 
+// Tax Rates
+const (
+	OpCreateTaxRate string = "OpCreateTaxRate"
+	OpGetTaxRate    string = "OpGetTaxRate"
+	OpListTaxRates  string = "OpListTaxRates"
+	OpDeleteTaxRate string = "OpDeleteTaxRate"
+
+	// ErrCodeTaxRateNotFound error
+	ErrCodeTaxRateNotFound string = "tax-rates/tax-rate-not-found"
+
+	// ErrCodeTaxCodeNotFound error
+	ErrCodeTaxCodeNotFound string = "tax-rates/tax-code-not-found"
+)
+
 // Product Set Items
 const (
 	// ErrCodeProductSetNotFound error
@@ -166,17 +206,56 @@ const (
 	// ErrCodeCategoriesInUse occurs when attempting to update the categories tree and
 	// one or more categories are referenced in a promo rule.
 	ErrCodeCategoriesInUse string = "categories/categories-in-use"
+
+	// ErrCodeCategoryPathExists is sent when a category would have the
+	// same segment as one of its siblings.
+	ErrCodeCategoryPathExists string = "categories/category-path-exists"
+
+	// ErrCodeCategoryHasProducts is sent when adding children to or
+	// deleting a leaf category that has products.
+	ErrCodeCategoryHasProducts string = "categories/category-has-products"
+
+	// ErrCodeCategoryRootExists is sent when creating a category without
+	// a parent once the root category exists.
+	ErrCodeCategoryRootExists string = "categories/category-root-exists"
+
+	// ErrCodeCategoryMoveInvalid is sent when moving the root category or
+	// moving a category under itself or one of its descendants.
+	ErrCodeCategoryMoveInvalid string = "categories/category-move-invalid"
+
+	// ErrCodeCategoryProductsMismatch is sent when reordering the products
+	// of a category with a list that is not exactly its products.
+	ErrCodeCategoryProductsMismatch string = "categories/category-products-mismatch"
+)
+
+// Routes
+const (
+	// ErrCodeRouteNotFound is sent when a path resolves to no product or
+	// category.
+	ErrCodeRouteNotFound string = "routes/route-not-found"
+)
+
+// Feeds
+const (
+	// ErrCodeSitemapNotFound is sent when asking for a sitemap chunk that
+	// does not exist.
+	ErrCodeSitemapNotFound string = "feeds/sitemap-not-found"
 )
 
 // Orders
 const (
-	OpPlaceOrder string = "OpPlaceOrder"
-	OpGetOrder   string = "OpGetOrder"
-	OpListOrders string = "OpListOrders"
+	OpPlaceOrder      string = "OpPlaceOrder"
+	OpGetOrder        string = "OpGetOrder"
+	OpListOrders      string = "OpListOrders"
+	OpUpdateOrder     string = "OpUpdateOrder"
+	OpGetOrderHistory string = "OpGetOrderHistory"
 
 	// ErrCodeOrderCartEmpty error
 	ErrCodeOrderCartEmpty string = "orders/order-cart-empty"
 
+	// ErrCodeInsufficientStock error
+	ErrCodeInsufficientStock string = "orders/insufficient-stock"
+
 	// ErrCodeOrderUserNotFound error
 	ErrCodeOrderUserNotFound string = "orders/order-user-not-found"
 
@@ -185,15 +264,63 @@ const (
 
 	// ErrCodeOrderItemsNotFound error
 	ErrCodeOrderItemsNotFound string = "orders/order-items-not-found"
+
+	// ErrCodeOrderTransitionInvalid error
+	ErrCodeOrderTransitionInvalid string = "orders/order-transition-invalid"
+
+	// ErrCodeOrderStatusChanged error
+	ErrCodeOrderStatusChanged string = "orders/order-status-changed"
+)
+
+// Refunds
+const (
+	OpCreateRefund string = "OpCreateRefund"
+	OpListRefunds  string = "OpListRefunds"
+	OpCancelOrder  string = "OpCancelOrder"
+
+	// ErrCodeOrderNotRefundable error
+	ErrCodeOrderNotRefundable string = "refunds/order-not-refundable"
+
+	// ErrCodeRefundOrderItemNotFound error
+	ErrCodeRefundOrderItemNotFound string = "refunds/order-item-not-found"
+
+	// ErrCodeRefundQtyExceeded error
+	ErrCodeRefundQtyExceeded string = "refunds/refund-qty-exceeded"
+
+	// ErrCodeRefundAmountExceeded error
+	ErrCodeRefundAmountExceeded string = "refunds/refund-amount-exceeded"
+
+	// ErrCodeRefundFailed error
+	ErrCodeRefundFailed string = "refunds/refund-failed"
+)
+
+// Shipments
+const (
+	OpCreateShipment string = "OpCreateShipment"
+	OpGetShipment    string = "OpGetShipment"
+	OpListShipments  string = "OpListShipments"
+
+	// ErrCodeShipmentNotFound error
+	ErrCodeShipmentNotFound string = "shipments/shipment-not-found"
+
+	// ErrCodeShipmentOrderItemNotFound error
+	ErrCodeShipmentOrderItemNotFound string = "shipments/order-item-not-found"
+
+	// ErrCodeOrderNotShippable error
+	ErrCodeOrderNotShippable string = "shipments/order-not-shippable"
+
+	// ErrCodeShipmentQtyExceeded error
+	ErrCodeShipmentQtyExceeded string = "shipments/shipment-qty-exceeded"
 )
 
 // Products
 const (
-	OpCreateProduct string = "OpCreateProduct"
-	OpUpdateProduct string = "OpUpdateProduct"
-	OpGetProduct    string = "OpGetProduct"
-	OpListProducts  string = "OpListProducts"
-	OpDeleteProduct string = "OpDeleteProduct"
+	OpCreateProduct  string = "OpCreateProduct"
+	OpUpdateProduct  string = "OpUpdateProduct"
+	OpGetProduct     string = "OpGetProduct"
+	OpListProducts   string = "OpListProducts"
+	OpSearchProducts string = "OpSearchProducts"
+	OpDeleteProduct  string = "OpDeleteProduct"
 
 	// ErrCodeProductNotFound indicates the product with given SKU could not be found.
 	ErrCodeProductNotFound string = "products/product-not-found"
@@ -209,6 +336,101 @@ const (
 	// ErrCodeProductSKUExists is returned when attempting to create or update a product
 	// with a SKU that is already used by another product.
 	ErrCodeProductSKUExists string = "products/product-sku-exists"
+
+	// ErrCodeProductHasVariants is returned when attempting to delete a product
+	// with variants or add it to a cart without selecting a variant.
+	ErrCodeProductHasVariants string = "products/product-has-variants"
+
+	// ErrCodeProductAttributeInvalid is returned when a product attribute is
+	// not defined or its value is not of the defined type.
+	ErrCodeProductAttributeInvalid string = "products/product-attribute-invalid"
+)
+
+// Product Status
+const (
+	OpUpdateProductStatus string = "OpUpdateProductStatus"
+)
+
+// Product Attributes
+const (
+	OpCreateProductAttribute string = "OpCreateProductAttribute"
+	OpGetProductAttribute    string = "OpGetProductAttribute"
+	OpListProductAttributes  string = "OpListProductAttributes"
+	OpDeleteProductAttribute string = "OpDeleteProductAttribute"
+
+	// ErrCodeProductAttributeNotFound error
+	ErrCodeProductAttributeNotFound string = "product-attributes/product-attribute-not-found"
+
+	// ErrCodeProductAttributeCodeExists error
+	ErrCodeProductAttributeCodeExists string = "product-attributes/product-attribute-code-exists"
+
+	// ErrCodeProductAttributeInUse is returned when attempting to delete a
+	// product attribute used by products.
+	ErrCodeProductAttributeInUse string = "product-attributes/product-attribute-in-use"
+)
+
+// Catalog imports and exports
+const (
+	OpCreateCatalogImport string = "OpCreateCatalogImport"
+	OpGetCatalogImport    string = "OpGetCatalogImport"
+	OpResumeCatalogImport string = "OpResumeCatalogImport"
+	OpExportCatalog       string = "OpExportCatalog"
+
+	// ErrCodeCatalogImportNotFound error
+	ErrCodeCatalogImportNotFound string = "catalog-imports/catalog-import-not-found"
+
+	// ErrCodeCatalogImportNotResumable is returned when attempting to
+	// resume an import that is invalid or has completed.
+	ErrCodeCatalogImportNotResumable string = "catalog-imports/catalog-import-not-resumable"
+)
+
+// Jobs
+const (
+	OpGetJob    string = "OpGetJob"
+	OpCancelJob string = "OpCancelJob"
+
+	// ErrCodeJobNotFound error
+	ErrCodeJobNotFound string = "jobs/job-not-found"
+
+	// ErrCodeJobNotCancellable is returned when attempting to cancel a
+	// job that has finished.
+	ErrCodeJobNotCancellable string = "jobs/job-not-cancellable"
+)
+
+// Product Variants
+const (
+	OpCreateVariant string = "OpCreateVariant"
+	OpListVariants  string = "OpListVariants"
+
+	// ErrCodeVariantNotFound is returned when no variant of a product has
+	// the selected options.
+	ErrCodeVariantNotFound string = "variants/variant-not-found"
+
+	// ErrCodeVariantOptionsExist is returned when creating a variant with the
+	// same options as another variant of the product.
+	ErrCodeVariantOptionsExist string = "variants/variant-options-exist"
+
+	// ErrCodeProductIsVariant is returned when attempting to create a variant
+	// of a variant.
+	ErrCodeProductIsVariant string = "variants/product-is-variant"
+)
+
+// Option Types
+const (
+	OpCreateOptionType string = "OpCreateOptionType"
+	OpGetOptionType    string = "OpGetOptionType"
+	OpListOptionTypes  string = "OpListOptionTypes"
+	OpDeleteOptionType string = "OpDeleteOptionType"
+
+	// ErrCodeOptionTypeNotFound error
+	ErrCodeOptionTypeNotFound string = "option-types/option-type-not-found"
+
+	// ErrCodeOptionTypeCodeExists error
+	ErrCodeOptionTypeCodeExists string = "option-types/option-type-code-exists"
+
+	// ErrCodeOptionTypeInUse is returned when attempting to delete an option
+	// type used by product variants.
+	ErrCodeOptionTypeInUse string = "option-types/option-type-in-use"
 )
 
 // Carts
@@ -219,6 +441,7 @@ const (
 	OpUpdateCartProduct string = "OpUpdateCartProduct"
 	OpDeleteCartProduct string = "OpDeleteCartProduct"
 	OpEmptyCartProducts string = "OpEmptyCartProducts"
+	OpGetCartTotals     string = "OpGetCartTotals"
 
 	// ErrCodeCartProductExists is sent when attempting to add a product to a cart
 	// and that product is already in the cart.
@@ -227,6 +450,10 @@ const (
 	// ErrCodeCartNotFound is sent when attempting to do cart operation of a non existing
 	// cart.
 	ErrCodeCartNotFound string = "carts/cart-not-found"
+
+	// ErrCodeCartProductUnpublished is sent when pricing or ordering a cart
+	// that holds a product that is no longer published.
+	ErrCodeCartProductUnpublished string = "carts/cart-product-unpublished"
 )
 
 // Carts Coupons
@@ -339,10 +566,13 @@ const (
 	OpDeleteTierPricing string = "OpDeleteTierPricing"
 
 	// Inventory
-	OpGetInventory         string = "OpGetInventory"
-	OpListInventory        string = "OpListInventory"
-	OpUpdateInventory      string = "OpUpdateInventory"
-	OpBatchUpdateInventory string = "OpBatchUpdateInventory"
+	OpGetInventory           string = "OpGetInventory"
+	OpListInventory          string = "OpListInventory"
+	OpUpdateInventory        string = "OpUpdateInventory"
+	OpBatchUpdateInventory   string = "OpBatchUpdateInventory"
+	OpAdjustInventory        string = "OpAdjustInventory"
+	OpListInventoryMovements string = "OpListInventoryMovements"
+	OpListBackorders         string = "OpListBackorders"
 
 	// Product Set Items
 	OpGetProductSetItems string = "OpGetProductSetItems"
@@ -386,11 +616,25 @@ const (
 	// Categories
 	OpGetCategories    string = "OpGetCategories"
 	OpDeleteCategories string = "OpDeleteCategories"
+	OpCreateCategory   string = "OpCreateCategory"
+	OpGetCategory      string = "OpGetCategory"
+	OpUpdateCategory   string = "OpUpdateCategory"
+	OpMoveCategory     string = "OpMoveCategory"
+	OpDeleteCategory   string = "OpDeleteCategory"
+
+	OpReorderCategoryProducts string = "OpReorderCategoryProducts"
+	OpGetCategoryByPath       string = "OpGetCategoryByPath"
+
+	// Routes
+	OpResolvePath string = "OpResolvePath"
 
 	// Stripe
 	OpStripeCheckout string = "OpStripeCheckout"
 	OpStripeWebhook  string = "OpStripeWebhook"
 
+	// Payments
+	OpPaymentWebhook string = "OpPaymentWebhook"
+
 	// System
 	OpSystemInfo string = "OpSysInfo"
 
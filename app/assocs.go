@@ -194,7 +194,8 @@ func (a *App) UpdateProductCategoryAssocsHandler() http.HandlerFunc {
 		}
 		defer r.Body.Close()
 
-		tree, err := a.Service.GetCategoriesTree(ctx)
+		userID := ctx.Value(ecomUIDKey).(string)
+		tree, err := a.Service.GetCategoriesTree(ctx, userID, false)
 		if err != nil {
 			contextLogger.Errorf("a.Service.GetCatalog(ctx) failed: %+v", errors.Cause(err))
 			w.WriteHeader(http.StatusInternalServerError)
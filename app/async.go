@@ -0,0 +1,32 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+)
+
+// asyncFromQuery returns true if the async query parameter asks for an
+// operation to be run as a background job.
+func asyncFromQuery(v url.Values) (bool, error) {
+	if v.Get("async") == "" {
+		return false, nil
+	}
+	async, err := strconv.ParseBool(v.Get("async"))
+	if err != nil {
+		return false, errors.New("query parameter async must be true or false")
+	}
+	return async, nil
+}
+
+// jobAccepted writes a 202 Accepted response with the job and its
+// location.
+func jobAccepted(w http.ResponseWriter, job *service.Job) {
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted) // 202 Accepted
+	json.NewEncoder(w).Encode(job)
+}
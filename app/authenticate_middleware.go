@@ -17,6 +17,10 @@ type ecomUIDString string
 
 const ecomUIDKey ecomUIDString = "ecom_uid"
 
+type ecomRoleString string
+
+const ecomRoleKey ecomRoleString = "ecom_role"
+
 // AuthenticateMiddleware provides authentication layer
 func (a *App) AuthenticateMiddleware(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
@@ -11,6 +11,13 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// hasAdminRole returns true if the request was authorized with
+// RoleAdmin or RoleSuperUser privileges.
+func hasAdminRole(ctx context.Context) bool {
+	role, _ := ctx.Value(ecomRoleKey).(string)
+	return role == RoleAdmin || role == RoleSuperUser
+}
+
 // Authorization provides authorization middleware
 func (a *App) Authorization(op string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
@@ -41,6 +48,7 @@ func (a *App) Authorization(op string, next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		ctx2 := context.WithValue(ctx, ecomUIDKey, cid)
+		ctx2 = context.WithValue(ctx2, ecomRoleKey, role)
 
 		// superuser has all privileges. The JWT containing the claims is cryptographically
 		// signed with a claim of "root" so we give maximum privilege.
@@ -56,8 +64,9 @@ func (a *App) Authorization(op string, next http.HandlerFunc) http.HandlerFunc {
 		switch op {
 		// Operations that don't require any special authorization
 		case OpCreateCart, OpAddProductToCart, OpGetCartProducts, OpUpdateCartProduct,
-			OpDeleteCartProduct, OpEmptyCartProducts, OpGetCategories, OpGetCategoriesTree, OpSignInWithDevKey,
-			OpGetProduct, OpListProducts, OpGetProductCategoryRelations,
+			OpDeleteCartProduct, OpEmptyCartProducts, OpGetCartTotals, OpGetCategories, OpGetCategoriesTree, OpGetCategoryByPath, OpResolvePath, OpSignInWithDevKey,
+			OpGetProduct, OpListProducts, OpSearchProducts, OpListVariants, OpGetProductCategoryRelations,
+			OpGetOptionType, OpListOptionTypes, OpGetProductAttribute, OpListProductAttributes,
 			OpGetTierPricing, OpMapPricingByTier, OpGetImage,
 			OpListProductImages, OpPlaceOrder, OpStripeCheckout, OpGetPriceList,
 			OpListInventory, OpGetInventory, OpGetShippingTariff, OpListShippingTariffs,
@@ -70,6 +79,12 @@ func (a *App) Authorization(op string, next http.HandlerFunc) http.HandlerFunc {
 		// Operations that required at least RoleAdmin privileges
 		case OpListUsers, OpDeleteUser,
 			OpCreateProduct, OpUpdateProduct, OpDeleteProduct, OpDeleteCategories,
+			OpUpdateProductStatus, OpCreateVariant, OpCreateOptionType, OpDeleteOptionType,
+			OpCreateProductAttribute, OpDeleteProductAttribute,
+			OpCreateCatalogImport, OpGetCatalogImport, OpResumeCatalogImport, OpExportCatalog,
+			OpGetJob, OpCancelJob,
+			OpCreateCategory, OpGetCategory, OpUpdateCategory, OpMoveCategory, OpDeleteCategory,
+			OpReorderCategoryProducts,
 			OpUpdateProductCategoryRelations, OpSystemInfo,
 			OpAddProductCategoryRelations, OpUpdateProductPrices,
 			OpDeleteProductCategoryRelations, OpDeleteTierPricing,
@@ -77,14 +92,19 @@ func (a *App) Authorization(op string, next http.HandlerFunc) http.HandlerFunc {
 			OpCreatePriceList, OpListPriceLists, OpUpdatePriceList, OpDeletePriceList,
 			OpCreatePromoRule, OpDeletePromoRule, OpGetPromoRule, OpListPromoRules,
 			OpUpdateInventory, OpBatchUpdateInventory,
+			OpAdjustInventory, OpListInventoryMovements, OpListBackorders,
+			OpCreateLocation, OpGetLocation, OpListLocations, OpUpdateLocation,
 			OpUpdateCategoriesTree,
 			OpCreateShippingTariff, OpUpdateShippingTariff, OpDeleteShippingTariff,
+			OpCreateTaxRate, OpGetTaxRate, OpListTaxRates, OpDeleteTaxRate,
 			OpActivateOffer, OpDeactivateOffer,
 			OpCreateCoupon, OpGetCoupon, OpListCoupons, OpUpdateCoupon, OpDeleteCoupon,
 			OpCreateProductToProductAssocGroup,
 			OpDeleteProductToProductAssocGroup, OpDeleteProductToProductAssoc,
 			OpBatchUpdateProductToProductAssocs, OpCreateWebhook, OpGetWebhook, OpListWebhooks,
-			OpUpdateWebhook, OpDeleteWebhook, OpGetOrder, OpListOrders:
+			OpUpdateWebhook, OpDeleteWebhook, OpGetOrder, OpListOrders, OpUpdateOrder,
+			OpGetOrderHistory, OpCreateShipment, OpGetShipment, OpListShipments,
+			OpCreateRefund, OpListRefunds, OpCancelOrder:
 			if role == RoleAdmin {
 				next.ServeHTTP(w, r.WithContext(ctx2))
 				return
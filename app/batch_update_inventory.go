@@ -47,6 +47,11 @@ func validateBatchUpdateInventoryRequest(request *batchUpdateInventoryRequest) (
 		if overselling == nil {
 			return false, "attribute overselling must be set for all items"
 		}
+
+		// optional reorder_level attribute
+		if update.ReorderLevel != nil && *update.ReorderLevel < 0 {
+			return false, fmt.Sprintf("reorder_level must be a positive integer or zero for product=%q", *update.ProductID)
+		}
 	}
 
 	return true, ""
@@ -80,12 +85,18 @@ func (a *App) BatchUpdateInventoryHandler() http.HandlerFunc {
 		}
 
 		// do the batch updates
-		inventoryList, err := a.Service.BatchUpdateInventory(ctx, request.Data)
+		userID := ctx.Value(ecomUIDKey).(string)
+		inventoryList, err := a.Service.BatchUpdateInventory(ctx, userID, request.Data)
 		if err == service.ErrProductNotFound {
 			clientError(w, http.StatusNotFound,
 				ErrCodeProductNotFound, "one or more products could not be found") // 404
 			return
 		}
+		if err == service.ErrLocationNotFound {
+			clientError(w, http.StatusNotFound,
+				ErrCodeLocationNotFound, "one or more locations could not be found") // 404
+			return
+		}
 		if err != nil {
 			contextLogger.Infof("app: a.Service.BatchUpdateInventory(ctx, request.Data) failed: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
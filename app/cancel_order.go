@@ -0,0 +1,78 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+type cancelOrderRequestBody struct {
+	Reason *string `json:"reason"`
+}
+
+// CancelOrderHandler returns a http.HandlerFunc that cancels an order
+// refunding any payment.
+func (a *App) CancelOrderHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CancelOrderHandler called")
+
+		orderID := chi.URLParam(r, "id")
+		if !IsValidUUID(orderID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		request := cancelOrderRequestBody{}
+		if r.Body != nil && r.ContentLength != 0 {
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&request); err != nil {
+				clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+					err.Error()) // 400
+				return
+			}
+			defer r.Body.Close()
+		}
+		if request.Reason != nil && len(*request.Reason) > 1024 {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"attribute reason must be no more than 1024 characters") // 400
+			return
+		}
+
+		order, err := a.Service.CancelOrder(ctx, orderID, request.Reason)
+		if err == service.ErrOrderNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeOrderNotFound,
+				"order not found") // 404
+			return
+		}
+		if err == service.ErrOrderTransitionInvalid {
+			clientError(w, http.StatusConflict, ErrCodeOrderTransitionInvalid,
+				"order cannot be cancelled from its current status") // 409
+			return
+		}
+		if err == service.ErrOrderStatusChanged {
+			clientError(w, http.StatusConflict, ErrCodeOrderStatusChanged,
+				"order status was changed by another request") // 409
+			return
+		}
+		if err == service.ErrRefundFailed {
+			clientError(w, http.StatusConflict, ErrCodeRefundFailed,
+				"refund was declined by the payment provider so the order was not cancelled") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CancelOrder(ctx, orderID=%q, ...) failed: %+v",
+				orderID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(&order)
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxCatalogImportSize is the maximum size in bytes of a catalog import
+// request body.
+const maxCatalogImportSize = 32 << 20
+
+// CreateCatalogImportHandler creates a handler function that imports a
+// CSV or JSON Lines catalog from the request body. The format query
+// parameter is either csv or jsonl and defaults to csv. With the async
+// query parameter set to true valid imports are applied by a background
+// job.
+func (a *App) CreateCatalogImportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateCatalogImportHandler started")
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = service.CatalogFormatCSV
+		}
+		if !service.IsValidCatalogFormat(format) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "query parameter format must be csv or jsonl") // 400
+			return
+		}
+		async, err := asyncFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		userID := ctx.Value(ecomUIDKey).(string)
+		body := http.MaxBytesReader(w, r.Body, maxCatalogImportSize)
+		var catalogImport *service.CatalogImport
+		var job *service.Job
+		if async {
+			catalogImport, job, err = a.Service.ImportCatalogAsync(ctx, userID, format, body)
+		} else {
+			catalogImport, err = a.Service.ImportCatalog(ctx, userID, format, body)
+		}
+		if ferr, ok := err.(*service.CatalogFormatError); ok {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, ferr.Message) // 400
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.ImportCatalog(ctx, userID=%q, format=%q, body) failed: %+v", userID, format, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		if job != nil {
+			jobAccepted(w, job)
+			return
+		}
+		w.WriteHeader(http.StatusCreated) // 201 Created
+		json.NewEncoder(w).Encode(&catalogImport)
+	}
+}
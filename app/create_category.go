@@ -0,0 +1,114 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+type createCategoryRequestBody struct {
+	ParentID *string `json:"parent_id"`
+	Segment  *string `json:"segment"`
+	Name     *string `json:"name"`
+	Position *int    `json:"position"`
+}
+
+// validateCategorySegment checks a segment can be used in a category
+// path.
+func validateCategorySegment(segment string) (bool, string) {
+	if len(segment) < 1 || len(segment) > 512 {
+		return false, "attribute segment must be between 1 and 512 characters"
+	}
+	if strings.Contains(segment, "/") {
+		return false, "attribute segment must not contain a forward slash"
+	}
+	return true, ""
+}
+
+func validateCreateCategoryRequest(request *createCategoryRequestBody) (bool, string) {
+	if request.ParentID != nil && !IsValidUUID(*request.ParentID) {
+		return false, "attribute parent_id must be a valid v4 uuid"
+	}
+	if request.Segment == nil {
+		return false, "attribute segment must be set"
+	}
+	if valid, message := validateCategorySegment(*request.Segment); !valid {
+		return false, message
+	}
+	if request.Name == nil {
+		return false, "attribute name must be set"
+	}
+	if len(*request.Name) < 1 || len(*request.Name) > 1024 {
+		return false, "attribute name must be between 1 and 1024 characters"
+	}
+	if request.Position != nil && *request.Position < 0 {
+		return false, "attribute position must not be negative"
+	}
+	return true, ""
+}
+
+// categoryClientError writes the 4xx response for the errors of category
+// edits and returns true if err is one of them.
+func categoryClientError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case service.ErrCategoryNotFound:
+		clientError(w, http.StatusNotFound, ErrCodeCategoryNotFound, "category not found") // 404
+	case service.ErrCategoryPathExists:
+		clientError(w, http.StatusConflict, ErrCodeCategoryPathExists,
+			"a sibling category already has the same segment") // 409
+	case service.ErrCategoryHasProducts:
+		clientError(w, http.StatusConflict, ErrCodeCategoryHasProducts,
+			"leaf category has products - remove the product to category relations first") // 409
+	case service.ErrCategoryRootExists:
+		clientError(w, http.StatusConflict, ErrCodeCategoryRootExists,
+			"root category already exists - set parent_id") // 409
+	case service.ErrCategoryMoveInvalid:
+		clientError(w, http.StatusConflict, ErrCodeCategoryMoveInvalid,
+			"the root category cannot be moved and a category cannot be moved under itself or its descendants") // 409
+	case service.ErrCategoryNotLeaf:
+		clientError(w, http.StatusConflict, ErrCodeCategoryNotLeaf, "only leaf categories can be deleted") // 409
+	case service.ErrCategoriesInUse:
+		clientError(w, http.StatusConflict, ErrCodeCategoriesInUse, "category in use - check promo rules") // 409
+	default:
+		return false
+	}
+	return true
+}
+
+// CreateCategoryHandler creates a handler function that adds a category
+// to the categories tree without changing the rest of the tree.
+func (a *App) CreateCategoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateCategoryHandler started")
+
+		request := createCategoryRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		valid, message := validateCreateCategoryRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, message) // 400
+			return
+		}
+
+		category, err := a.Service.CreateCategory(ctx, request.ParentID, *request.Segment, *request.Name, request.Position)
+		if categoryClientError(w, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CreateCategory(ctx, parentID=%v, segment=%q, ...) failed: %+v", request.ParentID, *request.Segment, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusCreated) // 201 Created
+		json.NewEncoder(w).Encode(&category)
+	}
+}
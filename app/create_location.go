@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+type createLocationRequestBody struct {
+	Code        *string `json:"code"`
+	Name        *string `json:"name"`
+	CountryCode *string `json:"country_code"`
+	Priority    *int    `json:"priority"`
+	Active      *bool   `json:"active"`
+}
+
+func validateCreateLocationRequest(request *createLocationRequestBody) (bool, string) {
+	// code attribute
+	if request.Code == nil {
+		return false, "attribute code must be set"
+	}
+	if len(*request.Code) < 1 || len(*request.Code) > 32 {
+		return false, "attribute code must be between 1 and 32 characters"
+	}
+
+	// name attribute
+	if request.Name == nil {
+		return false, "attribute name must be set"
+	}
+
+	// country_code attribute
+	if request.CountryCode == nil {
+		return false, "attribute country_code must be set"
+	}
+	if len(*request.CountryCode) != 2 {
+		return false, "attribute country_code must be a two letter country code"
+	}
+
+	// priority attribute
+	if request.Priority == nil {
+		return false, "attribute priority must be set"
+	}
+
+	// active attribute
+	if request.Active == nil {
+		return false, "attribute active must be set"
+	}
+	return true, ""
+}
+
+// CreateLocationHandler creates a stock location.
+func (a *App) CreateLocationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateLocationHandler called")
+
+		request := createLocationRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		valid, message := validateCreateLocationRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, message) // 400
+			return
+		}
+
+		location, err := a.Service.CreateLocation(ctx, *request.Code, *request.Name,
+			*request.CountryCode, *request.Priority, *request.Active)
+		if err == service.ErrLocationCodeExists {
+			clientError(w, http.StatusConflict, ErrCodeLocationCodeExists,
+				"location code already exists") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CreateLocation(ctx, code=%q, ...) failed: %+v", *request.Code, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusCreated) // 201
+		json.NewEncoder(w).Encode(&location)
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+type createOptionTypeRequestBody struct {
+	Code *string `json:"code"`
+	Name *string `json:"name"`
+}
+
+func validateCreateOptionTypeRequest(request *createOptionTypeRequestBody) (bool, string) {
+	// code attribute
+	if request.Code == nil {
+		return false, "attribute code must be set"
+	}
+	if len(*request.Code) < 1 || len(*request.Code) > 32 {
+		return false, "attribute code must be between 1 and 32 characters"
+	}
+
+	// name attribute
+	if request.Name == nil {
+		return false, "attribute name must be set"
+	}
+	return true, ""
+}
+
+// CreateOptionTypeHandler creates an option type such as size or colour.
+func (a *App) CreateOptionTypeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateOptionTypeHandler called")
+
+		request := createOptionTypeRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		valid, message := validateCreateOptionTypeRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, message) // 400
+			return
+		}
+
+		optionType, err := a.Service.CreateOptionType(ctx, *request.Code, *request.Name)
+		if err == service.ErrOptionTypeCodeExists {
+			clientError(w, http.StatusConflict, ErrCodeOptionTypeCodeExists,
+				"option type code already exists") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CreateOptionType(ctx, code=%q, ...) failed: %+v", *request.Code, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusCreated) // 201
+		json.NewEncoder(w).Encode(&optionType)
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
@@ -21,6 +22,18 @@ func validateProductCreateRequestBody(request *service.ProductCreateRequestBody)
 		return false, "name attribute not set"
 	}
 
+	if request.TaxCode != nil && (*request.TaxCode == "" || len(*request.TaxCode) > 32) {
+		return false, "tax_code attribute must be between 1 and 32 characters"
+	}
+
+	if request.MetaTitle != nil && len(*request.MetaTitle) > 512 {
+		return false, "meta_title attribute must be at most 512 characters"
+	}
+
+	if request.MetaDescription != nil && len(*request.MetaDescription) > 1024 {
+		return false, "meta_description attribute must be at most 1024 characters"
+	}
+
 	return true, ""
 }
 
@@ -49,6 +62,11 @@ func (a *App) CreateProductHandler() http.HandlerFunc {
 		userID := ctx.Value(ecomUIDKey).(string)
 		contextLogger.Debugf("app: ecom_uid=%q", userID)
 		product, err := a.Service.CreateProduct(ctx, userID, &request)
+		if e, ok := err.(*service.ProductAttributeError); ok {
+			clientError(w, http.StatusBadRequest, ErrCodeProductAttributeInvalid,
+				fmt.Sprintf("attributes %s %s", e.Code, e.Message)) // 400
+			return
+		}
 		if err == service.ErrPriceListNotFound {
 			clientError(w, http.StatusConflict, ErrCodePriceListNotFound, "price list could not be found")
 			return
@@ -61,6 +79,10 @@ func (a *App) CreateProductHandler() http.HandlerFunc {
 			clientError(w, http.StatusConflict, ErrCodeProductSKUExists, "product sku already exists")
 			return
 		}
+		if err == service.ErrTaxCodeNotFound {
+			clientError(w, http.StatusConflict, ErrCodeTaxCodeNotFound, "tax code has no tax rates")
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("create product failed: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500 Internal Server Error
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+type createProductAttributeRequestBody struct {
+	Code *string `json:"code"`
+	Name *string `json:"name"`
+	Typ  *string `json:"type"`
+}
+
+func validateCreateProductAttributeRequest(request *createProductAttributeRequestBody) (bool, string) {
+	// code attribute
+	if request.Code == nil {
+		return false, "attribute code must be set"
+	}
+	if len(*request.Code) < 1 || len(*request.Code) > 32 {
+		return false, "attribute code must be between 1 and 32 characters"
+	}
+
+	// name attribute
+	if request.Name == nil {
+		return false, "attribute name must be set"
+	}
+
+	// type attribute
+	if request.Typ == nil {
+		return false, "attribute type must be set"
+	}
+	switch *request.Typ {
+	case "string", "integer", "number", "boolean":
+	default:
+		return false, "attribute type must be one of string, integer, number or boolean"
+	}
+	return true, ""
+}
+
+// CreateProductAttributeHandler creates a product attribute definition
+// used to validate the custom attributes of products.
+func (a *App) CreateProductAttributeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateProductAttributeHandler called")
+
+		request := createProductAttributeRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		valid, message := validateCreateProductAttributeRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, message) // 400
+			return
+		}
+
+		productAttribute, err := a.Service.CreateProductAttribute(ctx, *request.Code, *request.Name, *request.Typ)
+		if err == service.ErrProductAttributeCodeExists {
+			clientError(w, http.StatusConflict, ErrCodeProductAttributeCodeExists,
+				"product attribute code already exists") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CreateProductAttribute(ctx, code=%q, ...) failed: %+v", *request.Code, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusCreated) // 201
+		json.NewEncoder(w).Encode(&productAttribute)
+	}
+}
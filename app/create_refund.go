@@ -0,0 +1,134 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+type createRefundRequestBody struct {
+	Amount  *int                         `json:"amount"`
+	Items   []*service.RefundItemRequest `json:"items"`
+	Restock *bool                        `json:"restock"`
+	Reason  *string                      `json:"reason"`
+}
+
+func validateCreateRefundRequest(request *createRefundRequestBody) (bool, string) {
+	// amount attribute
+	if request.Amount != nil && *request.Amount < 1 {
+		return false, "attribute amount must be at least 1"
+	}
+
+	// items attribute
+	seen := make(map[string]bool)
+	for _, i := range request.Items {
+		if i == nil || i.OrderItemID == nil {
+			return false, "attribute order_item_id must be set for each item"
+		}
+		if !IsValidUUID(*i.OrderItemID) {
+			return false, "attribute order_item_id must be a valid v4 uuid"
+		}
+		if seen[*i.OrderItemID] {
+			return false, "attribute order_item_id must not be repeated"
+		}
+		seen[*i.OrderItemID] = true
+		if i.Qty == nil || *i.Qty < 1 {
+			return false, "attribute qty must be at least 1 for each item"
+		}
+	}
+
+	// restock attribute
+	if request.Restock != nil && *request.Restock && request.Amount != nil && len(request.Items) == 0 {
+		return false, "attribute restock requires items when amount is set"
+	}
+
+	// reason attribute
+	if request.Reason != nil && len(*request.Reason) > 1024 {
+		return false, "attribute reason must be no more than 1024 characters"
+	}
+	return true, ""
+}
+
+// CreateRefundHandler creates a full or partial refund against an order.
+func (a *App) CreateRefundHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateRefundHandler called")
+
+		orderID := chi.URLParam(r, "id")
+		if !IsValidUUID(orderID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		if r.Body == nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"missing request body") // 400
+			return
+		}
+		request := createRefundRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&request)
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				err.Error()) // 400
+			return
+		}
+		defer r.Body.Close()
+
+		valid, message := validateCreateRefundRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				message) // 400
+			return
+		}
+
+		restock := request.Restock != nil && *request.Restock
+		refund, err := a.Service.CreateRefund(ctx, orderID, request.Amount,
+			request.Items, restock, request.Reason)
+		if err == service.ErrOrderNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeOrderNotFound,
+				"order not found") // 404
+			return
+		}
+		if err == service.ErrRefundOrderItemNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeRefundOrderItemNotFound,
+				"order item not found in this order") // 404
+			return
+		}
+		if err == service.ErrOrderNotRefundable {
+			clientError(w, http.StatusConflict, ErrCodeOrderNotRefundable,
+				"order has not been paid or has been refunded in full") // 409
+			return
+		}
+		if err == service.ErrRefundQtyExceeded {
+			clientError(w, http.StatusConflict, ErrCodeRefundQtyExceeded,
+				"qty exceeds the quantity left to refund for the order item") // 409
+			return
+		}
+		if err == service.ErrRefundAmountExceeded {
+			clientError(w, http.StatusConflict, ErrCodeRefundAmountExceeded,
+				"amount exceeds the amount left to refund for the order") // 409
+			return
+		}
+		if err == service.ErrRefundFailed {
+			clientError(w, http.StatusConflict, ErrCodeRefundFailed,
+				"refund was declined by the payment provider") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CreateRefund(ctx, orderID=%q, ...) failed: %+v",
+				orderID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusCreated) // 201
+		json.NewEncoder(w).Encode(&refund)
+	}
+}
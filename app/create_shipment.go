@@ -0,0 +1,137 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+type createShipmentRequestBody struct {
+	Carrier        *string                        `json:"carrier"`
+	TrackingNumber *string                        `json:"tracking_number"`
+	TrackingURL    *string                        `json:"tracking_url"`
+	Items          []*service.ShipmentItemRequest `json:"items"`
+}
+
+func validateCreateShipmentRequest(request *createShipmentRequestBody) (bool, string) {
+	// carrier attribute
+	if request.Carrier == nil {
+		return false, "attribute carrier must be set"
+	}
+	if *request.Carrier == "" || len(*request.Carrier) > 128 {
+		return false, "attribute carrier must be between 1 and 128 characters"
+	}
+
+	// tracking_number attribute
+	if request.TrackingNumber == nil {
+		return false, "attribute tracking_number must be set"
+	}
+	if *request.TrackingNumber == "" || len(*request.TrackingNumber) > 128 {
+		return false, "attribute tracking_number must be between 1 and 128 characters"
+	}
+
+	// tracking_url attribute
+	if request.TrackingURL != nil && (*request.TrackingURL == "" || len(*request.TrackingURL) > 2048) {
+		return false, "attribute tracking_url must be between 1 and 2048 characters"
+	}
+
+	// items attribute
+	if len(request.Items) == 0 {
+		return false, "attribute items must contain at least one item"
+	}
+	seen := make(map[string]bool)
+	for _, i := range request.Items {
+		if i == nil || i.OrderItemID == nil {
+			return false, "attribute order_item_id must be set for each item"
+		}
+		if !IsValidUUID(*i.OrderItemID) {
+			return false, "attribute order_item_id must be a valid v4 uuid"
+		}
+		if seen[*i.OrderItemID] {
+			return false, "attribute order_item_id must not be repeated"
+		}
+		seen[*i.OrderItemID] = true
+		if i.Qty == nil || *i.Qty < 1 {
+			return false, "attribute qty must be at least 1 for each item"
+		}
+	}
+	return true, ""
+}
+
+// CreateShipmentHandler creates a shipment against an order.
+func (a *App) CreateShipmentHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateShipmentHandler called")
+
+		orderID := chi.URLParam(r, "id")
+		if !IsValidUUID(orderID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		if r.Body == nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"missing request body") // 400
+			return
+		}
+		request := createShipmentRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&request)
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				err.Error()) // 400
+			return
+		}
+		defer r.Body.Close()
+
+		valid, message := validateCreateShipmentRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				message) // 400
+			return
+		}
+
+		shipment, err := a.Service.CreateShipment(ctx, orderID, *request.Carrier,
+			*request.TrackingNumber, request.TrackingURL, request.Items)
+		if err == service.ErrOrderNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeOrderNotFound,
+				"order not found") // 404
+			return
+		}
+		if err == service.ErrShipmentOrderItemNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeShipmentOrderItemNotFound,
+				"order item not found in this order") // 404
+			return
+		}
+		if err == service.ErrOrderNotShippable {
+			clientError(w, http.StatusConflict, ErrCodeOrderNotShippable,
+				"order cannot be shipped in its current status") // 409
+			return
+		}
+		if err == service.ErrOrderStatusChanged {
+			clientError(w, http.StatusConflict, ErrCodeOrderStatusChanged,
+				"order status was changed by another request") // 409
+			return
+		}
+		if err == service.ErrShipmentQtyExceeded {
+			clientError(w, http.StatusConflict, ErrCodeShipmentQtyExceeded,
+				"qty exceeds the quantity left to ship for the order item") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CreateShipment(ctx, orderID=%q, ...) failed: %+v",
+				orderID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusCreated) // 201
+		json.NewEncoder(w).Encode(&shipment)
+	}
+}
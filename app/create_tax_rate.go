@@ -0,0 +1,93 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type createTaxRateRequestBody struct {
+	TaxCode       *string    `json:"tax_code"`
+	CountryCode   *string    `json:"country_code"`
+	Region        *string    `json:"region"`
+	Rate          *int       `json:"rate"`
+	EffectiveFrom *time.Time `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+}
+
+func validateCreateTaxRateRequest(request *createTaxRateRequestBody) (bool, string) {
+	// tax_code attribute
+	if request.TaxCode == nil {
+		return false, "attribute tax_code must be set"
+	}
+	if *request.TaxCode == "" || len(*request.TaxCode) > 32 {
+		return false, "attribute tax_code must be between 1 and 32 characters"
+	}
+
+	// country_code attribute
+	if request.CountryCode == nil {
+		return false, "attribute country_code must be set"
+	}
+	if len(*request.CountryCode) != 2 {
+		return false, "attribute country_code must be a two letter country code"
+	}
+
+	// rate attribute
+	if request.Rate == nil {
+		return false, "attribute rate must be set"
+	}
+	if *request.Rate < 0 || *request.Rate > 10000 {
+		return false, "attribute rate must be between 0 and 10000"
+	}
+
+	// effective_to attribute
+	if request.EffectiveTo != nil && request.EffectiveFrom != nil && !request.EffectiveTo.After(*request.EffectiveFrom) {
+		return false, "attribute effective_to must be after effective_from"
+	}
+	return true, ""
+}
+
+// CreateTaxRateHandler creates a tax rate
+func (a *App) CreateTaxRateHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateTaxRateHandler called")
+
+		if r.Body == nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"missing request body") // 400
+			return
+		}
+		request := createTaxRateRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&request)
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				err.Error()) // 400
+			return
+		}
+		defer r.Body.Close()
+
+		valid, message := validateCreateTaxRateRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				message) // 400
+			return
+		}
+
+		taxRate, err := a.Service.CreateTaxRate(ctx, *request.TaxCode, *request.CountryCode,
+			request.Region, *request.Rate, request.EffectiveFrom, request.EffectiveTo)
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CreateTaxRate(ctx, taxCode=%q, countryCode=%q, ...) failed: %+v",
+				*request.TaxCode, *request.CountryCode, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusCreated) // 201
+		json.NewEncoder(w).Encode(&taxRate)
+	}
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+func validateVariantCreateRequestBody(request *service.VariantCreateRequestBody) (bool, string) {
+	if request.Path == "" {
+		return false, "path attribute not set"
+	}
+
+	if request.SKU == "" {
+		return false, "sku attribute not set"
+	}
+
+	if request.Name == "" {
+		return false, "name attribute not set"
+	}
+
+	if request.TaxCode != nil && (*request.TaxCode == "" || len(*request.TaxCode) > 32) {
+		return false, "tax_code attribute must be between 1 and 32 characters"
+	}
+
+	if request.MetaTitle != nil && len(*request.MetaTitle) > 512 {
+		return false, "meta_title attribute must be at most 512 characters"
+	}
+
+	if request.MetaDescription != nil && len(*request.MetaDescription) > 1024 {
+		return false, "meta_description attribute must be at most 1024 characters"
+	}
+
+	if len(request.Options) == 0 {
+		return false, "options attribute must contain at least one option"
+	}
+	for code, value := range request.Options {
+		if value == "" || len(value) > 64 {
+			return false, "options attribute " + code + " must be between 1 and 64 characters"
+		}
+	}
+
+	return true, ""
+}
+
+// CreateVariantHandler creates a new variant of a product.
+func (a *App) CreateVariantHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: CreateVariantHandler called")
+
+		productID := chi.URLParam(r, "id")
+		if !IsValidUUID(productID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		request := service.VariantCreateRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		defer r.Body.Close()
+
+		valid, message := validateVariantCreateRequestBody(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, message) // 400
+			return
+		}
+
+		userID := ctx.Value(ecomUIDKey).(string)
+		variant, err := a.Service.CreateVariant(ctx, userID, productID, &request)
+		if e, ok := err.(*service.ProductAttributeError); ok {
+			clientError(w, http.StatusBadRequest, ErrCodeProductAttributeInvalid,
+				fmt.Sprintf("attributes %s %s", e.Code, e.Message)) // 400
+			return
+		}
+		if err == service.ErrProductNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeProductNotFound, "product not found") // 404
+			return
+		}
+		if err == service.ErrProductIsVariant {
+			clientError(w, http.StatusConflict, ErrCodeProductIsVariant,
+				"variants can not be created for a product that is a variant") // 409
+			return
+		}
+		if err == service.ErrOptionTypeNotFound {
+			clientError(w, http.StatusConflict, ErrCodeOptionTypeNotFound,
+				"one or more option types in options could not be found") // 409
+			return
+		}
+		if err == service.ErrVariantOptionsExist {
+			clientError(w, http.StatusConflict, ErrCodeVariantOptionsExist,
+				"another variant of the product has the same options") // 409
+			return
+		}
+		if err == service.ErrProductPathExists {
+			clientError(w, http.StatusConflict, ErrCodeProductPathExists, "product path already exists") // 409
+			return
+		}
+		if err == service.ErrProductSKUExists {
+			clientError(w, http.StatusConflict, ErrCodeProductSKUExists, "product sku already exists") // 409
+			return
+		}
+		if err == service.ErrTaxCodeNotFound {
+			clientError(w, http.StatusConflict, ErrCodeTaxCodeNotFound, "tax code has no tax rates") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.CreateVariant(ctx, userID=%q, productID=%q, ...) failed: %+v", userID, productID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		contextLogger.Infof("app: variant %q of product %q created", variant.ID, productID)
+
+		w.WriteHeader(http.StatusCreated) // 201
+		json.NewEncoder(w).Encode(variant)
+	}
+}
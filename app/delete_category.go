@@ -0,0 +1,37 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeleteCategoryHandler creates a handler function that deletes a leaf
+// category without changing the rest of the tree.
+func (a *App) DeleteCategoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: DeleteCategoryHandler started")
+
+		categoryID := chi.URLParam(r, "id")
+		if !IsValidUUID(categoryID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "url parameter must be a valid v4 UUID") // 400
+			return
+		}
+
+		err := a.Service.DeleteCategory(ctx, categoryID)
+		if categoryClientError(w, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.DeleteCategory(ctx, categoryID=%q) failed: %+v", categoryID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.Header().Del("Content-Type")
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusNoContent) // 204
+	}
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeleteOptionTypeHandler create a handler to delete an option type.
+func (a *App) DeleteOptionTypeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: DeleteOptionTypeHandler started")
+
+		optionTypeID := chi.URLParam(r, "id")
+		if !IsValidUUID(optionTypeID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameters id must be a valid v4 uuid") // 400
+			return
+		}
+		err := a.Service.DeleteOptionType(ctx, optionTypeID)
+		if err == service.ErrOptionTypeNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeOptionTypeNotFound,
+				"option type not found") // 404
+			return
+		}
+		if err == service.ErrOptionTypeInUse {
+			clientError(w, http.StatusConflict, ErrCodeOptionTypeInUse,
+				"option type is used by product variants") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: DeleteOptionType(ctx, optionTypeID=%q) failed: %+v", optionTypeID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.Header().Del("Content-Type")
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusNoContent) // 204
+	}
+}
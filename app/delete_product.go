@@ -21,6 +21,10 @@ func (a *App) DeleteProductHandler() http.HandlerFunc {
 			clientError(w, http.StatusNotFound, ErrCodeProductNotFound, "product not found")
 			return
 		}
+		if err == service.ErrProductHasVariants {
+			clientError(w, http.StatusConflict, ErrCodeProductHasVariants, "product variants must be deleted first") // 409
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app DeleteProduct(ctx, productID=%q) failed: %+v", productID, err)
 			w.WriteHeader(http.StatusInternalServerError) // 500 Internal Server Error
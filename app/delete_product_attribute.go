@@ -0,0 +1,44 @@
+package app
+
+import (
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeleteProductAttributeHandler create a handler to delete a product attribute.
+func (a *App) DeleteProductAttributeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: DeleteProductAttributeHandler started")
+
+		productAttributeID := chi.URLParam(r, "id")
+		if !IsValidUUID(productAttributeID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameters id must be a valid v4 uuid") // 400
+			return
+		}
+		err := a.Service.DeleteProductAttribute(ctx, productAttributeID)
+		if err == service.ErrProductAttributeNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeProductAttributeNotFound,
+				"product attribute not found") // 404
+			return
+		}
+		if err == service.ErrProductAttributeInUse {
+			clientError(w, http.StatusConflict, ErrCodeProductAttributeInUse,
+				"product attribute is used by products") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: DeleteProductAttribute(ctx, productAttributeID=%q) failed: %+v", productAttributeID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.Header().Del("Content-Type")
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusNoContent) // 204
+	}
+}
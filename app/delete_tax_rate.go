@@ -0,0 +1,39 @@
+package app
+
+import (
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeleteTaxRateHandler create a handler to delete a tax rate.
+func (a *App) DeleteTaxRateHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: DeleteTaxRateHandler started")
+
+		taxRateID := chi.URLParam(r, "id")
+		if !IsValidUUID(taxRateID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameters id must be a valid v4 uuid") // 400
+			return
+		}
+		err := a.Service.DeleteTaxRate(ctx, taxRateID)
+		if err == service.ErrTaxRateNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeTaxRateNotFound,
+				"tax rate not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: DeleteTaxRate(ctx, taxRateID=%q) failed: %+v", taxRateID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.Header().Del("Content-Type")
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusNoContent) // 204
+	}
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// exportWriter records whether anything has been written to the
+// response so an error can still be reported with a status code.
+type exportWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *exportWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// ExportCatalogHandler creates a handler function that streams the whole
+// catalog as CSV or JSON Lines in the same format accepted by catalog
+// imports. The format query parameter is either csv or jsonl and
+// defaults to csv.
+func (a *App) ExportCatalogHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ExportCatalogHandler started")
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = service.CatalogFormatCSV
+		}
+		if !service.IsValidCatalogFormat(format) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "query parameter format must be csv or jsonl") // 400
+			return
+		}
+
+		contentType := "text/csv; charset=utf-8"
+		if format == service.CatalogFormatJSONL {
+			contentType = "application/x-ndjson"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="catalog.`+format+`"`)
+
+		ew := exportWriter{ResponseWriter: w}
+		if err := a.Service.ExportCatalog(ctx, format, &ew); err != nil {
+			contextLogger.Errorf("app: a.Service.ExportCatalog(ctx, format=%q, w) failed: %+v", format, err)
+			if !ew.written {
+				w.Header().Del("Content-Disposition")
+				w.WriteHeader(http.StatusInternalServerError) // 500
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetCartTotalsHandler returns an http.HandlerFunc that returns the
+// totals of a cart priced the same way as placing an order.
+func (a *App) GetCartTotalsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetCartTotalsHandler started")
+
+		cartID := chi.URLParam(r, "id")
+		if !IsValidUUID(cartID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"URL parameter id must be a valid v4 UUID") // 400
+			return
+		}
+
+		var shippingTariffID *string
+		if v := r.URL.Query().Get("shipping_tariff_id"); v != "" {
+			if !IsValidUUID(v) {
+				clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+					"query parameter shipping_tariff_id must be a valid v4 UUID") // 400
+				return
+			}
+			shippingTariffID = &v
+		}
+
+		var countryCode, region *string
+		if v := r.URL.Query().Get("country_code"); v != "" {
+			if len(v) != 2 {
+				clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+					"query parameter country_code must be a two letter country code") // 400
+				return
+			}
+			countryCode = &v
+		}
+		if v := r.URL.Query().Get("region"); v != "" {
+			if countryCode == nil {
+				clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+					"query parameter region requires country_code") // 400
+				return
+			}
+			region = &v
+		}
+
+		userID := ctx.Value(ecomUIDKey).(string)
+		totals, err := a.Service.GetCartTotals(ctx, userID, cartID, shippingTariffID, countryCode, region)
+		if err == service.ErrCartNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeCartNotFound,
+				"cart not found") // 404
+			return
+		}
+		if err == service.ErrUserNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeUserNotFound,
+				"user for this call could not be found") // 404
+			return
+		}
+		if err == service.ErrDefaultPriceListNotFound {
+			clientError(w, http.StatusNotFound, ErrCodePriceListNotFound,
+				"user price list could not be found") // 404
+			return
+		}
+		if err == service.ErrShippingTariffNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeShippingTariffNotFound,
+				"shipping tariff not found") // 404
+			return
+		}
+		if err == service.ErrTaxRateNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeTaxRateNotFound,
+				"no tax rate found for the destination") // 404
+			return
+		}
+		if err == service.ErrCartProductUnpublished {
+			clientError(w, http.StatusConflict, ErrCodeCartProductUnpublished,
+				"the cart contains a product that is no longer available") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetCartTotals(ctx, userID=%q, cartID=%q, ...) failed: %+v", userID, cartID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(totals)
+	}
+}
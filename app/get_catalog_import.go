@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetCatalogImportHandler creates a handler function that returns a
+// single catalog import along with its row errors.
+func (a *App) GetCatalogImportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetCatalogImportHandler started")
+
+		catalogImportID := chi.URLParam(r, "id")
+		if !IsValidUUID(catalogImportID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "url parameter must be a valid v4 UUID") // 400
+			return
+		}
+
+		catalogImport, err := a.Service.GetCatalogImport(ctx, catalogImportID)
+		if err == service.ErrCatalogImportNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeCatalogImportNotFound, "catalog import not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetCatalogImport(ctx, catalogImportID=%q) failed: %+v", catalogImportID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&catalogImport)
+	}
+}
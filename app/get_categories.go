@@ -21,7 +21,8 @@ func (a *App) GetCategoriesHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: GetCategoriesHandler called")
 
-		categories, err := a.Service.GetCategories(ctx)
+		// shoppers do not see hidden categories.
+		categories, err := a.Service.GetCategories(ctx, !hasAdminRole(ctx))
 		if err != nil {
 			contextLogger.Errorf("app: a.Service.GetCategories(ctx) error: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500 Internal Server Error
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
@@ -16,7 +17,14 @@ func (app *App) GetCategoriesTreeHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: GetCategoriesHandler called")
 
-		tree, err := app.Service.GetCategoriesTree(ctx)
+		// shoppers do not see hidden categories.
+		userID := ctx.Value(ecomUIDKey).(string)
+		tree, err := app.Service.GetCategoriesTree(ctx, userID, !hasAdminRole(ctx))
+		if err == service.ErrCategoriesEmpty {
+			w.WriteHeader(http.StatusOK) // 200
+			w.Write([]byte("{}"))
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: service GetCatalog(ctx) error: %+v",
 				errors.WithStack(err))
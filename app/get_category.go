@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetCategoryHandler creates a handler function that returns a single
+// category.
+func (a *App) GetCategoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetCategoryHandler started")
+
+		categoryID := chi.URLParam(r, "id")
+		if !IsValidUUID(categoryID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "url parameter must be a valid v4 UUID") // 400
+			return
+		}
+
+		category, err := a.Service.GetCategory(ctx, categoryID)
+		if err == service.ErrCategoryNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeCategoryNotFound, "category not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetCategory(ctx, categoryID=%q) failed: %+v", categoryID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&category)
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetCategoryByPathHandler creates a handler function that returns the
+// category with the path given by the path query parameter along with
+// its breadcrumbs, children and a page of the products in its subtree.
+func (a *App) GetCategoryByPathHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetCategoryByPathHandler started")
+
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "query parameter path must be set") // 400
+			return
+		}
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		// shoppers do not see hidden categories or unpublished products.
+		userID := ctx.Value(ecomUIDKey).(string)
+		landing, err := a.Service.GetCategoryLanding(ctx, userID, path, opts, !hasAdminRole(ctx))
+		if listClientError(w, opts, err) {
+			return
+		}
+		if err == service.ErrCategoryNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeCategoryNotFound, "category not found") // 404
+			return
+		}
+		if err == service.ErrDefaultPriceListNotFound {
+			clientError(w, http.StatusNotFound, ErrCodePriceListNotFound,
+				"default price list not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetCategoryLanding(ctx, userID=%q, path=%q, ...) failed: %+v", userID, path, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(landing)
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -36,7 +37,20 @@ func (a *App) GetInventoryHandler() http.HandlerFunc {
 			return
 		}
 
+		hideInventoryLocations(ctx, inventory)
 		w.WriteHeader(http.StatusOK) // 200
 		json.NewEncoder(w).Encode(&inventory)
 	}
 }
+
+// hideInventoryLocations removes the onhand at each location from the
+// inventory unless the caller has admin privileges. Shoppers only see
+// the total onhand.
+func hideInventoryLocations(ctx context.Context, inventory ...*service.Inventory) {
+	if hasAdminRole(ctx) {
+		return
+	}
+	for _, v := range inventory {
+		v.Locations = nil
+	}
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetJobHandler creates a handler function that returns the status,
+// progress and result of a background job.
+func (a *App) GetJobHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetJobHandler started")
+
+		jobID := chi.URLParam(r, "id")
+		if !IsValidUUID(jobID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "url parameter must be a valid v4 UUID") // 400
+			return
+		}
+
+		job, err := a.Service.GetJob(ctx, jobID)
+		if err == service.ErrJobNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeJobNotFound, "job not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetJob(ctx, jobID=%q) failed: %+v", jobID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&job)
+	}
+}
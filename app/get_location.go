@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetLocationHandler creates a handler function that returns a
+// stock location by id.
+func (a *App) GetLocationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetLocationHandler called")
+
+		locationID := chi.URLParam(r, "id")
+		if !IsValidUUID(locationID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+		location, err := a.Service.GetLocation(ctx, locationID)
+		if err == service.ErrLocationNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeLocationNotFound,
+				"location not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetLocation(ctx, locationID=%q) failed: %+v", locationID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(&location)
+	}
+}
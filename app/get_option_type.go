@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetOptionTypeHandler creates a handler function that returns an
+// option type by id.
+func (a *App) GetOptionTypeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetOptionTypeHandler called")
+
+		optionTypeID := chi.URLParam(r, "id")
+		if !IsValidUUID(optionTypeID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+		optionType, err := a.Service.GetOptionType(ctx, optionTypeID)
+		if err == service.ErrOptionTypeNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeOptionTypeNotFound,
+				"option type not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetOptionType(ctx, optionTypeID=%q) failed: %+v", optionTypeID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(&optionType)
+	}
+}
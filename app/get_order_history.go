@@ -0,0 +1,51 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetOrderHistoryHandler creates a handler function that returns the
+// status changes of an order oldest first.
+func (a *App) GetOrderHistoryHandler() http.HandlerFunc {
+	type getOrderHistoryResponse struct {
+		Object string                       `json:"object"`
+		Data   []*service.OrderStatusChange `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetOrderHistoryHandler started")
+
+		orderID := chi.URLParam(r, "id")
+		if !IsValidUUID(orderID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		history, err := a.Service.GetOrderStatusHistory(ctx, orderID)
+		if err == service.ErrOrderNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeOrderNotFound,
+				"order not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetOrderStatusHistory(ctx, orderID=%q) error: %+v", orderID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := getOrderHistoryResponse{
+			Object: "list",
+			Data:   history,
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
@@ -60,7 +60,7 @@ func (a *App) GetProductHandler() http.HandlerFunc {
 
 		productID := chi.URLParam(r, "id")
 		include := r.URL.Query().Get("include")
-		unaccepted, includeList, err := parseIncludeQueryParam(include, []string{"images", "prices"})
+		unaccepted, includeList, err := parseIncludeQueryParam(include, []string{"images", "prices", "variants"})
 		if err == ErrIncludeQueryParamParseError {
 			clientError(w, http.StatusBadRequest, ErrCodeIncludeQueryParamParseError,
 				"include query parameter not valid") // 404
@@ -88,9 +88,15 @@ func (a *App) GetProductHandler() http.HandlerFunc {
 		if sliceContains(includeList, "prices") {
 			includePrices = true
 		}
+		var includeVariants bool
+		if sliceContains(includeList, "variants") {
+			includeVariants = true
+		}
 
 		userID := ctx.Value(ecomUIDKey).(string)
-		product, err := a.Service.GetProduct(ctx, userID, productID, includeImages, includePrices)
+		// shoppers only see published products.
+		publishedOnly := !hasAdminRole(ctx)
+		product, err := a.Service.GetProduct(ctx, userID, productID, includeImages, includePrices, includeVariants, publishedOnly)
 		if err == service.ErrProductNotFound {
 			clientError(w, http.StatusNotFound, ErrCodeProductNotFound, "product not found")
 			return
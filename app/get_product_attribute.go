@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetProductAttributeHandler creates a handler function that returns an
+// product attribute by id.
+func (a *App) GetProductAttributeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetProductAttributeHandler called")
+
+		productAttributeID := chi.URLParam(r, "id")
+		if !IsValidUUID(productAttributeID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+		productAttribute, err := a.Service.GetProductAttribute(ctx, productAttributeID)
+		if err == service.ErrProductAttributeNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeProductAttributeNotFound,
+				"product attribute not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetProductAttribute(ctx, productAttributeID=%q) failed: %+v", productAttributeID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(&productAttribute)
+	}
+}
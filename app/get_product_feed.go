@@ -0,0 +1,52 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetProductFeedHandler creates a handler function that returns the
+// Google Merchant product feed for the storefront at storefrontURL with
+// prices from the price list with the given priceListID or the default
+// price list if empty. The feed is public so callers cannot choose the
+// price list.
+func (a *App) GetProductFeedHandler(storefrontURL, priceListID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetProductFeedHandler started")
+
+		feed, err := a.Service.GetProductFeed(ctx, storefrontURL, priceListID)
+		if err == service.ErrPriceListNotFound {
+			clientError(w, http.StatusNotFound, ErrCodePriceListNotFound, "price list not found") // 404
+			return
+		}
+		if err == service.ErrDefaultPriceListNotFound {
+			clientError(w, http.StatusNotFound, ErrCodePriceListNotFound,
+				"default price list not found") // 404
+			return
+		}
+		if err == service.ErrTaxRateNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeTaxRateNotFound,
+				"tax rate not found for one or more product tax codes") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetProductFeed(ctx, baseURL=%q, priceListID=%q) failed: %+v", storefrontURL, priceListID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		var b bytes.Buffer
+		if err := feed.Write(&b); err != nil {
+			contextLogger.Errorf("app: feed.Write(w) failed: %+v", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.WriteHeader(http.StatusOK) // 200 OK
+		b.WriteTo(w)
+	}
+}
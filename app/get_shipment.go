@@ -0,0 +1,42 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetShipmentHandler returns a http.HandlerFunc that returns a shipment
+// by object id.
+func (a *App) GetShipmentHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetShipmentHandler called")
+
+		shipmentID := chi.URLParam(r, "id")
+		if !IsValidUUID(shipmentID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		shipment, err := a.Service.GetShipment(ctx, shipmentID)
+		if err == service.ErrShipmentNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeShipmentNotFound,
+				"shipment not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetShipment(ctx, shipmentID=%q): %+v",
+				shipmentID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(&shipment)
+	}
+}
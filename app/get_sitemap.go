@@ -0,0 +1,74 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetSitemapHandler creates a handler function that returns sitemap.xml
+// for the storefront at storefrontURL. Sitemaps of more than 50,000 URLs
+// are returned as a sitemap index of chunks.
+func (a *App) GetSitemapHandler(storefrontURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetSitemapHandler started")
+
+		sitemap, err := a.Service.GetSitemap(ctx, storefrontURL)
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetSitemap(ctx, baseURL=%q) failed: %+v", storefrontURL, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		var b bytes.Buffer
+		if err := sitemap.Write(&b); err != nil {
+			contextLogger.Errorf("app: sitemap.Write(w) failed: %+v", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusOK) // 200 OK
+		b.WriteTo(w)
+	}
+}
+
+// GetSitemapChunkHandler creates a handler function that returns a chunk
+// of the sitemap listed by the sitemap index.
+func (a *App) GetSitemapChunkHandler(storefrontURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetSitemapChunkHandler started")
+
+		n, err := strconv.Atoi(chi.URLParam(r, "n"))
+		if err != nil {
+			clientError(w, http.StatusNotFound, ErrCodeSitemapNotFound, "sitemap not found") // 404
+			return
+		}
+		sitemap, err := a.Service.GetSitemap(ctx, storefrontURL)
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetSitemap(ctx, baseURL=%q) failed: %+v", storefrontURL, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		var b bytes.Buffer
+		err = sitemap.WriteChunk(&b, n)
+		if err == service.ErrSitemapChunkNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeSitemapNotFound, "sitemap not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: sitemap.WriteChunk(w, n=%d) failed: %+v", n, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusOK) // 200 OK
+		b.WriteTo(w)
+	}
+}
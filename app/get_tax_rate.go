@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetTaxRateHandler creates a handler function that returns a
+// tax rate by id.
+func (a *App) GetTaxRateHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: GetTaxRateHandler called")
+
+		taxRateID := chi.URLParam(r, "id")
+		if !IsValidUUID(taxRateID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+		taxRate, err := a.Service.GetTaxRate(ctx, taxRateID)
+		if err == service.ErrTaxRateNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeTaxRateNotFound,
+				"tax rate not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetTaxRate(ctx, taxRateID=%q) failed: %+v", taxRateID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(&taxRate)
+	}
+}
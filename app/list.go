@@ -0,0 +1,73 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+)
+
+const maxListLimit = 250
+
+// listOptionsFromQuery builds the list options from the query parameters
+// limit, start_after, end_before, order_by and order_dir. If limit is not
+// given the limit is zero and every item is returned as before lists were
+// paginated. For backwards compatibility an order_by prefixed with a
+// minus sign sorts in descending order.
+func listOptionsFromQuery(v url.Values) (*service.ListOptions, error) {
+	opts := service.ListOptions{
+		StartAfter: v.Get("start_after"),
+		EndBefore:  v.Get("end_before"),
+		OrderBy:    v.Get("order_by"),
+		OrderDir:   strings.ToLower(v.Get("order_dir")),
+	}
+
+	if v.Get("limit") != "" {
+		limit, err := strconv.Atoi(v.Get("limit"))
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return nil, fmt.Errorf("query parameter limit must be an integer between 1 and %d", maxListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	if opts.StartAfter != "" && opts.EndBefore != "" {
+		return nil, errors.New("query parameters start_after and end_before cannot be used together")
+	}
+	if opts.StartAfter != "" && !IsValidUUID(opts.StartAfter) {
+		return nil, errors.New("query parameter start_after must be a valid v4 uuid")
+	}
+	if opts.EndBefore != "" && !IsValidUUID(opts.EndBefore) {
+		return nil, errors.New("query parameter end_before must be a valid v4 uuid")
+	}
+
+	if strings.HasPrefix(opts.OrderBy, "-") {
+		opts.OrderBy = opts.OrderBy[1:]
+		if opts.OrderDir == "" {
+			opts.OrderDir = "desc"
+		}
+	}
+	if opts.OrderDir != "" && opts.OrderDir != "asc" && opts.OrderDir != "desc" {
+		return nil, errors.New("query parameter order_dir must be asc or desc")
+	}
+	return &opts, nil
+}
+
+// listClientError writes a 400 Bad Request response and returns true if
+// err is caused by the list options of the request.
+func listClientError(w http.ResponseWriter, opts *service.ListOptions, err error) bool {
+	if err == service.ErrOrderByInvalid {
+		clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+			fmt.Sprintf("query parameter order_by %q is not a sortable field", opts.OrderBy)) // 400
+		return true
+	}
+	if err == service.ErrCursorNotFound {
+		clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+			"query parameter start_after or end_before not found in list") // 400
+		return true
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListBackordersHandler creates a handler function that returns the
+// outstanding backordered quantity of each product ordered by SKU.
+func (a *App) ListBackordersHandler() http.HandlerFunc {
+	type listBackordersResponse struct {
+		Object     string               `json:"object"`
+		Data       []*service.Backorder `json:"data"`
+		Pagination *service.Pagination  `json:"pagination"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ListBackordersHandler called")
+
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		backorders, pagination, err := a.Service.GetBackorders(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetBackorders(ctx) failed: %+v", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := listBackordersResponse{
+			Object:     "list",
+			Data:       backorders,
+			Pagination: pagination,
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
@@ -9,18 +9,28 @@ import (
 )
 
 // ListCouponsHandler returns a http.HandlerFunc that returns
-// a list of coupons.
+// a page of coupons.
 func (a *App) ListCouponsHandler() http.HandlerFunc {
 	type response struct {
-		Object string            `json:"object"`
-		Data   []*service.Coupon `json:"data"`
+		Object     string              `json:"object"`
+		Data       []*service.Coupon   `json:"data"`
+		Pagination *service.Pagination `json:"pagination"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListCouponsHandler called")
 
-		coupons, err := a.Service.GetCoupons(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		coupons, pagination, err := a.Service.GetCoupons(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: 500 Internal Server Error - a.Service.GetAllInventory(ctx): %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
@@ -28,8 +38,9 @@ func (a *App) ListCouponsHandler() http.HandlerFunc {
 		}
 
 		list := response{
-			Object: "list",
-			Data:   coupons,
+			Object:     "list",
+			Data:       coupons,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200
 		json.NewEncoder(w).Encode(&list)
@@ -9,12 +9,14 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// ListInventoryHandler returns a http.HandlerFunc that returns the inventory
-// for a list of products.
+// ListInventoryHandler returns a http.HandlerFunc that returns a page of
+// inventory. The location query parameter returns the onhand at a single
+// location and is only available to admins.
 func (a *App) ListInventoryHandler() http.HandlerFunc {
 	type response struct {
-		Object string               `json:"object"`
-		Data   []*service.Inventory `json:"data"`
+		Object     string               `json:"object"`
+		Data       []*service.Inventory `json:"data"`
+		Pagination *service.Pagination  `json:"pagination"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -35,25 +37,49 @@ func (a *App) ListInventoryHandler() http.HandlerFunc {
 				return
 			}
 
+			hideInventoryLocations(ctx, inventory)
 			w.WriteHeader(http.StatusOK) // 200
 			json.NewEncoder(w).Encode(&inventory)
 			return
 		}
 
-		inventoryList, err := a.Service.GetAllInventory(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		var location *string
+		if l := r.URL.Query().Get("location"); l != "" {
+			if !hasAdminRole(ctx) {
+				clientError(w, http.StatusForbidden, ErrCodeInventoryLocationForbidden,
+					"location query parameter requires admin privileges") // 403
+				return
+			}
+			location = &l
+		}
+		inventoryList, pagination, err := a.Service.GetAllInventory(ctx, opts, location)
 		if err == postgres.ErrInventoryNotFound {
 			clientError(w, http.StatusNotFound, ErrCodeInventoryNotFound, "inventory not found") // 404
 			return
 		}
+		if err == service.ErrLocationNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeLocationNotFound, "location not found") // 404
+			return
+		}
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
-			contextLogger.Errorf("app: a.Service.GetAllInventory(ctx) failed: %+v", err)
+			contextLogger.Errorf("app: a.Service.GetAllInventory(ctx, location=%v) failed: %+v", location, err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
 			return
 		}
 
+		hideInventoryLocations(ctx, inventoryList...)
 		list := response{
-			Object: "list",
-			Data:   inventoryList,
+			Object:     "list",
+			Data:       inventoryList,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200 OK
 		json.NewEncoder(w).Encode(&list)
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultInventoryMovementsLimit = 100
+
+// ListInventoryMovementsHandler creates a handler function that returns
+// a page of the movements of inventory newest first. Pages hold 100
+// movements unless limit is given.
+func (a *App) ListInventoryMovementsHandler() http.HandlerFunc {
+	type links struct {
+		Next string `json:"next,omitempty"`
+	}
+
+	type listInventoryMovementsResponse struct {
+		Object     string                       `json:"object"`
+		Data       []*service.InventoryMovement `json:"data"`
+		Links      links                        `json:"links"`
+		Pagination *service.Pagination          `json:"pagination"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ListInventoryMovementsHandler started")
+
+		inventoryID := chi.URLParam(r, "id")
+		if !IsValidUUID(inventoryID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		if opts.Limit == 0 {
+			opts.Limit = defaultInventoryMovementsLimit
+		}
+
+		movements, pagination, err := a.Service.GetInventoryMovements(ctx, inventoryID, opts)
+		if err == service.ErrInventoryNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeInventoryNotFound,
+				"inventory not found") // 404
+			return
+		}
+		if err == service.ErrInventoryMovementNotFound {
+			clientError(w, http.StatusBadRequest, ErrCodeInventoryMovementNotFound,
+				"start_after or end_before inventory movement not found") // 400
+			return
+		}
+		if listClientError(w, opts, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetInventoryMovements(ctx, inventoryID=%q, ...) error: %+v", inventoryID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := listInventoryMovementsResponse{
+			Object:     "list",
+			Data:       movements,
+			Pagination: pagination,
+		}
+		if pagination.HasNext {
+			list.Links.Next = fmt.Sprintf("/inventory/%s/movements?limit=%d&start_after=%s",
+				inventoryID, opts.Limit, pagination.LastID)
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListLocationsHandler creates a handler function that returns a
+// page of stock locations in priority order.
+func (a *App) ListLocationsHandler() http.HandlerFunc {
+	type listLocationsResponse struct {
+		Object     string              `json:"object"`
+		Data       []*service.Location `json:"data"`
+		Pagination *service.Pagination `json:"pagination"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ListLocationsHandler called")
+
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		locations, pagination, err := a.Service.GetLocations(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetLocations(ctx) failed: %+v", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := listLocationsResponse{
+			Object:     "list",
+			Data:       locations,
+			Pagination: pagination,
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
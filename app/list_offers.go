@@ -9,11 +9,12 @@ import (
 )
 
 // ListOffersHandler creates a handler function that returns a
-// list of active offers.
+// page of active offers.
 func (a *App) ListOffersHandler() http.HandlerFunc {
 	type response struct {
-		Object string           `json:"object"`
-		Data   []*service.Offer `json:"data"`
+		Object     string              `json:"object"`
+		Data       []*service.Offer    `json:"data"`
+		Pagination *service.Pagination `json:"pagination"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -21,15 +22,25 @@ func (a *App) ListOffersHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListOffersHandler started")
 
-		offers, err := a.Service.GetOffers(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		offers, pagination, err := a.Service.GetOffers(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: a.Service.GetOffers(ctx) failed: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
 			return
 		}
 		list := response{
-			Object: "list",
-			Data:   offers,
+			Object:     "list",
+			Data:       offers,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200
 		json.NewEncoder(w).Encode(&list)
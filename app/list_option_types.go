@@ -0,0 +1,49 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListOptionTypesHandler creates a handler function that returns a
+// page of option types.
+func (a *App) ListOptionTypesHandler() http.HandlerFunc {
+	type listOptionTypesResponse struct {
+		Object     string                `json:"object"`
+		Data       []*service.OptionType `json:"data"`
+		Pagination *service.Pagination   `json:"pagination"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ListOptionTypesHandler called")
+
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		optionTypes, pagination, err := a.Service.GetOptionTypes(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetOptionTypes(ctx) failed: %+v", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := listOptionTypesResponse{
+			Object:     "list",
+			Data:       optionTypes,
+			Pagination: pagination,
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
@@ -8,12 +8,13 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// ListOrdersHandler creates a handler function that returns a
-// list of orders.
+// ListOrdersHandler creates a handler function that returns a page of
+// orders optionally filtered by status.
 func (a *App) ListOrdersHandler() http.HandlerFunc {
 	type response struct {
-		Object string           `json:"object"`
-		Data   []*service.Order `json:"data"`
+		Object     string              `json:"object"`
+		Data       []*service.Order    `json:"data"`
+		Pagination *service.Pagination `json:"pagination"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -21,7 +22,22 @@ func (a *App) ListOrdersHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListOrdersHandler called")
 
-		orders, err := a.Service.GetOrders(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		status := r.URL.Query().Get("status")
+		if status != "" && !service.IsValidOrderStatus(status) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"query parameter status is not a valid order status") // 400
+			return
+		}
+
+		orders, pagination, err := a.Service.GetOrders(ctx, opts, status)
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: a.Service.GetOrders(ctx) failed: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
@@ -31,8 +47,9 @@ func (a *App) ListOrdersHandler() http.HandlerFunc {
 			len(orders))
 
 		list := response{
-			Object: "list",
-			Data:   orders,
+			Object:     "list",
+			Data:       orders,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200
 		json.NewEncoder(w).Encode(&list)
@@ -9,11 +9,12 @@ import (
 )
 
 // ListPPAssocGroupsHandler creates a handler function that returns a
-// list of product to product association groups.
+// page of product to product association groups.
 func (a *App) ListPPAssocGroupsHandler() http.HandlerFunc {
 	type listPromoRulesResponse struct {
-		Object string                  `json:"object"`
-		Data   []*service.PPAssocGroup `json:"data"`
+		Object     string                  `json:"object"`
+		Data       []*service.PPAssocGroup `json:"data"`
+		Pagination *service.Pagination     `json:"pagination"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -21,7 +22,16 @@ func (a *App) ListPPAssocGroupsHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListPPAssocGroupsHandler started")
 
-		ppAssocGroups, err := a.Service.GetPPAssocGroups(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		ppAssocGroups, pagination, err := a.Service.GetPPAssocGroups(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: a.Service.GetPPAssocGroups(ctx) error: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500 Internal Server Error
@@ -29,8 +39,9 @@ func (a *App) ListPPAssocGroupsHandler() http.HandlerFunc {
 		}
 
 		list := listPromoRulesResponse{
-			Object: "list",
-			Data:   ppAssocGroups,
+			Object:     "list",
+			Data:       ppAssocGroups,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200 OK
 		json.NewEncoder(w).Encode(&list)
@@ -9,11 +9,12 @@ import (
 )
 
 // ListPriceListsHandler creates a handler function that returns a
-// list of price lists.
+// page of price lists.
 func (a *App) ListPriceListsHandler() http.HandlerFunc {
 	type listPriceListsResponse struct {
-		Object string               `json:"object"`
-		Data   []*service.PriceList `json:"data"`
+		Object     string               `json:"object"`
+		Data       []*service.PriceList `json:"data"`
+		Pagination *service.Pagination  `json:"pagination"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -21,7 +22,16 @@ func (a *App) ListPriceListsHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListPriceListsHandler started")
 
-		priceLists, err := a.Service.GetPriceLists(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		priceLists, pagination, err := a.Service.GetPriceLists(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: GetPricingLists(ctx) error: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500 Internal Server Error
@@ -29,8 +39,9 @@ func (a *App) ListPriceListsHandler() http.HandlerFunc {
 		}
 
 		list := listPriceListsResponse{
-			Object: "list",
-			Data:   priceLists,
+			Object:     "list",
+			Data:       priceLists,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200 OK
 		json.NewEncoder(w).Encode(&list)
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListProductAttributesHandler creates a handler function that returns a
+// page of product attributes.
+func (a *App) ListProductAttributesHandler() http.HandlerFunc {
+	type listProductAttributesResponse struct {
+		Object     string                      `json:"object"`
+		Data       []*service.ProductAttribute `json:"data"`
+		Pagination *service.Pagination         `json:"pagination"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ListProductAttributesHandler called")
+
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		productAttributes, pagination, err := a.Service.GetProductAttributes(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetProductAttributes(ctx) failed: %+v", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := listProductAttributesResponse{
+			Object:     "list",
+			Data:       productAttributes,
+			Pagination: pagination,
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
@@ -8,11 +8,13 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// ListProductsHandler creates a handler that returns a list of products.
+// ListProductsHandler creates a handler that returns a page of products
+// optionally filtered by status.
 func (a *App) ListProductsHandler() http.HandlerFunc {
 	type itemsListResponseBody struct {
-		Object string             `json:"object"`
-		Data   []*service.Product `json:"data"`
+		Object     string              `json:"object"`
+		Data       []*service.Product  `json:"data"`
+		Pagination *service.Pagination `json:"pagination"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -20,15 +22,32 @@ func (a *App) ListProductsHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListProductsHandler started")
 
-		products, err := a.Service.ListProducts(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		status := r.URL.Query().Get("status")
+		if status != "" && !service.IsValidProductStatus(status) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"query parameter status must be one of draft, active or archived") // 400
+			return
+		}
+
+		// shoppers only see published products.
+		products, pagination, err := a.Service.ListProducts(ctx, opts, status, !hasAdminRole(ctx))
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: ListProducts(ctx) error: %v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500 Internal Server Error
 			return
 		}
 		res := itemsListResponseBody{
-			Object: "list",
-			Data:   products,
+			Object:     "list",
+			Data:       products,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200 OK
 		json.NewEncoder(w).Encode(&res)
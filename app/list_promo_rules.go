@@ -9,11 +9,12 @@ import (
 )
 
 // ListPromoRulesHandler creates a handler function that returns a
-// list of promotion rules.
+// page of promotion rules.
 func (a *App) ListPromoRulesHandler() http.HandlerFunc {
 	type listPromoRulesResponse struct {
-		Object string               `json:"object"`
-		Data   []*service.PromoRule `json:"data"`
+		Object     string               `json:"object"`
+		Data       []*service.PromoRule `json:"data"`
+		Pagination *service.Pagination  `json:"pagination"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -21,7 +22,16 @@ func (a *App) ListPromoRulesHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListPromoRulesHandler started")
 
-		promoRules, err := a.Service.GetPromoRules(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		promoRules, pagination, err := a.Service.GetPromoRules(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: GetPromoRules(ctx) error: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
@@ -29,8 +39,9 @@ func (a *App) ListPromoRulesHandler() http.HandlerFunc {
 		}
 
 		list := listPromoRulesResponse{
-			Object: "list",
-			Data:   promoRules,
+			Object:     "list",
+			Data:       promoRules,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200
 		json.NewEncoder(w).Encode(&list)
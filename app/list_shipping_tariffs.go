@@ -9,11 +9,12 @@ import (
 )
 
 // ListShippingTariffsHandler creates a handler function that returns a
-// list of shipping tariffs.
+// page of shipping tariffs.
 func (a *App) ListShippingTariffsHandler() http.HandlerFunc {
 	type listPromoRulesResponse struct {
-		Object string                    `json:"object"`
-		Data   []*service.ShippingTariff `json:"data"`
+		Object     string                    `json:"object"`
+		Data       []*service.ShippingTariff `json:"data"`
+		Pagination *service.Pagination       `json:"pagination"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -21,7 +22,16 @@ func (a *App) ListShippingTariffsHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListShippingTariffsHandler started")
 
-		shippingTariffs, err := a.Service.GetShippingTariffs(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		shippingTariffs, pagination, err := a.Service.GetShippingTariffs(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: a.Service.GetShippingTariffs(ctx) error: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
@@ -29,8 +39,9 @@ func (a *App) ListShippingTariffsHandler() http.HandlerFunc {
 		}
 
 		list := listPromoRulesResponse{
-			Object: "list",
-			Data:   shippingTariffs,
+			Object:     "list",
+			Data:       shippingTariffs,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200 OK
 		json.NewEncoder(w).Encode(&list)
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListTaxRatesHandler creates a handler function that returns a
+// page of tax rates.
+func (a *App) ListTaxRatesHandler() http.HandlerFunc {
+	type listTaxRatesResponse struct {
+		Object     string              `json:"object"`
+		Data       []*service.TaxRate  `json:"data"`
+		Pagination *service.Pagination `json:"pagination"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ListTaxRatesHandler started")
+
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		taxRates, pagination, err := a.Service.GetTaxRates(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetTaxRates(ctx) error: %+v", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := listTaxRatesResponse{
+			Object:     "list",
+			Data:       taxRates,
+			Pagination: pagination,
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListVariantsHandler creates a handler function that returns a
+// list of the variants of a product.
+func (a *App) ListVariantsHandler() http.HandlerFunc {
+	type listVariantsResponse struct {
+		Object string             `json:"object"`
+		Data   []*service.Product `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ListVariantsHandler called")
+
+		productID := chi.URLParam(r, "id")
+		if !IsValidUUID(productID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+		// shoppers only see published variants of published products.
+		variants, err := a.Service.GetVariants(ctx, productID, !hasAdminRole(ctx))
+		if err == service.ErrProductNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeProductNotFound, "product not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.GetVariants(ctx, productID=%q) failed: %+v", productID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := listVariantsResponse{
+			Object: "list",
+			Data:   variants,
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
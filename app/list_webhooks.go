@@ -9,18 +9,28 @@ import (
 )
 
 // ListWebhooksHandler returns a http.HandlerFunc that returns
-// a list of all webhooks.
+// a page of webhooks.
 func (a *App) ListWebhooksHandler() http.HandlerFunc {
 	type response struct {
-		Object string             `json:"object"`
-		Data   []*service.Webhook `json:"data"`
+		Object     string              `json:"object"`
+		Data       []*service.Webhook  `json:"data"`
+		Pagination *service.Pagination `json:"pagination"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: ListWebhooksHandler called")
 
-		webhooks, err := a.Service.GetWebhooks(ctx)
+		opts, err := listOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		webhooks, pagination, err := a.Service.GetWebhooks(ctx, opts)
+		if listClientError(w, opts, err) {
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: a.Service.GetWebhooks(ctx) failed: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -28,8 +38,9 @@ func (a *App) ListWebhooksHandler() http.HandlerFunc {
 		}
 
 		list := response{
-			Object: "list",
-			Data:   webhooks,
+			Object:     "list",
+			Data:       webhooks,
+			Pagination: pagination,
 		}
 		w.WriteHeader(http.StatusOK) // 200 OK
 		json.NewEncoder(w).Encode(&list)
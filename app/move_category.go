@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+type moveCategoryRequestBody struct {
+	ParentID *string `json:"parent_id"`
+	Position *int    `json:"position"`
+}
+
+func validateMoveCategoryRequest(request *moveCategoryRequestBody) (bool, string) {
+	if request.ParentID == nil {
+		return false, "attribute parent_id must be set"
+	}
+	if !IsValidUUID(*request.ParentID) {
+		return false, "attribute parent_id must be a valid v4 uuid"
+	}
+	if request.Position != nil && *request.Position < 0 {
+		return false, "attribute position must not be negative"
+	}
+	return true, ""
+}
+
+// MoveCategoryHandler creates a handler function that moves a category
+// and its descendants to a new parent and position. Products and promo
+// rules stay with the moved categories.
+func (a *App) MoveCategoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: MoveCategoryHandler started")
+
+		categoryID := chi.URLParam(r, "id")
+		if !IsValidUUID(categoryID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "url parameter must be a valid v4 UUID") // 400
+			return
+		}
+
+		request := moveCategoryRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		valid, message := validateMoveCategoryRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, message) // 400
+			return
+		}
+
+		category, err := a.Service.MoveCategory(ctx, categoryID, *request.ParentID, request.Position)
+		if categoryClientError(w, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.MoveCategory(ctx, categoryID=%q, parentID=%q, ...) failed: %+v", categoryID, *request.ParentID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&category)
+	}
+}
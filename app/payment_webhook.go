@@ -0,0 +1,45 @@
+package app
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// PaymentWebhookHandler returns a handler that processes webhook calls
+// from the payment provider.
+func (a *App) PaymentWebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: PaymentWebhookHandler started")
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			contextLogger.Errorf("app: failed to read request body: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		err = a.Service.ProcessPaymentWebhook(ctx, body, r.Header)
+		if err == service.ErrWebhookSignatureInvalid {
+			contextLogger.Errorf("app: failed to verify webhook signature: %v", err)
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"webhook signature invalid") // 400
+			return
+		}
+		if err == service.ErrOrderNotFound {
+			contextLogger.Warnf("app: payment webhook for unknown order")
+			w.WriteHeader(http.StatusNoContent) // 204 No Content
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.ProcessPaymentWebhook(ctx, ...) failed: %+v", err)
+			w.WriteHeader(http.StatusInternalServerError) // 500 Internal Server Error
+			return
+		}
+		w.WriteHeader(http.StatusNoContent) // 204 No Content
+	}
+}
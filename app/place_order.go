@@ -2,21 +2,24 @@ package app
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 
 	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
 	log "github.com/sirupsen/logrus"
 )
 
 type orderRequestBody struct {
-	CartID      *string                         `json:"cart_id"`
-	ContactName *string                         `json:"contact_name"`
-	Email       *string                         `json:"email"`
-	UserID      *string                         `json:"user_id"`
-	BillingID   *string                         `json:"billing_id"`
-	ShippingID  *string                         `json:"shipping_id"`
-	Billing     *service.NewOrderAddressRequest `json:"billing"`
-	Shipping    *service.NewOrderAddressRequest `json:"shipping"`
+	CartID           *string                         `json:"cart_id"`
+	ContactName      *string                         `json:"contact_name"`
+	Email            *string                         `json:"email"`
+	UserID           *string                         `json:"user_id"`
+	BillingID        *string                         `json:"billing_id"`
+	ShippingID       *string                         `json:"shipping_id"`
+	Billing          *service.NewOrderAddressRequest `json:"billing"`
+	Shipping         *service.NewOrderAddressRequest `json:"shipping"`
+	ShippingTariffID *string                         `json:"shipping_tariff_id"`
 }
 
 // PlaceOrderHandler returns an HTTP handler that places a new order.
@@ -51,16 +54,17 @@ func (a *App) PlaceOrderHandler() http.HandlerFunc {
 		var order *service.Order
 		if req.UserID == nil {
 			order, err = a.Service.PlaceGuestOrder(ctx, *req.CartID, *req.ContactName, *req.Email,
-				req.Billing, req.Shipping)
+				req.Billing, req.Shipping, req.ShippingTariffID)
 		} else {
 			order, err = a.Service.PlaceOrder(ctx, *req.CartID,
-				*req.UserID, *req.BillingID, *req.ShippingID)
+				*req.UserID, *req.BillingID, *req.ShippingID, req.ShippingTariffID)
 		}
 
 		if err == service.ErrCartNotFound {
 			contextLogger.Warn("app: 404 Not Found - cart not found")
 			clientError(w, http.StatusNotFound, ErrCodeCartNotFound,
 				"cart not found") // 404
+			return
 		}
 		if err == service.ErrCartEmpty {
 			contextLogger.Warn("app: 409 Conflict - cart is empty")
@@ -68,6 +72,12 @@ func (a *App) PlaceOrderHandler() http.HandlerFunc {
 				"The cart id you passed contains no items") // 404
 			return
 		}
+		if e, ok := err.(*service.InsufficientStockError); ok {
+			contextLogger.Warnf("app: 409 Conflict - insufficient stock for skus %v", e.SKUs)
+			clientError(w, http.StatusConflict, ErrCodeInsufficientStock,
+				fmt.Sprintf("insufficient stock for skus %s", strings.Join(e.SKUs, ", "))) // 409
+			return
+		}
 		if err == service.ErrUserNotFound {
 			contextLogger.Warn("app: 404 Not Found - user not found")
 			clientError(w, http.StatusNotFound, ErrCodeOrderUserNotFound,
@@ -80,6 +90,30 @@ func (a *App) PlaceOrderHandler() http.HandlerFunc {
 				"billing or shipping address not found")
 			return
 		}
+		if err == service.ErrShippingTariffNotFound {
+			contextLogger.Warn("app: 404 Not Found - shipping tariff not found")
+			clientError(w, http.StatusNotFound, ErrCodeShippingTariffNotFound,
+				"shipping tariff not found") // 404
+			return
+		}
+		if err == service.ErrCouponUsed {
+			contextLogger.Warn("app: 409 Conflict - coupon used")
+			clientError(w, http.StatusConflict, ErrCodeCouponUsed,
+				"a coupon applied to the cart has already been used") // 409
+			return
+		}
+		if err == service.ErrTaxRateNotFound {
+			contextLogger.Warn("app: 404 Not Found - tax rate not found")
+			clientError(w, http.StatusNotFound, ErrCodeTaxRateNotFound,
+				"no tax rate found for the shipping address") // 404
+			return
+		}
+		if err == service.ErrCartProductUnpublished {
+			contextLogger.Warn("app: 409 Conflict - cart product unpublished")
+			clientError(w, http.StatusConflict, ErrCodeCartProductUnpublished,
+				"the cart contains a product that is no longer available") // 409
+			return
+		}
 		if err != nil {
 			contextLogger.Panicf("app: PlaceOrder(ctx, ...) failed with error: %v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
@@ -96,6 +130,11 @@ func validateOrderRequestBody(req *orderRequestBody) (string, bool) {
 		return "cart_id attribute missing", false
 	}
 
+	// shipping_tariff_id
+	if req.ShippingTariffID != nil && !IsValidUUID(*req.ShippingTariffID) {
+		return "shipping_tariff_id attribute must be a valid v4 uuid", false
+	}
+
 	// user_id
 	if req.UserID != nil {
 		//
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+type reorderCategoryProductsRequestBody struct {
+	ProductIDs []string `json:"product_ids"`
+}
+
+// ReorderCategoryProductsHandler creates a handler function that sets the
+// manual order of the products of a leaf category.
+func (a *App) ReorderCategoryProductsHandler() http.HandlerFunc {
+	type listResponse struct {
+		Object string                        `json:"object"`
+		Data   []*service.ProductsCategories `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ReorderCategoryProductsHandler started")
+
+		categoryID := chi.URLParam(r, "id")
+		if !IsValidUUID(categoryID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "url parameter must be a valid v4 UUID") // 400
+			return
+		}
+
+		request := reorderCategoryProductsRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		if request.ProductIDs == nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "attribute product_ids must be set") // 400
+			return
+		}
+		for _, id := range request.ProductIDs {
+			if !IsValidUUID(id) {
+				clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+					"attribute product_ids must contain valid v4 uuids") // 400
+				return
+			}
+		}
+
+		productsCategories, err := a.Service.ReorderCategoryProducts(ctx, categoryID, request.ProductIDs)
+		if err == service.ErrCategoryNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeCategoryNotFound, "category not found") // 404
+			return
+		}
+		if err == service.ErrCategoryNotLeaf {
+			clientError(w, http.StatusConflict, ErrCodeCategoryNotLeaf, "category is not a leaf") // 409
+			return
+		}
+		if err == service.ErrCategoryProductsMismatch {
+			clientError(w, http.StatusConflict, ErrCodeCategoryProductsMismatch,
+				"product_ids must contain each product of the category exactly once") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.ReorderCategoryProducts(ctx, categoryID=%q, ...) failed: %+v", categoryID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+
+		list := listResponse{
+			Object: "list",
+			Data:   productsCategories,
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&list)
+	}
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// ResolvePathHandler creates a handler function that returns the product
+// or category the path query parameter resolves to. Old paths of products
+// and categories resolve with a status of 301 and the current path.
+func (a *App) ResolvePathHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ResolvePathHandler started")
+
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "query parameter path must be set") // 400
+			return
+		}
+
+		// shoppers do not see hidden categories or unpublished products.
+		route, err := a.Service.ResolvePath(ctx, path, !hasAdminRole(ctx))
+		if err == service.ErrRouteNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeRouteNotFound, "route not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.ResolvePath(ctx, path=%q, ...) failed: %+v", path, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(route)
+	}
+}
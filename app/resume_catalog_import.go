@@ -0,0 +1,62 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+// ResumeCatalogImportHandler creates a handler function that applies the
+// remaining rows of a failed or interrupted catalog import. With the
+// async query parameter set to true the rows are applied by a
+// background job.
+func (a *App) ResumeCatalogImportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: ResumeCatalogImportHandler started")
+
+		catalogImportID := chi.URLParam(r, "id")
+		if !IsValidUUID(catalogImportID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "url parameter must be a valid v4 UUID") // 400
+			return
+		}
+		async, err := asyncFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		userID := ctx.Value(ecomUIDKey).(string)
+		var catalogImport *service.CatalogImport
+		var job *service.Job
+		if async {
+			job, err = a.Service.ResumeCatalogImportAsync(ctx, userID, catalogImportID)
+		} else {
+			catalogImport, err = a.Service.ResumeCatalogImport(ctx, userID, catalogImportID)
+		}
+		if err == service.ErrCatalogImportNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeCatalogImportNotFound, "catalog import not found") // 404
+			return
+		}
+		if err == service.ErrCatalogImportNotResumable {
+			clientError(w, http.StatusConflict, ErrCodeCatalogImportNotResumable, "catalog import is invalid or has completed") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.ResumeCatalogImport(ctx, userID=%q, catalogImportID=%q) failed: %+v", userID, catalogImportID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		if job != nil {
+			jobAccepted(w, job)
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&catalogImport)
+	}
+}
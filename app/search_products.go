@@ -0,0 +1,123 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSearchProductsLimit = 50
+	maxSearchProductsLimit     = 250
+)
+
+// parseSearchProductsQuery builds a product search request from the
+// query parameters q, category, price_min, price_max, in_stock, limit
+// and attribute. Each attribute query parameter holds a single attribute
+// filter in the form code:value.
+func parseSearchProductsQuery(v url.Values) (*service.ProductSearchRequest, error) {
+	req := service.ProductSearchRequest{
+		Query: strings.TrimSpace(v.Get("q")),
+		Limit: defaultSearchProductsLimit,
+	}
+	if len(req.Query) > 256 {
+		return nil, errors.New("query parameter q must be at most 256 characters")
+	}
+
+	if category := v.Get("category"); category != "" {
+		req.Category = &category
+	}
+
+	for _, name := range []string{"price_min", "price_max"} {
+		if v.Get(name) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v.Get(name))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("query parameter %s must be a non-negative integer", name)
+		}
+		if name == "price_min" {
+			req.MinPrice = &n
+		} else {
+			req.MaxPrice = &n
+		}
+	}
+	if req.MinPrice != nil && req.MaxPrice != nil && *req.MinPrice > *req.MaxPrice {
+		return nil, errors.New("query parameter price_min must not be greater than price_max")
+	}
+
+	if v.Get("in_stock") != "" {
+		inStock, err := strconv.ParseBool(v.Get("in_stock"))
+		if err != nil {
+			return nil, errors.New("query parameter in_stock must be true or false")
+		}
+		req.InStock = inStock
+	}
+
+	if v.Get("limit") != "" {
+		limit, err := strconv.Atoi(v.Get("limit"))
+		if err != nil || limit < 1 || limit > maxSearchProductsLimit {
+			return nil, fmt.Errorf("query parameter limit must be an integer between 1 and %d", maxSearchProductsLimit)
+		}
+		req.Limit = limit
+	}
+
+	for _, attr := range v["attribute"] {
+		kv := strings.SplitN(attr, ":", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, errors.New("query parameter attribute must be in the form code:value")
+		}
+		if req.Attributes == nil {
+			req.Attributes = make(map[string]string)
+		}
+		req.Attributes[kv[0]] = kv[1]
+	}
+	return &req, nil
+}
+
+// SearchProductsHandler creates a handler function that returns the
+// products matching a full-text search query and filters ordered by
+// relevance along with facet counts per category and attribute value.
+func (a *App) SearchProductsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: SearchProductsHandler started")
+
+		req, err := parseSearchProductsQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		// shoppers only see published products.
+		req.PublishedOnly = !hasAdminRole(ctx)
+
+		userID := ctx.Value(ecomUIDKey).(string)
+		result, err := a.Service.SearchProducts(ctx, userID, req)
+		if err == service.ErrCategoryNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeCategoryNotFound,
+				"category not found") // 404
+			return
+		}
+		if err == service.ErrDefaultPriceListNotFound {
+			clientError(w, http.StatusNotFound, ErrCodePriceListNotFound,
+				"default price list not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.SearchProducts(ctx, userID=%q, req=%+v) failed: %+v", userID, req, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(result)
+	}
+}
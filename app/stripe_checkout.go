@@ -8,7 +8,8 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// StripeCheckoutHandler returns a handler that returns a Stripe Checkout Session ID.
+// StripeCheckoutHandler returns a handler that starts a checkout with the
+// payment provider and returns the Checkout Session ID.
 func (a *App) StripeCheckoutHandler(stripeSuccessURL, stripeCancelURL string) http.HandlerFunc {
 	type stripeCheckoutResponseBody struct {
 		Object            string `json:"object"`
@@ -29,15 +30,15 @@ func (a *App) StripeCheckoutHandler(stripeSuccessURL, stripeCancelURL string) ht
 		}
 
 		contextLogger.Debugf("app: order id %s", orderID)
-		sid, err := a.Service.StripeCheckout(ctx, orderID, stripeSuccessURL, stripeCancelURL)
+		sid, err := a.Service.CreateCheckout(ctx, orderID, stripeSuccessURL, stripeCancelURL)
 		if err != nil {
-			contextLogger.Errorf("app: StripeCheckout(ctx, %q) error: %v",
+			contextLogger.Errorf("app: a.Service.CreateCheckout(ctx, %q) error: %v",
 				orderID, err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
 			return
 		}
 		res := stripeCheckoutResponseBody{
-			Object:            "stripe_checkout_session",
+			Object:            a.Service.PaymentProviderName() + "_checkout_session",
 			CheckoutSessionID: sid,
 		}
 		w.WriteHeader(http.StatusCreated) // 201
@@ -10,13 +10,20 @@ import (
 )
 
 // UpdateCategoriesTreeHandler creates an HTTP handler that updates all the categories
-// using a tree structure.
+// using a tree structure. With the async query parameter set to true the
+// categories are replaced by a background job.
 func (a *App) UpdateCategoriesTreeHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: UpdateCategoriesTreeHandler started")
 
+		async, err := asyncFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
 		catRequest := service.CategoryRequest{}
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
@@ -27,7 +34,13 @@ func (a *App) UpdateCategoriesTreeHandler() http.HandlerFunc {
 		}
 		defer r.Body.Close()
 
-		err := a.Service.UpdateCatalog(ctx, &catRequest)
+		var job *service.Job
+		if async {
+			userID := ctx.Value(ecomUIDKey).(string)
+			job, err = a.Service.UpdateCatalogAsync(ctx, userID, &catRequest)
+		} else {
+			err = a.Service.UpdateCatalog(ctx, &catRequest)
+		}
 		if err == service.ErrCategoriesInUse {
 			clientError(w, http.StatusConflict, ErrCodeCategoriesInUse,
 				"one or more categories in use - check promo rules") // 409
@@ -43,6 +56,10 @@ func (a *App) UpdateCategoriesTreeHandler() http.HandlerFunc {
 			w.WriteHeader(http.StatusInternalServerError) // 500
 			return
 		}
+		if job != nil {
+			jobAccepted(w, job)
+			return
+		}
 		w.Header().Del("Content-Type")
 		w.Header().Set("Content-Length", "0")
 		w.WriteHeader(http.StatusNoContent) // 204
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	log "github.com/sirupsen/logrus"
+)
+
+type updateCategoryRequestBody struct {
+	Segment         *string `json:"segment"`
+	Name            *string `json:"name"`
+	Description     *string `json:"description"`
+	HeroImage       *string `json:"hero_image"`
+	MetaTitle       *string `json:"meta_title"`
+	MetaDescription *string `json:"meta_description"`
+	Hidden          *bool   `json:"hidden"`
+	ProductSort     *string `json:"product_sort"`
+}
+
+func validateUpdateCategoryRequest(request *updateCategoryRequestBody) (bool, string) {
+	if *request == (updateCategoryRequestBody{}) {
+		return false, "at least one attribute must be set"
+	}
+	if request.Segment != nil {
+		if valid, message := validateCategorySegment(*request.Segment); !valid {
+			return false, message
+		}
+	}
+	if request.Name != nil && (len(*request.Name) < 1 || len(*request.Name) > 1024) {
+		return false, "attribute name must be between 1 and 1024 characters"
+	}
+	if request.HeroImage != nil && len(*request.HeroImage) > 1024 {
+		return false, "attribute hero_image must be at most 1024 characters"
+	}
+	if request.MetaTitle != nil && len(*request.MetaTitle) > 512 {
+		return false, "attribute meta_title must be at most 512 characters"
+	}
+	if request.MetaDescription != nil && len(*request.MetaDescription) > 1024 {
+		return false, "attribute meta_description must be at most 1024 characters"
+	}
+	if request.ProductSort != nil && !service.IsValidCategoryProductSort(*request.ProductSort) {
+		return false, "attribute product_sort must be one of manual, price_asc, price_desc, newest or bestselling"
+	}
+	return true, ""
+}
+
+// UpdateCategoryHandler creates a handler function that renames a
+// category and sets its metadata, visibility and product sort. Changing
+// the segment changes the path of the category and all of its
+// descendants.
+func (a *App) UpdateCategoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: UpdateCategoryHandler started")
+
+		categoryID := chi.URLParam(r, "id")
+		if !IsValidUUID(categoryID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, "url parameter must be a valid v4 UUID") // 400
+			return
+		}
+
+		request := updateCategoryRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		valid, message := validateUpdateCategoryRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, message) // 400
+			return
+		}
+
+		category, err := a.Service.UpdateCategory(ctx, categoryID, &service.CategoryUpdate{
+			Segment:         request.Segment,
+			Name:            request.Name,
+			Description:     request.Description,
+			HeroImage:       request.HeroImage,
+			MetaTitle:       request.MetaTitle,
+			MetaDescription: request.MetaDescription,
+			Hidden:          request.Hidden,
+			ProductSort:     request.ProductSort,
+		})
+		if categoryClientError(w, err) {
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.UpdateCategory(ctx, categoryID=%q, ...) failed: %+v", categoryID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&category)
+	}
+}
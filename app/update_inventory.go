@@ -3,6 +3,7 @@ package app
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
 	"github.com/go-chi/chi"
@@ -10,21 +11,50 @@ import (
 )
 
 type updateInventoryRequest struct {
-	Onhand      *int  `json:"onhand"`
-	Overselling *bool `json:"overselling"`
+	Location       *string `json:"location"`
+	Onhand         *int    `json:"onhand"`
+	Overselling    *bool   `json:"overselling"`
+	ReorderLevel   *int    `json:"reorder_level"`
+	Backorder      *string `json:"backorder"`
+	AvailableDate  *string `json:"available_date"`
+	BackorderLimit *int    `json:"backorder_limit"`
 }
 
 func validateUpdateInventoryRequest(request *updateInventoryRequest) (bool, string) {
 	// onhand and overselling pair
 	onhand := request.Onhand
 	overselling := request.Overselling
-	if onhand == nil && overselling == nil {
-		return false, "you must set at least one attribute onhand and/or overselling"
+	reorderLevel := request.ReorderLevel
+	backorder := request.Backorder
+	if onhand == nil && overselling == nil && reorderLevel == nil && backorder == nil {
+		return false, "you must set at least one attribute onhand, overselling, reorder_level and/or backorder"
 	}
 
 	if onhand != nil && *onhand < 0 {
 		return false, "attribute onhand must be an positive integer or zero"
 	}
+	if reorderLevel != nil && *reorderLevel < 0 {
+		return false, "attribute reorder_level must be an positive integer or zero"
+	}
+	if request.Location != nil && onhand == nil {
+		return false, "attribute location must be set with onhand"
+	}
+
+	// backorder, available_date and backorder_limit
+	if backorder != nil && *backorder != "none" && *backorder != "backorder" && *backorder != "preorder" {
+		return false, "attribute backorder must be one of none, backorder or preorder"
+	}
+	if backorder == nil && (request.AvailableDate != nil || request.BackorderLimit != nil) {
+		return false, "attributes available_date and backorder_limit must be set with backorder"
+	}
+	if request.AvailableDate != nil {
+		if _, err := time.Parse("2006-01-02", *request.AvailableDate); err != nil {
+			return false, "attribute available_date must be a date in the format YYYY-MM-DD"
+		}
+	}
+	if request.BackorderLimit != nil && *request.BackorderLimit < 0 {
+		return false, "attribute backorder_limit must be an positive integer or zero"
+	}
 	return true, ""
 }
 
@@ -38,7 +68,8 @@ func (a *App) UpdateInventoryHandler() http.HandlerFunc {
 
 		// parse request body
 		// example
-		// { "onhand": 4, "overselling": true }
+		// { "location": "default", "onhand": 4, "overselling": true, "reorder_level": 2 }
+		// { "backorder": "preorder", "available_date": "2020-03-01", "backorder_limit": 100 }
 		var request updateInventoryRequest
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
@@ -58,11 +89,28 @@ func (a *App) UpdateInventoryHandler() http.HandlerFunc {
 			return
 		}
 
-		inventory, err := a.Service.UpdateInventory(ctx, inventoryID, request.Onhand, request.Overselling)
+		var backorder *service.InventoryBackorderRequest
+		if request.Backorder != nil {
+			backorder = &service.InventoryBackorderRequest{
+				Backorder: *request.Backorder,
+				Limit:     request.BackorderLimit,
+			}
+			if request.AvailableDate != nil {
+				t, _ := time.Parse("2006-01-02", *request.AvailableDate)
+				backorder.AvailableDate = &t
+			}
+		}
+
+		userID := ctx.Value(ecomUIDKey).(string)
+		inventory, err := a.Service.UpdateInventory(ctx, userID, inventoryID, request.Location, request.Onhand, request.Overselling, request.ReorderLevel, backorder)
 		if err == service.ErrInventoryNotFound {
 			clientError(w, http.StatusNotFound, ErrCodeInventoryNotFound, "inventory not found") // 404
 			return
 		}
+		if err == service.ErrLocationNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeLocationNotFound, "location not found") // 404
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("app: a.Service.UpdateInventory(ctx, inventoryID=%q, onhand=%v, overeslling=%v) failed: %+v", inventoryID, request.Onhand, request.Overselling, err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// UpdateLocationHandler creates a handler function that updates
+// a stock location with the given id.
+func (a *App) UpdateLocationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: UpdateLocationHandler called")
+
+		locationID := chi.URLParam(r, "id")
+		if !IsValidUUID(locationID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		request := createLocationRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&request); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		valid, message := validateCreateLocationRequest(&request)
+		if !valid {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, message) // 400
+			return
+		}
+
+		location, err := a.Service.UpdateLocation(ctx, locationID, *request.Code,
+			*request.Name, *request.CountryCode, *request.Priority, *request.Active)
+		if err == service.ErrLocationNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeLocationNotFound,
+				"location not found") // 404
+			return
+		}
+		if err == service.ErrLocationCodeExists {
+			clientError(w, http.StatusConflict, ErrCodeLocationCodeExists,
+				"location code already exists") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.UpdateLocation(ctx, locationID=%q, ...) failed: %+v", locationID, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200 OK
+		json.NewEncoder(w).Encode(&location)
+	}
+}
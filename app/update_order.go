@@ -0,0 +1,93 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+type updateOrderRequestBody struct {
+	Status *string `json:"status"`
+	Note   *string `json:"note"`
+}
+
+func validateUpdateOrderRequestBody(o *updateOrderRequestBody) error {
+	if o.Status == nil {
+		return errors.New("status attribute must be set")
+	}
+	if !service.IsValidOrderStatus(*o.Status) {
+		return errors.New("status attribute must be one of pending, paid, processing, partially_shipped, shipped, delivered, cancelled or refunded")
+	}
+	// Statuses that move money are only reached through the payment
+	// provider.
+	switch *o.Status {
+	case service.OrderStatusPaid:
+		return errors.New("status attribute cannot be paid - orders are paid by the payment webhook")
+	case service.OrderStatusCancelled:
+		return errors.New("status attribute cannot be cancelled - use POST /orders/{id}/cancel")
+	case service.OrderStatusRefunded:
+		return errors.New("status attribute cannot be refunded - use POST /orders/{id}/refunds")
+	}
+	if o.Note != nil && len(*o.Note) > 1024 {
+		return errors.New("note attribute must be no more than 1024 characters")
+	}
+	return nil
+}
+
+// UpdateOrderHandler returns a http.HandlerFunc that moves an order
+// to a new status.
+func (a *App) UpdateOrderHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: UpdateOrderHandler called")
+
+		orderID := chi.URLParam(r, "id")
+		if !IsValidUUID(orderID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		o := updateOrderRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&o); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		if err := validateUpdateOrderRequestBody(&o); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		order, err := a.Service.UpdateOrderStatus(ctx, orderID, *o.Status, o.Note)
+		if err == service.ErrOrderNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeOrderNotFound,
+				"order not found") // 404
+			return
+		}
+		if err == service.ErrOrderTransitionInvalid {
+			clientError(w, http.StatusConflict, ErrCodeOrderTransitionInvalid,
+				"order cannot be moved to the requested status from its current status") // 409
+			return
+		}
+		if err == service.ErrOrderStatusChanged {
+			clientError(w, http.StatusConflict, ErrCodeOrderStatusChanged,
+				"order status was changed by another request") // 409
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.UpdateOrderStatus(ctx, orderID=%q, status=%q, ...) failed: %+v",
+				orderID, *o.Status, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(&order)
+	}
+}
@@ -2,6 +2,8 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
@@ -20,6 +22,16 @@ func validateProductUpdateRequestBody(pc *service.ProductUpdateRequestBody) erro
 
 	// TODO: make sure the new path is not already taken by another
 	// product other than this one.
+
+	if pc.TaxCode != nil && (*pc.TaxCode == "" || len(*pc.TaxCode) > 32) {
+		return errors.New("tax_code attribute must be between 1 and 32 characters")
+	}
+	if pc.MetaTitle != nil && len(*pc.MetaTitle) > 512 {
+		return errors.New("meta_title attribute must be at most 512 characters")
+	}
+	if pc.MetaDescription != nil && len(*pc.MetaDescription) > 1024 {
+		return errors.New("meta_description attribute must be at most 1024 characters")
+	}
 	return nil
 }
 
@@ -47,6 +59,11 @@ func (a *App) UpdateProductHandler() http.HandlerFunc {
 		}
 
 		product, err := a.Service.UpdateProduct(ctx, productID, &pu)
+		if e, ok := err.(*service.ProductAttributeError); ok {
+			clientError(w, http.StatusBadRequest, ErrCodeProductAttributeInvalid,
+				fmt.Sprintf("attributes %s %s", e.Code, e.Message)) // 400
+			return
+		}
 		if err == service.ErrProductNotFound {
 			clientError(w, http.StatusNotFound, ErrCodeProductNotFound,
 				"product not found") // 404
@@ -62,6 +79,11 @@ func (a *App) UpdateProductHandler() http.HandlerFunc {
 				"product sku already exists") // 409
 			return
 		}
+		if err == service.ErrTaxCodeNotFound {
+			clientError(w, http.StatusConflict, ErrCodeTaxCodeNotFound,
+				"tax code has no tax rates") // 409
+			return
+		}
 		if err != nil {
 			contextLogger.Errorf("update product failed: %+v", err)
 			w.WriteHeader(http.StatusInternalServerError) // 500
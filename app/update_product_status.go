@@ -0,0 +1,75 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+type updateProductStatusRequestBody struct {
+	Status      *string    `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+func validateUpdateProductStatusRequestBody(p *updateProductStatusRequestBody) error {
+	if p.Status == nil {
+		return errors.New("status attribute must be set")
+	}
+	if !service.IsValidProductStatus(*p.Status) {
+		return errors.New("status attribute must be one of draft, active or archived")
+	}
+	if p.PublishAt != nil && p.UnpublishAt != nil && !p.UnpublishAt.After(*p.PublishAt) {
+		return errors.New("unpublish_at attribute must be after publish_at")
+	}
+	return nil
+}
+
+// UpdateProductStatusHandler returns a http.HandlerFunc that sets the
+// status and publish window of a product.
+func (a *App) UpdateProductStatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		contextLogger := log.WithContext(ctx)
+		contextLogger.Info("app: UpdateProductStatusHandler called")
+
+		productID := chi.URLParam(r, "id")
+		if !IsValidUUID(productID) {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest,
+				"path parameter id must be a valid v4 uuid") // 400
+			return
+		}
+
+		p := updateProductStatusRequestBody{}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+		if err := validateUpdateProductStatusRequestBody(&p); err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error()) // 400
+			return
+		}
+
+		product, err := a.Service.UpdateProductStatus(ctx, productID, *p.Status, p.PublishAt, p.UnpublishAt)
+		if err == service.ErrProductNotFound {
+			clientError(w, http.StatusNotFound, ErrCodeProductNotFound,
+				"product not found") // 404
+			return
+		}
+		if err != nil {
+			contextLogger.Errorf("app: a.Service.UpdateProductStatus(ctx, productID=%q, status=%q, ...) failed: %+v",
+				productID, *p.Status, err)
+			w.WriteHeader(http.StatusInternalServerError) // 500
+			return
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		json.NewEncoder(w).Encode(product)
+	}
+}
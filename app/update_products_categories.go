@@ -10,6 +10,8 @@ import (
 )
 
 // UpdateProductsCategoriesHandler returns a handler to batch update product to categories.
+// With the async query parameter set to true the update is run by a
+// background job.
 func (app *App) UpdateProductsCategoriesHandler() http.HandlerFunc {
 	type request struct {
 		Object string                              `json:"object"`
@@ -26,6 +28,12 @@ func (app *App) UpdateProductsCategoriesHandler() http.HandlerFunc {
 		contextLogger := log.WithContext(ctx)
 		contextLogger.Info("app: UpdateProductsCategoriesHandler called")
 
+		async, err := asyncFromQuery(r.URL.Query())
+		if err != nil {
+			clientError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+
 		var request request
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
@@ -34,6 +42,18 @@ func (app *App) UpdateProductsCategoriesHandler() http.HandlerFunc {
 			return
 		}
 
+		if async {
+			userID := ctx.Value(ecomUIDKey).(string)
+			job, err := app.Service.UpdateProductsCategoriesAsync(ctx, userID, request.Data)
+			if err != nil {
+				contextLogger.Errorf("app: UpdateProductsCategoriesAsync(ctx, userID=%q, ...) error: %+v", userID, err)
+				w.WriteHeader(http.StatusInternalServerError) // 500
+				return
+			}
+			jobAccepted(w, job)
+			return
+		}
+
 		productsCategories, err := app.Service.UpdateProductsCategories(ctx, request.Data)
 		if err == service.ErrProductNotFound {
 			// 404 Not Found
@@ -19,6 +19,9 @@ import (
 
 	"bitbucket.org/andyfusniakteam/ecom-api-go/app"
 	model "bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"bitbucket.org/andyfusniakteam/ecom-api-go/payment"
+	"bitbucket.org/andyfusniakteam/ecom-api-go/payment/manual"
+	stripepay "bitbucket.org/andyfusniakteam/ecom-api-go/payment/stripe"
 	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
 	"cloud.google.com/go/profiler"
 	"cloud.google.com/go/pubsub"
@@ -31,7 +34,6 @@ import (
 	"github.com/go-chi/chi/middleware"
 	_ "github.com/lib/pq"
 	"github.com/rs/cors"
-	stripe "github.com/stripe/stripe-go"
 
 	log "github.com/sirupsen/logrus"
 
@@ -43,6 +45,8 @@ var version = "v0.64.0"
 
 const maxDbConnectAttempts = 3
 
+const defaultJobWorkers = 2
+
 var (
 	//
 	// PostgreSQL Database settings
@@ -118,6 +122,18 @@ var (
 	fbCredentialsEnv  = os.Getenv("ECOM_FIREBASE_PRIVATE_CREDENTIALS")
 	pubSubPushToken   = os.Getenv("ECOM_GOOGLE_PUBSUB_PUSH_TOKEN")
 
+	// Payment provider settings. ECOM_PAYMENT_PROVIDER is stripe (default)
+	// or manual. The manual provider takes no payments and is used to run
+	// checkout flows without access to Stripe.
+	paymentProviderEnv            = os.Getenv("ECOM_PAYMENT_PROVIDER")
+	manualPaymentSigningSecretEnv = os.Getenv("ECOM_MANUAL_PAYMENT_SIGNING_SECRET")
+
+	// Stock allocation strategy used when an order is placed.
+	// ECOM_STOCK_ALLOCATION is priority (default) to take stock from
+	// locations in priority order or nearest to take stock from locations
+	// in the shipping country first.
+	stockAllocationEnv = os.Getenv("ECOM_STOCK_ALLOCATION")
+
 	// Stripe settings (optional)
 	stripeSecretKey     = os.Getenv("ECOM_STRIPE_SECRET_KEY")
 	stripeSigningSecret = os.Getenv("ECOM_STRIPE_SIGNING_SECRET")
@@ -138,6 +154,20 @@ var (
 	connMaxLifetimeEnv          = os.Getenv("ECOM_APP_CONN_MAX_LIFETIME")
 	enableStackDriverLoggingEnv = os.Getenv("ECOM_APP_ENABLE_STACKDRIVER_LOGGING")
 	appEndpoint                 = os.Getenv("ECOM_APP_ENDPOINT")
+
+	// Number of workers running background jobs such as async catalog
+	// imports. ECOM_APP_JOB_WORKERS defaults to 2. Set it to 0 to leave
+	// jobs to other instances.
+	jobWorkersEnv = os.Getenv("ECOM_APP_JOB_WORKERS")
+
+	// Absolute URL of the storefront used to build the product and
+	// category URLs in the sitemap and product feed. The sitemap and
+	// product feed are not served unless ECOM_APP_STOREFRONT_URL is set.
+	storefrontURL = os.Getenv("ECOM_APP_STOREFRONT_URL")
+
+	// Id of the price list of the public product feed. The default price
+	// list is used if ECOM_APP_FEED_PRICE_LIST_ID is not set.
+	feedPriceListID = os.Getenv("ECOM_APP_FEED_PRICE_LIST_ID")
 )
 
 var enableStackDriverLogging bool
@@ -410,11 +440,21 @@ func main() {
 			log.Fatal("main: ECOM_STRIPE_SECRET_KEY must be set since ECOM_STRIPE_SIGNING_SECRET has been set")
 		}
 
-		if stripeSecretKey != "" {
-			stripe.Key = stripeSecretKey
-			log.Info("main: Stripe Secret Key set in Stripe library")
+	}
+
+	var paymentProvider payment.Provider
+	switch paymentProviderEnv {
+	case "", "stripe":
+		paymentProvider = stripepay.NewProvider(stripeSecretKey, stripeSigningSecret)
+	case "manual":
+		if manualPaymentSigningSecretEnv == "" {
+			log.Fatal("main: ECOM_MANUAL_PAYMENT_SIGNING_SECRET must be set when ECOM_PAYMENT_PROVIDER is manual")
 		}
+		paymentProvider = manual.NewProvider(manualPaymentSigningSecretEnv)
+	default:
+		log.Fatalf("main: ECOM_PAYMENT_PROVIDER must be stripe or manual but is %q", paymentProviderEnv)
 	}
+	log.Infof("main: using the %s payment provider", paymentProvider.Name())
 
 	if stripeSuccessURL == "" {
 		stripeSuccessURL = "https://example.com/success"
@@ -529,6 +569,34 @@ func main() {
 		}
 	}
 
+	// 7. Background job workers
+	jobWorkers := defaultJobWorkers
+	if jobWorkersEnv != "" {
+		var err error
+		jobWorkers, err = strconv.Atoi(jobWorkersEnv)
+		if err != nil || jobWorkers < 0 {
+			log.Fatal("main: app failed to read value in ECOM_APP_JOB_WORKERS")
+		}
+	}
+	log.Infof("main: ECOM_APP_JOB_WORKERS set to %d", jobWorkers)
+
+	// 8. Storefront URL for the sitemap and product feed
+	if storefrontURL == "" {
+		log.Warn("main: ECOM_APP_STOREFRONT_URL is not set. The sitemap and product feed will not be served")
+	} else {
+		su, err := url.Parse(storefrontURL)
+		if err != nil || !su.IsAbs() {
+			log.Fatalf("main: ECOM_APP_STOREFRONT_URL must be set to an absolute URL - got %s", storefrontURL)
+		}
+		log.Infof("main: ECOM_APP_STOREFRONT_URL set to %s", storefrontURL)
+	}
+	if feedPriceListID != "" {
+		if !app.IsValidUUID(feedPriceListID) {
+			log.Fatalf("main: ECOM_APP_FEED_PRICE_LIST_ID must be a valid v4 uuid - got %s", feedPriceListID)
+		}
+		log.Infof("main: ECOM_APP_FEED_PRICE_LIST_ID set to %s", feedPriceListID)
+	}
+
 	// connect to postgres
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -573,6 +641,12 @@ func main() {
 
 	// build a Postgres model
 	pgModel := model.NewPgModel(db)
+	if stockAllocationEnv != "" {
+		if err := pgModel.SetAllocationStrategy(stockAllocationEnv); err != nil {
+			log.Fatalf("main: ECOM_STOCK_ALLOCATION must be priority or nearest but is %q", stockAllocationEnv)
+		}
+	}
+	log.Infof("main: ECOM_STOCK_ALLOCATION set to %s", pgModel.AllocationStrategy())
 
 	// build a Google Firebase App
 	var fbApp *firebase.App
@@ -618,7 +692,7 @@ func main() {
 	}
 
 	// build a Firebase service injecting in the model and firebase app as dependencies
-	fbSrv := service.NewService(pgModel, fbApp, eventsTopic, whBroadcastTopic)
+	fbSrv := service.NewService(pgModel, fbApp, eventsTopic, whBroadcastTopic, paymentProvider)
 
 	// ensure the root user has been created
 	err = fbSrv.CreateRootIfNotExists(ctx, rootEmail, rootPassword)
@@ -626,6 +700,14 @@ func main() {
 		log.Fatalf("main: failed to create root credentials if not exists: %+v", err)
 	}
 
+	// run background jobs until the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	jobsStopped := make(chan struct{})
+	go func() {
+		fbSrv.RunJobWorkers(jobsCtx, jobWorkers)
+		close(jobsStopped)
+	}()
+
 	// SystemInfo
 	si := app.SystemInfo{
 		Version: version,
@@ -714,12 +796,27 @@ func main() {
 			r.Get("/", a.Authorization(app.OpListInventory, a.ListInventoryHandler()))
 			r.Get("/{id}", a.Authorization(app.OpGetInventory, a.GetInventoryHandler()))
 			r.Patch("/{id}", a.Authorization(app.OpUpdateInventory, a.UpdateInventoryHandler()))
+			r.Post("/{id}/adjustments", a.Authorization(app.OpAdjustInventory, a.AdjustInventoryHandler()))
+			r.Get("/{id}/movements", a.Authorization(app.OpListInventoryMovements, a.ListInventoryMovementsHandler()))
+		})
+
+		// Locations
+		r.Route("/locations", func(r chi.Router) {
+			r.Post("/", a.Authorization(app.OpCreateLocation, a.CreateLocationHandler()))
+			r.Get("/", a.Authorization(app.OpListLocations, a.ListLocationsHandler()))
+			r.Get("/{id}", a.Authorization(app.OpGetLocation, a.GetLocationHandler()))
+			r.Put("/{id}", a.Authorization(app.OpUpdateLocation, a.UpdateLocationHandler()))
 		})
 
 		r.Route("/inventory:batch-update", func(r chi.Router) {
 			r.Patch("/", a.Authorization(app.OpBatchUpdateInventory, a.BatchUpdateInventoryHandler()))
 		})
 
+		// Backorders
+		r.Route("/backorders", func(r chi.Router) {
+			r.Get("/", a.Authorization(app.OpListBackorders, a.ListBackordersHandler()))
+		})
+
 		// Promo Rules
 		r.Route("/promo-rules", func(r chi.Router) {
 			r.Post("/", a.Authorization(app.OpCreatePromoRule, a.CreatePromoRuleHandler()))
@@ -754,9 +851,43 @@ func main() {
 		r.Route("/products", func(r chi.Router) {
 			r.Post("/", a.Authorization((app.OpCreateProduct), a.CreateProductHandler()))
 			r.Put("/{id}", a.Authorization(app.OpUpdateProduct, a.UpdateProductHandler()))
+			r.Patch("/{id}", a.Authorization(app.OpUpdateProductStatus, a.UpdateProductStatusHandler()))
 			r.Get("/", a.Authorization(app.OpListProducts, a.ListProductsHandler()))
+			r.Get("/search", a.Authorization(app.OpSearchProducts, a.SearchProductsHandler()))
 			r.Get("/{id}", a.Authorization(app.OpGetProduct, a.GetProductHandler()))
 			r.Delete("/{id}", a.Authorization(app.OpDeleteProduct, a.DeleteProductHandler()))
+			r.Post("/{id}/variants", a.Authorization(app.OpCreateVariant, a.CreateVariantHandler()))
+			r.Get("/{id}/variants", a.Authorization(app.OpListVariants, a.ListVariantsHandler()))
+		})
+
+		// Catalog imports and exports
+		r.Route("/catalog-imports", func(r chi.Router) {
+			r.Post("/", a.Authorization(app.OpCreateCatalogImport, a.CreateCatalogImportHandler()))
+			r.Get("/{id}", a.Authorization(app.OpGetCatalogImport, a.GetCatalogImportHandler()))
+			r.Post("/{id}/resume", a.Authorization(app.OpResumeCatalogImport, a.ResumeCatalogImportHandler()))
+		})
+		r.Get("/catalog-export", a.Authorization(app.OpExportCatalog, a.ExportCatalogHandler()))
+
+		// Jobs
+		r.Route("/jobs", func(r chi.Router) {
+			r.Get("/{id}", a.Authorization(app.OpGetJob, a.GetJobHandler()))
+			r.Post("/{id}/cancel", a.Authorization(app.OpCancelJob, a.CancelJobHandler()))
+		})
+
+		// Option types
+		r.Route("/option-types", func(r chi.Router) {
+			r.Post("/", a.Authorization(app.OpCreateOptionType, a.CreateOptionTypeHandler()))
+			r.Get("/", a.Authorization(app.OpListOptionTypes, a.ListOptionTypesHandler()))
+			r.Get("/{id}", a.Authorization(app.OpGetOptionType, a.GetOptionTypeHandler()))
+			r.Delete("/{id}", a.Authorization(app.OpDeleteOptionType, a.DeleteOptionTypeHandler()))
+		})
+
+		// Product attributes
+		r.Route("/product-attributes", func(r chi.Router) {
+			r.Post("/", a.Authorization(app.OpCreateProductAttribute, a.CreateProductAttributeHandler()))
+			r.Get("/", a.Authorization(app.OpListProductAttributes, a.ListProductAttributesHandler()))
+			r.Get("/{id}", a.Authorization(app.OpGetProductAttribute, a.GetProductAttributeHandler()))
+			r.Delete("/{id}", a.Authorization(app.OpDeleteProductAttribute, a.DeleteProductAttributeHandler()))
 		})
 
 		r.Route("/images", func(r chi.Router) {
@@ -800,9 +931,18 @@ func main() {
 			r.Delete("/{id}", a.Authorization(app.OpDeleteShippingTariff, a.DeleteShippingTariffHandler()))
 		})
 
+		// Tax Rates
+		r.Route("/tax-rates", func(r chi.Router) {
+			r.Post("/", a.Authorization(app.OpCreateTaxRate, a.CreateTaxRateHandler()))
+			r.Get("/{id}", a.Authorization(app.OpGetTaxRate, a.GetTaxRateHandler()))
+			r.Get("/", a.Authorization(app.OpListTaxRates, a.ListTaxRatesHandler()))
+			r.Delete("/{id}", a.Authorization(app.OpDeleteTaxRate, a.DeleteTaxRateHandler()))
+		})
+
 		// Carts
 		r.Route("/carts", func(r chi.Router) {
 			r.Post("/", a.Authorization(app.OpCreateCart, a.CreateCartHandler()))
+			r.Get("/{id}/totals", a.Authorization(app.OpGetCartTotals, a.GetCartTotalsHandler()))
 		})
 
 		// Cart Coupons
@@ -825,6 +965,18 @@ func main() {
 		r.Route("/categories", func(r chi.Router) {
 			r.Get("/", a.Authorization(app.OpGetCategories, a.GetCategoriesHandler()))
 			r.Delete("/", a.Authorization(app.OpDeleteCategories, a.DeleteCategoriesHandler()))
+			r.Post("/", a.Authorization(app.OpCreateCategory, a.CreateCategoryHandler()))
+			r.Get("/by-path", a.Authorization(app.OpGetCategoryByPath, a.GetCategoryByPathHandler()))
+			r.Get("/{id}", a.Authorization(app.OpGetCategory, a.GetCategoryHandler()))
+			r.Patch("/{id}", a.Authorization(app.OpUpdateCategory, a.UpdateCategoryHandler()))
+			r.Post("/{id}/move", a.Authorization(app.OpMoveCategory, a.MoveCategoryHandler()))
+			r.Delete("/{id}", a.Authorization(app.OpDeleteCategory, a.DeleteCategoryHandler()))
+			r.Put("/{id}/products", a.Authorization(app.OpReorderCategoryProducts, a.ReorderCategoryProductsHandler()))
+		})
+
+		// Routes
+		r.Route("/resolve", func(r chi.Router) {
+			r.Get("/", a.Authorization(app.OpResolvePath, a.ResolvePathHandler()))
 		})
 
 		r.Route("/categories-tree", func(r chi.Router) {
@@ -847,9 +999,21 @@ func main() {
 			r.Post("/", a.Authorization(app.OpPlaceOrder, a.PlaceOrderHandler()))
 			r.Get("/{id}", a.Authorization(app.OpGetOrder, a.GetOrderHandler()))
 			r.Get("/", a.Authorization(app.OpListOrders, a.ListOrdersHandler()))
+			r.Patch("/{id}", a.Authorization(app.OpUpdateOrder, a.UpdateOrderHandler()))
+			r.Get("/{id}/history", a.Authorization(app.OpGetOrderHistory, a.GetOrderHistoryHandler()))
+			r.Post("/{id}/shipments", a.Authorization(app.OpCreateShipment, a.CreateShipmentHandler()))
+			r.Get("/{id}/shipments", a.Authorization(app.OpListShipments, a.ListShipmentsHandler()))
+			r.Post("/{id}/refunds", a.Authorization(app.OpCreateRefund, a.CreateRefundHandler()))
+			r.Get("/{id}/refunds", a.Authorization(app.OpListRefunds, a.ListRefundsHandler()))
+			r.Post("/{id}/cancel", a.Authorization(app.OpCancelOrder, a.CancelOrderHandler()))
 			r.Post("/{id}/stripecheckout", a.Authorization(app.OpStripeCheckout, a.StripeCheckoutHandler(stripeSuccessURL, stripeCancelURL)))
 		})
 
+		// Shipments
+		r.Route("/shipments", func(r chi.Router) {
+			r.Get("/{id}", a.Authorization(app.OpGetShipment, a.GetShipmentHandler()))
+		})
+
 		r.Route("/sysinfo", func(r chi.Router) {
 			r.Get("/", a.Authorization(app.OpSystemInfo, a.SystemInfoHandler(si)))
 		})
@@ -873,9 +1037,19 @@ func main() {
 		r.Get("/healthz", healthCheckHandler)
 		r.Get("/config", a.ConfigHandler(si.Env.Firebase))
 		r.Route("/stripe-webhook", func(r chi.Router) {
-			r.Post("/", a.StripeWebhookHandler(stripeSigningSecret))
+			r.Post("/", a.PaymentWebhookHandler())
+		})
+		r.Route("/payment-webhook", func(r chi.Router) {
+			r.Post("/", a.PaymentWebhookHandler())
 		})
 
+		// Sitemap and Google Merchant product feed
+		if storefrontURL != "" {
+			r.Get("/sitemap.xml", a.GetSitemapHandler(storefrontURL))
+			r.Get("/sitemap-{n}.xml", a.GetSitemapChunkHandler(storefrontURL))
+			r.Get("/google-merchant-feed.xml", a.GetProductFeedHandler(storefrontURL, feedPriceListID))
+		}
+
 		r.Route("/private-pubsub-events", func(r chi.Router) {
 			r.Post("/", a.PubSubEventHandler(pubSubPushToken))
 		})
@@ -919,6 +1093,10 @@ func main() {
 			log.Infof("main: HTTP server Shutdown: %v", err)
 		}
 		log.Infof("main: HTTP server shutdown complete")
+
+		stopJobs()
+		<-jobsStopped
+		log.Infof("main: job workers stopped")
 		close(idleConnsClosed)
 	}()
 
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	model "bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	service "bitbucket.org/andyfusniakteam/ecom-api-go/service/firebase"
+	_ "github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+const usage = `usage: ecom-feeds <command> [flags]
+
+Commands:
+  sitemap       write sitemap.xml, and its chunks if over 50,000 URLs, to a directory
+  product-feed  write the Google Merchant product feed to a file
+
+Run ecom-feeds <command> -h for the flags of each command.
+
+The database connection is configured with the same ECOM_PG_* environment
+variables as ecom-api. The storefront URL defaults to ECOM_APP_STOREFRONT_URL.
+`
+
+func main() {
+	log.SetFormatter(&log.TextFormatter{
+		ForceColors: true,
+	})
+	log.SetOutput(os.Stderr)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "sitemap":
+		sitemapCmd(os.Args[2:])
+	case "product-feed":
+		productFeedCmd(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func sitemapCmd(args []string) {
+	fs := flag.NewFlagSet("sitemap", flag.ExitOnError)
+	storefrontURL := fs.String("storefront-url", os.Getenv("ECOM_APP_STOREFRONT_URL"), "absolute URL of the storefront")
+	dir := fs.String("dir", ".", "directory to write sitemap.xml and its chunks to")
+	fs.Parse(args)
+	mustBeAbsURL(*storefrontURL)
+
+	srv, db := newService()
+	defer db.Close()
+
+	sitemap, err := srv.GetSitemap(context.Background(), *storefrontURL)
+	if err != nil {
+		log.Fatalf("main: srv.GetSitemap(ctx, baseURL=%q) failed: %+v", *storefrontURL, err)
+	}
+	writeFile(filepath.Join(*dir, "sitemap.xml"), sitemap.Write)
+	for n := 1; n <= sitemap.Chunks(); n++ {
+		n := n
+		writeFile(filepath.Join(*dir, service.SitemapChunkName(n)), func(w io.Writer) error {
+			return sitemap.WriteChunk(w, n)
+		})
+	}
+}
+
+func productFeedCmd(args []string) {
+	fs := flag.NewFlagSet("product-feed", flag.ExitOnError)
+	storefrontURL := fs.String("storefront-url", os.Getenv("ECOM_APP_STOREFRONT_URL"), "absolute URL of the storefront")
+	priceListID := fs.String("price-list-id", "", "id of the price list to take prices from (default price list if not set)")
+	out := fs.String("out", "google-merchant-feed.xml", "file to write the product feed to")
+	fs.Parse(args)
+	mustBeAbsURL(*storefrontURL)
+
+	srv, db := newService()
+	defer db.Close()
+
+	feed, err := srv.GetProductFeed(context.Background(), *storefrontURL, *priceListID)
+	if err != nil {
+		log.Fatalf("main: srv.GetProductFeed(ctx, baseURL=%q, priceListID=%q) failed: %+v", *storefrontURL, *priceListID, err)
+	}
+	writeFile(*out, feed.Write)
+	log.Infof("main: %d products in the product feed", len(feed.Items()))
+}
+
+func mustBeAbsURL(storefrontURL string) {
+	u, err := url.Parse(storefrontURL)
+	if err != nil || !u.IsAbs() {
+		log.Fatalf("main: storefront URL must be set to an absolute URL. Use -storefront-url or ECOM_APP_STOREFRONT_URL")
+	}
+}
+
+// writeFile creates the file at path and calls write to fill it.
+func writeFile(path string, write func(w io.Writer) error) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("main: os.Create(%q) failed: %v", path, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		log.Fatalf("main: failed to write %s: %+v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("main: failed to close %s: %v", path, err)
+	}
+	log.Infof("main: wrote %s", path)
+}
+
+// newService connects to postgres using the ECOM_PG_* environment
+// variables and returns a service backed by the database only.
+func newService() (*service.Service, *sql.DB) {
+	pghost := os.Getenv("ECOM_PG_HOST")
+	if pghost == "" {
+		log.Fatal("main: postgres host not set. Use ECOM_PG_HOST")
+	}
+	pgdatabase := os.Getenv("ECOM_PG_DATABASE")
+	if pgdatabase == "" {
+		log.Fatal("main: ECOM_PG_DATABASE not set.")
+	}
+	pgpassword := os.Getenv("ECOM_PG_PASSWORD")
+	if pgpassword == "" {
+		log.Fatal("main: ECOM_PG_PASSWORD not set. You must set a password")
+	}
+	pgport := os.Getenv("ECOM_PG_PORT")
+	if pgport == "" {
+		pgport = "5432"
+	}
+	pguser := os.Getenv("ECOM_PG_USER")
+	if pguser == "" {
+		pguser = "postgres"
+	}
+	pgsslmode := os.Getenv("ECOM_PG_SSLMODE")
+	if pgsslmode == "" {
+		pgsslmode = "disable"
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", pghost, pgport, pguser, pgpassword, pgdatabase, pgsslmode)
+	if pgsslmode != "disable" {
+		dsn += fmt.Sprintf(" sslcert=%s sslrootcert=%s sslkey=%s",
+			os.Getenv("ECOM_PG_SSLCERT"), os.Getenv("ECOM_PG_SSLROOTCERT"), os.Getenv("ECOM_PG_SSLKEY"))
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		log.Fatalf("main: failed to open db: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		log.Fatalf("main: failed to verify db connection: %v", err)
+	}
+	return service.NewService(model.NewPgModel(db), nil, nil, nil, nil), db
+}
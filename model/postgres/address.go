@@ -53,7 +53,7 @@ func (m *PgModel) CreateAddress(ctx context.Context, usrUUID, typ, contactName,
 	var usrID int
 	err := m.db.QueryRowContext(ctx, q1, usrUUID).Scan(&usrID)
 	if err == sql.ErrNoRows {
-		contextLogger.Debug("postgres: user usrUUID=%q not found", usrUUID)
+		contextLogger.Debugf("postgres: user usrUUID=%q not found", usrUUID)
 		return nil, ErrUserNotFound
 	}
 	if err != nil {
@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Backorder policies of inventory.
+const (
+	// BackorderNone does not accept orders beyond onhand unless the
+	// inventory allows overselling.
+	BackorderNone = "none"
+
+	// BackorderAllowed accepts orders beyond onhand and backorders the
+	// quantity short.
+	BackorderAllowed = "backorder"
+
+	// BackorderPreorder accepts orders for products not yet released.
+	BackorderPreorder = "preorder"
+)
+
+// Availability statuses of products.
+const (
+	AvailabilityInStock    = "in_stock"
+	AvailabilityOutOfStock = "out_of_stock"
+	AvailabilityBackorder  = "backorder"
+	AvailabilityPreorder   = "preorder"
+)
+
+// InventoryBackorder holds the backorder policy of inventory.
+// AvailableDate is the date stock is expected and Limit caps the
+// outstanding backordered quantity if not nil.
+type InventoryBackorder struct {
+	Backorder     string
+	AvailableDate *time.Time
+	Limit         *int
+}
+
+// ProductAvailabilityRow holds the availability of a single product.
+type ProductAvailabilityRow struct {
+	ProductUUID   string
+	Status        string
+	AvailableDate *time.Time
+}
+
+// BackorderRow holds the outstanding backordered quantity of a single
+// product across all open orders.
+type BackorderRow struct {
+	ProductUUID   string
+	SKU           string
+	Name          string
+	Backorder     string
+	AvailableDate *time.Time
+	Backordered   int
+	Orders        int
+}
+
+// outstandingBackorders is a sub query of the quantity of each order
+// item still backordered. An order item is no longer backordered once
+// the order is closed or the backordered quantity has shipped.
+const outstandingBackorders = `
+	SELECT
+	  p.id AS product_id, oi.order_id,
+	  LEAST(oi.backordered, oi.qty - COALESCE(s.qty, 0)) AS qty
+	FROM order_item AS oi
+	INNER JOIN "order" AS o
+	  ON o.id = oi.order_id
+	INNER JOIN product AS p
+	  ON p.sku = oi.sku
+	LEFT OUTER JOIN (
+	  SELECT order_item_id, SUM(qty) AS qty
+	  FROM shipment_item
+	  GROUP BY order_item_id
+	) AS s
+	  ON s.order_item_id = oi.id
+	WHERE
+	  oi.backordered > 0 AND
+	  o.status IN ('pending', 'paid', 'processing', 'partially_shipped')
+`
+
+// availabilityStatus returns the availability of a product with the
+// given onhand and backorder policy where outstanding is the quantity
+// already backordered. Stock owed to outstanding backorders is not in
+// stock.
+func availabilityStatus(onhand int, overselling bool, backorder string, limit *int, outstanding int) string {
+	if backorder == BackorderPreorder {
+		if limit != nil && outstanding >= *limit {
+			return AvailabilityOutOfStock
+		}
+		return AvailabilityPreorder
+	}
+	if onhand-outstanding > 0 {
+		return AvailabilityInStock
+	}
+	if backorder == BackorderAllowed {
+		if limit != nil && outstanding >= *limit {
+			return AvailabilityOutOfStock
+		}
+		return AvailabilityBackorder
+	}
+	if overselling {
+		return AvailabilityBackorder
+	}
+	return AvailabilityOutOfStock
+}
+
+// GetProductAvailability returns the availability of each of the
+// products with the given uuids keyed by product uuid. Only stock at
+// active locations counts. Products without inventory are always in
+// stock.
+func (m *PgModel) GetProductAvailability(ctx context.Context, productUUIDs []string) (map[string]*ProductAvailabilityRow, error) {
+	q1 := `
+		SELECT
+		  p.uuid, a.onhand, v.overselling, v.backorder, v.available_date,
+		  v.backorder_limit, COALESCE(x.qty, 0)
+		FROM product AS p
+		LEFT OUTER JOIN inventory AS v
+		  ON v.product_id = p.id
+		LEFT OUTER JOIN (` + activeStock + `) AS a
+		  ON a.inventory_id = v.id
+		LEFT OUTER JOIN (
+		  SELECT b.product_id, SUM(b.qty) AS qty
+		  FROM (` + outstandingBackorders + `) AS b
+		  GROUP BY b.product_id
+		) AS x
+		  ON x.product_id = p.id
+		WHERE p.uuid = ANY($1::UUID[])
+	`
+	rows, err := m.db.QueryContext(ctx, q1, pq.Array(productUUIDs))
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	availability := make(map[string]*ProductAvailabilityRow, len(productUUIDs))
+	for rows.Next() {
+		var a ProductAvailabilityRow
+		var onhand, limit *int
+		var overselling *bool
+		var backorder *string
+		var outstanding int
+		if err := rows.Scan(&a.ProductUUID, &onhand, &overselling, &backorder,
+			&a.AvailableDate, &limit, &outstanding); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		a.Status = AvailabilityInStock
+		if backorder != nil {
+			var n int
+			if onhand != nil {
+				n = *onhand
+			}
+			a.Status = availabilityStatus(n, *overselling, *backorder, limit, outstanding)
+		}
+		availability[a.ProductUUID] = &a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return availability, nil
+}
+
+// GetBackorders returns a page of the outstanding backordered quantity
+// of each product across all open orders ordered by SKU by default.
+func (m *PgModel) GetBackorders(ctx context.Context, opts *ListOptions) ([]*BackorderRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  p.uuid, p.sku, p.name, COALESCE(v.backorder, 'none'), v.available_date,
+		  SUM(b.qty), COUNT(DISTINCT b.order_id)`,
+		from: `(` + outstandingBackorders + `) AS b
+		  INNER JOIN product AS p ON p.id = b.product_id
+		  LEFT OUTER JOIN inventory AS v ON v.product_id = p.id`,
+		alias:   "p",
+		groupBy: "p.id, p.uuid, p.sku, p.name, v.backorder, v.available_date",
+		fields: map[string]string{
+			"sku":  "p.sku",
+			"name": "p.name",
+		},
+		orderBy:  "p.sku",
+		orderDir: OrderAsc,
+	}
+	q.where("b.qty > 0")
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	backorders := make([]*BackorderRow, 0, 16)
+	for rows.Next() {
+		var b BackorderRow
+		if err := rows.Scan(&b.ProductUUID, &b.SKU, &b.Name, &b.Backorder,
+			&b.AvailableDate, &b.Backordered, &b.Orders); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		backorders = append(backorders, &b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return backorders, opts.page(&backorders), nil
+}
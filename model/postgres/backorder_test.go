@@ -0,0 +1,21 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailabilityStatus(t *testing.T) {
+	limit := 5
+	assert.Equal(t, AvailabilityInStock, availabilityStatus(3, false, BackorderNone, nil, 0))
+	assert.Equal(t, AvailabilityOutOfStock, availabilityStatus(0, false, BackorderNone, nil, 0))
+	assert.Equal(t, AvailabilityBackorder, availabilityStatus(0, true, BackorderNone, nil, 0))
+	assert.Equal(t, AvailabilityInStock, availabilityStatus(3, false, BackorderAllowed, nil, 0))
+	assert.Equal(t, AvailabilityBackorder, availabilityStatus(3, false, BackorderAllowed, nil, 3))
+	assert.Equal(t, AvailabilityOutOfStock, availabilityStatus(3, false, BackorderNone, nil, 3))
+	assert.Equal(t, AvailabilityBackorder, availabilityStatus(0, false, BackorderAllowed, &limit, 4))
+	assert.Equal(t, AvailabilityOutOfStock, availabilityStatus(0, false, BackorderAllowed, &limit, 5))
+	assert.Equal(t, AvailabilityPreorder, availabilityStatus(3, false, BackorderPreorder, nil, 0))
+	assert.Equal(t, AvailabilityOutOfStock, availabilityStatus(0, false, BackorderPreorder, &limit, 5))
+}
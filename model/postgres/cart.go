@@ -24,6 +24,10 @@ var (
 
 	// ErrProductHasNoPrices error
 	ErrProductHasNoPrices = errors.New("postgres: product has no prices")
+
+	// ErrCartProductUnpublished occurs when pricing a cart that holds a
+	// product that is no longer published.
+	ErrCartProductUnpublished = errors.New("postgres: cart product unpublished")
 )
 
 // CartRow represents a row from the the cart table.
@@ -126,7 +130,8 @@ func (m *PgModel) AddProductToCart(ctx context.Context, cartUUID, userUUID, prod
 		return nil, errors.Wrapf(err, "postgres: query row context failed for q2=%q", q2)
 	}
 
-	q3 := "SELECT id FROM product WHERE uuid = $1"
+	// unpublished products and variants cannot be added to carts.
+	q3 := "SELECT p.id FROM product AS p WHERE p.uuid = $1 AND " + purchasableCondition
 	var productID int
 	err = tx.QueryRowContext(ctx, q3, productUUID).Scan(&productID)
 	if err == sql.ErrNoRows {
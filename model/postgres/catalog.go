@@ -0,0 +1,457 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// CatalogItemRow holds the values of a single product in a catalogue
+// import or export keyed by SKU. Fields of an import that are nil are
+// left unchanged. Prices maps price list codes to the unit price for a
+// quantity of one. Onhand is the stock at the default location.
+// Categories holds leaf category paths and Images image paths in order.
+// ParentSKU and Options are set for variants and are only used when a
+// variant is created.
+type CatalogItemRow struct {
+	SKU             string            `json:"sku"`
+	ParentSKU       *string           `json:"parent_sku"`
+	Options         map[string]string `json:"options"`
+	Status          *string           `json:"status"`
+	Path            *string           `json:"path"`
+	Name            *string           `json:"name"`
+	TaxCode         *string           `json:"tax_code"`
+	Description     *string           `json:"description"`
+	Attributes      ProductAttributes `json:"attributes"`
+	MetaTitle       *string           `json:"meta_title"`
+	MetaDescription *string           `json:"meta_description"`
+	Prices          map[string]int    `json:"prices"`
+	Onhand          *int              `json:"onhand"`
+	Categories      []string          `json:"categories"`
+	Images          []string          `json:"images"`
+}
+
+// ErrParentProductNotFound is returned when the parent SKU of a new
+// variant in a catalog import does not exist.
+var ErrParentProductNotFound = errors.New("postgres: parent product not found")
+
+// ErrParentSKUChanged is returned when a catalog import sets a parent SKU
+// on an existing product other than the one it has.
+var ErrParentSKUChanged = errors.New("postgres: parent sku changed")
+
+// CatalogProductRef holds the path of a product along with the SKU of
+// its parent and its options if it is a variant.
+type CatalogProductRef struct {
+	Path      string
+	ParentSKU *string
+	Options   map[string]string
+}
+
+// GetCatalogProductRefs returns the path, parent SKU and options of every
+// product keyed by SKU.
+func (m *PgModel) GetCatalogProductRefs(ctx context.Context) (map[string]*CatalogProductRef, error) {
+	q1 := `
+		SELECT
+		  p.sku, p.path, pp.sku,
+		  COALESCE((
+		    SELECT jsonb_object_agg(t.code, o.value)
+		    FROM product_option AS o
+		    INNER JOIN option_type AS t
+		      ON t.id = o.option_type_id
+		    WHERE o.product_id = p.id
+		  ), '{}')
+		FROM product AS p
+		LEFT OUTER JOIN product AS pp
+		  ON pp.id = p.parent_id
+	`
+	rows, err := m.db.QueryContext(ctx, q1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query context q1=%q", q1)
+	}
+	defer rows.Close()
+
+	refs := make(map[string]*CatalogProductRef)
+	for rows.Next() {
+		var sku string
+		var r CatalogProductRef
+		var options []byte
+		if err := rows.Scan(&sku, &r.Path, &r.ParentSKU, &options); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		if err := json.Unmarshal(options, &r.Options); err != nil {
+			return nil, errors.Wrap(err, "postgres: json unmarshal of options failed")
+		}
+		refs[sku] = &r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return refs, nil
+}
+
+// ExportCatalog calls fn for every product in the catalogue in the order
+// they were created so parent products come before their variants. The
+// rows are streamed from the database and export stops at the first
+// error returned by fn.
+func (m *PgModel) ExportCatalog(ctx context.Context, fn func(item *CatalogItemRow) error) error {
+	q1 := `
+		SELECT
+		  p.sku, pp.sku,
+		  (
+		    SELECT jsonb_object_agg(t.code, o.value)
+		    FROM product_option AS o
+		    INNER JOIN option_type AS t
+		      ON t.id = o.option_type_id
+		    WHERE o.product_id = p.id
+		  ),
+		  p.status, p.path, p.name, p.tax_code, p.description, p.attributes,
+		  p.meta_title, p.meta_description,
+		  COALESCE((
+		    SELECT jsonb_object_agg(l.code, r.unit_price)
+		    FROM price AS r
+		    INNER JOIN price_list AS l
+		      ON l.id = r.price_list_id
+		    WHERE r.product_id = p.id AND r.break = 1
+		  ), '{}'),
+		  COALESCE((
+		    SELECT il.onhand
+		    FROM inventory AS v
+		    INNER JOIN inventory_location AS il
+		      ON il.inventory_id = v.id
+		    INNER JOIN location AS o
+		      ON o.id = il.location_id
+		    WHERE v.product_id = p.id AND o.code = $1
+		  ), 0),
+		  ARRAY(
+		    SELECT c.path
+		    FROM product_category AS pc
+		    INNER JOIN category AS c
+		      ON c.id = pc.category_id
+		    WHERE pc.product_id = p.id
+		    ORDER BY c.path
+		  ),
+		  ARRAY(
+		    SELECT i.path FROM image AS i
+		    WHERE i.product_id = p.id
+		    ORDER BY i.pri, i.id
+		  )
+		FROM product AS p
+		LEFT OUTER JOIN product AS pp
+		  ON pp.id = p.parent_id
+		ORDER BY p.id
+	`
+	rows, err := m.db.QueryContext(ctx, q1, DefaultLocationCode)
+	if err != nil {
+		return errors.Wrapf(err, "postgres: query context q1=%q", q1)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c CatalogItemRow
+		var status, path, name, taxCode, description, metaTitle, metaDescription string
+		var options, prices []byte
+		var onhand int
+		if err := rows.Scan(&c.SKU, &c.ParentSKU, &options, &status, &path, &name, &taxCode,
+			&description, &c.Attributes, &metaTitle, &metaDescription, &prices, &onhand,
+			pq.Array(&c.Categories), pq.Array(&c.Images)); err != nil {
+			return errors.Wrap(err, "postgres: scan failed")
+		}
+		if options != nil {
+			if err := json.Unmarshal(options, &c.Options); err != nil {
+				return errors.Wrap(err, "postgres: json unmarshal of options failed")
+			}
+		}
+		if err := json.Unmarshal(prices, &c.Prices); err != nil {
+			return errors.Wrap(err, "postgres: json unmarshal of prices failed")
+		}
+		c.Status, c.Path, c.Name, c.TaxCode, c.Description = &status, &path, &name, &taxCode, &description
+		c.MetaTitle, c.MetaDescription, c.Onhand = &metaTitle, &metaDescription, &onhand
+		if err := fn(&c); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return nil
+}
+
+// applyCatalogItem creates or updates the product with the SKU of the
+// item along with its prices, stock, categories and images. New products
+// are created in the price list of the user with the given usrUUID and
+// are created as variants if the item has a parent SKU. A change to
+// onhand is recorded as a stock take in changes. The caller is
+// responsible for rolling back tx on error.
+func applyCatalogItem(ctx context.Context, tx *sql.Tx, changes *stockChanges, item *CatalogItemRow, usrUUID *string) error {
+	// 1. Create the product if it does not exist otherwise update it.
+	q1 := `
+		SELECT p.id, pp.sku
+		FROM product AS p
+		LEFT OUTER JOIN product AS pp
+		  ON pp.id = p.parent_id
+		WHERE p.sku = $1
+	`
+	var productID int
+	var parentSKU *string
+	err := tx.QueryRowContext(ctx, q1, item.SKU).Scan(&productID, &parentSKU)
+	if err == sql.ErrNoRows {
+		if item.Path == nil || item.Name == nil {
+			return ErrProductNotFound
+		}
+		taxCode := DefaultTaxCode
+		if item.TaxCode != nil {
+			taxCode = *item.TaxCode
+		}
+		content := ProductContent{Attributes: item.Attributes}
+		if item.Description != nil {
+			content.Description = *item.Description
+		}
+		if item.MetaTitle != nil {
+			content.MetaTitle = *item.MetaTitle
+		}
+		if item.MetaDescription != nil {
+			content.MetaDescription = *item.MetaDescription
+		}
+		userUUID := ""
+		if usrUUID != nil {
+			userUUID = *usrUUID
+		}
+		productID, err = createCatalogProduct(ctx, tx, userUUID, item, taxCode, &content)
+		if err != nil {
+			return err
+		}
+		if item.Status != nil {
+			q2 := "UPDATE product SET status = $2 WHERE id = $1"
+			if _, err := tx.ExecContext(ctx, q2, productID, *item.Status); err != nil {
+				return errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+			}
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	} else {
+		if item.ParentSKU != nil && (parentSKU == nil || *parentSKU != *item.ParentSKU) {
+			return ErrParentSKUChanged
+		}
+		if err := updateCatalogProduct(ctx, tx, productID, item); err != nil {
+			return err
+		}
+	}
+
+	// 2. Set the unit price for a quantity of one in each price list
+	// leaving any other price breaks in place.
+	q3 := "SELECT id FROM price_list WHERE code = $1"
+	q4 := `
+		INSERT INTO price (product_id, price_list_id, break, unit_price, created, modified)
+		VALUES ($1, $2, 1, $3, NOW(), NOW())
+		ON CONFLICT (price_list_id, product_id, break)
+		DO UPDATE SET unit_price = EXCLUDED.unit_price, modified = NOW()
+	`
+	for code, unitPrice := range item.Prices {
+		var priceListID int
+		err := tx.QueryRowContext(ctx, q3, code).Scan(&priceListID)
+		if err == sql.ErrNoRows {
+			return ErrPriceListNotFound
+		}
+		if err != nil {
+			return errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+		}
+		if _, err := tx.ExecContext(ctx, q4, productID, priceListID, unitPrice); err != nil {
+			return errors.Wrapf(err, "postgres: exec context q4=%q", q4)
+		}
+	}
+
+	// 3. Set the onhand at the default location.
+	if item.Onhand != nil {
+		q5 := "SELECT id FROM inventory WHERE product_id = $1 FOR UPDATE"
+		var inventoryID int
+		err := tx.QueryRowContext(ctx, q5, productID).Scan(&inventoryID)
+		if err == sql.ErrNoRows {
+			return ErrInventoryNotFound
+		}
+		if err != nil {
+			return errors.Wrapf(err, "postgres: query row context q5=%q", q5)
+		}
+		if err := setLocationStock(ctx, tx, changes, inventoryID, nil, *item.Onhand, usrUUID); err != nil {
+			return err
+		}
+	}
+
+	if item.Categories != nil {
+		if err := setCatalogCategories(ctx, tx, productID, item.Categories); err != nil {
+			return err
+		}
+	}
+	if item.Images != nil {
+		if err := setCatalogImages(ctx, tx, productID, item.Images); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createCatalogProduct creates the product of a catalog item as a
+// variant of the product with its parent SKU if set and returns its id.
+func createCatalogProduct(ctx context.Context, tx *sql.Tx, userUUID string, item *CatalogItemRow, taxCode string, content *ProductContent) (int, error) {
+	if item.ParentSKU == nil {
+		p, err := createProduct(ctx, tx, userUUID, nil, *item.Path, item.SKU, *item.Name, taxCode, content)
+		if err != nil {
+			return 0, err
+		}
+		return p.id, nil
+	}
+
+	q1 := "SELECT id, uuid, parent_id FROM product WHERE sku = $1"
+	var parentID int
+	var parentUUID string
+	var grandParentID *int
+	err := tx.QueryRowContext(ctx, q1, *item.ParentSKU).Scan(&parentID, &parentUUID, &grandParentID)
+	if err == sql.ErrNoRows {
+		return 0, ErrParentProductNotFound
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if grandParentID != nil {
+		return 0, ErrProductIsVariant
+	}
+	v, err := createVariant(ctx, tx, userUUID, parentID, parentUUID, *item.Path, item.SKU, *item.Name, taxCode, content, item.Options)
+	if err != nil {
+		return 0, err
+	}
+	return v.id, nil
+}
+
+// updateCatalogProduct updates the fields of the product that are set in
+// the item.
+func updateCatalogProduct(ctx context.Context, tx *sql.Tx, productID int, item *CatalogItemRow) error {
+	if item.TaxCode != nil {
+		if err := checkTaxCode(ctx, tx, *item.TaxCode); err != nil {
+			return err
+		}
+	}
+
+	var oldPath string
+	if item.Path != nil {
+		q1 := `
+			SELECT
+			  path,
+			  EXISTS(SELECT 1 FROM product WHERE path = $1 AND id != $2) AS exists
+			FROM product
+			WHERE id = $2
+		`
+		var exists bool
+		if err := tx.QueryRowContext(ctx, q1, *item.Path, productID).Scan(&oldPath, &exists); err != nil {
+			return errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+		}
+		if exists {
+			return ErrProductPathExists
+		}
+	}
+
+	// attributes are only replaced if set.
+	var attributes interface{}
+	if item.Attributes != nil {
+		attributes = item.Attributes
+	}
+	q2 := `
+		UPDATE product
+		SET
+		  path = COALESCE($2, path),
+		  name = COALESCE($3, name),
+		  tax_code = COALESCE($4, tax_code),
+		  description = COALESCE($5, description),
+		  attributes = COALESCE($6, attributes),
+		  meta_title = COALESCE($7, meta_title),
+		  meta_description = COALESCE($8, meta_description),
+		  status = COALESCE($9, status),
+		  modified = NOW()
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, q2, productID, item.Path, item.Name, item.TaxCode,
+		item.Description, attributes, item.MetaTitle, item.MetaDescription, item.Status); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+	}
+
+	if item.Path != nil && *item.Path != oldPath {
+		if err := addProductRedirect(ctx, tx, productID, oldPath, *item.Path); err != nil {
+			return errors.Wrapf(err, "postgres: addProductRedirect(ctx, tx, productID=%d, oldPath=%q, newPath=%q) failed", productID, oldPath, *item.Path)
+		}
+	}
+	return nil
+}
+
+// setCatalogCategories replaces the categories of a product with the
+// leaf categories with the given paths. Existing associations are kept
+// so products keep their place in the categories.
+func setCatalogCategories(ctx context.Context, tx *sql.Tx, productID int, paths []string) error {
+	q1 := `
+		DELETE FROM product_category AS pc
+		USING category AS c
+		WHERE pc.category_id = c.id AND pc.product_id = $1 AND NOT (c.path = ANY($2))
+	`
+	if _, err := tx.ExecContext(ctx, q1, productID, pq.Array(paths)); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+
+	q2 := "SELECT id FROM category WHERE path = $1 AND lft = rgt - 1"
+	q3 := `
+		INSERT INTO product_category (product_id, category_id, pri)
+		VALUES ($1, $2, (
+		  SELECT COALESCE(MAX(pri), 0) + 10 FROM product_category WHERE category_id = $2
+		))
+		ON CONFLICT (product_id, category_id) DO NOTHING
+	`
+	for _, path := range paths {
+		var categoryID int
+		err := tx.QueryRowContext(ctx, q2, path).Scan(&categoryID)
+		if err == sql.ErrNoRows {
+			return ErrLeafCategoryNotFound
+		}
+		if err != nil {
+			return errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+		}
+		if _, err := tx.ExecContext(ctx, q3, productID, categoryID); err != nil {
+			return errors.Wrapf(err, "postgres: exec context q3=%q", q3)
+		}
+	}
+	return nil
+}
+
+// setCatalogImages replaces the images of a product with the images at
+// the given paths in order. Images already at one of the paths are kept.
+func setCatalogImages(ctx context.Context, tx *sql.Tx, productID int, paths []string) error {
+	q1 := "DELETE FROM image WHERE product_id = $1 AND NOT (path = ANY($2))"
+	if _, err := tx.ExecContext(ctx, q1, productID, pq.Array(paths)); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+
+	q2 := "UPDATE image SET pri = $3, modified = NOW() WHERE product_id = $1 AND path = $2"
+	q3 := `
+		INSERT INTO image (
+		  product_id, w, h, path, typ, ori, up, pri, size, q, gsurl, created, modified
+		) VALUES (
+		  $1, 99999999, 99999999, $2, 'image/jpeg', true, false, $3, 0, 100, $4, NOW(), NOW()
+		)
+	`
+	for i, path := range paths {
+		pri := (i + 1) * 10
+		res, err := tx.ExecContext(ctx, q2, productID, path, pri)
+		if err != nil {
+			return errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "postgres: res.RowsAffected()")
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, q3, productID, path, pri, "gs://"+path); err != nil {
+			return errors.Wrapf(err, "postgres: exec context q3=%q", q3)
+		}
+	}
+	return nil
+}
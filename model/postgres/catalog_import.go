@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Catalog import status values. Imports with validation errors are
+// invalid and are never applied. Failed imports can be resumed.
+const (
+	CatalogImportStatusInvalid    = "invalid"
+	CatalogImportStatusPending    = "pending"
+	CatalogImportStatusProcessing = "processing"
+	CatalogImportStatusCompleted  = "completed"
+	CatalogImportStatusFailed     = "failed"
+)
+
+// ErrCatalogImportNotFound error
+var ErrCatalogImportNotFound = errors.New("postgres: catalog import not found")
+
+// ErrCatalogImportNotResumable is returned when applying an import that
+// is invalid or has already completed.
+var ErrCatalogImportNotResumable = errors.New("postgres: catalog import not resumable")
+
+// CatalogItemError is returned when a row of a catalog import could not
+// be applied. Row is the 1-based row number in the import.
+type CatalogItemError struct {
+	Row int
+	SKU string
+	Err error
+}
+
+func (e *CatalogItemError) Error() string {
+	return fmt.Sprintf("postgres: catalog import row %d sku %q: %v", e.Row, e.SKU, e.Err)
+}
+
+// CatalogImportErrorRow describes a validation error of a single row of
+// a catalog import.
+type CatalogImportErrorRow struct {
+	Row     int    `json:"row"`
+	SKU     string `json:"sku"`
+	Message string `json:"message"`
+}
+
+// CatalogImportRow maps to a catalog_import row without its data.
+// Processed is the number of rows applied.
+type CatalogImportRow struct {
+	id           int
+	UUID         string
+	Format       string
+	Status       string
+	Total        int
+	Processed    int
+	Errors       []*CatalogImportErrorRow
+	ErrorMessage *string
+	Created      time.Time
+	Modified     time.Time
+}
+
+const catalogImportColumns = "id, uuid, format, status, total, processed, errors, error_message, created, modified"
+
+func scanCatalogImport(row *sql.Row) (*CatalogImportRow, error) {
+	var c CatalogImportRow
+	var rowErrors []byte
+	if err := row.Scan(&c.id, &c.UUID, &c.Format, &c.Status, &c.Total, &c.Processed,
+		&rowErrors, &c.ErrorMessage, &c.Created, &c.Modified); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rowErrors, &c.Errors); err != nil {
+		return nil, errors.Wrap(err, "postgres: json unmarshal of catalog import errors failed")
+	}
+	return &c, nil
+}
+
+// CreateCatalogImport stores the items of a catalog import along with
+// any validation errors. Imports with errors should be created with the
+// invalid status.
+func (m *PgModel) CreateCatalogImport(ctx context.Context, format, status string, items []*CatalogItemRow, rowErrors []*CatalogImportErrorRow) (*CatalogImportRow, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, errors.Wrap(err, "postgres: json marshal of catalog items failed")
+	}
+	if rowErrors == nil {
+		rowErrors = []*CatalogImportErrorRow{}
+	}
+	errs, err := json.Marshal(rowErrors)
+	if err != nil {
+		return nil, errors.Wrap(err, "postgres: json marshal of catalog import errors failed")
+	}
+
+	q1 := `
+		INSERT INTO catalog_import (format, status, data, total, errors, created, modified)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING ` + catalogImportColumns
+	c, err := scanCatalogImport(m.db.QueryRowContext(ctx, q1, format, status, data, len(items), errs))
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return c, nil
+}
+
+// GetCatalogImport returns the catalog import with the given uuid.
+func (m *PgModel) GetCatalogImport(ctx context.Context, catalogImportUUID string) (*CatalogImportRow, error) {
+	q1 := "SELECT " + catalogImportColumns + " FROM catalog_import WHERE uuid = $1"
+	c, err := scanCatalogImport(m.db.QueryRowContext(ctx, q1, catalogImportUUID))
+	if err == sql.ErrNoRows {
+		return nil, ErrCatalogImportNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return c, nil
+}
+
+// ApplyCatalogImportChunk applies up to size rows of a catalog import
+// following the rows already processed. The rows and the new processed
+// count are committed together so an import can be resumed after a
+// failure. The import is locked while the chunk is applied. Changes to
+// onhand are recorded as stock takes by the user with the given usrUUID
+// and any stock alerts raised are returned. If a row cannot be applied
+// the chunk is rolled back and a *CatalogItemError is returned.
+func (m *PgModel) ApplyCatalogImportChunk(ctx context.Context, catalogImportUUID string, size int, usrUUID *string) (*CatalogImportRow, []*StockAlertRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: ApplyCatalogImportChunk(ctx, catalogImportUUID=%q, size=%d) started", catalogImportUUID, size)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: db.BeginTx failed")
+	}
+
+	// 1. Lock the import.
+	q1 := "SELECT id, status, total, processed FROM catalog_import WHERE uuid = $1 FOR UPDATE"
+	var catalogImportID, total, processed int
+	var status string
+	err = tx.QueryRowContext(ctx, q1, catalogImportUUID).Scan(&catalogImportID, &status, &total, &processed)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil, ErrCatalogImportNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if status == CatalogImportStatusInvalid || status == CatalogImportStatusCompleted {
+		tx.Rollback()
+		return nil, nil, ErrCatalogImportNotResumable
+	}
+
+	// 2. Read the next chunk of rows.
+	q2 := `
+		SELECT e.value
+		FROM catalog_import AS c, jsonb_array_elements(c.data) WITH ORDINALITY AS e(value, n)
+		WHERE c.id = $1 AND e.n > c.processed
+		ORDER BY e.n
+		LIMIT $2
+	`
+	rows, err := tx.QueryContext(ctx, q2, catalogImportID, size)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, errors.Wrapf(err, "postgres: query context q2=%q", q2)
+	}
+	items := make([]*CatalogItemRow, 0, size)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		var item CatalogItemRow
+		if err := json.Unmarshal(data, &item); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, nil, errors.Wrap(err, "postgres: json unmarshal of catalog item failed")
+		}
+		items = append(items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	rows.Close()
+
+	// 3. Apply the rows.
+	changes := newStockChanges()
+	for i, item := range items {
+		if err := applyCatalogItem(ctx, tx, changes, item, usrUUID); err != nil {
+			tx.Rollback()
+			return nil, nil, &CatalogItemError{Row: processed + i + 1, SKU: item.SKU, Err: err}
+		}
+	}
+	alerts, err := changes.alerts(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	// 4. Record the progress.
+	processed += len(items)
+	status = CatalogImportStatusProcessing
+	if processed >= total {
+		status = CatalogImportStatusCompleted
+	}
+	q3 := `
+		UPDATE catalog_import
+		SET processed = $2, status = $3, error_message = NULL, modified = NOW()
+		WHERE id = $1
+		RETURNING ` + catalogImportColumns
+	c, err := scanCatalogImport(tx.QueryRowContext(ctx, q3, catalogImportID, processed, status))
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	return c, alerts, nil
+}
+
+// FailCatalogImport sets the status of a catalog import to failed along
+// with the reason.
+func (m *PgModel) FailCatalogImport(ctx context.Context, catalogImportUUID, message string) (*CatalogImportRow, error) {
+	q1 := `
+		UPDATE catalog_import
+		SET status = $2, error_message = $3, modified = NOW()
+		WHERE uuid = $1
+		RETURNING ` + catalogImportColumns
+	c, err := scanCatalogImport(m.db.QueryRowContext(ctx, q1, catalogImportUUID, CatalogImportStatusFailed, message))
+	if err == sql.ErrNoRows {
+		return nil, ErrCatalogImportNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return c, nil
+}
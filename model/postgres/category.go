@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/pkg/errors"
@@ -19,18 +20,89 @@ var ErrLeafCategoryNotFound = errors.New("postgres: category not found")
 // ErrCategoriesInUse error
 var ErrCategoriesInUse = errors.New("postgres: categories in use")
 
+// Category product sort values. Manual orders the products of a leaf
+// category by product_category.pri.
+const (
+	CategoryProductSortManual      = "manual"
+	CategoryProductSortPriceAsc    = "price_asc"
+	CategoryProductSortPriceDesc   = "price_desc"
+	CategoryProductSortNewest      = "newest"
+	CategoryProductSortBestselling = "bestselling"
+)
+
 // A CategoryRow represents a single row from the category table.
 type CategoryRow struct {
-	id       int
-	UUID     string
-	Segment  string
-	Path     string
-	Name     string
-	Lft      int
-	Rgt      int
-	Depth    int
-	Created  time.Time
-	Modified time.Time
+	id              int
+	UUID            string
+	Segment         string
+	Path            string
+	Name            string
+	Lft             int
+	Rgt             int
+	Depth           int
+	Description     string
+	HeroImage       string
+	MetaTitle       string
+	MetaDescription string
+	Hidden          bool
+	ProductSort     string
+	Created         time.Time
+	Modified        time.Time
+}
+
+// CategoryUpdate contains the changes to a category. Nil values are left
+// unchanged.
+type CategoryUpdate struct {
+	Segment         *string
+	Name            *string
+	Description     *string
+	HeroImage       *string
+	MetaTitle       *string
+	MetaDescription *string
+	Hidden          *bool
+	ProductSort     *string
+}
+
+// apply sets the name and metadata of c that are set in cu. The segment
+// is left to the caller as it changes the path of the descendants.
+func (cu *CategoryUpdate) apply(c *CategoryRow) {
+	if cu.Name != nil {
+		c.Name = *cu.Name
+	}
+	if cu.Description != nil {
+		c.Description = *cu.Description
+	}
+	if cu.HeroImage != nil {
+		c.HeroImage = *cu.HeroImage
+	}
+	if cu.MetaTitle != nil {
+		c.MetaTitle = *cu.MetaTitle
+	}
+	if cu.MetaDescription != nil {
+		c.MetaDescription = *cu.MetaDescription
+	}
+	if cu.Hidden != nil {
+		c.Hidden = *cu.Hidden
+	}
+	if cu.ProductSort != nil {
+		c.ProductSort = *cu.ProductSort
+	}
+}
+
+// categoryColumns are the columns of a category row in the order read
+// by scanCategory.
+const categoryColumns = `
+	id, uuid, segment, path, name, lft, rgt, depth, description, hero_image,
+	meta_title, meta_description, hidden, product_sort, created, modified`
+
+func scanCategory(row interface{ Scan(...interface{}) error }) (*CategoryRow, error) {
+	var c CategoryRow
+	if err := row.Scan(&c.id, &c.UUID, &c.Segment, &c.Path, &c.Name, &c.Lft, &c.Rgt, &c.Depth,
+		&c.Description, &c.HeroImage, &c.MetaTitle, &c.MetaDescription, &c.Hidden, &c.ProductSort,
+		&c.Created, &c.Modified); err != nil {
+		return nil, err
+	}
+	return &c, nil
 }
 
 // BatchCreateNestedSet creates a nested set of nodes representing the
@@ -94,18 +166,15 @@ func (m *PgModel) BatchCreateNestedSet(ctx context.Context, ns []*CategoryRow) e
 
 // GetCategoryByPath retrieves a single set element by the given path.
 func (m *PgModel) GetCategoryByPath(ctx context.Context, path string) (*CategoryRow, error) {
-	query := `
-		SELECT
-		  id, uuid, segment, path, name, lft, rgt, depth, created, modified
-		FROM category
-		WHERE path = $1
-	`
-	var n CategoryRow
-	row := m.db.QueryRowContext(ctx, query, path)
-	if err := row.Scan(&n.id, &n.UUID, &n.Segment, &n.Path, &n.Name, &n.Lft, &n.Rgt, &n.Depth, &n.Created, &n.Modified); err != nil {
+	query := "SELECT " + categoryColumns + " FROM category WHERE path = $1"
+	n, err := scanCategory(m.db.QueryRowContext(ctx, query, path))
+	if err == sql.ErrNoRows {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
 		return nil, errors.Wrapf(err, "service: query row ctx scan query=%q", query)
 	}
-	return &n, nil
+	return n, nil
 }
 
 // HasCatalog returns true if any rows exist in the category table.
@@ -124,12 +193,7 @@ func (m *PgModel) HasCatalog(ctx context.Context) (bool, error) {
 
 // GetCategories returns a slice of CategoryRow representing the catalog as a nested set.
 func (m *PgModel) GetCategories(ctx context.Context) ([]*CategoryRow, error) {
-	query := `
-		SELECT
-		  id, uuid, segment, path, name, lft, rgt, depth, created, modified
-		FROM category
-		ORDER BY lft ASC
-	`
+	query := "SELECT " + categoryColumns + " FROM category ORDER BY lft ASC"
 	rows, err := m.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, errors.Wrapf(err, "query context query=%q", query)
@@ -138,11 +202,11 @@ func (m *PgModel) GetCategories(ctx context.Context) ([]*CategoryRow, error) {
 
 	nodes := make([]*CategoryRow, 0, 256)
 	for rows.Next() {
-		var n CategoryRow
-		if err = rows.Scan(&n.id, &n.UUID, &n.Segment, &n.Path, &n.Name, &n.Lft, &n.Rgt, &n.Depth, &n.Created, &n.Modified); err != nil {
+		n, err := scanCategory(rows)
+		if err != nil {
 			return nil, err
 		}
-		nodes = append(nodes, &n)
+		nodes = append(nodes, n)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// hiddenCategoryCondition is true for the category aliased as c if it or
+// any of its ancestors is hidden.
+const hiddenCategoryCondition = `
+	EXISTS (
+	  SELECT 1 FROM category AS h
+	  WHERE h.hidden AND h.lft <= c.lft AND h.rgt >= c.rgt
+	)`
+
+func (m *PgModel) queryCategories(ctx context.Context, query string, args ...interface{}) ([]*CategoryRow, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query context query=%q", query)
+	}
+	defer rows.Close()
+
+	categories := make([]*CategoryRow, 0, 16)
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return categories, nil
+}
+
+// GetCategoryAncestors returns the ancestors of a category from the root
+// category down to its parent.
+func (m *PgModel) GetCategoryAncestors(ctx context.Context, c *CategoryRow) ([]*CategoryRow, error) {
+	q1 := "SELECT " + categoryColumns + " FROM category WHERE lft < $1 AND rgt > $2 ORDER BY lft ASC"
+	return m.queryCategories(ctx, q1, c.Lft, c.Rgt)
+}
+
+// GetCategoryChildren returns the immediate children of a category in
+// order.
+func (m *PgModel) GetCategoryChildren(ctx context.Context, c *CategoryRow) ([]*CategoryRow, error) {
+	q1 := "SELECT " + categoryColumns + " FROM category WHERE lft > $1 AND rgt < $2 AND depth = $3 ORDER BY lft ASC"
+	return m.queryCategories(ctx, q1, c.Lft, c.Rgt, c.Depth+1)
+}
+
+// subtreeProductSort returns the column expression and direction of the
+// default order of the products in the subtree of a category given by its
+// product sort. Manual sorts by the lowest pri of the product in the
+// subtree. Price sorts use the unit price for a quantity of one, or the
+// offer price if lower, in the price list with the given priceListUUID
+// and put products without a price last. Bestselling sorts by the total
+// quantity ordered.
+func subtreeProductSort(c *CategoryRow, priceListUUID string) (string, OrderDirection) {
+	price := `(
+		  SELECT MIN(COALESCE(pr.offer_price, pr.unit_price))
+		  FROM price AS pr
+		  INNER JOIN price_list AS pl
+		    ON pl.id = pr.price_list_id
+		  WHERE pr.product_id = p.id AND pr.break = 1 AND pl.uuid = ` + pq.QuoteLiteral(priceListUUID) + `
+		)`
+	switch c.ProductSort {
+	case CategoryProductSortPriceAsc:
+		return "COALESCE(" + price + ", 2147483647)", OrderAsc
+	case CategoryProductSortPriceDesc:
+		return "COALESCE(" + price + ", -1)", OrderDesc
+	case CategoryProductSortNewest:
+		return "p.created", OrderDesc
+	case CategoryProductSortBestselling:
+		return `(
+		  SELECT COALESCE(SUM(oi.qty), 0)
+		  FROM order_item AS oi
+		  WHERE oi.sku = p.sku
+		)`, OrderDesc
+	}
+	return fmt.Sprintf(`(
+		  SELECT MIN(r.pri)
+		  FROM product_category AS r
+		  INNER JOIN category AS c
+		    ON c.id = r.category_id
+		  WHERE r.product_id = p.id AND c.lft >= %d AND c.rgt <= %d
+		)`, c.Lft, c.Rgt), OrderAsc
+}
+
+// GetCategorySubtreeProducts returns a page of the products in the leaf
+// categories of the subtree of a category in the product sort of the
+// category by default. Price sorts use prices from the price list with
+// the given priceListUUID. If publishedOnly is true only published
+// products are returned. If visibleOnly is true products that are only in
+// hidden categories are left out.
+func (m *PgModel) GetCategorySubtreeProducts(ctx context.Context, c *CategoryRow, priceListUUID string, opts *ListOptions, publishedOnly, visibleOnly bool) ([]*ProductRow, *ListContext, error) {
+	orderBy, orderDir := subtreeProductSort(c, priceListUUID)
+	q := listQuery{
+		sel: `
+		  p.id, p.parent_id, p.uuid, pp.uuid, p.sku, p.path, p.name, p.tax_code,
+		  p.description, p.attributes, p.meta_title, p.meta_description,
+		  p.status, p.publish_at, p.unpublish_at, p.created, p.modified`,
+		from:  "product AS p LEFT OUTER JOIN product AS pp ON pp.id = p.parent_id",
+		alias: "p",
+		fields: map[string]string{
+			"sku":      "p.sku",
+			"path":     "p.path",
+			"name":     "p.name",
+			"created":  "p.created",
+			"modified": "p.modified",
+		},
+		orderBy:  orderBy,
+		orderDir: orderDir,
+	}
+	subtree := `
+		EXISTS (
+		  SELECT 1 FROM product_category AS r
+		  INNER JOIN category AS c
+		    ON c.id = r.category_id
+		  WHERE r.product_id = p.id AND c.lft >= %s AND c.rgt <= %s`
+	if visibleOnly {
+		subtree = subtree + " AND NOT " + hiddenCategoryCondition
+	}
+	q.where(subtree+")", c.Lft, c.Rgt)
+	if publishedOnly {
+		q.where("(" + publishedCondition + ")")
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	query, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) query=%q failed", query)
+	}
+	defer rows.Close()
+
+	products := make([]*ProductRow, 0, 64)
+	for rows.Next() {
+		var p ProductRow
+		if err := rows.Scan(&p.id, &p.parentID, &p.UUID, &p.ParentUUID, &p.SKU, &p.Path, &p.Name, &p.TaxCode,
+			&p.Description, &p.Attributes, &p.MetaTitle, &p.MetaDescription,
+			&p.Status, &p.PublishAt, &p.UnpublishAt, &p.Created, &p.Modified); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		products = append(products, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err() failed")
+	}
+	return products, opts.page(&products), nil
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubtreeProductSort(t *testing.T) {
+	c := &CategoryRow{Lft: 2, Rgt: 9, ProductSort: CategoryProductSortManual}
+	orderBy, dir := subtreeProductSort(c, "p1")
+	assert.Contains(t, orderBy, "MIN(r.pri)")
+	assert.Contains(t, orderBy, "c.lft >= 2 AND c.rgt <= 9")
+	assert.Equal(t, OrderAsc, dir)
+
+	c.ProductSort = CategoryProductSortPriceAsc
+	orderBy, dir = subtreeProductSort(c, "p1")
+	assert.Contains(t, orderBy, "pl.uuid = 'p1'")
+	assert.Equal(t, OrderAsc, dir)
+
+	c.ProductSort = CategoryProductSortPriceDesc
+	orderBy, dir = subtreeProductSort(c, "p1")
+	assert.Contains(t, orderBy, ", -1)")
+	assert.Equal(t, OrderDesc, dir)
+
+	c.ProductSort = CategoryProductSortNewest
+	orderBy, dir = subtreeProductSort(c, "p1")
+	assert.Equal(t, "p.created", orderBy)
+	assert.Equal(t, OrderDesc, dir)
+
+	c.ProductSort = CategoryProductSortBestselling
+	orderBy, dir = subtreeProductSort(c, "p1")
+	assert.Contains(t, orderBy, "SUM(oi.qty)")
+	assert.Equal(t, OrderDesc, dir)
+}
@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCategoryProductsMismatch is returned when reordering the products of
+// a category with a list that is not exactly the products of the category.
+var ErrCategoryProductsMismatch = errors.New("postgres: category products mismatch")
+
+// GetCategoryProducts returns the products of every leaf category ordered
+// by category lft and then by the product sort of each category. Price
+// sorts use the unit price for a quantity of one, or the offer price if
+// lower, in the price list with the given priceListUUID. Bestselling
+// sorts by the total quantity ordered. Ties are broken by pri. If
+// publishedOnly is true unpublished products are left out.
+func (m *PgModel) GetCategoryProducts(ctx context.Context, priceListUUID *string, publishedOnly bool) ([]*ProductCategoryJoinRow, error) {
+	var where string
+	if publishedOnly {
+		where = "WHERE (" + publishedCondition + ")"
+	}
+	q1 := `
+		SELECT
+		  r.id, r.uuid, c.id, c.uuid, c.path,
+		  p.id, p.uuid, p.sku, p.path, p.name, p.created, p.modified,
+		  r.pri, r.created, r.modified
+		FROM product_category AS r
+		INNER JOIN category AS c
+		  ON c.id = r.category_id
+		INNER JOIN product AS p
+		  ON p.id = r.product_id
+		LEFT JOIN (
+		  SELECT pr.product_id, MIN(COALESCE(pr.offer_price, pr.unit_price)) AS price
+		  FROM price AS pr
+		  INNER JOIN price_list AS pl
+		    ON pl.id = pr.price_list_id
+		  WHERE pl.uuid = $1 AND pr.break = 1
+		  GROUP BY pr.product_id
+		) AS pp
+		  ON pp.product_id = p.id
+		LEFT JOIN (
+		  SELECT sku, SUM(qty) AS sold
+		  FROM order_item
+		  GROUP BY sku
+		) AS s
+		  ON s.sku = p.sku
+		` + where + `
+		ORDER BY
+		  c.lft ASC,
+		  CASE WHEN c.product_sort = 'price_asc' THEN pp.price END ASC NULLS LAST,
+		  CASE WHEN c.product_sort = 'price_desc' THEN pp.price END DESC NULLS LAST,
+		  CASE WHEN c.product_sort = 'newest' THEN p.created END DESC,
+		  CASE WHEN c.product_sort = 'bestselling' THEN s.sold END DESC NULLS LAST,
+		  r.pri ASC
+	`
+	rows, err := m.db.QueryContext(ctx, q1, priceListUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query context q1=%q", q1)
+	}
+	defer rows.Close()
+
+	cpas := make([]*ProductCategoryJoinRow, 0, 256)
+	for rows.Next() {
+		var n ProductCategoryJoinRow
+		if err := rows.Scan(&n.id, &n.UUID, &n.categoryID, &n.CategoryUUID, &n.CategoryPath,
+			&n.productID, &n.ProductUUID, &n.ProductSKU, &n.ProductPath, &n.ProductName,
+			&n.ProductCreated, &n.ProductModified, &n.Pri, &n.Created, &n.Modified); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		cpas = append(cpas, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return cpas, nil
+}
+
+// ReorderCategoryProducts sets the pri of the products of a leaf category
+// to the order of productUUIDs. productUUIDs must hold each product of
+// the category exactly once.
+func (m *PgModel) ReorderCategoryProducts(ctx context.Context, categoryUUID string, productUUIDs []string) ([]*ProductCategoryJoinRow, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "postgres: db.BeginTx failed")
+	}
+
+	// 1. Check the category exists and is a leaf.
+	q1 := "SELECT id, lft, rgt FROM category WHERE uuid = $1 FOR SHARE"
+	var categoryID, lft, rgt int
+	err = tx.QueryRowContext(ctx, q1, categoryUUID).Scan(&categoryID, &lft, &rgt)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if lft != rgt-1 {
+		tx.Rollback()
+		return nil, ErrCategoryNotLeaf
+	}
+
+	// 2. Get the product to category relations keyed by product uuid.
+	q2 := `
+		SELECT r.id, p.uuid
+		FROM product_category AS r
+		INNER JOIN product AS p
+		  ON p.id = r.product_id
+		WHERE r.category_id = $1
+		FOR UPDATE OF r
+	`
+	rows, err := tx.QueryContext(ctx, q2, categoryID)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "postgres: query context q2=%q", q2)
+	}
+	relations := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var productUUID string
+		if err := rows.Scan(&id, &productUUID); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		relations[productUUID] = id
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	rows.Close()
+
+	if len(productUUIDs) != len(relations) {
+		tx.Rollback()
+		return nil, ErrCategoryProductsMismatch
+	}
+	seen := make(map[string]bool, len(productUUIDs))
+	for _, id := range productUUIDs {
+		if _, ok := relations[id]; !ok || seen[id] {
+			tx.Rollback()
+			return nil, ErrCategoryProductsMismatch
+		}
+		seen[id] = true
+	}
+
+	// 3. Renumber pri in steps of 10 in the new order.
+	q3 := "UPDATE product_category SET pri = $2, modified = NOW() WHERE id = $1"
+	for i, id := range productUUIDs {
+		if _, err := tx.ExecContext(ctx, q3, relations[id], (i+1)*10); err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "postgres: exec context q3=%q", q3)
+		}
+	}
+
+	// 4. Return the relations in their new order.
+	q4 := `
+		SELECT
+		  r.id, r.uuid, c.id, c.uuid, c.path,
+		  p.id, p.uuid, p.sku, p.path, p.name, p.created, p.modified,
+		  r.pri, r.created, r.modified
+		FROM product_category AS r
+		INNER JOIN category AS c
+		  ON c.id = r.category_id
+		INNER JOIN product AS p
+		  ON p.id = r.product_id
+		WHERE r.category_id = $1
+		ORDER BY r.pri ASC
+	`
+	rows, err = tx.QueryContext(ctx, q4, categoryID)
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "postgres: query context q4=%q", q4)
+	}
+	cpas := make([]*ProductCategoryJoinRow, 0, len(productUUIDs))
+	for rows.Next() {
+		var n ProductCategoryJoinRow
+		if err := rows.Scan(&n.id, &n.UUID, &n.categoryID, &n.CategoryUUID, &n.CategoryPath,
+			&n.productID, &n.ProductUUID, &n.ProductSKU, &n.ProductPath, &n.ProductName,
+			&n.ProductCreated, &n.ProductModified, &n.Pri, &n.Created, &n.Modified); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		cpas = append(cpas, &n)
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	return cpas, nil
+}
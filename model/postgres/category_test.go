@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryUpdateApply(t *testing.T) {
+	name := "Security Systems"
+	hidden := true
+	sort := CategoryProductSortNewest
+	c := CategoryRow{
+		Segment:     "security",
+		Name:        "Security",
+		MetaTitle:   "Security",
+		ProductSort: CategoryProductSortManual,
+	}
+	cu := CategoryUpdate{Name: &name, Hidden: &hidden, ProductSort: &sort}
+	cu.apply(&c)
+	assert.Equal(t, CategoryRow{
+		Segment:     "security",
+		Name:        "Security Systems",
+		MetaTitle:   "Security",
+		Hidden:      true,
+		ProductSort: CategoryProductSortNewest,
+	}, c)
+}
@@ -0,0 +1,453 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// ErrCategoryPathExists is returned when a category would have the same
+// segment as one of its siblings.
+var ErrCategoryPathExists = errors.New("postgres: category path exists")
+
+// ErrCategoryHasProducts is returned when adding children to or deleting
+// a leaf category that has products.
+var ErrCategoryHasProducts = errors.New("postgres: category has products")
+
+// ErrCategoryRootExists is returned when creating a category without a
+// parent once the root category exists.
+var ErrCategoryRootExists = errors.New("postgres: category root exists")
+
+// ErrCategoryMoveInvalid is returned when moving the root category or
+// moving a category under itself or one of its descendants.
+var ErrCategoryMoveInvalid = errors.New("postgres: category move invalid")
+
+// categoryNode is a category in a tree of categories being edited.
+type categoryNode struct {
+	row      *CategoryRow
+	parent   *categoryNode
+	children []*categoryNode
+}
+
+// categoryTree is the tree of categories being edited.
+type categoryTree struct {
+	root   *categoryNode
+	byUUID map[string]*categoryNode
+}
+
+// newCategoryTree builds the tree of a nested set of categories ordered
+// by lft.
+func newCategoryTree(rows []*CategoryRow) (*categoryTree, error) {
+	t := categoryTree{byUUID: make(map[string]*categoryNode, len(rows))}
+	stack := make([]*categoryNode, 0, 16)
+	for _, row := range rows {
+		n := &categoryNode{row: row}
+		for len(stack) > 0 && stack[len(stack)-1].row.Rgt < row.Lft {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			n.parent = stack[len(stack)-1]
+			n.parent.children = append(n.parent.children, n)
+		} else if t.root != nil {
+			return nil, errors.Errorf("postgres: category %q is a second root", row.Path)
+		} else {
+			t.root = n
+		}
+		stack = append(stack, n)
+		t.byUUID[row.UUID] = n
+	}
+	return &t, nil
+}
+
+// renumber sets the lft, rgt, depth and path of the node and its
+// descendants using a preorder traversal returning the next lft.
+func (n *categoryNode) renumber(lft, depth int, path string) int {
+	n.row.Lft, n.row.Depth, n.row.Path = lft, depth, path
+	next := lft + 1
+	for _, c := range n.children {
+		next = c.renumber(next, depth+1, path+"/"+c.row.Segment)
+	}
+	n.row.Rgt = next
+	return next + 1
+}
+
+// isLeaf returns true if the node has no children.
+func (n *categoryNode) isLeaf() bool {
+	return len(n.children) == 0
+}
+
+// contains returns true if c is n or one of its descendants.
+func (n *categoryNode) contains(c *categoryNode) bool {
+	for ; c != nil; c = c.parent {
+		if c == n {
+			return true
+		}
+	}
+	return false
+}
+
+// hasChildSegment returns true if a child of n other than except has
+// the given segment.
+func (n *categoryNode) hasChildSegment(segment string, except *categoryNode) bool {
+	for _, c := range n.children {
+		if c != except && c.row.Segment == segment {
+			return true
+		}
+	}
+	return false
+}
+
+// insertChild adds c to the children of n at the given position or at
+// the end if position is nil or past the last child.
+func (n *categoryNode) insertChild(c *categoryNode, position *int) {
+	c.parent = n
+	i := len(n.children)
+	if position != nil && *position >= 0 && *position < i {
+		i = *position
+	}
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = c
+}
+
+// removeChild removes c from the children of n.
+func (n *categoryNode) removeChild(c *categoryNode) {
+	for i, child := range n.children {
+		if child == c {
+			n.children = append(n.children[:i], n.children[i+1:]...)
+			break
+		}
+	}
+	c.parent = nil
+}
+
+// preorder returns the nodes of the tree in lft order.
+func (t *categoryTree) preorder() []*categoryNode {
+	nodes := make([]*categoryNode, 0, len(t.byUUID))
+	var walk func(n *categoryNode)
+	walk = func(n *categoryNode) {
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	if t.root != nil {
+		walk(t.root)
+	}
+	return nodes
+}
+
+// editCategoryTree locks the categories, passes the tree to edit and
+// then writes the categories edit added or changed. Categories keep
+// their ids so product to category associations and promo rules are
+// preserved.
+func (m *PgModel) editCategoryTree(ctx context.Context, edit func(tx *sql.Tx, t *categoryTree) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "postgres: db.BeginTx failed")
+	}
+
+	// 1. Lock the categories against concurrent edits.
+	q1 := "LOCK TABLE category IN SHARE ROW EXCLUSIVE MODE"
+	if _, err := tx.ExecContext(ctx, q1); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+
+	// 2. Build the tree of categories.
+	q2 := "SELECT " + categoryColumns + " FROM category ORDER BY lft ASC"
+	rows, err := tx.QueryContext(ctx, q2)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: query context q2=%q", q2)
+	}
+	cats := make([]*CategoryRow, 0, 256)
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return errors.Wrap(err, "postgres: scan failed")
+		}
+		cats = append(cats, c)
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "postgres: rows.Err()")
+	}
+	rows.Close()
+
+	t, err := newCategoryTree(cats)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	before := make(map[int]CategoryRow, len(cats))
+	for _, c := range cats {
+		before[c.id] = *c
+	}
+
+	// 3. Edit the tree and renumber the nested set.
+	if err := edit(tx, t); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if t.root != nil {
+		t.root.renumber(1, 0, t.root.row.Segment)
+	}
+
+	// 4. Write the changes. The changed categories are first moved out of
+	// the way of the unique lft, rgt and path constraints. Paths ending
+	// in a slash are never used by a category.
+	var changed, added []*CategoryRow
+	for _, n := range t.preorder() {
+		b, ok := before[n.row.id]
+		if !ok {
+			added = append(added, n.row)
+			continue
+		}
+		if b != *n.row {
+			changed = append(changed, n.row)
+		}
+	}
+	if len(changed) > 0 {
+		ids := make([]int64, 0, len(changed))
+		for _, c := range changed {
+			ids = append(ids, int64(c.id))
+		}
+		q3 := "UPDATE category SET lft = -lft, rgt = -rgt, path = uuid::text || '/' WHERE id = ANY($1)"
+		if _, err := tx.ExecContext(ctx, q3, pq.Array(ids)); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "postgres: exec context q3=%q", q3)
+		}
+
+		q4 := `
+			UPDATE category
+			SET
+			  segment = $2, path = $3, name = $4, lft = $5, rgt = $6, depth = $7,
+			  description = $8, hero_image = $9, meta_title = $10, meta_description = $11,
+			  hidden = $12, product_sort = $13, modified = NOW()
+			WHERE id = $1
+			RETURNING modified
+		`
+		for _, c := range changed {
+			if err := tx.QueryRowContext(ctx, q4, c.id, c.Segment, c.Path, c.Name, c.Lft, c.Rgt, c.Depth,
+				c.Description, c.HeroImage, c.MetaTitle, c.MetaDescription, c.Hidden, c.ProductSort).Scan(&c.Modified); err != nil {
+				tx.Rollback()
+				return errors.Wrapf(err, "postgres: query row context q4=%q", q4)
+			}
+		}
+
+		// Keep the old paths of moved or renamed categories working.
+		for _, c := range changed {
+			oldPath := before[c.id].Path
+			if oldPath == c.Path {
+				continue
+			}
+			if err := addCategoryRedirect(ctx, tx, c.id, oldPath, c.Path); err != nil {
+				tx.Rollback()
+				return errors.Wrapf(err, "postgres: addCategoryRedirect(ctx, tx, categoryID=%d, oldPath=%q, newPath=%q) failed", c.id, oldPath, c.Path)
+			}
+		}
+	}
+	q5 := `
+		INSERT INTO category (
+		  segment, path, name, lft, rgt, depth, description, hero_image,
+		  meta_title, meta_description, hidden, product_sort, created, modified
+		) VALUES (
+		  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW()
+		)
+		RETURNING id, uuid, created, modified
+	`
+	for _, c := range added {
+		if err := tx.QueryRowContext(ctx, q5, c.Segment, c.Path, c.Name, c.Lft, c.Rgt, c.Depth,
+			c.Description, c.HeroImage, c.MetaTitle, c.MetaDescription, c.Hidden, c.ProductSort).Scan(&c.id, &c.UUID, &c.Created, &c.Modified); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "postgres: query row context q5=%q", q5)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	return nil
+}
+
+// categoryHasProducts returns true if any products are associated with
+// the category.
+func categoryHasProducts(ctx context.Context, tx *sql.Tx, categoryID int) (bool, error) {
+	q1 := "SELECT EXISTS (SELECT 1 FROM product_category WHERE category_id = $1)"
+	var exists bool
+	if err := tx.QueryRowContext(ctx, q1, categoryID).Scan(&exists); err != nil {
+		return false, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return exists, nil
+}
+
+// checkCanAddChild returns ErrCategoryHasProducts if n is a leaf with
+// products as it would stop being a leaf.
+func checkCanAddChild(ctx context.Context, tx *sql.Tx, n *categoryNode) error {
+	if !n.isLeaf() {
+		return nil
+	}
+	has, err := categoryHasProducts(ctx, tx, n.row.id)
+	if err != nil {
+		return err
+	}
+	if has {
+		return ErrCategoryHasProducts
+	}
+	return nil
+}
+
+// GetCategoryByUUID returns the category with the given uuid.
+func (m *PgModel) GetCategoryByUUID(ctx context.Context, categoryUUID string) (*CategoryRow, error) {
+	q1 := "SELECT " + categoryColumns + " FROM category WHERE uuid = $1"
+	c, err := scanCategory(m.db.QueryRowContext(ctx, q1, categoryUUID))
+	if err == sql.ErrNoRows {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return c, nil
+}
+
+// CreateCategory adds a category as a child of the parent category with
+// the given parentUUID at the given position among its siblings. The
+// category is added after its siblings if position is nil. If
+// parentUUID is nil the category is created as the root category.
+func (m *PgModel) CreateCategory(ctx context.Context, parentUUID *string, segment, name string, position *int) (*CategoryRow, error) {
+	row := &CategoryRow{Segment: segment, Name: name, ProductSort: CategoryProductSortManual}
+	err := m.editCategoryTree(ctx, func(tx *sql.Tx, t *categoryTree) error {
+		n := &categoryNode{row: row}
+		if parentUUID == nil {
+			if t.root != nil {
+				return ErrCategoryRootExists
+			}
+			t.root = n
+			return nil
+		}
+		parent, ok := t.byUUID[*parentUUID]
+		if !ok {
+			return ErrCategoryNotFound
+		}
+		if parent.hasChildSegment(segment, nil) {
+			return ErrCategoryPathExists
+		}
+		if err := checkCanAddChild(ctx, tx, parent); err != nil {
+			return err
+		}
+		parent.insertChild(n, position)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// UpdateCategory changes the segment, name and metadata of a category.
+// Changing the segment changes the path of the category and its
+// descendants.
+func (m *PgModel) UpdateCategory(ctx context.Context, categoryUUID string, cu *CategoryUpdate) (*CategoryRow, error) {
+	var row *CategoryRow
+	err := m.editCategoryTree(ctx, func(tx *sql.Tx, t *categoryTree) error {
+		n, ok := t.byUUID[categoryUUID]
+		if !ok {
+			return ErrCategoryNotFound
+		}
+		if cu.Segment != nil && *cu.Segment != n.row.Segment {
+			if n.parent != nil && n.parent.hasChildSegment(*cu.Segment, n) {
+				return ErrCategoryPathExists
+			}
+			n.row.Segment = *cu.Segment
+		}
+		cu.apply(n.row)
+		row = n.row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// MoveCategory moves a category and its descendants to the parent
+// category with the given parentUUID at the given position among its
+// new siblings. The category is added after its siblings if position is
+// nil.
+func (m *PgModel) MoveCategory(ctx context.Context, categoryUUID, parentUUID string, position *int) (*CategoryRow, error) {
+	var row *CategoryRow
+	err := m.editCategoryTree(ctx, func(tx *sql.Tx, t *categoryTree) error {
+		n, ok := t.byUUID[categoryUUID]
+		if !ok {
+			return ErrCategoryNotFound
+		}
+		parent, ok := t.byUUID[parentUUID]
+		if !ok {
+			return ErrCategoryNotFound
+		}
+		if n.parent == nil || n.contains(parent) {
+			return ErrCategoryMoveInvalid
+		}
+		if parent.hasChildSegment(n.row.Segment, n) {
+			return ErrCategoryPathExists
+		}
+		if err := checkCanAddChild(ctx, tx, parent); err != nil {
+			return err
+		}
+		n.parent.removeChild(n)
+		parent.insertChild(n, position)
+		row = n.row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// DeleteCategoryByUUID deletes a leaf category. Categories with products
+// or referenced by promo rules cannot be deleted.
+func (m *PgModel) DeleteCategoryByUUID(ctx context.Context, categoryUUID string) error {
+	return m.editCategoryTree(ctx, func(tx *sql.Tx, t *categoryTree) error {
+		n, ok := t.byUUID[categoryUUID]
+		if !ok {
+			return ErrCategoryNotFound
+		}
+		if !n.isLeaf() {
+			return ErrCategoryNotLeaf
+		}
+		has, err := categoryHasProducts(ctx, tx, n.row.id)
+		if err != nil {
+			return err
+		}
+		if has {
+			return ErrCategoryHasProducts
+		}
+
+		q1 := "SELECT EXISTS (SELECT 1 FROM promo_rule WHERE category_id = $1)"
+		var inUse bool
+		if err := tx.QueryRowContext(ctx, q1, n.row.id).Scan(&inUse); err != nil {
+			return errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+		}
+		if inUse {
+			return ErrCategoriesInUse
+		}
+
+		q2 := "DELETE FROM category WHERE id = $1"
+		if _, err := tx.ExecContext(ctx, q2, n.row.id); err != nil {
+			return errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+		}
+		if n.parent == nil {
+			t.root = nil
+		} else {
+			n.parent.removeChild(n)
+		}
+		delete(t.byUUID, categoryUUID)
+		return nil
+	})
+}
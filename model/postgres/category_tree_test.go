@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCategoryRows returns the nested set of the tree
+//
+//	a
+//	├── b
+//	│   └── d
+//	└── c
+func testCategoryRows() []*CategoryRow {
+	return []*CategoryRow{
+		{id: 1, UUID: "a", Segment: "a", Path: "a", Lft: 1, Rgt: 8, Depth: 0},
+		{id: 2, UUID: "b", Segment: "b", Path: "a/b", Lft: 2, Rgt: 5, Depth: 1},
+		{id: 3, UUID: "d", Segment: "d", Path: "a/b/d", Lft: 3, Rgt: 4, Depth: 2},
+		{id: 4, UUID: "c", Segment: "c", Path: "a/c", Lft: 6, Rgt: 7, Depth: 1},
+	}
+}
+
+func categoryPaths(t *categoryTree) []string {
+	paths := make([]string, 0, len(t.byUUID))
+	for _, n := range t.preorder() {
+		paths = append(paths, n.row.Path)
+	}
+	return paths
+}
+
+func TestNewCategoryTree(t *testing.T) {
+	tree, err := newCategoryTree(testCategoryRows())
+	assert.NoError(t, err)
+	assert.Equal(t, "a", tree.root.row.UUID)
+	assert.Len(t, tree.root.children, 2)
+	assert.Equal(t, tree.byUUID["b"], tree.byUUID["d"].parent)
+	assert.True(t, tree.byUUID["c"].isLeaf())
+	assert.True(t, tree.byUUID["b"].contains(tree.byUUID["d"]))
+	assert.False(t, tree.byUUID["d"].contains(tree.byUUID["b"]))
+
+	// renumbering an unchanged tree gives the same nested set.
+	tree.root.renumber(1, 0, "a")
+	assert.Equal(t, testCategoryRows(), []*CategoryRow{
+		tree.byUUID["a"].row, tree.byUUID["b"].row, tree.byUUID["d"].row, tree.byUUID["c"].row,
+	})
+
+	_, err = newCategoryTree([]*CategoryRow{
+		{UUID: "a", Lft: 1, Rgt: 2},
+		{UUID: "b", Lft: 3, Rgt: 4},
+	})
+	assert.Error(t, err)
+}
+
+func TestCategoryTreeEdits(t *testing.T) {
+	tree, _ := newCategoryTree(testCategoryRows())
+
+	// move d to the front of the children of a.
+	d := tree.byUUID["d"]
+	position := 0
+	d.parent.removeChild(d)
+	tree.root.insertChild(d, &position)
+	tree.root.renumber(1, 0, "a")
+	assert.Equal(t, []string{"a", "a/d", "a/b", "a/c"}, categoryPaths(tree))
+	assert.Equal(t, 2, d.row.Lft)
+	assert.Equal(t, 3, d.row.Rgt)
+	assert.Equal(t, 1, d.row.Depth)
+	assert.True(t, tree.byUUID["b"].isLeaf())
+	assert.Equal(t, 8, tree.root.row.Rgt)
+
+	// add a child to c after its siblings and rename c.
+	e := &categoryNode{row: &CategoryRow{Segment: "e"}}
+	c := tree.byUUID["c"]
+	c.insertChild(e, nil)
+	c.row.Segment = "cc"
+	tree.root.renumber(1, 0, "a")
+	assert.Equal(t, []string{"a", "a/d", "a/b", "a/cc", "a/cc/e"}, categoryPaths(tree))
+	assert.Equal(t, 7, e.row.Lft)
+	assert.Equal(t, 10, tree.root.row.Rgt)
+	assert.True(t, tree.root.hasChildSegment("cc", nil))
+	assert.False(t, tree.root.hasChildSegment("cc", c))
+}
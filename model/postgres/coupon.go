@@ -106,20 +106,35 @@ func (m *PgModel) GetCouponByUUID(ctx context.Context, couponUUID string) (*Coup
 	return &c, nil
 }
 
-// GetCoupons returns a list of CouponJoinRows.
-func (m *PgModel) GetCoupons(ctx context.Context) ([]*CouponJoinRow, error) {
-	q1 := `
-		SELECT
+// GetCoupons returns a page of CouponJoinRows.
+func (m *PgModel) GetCoupons(ctx context.Context, opts *ListOptions) ([]*CouponJoinRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
 		  c.id, c.uuid,
-		  coupon_code, promo_rule_id, r.uuid as promo_rule_uuid, r.promo_rule_code,
-		  void, reusable, spend_count, c.created, c.modified
-		FROM coupon AS c
-		INNER JOIN promo_rule AS r
-		  ON r.id = c.promo_rule_id
-	`
-	rows, err := m.db.QueryContext(ctx, q1)
+		  c.coupon_code, c.promo_rule_id, r.uuid as promo_rule_uuid, r.promo_rule_code,
+		  c.void, c.reusable, c.spend_count, c.created, c.modified`,
+		from:  "coupon AS c INNER JOIN promo_rule AS r ON r.id = c.promo_rule_id",
+		alias: "c",
+		fields: map[string]string{
+			"coupon_code":     "c.coupon_code",
+			"promo_rule_code": "r.promo_rule_code",
+			"spend_count":     "c.spend_count",
+			"created":         "c.created",
+			"modified":        "c.modified",
+		},
+		orderBy:  "c.created",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
 	}
 	defer rows.Close()
 
@@ -130,14 +145,14 @@ func (m *PgModel) GetCoupons(ctx context.Context) ([]*CouponJoinRow, error) {
 			&c.CouponCode, &c.promoRuleID, &c.PromoRuleUUID, &c.PromoRuleCode,
 			&c.Void, &c.Resuable, &c.SpendCount, &c.Created, &c.Modified)
 		if err != nil {
-			return nil, errors.Wrap(err, "postgres: scan failed")
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
 		}
 		coupons = append(coupons, &c)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows.Err()")
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
 	}
-	return coupons, nil
+	return coupons, opts.page(&coupons), nil
 }
 
 // UpdateCouponByUUID updates a coupon by uuid.
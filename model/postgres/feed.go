@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// SitemapRow is a storefront path of a product or category and the time
+// it was last modified.
+type SitemapRow struct {
+	Path     string
+	Modified time.Time
+}
+
+// ProductFeedRow holds a published product with its break 1 price in a
+// price list and the gsurls of its images in priority order. UnitPrice is
+// nil if the product has no price in the price list.
+type ProductFeedRow struct {
+	UUID        string
+	ParentUUID  *string
+	Path        string
+	SKU         string
+	Name        string
+	TaxCode     string
+	Description string
+	Attributes  ProductAttributes
+	UnitPrice   *int
+	OfferPrice  *int
+	Images      []string
+	Modified    time.Time
+}
+
+// GetSitemapRows returns the paths of all published products followed by
+// the paths of all categories that are not hidden. Each is ordered by
+// path.
+func (m *PgModel) GetSitemapRows(ctx context.Context) ([]*SitemapRow, error) {
+	q1 := `
+		SELECT p.path, p.modified, 1
+		FROM product AS p
+		WHERE ` + publishedCondition + `
+		UNION ALL
+		SELECT c.path, c.modified, 2
+		FROM category AS c
+		WHERE NOT ` + hiddenCategoryCondition + `
+		ORDER BY 3, 1
+	`
+	rows, err := m.db.QueryContext(ctx, q1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query context q1=%q", q1)
+	}
+	defer rows.Close()
+
+	sitemap := make([]*SitemapRow, 0, 1024)
+	for rows.Next() {
+		var s SitemapRow
+		var precedence int
+		if err := rows.Scan(&s.Path, &s.Modified, &precedence); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		sitemap = append(sitemap, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return sitemap, nil
+}
+
+// GetProductFeed returns every purchasable product ordered by SKU with
+// prices from the price list with the given priceListUUID. Parent products
+// are left out in favour of their variants. Prices include the tax due
+// for dest, which is added using the tax calculator if the price list
+// prices exclude tax. Returns ErrPriceListNotFound if the price list does
+// not exist or ErrTaxRateNotFound if a tax rate is missing.
+func (m *PgModel) GetProductFeed(ctx context.Context, priceListUUID string, dest *TaxDestination) ([]*ProductFeedRow, error) {
+	q0 := "SELECT inc_tax FROM price_list WHERE uuid = $1"
+	var incTax bool
+	err := m.db.QueryRowContext(ctx, q0, priceListUUID).Scan(&incTax)
+	if err == sql.ErrNoRows {
+		return nil, ErrPriceListNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q0=%q", q0)
+	}
+
+	q1 := `
+		SELECT
+		  p.uuid, pp.uuid, p.path, p.sku, p.name, p.tax_code, p.description, p.attributes,
+		  pr.unit_price, pr.offer_price,
+		  ARRAY(SELECT i.gsurl FROM image AS i WHERE i.product_id = p.id ORDER BY i.pri ASC, i.id ASC),
+		  p.modified
+		FROM product AS p
+		LEFT OUTER JOIN product AS pp
+		  ON pp.id = p.parent_id
+		LEFT OUTER JOIN (
+		  SELECT r.product_id, r.unit_price, r.offer_price
+		  FROM price AS r
+		  INNER JOIN price_list AS l
+		    ON l.id = r.price_list_id
+		  WHERE l.uuid = $1 AND r.break = 1
+		) AS pr
+		  ON pr.product_id = p.id
+		WHERE ` + purchasableCondition + ` AND
+		  NOT EXISTS (SELECT 1 FROM product AS v WHERE v.parent_id = p.id)
+		ORDER BY p.sku ASC
+	`
+	rows, err := m.db.QueryContext(ctx, q1, priceListUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query context q1=%q", q1)
+	}
+	defer rows.Close()
+
+	products := make([]*ProductFeedRow, 0, 1024)
+	for rows.Next() {
+		var p ProductFeedRow
+		if err := rows.Scan(&p.UUID, &p.ParentUUID, &p.Path, &p.SKU, &p.Name, &p.TaxCode, &p.Description,
+			&p.Attributes, &p.UnitPrice, &p.OfferPrice, pq.Array(&p.Images), &p.Modified); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		products = append(products, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	if !incTax {
+		if err := addFeedTax(ctx, m.tax, products, dest, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return products, nil
+}
+
+// addFeedTax adds the tax due for dest to the prices of the products.
+func addFeedTax(ctx context.Context, tc TaxCalculator, products []*ProductFeedRow, dest *TaxDestination, at time.Time) error {
+	for _, p := range products {
+		taxCode := p.TaxCode
+		if taxCode == "" {
+			taxCode = DefaultTaxCode
+		}
+		for _, price := range []*int{p.UnitPrice, p.OfferPrice} {
+			if price == nil {
+				continue
+			}
+			tax, err := tc.CalcTax(ctx, dest, taxCode, *price, false, at)
+			if err != nil {
+				return err
+			}
+			*price += tax
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddFeedTax(t *testing.T) {
+	unitPrice, offerPrice, foodPrice := 1000, 800, 500
+	products := []*ProductFeedRow{
+		{SKU: "WATER-BLUE", UnitPrice: &unitPrice, OfferPrice: &offerPrice},
+		{SKU: "BREAD", TaxCode: "T0", UnitPrice: &foodPrice},
+		{SKU: "UNPRICED"},
+	}
+	dest := TaxDestination{CountryCode: "GB"}
+	assert.NoError(t, addFeedTax(context.Background(), ukVAT, products, &dest, time.Now()))
+	assert.Equal(t, 1200, *products[0].UnitPrice)
+	assert.Equal(t, 960, *products[0].OfferPrice)
+	assert.Equal(t, 500, *products[1].UnitPrice)
+	assert.Nil(t, products[2].UnitPrice)
+
+	products = []*ProductFeedRow{{SKU: "X", TaxCode: "T99", UnitPrice: &unitPrice}}
+	assert.Equal(t, ErrTaxRateNotFound, addFeedTax(context.Background(), ukVAT, products, &dest, time.Now()))
+}
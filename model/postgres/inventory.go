@@ -3,10 +3,9 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
@@ -15,25 +14,82 @@ import (
 var ErrInventoryNotFound = errors.New("postgres: inventory not found")
 
 // InventoryRowUpdate holds the data for a single update used in batch update.
+// The default location is updated if LocationCode is nil. The reorder
+// level is left unchanged if ReorderLevel is nil.
 type InventoryRowUpdate struct {
-	ProductUUID string
-	Onhand      int
-	Overselling bool
+	ProductUUID  string
+	LocationCode *string
+	Onhand       int
+	Overselling  bool
+	ReorderLevel *int
+}
+
+// InventoryLocationRow holds the onhand of inventory at a single location.
+type InventoryLocationRow struct {
+	inventoryID  int
+	locationID   int
+	LocationUUID string
+	LocationCode string
+	Onhand       int
 }
 
 // A InventoryJoinRow represents a single row from the inventory table
 // joined to the product table.
 type InventoryJoinRow struct {
-	id          int
-	UUID        string
-	productID   int
-	ProductUUID string
-	ProductPath string
-	ProductSKU  string
-	Onhand      int
-	Overselling bool
-	Created     time.Time
-	Modified    time.Time
+	id             int
+	UUID           string
+	productID      int
+	ProductUUID    string
+	ProductPath    string
+	ProductSKU     string
+	Onhand         int
+	Overselling    bool
+	ReorderLevel   int
+	Backorder      string
+	AvailableDate  *time.Time
+	BackorderLimit *int
+	Locations      []*InventoryLocationRow
+	Created        time.Time
+	Modified       time.Time
+}
+
+// getInventoryLocations sets the onhand at each location of each
+// inventory in the list.
+func getInventoryLocations(ctx context.Context, q queryer, list []*InventoryJoinRow) error {
+	inventoryIDs := make([]int64, 0, len(list))
+	inventory := make(map[int]*InventoryJoinRow)
+	for _, v := range list {
+		inventoryIDs = append(inventoryIDs, int64(v.id))
+		inventory[v.id] = v
+		v.Locations = make([]*InventoryLocationRow, 0)
+	}
+
+	q1 := `
+		SELECT il.inventory_id, l.id, l.uuid, l.code, il.onhand
+		FROM inventory_location AS il
+		INNER JOIN location AS l
+		  ON l.id = il.location_id
+		WHERE il.inventory_id = ANY($1)
+		ORDER BY l.priority, l.id
+	`
+	rows, err := q.QueryContext(ctx, q1, pq.Array(inventoryIDs))
+	if err != nil {
+		return errors.Wrapf(err, "postgres: query context q1=%q", q1)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var il InventoryLocationRow
+		if err := rows.Scan(&il.inventoryID, &il.locationID, &il.LocationUUID, &il.LocationCode, &il.Onhand); err != nil {
+			return errors.Wrap(err, "postgres: scan failed")
+		}
+		v := inventory[il.inventoryID]
+		v.Locations = append(v.Locations, &il)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return nil
 }
 
 // GetInventoryByUUID returns a single InventoryJoinRow for a given inventory id.
@@ -42,7 +98,8 @@ func (m *PgModel) GetInventoryByUUID(ctx context.Context, inventoryUUID string)
 		SELECT
 		  v.id, v.uuid, v.product_id, p.uuid AS product_uuid,
 		  p.path AS product_path, p.sku AS product_sku,
-		  onhand, overselling, v.created, v.modified
+		  onhand, overselling, reorder_level, backorder, available_date,
+		  backorder_limit, v.created, v.modified
 		FROM inventory AS v
 		INNER JOIN product AS p
 		ON v.product_id = p.id
@@ -51,13 +108,16 @@ func (m *PgModel) GetInventoryByUUID(ctx context.Context, inventoryUUID string)
 	row := m.db.QueryRowContext(ctx, q1, inventoryUUID)
 	var v InventoryJoinRow
 	err := row.Scan(&v.id, &v.UUID, &v.productID, &v.ProductUUID,
-		&v.ProductPath, &v.ProductSKU, &v.Onhand, &v.Overselling, &v.Created, &v.Modified)
+		&v.ProductPath, &v.ProductSKU, &v.Onhand, &v.Overselling, &v.ReorderLevel, &v.Backorder, &v.AvailableDate, &v.BackorderLimit, &v.Created, &v.Modified)
 	if err == sql.ErrNoRows {
 		return nil, ErrInventoryNotFound
 	}
 	if err != nil {
 		return nil, errors.Wrap(err, "postgres: scan failed")
 	}
+	if err := getInventoryLocations(ctx, m.db, []*InventoryJoinRow{&v}); err != nil {
+		return nil, err
+	}
 	return &v, nil
 }
 
@@ -67,7 +127,8 @@ func (m *PgModel) GetInventoryByProductUUID(ctx context.Context, productUUID str
 		SELECT
 		  v.id, v.uuid, v.product_id, p.uuid AS product_uuid,
 		  p.path AS product_path, p.sku AS product_sku,
-		  onhand, overselling, v.created, v.modified
+		  onhand, overselling, reorder_level, backorder, available_date,
+		  backorder_limit, v.created, v.modified
 		FROM inventory AS v
 		INNER JOIN product AS p
 		ON v.product_id = p.id
@@ -76,30 +137,49 @@ func (m *PgModel) GetInventoryByProductUUID(ctx context.Context, productUUID str
 	var v InventoryJoinRow
 	err := m.db.QueryRowContext(ctx, q1, productUUID).Scan(&v.id, &v.UUID, &v.productID,
 		&v.ProductUUID, &v.ProductPath, &v.ProductSKU, &v.Onhand,
-		&v.Overselling, &v.Created, &v.Modified)
+		&v.Overselling, &v.ReorderLevel, &v.Backorder, &v.AvailableDate, &v.BackorderLimit, &v.Created, &v.Modified)
 	if err == sql.ErrNoRows {
 		return nil, ErrProductNotFound
 	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "postgres: query row context failed for q1=%q", q1)
 	}
+	if err := getInventoryLocations(ctx, m.db, []*InventoryJoinRow{&v}); err != nil {
+		return nil, err
+	}
 	return &v, nil
 }
 
-// GetAllInventory returns a list of InventoryJoinRows for all inventory.
-func (m *PgModel) GetAllInventory(ctx context.Context) ([]*InventoryJoinRow, error) {
-	q1 := `
-		SELECT
+// GetAllInventory returns a page of InventoryJoinRows.
+func (m *PgModel) GetAllInventory(ctx context.Context, opts *ListOptions) ([]*InventoryJoinRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
 		  v.id, v.uuid, v.product_id, p.uuid AS product_uuid,
 		  p.path AS product_path, p.sku AS product_sku,
-		  onhand, overselling, v.created, v.modified
-		FROM inventory AS v
-		INNER JOIN product AS p
-		ON v.product_id = p.id
-	`
-	rows, err := m.db.QueryContext(ctx, q1)
+		  v.onhand, v.overselling, v.reorder_level, v.backorder, v.available_date,
+		  v.backorder_limit, v.created, v.modified`,
+		from:  "inventory AS v INNER JOIN product AS p ON v.product_id = p.id",
+		alias: "v",
+		fields: map[string]string{
+			"product_sku":  "p.sku",
+			"product_path": "p.path",
+			"onhand":       "v.onhand",
+			"created":      "v.created",
+			"modified":     "v.modified",
+		},
+		orderBy:  "v.created",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
 	}
 	defer rows.Close()
 
@@ -107,97 +187,134 @@ func (m *PgModel) GetAllInventory(ctx context.Context) ([]*InventoryJoinRow, err
 	for rows.Next() {
 		var v InventoryJoinRow
 		err = rows.Scan(&v.id, &v.UUID, &v.productID, &v.ProductUUID, &v.ProductPath,
-			&v.ProductSKU, &v.Onhand, &v.Overselling, &v.Created, &v.Modified)
+			&v.ProductSKU, &v.Onhand, &v.Overselling, &v.ReorderLevel, &v.Backorder, &v.AvailableDate, &v.BackorderLimit, &v.Created, &v.Modified)
 		if err == sql.ErrNoRows {
-			return nil, ErrInventoryNotFound
+			return nil, nil, ErrInventoryNotFound
 		}
 		if err != nil {
-			return nil, errors.Wrap(err, "postgres: scan failed")
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
 		}
 		list = append(list, &v)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows.Err()")
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
 	}
-	return list, nil
+	lc := opts.page(&list)
+	if err := getInventoryLocations(ctx, m.db, list); err != nil {
+		return nil, nil, err
+	}
+	return list, lc, nil
 }
 
 // UpdateInventoryByUUID updates the inventory with the given uuid
-// returning the new inventory.
-func (m *PgModel) UpdateInventoryByUUID(ctx context.Context, inventoryUUID string, onhand *int, overselling *bool) (*InventoryJoinRow, error) {
+// returning the new inventory. onhand sets the stock at the location with
+// the given code or the default location if locationCode is nil. A
+// change to onhand is recorded as a stock take by the user with the given
+// usrUUID. If backorder is not nil the backorder policy is replaced. Any
+// stock alert raised is returned.
+func (m *PgModel) UpdateInventoryByUUID(ctx context.Context, inventoryUUID string, locationCode *string, onhand *int, overselling *bool, reorderLevel *int, backorder *InventoryBackorder, usrUUID *string) (*InventoryJoinRow, []*StockAlertRow, error) {
 	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "postgres: db.BeginTx failed")
+		return nil, nil, errors.Wrap(err, "postgres: db.BeginTx failed")
 	}
 
-	// 1. Check the inventory exists
-	q1 := "SELECT id FROM inventory WHERE uuid = $1"
+	// 1. Check the inventory exists and lock it.
+	q1 := "SELECT id FROM inventory WHERE uuid = $1 FOR UPDATE"
 	var inventoryID int
 	err = tx.QueryRowContext(ctx, q1, inventoryUUID).Scan(&inventoryID)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
-		return nil, ErrInventoryNotFound
+		return nil, nil, ErrInventoryNotFound
 	}
 	if err != nil {
 		tx.Rollback()
-		return nil, errors.Wrapf(err, "postgres: query row context failed for q1=%q", q1)
+		return nil, nil, errors.Wrapf(err, "postgres: query row context failed for q1=%q", q1)
+	}
+
+	// 2. Update the reorder level before setting onhand so that the new
+	// level is used for any stock alert.
+	if reorderLevel != nil {
+		q2 := `UPDATE inventory SET reorder_level = $2, modified = NOW() WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, q2, inventoryID, *reorderLevel); err != nil {
+			tx.Rollback()
+			return nil, nil, errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+		}
 	}
 
-	// 2. Update the inventory row with the new onhand value.
-	var set []string
-	var queryArgs []interface{}
-	argCounter := 1
+	// 3. Set the onhand at the location.
+	changes := newStockChanges()
 	if onhand != nil {
-		set = append(set, fmt.Sprintf("onhand = $%d", argCounter))
-		argCounter++
-		queryArgs = append(queryArgs, *onhand)
+		if err := setLocationStock(ctx, tx, changes, inventoryID, locationCode, *onhand, usrUUID); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
 	}
+
+	// 4. Update overselling.
 	if overselling != nil {
-		set = append(set, fmt.Sprintf("overselling = $%d", argCounter))
-		argCounter++
-		queryArgs = append(queryArgs, *overselling)
+		q3 := `UPDATE inventory SET overselling = $2, modified = NOW() WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, q3, inventoryID, *overselling); err != nil {
+			tx.Rollback()
+			return nil, nil, errors.Wrapf(err, "postgres: exec context q3=%q", q3)
+		}
 	}
 
-	queryArgs = append(queryArgs, inventoryID)
-	setQuery := strings.Join(set, ", ")
-	q2 := `
-		UPDATE inventory
-		SET %SET_QUERY%, modified = NOW()
-		WHERE id = %ARG_COUNTER%
-		RETURNING
-		  id, uuid, product_id, onhand, overselling, created, modified
-	`
-	q2 = strings.Replace(q2, "%SET_QUERY%", setQuery, 1)
-	q2 = strings.Replace(q2, "%ARG_COUNTER%", fmt.Sprintf("$%d", argCounter), 1)
-	row := tx.QueryRowContext(ctx, q2, queryArgs...)
-	v := InventoryJoinRow{}
-	if err := row.Scan(&v.id, &v.UUID, &v.productID, &v.Onhand, &v.Overselling, &v.Created, &v.Modified); err != nil {
+	// 5. Replace the backorder policy.
+	if backorder != nil {
+		q4 := `
+			UPDATE inventory
+			SET backorder = $2, available_date = $3, backorder_limit = $4, modified = NOW()
+			WHERE id = $1
+		`
+		if _, err := tx.ExecContext(ctx, q4, inventoryID, backorder.Backorder, backorder.AvailableDate, backorder.Limit); err != nil {
+			tx.Rollback()
+			return nil, nil, errors.Wrapf(err, "postgres: exec context q4=%q", q4)
+		}
+	}
+
+	alerts, err := changes.alerts(ctx, tx)
+	if err != nil {
 		tx.Rollback()
-		return nil, errors.Wrapf(err, "postgres: query row context q2=%q failed", q2)
+		return nil, nil, err
 	}
 
-	q3 := "SELECT uuid, path, sku FROM product WHERE id = $1"
-	row = tx.QueryRowContext(ctx, q3, v.productID)
-	err = row.Scan(&v.ProductUUID, &v.ProductPath, &v.ProductSKU)
-	if err == sql.ErrNoRows {
-		return nil, ErrProductNotFound
+	if err := tx.Commit(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: tx.Commit failed")
 	}
+	v, err := m.GetInventoryByUUID(ctx, inventoryUUID)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: scan failed")
+		return nil, nil, err
 	}
+	return v, alerts, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return nil, errors.Wrap(err, "postgres: tx.Commit failed")
+// setLocationStock sets the onhand of inventory at the location with the
+// given code recording the change as a stock take. The default location
+// is used if locationCode is nil. The change is recorded in changes.
+func setLocationStock(ctx context.Context, tx *sql.Tx, changes *stockChanges, inventoryID int, locationCode *string, onhand int, usrUUID *string) error {
+	locationID, err := locationIDByCode(ctx, tx, locationCode)
+	if err != nil {
+		return err
 	}
-	return &v, nil
+	prev, err := locationOnhand(ctx, tx, inventoryID, locationID)
+	if err != nil {
+		return err
+	}
+	if onhand == prev {
+		return nil
+	}
+	_, err = moveStock(ctx, tx, changes, inventoryID, locationID, onhand-prev, "stock_take", nil, usrUUID, nil)
+	return err
 }
 
 // BatchUpdateInventory updates multiple product inventory, either
-// all completing or none.
-func (m *PgModel) BatchUpdateInventory(ctx context.Context, inventoryList []*InventoryRowUpdate) ([]*InventoryJoinRow, error) {
+// all completing or none. Each change to onhand is recorded as a stock
+// take by the user with the given usrUUID. Any stock alerts raised are
+// returned.
+func (m *PgModel) BatchUpdateInventory(ctx context.Context, inventoryList []*InventoryRowUpdate, usrUUID *string) ([]*InventoryJoinRow, []*StockAlertRow, error) {
 	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "postgres: db.BeginTx failed")
+		return nil, nil, errors.Wrap(err, "postgres: db.BeginTx failed")
 	}
 
 	// 1. Create a map of product uuid to product ids
@@ -205,7 +322,7 @@ func (m *PgModel) BatchUpdateInventory(ctx context.Context, inventoryList []*Inv
 	rows1, err := tx.QueryContext(ctx, q1)
 	if err != nil {
 		tx.Rollback()
-		return nil, errors.Wrapf(err, "postgres: query context q1=%q", q1)
+		return nil, nil, errors.Wrapf(err, "postgres: query context q1=%q", q1)
 	}
 	defer rows1.Close()
 
@@ -222,12 +339,12 @@ func (m *PgModel) BatchUpdateInventory(ctx context.Context, inventoryList []*Inv
 		err = rows1.Scan(&p.id, &p.uuid, &p.path, &p.sku, &p.name)
 		if err != nil {
 			tx.Rollback()
-			return nil, errors.Wrapf(err, "postgres: scan failed")
+			return nil, nil, errors.Wrapf(err, "postgres: scan failed")
 		}
 		productMap[p.uuid] = &p
 	}
 	if err := rows1.Err(); err != nil {
-		return nil, errors.Wrapf(err, "postgres: rows.Err()")
+		return nil, nil, errors.Wrapf(err, "postgres: rows.Err()")
 	}
 
 	// Iterate the inventory update list passed in to this function.
@@ -235,47 +352,75 @@ func (m *PgModel) BatchUpdateInventory(ctx context.Context, inventoryList []*Inv
 	for _, inv := range inventoryList {
 		if _, ok := productMap[inv.ProductUUID]; !ok {
 			tx.Rollback()
-			return nil, ErrProductNotFound
+			return nil, nil, ErrProductNotFound
 		}
 	}
 
+	// 2. Update overselling and the reorder level and set the onhand at
+	// the location.
 	q2 := `
 		UPDATE inventory
-		SET onhand = $1, modified = NOW()
-		WHERE product_id = $2
-		RETURNING id, uuid, product_id, onhand, overselling, created, modified
+		SET overselling = $2, reorder_level = COALESCE($3, reorder_level), modified = NOW()
+		WHERE product_id = $1
+		RETURNING id
 	`
-	stmt2, err := tx.PrepareContext(ctx, q2)
-	if err != nil {
-		tx.Rollback()
-		return nil, errors.Wrapf(err, "postgres: tx prepare for q2=%q", q2)
-	}
-	defer stmt2.Close()
-
-	inventoryResults := make([]*InventoryJoinRow, 0, len(inventoryList))
+	changes := newStockChanges()
+	inventoryIDs := make([]int, 0, len(inventoryList))
 	for _, i := range inventoryList {
 		product := productMap[i.ProductUUID]
 
-		v := InventoryJoinRow{}
-		err := stmt2.QueryRowContext(ctx, i.Onhand, product.id).Scan(&v.id, &v.UUID, &v.productID,
-			&v.Onhand, &v.Overselling, &v.Created, &v.Modified)
+		var inventoryID int
+		err := tx.QueryRowContext(ctx, q2, product.id, i.Overselling, i.ReorderLevel).Scan(&inventoryID)
 		if err == sql.ErrNoRows {
 			tx.Rollback()
-			return nil, ErrProductCategoryNotFound
+			return nil, nil, ErrProductCategoryNotFound
 		}
 		if err != nil {
 			tx.Rollback()
-			return nil, errors.Wrapf(err, "postgres: stmt2.QueryRowContext(ctx, ...) failed q2%q", q2)
+			return nil, nil, errors.Wrapf(err, "postgres: tx.QueryRowContext(ctx, ...) failed q2=%q", q2)
 		}
-		v.ProductUUID = product.uuid
-		v.ProductPath = product.path
-		v.ProductSKU = product.sku
+		if err := setLocationStock(ctx, tx, changes, inventoryID, i.LocationCode, i.Onhand, usrUUID); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		inventoryIDs = append(inventoryIDs, inventoryID)
+	}
 
+	// 3. Read back the updated inventory.
+	q3 := `
+		SELECT
+		  v.id, v.uuid, v.product_id, p.uuid AS product_uuid,
+		  p.path AS product_path, p.sku AS product_sku,
+		  onhand, overselling, reorder_level, backorder, available_date,
+		  backorder_limit, v.created, v.modified
+		FROM inventory AS v
+		INNER JOIN product AS p
+		ON v.product_id = p.id
+		WHERE v.id = $1
+	`
+	inventoryResults := make([]*InventoryJoinRow, 0, len(inventoryIDs))
+	for _, inventoryID := range inventoryIDs {
+		var v InventoryJoinRow
+		if err := tx.QueryRowContext(ctx, q3, inventoryID).Scan(&v.id, &v.UUID, &v.productID,
+			&v.ProductUUID, &v.ProductPath, &v.ProductSKU, &v.Onhand,
+			&v.Overselling, &v.ReorderLevel, &v.Backorder, &v.AvailableDate, &v.BackorderLimit, &v.Created, &v.Modified); err != nil {
+			tx.Rollback()
+			return nil, nil, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+		}
 		inventoryResults = append(inventoryResults, &v)
 	}
+	if err := getInventoryLocations(ctx, tx, inventoryResults); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	alerts, err := changes.alerts(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
-		return nil, errors.Wrap(err, "postgres: tx.Commit failed")
+		return nil, nil, errors.Wrap(err, "postgres: tx.Commit failed")
 	}
-	return inventoryResults, nil
+	return inventoryResults, alerts, nil
 }
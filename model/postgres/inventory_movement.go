@@ -0,0 +1,253 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInventoryBelowZero is returned when an adjustment would take the
+// onhand of inventory below zero.
+var ErrInventoryBelowZero = errors.New("postgres: inventory below zero")
+
+// ErrInventoryMovementNotFound error
+var ErrInventoryMovementNotFound = errors.New("postgres: inventory movement not found")
+
+// InventoryMovementRow holds a single row of data from the
+// inventory_movement table.
+type InventoryMovementRow struct {
+	id            int
+	UUID          string
+	inventoryID   int
+	InventoryUUID string
+	SKU           string
+	locationID    *int
+	LocationCode  *string
+	Reason        string
+	Delta         int
+	Balance       int
+	orderID       *int
+	OrderUUID     *string
+	usrID         *int
+	UsrUUID       *string
+	Note          *string
+	Created       time.Time
+}
+
+// insertInventoryMovement records a change to the onhand of inventory at
+// a location along with the sku of its product. The user is looked up by
+// usrUUID if set.
+func insertInventoryMovement(ctx context.Context, tx *sql.Tx, inventoryID, locationID int, reason string, delta, balance int, orderID *int, usrUUID, note *string) error {
+	q1 := `
+		INSERT INTO inventory_movement
+		  (inventory_id, sku, location_id, reason, delta, balance, order_id, usr_id, note, created)
+		SELECT
+		  v.id, p.sku, $2, $3, $4, $5, $6, (SELECT id FROM usr WHERE uuid::text = $7), $8, NOW()
+		FROM inventory AS v
+		INNER JOIN product AS p
+		  ON p.id = v.product_id
+		WHERE v.id = $1
+	`
+	if _, err := tx.ExecContext(ctx, q1, inventoryID, locationID, reason, delta, balance, orderID, usrUUID, note); err != nil {
+		return errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q1=%q, inventoryID=%d, ...) failed", q1, inventoryID)
+	}
+	return nil
+}
+
+// moveStock changes the onhand of inventory at a location by delta
+// keeping the inventory onhand total in step and records the movement.
+// The change to the total is recorded in changes if not nil. The caller
+// must hold a lock on the inventory row. Returns the onhand at the
+// location after the move.
+func moveStock(ctx context.Context, tx *sql.Tx, changes *stockChanges, inventoryID, locationID, delta int, reason string, orderID *int, usrUUID, note *string) (int, error) {
+	q1 := `
+		INSERT INTO inventory_location (inventory_id, location_id, onhand, created, modified)
+		VALUES ($1, $2, 0, NOW(), NOW())
+		ON CONFLICT (inventory_id, location_id) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, q1, inventoryID, locationID); err != nil {
+		return 0, errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q1=%q, inventoryID=%d, ...) failed", q1, inventoryID)
+	}
+
+	q2 := `
+		UPDATE inventory_location
+		SET onhand = onhand + $3, modified = NOW()
+		WHERE inventory_id = $1 AND location_id = $2
+		RETURNING onhand
+	`
+	var locationOnhand int
+	if err := tx.QueryRowContext(ctx, q2, inventoryID, locationID, delta).Scan(&locationOnhand); err != nil {
+		return 0, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+
+	q3 := `
+		UPDATE inventory
+		SET onhand = COALESCE(onhand, 0) + $2, modified = NOW()
+		WHERE id = $1
+		RETURNING onhand
+	`
+	var balance int
+	if err := tx.QueryRowContext(ctx, q3, inventoryID, delta).Scan(&balance); err != nil {
+		return 0, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+	}
+	if err := insertInventoryMovement(ctx, tx, inventoryID, locationID, reason, delta, balance, orderID, usrUUID, note); err != nil {
+		return 0, err
+	}
+	if changes != nil {
+		changes.record(inventoryID, balance-delta, balance)
+	}
+	return locationOnhand, nil
+}
+
+// locationOnhand returns the onhand of inventory at a location.
+func locationOnhand(ctx context.Context, tx *sql.Tx, inventoryID, locationID int) (int, error) {
+	q1 := `
+		SELECT COALESCE((
+		  SELECT onhand FROM inventory_location
+		  WHERE inventory_id = $1 AND location_id = $2
+		), 0)
+	`
+	var onhand int
+	if err := tx.QueryRowContext(ctx, q1, inventoryID, locationID).Scan(&onhand); err != nil {
+		return 0, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return onhand, nil
+}
+
+// AdjustInventoryByUUID changes the onhand of the inventory with the given
+// uuid at the location with the given code by delta and records an
+// adjustment made by the user. The default location is used if
+// locationCode is nil. Returns ErrInventoryNotFound if the inventory does
+// not exist, ErrLocationNotFound if the location does not exist or
+// ErrInventoryBelowZero if onhand at the location would drop below zero.
+// Any stock alert raised is returned.
+func (m *PgModel) AdjustInventoryByUUID(ctx context.Context, inventoryUUID string, locationCode *string, delta int, usrUUID, note *string) (*InventoryJoinRow, []*StockAlertRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: AdjustInventoryByUUID(ctx, inventoryUUID=%q, locationCode=%v, delta=%d, usrUUID=%v, note=%v) started", inventoryUUID, locationCode, delta, usrUUID, note)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: db.BeginTx failed")
+	}
+
+	// 1. Lock the inventory.
+	q1 := `SELECT id FROM inventory WHERE uuid = $1 FOR UPDATE`
+	var inventoryID int
+	err = tx.QueryRowContext(ctx, q1, inventoryUUID).Scan(&inventoryID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil, ErrInventoryNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	locationID, err := locationIDByCode(ctx, tx, locationCode)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	onhand, err := locationOnhand(ctx, tx, inventoryID, locationID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	if onhand+delta < 0 {
+		tx.Rollback()
+		return nil, nil, ErrInventoryBelowZero
+	}
+
+	// 2. Apply the delta and record the movement.
+	changes := newStockChanges()
+	if _, err := moveStock(ctx, tx, changes, inventoryID, locationID, delta, "adjustment", nil, usrUUID, note); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	alerts, err := changes.alerts(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	v, err := m.GetInventoryByUUID(ctx, inventoryUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, alerts, nil
+}
+
+// GetInventoryMovementsByUUID returns a page of movements of the
+// inventory with the given uuid newest first by default. Returns
+// ErrInventoryNotFound if the inventory does not exist or
+// ErrInventoryMovementNotFound if the StartAfter or EndBefore movement
+// does not exist.
+func (m *PgModel) GetInventoryMovementsByUUID(ctx context.Context, inventoryUUID string, opts *ListOptions) ([]*InventoryMovementRow, *ListContext, error) {
+	q1 := `SELECT id FROM inventory WHERE uuid = $1`
+	var inventoryID int
+	err := m.db.QueryRowContext(ctx, q1, inventoryUUID).Scan(&inventoryID)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrInventoryNotFound
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+
+	q := listQuery{
+		sel: `
+		  m.id, m.uuid, m.inventory_id, v.uuid, m.sku, m.location_id, l.code,
+		  m.reason, m.delta, m.balance,
+		  m.order_id, o.uuid, m.usr_id, u.uuid, m.note, m.created`,
+		from: `inventory_movement AS m
+		  INNER JOIN inventory AS v ON v.id = m.inventory_id
+		  LEFT OUTER JOIN location AS l ON l.id = m.location_id
+		  LEFT OUTER JOIN "order" AS o ON o.id = m.order_id
+		  LEFT OUTER JOIN usr AS u ON u.id = m.usr_id`,
+		alias: "m",
+		fields: map[string]string{
+			"reason":  "m.reason",
+			"delta":   "m.delta",
+			"created": "m.created",
+		},
+		orderBy:  "m.created",
+		orderDir: OrderDesc,
+	}
+	q.where("m.inventory_id = %s", inventoryID)
+	err = m.checkCursor(ctx, &q, opts)
+	if err == ErrCursorNotFound {
+		return nil, nil, ErrInventoryMovementNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	q2, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q2, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q2=%q, ...) failed", q2)
+	}
+	defer rows.Close()
+
+	movements := make([]*InventoryMovementRow, 0, 16)
+	for rows.Next() {
+		var r InventoryMovementRow
+		if err := rows.Scan(&r.id, &r.UUID, &r.inventoryID, &r.InventoryUUID, &r.SKU, &r.locationID,
+			&r.LocationCode, &r.Reason,
+			&r.Delta, &r.Balance, &r.orderID, &r.OrderUUID, &r.usrID, &r.UsrUUID,
+			&r.Note, &r.Created); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		movements = append(movements, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return movements, opts.page(&movements), nil
+}
@@ -0,0 +1,244 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Job status values. Pending jobs are waiting for a worker. Completed,
+// failed and cancelled jobs are finished.
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// ErrJobNotFound error
+var ErrJobNotFound = errors.New("postgres: job not found")
+
+// ErrJobNotCancellable is returned when cancelling a job that has
+// finished.
+var ErrJobNotCancellable = errors.New("postgres: job not cancellable")
+
+// ErrJobClaimLost is returned when a worker updates a job that is no
+// longer running under its claim. This happens once the job has finished
+// or has been claimed again by another worker after missing heartbeats.
+var ErrJobClaimLost = errors.New("postgres: job claim lost")
+
+// JobRow maps to a job row. UsrUUID is the user that created the job.
+type JobRow struct {
+	id              int
+	UUID            string
+	Typ             string
+	Status          string
+	UsrUUID         *string
+	Payload         []byte
+	Result          []byte
+	Progress        int
+	Total           int
+	ErrorCode       *string
+	ErrorMessage    *string
+	CancelRequested bool
+	Attempts        int
+	Started         *time.Time
+	Finished        *time.Time
+	Created         time.Time
+	Modified        time.Time
+}
+
+// jobColumns are the columns of a job for both SELECT and RETURNING
+// clauses.
+const jobColumns = `
+	id, uuid, typ, status, (SELECT u.uuid FROM usr AS u WHERE u.id = job.usr_id),
+	payload, result, progress, total, error_code, error_message,
+	cancel_requested, attempts, started, finished, created, modified`
+
+func scanJob(row *sql.Row) (*JobRow, error) {
+	var j JobRow
+	if err := row.Scan(&j.id, &j.UUID, &j.Typ, &j.Status, &j.UsrUUID, &j.Payload, &j.Result,
+		&j.Progress, &j.Total, &j.ErrorCode, &j.ErrorMessage, &j.CancelRequested, &j.Attempts,
+		&j.Started, &j.Finished, &j.Created, &j.Modified); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// CreateJob creates a pending job of the given type. The payload is the
+// JSON input of the job and the job is owned by the user with the given
+// usrUUID if set.
+func (m *PgModel) CreateJob(ctx context.Context, typ string, payload []byte, usrUUID *string) (*JobRow, error) {
+	q1 := `
+		INSERT INTO job (typ, status, usr_id, payload, created, modified)
+		VALUES ($1, $2, (SELECT id FROM usr WHERE uuid::text = $3), $4, NOW(), NOW())
+		RETURNING ` + jobColumns
+	j, err := scanJob(m.db.QueryRowContext(ctx, q1, typ, JobStatusPending, usrUUID, payload))
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return j, nil
+}
+
+// GetJob returns the job with the given uuid.
+func (m *PgModel) GetJob(ctx context.Context, jobUUID string) (*JobRow, error) {
+	q1 := "SELECT " + jobColumns + " FROM job WHERE uuid = $1"
+	j, err := scanJob(m.db.QueryRowContext(ctx, q1, jobUUID))
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return j, nil
+}
+
+// ClaimJob sets the oldest pending job to running and returns it. Jobs
+// left running with no heartbeat for longer than staleAfter are claimed
+// again. Jobs locked by another worker are skipped. ErrJobNotFound is
+// returned if there are no jobs to run. Each claim increments Attempts
+// which the worker passes back as its claim when updating the job.
+func (m *PgModel) ClaimJob(ctx context.Context, staleAfter time.Duration) (*JobRow, error) {
+	q1 := `
+		UPDATE job
+		SET status = $1, attempts = attempts + 1, heartbeat = NOW(),
+		  started = COALESCE(started, NOW()), modified = NOW()
+		WHERE id = (
+		  SELECT id FROM job
+		  WHERE status = $2 OR (status = $1 AND heartbeat < NOW() - $3 * INTERVAL '1 second')
+		  ORDER BY id
+		  LIMIT 1
+		  FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+	j, err := scanJob(m.db.QueryRowContext(ctx, q1, JobStatusRunning, JobStatusPending, int(staleAfter.Seconds())))
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return j, nil
+}
+
+// HeartbeatJob records that a running job is still being worked on under
+// the claim with the given attempt and returns whether the job has been
+// asked to cancel. Returns ErrJobClaimLost if the claim no longer holds.
+func (m *PgModel) HeartbeatJob(ctx context.Context, jobUUID string, attempt int) (bool, error) {
+	q1 := `
+		UPDATE job SET heartbeat = NOW()
+		WHERE uuid = $1 AND status = $2 AND attempts = $3
+		RETURNING cancel_requested`
+	var cancelRequested bool
+	err := m.db.QueryRowContext(ctx, q1, jobUUID, JobStatusRunning, attempt).Scan(&cancelRequested)
+	if err == sql.ErrNoRows {
+		return false, ErrJobClaimLost
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return cancelRequested, nil
+}
+
+// UpdateJobProgress sets the progress of a job running under the claim
+// with the given attempt out of total. Returns ErrJobClaimLost if the
+// claim no longer holds.
+func (m *PgModel) UpdateJobProgress(ctx context.Context, jobUUID string, attempt, progress, total int) error {
+	q1 := `
+		UPDATE job SET progress = $2, total = $3, heartbeat = NOW(), modified = NOW()
+		WHERE uuid = $1 AND status = $4 AND attempts = $5`
+	res, err := m.db.ExecContext(ctx, q1, jobUUID, progress, total, JobStatusRunning, attempt)
+	if err != nil {
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "postgres: rows affected")
+	}
+	if n == 0 {
+		return ErrJobClaimLost
+	}
+	return nil
+}
+
+// FinishJob sets the final status of a job running under the claim with
+// the given attempt along with its result or the code and message of the
+// error that stopped it. Returns ErrJobClaimLost if the claim no longer
+// holds.
+func (m *PgModel) FinishJob(ctx context.Context, jobUUID string, attempt int, status string, result []byte, errorCode, errorMessage *string) (*JobRow, error) {
+	q1 := `
+		UPDATE job
+		SET status = $2, result = $3, error_code = $4, error_message = $5,
+		  finished = NOW(), modified = NOW()
+		WHERE uuid = $1 AND status = $6 AND attempts = $7
+		RETURNING ` + jobColumns
+	j, err := scanJob(m.db.QueryRowContext(ctx, q1, jobUUID, status, result, errorCode, errorMessage, JobStatusRunning, attempt))
+	if err == sql.ErrNoRows {
+		return nil, ErrJobClaimLost
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return j, nil
+}
+
+// ReleaseJob returns a job running under the claim with the given attempt
+// to pending so that it is run again by the next available worker. Jobs
+// claimed since by another worker are left alone.
+func (m *PgModel) ReleaseJob(ctx context.Context, jobUUID string, attempt int) error {
+	q1 := `
+		UPDATE job SET status = $2, heartbeat = NULL, modified = NOW()
+		WHERE uuid = $1 AND status = $3 AND attempts = $4`
+	if _, err := m.db.ExecContext(ctx, q1, jobUUID, JobStatusPending, JobStatusRunning, attempt); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+	return nil
+}
+
+// CancelJob cancels a pending job straight away and asks the worker
+// running a running job to stop. Returns ErrJobNotCancellable if the
+// job has finished.
+func (m *PgModel) CancelJob(ctx context.Context, jobUUID string) (*JobRow, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "postgres: db.BeginTx failed")
+	}
+
+	q1 := "SELECT id, status FROM job WHERE uuid = $1 FOR UPDATE"
+	var jobID int
+	var status string
+	err = tx.QueryRowContext(ctx, q1, jobUUID).Scan(&jobID, &status)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+
+	var q2 string
+	switch status {
+	case JobStatusPending:
+		q2 = `
+			UPDATE job SET status = 'cancelled', cancel_requested = true,
+			  finished = NOW(), modified = NOW()
+			WHERE id = $1`
+	case JobStatusRunning:
+		q2 = "UPDATE job SET cancel_requested = true, modified = NOW() WHERE id = $1"
+	default:
+		tx.Rollback()
+		return nil, ErrJobNotCancellable
+	}
+	if _, err := tx.ExecContext(ctx, q2, jobID); err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	return m.GetJob(ctx, jobUUID)
+}
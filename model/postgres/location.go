@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultLocationCode is the code of the location that holds stock
+// updated without a location.
+const DefaultLocationCode = "default"
+
+// ErrLocationNotFound error
+var ErrLocationNotFound = errors.New("postgres: location not found")
+
+// ErrLocationCodeExists error for duplicates.
+var ErrLocationCodeExists = errors.New("postgres: location code exists")
+
+// LocationRow maps to a row in the location table.
+type LocationRow struct {
+	id          int
+	UUID        string
+	Code        string
+	Name        string
+	CountryCode string
+	Priority    int
+	Active      bool
+	Created     time.Time
+	Modified    time.Time
+}
+
+// CreateLocation creates a new stock location.
+func (m *PgModel) CreateLocation(ctx context.Context, code, name, countryCode string, priority int, active bool) (*LocationRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: CreateLocation(ctx, code=%q, name=%q, countryCode=%q, priority=%d, active=%t) started", code, name, countryCode, priority, active)
+
+	// 1. Check if the location code exists
+	q1 := `SELECT EXISTS(SELECT 1 FROM location WHERE code = $1) AS exists`
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, q1, code).Scan(&exists); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if exists {
+		return nil, ErrLocationCodeExists
+	}
+
+	// 2. Insert the new location
+	q2 := `
+		INSERT INTO location
+		  (code, name, country_code, priority, active, created, modified)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING
+		  id, uuid, code, name, country_code, priority, active, created, modified
+	`
+	var l LocationRow
+	row := m.db.QueryRowContext(ctx, q2, code, name, countryCode, priority, active)
+	if err := row.Scan(&l.id, &l.UUID, &l.Code, &l.Name, &l.CountryCode, &l.Priority, &l.Active, &l.Created, &l.Modified); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	return &l, nil
+}
+
+// GetLocationByUUID returns a single LocationRow by uuid.
+func (m *PgModel) GetLocationByUUID(ctx context.Context, locationUUID string) (*LocationRow, error) {
+	q1 := `
+		SELECT id, uuid, code, name, country_code, priority, active, created, modified
+		FROM location
+		WHERE uuid = $1
+	`
+	var l LocationRow
+	err := m.db.QueryRowContext(ctx, q1, locationUUID).Scan(&l.id, &l.UUID, &l.Code,
+		&l.Name, &l.CountryCode, &l.Priority, &l.Active, &l.Created, &l.Modified)
+	if err == sql.ErrNoRows {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return &l, nil
+}
+
+// GetLocationByCode returns a single LocationRow by code.
+func (m *PgModel) GetLocationByCode(ctx context.Context, code string) (*LocationRow, error) {
+	q1 := `
+		SELECT id, uuid, code, name, country_code, priority, active, created, modified
+		FROM location
+		WHERE code = $1
+	`
+	var l LocationRow
+	err := m.db.QueryRowContext(ctx, q1, code).Scan(&l.id, &l.UUID, &l.Code,
+		&l.Name, &l.CountryCode, &l.Priority, &l.Active, &l.Created, &l.Modified)
+	if err == sql.ErrNoRows {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return &l, nil
+}
+
+// GetLocations returns a page of stock locations ordered by priority
+// by default.
+func (m *PgModel) GetLocations(ctx context.Context, opts *ListOptions) ([]*LocationRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  id, uuid, code, name, country_code, priority, active, created, modified`,
+		from:  "location AS l",
+		alias: "l",
+		fields: map[string]string{
+			"code":         "l.code",
+			"name":         "l.name",
+			"country_code": "l.country_code",
+			"priority":     "l.priority",
+			"created":      "l.created",
+			"modified":     "l.modified",
+		},
+		orderBy:  "l.priority",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	locations := make([]*LocationRow, 0, 4)
+	for rows.Next() {
+		var l LocationRow
+		if err := rows.Scan(&l.id, &l.UUID, &l.Code, &l.Name, &l.CountryCode,
+			&l.Priority, &l.Active, &l.Created, &l.Modified); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		locations = append(locations, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return locations, opts.page(&locations), nil
+}
+
+// UpdateLocation updates a stock location.
+func (m *PgModel) UpdateLocation(ctx context.Context, locationUUID, code, name, countryCode string, priority int, active bool) (*LocationRow, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "postgres: db.BeginTx")
+	}
+
+	q1 := "SELECT id FROM location WHERE uuid = $1"
+	var locationID int
+	err = tx.QueryRowContext(ctx, q1, locationUUID).Scan(&locationID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "postgres: query row context failed for q1=%q", q1)
+	}
+
+	q2 := "SELECT EXISTS(SELECT 1 FROM location WHERE code = $1 AND id != $2) AS exists"
+	var exists bool
+	if err := tx.QueryRowContext(ctx, q2, code, locationID).Scan(&exists); err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	if exists {
+		tx.Rollback()
+		return nil, ErrLocationCodeExists
+	}
+
+	q3 := `
+		UPDATE location
+		SET code = $1, name = $2, country_code = $3, priority = $4, active = $5, modified = NOW()
+		WHERE id = $6
+		RETURNING
+		  id, uuid, code, name, country_code, priority, active, created, modified
+	`
+	var l LocationRow
+	row := tx.QueryRowContext(ctx, q3, code, name, countryCode, priority, active, locationID)
+	if err := row.Scan(&l.id, &l.UUID, &l.Code, &l.Name, &l.CountryCode, &l.Priority, &l.Active, &l.Created, &l.Modified); err != nil {
+		tx.Rollback()
+		return nil, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	return &l, nil
+}
+
+// locationIDByCode returns the id of the location with the given code
+// or the default location if code is nil.
+func locationIDByCode(ctx context.Context, q queryer, code *string) (int, error) {
+	c := DefaultLocationCode
+	if code != nil {
+		c = *code
+	}
+	q1 := "SELECT id FROM location WHERE code = $1"
+	var locationID int
+	err := q.QueryRowContext(ctx, q1, c).Scan(&locationID)
+	if err == sql.ErrNoRows {
+		return 0, ErrLocationNotFound
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return locationID, nil
+}
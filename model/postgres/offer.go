@@ -323,20 +323,33 @@ func (m *PgModel) GetOfferByUUID(ctx context.Context, offerUUID string) (*OfferJ
 	return &o, nil
 }
 
-// GetOffers returns a list of offers
-func (m *PgModel) GetOffers(ctx context.Context) ([]*OfferJoinRow, error) {
-	q1 := `
-		SELECT
+// GetOffers returns a page of offers.
+func (m *PgModel) GetOffers(ctx context.Context, opts *ListOptions) ([]*OfferJoinRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
 		  o.id, o.uuid, promo_rule_id,
 		  r.uuid as promo_rule_uuid, r.promo_rule_code,
-		  o.created, o.modified
-		FROM offer AS o
-		INNER JOIN promo_rule AS r
-		  ON r.id = o.promo_rule_id
-	`
-	rows, err := m.db.QueryContext(ctx, q1)
+		  o.created, o.modified`,
+		from:  "offer AS o INNER JOIN promo_rule AS r ON r.id = o.promo_rule_id",
+		alias: "o",
+		fields: map[string]string{
+			"promo_rule_code": "r.promo_rule_code",
+			"created":         "o.created",
+			"modified":        "o.modified",
+		},
+		orderBy:  "o.created",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
 	if err != nil {
-		return nil, errors.Wrapf(err,
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err,
 			"postgres: m.db.QueryContext(ctx) failed")
 	}
 	defer rows.Close()
@@ -347,14 +360,14 @@ func (m *PgModel) GetOffers(ctx context.Context) ([]*OfferJoinRow, error) {
 		if err = rows.Scan(&o.id, &o.UUID, &o.promoRuleID,
 			&o.PromoRuleUUID, &o.PromoRuleCode,
 			&o.Created, &o.Modified); err != nil {
-			return nil, errors.Wrap(err, "postgres: scan failed")
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
 		}
 		offers = append(offers, &o)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows.Err()")
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
 	}
-	return offers, nil
+	return offers, opts.page(&offers), nil
 }
 
 // DeleteOfferByUUID deletes an offer.
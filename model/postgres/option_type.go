@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrOptionTypeNotFound error
+var ErrOptionTypeNotFound = errors.New("postgres: option type not found")
+
+// ErrOptionTypeCodeExists error for duplicates.
+var ErrOptionTypeCodeExists = errors.New("postgres: option type code exists")
+
+// ErrOptionTypeInUse error
+var ErrOptionTypeInUse = errors.New("postgres: option type is used by product variants")
+
+// OptionTypeRow maps to a row in the option_type table.
+type OptionTypeRow struct {
+	id       int
+	UUID     string
+	Code     string
+	Name     string
+	Created  time.Time
+	Modified time.Time
+}
+
+// CreateOptionType creates a new option type such as size or colour.
+func (m *PgModel) CreateOptionType(ctx context.Context, code, name string) (*OptionTypeRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: CreateOptionType(ctx, code=%q, name=%q) started", code, name)
+
+	// 1. Check if the option type code exists
+	q1 := `SELECT EXISTS(SELECT 1 FROM option_type WHERE code = $1) AS exists`
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, q1, code).Scan(&exists); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if exists {
+		return nil, ErrOptionTypeCodeExists
+	}
+
+	// 2. Insert the new option type
+	q2 := `
+		INSERT INTO option_type
+		  (code, name, created, modified)
+		VALUES ($1, $2, NOW(), NOW())
+		RETURNING
+		  id, uuid, code, name, created, modified
+	`
+	var o OptionTypeRow
+	row := m.db.QueryRowContext(ctx, q2, code, name)
+	if err := row.Scan(&o.id, &o.UUID, &o.Code, &o.Name, &o.Created, &o.Modified); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	return &o, nil
+}
+
+// GetOptionTypeByUUID returns a single OptionTypeRow by uuid.
+func (m *PgModel) GetOptionTypeByUUID(ctx context.Context, optionTypeUUID string) (*OptionTypeRow, error) {
+	q1 := `
+		SELECT id, uuid, code, name, created, modified
+		FROM option_type
+		WHERE uuid = $1
+	`
+	var o OptionTypeRow
+	err := m.db.QueryRowContext(ctx, q1, optionTypeUUID).Scan(&o.id, &o.UUID, &o.Code,
+		&o.Name, &o.Created, &o.Modified)
+	if err == sql.ErrNoRows {
+		return nil, ErrOptionTypeNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return &o, nil
+}
+
+// GetOptionTypes returns a page of option types ordered by code by
+// default.
+func (m *PgModel) GetOptionTypes(ctx context.Context, opts *ListOptions) ([]*OptionTypeRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  id, uuid, code, name, created, modified`,
+		from:  "option_type AS o",
+		alias: "o",
+		fields: map[string]string{
+			"code":     "o.code",
+			"name":     "o.name",
+			"created":  "o.created",
+			"modified": "o.modified",
+		},
+		orderBy:  "o.code",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	optionTypes := make([]*OptionTypeRow, 0, 4)
+	for rows.Next() {
+		var o OptionTypeRow
+		if err := rows.Scan(&o.id, &o.UUID, &o.Code, &o.Name, &o.Created, &o.Modified); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		optionTypes = append(optionTypes, &o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return optionTypes, opts.page(&optionTypes), nil
+}
+
+// DeleteOptionType deletes an option type. Returns ErrOptionTypeInUse
+// if any product variant has a value for the option type.
+func (m *PgModel) DeleteOptionType(ctx context.Context, optionTypeUUID string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "postgres: db.BeginTx")
+	}
+
+	q1 := "SELECT id FROM option_type WHERE uuid = $1"
+	var optionTypeID int
+	err = tx.QueryRowContext(ctx, q1, optionTypeUUID).Scan(&optionTypeID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return ErrOptionTypeNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: query row context failed for q1=%q", q1)
+	}
+
+	q2 := "SELECT EXISTS(SELECT 1 FROM product_option WHERE option_type_id = $1) AS exists"
+	var exists bool
+	if err := tx.QueryRowContext(ctx, q2, optionTypeID).Scan(&exists); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	if exists {
+		tx.Rollback()
+		return ErrOptionTypeInUse
+	}
+
+	q3 := "DELETE FROM option_type WHERE id = $1"
+	if _, err := tx.ExecContext(ctx, q3, optionTypeID); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: exec context q3=%q", q3)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	return nil
+}
@@ -3,8 +3,6 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"math"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,7 +18,7 @@ var ErrOrderNotFound = errors.New("postgres: order not found")
 var ErrOrderItemsNotFound = errors.New("postgres: order items not found")
 
 // ErrCartEmpty error
-var ErrCartEmpty = errors.New("postgres: cart not found")
+var ErrCartEmpty = errors.New("postgres: cart empty")
 
 // Scan unmarshals JSON data into a ProductContent struct
 // func (oa *orderAddress) Scan(value interface{}) error {
@@ -40,27 +38,6 @@ var ErrCartEmpty = errors.New("postgres: cart not found")
 // 	return fmt.Errorf("scan value failed")
 // }
 
-func vat20Normalised(price int) int {
-	return int(math.Round(float64(price) * 0.2))
-}
-
-func discountMultiplier(disc int) float64 {
-	if disc < 0 || disc > 10000 {
-		return 0
-	}
-	return float64(10000-disc) / 10000.0
-}
-
-func totalSpend(cartProducts []*CartProductJoinRow) (int, int) {
-	totalExVAT := 0
-	totalVAT := 0
-	for _, i := range cartProducts {
-		totalExVAT = totalExVAT + (i.Qty * i.UnitPrice)
-		totalVAT = totalVAT + vat20Normalised(i.Qty*i.UnitPrice)
-	}
-	return totalExVAT, totalVAT
-}
-
 // NewOrderAddress object
 type NewOrderAddress struct {
 	ContactName string
@@ -74,40 +51,50 @@ type NewOrderAddress struct {
 
 // OrderRow holds a single row of data from the orders table.
 type OrderRow struct {
-	ID          int
-	UUID        string
-	usrID       *int
-	UsrUUID     *string
-	Status      string
-	Payment     string
-	ContactName *string
-	Email       *string
-	StripePI    *string
-	billingID   int
-	shippingID  int
-	Currency    string
-	TotalExVAT  int
-	VATTotal    int
-	TotalIncVAT int
-	Created     time.Time
-	Modified    time.Time
+	ID               int
+	UUID             string
+	usrID            *int
+	UsrUUID          *string
+	Status           string
+	Payment          string
+	ContactName      *string
+	Email            *string
+	StripePI         *string
+	billingID        int
+	shippingID       int
+	Currency         string
+	IncTax           bool
+	TotalExVAT       int
+	VATTotal         int
+	TotalIncVAT      int
+	Discount         int
+	ShippingCode     *string
+	ShippingPrice    int
+	ShippingDiscount int
+	ShippingVAT      int
+	Created          time.Time
+	Modified         time.Time
 }
 
 // OrderItemRow holds a single row of data from the order_item table.
+// Discount is from the promo rules of the line and OrderDiscount is the
+// share of the order discount allocated to the item.
 type OrderItemRow struct {
-	id        int
-	UUID      string
-	orderID   int
-	Path      string
-	SKU       string
-	Name      string
-	Qty       int
-	UnitPrice int
-	Currency  string
-	Discount  *int
-	TaxCode   string
-	VAT       int
-	Created   time.Time
+	id            int
+	UUID          string
+	orderID       int
+	Path          string
+	SKU           string
+	Name          string
+	Qty           int
+	UnitPrice     int
+	Currency      string
+	Discount      *int
+	OrderDiscount int
+	TaxCode       string
+	VAT           int
+	Backordered   int
+	Created       time.Time
 }
 
 // OrderAddressRow holds a single row of data from the order_address table.
@@ -127,9 +114,14 @@ type OrderAddressRow struct {
 }
 
 // AddGuestOrder adds a new guest order to the database returning the order row,
-// slice of order item rows, a billing and shipping address row.
+// slice of order item rows, a billing and shipping address row. The order is
+// priced using the default price list with any active offers and coupons
+// applied to the cart. If shippingTariffUUID is not nil the shipping tariff
+// is added to the order totals. Taxes are calculated for the country and
+// county of the shipping address. Any stock alerts raised by taking the
+// items from stock are returned.
 func (m *PgModel) AddGuestOrder(ctx context.Context, cartUUID, contactName, email string,
-	billing, shipping *NewOrderAddress) (*OrderRow, []*OrderItemRow, *OrderAddressRow, *OrderAddressRow, error) {
+	billing, shipping *NewOrderAddress, shippingTariffUUID *string) (*OrderRow, []*OrderItemRow, *OrderAddressRow, *OrderAddressRow, []*StockAlertRow, error) {
 	contextLogger := log.WithContext(ctx)
 	contextLogger.Debugf("postgres: AddGuestOrder(ctx, cartUUID=%q, contactName=%s, email=%s, ...)",
 		cartUUID, contactName, email)
@@ -137,7 +129,7 @@ func (m *PgModel) AddGuestOrder(ctx context.Context, cartUUID, contactName, emai
 	// start transaction
 	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, nil, nil, nil,
+		return nil, nil, nil, nil, nil,
 			errors.Wrap(err, "postgres: db.BeginTx")
 	}
 
@@ -147,61 +139,42 @@ func (m *PgModel) AddGuestOrder(ctx context.Context, cartUUID, contactName, emai
 	err = tx.QueryRowContext(ctx, q1, cartUUID).Scan(&cartID)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
-		return nil, nil, nil, nil, ErrCartNotFound
+		return nil, nil, nil, nil, nil, ErrCartNotFound
 	}
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, errors.Wrapf(err,
+		return nil, nil, nil, nil, nil, errors.Wrapf(err,
 			"postgres: query row context failed for q1=%q", q1)
 	}
 
 	contextLogger.Debugf("postgres: q1 returned a cartID=%d", cartID)
 
-	// 2. Get a list all all products in the cart with their price.
-	q2 := `
-		SELECT
-		  c.id, c.uuid,
-		  p.id, p.uuid,
-		  p.path, p.sku, p.name, qty,
-		  r.unit_price,
-		  c.created, c.modified
-		FROM cart_product AS c
-		JOIN product AS p
-		  ON c.product_id = p.id
-		JOIN price AS r
-		  ON c.product_id = r.product_id
-		WHERE r.break = 1 AND c.cart_id = $1
-	`
-	rows, err := tx.QueryContext(ctx, q2, cartID)
+	// 2. Price the products in the cart.
+	priceListID, err := priceListIDForUser(ctx, tx, nil)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrapf(err,
-			"postgres: tx.QueryContext(ctx, q2=%q, cartID=%d) failed",
-			q2, cartID)
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, err
 	}
-	defer rows.Close()
-
-	cartProducts := make([]*CartProductJoinRow, 0, 20)
-	for rows.Next() {
-		c := CartProductJoinRow{}
-		err = rows.Scan(&c.id, &c.UUID,
-			&c.productID, &c.ProductUUID,
-			&c.Path, &c.SKU, &c.Name, &c.Qty,
-			&c.UnitPrice,
-			&c.Created, &c.Modified)
-		if err != nil {
-			return nil, nil, nil, nil,
-				errors.Wrapf(err, "postgres: scan cart item %v", c)
-		}
-		c.CartUUID = cartUUID
-		cartProducts = append(cartProducts, &c)
+	dest := TaxDestination{
+		CountryCode: shipping.CountryCode,
+		Region:      shipping.County,
 	}
-	if err := rows.Err(); err != nil {
-		return nil, nil, nil, nil,
-			errors.Wrapf(err, "postgres: rows err")
+	pricing, err := priceCart(ctx, tx, m.tax, cartID, priceListID, shippingTariffUUID, &dest, time.Now())
+	if err == ErrShippingTariffNotFound || err == ErrTaxRateNotFound || err == ErrCartProductUnpublished {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, err
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, errors.Wrapf(err,
+			"postgres: priceCart(ctx, tx, cartID=%d, priceListID=%d, ...) failed",
+			cartID, priceListID)
+	}
+	contextLogger.Infof("postgres: %d products in this cart", len(pricing.Lines))
+	if len(pricing.Lines) == 0 {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, ErrCartEmpty
 	}
-	contextLogger.Infof("postgres: q2 returned %d products in this cart", len(cartProducts))
-
-	fmt.Printf("%#v\n", cartProducts[0])
 
 	// 3. Insert the billing and shipping addresses.
 	q3 := `
@@ -224,7 +197,7 @@ func (m *PgModel) AddGuestOrder(ctx context.Context, cartUUID, contactName, emai
 	stmt3, err := tx.PrepareContext(ctx, q3)
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, errors.Wrapf(err,
+		return nil, nil, nil, nil, nil, errors.Wrapf(err,
 			"postgres: tx prepare for q3=%q", q3)
 	}
 	defer stmt3.Close()
@@ -239,7 +212,7 @@ func (m *PgModel) AddGuestOrder(ctx context.Context, cartUUID, contactName, emai
 		&bv.Created, &bv.Modified)
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, errors.Wrap(err, "postgres: scan failed")
+		return nil, nil, nil, nil, nil, errors.Wrap(err, "postgres: scan failed")
 	}
 
 	var sv OrderAddressRow
@@ -252,94 +225,39 @@ func (m *PgModel) AddGuestOrder(ctx context.Context, cartUUID, contactName, emai
 		&sv.Created, &sv.Modified)
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, errors.Wrap(err, "postgres: scan failed")
+		return nil, nil, nil, nil, nil, errors.Wrap(err, "postgres: scan failed")
 	}
 
-	// 4. Insert the order row
-	q4 := `
-		INSERT INTO "order" (
-		  status, payment, contact_name, email,
-		  billing_id, shipping_id, currency, total_ex_vat,
-		  vat_total, total_inc_vat,
-		  created, modified
-		) VALUES (
-		  'incomplete', 'unpaid', $1, $2,
-		  $3, $4, $5, $6, $7, $8,
-		  NOW(), NOW()
-		) RETURNING
-		  id, uuid, usr_id, status, payment, contact_name, email, stripe_pi,
-		  billing_id, shipping_id, currency, total_ex_vat, vat_total,
-		  total_inc_vat, created, modified
-	`
-
-	o := OrderRow{}
-	currency := "GBP" // hardcoded for now but may come from elsewhere later.
-	totalExVAT, totalVAT := totalSpend(cartProducts)
-	totalIncVAT := totalExVAT + totalVAT
-
-	row = tx.QueryRowContext(ctx, q4, contactName, email,
-		bv.id, sv.id, currency, totalExVAT,
-		totalVAT, totalIncVAT)
-	err = row.Scan(&o.ID, &o.UUID, &o.usrID, &o.Status, &o.Payment,
-		&o.ContactName, &o.Email, &o.StripePI, &o.billingID,
-		&o.shippingID, &o.Currency, &o.TotalExVAT, &o.VATTotal,
-		&o.TotalIncVAT, &o.Created, &o.Modified)
+	// 4. Insert the order row and order items.
+	o, orderItems, alerts, err := insertOrder(ctx, tx, nil, &contactName, &email, bv.id, sv.id, pricing, m.allocation, dest.CountryCode)
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, errors.Wrapf(err,
-			"postgres: tx.QueryRowContext(ctx, q4=%q) failed", q4)
+		return nil, nil, nil, nil, nil, err
 	}
 
-	// 5. Prepared statement for individual order items.
-	q5 := `
-		INSERT INTO order_item (
-		  order_id, path, sku, name,
-		  qty, unit_price, discount, tax_code, vat, created
-		) VALUES (
-		  $1, $2, $3, $4,
-		  $5, $6, $7, $8, $9, NOW()
-		) RETURNING
-		  id, uuid, order_id, path, sku, name,
-		  qty, unit_price, currency, discount,
-		  tax_code, vat, created
-	`
-	stmt, err := tx.PrepareContext(ctx, q5)
-	if err != nil {
+	// 5. Spend any non-reusable coupons.
+	if err := spendCoupons(ctx, tx, pricing.spendCoupons); err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil,
-			errors.Wrapf(err, "postgres: tx prepare for q5=%q", q5)
+		return nil, nil, nil, nil, nil, err
 	}
-	defer stmt.Close()
 
-	orderItems := make([]*OrderItemRow, 0, len(cartProducts))
-	for _, row := range cartProducts {
-		oi := OrderItemRow{}
-		row := stmt.QueryRowContext(ctx, o.ID, row.Path, row.SKU, row.Name,
-			row.Qty, row.UnitPrice, nil, "T20",
-			vat20Normalised(row.Qty*row.UnitPrice))
-		err := row.Scan(&oi.id, &oi.UUID, &oi.orderID, &oi.Path, &oi.SKU,
-			&oi.Name, &oi.Qty, &oi.UnitPrice, &oi.Currency,
-			&oi.Discount, &oi.TaxCode, &oi.VAT, &oi.Created)
-		if err != nil {
-			tx.Rollback()
-			return nil, nil, nil, nil,
-				errors.Wrap(err, "postgres: stmt.QueryRowContext failed")
-		}
-		orderItems = append(orderItems, &oi)
-	}
 	if err := tx.Commit(); err != nil {
-		return nil, nil, nil, nil,
+		return nil, nil, nil, nil, nil,
 			errors.Wrap(err, "postgres: tx.Commit() failed")
 	}
 
-	return &o, orderItems, &bv, &sv, nil
+	return o, orderItems, &bv, &sv, alerts, nil
 }
 
-// AddOrder adds a new order to the database returning the order row. If
-// shipping is nil ship_tb (ship to billing address) is set to true.
+// AddOrder adds a new order to the database returning the order row. The
+// order is priced using the user's price list with any active offers and
+// coupons applied to the cart. If shippingTariffUUID is not nil the
+// shipping tariff is added to the order totals. Taxes are calculated for
+// the country and county of the shipping address.
 // Returns both the OrderRow and list of OrderItemRows as well as the
-// order total including VAT to be paid, or nil, nil, 0 if an error occurs.
-func (m *PgModel) AddOrder(ctx context.Context, cartUUID, userUUID, billingUUID, shippingUUID string) (*OrderRow, []*OrderItemRow, *UsrRow, *OrderAddressRow, *OrderAddressRow, error) {
+// user and the billing and shipping addresses and any stock alerts raised
+// by taking the items from stock.
+func (m *PgModel) AddOrder(ctx context.Context, cartUUID, userUUID, billingUUID, shippingUUID string, shippingTariffUUID *string) (*OrderRow, []*OrderItemRow, *UsrRow, *OrderAddressRow, *OrderAddressRow, []*StockAlertRow, error) {
 	contextLogger := log.WithContext(ctx)
 	contextLogger.Debugf("postgres: AddOrder(ctx, cartUUID=%q, userUUID=%q, billingUUID=%q, shippingUUID=%q, ...)",
 		cartUUID, userUUID, billingUUID, shippingUUID)
@@ -347,7 +265,7 @@ func (m *PgModel) AddOrder(ctx context.Context, cartUUID, userUUID, billingUUID,
 	// start transaction
 	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, nil, nil, nil, nil,
+		return nil, nil, nil, nil, nil, nil,
 			errors.Wrap(err, "postgres: db.BeginTx")
 	}
 
@@ -357,79 +275,40 @@ func (m *PgModel) AddOrder(ctx context.Context, cartUUID, userUUID, billingUUID,
 	err = tx.QueryRowContext(ctx, q1, cartUUID).Scan(&cartID)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil, ErrCartNotFound
+		return nil, nil, nil, nil, nil, nil, ErrCartNotFound
 	}
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil, errors.Wrapf(err,
+		return nil, nil, nil, nil, nil, nil, errors.Wrapf(err,
 			"postgres: query row context failed for q1=%q", q1)
 	}
 	contextLogger.Debugf("postgres: q1 returned cart id of %d", cartID)
 
-	// 2. Get a list all all products in the cart with their price.
-	q2 := `
-		SELECT
-		  c.id, c.uuid, p.path, p.name, p.sku,
-		  qty, r.unit_price,
-		  c.created, c.modified
-		FROM cart_product AS c
-		JOIN product AS p
-		  ON c.product_id = p.id
-		JOIN price AS r
-		  ON c.product_id = r.product_id
-		WHERE r.break = 1 AND c.cart_id = $1
-	`
-	rows, err := tx.QueryContext(ctx, q2, cartID)
-	if err != nil {
-		return nil, nil, nil, nil, nil, errors.Wrapf(err,
-			"postgres: tx.QueryContext(ctx, q2=%q, cartID=%d) failed",
-			q2, cartID)
-	}
-	defer rows.Close()
-
-	cartProducts := make([]*CartProductJoinRow, 0, 20)
-	for rows.Next() {
-		c := CartProductJoinRow{}
-		err = rows.Scan(&c.id, &c.UUID, &c.Path, &c.Name, &c.SKU,
-			&c.Qty, &c.UnitPrice, &c.Created, &c.Modified)
-		if err != nil {
-			return nil, nil, nil, nil, nil,
-				errors.Wrapf(err, "postgres: scan %v", c)
-		}
-		cartProducts = append(cartProducts, &c)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, nil, nil, nil, nil,
-			errors.Wrapf(err, "postgres: rows err")
-	}
-
-	contextLogger.Infof("postgres: %d products in this cart", len(cartProducts))
-
-	// 3. Get the user
+	// 2. Get the user
 	var c UsrRow
-	q3 := `
+	q2 := `
 		SELECT
 		  id, uuid, uid, role, email, firstname, lastname, created, modified
 		FROM usr
 		WHERE
 		  uuid = $1
 	`
-	err = tx.QueryRowContext(ctx, q3, userUUID).Scan(&c.id, &c.UUID,
+	err = tx.QueryRowContext(ctx, q2, userUUID).Scan(&c.id, &c.UUID,
 		&c.UID, &c.Role, &c.Email, &c.Firstname,
 		&c.Lastname, &c.Created, &c.Modified)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil, ErrUserNotFound
+		return nil, nil, nil, nil, nil, nil, ErrUserNotFound
 	}
 	if err != nil {
-		return nil, nil, nil, nil, nil,
-			errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, nil,
+			errors.Wrapf(err, "postgres: query row context q2=%q", q2)
 	}
-	// userID = &c.id
 
-	// 4. Get the billing and shipping addresses
+	// 3. Get the billing and shipping addresses
 	// and make sure this user owns them.
-	q4 := `
+	q3 := `
 		SELECT
 		  id, uuid, usr_id, typ,
 		  contact_name, addr1, addr2,
@@ -438,46 +317,73 @@ func (m *PgModel) AddOrder(ctx context.Context, cartUUID, userUUID, billingUUID,
 		FROM address
 		WHERE uuid = $1 AND usr_id = $2
 	`
-	stmt4, err := tx.PrepareContext(ctx, q4)
+	stmt3, err := tx.PrepareContext(ctx, q3)
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil, errors.Wrapf(err,
-			"postgres: tx prepare for q4=%q", q4)
+		return nil, nil, nil, nil, nil, nil, errors.Wrapf(err,
+			"postgres: tx prepare for q3=%q", q3)
 	}
-	defer stmt4.Close()
+	defer stmt3.Close()
 
 	var abv AddressJoinRow
-	row := stmt4.QueryRowContext(ctx, billingUUID, c.id)
+	row := stmt3.QueryRowContext(ctx, billingUUID, c.id)
 	err = row.Scan(&abv.id, &abv.UUID, &abv.usrID, &abv.Typ,
 		&abv.ContactName, &abv.Addr1, &abv.Addr2,
 		&abv.City, &abv.County, &abv.Postcode,
 		&abv.CountryCode, &abv.Created, &abv.Modified)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil, ErrAddressNotFound
+		return nil, nil, nil, nil, nil, nil, ErrAddressNotFound
 	}
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil,
+		return nil, nil, nil, nil, nil, nil,
 			errors.Wrap(err, "postgres: scan failed")
 	}
 
 	var asv AddressJoinRow
-	row = stmt4.QueryRowContext(ctx, shippingUUID, c.id)
+	row = stmt3.QueryRowContext(ctx, shippingUUID, c.id)
 	err = row.Scan(&asv.id, &asv.UUID, &asv.usrID, &asv.Typ,
 		&asv.ContactName, &asv.Addr1, &asv.Addr2,
 		&asv.City, &asv.County, &asv.Postcode,
 		&asv.CountryCode, &asv.Created, &asv.Modified)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil, ErrAddressNotFound
+		return nil, nil, nil, nil, nil, nil, ErrAddressNotFound
 	}
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil,
+		return nil, nil, nil, nil, nil, nil,
 			errors.Wrap(err, "postgres: scan failed")
 	}
 
+	// 4. Price the products in the cart.
+	priceListID, err := priceListIDForUser(ctx, tx, &c.id)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	dest := TaxDestination{
+		CountryCode: asv.CountryCode,
+		Region:      asv.County,
+	}
+	pricing, err := priceCart(ctx, tx, m.tax, cartID, priceListID, shippingTariffUUID, &dest, time.Now())
+	if err == ErrShippingTariffNotFound || err == ErrTaxRateNotFound || err == ErrCartProductUnpublished {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, nil, errors.Wrapf(err,
+			"postgres: priceCart(ctx, tx, cartID=%d, priceListID=%d, ...) failed",
+			cartID, priceListID)
+	}
+	contextLogger.Infof("postgres: %d products in this cart", len(pricing.Lines))
+	if len(pricing.Lines) == 0 {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, nil, ErrCartEmpty
+	}
+
 	// 5. Insert the billing and shipping addresses.
 	q5 := `
 		INSERT INTO order_address (
@@ -496,7 +402,7 @@ func (m *PgModel) AddOrder(ctx context.Context, cartUUID, userUUID, billingUUID,
 	stmt5, err := tx.PrepareContext(ctx, q5)
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil, errors.Wrapf(err,
+		return nil, nil, nil, nil, nil, nil, errors.Wrapf(err,
 			"postgres: tx prepare for q5=%q", q5)
 	}
 	defer stmt5.Close()
@@ -510,7 +416,7 @@ func (m *PgModel) AddOrder(ctx context.Context, cartUUID, userUUID, billingUUID,
 		&bv.Created, &bv.Modified)
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil,
+		return nil, nil, nil, nil, nil, nil,
 			errors.Wrap(err, "postgres: scan failed")
 	}
 
@@ -523,89 +429,139 @@ func (m *PgModel) AddOrder(ctx context.Context, cartUUID, userUUID, billingUUID,
 		&sv.Created, &sv.Modified)
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, nil, nil, nil,
+		return nil, nil, nil, nil, nil, nil,
 			errors.Wrap(err, "postgres: scan failed")
 	}
 
-	// 6. Insert the order
-	q6 := `
+	// 6. Insert the order and order items
+	o, orderItems, alerts, err := insertOrder(ctx, tx, &c.id, nil, nil, bv.id, sv.id, pricing, m.allocation, dest.CountryCode)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	o.UsrUUID = &c.UUID
+
+	// 7. Spend any non-reusable coupons.
+	if err := spendCoupons(ctx, tx, pricing.spendCoupons); err != nil {
+		tx.Rollback()
+		return nil, nil, nil, nil, nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, nil, nil, nil, nil,
+			errors.Wrap(err, "postgres: tx.Commit() failed")
+	}
+
+	return o, orderItems, &c, &bv, &sv, alerts, nil
+}
+
+// insertOrder inserts a new order row and an order item row for each
+// line of the cart pricing and takes the items from stock using the
+// allocation strategy for the shipping country. Any stock alerts raised
+// are returned.
+func insertOrder(ctx context.Context, tx *sql.Tx, usrID *int, contactName, email *string, billingID, shippingID int, pricing *CartPricing, strategy, countryCode string) (*OrderRow, []*OrderItemRow, []*StockAlertRow, error) {
+	// 1. Insert the order row
+	q1 := `
 		INSERT INTO "order" (
-		  status, payment, usr_id,
+		  status, payment, usr_id, contact_name, email,
 		  billing_id, shipping_id, currency,
 		  total_ex_vat, vat_total, total_inc_vat,
+		  discount, shipping_code, shipping_price,
+		  shipping_discount, shipping_vat, inc_tax,
 		  created, modified
 		) VALUES (
-		  'incomplete', 'unpaid', $1,
-		  $2, $3, $4,
-		  $5, $6, $7,
+		  'pending', 'unpaid', $1, $2, $3,
+		  $4, $5, $6,
+		  $7, $8, $9,
+		  $10, $11, $12,
+		  $13, $14, $15,
 		  NOW(), NOW()
 		) RETURNING
 		  id, uuid, usr_id, status, payment, contact_name, email, stripe_pi,
 		  billing_id, shipping_id, currency, total_ex_vat, vat_total,
-		  total_inc_vat, created, modified
+		  total_inc_vat, discount, shipping_code, shipping_price,
+		  shipping_discount, shipping_vat, inc_tax, created, modified
 	`
+	var shippingCode *string
+	if pricing.ShippingTariff != nil {
+		shippingCode = &pricing.ShippingTariff.ShippingCode
+	}
 
 	o := OrderRow{}
 	currency := "GBP" // hardcoded for now but may come from elsewhere later.
-	totalExVAT, totalVAT := totalSpend(cartProducts)
-	totalIncVAT := totalExVAT + totalVAT
-
-	row = tx.QueryRowContext(ctx, q6, c.id,
-		bv.id, sv.id, currency, totalExVAT, totalVAT, totalIncVAT)
-	err = row.Scan(&o.ID, &o.UUID, &o.usrID, &o.Status, &o.Payment,
+	row := tx.QueryRowContext(ctx, q1, usrID, contactName, email,
+		billingID, shippingID, currency,
+		pricing.TotalExVAT, pricing.VATTotal, pricing.TotalIncVAT,
+		pricing.Discount, shippingCode, pricing.ShippingPrice,
+		pricing.ShippingDiscount, pricing.ShippingVAT, pricing.IncTax)
+	err := row.Scan(&o.ID, &o.UUID, &o.usrID, &o.Status, &o.Payment,
 		&o.ContactName, &o.Email, &o.StripePI,
 		&o.billingID, &o.shippingID, &o.Currency,
-		&o.TotalExVAT, &o.VATTotal,
-		&o.TotalIncVAT, &o.Created, &o.Modified)
+		&o.TotalExVAT, &o.VATTotal, &o.TotalIncVAT,
+		&o.Discount, &o.ShippingCode, &o.ShippingPrice,
+		&o.ShippingDiscount, &o.ShippingVAT, &o.IncTax, &o.Created, &o.Modified)
 	if err != nil {
-		tx.Rollback()
-		return nil, nil, nil, nil, nil, errors.Wrapf(err,
-			"postgres: tx.QueryRowContext(ctx, q6=%q) failed", q6)
+		return nil, nil, nil, errors.Wrapf(err,
+			"postgres: tx.QueryRowContext(ctx, q1=%q) failed", q1)
+	}
+	if err := insertOrderStatusHistory(ctx, tx, o.ID, nil, o.Status, nil); err != nil {
+		return nil, nil, nil, err
 	}
 
-	// 7. Insert the order items
-	q7 := `
+	// 2. Insert the order items
+	q2 := `
 		INSERT INTO order_item (
 		  order_id, path, sku, name,
-		  qty, unit_price, discount,
+		  qty, unit_price, discount, order_discount,
 		  tax_code, vat, created
 		) VALUES (
-		  $1, $2, $3, $4, $5, $6, $7, $8, $9, NOW()
+		  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW()
 		) RETURNING
 		  id, uuid, order_id, path, sku, name, qty, unit_price, currency,
-		  discount, tax_code, vat, created
+		  discount, order_discount, tax_code, vat, created
 	`
-	stmt7, err := tx.PrepareContext(ctx, q7)
-	if err != nil {
-		tx.Rollback()
-		return nil, nil, nil, nil, nil,
-			errors.Wrapf(err, "postgres: tx prepare for q7=%q", q7)
-	}
-	defer stmt7.Close()
-
-	orderItems := make([]*OrderItemRow, 0, len(cartProducts))
-	for _, t := range cartProducts {
+	stmt2, err := tx.PrepareContext(ctx, q2)
+	if err != nil {
+		return nil, nil, nil,
+			errors.Wrapf(err, "postgres: tx prepare for q2=%q", q2)
+	}
+	defer stmt2.Close()
+
+	orderItems := make([]*OrderItemRow, 0, len(pricing.Lines))
+	for _, l := range pricing.Lines {
+		// the discount on each line is from the line promo rules
+		// only as the order discount is recorded on the order. The
+		// line's share of the order discount is kept separately so
+		// the line total less both is the amount paid for the line.
+		var discount *int
+		if l.Discount > 0 {
+			d := l.Discount
+			discount = &d
+		}
 		oi := OrderItemRow{}
-		row := stmt7.QueryRowContext(ctx, o.ID,
-			t.Path, t.SKU, t.Name,
-			t.Qty, t.UnitPrice, nil,
-			"T20", vat20Normalised(t.Qty*t.UnitPrice))
+		row := stmt2.QueryRowContext(ctx, o.ID,
+			l.Path, l.SKU, l.Name,
+			l.Qty, l.UnitPrice, discount, l.orderDiscount,
+			l.TaxCode, l.VAT)
 		err := row.Scan(&oi.id, &oi.UUID, &oi.orderID, &oi.Path, &oi.SKU,
 			&oi.Name, &oi.Qty, &oi.UnitPrice, &oi.Currency,
-			&oi.Discount, &oi.TaxCode, &oi.VAT, &oi.Created)
+			&oi.Discount, &oi.OrderDiscount, &oi.TaxCode, &oi.VAT, &oi.Created)
 		if err != nil {
-			tx.Rollback()
-			return nil, nil, nil, nil, nil,
-				errors.Wrap(err, "postgres: stmt.QueryRowContext failed")
+			return nil, nil, nil,
+				errors.Wrap(err, "postgres: stmt2.QueryRowContext failed")
 		}
 		orderItems = append(orderItems, &oi)
 	}
-	if err := tx.Commit(); err != nil {
-		return nil, nil, nil, nil, nil,
-			errors.Wrap(err, "postgres: tx.Commit() failed")
-	}
 
-	return &o, orderItems, &c, &bv, &sv, nil
+	// 3. Take the items from stock.
+	backordered, alerts, err := reserveStock(ctx, tx, o.ID, pricing.Lines, strategy, countryCode)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, oi := range orderItems {
+		oi.Backordered = backordered[oi.SKU]
+	}
+	return &o, orderItems, alerts, nil
 }
 
 // GetOrderDetailsByUUID retrieves the order row and order item rows
@@ -628,7 +584,8 @@ func (m *PgModel) GetOrderDetailsByUUID(ctx context.Context, orderUUID string) (
 		  usr_id, u.uuid as usr_uuid, status, payment,
 		  contact_name, o.email, stripe_pi,
 		  billing_id, shipping_id, currency, total_ex_vat, vat_total,
-		  total_inc_vat, o.created, o.modified
+		  total_inc_vat, discount, shipping_code, shipping_price,
+		  shipping_discount, shipping_vat, inc_tax, o.created, o.modified
 		FROM "order" AS o
 		LEFT JOIN usr AS u
 		  ON o.usr_id = u.id
@@ -638,7 +595,8 @@ func (m *PgModel) GetOrderDetailsByUUID(ctx context.Context, orderUUID string) (
 	err = tx.QueryRowContext(ctx, q1, orderUUID).Scan(&o.ID, &o.UUID,
 		&o.usrID, &o.UsrUUID, &o.Status, &o.Payment, &o.ContactName, &o.Email, &o.StripePI,
 		&o.billingID, &o.shippingID, &o.Currency, &o.TotalExVAT, &o.VATTotal,
-		&o.TotalIncVAT, &o.Created, &o.Modified)
+		&o.TotalIncVAT, &o.Discount, &o.ShippingCode, &o.ShippingPrice,
+		&o.ShippingDiscount, &o.ShippingVAT, &o.IncTax, &o.Created, &o.Modified)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
 		return nil, nil, nil, nil, ErrOrderNotFound
@@ -655,8 +613,8 @@ func (m *PgModel) GetOrderDetailsByUUID(ctx context.Context, orderUUID string) (
 	q2 := `
 		SELECT
 		  id, uuid, order_id, path, sku, name,
-		  qty, unit_price, currency, discount,
-		  tax_code, vat, created
+		  qty, unit_price, currency, discount, order_discount,
+		  tax_code, vat, backordered, created
 		FROM order_item
 		WHERE order_id = $1
 	`
@@ -677,7 +635,7 @@ func (m *PgModel) GetOrderDetailsByUUID(ctx context.Context, orderUUID string) (
 		i := OrderItemRow{}
 		err = rows.Scan(&i.id, &i.UUID, &i.orderID, &i.Path, &i.SKU,
 			&i.Name, &i.Qty, &i.UnitPrice, &i.Currency,
-			&i.Discount, &i.TaxCode, &i.VAT, &i.Created)
+			&i.Discount, &i.OrderDiscount, &i.TaxCode, &i.VAT, &i.Backordered, &i.Created)
 		if err != nil {
 			return nil, nil, nil, nil,
 				errors.Wrapf(err, "postgres: scan order item %v", i)
@@ -736,21 +694,42 @@ func (m *PgModel) GetOrderDetailsByUUID(ctx context.Context, orderUUID string) (
 	return &o, orderProducts, &bv, &sv, nil
 }
 
-// GetOrders returns a slice of OrderRow for all orders.
-func (m *PgModel) GetOrders(ctx context.Context) ([]*OrderRow, error) {
-	q1 := `
-		SELECT
-		  id, uuid, usr_id, status, payment,
-		  contact_name, email, stripe_pi,
-		  billing_id, shipping_id, currency,
-		  total_ex_vat, vat_total, total_inc_vat,
-		  created, modified
-		FROM "order"
-		ORDER BY id DESC
-	`
-	rows, err := m.db.QueryContext(ctx, q1)
-	if err != nil {
-		return nil, errors.Wrapf(err, "m.db.QueryContext(ctx)")
+// GetOrders returns a page of orders newest first by default. If status
+// is not empty only orders with the status are returned.
+func (m *PgModel) GetOrders(ctx context.Context, opts *ListOptions, status string) ([]*OrderRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  o.id, o.uuid, o.usr_id, o.status, o.payment,
+		  o.contact_name, o.email, o.stripe_pi,
+		  o.billing_id, o.shipping_id, o.currency,
+		  o.total_ex_vat, o.vat_total, o.total_inc_vat,
+		  o.discount, o.shipping_code, o.shipping_price,
+		  o.shipping_discount, o.shipping_vat, o.inc_tax,
+		  o.created, o.modified`,
+		from:  `"order" AS o`,
+		alias: "o",
+		fields: map[string]string{
+			"status":        "o.status",
+			"total_inc_vat": "o.total_inc_vat",
+			"created":       "o.created",
+			"modified":      "o.modified",
+		},
+		orderBy:  "o.created",
+		orderDir: OrderDesc,
+	}
+	if status != "" {
+		q.where("o.status = %s", status)
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "m.db.QueryContext(ctx, q1=%q)", q1)
 	}
 	defer rows.Close()
 
@@ -761,17 +740,19 @@ func (m *PgModel) GetOrders(ctx context.Context) ([]*OrderRow, error) {
 			&o.Payment, &o.ContactName, &o.Email,
 			&o.StripePI, &o.billingID, &o.shippingID,
 			&o.Currency, &o.TotalExVAT, &o.VATTotal,
-			&o.TotalIncVAT, &o.Created, &o.Modified)
+			&o.TotalIncVAT, &o.Discount, &o.ShippingCode,
+			&o.ShippingPrice, &o.ShippingDiscount, &o.ShippingVAT,
+			&o.IncTax, &o.Created, &o.Modified)
 		if err != nil {
-			return nil, errors.Wrap(err, "scan failed")
+			return nil, nil, errors.Wrap(err, "scan failed")
 		}
 		orders = append(orders, &o)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "rows.Err()")
+		return nil, nil, errors.Wrap(err, "rows.Err()")
 	}
 
-	return orders, nil
+	return orders, opts.page(&orders), nil
 }
 
 // SetStripePaymentIntent sets payment intent id reference on an
@@ -791,12 +772,15 @@ func (m *PgModel) SetStripePaymentIntent(ctx context.Context, orderID, pi string
 	return nil
 }
 
-// RecordPayment marks the order with the given order ID and Stripe Intent
-// referenceas complete and paid.
-func (m *PgModel) RecordPayment(ctx context.Context, orderUUID, pi string, body []byte) (*OrderRow, []*OrderItemRow, *OrderAddressRow, *OrderAddressRow, error) {
+// RecordPayment marks the order with the given order ID and payment intent
+// reference as paid and records the payment result from the payment
+// provider typ. The order status is left unchanged. Returns
+// ErrOrderNotFound if no order has the order ID and payment intent or
+// ErrOrderPaymentUnchanged if the payment has already been recorded.
+func (m *PgModel) RecordPayment(ctx context.Context, orderUUID, pi, typ string, body []byte) (*OrderRow, []*OrderItemRow, *OrderAddressRow, *OrderAddressRow, error) {
 	contextLogger := log.WithContext(ctx)
-	contextLogger.Infof("postgres: RecordPayment(ctx, orderID=%q, pi=%q, body=%v",
-		orderUUID, pi, string(body))
+	contextLogger.Infof("postgres: RecordPayment(ctx, orderID=%q, pi=%q, typ=%q, body=%v",
+		orderUUID, pi, typ, string(body))
 
 	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -804,16 +788,30 @@ func (m *PgModel) RecordPayment(ctx context.Context, orderUUID, pi string, body
 			"postgres: db.BeginTx")
 	}
 
-	// 1. Update the order status
+	// 1. Update the order payment status unless already paid.
 	q1 := `
 		UPDATE "order"
-		SET status = 'completed', payment = 'paid', modified = NOW()
-		WHERE uuid = $1 AND stripe_pi = $2
+		SET payment = 'paid', modified = NOW()
+		WHERE uuid = $1 AND stripe_pi = $2 AND payment IN ('unpaid', 'failed')
 		RETURNING id
 	`
 	var orderID int
 	err = tx.QueryRowContext(ctx, q1, orderUUID, pi).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+
+		q := `SELECT EXISTS(SELECT 1 FROM "order" WHERE uuid = $1 AND stripe_pi = $2) AS exists`
+		var exists bool
+		if err := m.db.QueryRowContext(ctx, q, orderUUID, pi).Scan(&exists); err != nil {
+			return nil, nil, nil, nil, errors.Wrapf(err, "postgres: query row context q=%q", q)
+		}
+		if !exists {
+			return nil, nil, nil, nil, ErrOrderNotFound
+		}
+		return nil, nil, nil, nil, ErrOrderPaymentUnchanged
+	}
 	if err != nil {
+		tx.Rollback()
 		return nil, nil, nil, nil, errors.Wrapf(err,
 			"postgres: tx.ExecContext(ctx, q1=%q, pi=%q, orderID=%q)",
 			q1, pi, orderID)
@@ -824,14 +822,15 @@ func (m *PgModel) RecordPayment(ctx context.Context, orderUUID, pi string, body
 		INSERT INTO payment (
 		  order_id, typ, result, created
 		) VALUES (
-		  $1, 'stripe', $2, NOW()
+		  $1, $2, $3, NOW()
 		)
 	`
-	_, err = tx.ExecContext(ctx, q2, orderID, body)
+	_, err = tx.ExecContext(ctx, q2, orderID, typ, body)
 	if err != nil {
+		tx.Rollback()
 		return nil, nil, nil, nil, errors.Wrapf(err,
-			"postgres: tx.ExecContext(ctx, q2=%q, orderID=%d, body=%q)",
-			q2, orderID, body)
+			"postgres: tx.ExecContext(ctx, q2=%q, orderID=%d, typ=%q, body=%q)",
+			q2, orderID, typ, body)
 	}
 
 	// 3. Get the main order details.
@@ -841,7 +840,8 @@ func (m *PgModel) RecordPayment(ctx context.Context, orderUUID, pi string, body
 		  usr_id, u.uuid as usr_uuid, status, payment,
 		  contact_name, o.email, stripe_pi,
 		  billing_id, shipping_id, currency, total_ex_vat, vat_total,
-		  total_inc_vat, o.created, o.modified
+		  total_inc_vat, discount, shipping_code, shipping_price,
+		  shipping_discount, shipping_vat, inc_tax, o.created, o.modified
 		FROM "order" AS o
 		LEFT JOIN usr AS u
 		  ON o.usr_id = u.id
@@ -851,7 +851,8 @@ func (m *PgModel) RecordPayment(ctx context.Context, orderUUID, pi string, body
 	err = tx.QueryRowContext(ctx, q3, orderID).Scan(&o.ID, &o.UUID,
 		&o.usrID, &o.UsrUUID, &o.Status, &o.Payment, &o.ContactName, &o.Email, &o.StripePI,
 		&o.billingID, &o.shippingID, &o.Currency, &o.TotalExVAT, &o.VATTotal,
-		&o.TotalIncVAT, &o.Created, &o.Modified)
+		&o.TotalIncVAT, &o.Discount, &o.ShippingCode, &o.ShippingPrice,
+		&o.ShippingDiscount, &o.ShippingVAT, &o.IncTax, &o.Created, &o.Modified)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
 		return nil, nil, nil, nil, ErrOrderNotFound
@@ -868,8 +869,8 @@ func (m *PgModel) RecordPayment(ctx context.Context, orderUUID, pi string, body
 	q4 := `
 		SELECT
 		  id, uuid, order_id, path, sku, name,
-		  qty, unit_price, currency, discount,
-		  tax_code, vat, created
+		  qty, unit_price, currency, discount, order_discount,
+		  tax_code, vat, backordered, created
 		FROM order_item
 		WHERE order_id = $1
 	`
@@ -891,7 +892,7 @@ func (m *PgModel) RecordPayment(ctx context.Context, orderUUID, pi string, body
 		i := OrderItemRow{}
 		err = rows.Scan(&i.id, &i.UUID, &i.orderID, &i.Path, &i.SKU,
 			&i.Name, &i.Qty, &i.UnitPrice, &i.Currency,
-			&i.Discount, &i.TaxCode, &i.VAT, &i.Created)
+			&i.Discount, &i.OrderDiscount, &i.TaxCode, &i.VAT, &i.Backordered, &i.Created)
 		if err != nil {
 			return nil, nil, nil, nil, errors.Wrapf(err,
 				"postgres: scan order item %v", i)
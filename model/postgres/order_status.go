@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrOrderStatusChanged is returned when the status of an order was
+// changed by another request before the update could be made.
+var ErrOrderStatusChanged = errors.New("postgres: order status changed")
+
+// OrderStatusHistoryRow holds a single row of data from the
+// order_status_history table. FromStatus is nil for the row
+// recorded when the order is placed.
+type OrderStatusHistoryRow struct {
+	id         int
+	orderID    int
+	FromStatus *string
+	ToStatus   string
+	Note       *string
+	Created    time.Time
+}
+
+// insertOrderStatusHistory records a change of status for an order.
+func insertOrderStatusHistory(ctx context.Context, tx *sql.Tx, orderID int, from *string, to string, note *string) error {
+	q1 := `
+		INSERT INTO order_status_history
+		  (order_id, from_status, to_status, note, created)
+		VALUES
+		  ($1, $2, $3, $4, NOW())
+	`
+	if _, err := tx.ExecContext(ctx, q1, orderID, from, to, note); err != nil {
+		return errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q1=%q, orderID=%d, ...) failed", q1, orderID)
+	}
+	return nil
+}
+
+// UpdateOrderStatus changes the status of an order from one status to
+// another and records the change in the order status history. The
+// update only takes place if the order still has the from status. The
+// stock held by the order is released when it is cancelled.
+// Returns ErrOrderNotFound if the order does not exist or
+// ErrOrderStatusChanged if the order no longer has the from status.
+func (m *PgModel) UpdateOrderStatus(ctx context.Context, orderUUID, from, to string, note *string) error {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: UpdateOrderStatus(ctx, orderUUID=%q, from=%q, to=%q, note=%v) started", orderUUID, from, to, note)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "postgres: db.BeginTx")
+	}
+
+	// 1. Update the order status if unchanged since it was read.
+	q1 := `
+		UPDATE "order"
+		SET status = $3, modified = NOW()
+		WHERE uuid = $1 AND status = $2
+		RETURNING id
+	`
+	var orderID int
+	err = tx.QueryRowContext(ctx, q1, orderUUID, from, to).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+
+		// 2. Determine if the order exists at all.
+		q2 := `SELECT EXISTS(SELECT 1 FROM "order" WHERE uuid = $1) AS exists`
+		var exists bool
+		if err := m.db.QueryRowContext(ctx, q2, orderUUID).Scan(&exists); err != nil {
+			return errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+		}
+		if !exists {
+			return ErrOrderNotFound
+		}
+		return ErrOrderStatusChanged
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+
+	// 3. Record the change.
+	if err := insertOrderStatusHistory(ctx, tx, orderID, &from, to, note); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 4. Release the stock held by a cancelled order.
+	if to == "cancelled" {
+		if err := releaseOrderStock(ctx, tx, orderID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "postgres: tx.Commit() failed")
+	}
+	return nil
+}
+
+// GetOrderStatusHistoryByUUID returns the status changes of an order
+// oldest first. Returns ErrOrderNotFound if the order does not exist.
+func (m *PgModel) GetOrderStatusHistoryByUUID(ctx context.Context, orderUUID string) ([]*OrderStatusHistoryRow, error) {
+	q1 := `SELECT id FROM "order" WHERE uuid = $1`
+	var orderID int
+	err := m.db.QueryRowContext(ctx, q1, orderUUID).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+
+	q2 := `
+		SELECT id, order_id, from_status, to_status, note, created
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created ASC, id ASC
+	`
+	rows, err := m.db.QueryContext(ctx, q2, orderID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q2=%q, orderID=%d) failed", q2, orderID)
+	}
+	defer rows.Close()
+
+	history := make([]*OrderStatusHistoryRow, 0, 8)
+	for rows.Next() {
+		var h OrderStatusHistoryRow
+		if err := rows.Scan(&h.id, &h.orderID, &h.FromStatus, &h.ToStatus, &h.Note, &h.Created); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		history = append(history, &h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return history, nil
+}
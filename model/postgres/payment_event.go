@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrOrderPaymentUnchanged is returned when the payment status of an
+// order does not allow the update, usually because a webhook event has
+// already been applied.
+var ErrOrderPaymentUnchanged = errors.New("postgres: order payment unchanged")
+
+// ClaimPaymentEvent records a webhook event from the payment provider typ
+// as processed. Returns false if the event has already been claimed.
+func (m *PgModel) ClaimPaymentEvent(ctx context.Context, typ, eventID, eventType string) (bool, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: ClaimPaymentEvent(ctx, typ=%q, eventID=%q, eventType=%q) started", typ, eventID, eventType)
+
+	q1 := `
+		INSERT INTO payment_event
+		  (typ, event_id, event_type, created)
+		VALUES
+		  ($1, $2, $3, NOW())
+		ON CONFLICT (typ, event_id) DO NOTHING
+		RETURNING id
+	`
+	var id int
+	err := m.db.QueryRowContext(ctx, q1, typ, eventID, eventType).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return true, nil
+}
+
+// ReleasePaymentEvent removes a claimed webhook event so it is processed
+// again when it is next delivered.
+func (m *PgModel) ReleasePaymentEvent(ctx context.Context, typ, eventID string) error {
+	q1 := `DELETE FROM payment_event WHERE typ = $1 AND event_id = $2`
+	if _, err := m.db.ExecContext(ctx, q1, typ, eventID); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+	return nil
+}
+
+// UpdateOrderPayment sets the payment status of the order with the given
+// payment intent to to if it currently has one of the from statuses.
+// Returns the order UUID, ErrOrderNotFound if no order has the payment
+// intent or ErrOrderPaymentUnchanged if the order has another payment
+// status.
+func (m *PgModel) UpdateOrderPayment(ctx context.Context, pi string, from []string, to string) (string, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: UpdateOrderPayment(ctx, pi=%q, from=%v, to=%q) started", pi, from, to)
+
+	q1 := `
+		UPDATE "order"
+		SET payment = $3, modified = NOW()
+		WHERE stripe_pi = $1 AND payment::text = ANY($2)
+		RETURNING uuid
+	`
+	var orderUUID string
+	err := m.db.QueryRowContext(ctx, q1, pi, pq.Array(from), to).Scan(&orderUUID)
+	if err == sql.ErrNoRows {
+		q2 := `SELECT EXISTS(SELECT 1 FROM "order" WHERE stripe_pi = $1) AS exists`
+		var exists bool
+		if err := m.db.QueryRowContext(ctx, q2, pi).Scan(&exists); err != nil {
+			return "", errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+		}
+		if !exists {
+			return "", ErrOrderNotFound
+		}
+		return "", ErrOrderPaymentUnchanged
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return orderUUID, nil
+}
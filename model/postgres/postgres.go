@@ -7,18 +7,40 @@ import (
 	"github.com/pkg/errors"
 )
 
-// PgModel contains the database handle
+// PgModel contains the database handle, the tax calculator used
+// to price carts and orders and the strategy used to allocate stock.
 type PgModel struct {
-	db *sql.DB
+	db         *sql.DB
+	tax        TaxCalculator
+	allocation string
 }
 
-// NewPgModel creates a new PgModel instance
+// NewPgModel creates a new PgModel instance using the tax rate table
+// to calculate taxes and allocating stock in location priority order.
 func NewPgModel(db *sql.DB) *PgModel {
 	return &PgModel{
-		db: db,
+		db:         db,
+		tax:        NewRateTableTaxCalculator(db),
+		allocation: AllocationPriority,
 	}
 }
 
+// SetAllocationStrategy sets the strategy used to allocate stock from
+// locations when an order is placed. Must be AllocationPriority or
+// AllocationNearest.
+func (m *PgModel) SetAllocationStrategy(strategy string) error {
+	if strategy != AllocationPriority && strategy != AllocationNearest {
+		return errors.Errorf("postgres: unknown allocation strategy %q", strategy)
+	}
+	m.allocation = strategy
+	return nil
+}
+
+// AllocationStrategy returns the strategy used to allocate stock.
+func (m *PgModel) AllocationStrategy() string {
+	return m.allocation
+}
+
 // GetSchemaVersion returns the underlying schema version string.
 func (m *PgModel) GetSchemaVersion(ctx context.Context) (*string, error) {
 	query := "SELECT schema_version() AS schema_version"
@@ -73,12 +73,32 @@ func (m *PgModel) GetPPAssocGroup(ctx context.Context, ppAssocGroupID string) (*
 	return &p, nil
 }
 
-// GetPPAssocGroups returns a list of price lists.
-func (m *PgModel) GetPPAssocGroups(ctx context.Context) ([]*PPAssocGroupRow, error) {
-	q1 := "SELECT id, uuid, code, name, created, modified FROM pp_assoc_group"
-	rows, err := m.db.QueryContext(ctx, q1)
+// GetPPAssocGroups returns a page of product to product assoc groups.
+func (m *PgModel) GetPPAssocGroups(ctx context.Context, opts *ListOptions) ([]*PPAssocGroupRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  id, uuid, code, name, created, modified`,
+		from:  "pp_assoc_group AS g",
+		alias: "g",
+		fields: map[string]string{
+			"code":     "g.code",
+			"name":     "g.name",
+			"created":  "g.created",
+			"modified": "g.modified",
+		},
+		orderBy:  "g.created",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
 	}
 	defer rows.Close()
 
@@ -86,14 +106,14 @@ func (m *PgModel) GetPPAssocGroups(ctx context.Context) ([]*PPAssocGroupRow, err
 	for rows.Next() {
 		var p PPAssocGroupRow
 		if err = rows.Scan(&p.id, &p.UUID, &p.Code, &p.Name, &p.Created, &p.Modified); err != nil {
-			return nil, errors.Wrap(err, "postgres: scan failed")
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
 		}
 		ppAssocGroups = append(ppAssocGroups, &p)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows.Err()")
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
 	}
-	return ppAssocGroups, nil
+	return ppAssocGroups, opts.page(&ppAssocGroups), nil
 }
 
 // DeletePPAssocGroup deletes a product to product assoc group by id.
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
@@ -177,6 +178,45 @@ func (m *PgModel) GetPrices(ctx context.Context, productUUID, priceListUUID stri
 	return prices, nil
 }
 
+// GetPricesByProducts returns the prices of each of the products with the
+// given productUUIDs in the price list with the given priceListUUID
+// ordered by product and break.
+func (m *PgModel) GetPricesByProducts(ctx context.Context, productUUIDs []string, priceListUUID string) ([]*PriceJoinRow, error) {
+	q1 := `
+		SELECT
+		  r.id, r.uuid AS uuid, p.id AS product_id, p.uuid as product_uuid, p.path, p.sku,
+		  t.id as price_list_id, t.uuid as price_list_uuid, t.code,
+		  r.unit_price, r.break, r.created, r.modified
+		FROM product AS p
+		INNER JOIN price AS r
+		  ON p.id = r.product_id
+		INNER JOIN price_list AS t
+		  ON t.id = r.price_list_id
+		WHERE p.uuid::text = ANY($1) AND t.uuid = $2
+		ORDER BY p.id, r.break
+	`
+	rows, err := m.db.QueryContext(ctx, q1, pq.Array(productUUIDs), priceListUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query context q1=%q", q1)
+	}
+	defer rows.Close()
+
+	prices := make([]*PriceJoinRow, 0, len(productUUIDs))
+	for rows.Next() {
+		var p PriceJoinRow
+		if err := rows.Scan(&p.id, &p.UUID, &p.productID, &p.ProductUUID, &p.ProductPath, &p.ProductSKU,
+			&p.priceListID, &p.PriceListUUID, &p.PriceListCode,
+			&p.UnitPrice, &p.Break, &p.Created, &p.Modified); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		prices = append(prices, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return prices, nil
+}
+
 // TODO: fix this up
 //
 
@@ -106,17 +106,34 @@ func (m *PgModel) GetDefaultPriceListUUID(ctx context.Context) (string, error) {
 	return priceListID, nil
 }
 
-// GetPriceLists returns a list of price lists.
-func (m *PgModel) GetPriceLists(ctx context.Context) ([]*PriceListRow, error) {
-	q1 := `
-		SELECT
+// GetPriceLists returns a page of price lists.
+func (m *PgModel) GetPriceLists(ctx context.Context, opts *ListOptions) ([]*PriceListRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
 		  id, uuid, code, currency_code, strategy, inc_tax, name,
-		  description, created, modified
-		FROM price_list
-	`
-	rows, err := m.db.QueryContext(ctx, q1)
+		  description, created, modified`,
+		from:  "price_list AS l",
+		alias: "l",
+		fields: map[string]string{
+			"code":          "l.code",
+			"currency_code": "l.currency_code",
+			"name":          "l.name",
+			"created":       "l.created",
+			"modified":      "l.modified",
+		},
+		orderBy:  "l.created",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "m.db.QueryContext(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "m.db.QueryContext(ctx) failed")
 	}
 	defer rows.Close()
 
@@ -125,17 +142,17 @@ func (m *PgModel) GetPriceLists(ctx context.Context) ([]*PriceListRow, error) {
 		var p PriceListRow
 		err = rows.Scan(&p.id, &p.UUID, &p.Code, &p.CurrencyCode, &p.Strategy, &p.IncTax, &p.Name, &p.Description, &p.Created, &p.Modified)
 		if err == sql.ErrNoRows {
-			return nil, ErrPriceListNotFound
+			return nil, nil, ErrPriceListNotFound
 		}
 		if err != nil {
-			return nil, errors.Wrap(err, "scan failed")
+			return nil, nil, errors.Wrap(err, "scan failed")
 		}
 		tiers = append(tiers, &p)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "rows.Err()")
+		return nil, nil, errors.Wrap(err, "rows.Err()")
 	}
-	return tiers, nil
+	return tiers, opts.page(&tiers), nil
 }
 
 // UpdatePriceList updates a price list by price list uuid
@@ -0,0 +1,674 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx allowing the pricing
+// queries to run inside or outside of a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// PricingLine holds the calculated price of a single product in a cart.
+type PricingLine struct {
+	productID      int
+	ProductUUID    string
+	Path           string
+	SKU            string
+	Name           string
+	Qty            int
+	UnitPrice      int
+	Total          int
+	Discount       int
+	TaxCode        string
+	VAT            int
+	PromoRuleCodes []string
+
+	// share of the order level discount used when calculating the VAT
+	// and recorded on the order item.
+	orderDiscount int
+}
+
+// PricingPromo holds a promo rule that reduced the price of a cart.
+// CouponCode is nil if the promo rule was applied as an offer.
+type PricingPromo struct {
+	promoRuleID   int
+	PromoRuleUUID string
+	PromoRuleCode string
+	couponID      *int
+	CouponCode    *string
+	Target        string
+	Discount      int
+}
+
+// CartPricing holds the totals of a cart after all active offers and
+// valid cart coupons have been applied. SubTotal is the sum of all
+// lines before discounts. LinesDiscount is the sum of all product,
+// product set and category discounts and Discount is the discount
+// taken from the order total. If IncTax is true the line prices
+// include tax. Shipping prices never include tax.
+type CartPricing struct {
+	Lines            []*PricingLine
+	Promos           []*PricingPromo
+	IncTax           bool
+	SubTotal         int
+	LinesDiscount    int
+	Discount         int
+	ShippingTariff   *ShippingTariffRow
+	ShippingPrice    int
+	ShippingDiscount int
+	ShippingVAT      int
+	Taxes            []*PricingTax
+	TotalExVAT       int
+	VATTotal         int
+	TotalIncVAT      int
+
+	// ids of the non-reusable coupons to be spent if an order is placed.
+	spendCoupons []int
+}
+
+// PricingTax holds the tax due for a single tax code. Taxable is the
+// amount the tax is charged on after all discounts.
+type PricingTax struct {
+	TaxCode string
+	Taxable int
+	Tax     int
+}
+
+// pricingRule holds a promo rule that is a candidate for pricing a cart.
+type pricingRule struct {
+	id               int
+	uuid             string
+	code             string
+	shippingTariffID *int
+	amount           int
+	totalThreshold   *int
+	typ              string
+	target           string
+	couponID         *int
+	couponCode       *string
+	reusable         bool
+
+	// set of product ids this rule targets.
+	products map[int]bool
+}
+
+// addVariantTargets adds the variants of each product targeted by the
+// rules to the products the rules target.
+func addVariantTargets(rules []*pricingRule, variants map[int][]int) {
+	for _, r := range rules {
+		var ids []int
+		for id := range r.products {
+			ids = append(ids, variants[id]...)
+		}
+		for _, id := range ids {
+			r.products[id] = true
+		}
+	}
+}
+
+// promoRuleActive returns true if now falls within the optional
+// start and end dates of a promo rule.
+func promoRuleActive(startAt, endAt *time.Time, now time.Time) bool {
+	if startAt != nil && now.Before(*startAt) {
+		return false
+	}
+	if endAt != nil && now.After(*endAt) {
+		return false
+	}
+	return true
+}
+
+// promoDiscount returns the discount for a promo rule applied to the
+// remaining amount. Percentage rules hold the amount as 0 to 10000
+// (0.00% to 100.00%). Fixed discounts are multiplied by qty and never
+// exceed the remaining amount.
+func promoDiscount(typ string, amount, qty, remaining int) int {
+	if remaining <= 0 {
+		return 0
+	}
+	var d int
+	if typ == "percentage" {
+		if amount > 10000 {
+			amount = 10000
+		}
+		d = int(math.Round(float64(remaining) * float64(amount) / 10000.0))
+	} else {
+		d = amount * qty
+	}
+	if d > remaining {
+		return remaining
+	}
+	return d
+}
+
+// allocate splits amount across the weights in proportion. Any remainder
+// left over from rounding down is given to the earliest weights that can
+// take it. amount must not exceed the sum of the weights.
+func allocate(amount int, weights []int) []int {
+	shares := make([]int, len(weights))
+	sum := 0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum == 0 || amount == 0 {
+		return shares
+	}
+	allocated := 0
+	for i, w := range weights {
+		shares[i] = int(int64(amount) * int64(w) / int64(sum))
+		allocated += shares[i]
+	}
+	for i := 0; allocated < amount && i < len(weights); i++ {
+		if weights[i] > shares[i] {
+			shares[i]++
+			allocated++
+		}
+	}
+	return shares
+}
+
+// calcCartPricing applies the promo rules to the lines of a cart in the
+// order given. Product, product set and category rules discount the
+// lines they target. Total rules discount the order once the total
+// after line discounts reaches the threshold. Shipping tariff rules
+// discount the shipping if the given tariff is targeted. Percentage
+// discounts compound on the amount left by earlier rules. Taxes are
+// added afterwards by calcCartTaxes.
+func calcCartPricing(lines []*PricingLine, rules []*pricingRule, shipping *ShippingTariffRow) *CartPricing {
+	p := CartPricing{
+		Lines:  lines,
+		Promos: make([]*PricingPromo, 0, len(rules)),
+	}
+	for _, l := range lines {
+		l.Total = l.Qty * l.UnitPrice
+		l.Discount = 0
+		l.orderDiscount = 0
+		p.SubTotal += l.Total
+	}
+
+	applied := func(r *pricingRule, d int) {
+		p.Promos = append(p.Promos, &PricingPromo{
+			promoRuleID:   r.id,
+			PromoRuleUUID: r.uuid,
+			PromoRuleCode: r.code,
+			couponID:      r.couponID,
+			CouponCode:    r.couponCode,
+			Target:        r.target,
+			Discount:      d,
+		})
+		if r.couponID != nil && !r.reusable {
+			p.spendCoupons = append(p.spendCoupons, *r.couponID)
+		}
+	}
+
+	// 1. Product, product set and category promotions.
+	for _, r := range rules {
+		if r.target != "product" && r.target != "productset" && r.target != "category" {
+			continue
+		}
+		total := 0
+		for _, l := range lines {
+			if !r.products[l.productID] {
+				continue
+			}
+			d := promoDiscount(r.typ, r.amount, l.Qty, l.Total-l.Discount)
+			if d == 0 {
+				continue
+			}
+			l.Discount += d
+			l.PromoRuleCodes = append(l.PromoRuleCodes, r.code)
+			total += d
+		}
+		if total > 0 {
+			p.LinesDiscount += total
+			applied(r, total)
+		}
+	}
+
+	// 2. Order total promotions.
+	net := p.SubTotal - p.LinesDiscount
+	for _, r := range rules {
+		if r.target != "total" {
+			continue
+		}
+		if r.totalThreshold != nil && net < *r.totalThreshold {
+			continue
+		}
+		d := promoDiscount(r.typ, r.amount, 1, net-p.Discount)
+		if d > 0 {
+			p.Discount += d
+			applied(r, d)
+		}
+	}
+
+	// 3. Shipping promotions.
+	if shipping != nil {
+		p.ShippingTariff = shipping
+		p.ShippingPrice = shipping.Price
+		for _, r := range rules {
+			if r.target != "shipping_tariff" {
+				continue
+			}
+			if r.shippingTariffID == nil || *r.shippingTariffID != shipping.id {
+				continue
+			}
+			d := promoDiscount(r.typ, r.amount, 1, p.ShippingPrice-p.ShippingDiscount)
+			if d > 0 {
+				p.ShippingDiscount += d
+				applied(r, d)
+			}
+		}
+	}
+
+	// 4. Spread the order discount across the lines so each line
+	// carries the tax on the amount actually paid.
+	weights := make([]int, len(lines))
+	for i, l := range lines {
+		weights[i] = l.Total - l.Discount
+	}
+	for i, share := range allocate(p.Discount, weights) {
+		lines[i].orderDiscount = share
+	}
+	return &p
+}
+
+// calcCartTaxes uses the tax calculator to add the tax for each line
+// and the shipping to the cart pricing for the given destination. If
+// incTax is true the line prices include tax and the tax is taken out
+// of the amount paid for each line.
+func calcCartTaxes(ctx context.Context, p *CartPricing, tc TaxCalculator, dest *TaxDestination, incTax bool, at time.Time) error {
+	p.IncTax = incTax
+	p.Taxes = make([]*PricingTax, 0, 2)
+	p.VATTotal = 0
+	p.TotalExVAT = 0
+
+	taxes := make(map[string]*PricingTax)
+	addTax := func(taxCode string, taxable, tax int) {
+		t, ok := taxes[taxCode]
+		if !ok {
+			t = &PricingTax{TaxCode: taxCode}
+			taxes[taxCode] = t
+			p.Taxes = append(p.Taxes, t)
+		}
+		t.Taxable += taxable
+		t.Tax += tax
+	}
+	for _, l := range p.Lines {
+		if l.TaxCode == "" {
+			l.TaxCode = DefaultTaxCode
+		}
+		paid := l.Total - l.Discount - l.orderDiscount
+		vat, err := tc.CalcTax(ctx, dest, l.TaxCode, paid, incTax, at)
+		if err != nil {
+			return err
+		}
+		l.VAT = vat
+		taxable := paid
+		if incTax {
+			taxable -= vat
+		}
+		p.VATTotal += l.VAT
+		p.TotalExVAT += taxable
+		addTax(l.TaxCode, taxable, l.VAT)
+	}
+	if p.ShippingTariff != nil {
+		taxCode := p.ShippingTariff.TaxCode
+		if taxCode == "" {
+			taxCode = DefaultTaxCode
+		}
+		taxable := p.ShippingPrice - p.ShippingDiscount
+		vat, err := tc.CalcTax(ctx, dest, taxCode, taxable, false, at)
+		if err != nil {
+			return err
+		}
+		p.ShippingVAT = vat
+		p.VATTotal += p.ShippingVAT
+		p.TotalExVAT += taxable
+		addTax(taxCode, taxable, p.ShippingVAT)
+	}
+	p.TotalIncVAT = p.TotalExVAT + p.VATTotal
+	return nil
+}
+
+// priceCart loads the products, active offers and cart coupons for the
+// cart and calculates the totals using the given price list. If
+// shippingTariffUUID is not nil the shipping tariff is added to the
+// totals. Offers and coupons are ignored outside of the start and end
+// dates of their promo rule. Coupons that are void or used are ignored.
+// Taxes are calculated for dest. If dest is nil the country of the
+// shipping tariff is used or DefaultTaxCountryCode if there is no
+// shipping tariff. Tax rates held in the database are read using q.
+// Returns ErrShippingTariffNotFound if the shipping tariff does
+// not exist or ErrTaxRateNotFound if a tax rate is missing.
+func priceCart(ctx context.Context, q queryer, tc TaxCalculator, cartID, priceListID int, shippingTariffUUID *string, dest *TaxDestination, now time.Time) (*CartPricing, error) {
+	// 0. Determine if the price list prices include tax.
+	q0 := "SELECT inc_tax FROM price_list WHERE id = $1"
+	var incTax bool
+	if err := q.QueryRowContext(ctx, q0, priceListID).Scan(&incTax); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q0=%q", q0)
+	}
+
+	// 1. Get the products in the cart with their unit price.
+	q1 := `
+		SELECT
+		  c.product_id, p.uuid, p.path, p.sku, p.name, p.tax_code, c.qty, r.unit_price,
+		  (` + purchasableCondition + `)
+		FROM cart_product AS c
+		INNER JOIN product AS p
+		  ON p.id = c.product_id
+		INNER JOIN price AS r
+		  ON r.product_id = c.product_id AND r.price_list_id = $2 AND r.break = 1
+		WHERE c.cart_id = $1
+		ORDER BY c.created ASC
+	`
+	rows, err := q.QueryContext(ctx, q1, cartID, priceListID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: q.QueryContext(ctx, q1=%q, cartID=%d, priceListID=%d) failed", q1, cartID, priceListID)
+	}
+	defer rows.Close()
+
+	lines := make([]*PricingLine, 0, 16)
+	for rows.Next() {
+		var l PricingLine
+		var purchasable bool
+		if err := rows.Scan(&l.productID, &l.ProductUUID, &l.Path, &l.SKU, &l.Name, &l.TaxCode, &l.Qty, &l.UnitPrice, &purchasable); err != nil {
+			return nil, errors.Wrapf(err, "postgres: scan q1=%q", q1)
+		}
+		// products may have been unpublished since they were added.
+		if !purchasable {
+			return nil, ErrCartProductUnpublished
+		}
+		lines = append(lines, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	// 2. Get the shipping tariff
+	var shipping *ShippingTariffRow
+	if shippingTariffUUID != nil {
+		q2 := `
+			SELECT
+			  id, uuid, country_code, shipping_code, name, price,
+			  COALESCE(tax_code, ''), created, modified
+			FROM shipping_tariff
+			WHERE uuid = $1
+		`
+		var s ShippingTariffRow
+		err := q.QueryRowContext(ctx, q2, *shippingTariffUUID).Scan(&s.id, &s.UUID, &s.CountryCode, &s.ShippingCode, &s.Name, &s.Price, &s.TaxCode, &s.Created, &s.Modified)
+		if err == sql.ErrNoRows {
+			return nil, ErrShippingTariffNotFound
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+		}
+		shipping = &s
+	}
+
+	// 3. Get the active offers followed by the coupons applied to the cart.
+	q3 := `
+		SELECT
+		  r.id, r.uuid, r.promo_rule_code, r.product_id, r.product_set_id,
+		  r.category_id, r.shipping_tariff_id, r.start_at, r.end_at,
+		  r.amount, r.total_threshold, r.type, r.target,
+		  NULL, NULL, false, false, 0
+		FROM offer AS o
+		INNER JOIN promo_rule AS r
+		  ON r.id = o.promo_rule_id
+		UNION ALL
+		SELECT
+		  r.id, r.uuid, r.promo_rule_code, r.product_id, r.product_set_id,
+		  r.category_id, r.shipping_tariff_id, r.start_at, r.end_at,
+		  r.amount, r.total_threshold, r.type, r.target,
+		  u.id, u.coupon_code, u.void, u.reusable, u.spend_count
+		FROM cart_coupon AS c
+		INNER JOIN coupon AS u
+		  ON u.id = c.coupon_id
+		INNER JOIN promo_rule AS r
+		  ON r.id = u.promo_rule_id
+		WHERE c.cart_id = $1
+	`
+	rows3, err := q.QueryContext(ctx, q3, cartID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: q.QueryContext(ctx, q3=%q, cartID=%d) failed", q3, cartID)
+	}
+	defer rows3.Close()
+
+	type candidate struct {
+		rule         pricingRule
+		productID    *int
+		productSetID *int
+		categoryID   *int
+	}
+	candidates := make([]*candidate, 0, 8)
+	seen := make(map[int]bool)
+	for rows3.Next() {
+		var c candidate
+		var startAt, endAt *time.Time
+		var void bool
+		var spendCount int
+		if err := rows3.Scan(&c.rule.id, &c.rule.uuid, &c.rule.code, &c.productID, &c.productSetID,
+			&c.categoryID, &c.rule.shippingTariffID, &startAt, &endAt,
+			&c.rule.amount, &c.rule.totalThreshold, &c.rule.typ, &c.rule.target,
+			&c.rule.couponID, &c.rule.couponCode, &void, &c.rule.reusable, &spendCount); err != nil {
+			return nil, errors.Wrapf(err, "postgres: scan q3=%q", q3)
+		}
+		if !promoRuleActive(startAt, endAt, now) {
+			continue
+		}
+		if void || (c.rule.couponID != nil && !c.rule.reusable && spendCount > 0) {
+			continue
+		}
+
+		// a promo rule is applied at most once even if it is
+		// both an offer and a coupon applied to the cart.
+		if seen[c.rule.id] {
+			continue
+		}
+		seen[c.rule.id] = true
+		candidates = append(candidates, &c)
+	}
+	if err := rows3.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows3.Err()")
+	}
+
+	// 4. Resolve the products targeted by each rule.
+	q4 := "SELECT product_id FROM product_set_item WHERE product_set_id = $1"
+	q5 := `
+		SELECT DISTINCT pc.product_id
+		FROM product_category AS pc
+		INNER JOIN category AS c
+		  ON c.id = pc.category_id
+		INNER JOIN category AS t
+		  ON c.lft >= t.lft AND c.rgt <= t.rgt
+		WHERE t.id = $1
+	`
+	rules := make([]*pricingRule, 0, len(candidates))
+	for _, c := range candidates {
+		c.rule.products = make(map[int]bool)
+		var query string
+		var arg *int
+		switch c.rule.target {
+		case "product":
+			if c.productID != nil {
+				c.rule.products[*c.productID] = true
+			}
+		case "productset":
+			query, arg = q4, c.productSetID
+		case "category":
+			query, arg = q5, c.categoryID
+		}
+		if query != "" && arg != nil {
+			ids, err := queryProductIDs(ctx, q, query, *arg)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				c.rule.products[id] = true
+			}
+		}
+		rules = append(rules, &c.rule)
+	}
+
+	// Rules targeting a parent product also target all of its variants.
+	parentIDs := make([]int, 0, 16)
+	for _, r := range rules {
+		for id := range r.products {
+			parentIDs = append(parentIDs, id)
+		}
+	}
+	if len(parentIDs) > 0 {
+		variants, err := variantIDsByParent(ctx, q, parentIDs)
+		if err != nil {
+			return nil, err
+		}
+		addVariantTargets(rules, variants)
+	}
+
+	// 5. Apply the discounts followed by the taxes.
+	if dest == nil || dest.CountryCode == "" {
+		dest = &TaxDestination{CountryCode: DefaultTaxCountryCode}
+		if shipping != nil {
+			dest.CountryCode = shipping.CountryCode
+		}
+	}
+	pricing := calcCartPricing(lines, rules, shipping)
+	if err := calcCartTaxes(ctx, pricing, taxCalculatorFor(tc, q), dest, incTax, now); err != nil {
+		if err == ErrTaxRateNotFound {
+			return nil, err
+		}
+		return nil, errors.Wrapf(err, "postgres: calcCartTaxes(ctx, pricing, tc, dest=%v, incTax=%t, now) failed", dest, incTax)
+	}
+	return pricing, nil
+}
+
+// GetCartTotals prices the cart using the same calculation used when
+// placing an order. If userUUID is empty the default price list is used.
+// If shippingTariffUUID is not nil the shipping tariff is added to the
+// totals. Taxes are calculated for dest if not nil. Returns
+// ErrCartNotFound, ErrUserNotFound, ErrShippingTariffNotFound or
+// ErrTaxRateNotFound.
+func (m *PgModel) GetCartTotals(ctx context.Context, cartUUID, userUUID string, shippingTariffUUID *string, dest *TaxDestination) (*CartPricing, error) {
+	// 1. Check the cart exists
+	q1 := "SELECT id FROM cart WHERE uuid = $1"
+	var cartID int
+	err := m.db.QueryRowContext(ctx, q1, cartUUID).Scan(&cartID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCartNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context failed for q1=%q", q1)
+	}
+
+	// 2. Get the user if set
+	var usrID *int
+	if userUUID != "" {
+		q2 := "SELECT id FROM usr WHERE uuid = $1"
+		var id int
+		err := m.db.QueryRowContext(ctx, q2, userUUID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "postgres: query row context failed for q2=%q", q2)
+		}
+		usrID = &id
+	}
+
+	// 3. Price the cart
+	priceListID, err := priceListIDForUser(ctx, m.db, usrID)
+	if err != nil {
+		return nil, err
+	}
+	pricing, err := priceCart(ctx, m.db, m.tax, cartID, priceListID, shippingTariffUUID, dest, time.Now())
+	if err == ErrShippingTariffNotFound || err == ErrTaxRateNotFound || err == ErrCartProductUnpublished {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: priceCart(ctx, m.db, cartID=%d, priceListID=%d, ...) failed", cartID, priceListID)
+	}
+	return pricing, nil
+}
+
+func queryProductIDs(ctx context.Context, q queryer, query string, arg int) ([]int, error) {
+	rows, err := q.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: q.QueryContext(ctx, query=%q, arg=%d) failed", query, arg)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0, 16)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrapf(err, "postgres: scan query=%q", query)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return ids, nil
+}
+
+// spendCoupons increments the spend count of the non-reusable coupons
+// used to price an order. Returns ErrCouponUsed if any of the coupons
+// has been spent by another order in the meantime.
+func spendCoupons(ctx context.Context, tx *sql.Tx, couponIDs []int) error {
+	q1 := `
+		UPDATE coupon
+		SET spend_count = spend_count + 1, modified = NOW()
+		WHERE id = $1 AND spend_count = 0
+	`
+	for _, id := range couponIDs {
+		res, err := tx.ExecContext(ctx, q1, id)
+		if err != nil {
+			return errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q1=%q, couponID=%d) failed", q1, id)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "postgres: res.RowsAffected()")
+		}
+		if n == 0 {
+			return ErrCouponUsed
+		}
+	}
+	return nil
+}
+
+// priceListIDForUser returns the price list id of the user or the
+// default price list if usrID is nil or the user has no price list.
+func priceListIDForUser(ctx context.Context, q queryer, usrID *int) (int, error) {
+	if usrID != nil {
+		q1 := "SELECT price_list_id FROM usr WHERE id = $1"
+		var priceListID *int
+		err := q.QueryRowContext(ctx, q1, *usrID).Scan(&priceListID)
+		if err == sql.ErrNoRows {
+			return 0, ErrUserNotFound
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+		}
+		if priceListID != nil {
+			return *priceListID, nil
+		}
+	}
+	q2 := "SELECT id FROM price_list WHERE code = 'default'"
+	var priceListID int
+	err := q.QueryRowContext(ctx, q2).Scan(&priceListID)
+	if err == sql.ErrNoRows {
+		return 0, ErrDefaultPriceListNotFound
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	return priceListID, nil
+}
@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(i int) *int { return &i }
+
+// fixedRateTaxCalculator uses the same rates for every destination.
+type fixedRateTaxCalculator map[string]int
+
+func (c fixedRateTaxCalculator) CalcTax(ctx context.Context, dest *TaxDestination, taxCode string, amount int, incTax bool, at time.Time) (int, error) {
+	rate, ok := c[taxCode]
+	if !ok {
+		return 0, ErrTaxRateNotFound
+	}
+	return taxOnAmount(rate, amount, incTax), nil
+}
+
+var ukVAT = fixedRateTaxCalculator{"T20": 2000, "T5": 500, "T0": 0}
+
+func testPricing(t *testing.T, lines []*PricingLine, rules []*pricingRule, shipping *ShippingTariffRow) *CartPricing {
+	p := calcCartPricing(lines, rules, shipping)
+	dest := TaxDestination{CountryCode: "GB"}
+	if err := calcCartTaxes(context.Background(), p, ukVAT, &dest, false, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func testLines() []*PricingLine {
+	return []*PricingLine{
+		{productID: 1, SKU: "DESK", Qty: 2, UnitPrice: 5000},
+		{productID: 2, SKU: "CHAIR", Qty: 1, UnitPrice: 2500},
+	}
+}
+
+func TestCalcCartPricingNoRules(t *testing.T) {
+	p := testPricing(t, testLines(), nil, nil)
+	assert.Equal(t, 12500, p.SubTotal)
+	assert.Equal(t, 0, p.LinesDiscount)
+	assert.Equal(t, 0, p.Discount)
+	assert.Equal(t, 12500, p.TotalExVAT)
+	assert.Equal(t, 2500, p.VATTotal)
+	assert.Equal(t, 15000, p.TotalIncVAT)
+	assert.Empty(t, p.Promos)
+}
+
+func TestCalcCartPricingProductPercentage(t *testing.T) {
+	rules := []*pricingRule{
+		{id: 1, code: "DESK10", typ: "percentage", target: "product", amount: 1000, products: map[int]bool{1: true}},
+	}
+	p := testPricing(t, testLines(), rules, nil)
+	assert.Equal(t, 1000, p.Lines[0].Discount)
+	assert.Equal(t, []string{"DESK10"}, p.Lines[0].PromoRuleCodes)
+	assert.Equal(t, 0, p.Lines[1].Discount)
+	assert.Equal(t, 1000, p.LinesDiscount)
+	assert.Equal(t, 11500, p.TotalExVAT)
+	assert.Equal(t, 2300, p.VATTotal)
+	assert.Len(t, p.Promos, 1)
+}
+
+func TestCalcCartPricingCompoundPercentage(t *testing.T) {
+	rules := []*pricingRule{
+		{id: 1, code: "A", typ: "percentage", target: "product", amount: 5000, products: map[int]bool{2: true}},
+		{id: 2, code: "B", typ: "percentage", target: "category", amount: 5000, products: map[int]bool{2: true}},
+	}
+	p := testPricing(t, testLines(), rules, nil)
+
+	// 50% of 2500 then 50% of the remaining 1250
+	assert.Equal(t, 1875, p.Lines[1].Discount)
+}
+
+func TestCalcCartPricingFixedCapped(t *testing.T) {
+	rules := []*pricingRule{
+		{id: 1, code: "BIG", typ: "fixed", target: "productset", amount: 4000, products: map[int]bool{2: true}},
+	}
+	p := testPricing(t, testLines(), rules, nil)
+	assert.Equal(t, 2500, p.Lines[1].Discount)
+	assert.Equal(t, 0, p.Lines[1].VAT)
+}
+
+func TestCalcCartPricingTotalThreshold(t *testing.T) {
+	rules := []*pricingRule{
+		{id: 1, code: "OVER200", typ: "fixed", target: "total", amount: 1000, totalThreshold: intPtr(20000)},
+		{id: 2, code: "OVER100", typ: "fixed", target: "total", amount: 1000, totalThreshold: intPtr(10000)},
+	}
+	p := testPricing(t, testLines(), rules, nil)
+	assert.Equal(t, 1000, p.Discount)
+	assert.Len(t, p.Promos, 1)
+	assert.Equal(t, "OVER100", p.Promos[0].PromoRuleCode)
+
+	// the order discount is spread over the lines in proportion
+	assert.Equal(t, 800, p.Lines[0].orderDiscount)
+	assert.Equal(t, 200, p.Lines[1].orderDiscount)
+	assert.Equal(t, 11500, p.TotalExVAT)
+	assert.Equal(t, 2300, p.VATTotal)
+	assert.Equal(t, p.TotalExVAT+p.VATTotal, p.TotalIncVAT)
+}
+
+func TestCalcCartPricingShipping(t *testing.T) {
+	shipping := &ShippingTariffRow{id: 7, ShippingCode: "UK_NEXT_DAY", Price: 1000}
+	rules := []*pricingRule{
+		{id: 1, code: "HALFSHIP", typ: "percentage", target: "shipping_tariff", amount: 5000, shippingTariffID: intPtr(7)},
+		{id: 2, code: "OTHERSHIP", typ: "percentage", target: "shipping_tariff", amount: 10000, shippingTariffID: intPtr(8)},
+	}
+	p := testPricing(t, testLines(), rules, shipping)
+	assert.Equal(t, 1000, p.ShippingPrice)
+	assert.Equal(t, 500, p.ShippingDiscount)
+	assert.Equal(t, 100, p.ShippingVAT)
+	assert.Equal(t, 13000, p.TotalExVAT)
+	assert.Equal(t, 2600, p.VATTotal)
+
+	// lines and shipping share the same tax code
+	assert.Len(t, p.Taxes, 1)
+	assert.Equal(t, 13000, p.Taxes[0].Taxable)
+	assert.Equal(t, 2600, p.Taxes[0].Tax)
+}
+
+func TestCalcCartPricingTaxCodes(t *testing.T) {
+	lines := testLines()
+	lines[1].TaxCode = "T5"
+	shipping := &ShippingTariffRow{id: 7, ShippingCode: "UK_NEXT_DAY", Price: 1000, TaxCode: "T0"}
+	p := testPricing(t, lines, nil, shipping)
+	assert.Equal(t, "T20", p.Lines[0].TaxCode)
+	assert.Equal(t, 2000, p.Lines[0].VAT)
+	assert.Equal(t, 125, p.Lines[1].VAT)
+	assert.Equal(t, 0, p.ShippingVAT)
+	assert.Len(t, p.Taxes, 3)
+	assert.Equal(t, 2125, p.VATTotal)
+	assert.Equal(t, 13500, p.TotalExVAT)
+}
+
+func TestCalcCartPricingIncTax(t *testing.T) {
+	rules := []*pricingRule{
+		{id: 1, code: "OVER100", typ: "fixed", target: "total", amount: 1200, totalThreshold: intPtr(10000)},
+	}
+	shipping := &ShippingTariffRow{id: 7, ShippingCode: "UK_NEXT_DAY", Price: 1000}
+	p := calcCartPricing(testLines(), rules, shipping)
+	dest := TaxDestination{CountryCode: "GB"}
+	err := calcCartTaxes(context.Background(), p, ukVAT, &dest, true, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, p.IncTax)
+
+	// lines pay 9040 and 2260 including VAT of 1507 and 377;
+	// shipping is always priced excluding VAT.
+	assert.Equal(t, 1507, p.Lines[0].VAT)
+	assert.Equal(t, 377, p.Lines[1].VAT)
+	assert.Equal(t, 200, p.ShippingVAT)
+	assert.Equal(t, 11300-1884+1000, p.TotalExVAT)
+	assert.Equal(t, 11300+1000+200, p.TotalIncVAT)
+}
+
+func TestCalcCartPricingTaxRateNotFound(t *testing.T) {
+	lines := testLines()
+	lines[0].TaxCode = "EXEMPT"
+	p := calcCartPricing(lines, nil, nil)
+	err := calcCartTaxes(context.Background(), p, ukVAT, &TaxDestination{CountryCode: "GB"}, false, time.Now())
+	assert.Equal(t, ErrTaxRateNotFound, err)
+}
+
+func TestTaxOnAmount(t *testing.T) {
+	assert.Equal(t, 200, taxOnAmount(2000, 1000, false))
+	assert.Equal(t, 200, taxOnAmount(2000, 1200, true))
+	assert.Equal(t, 5, taxOnAmount(500, 105, true))
+	assert.Equal(t, 0, taxOnAmount(0, 1000, false))
+}
+
+func TestCalcCartPricingSpendCoupons(t *testing.T) {
+	rules := []*pricingRule{
+		{id: 1, code: "SINGLE", typ: "fixed", target: "total", amount: 100, couponID: intPtr(3)},
+		{id: 2, code: "MULTI", typ: "fixed", target: "total", amount: 100, couponID: intPtr(4), reusable: true},
+		{id: 3, code: "UNUSED", typ: "fixed", target: "product", amount: 100, couponID: intPtr(5), products: map[int]bool{99: true}},
+	}
+	p := testPricing(t, testLines(), rules, nil)
+	assert.Equal(t, []int{3}, p.spendCoupons)
+}
+
+func TestPromoRuleActive(t *testing.T) {
+	now := time.Date(2019, 12, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+	assert.True(t, promoRuleActive(nil, nil, now))
+	assert.True(t, promoRuleActive(&before, &after, now))
+	assert.False(t, promoRuleActive(&after, nil, now))
+	assert.False(t, promoRuleActive(nil, &before, now))
+}
+
+func TestAllocate(t *testing.T) {
+	assert.Equal(t, []int{34, 33, 33}, allocate(100, []int{300, 300, 300}))
+	assert.Equal(t, []int{0, 5}, allocate(5, []int{0, 5}))
+	assert.Equal(t, []int{0, 0}, allocate(10, []int{0, 0}))
+}
+
+func TestAddVariantTargets(t *testing.T) {
+	rules := []*pricingRule{
+		{id: 1, code: "TSHIRT10", target: "product", products: map[int]bool{1: true}},
+		{id: 2, code: "DESK10", target: "product", products: map[int]bool{2: true}},
+	}
+	addVariantTargets(rules, map[int][]int{1: {3, 4}})
+	assert.Equal(t, map[int]bool{1: true, 3: true, 4: true}, rules[0].products)
+	assert.Equal(t, map[int]bool{2: true}, rules[1].products)
+}
@@ -16,22 +16,48 @@ type PriceEntry struct {
 	UnitPrice     int
 }
 
+// ProductContent contains the descriptive content of a product. The
+// Description may contain markdown or HTML.
+type ProductContent struct {
+	Description     string
+	Attributes      ProductAttributes
+	MetaTitle       string
+	MetaDescription string
+}
+
 // ProductUpdate contains the data required to update an existing product.
+// If TaxCode, Description, Attributes, MetaTitle or MetaDescription are
+// nil they are left unchanged.
 type ProductUpdate struct {
-	Path string
-	SKU  string
-	Name string
+	Path            string
+	SKU             string
+	Name            string
+	TaxCode         *string
+	Description     *string
+	Attributes      ProductAttributes
+	MetaTitle       *string
+	MetaDescription *string
 }
 
-// ProductRow maps to a product row.
+// ProductRow maps to a product row. ParentUUID is set if the product
+// is a variant of another product. Status is one of draft, active or
+// archived. Active products are only published between the optional
+// PublishAt and UnpublishAt times.
 type ProductRow struct {
-	id       int
-	UUID     string
-	Path     string
-	SKU      string
-	Name     string
-	Created  time.Time
-	Modified time.Time
+	id         int
+	parentID   *int
+	UUID       string
+	ParentUUID *string
+	Path       string
+	SKU        string
+	Name       string
+	TaxCode    string
+	ProductContent
+	Status      string
+	PublishAt   *time.Time
+	UnpublishAt *time.Time
+	Created     time.Time
+	Modified    time.Time
 }
 
 // ProductJoinRow represents a product row joined with a image row
@@ -57,15 +83,27 @@ var ErrProductPathExists = errors.New("postgres: product path exists")
 // ErrProductSKUExists error
 var ErrProductSKUExists = errors.New("postgres: product sku exists")
 
+// ErrProductHasVariants is returned when attempting to delete or add to
+// a cart a product that has variants.
+var ErrProductHasVariants = errors.New("postgres: product has variants")
+
 // GetProduct returns a ProductRow by product id.
 func (m *PgModel) GetProduct(ctx context.Context, productID string) (*ProductRow, error) {
 	q1 := `
-		SELECT id, uuid, sku, path, name, created, modified
-		FROM product WHERE uuid = $1
+		SELECT
+		  p.id, p.parent_id, p.uuid, pp.uuid, p.sku, p.path, p.name, p.tax_code,
+		  p.description, p.attributes, p.meta_title, p.meta_description,
+		  p.status, p.publish_at, p.unpublish_at, p.created, p.modified
+		FROM product AS p
+		LEFT OUTER JOIN product AS pp
+		  ON pp.id = p.parent_id
+		WHERE p.uuid = $1
 	`
 	p := ProductRow{}
 	row := m.db.QueryRowContext(ctx, q1, productID)
-	err := row.Scan(&p.id, &p.UUID, &p.SKU, &p.Path, &p.Name, &p.Created, &p.Modified)
+	err := row.Scan(&p.id, &p.parentID, &p.UUID, &p.ParentUUID, &p.SKU, &p.Path, &p.Name, &p.TaxCode,
+		&p.Description, &p.Attributes, &p.MetaTitle, &p.MetaDescription,
+		&p.Status, &p.PublishAt, &p.UnpublishAt, &p.Created, &p.Modified)
 	if err == sql.ErrNoRows {
 		return nil, ErrProductNotFound
 	}
@@ -75,27 +113,61 @@ func (m *PgModel) GetProduct(ctx context.Context, productID string) (*ProductRow
 	return &p, nil
 }
 
-// GetProducts returns a list of all products in the product table.
-func (m *PgModel) GetProducts(ctx context.Context) ([]*ProductRow, error) {
-	query := "SELECT id, uuid, sku, path, name, created, modified FROM product"
-	rows, err := m.db.QueryContext(ctx, query)
+// GetProducts returns a page of products. If status is not empty only
+// products with the status are returned. If publishedOnly is true only
+// published products are returned.
+func (m *PgModel) GetProducts(ctx context.Context, opts *ListOptions, status string, publishedOnly bool) ([]*ProductRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  p.id, p.parent_id, p.uuid, pp.uuid, p.sku, p.path, p.name, p.tax_code,
+		  p.description, p.attributes, p.meta_title, p.meta_description,
+		  p.status, p.publish_at, p.unpublish_at, p.created, p.modified`,
+		from:  "product AS p LEFT OUTER JOIN product AS pp ON pp.id = p.parent_id",
+		alias: "p",
+		fields: map[string]string{
+			"sku":      "p.sku",
+			"path":     "p.path",
+			"name":     "p.name",
+			"status":   "p.status",
+			"created":  "p.created",
+			"modified": "p.modified",
+		},
+		orderBy:  "p.created",
+		orderDir: OrderAsc,
+	}
+	if status != "" {
+		q.where("p.status = %s", status)
+	}
+	if publishedOnly {
+		q.where("(" + publishedCondition + ")")
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	query, args, err := q.build(opts)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) query=%q failed", query)
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) query=%q failed", query)
 	}
 	defer rows.Close()
 
 	products := make([]*ProductRow, 0, 256)
 	for rows.Next() {
 		var p ProductRow
-		if err := rows.Scan(&p.id, &p.UUID, &p.SKU, &p.Path, &p.Name, &p.Created, &p.Modified); err != nil {
-			return nil, errors.Wrap(err, "postgres: scan failed")
+		if err := rows.Scan(&p.id, &p.parentID, &p.UUID, &p.ParentUUID, &p.SKU, &p.Path, &p.Name, &p.TaxCode,
+			&p.Description, &p.Attributes, &p.MetaTitle, &p.MetaDescription,
+			&p.Status, &p.PublishAt, &p.UnpublishAt, &p.Created, &p.Modified); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
 		}
 		products = append(products, &p)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows.Err() failed")
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err() failed")
 	}
-	return products, nil
+	return products, opts.page(&products), nil
 }
 
 // ProductsExist accepts a slice of product uuids strings and returns only
@@ -131,96 +203,18 @@ func (m *PgModel) ProductsExist(ctx context.Context, productIDs []string) ([]str
 }
 
 // CreateProduct updates the details of a product with the given product id.
-func (m *PgModel) CreateProduct(ctx context.Context, userUUID string, path, sku, name string) (*ProductRow, error) {
+func (m *PgModel) CreateProduct(ctx context.Context, userUUID string, path, sku, name, taxCode string, content *ProductContent) (*ProductRow, error) {
 	contextLogger := log.WithContext(ctx)
-	contextLogger.Debugf("postgres: CreateProduct(ctx, userUUID=%s, path=%s, sku=%s, name=%q) called", userUUID, path, sku, name)
+	contextLogger.Debugf("postgres: CreateProduct(ctx, userUUID=%s, path=%s, sku=%s, name=%q, taxCode=%q) called", userUUID, path, sku, name, taxCode)
 
 	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "postgres: db.BeginTx failed")
 	}
-
-	q1 := "SELECT EXISTS(SELECT 1 FROM product WHERE path = $1) AS exists"
-	var exists bool
-	err = tx.QueryRowContext(ctx, q1, path).Scan(&exists)
+	p, err := createProduct(ctx, tx, userUUID, nil, path, sku, name, taxCode, content)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: tx.QueryRowContext(ctx, q1=%q, path=%q) failed", q1, path)
-	}
-	if exists {
-		return nil, ErrProductPathExists
-	}
-
-	q2 := "SELECT EXISTS(SELECT 1 FROM product WHERE sku = $1) AS exists"
-	err = tx.QueryRowContext(ctx, q2, sku).Scan(&exists)
-	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: tx.QueryRowContext(ctx, q2=%q, path=%q) failed", q2, sku)
-	}
-	if exists {
-		return nil, ErrProductSKUExists
-	}
-
-	// 3. Determine the price list the user is on.
-	var priceListID int
-	if userUUID != "" {
-		q3 := "SELECT price_list_id FROM usr WHERE uuid = $1"
-		err = tx.QueryRowContext(ctx, q3, userUUID).Scan(&priceListID)
-		if err == sql.ErrNoRows {
-			tx.Rollback()
-			return nil, ErrUserNotFound
-		}
-		if err != nil {
-			tx.Rollback()
-			return nil, errors.Wrapf(err, "postgres: query row context failed for query=%q", q3)
-		}
-		contextLogger.Debugf("postgres: userUUID=%s is on priceListID=%d", userUUID, priceListID)
-	} else {
-		contextLogger.Debugf("postgres: userUUID not set. Trying to determine priceListID using the default price list code")
-		q4 := "SELECT id FROM price_list WHERE code = 'default'"
-		err = tx.QueryRowContext(ctx, q4).Scan(&priceListID)
-		if err == sql.ErrNoRows {
-			tx.Rollback()
-			return nil, ErrDefaultPriceListNotFound
-		}
-		if err != nil {
-			tx.Rollback()
-			return nil, errors.Wrapf(err, "postgres: query row context failed for query=%q", q4)
-		}
-	}
-
-	q4 := `
-		INSERT INTO product
-		  (path, sku, name, created, modified)
-		VALUES
-		  ($1, $2, $3, NOW(), NOW())
-		RETURNING
-		  id, uuid, path, sku, name, created, modified
-	`
-	p := ProductRow{}
-	row := tx.QueryRowContext(ctx, q4, path, sku, name)
-	if err := row.Scan(&p.id, &p.UUID, &p.Path, &p.SKU, &p.Name, &p.Created, &p.Modified); err != nil {
 		tx.Rollback()
-		return nil, errors.Wrapf(err, "postgres: query row context q4=%q failed", q4)
-	}
-	contextLogger.Debugf("postgres: q4 created new product with product.id=%d, product.UUID=%s", p.id, p.UUID)
-
-	q5 := `
-		INSERT INTO price (product_id, price_list_id, break, unit_price)
-		VALUES ($1, $2, 1, 0)
-	`
-	_, err = tx.ExecContext(ctx, q5, p.id, priceListID)
-	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q5=%q, %d)", q5, p.id)
-	}
-	contextLogger.Debugf("postgres: q5 created a single empty price for product.id=%d, p.UUID=%s", p.id, p.UUID)
-
-	// 6. Set the inventory for this product to onhand = 0
-	q6 := `
-		INSERT INTO inventory (product_id, onhand, created, modified)
-		VALUES ($1, 0, NOW(), NOW())
-	`
-	_, err = tx.ExecContext(ctx, q6, p.id)
-	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q6=%q, %d)", q6, p.id)
+		return nil, err
 	}
 
 	// Delete all existing products. This is not the most efficient
@@ -350,6 +344,106 @@ func (m *PgModel) CreateProduct(ctx context.Context, userUUID string, path, sku,
 	if err := tx.Commit(); err != nil {
 		return nil, errors.Wrap(err, "postgres: tx.Commit failed")
 	}
+	return p, nil
+}
+
+// createProduct inserts a product row with a single empty price in the
+// user's price list and an empty inventory. If parentID is not nil the
+// product is created as a variant of the parent. If content is nil the
+// product has no content. The caller is responsible for rolling back tx
+// on error.
+func createProduct(ctx context.Context, tx *sql.Tx, userUUID string, parentID *int, path, sku, name, taxCode string, content *ProductContent) (*ProductRow, error) {
+	contextLogger := log.WithContext(ctx)
+
+	q1 := "SELECT EXISTS(SELECT 1 FROM product WHERE path = $1) AS exists"
+	var exists bool
+	err := tx.QueryRowContext(ctx, q1, path).Scan(&exists)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: tx.QueryRowContext(ctx, q1=%q, path=%q) failed", q1, path)
+	}
+	if exists {
+		return nil, ErrProductPathExists
+	}
+
+	q2 := "SELECT EXISTS(SELECT 1 FROM product WHERE sku = $1) AS exists"
+	err = tx.QueryRowContext(ctx, q2, sku).Scan(&exists)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: tx.QueryRowContext(ctx, q2=%q, path=%q) failed", q2, sku)
+	}
+	if exists {
+		return nil, ErrProductSKUExists
+	}
+
+	if err := checkTaxCode(ctx, tx, taxCode); err != nil {
+		return nil, err
+	}
+
+	// 3. Determine the price list the user is on.
+	var priceListID int
+	if userUUID != "" {
+		q3 := "SELECT price_list_id FROM usr WHERE uuid = $1"
+		err = tx.QueryRowContext(ctx, q3, userUUID).Scan(&priceListID)
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "postgres: query row context failed for query=%q", q3)
+		}
+		contextLogger.Debugf("postgres: userUUID=%s is on priceListID=%d", userUUID, priceListID)
+	} else {
+		contextLogger.Debugf("postgres: userUUID not set. Trying to determine priceListID using the default price list code")
+		q4 := "SELECT id FROM price_list WHERE code = 'default'"
+		err = tx.QueryRowContext(ctx, q4).Scan(&priceListID)
+		if err == sql.ErrNoRows {
+			return nil, ErrDefaultPriceListNotFound
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "postgres: query row context failed for query=%q", q4)
+		}
+	}
+
+	if content == nil {
+		content = &ProductContent{}
+	}
+	q4 := `
+		INSERT INTO product
+		  (parent_id, path, sku, name, tax_code, description, attributes,
+		   meta_title, meta_description, created, modified)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+		RETURNING
+		  id, parent_id, uuid, path, sku, name, tax_code, description, attributes,
+		  meta_title, meta_description, status, publish_at, unpublish_at, created, modified
+	`
+	p := ProductRow{}
+	row := tx.QueryRowContext(ctx, q4, parentID, path, sku, name, taxCode, content.Description,
+		content.Attributes, content.MetaTitle, content.MetaDescription)
+	if err := row.Scan(&p.id, &p.parentID, &p.UUID, &p.Path, &p.SKU, &p.Name, &p.TaxCode,
+		&p.Description, &p.Attributes, &p.MetaTitle, &p.MetaDescription,
+		&p.Status, &p.PublishAt, &p.UnpublishAt, &p.Created, &p.Modified); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q4=%q failed", q4)
+	}
+	contextLogger.Debugf("postgres: q4 created new product with product.id=%d, product.UUID=%s", p.id, p.UUID)
+
+	q5 := `
+		INSERT INTO price (product_id, price_list_id, break, unit_price)
+		VALUES ($1, $2, 1, 0)
+	`
+	_, err = tx.ExecContext(ctx, q5, p.id, priceListID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q5=%q, %d)", q5, p.id)
+	}
+	contextLogger.Debugf("postgres: q5 created a single empty price for product.id=%d, p.UUID=%s", p.id, p.UUID)
+
+	// 6. Set the inventory for this product to onhand = 0
+	q6 := `
+		INSERT INTO inventory (product_id, onhand, created, modified)
+		VALUES ($1, 0, NOW(), NOW())
+	`
+	_, err = tx.ExecContext(ctx, q6, p.id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q6=%q, %d)", q6, p.id)
+	}
 	return &p, nil
 }
 
@@ -360,9 +454,10 @@ func (m *PgModel) UpdateProduct(ctx context.Context, productUUID string, pu *Pro
 		return nil, errors.Wrap(err, "postgres: db.BeginTx failed")
 	}
 
-	q1 := "SELECT id FROM product WHERE uuid = $1"
+	q1 := "SELECT id, path FROM product WHERE uuid = $1 FOR UPDATE"
 	var productID int
-	err = tx.QueryRowContext(ctx, q1, productUUID).Scan(&productID)
+	var oldPath string
+	err = tx.QueryRowContext(ctx, q1, productUUID).Scan(&productID, &oldPath)
 	if err == sql.ErrNoRows {
 		tx.Rollback()
 		return nil, ErrProductNotFound
@@ -397,22 +492,54 @@ func (m *PgModel) UpdateProduct(ctx context.Context, productUUID string, pu *Pro
 		return nil, ErrProductSKUExists
 	}
 
+	if pu.TaxCode != nil {
+		if err := checkTaxCode(ctx, tx, *pu.TaxCode); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// attributes are only replaced if set.
+	var attributes interface{}
+	if pu.Attributes != nil {
+		attributes = pu.Attributes
+	}
 	q4 := `
-		UPDATE product
+		UPDATE product AS p
 		SET
-		  path = $1, sku = $2, name = $3, modified = NOW()
+		  path = $1, sku = $2, name = $3,
+		  tax_code = COALESCE($4, tax_code),
+		  description = COALESCE($5, description),
+		  attributes = COALESCE($6, attributes),
+		  meta_title = COALESCE($7, meta_title),
+		  meta_description = COALESCE($8, meta_description),
+		  modified = NOW()
 		WHERE
-		  id = $4
+		  id = $9
 		RETURNING
-		  id, uuid, path, sku, name, created, modified`
-	row := tx.QueryRowContext(ctx, q4, pu.Path, pu.SKU, pu.Name, productID)
+		  p.id, p.parent_id, p.uuid, (SELECT pp.uuid FROM product AS pp WHERE pp.id = p.parent_id),
+		  p.path, p.sku, p.name, p.tax_code, p.description, p.attributes,
+		  p.meta_title, p.meta_description, p.status, p.publish_at, p.unpublish_at,
+		  p.created, p.modified`
+	row := tx.QueryRowContext(ctx, q4, pu.Path, pu.SKU, pu.Name, pu.TaxCode, pu.Description,
+		attributes, pu.MetaTitle, pu.MetaDescription, productID)
 
 	p := ProductRow{}
-	if err := row.Scan(&p.id, &p.UUID, &p.Path, &p.SKU, &p.Name, &p.Created, &p.Modified); err != nil {
+	if err := row.Scan(&p.id, &p.parentID, &p.UUID, &p.ParentUUID, &p.Path, &p.SKU, &p.Name, &p.TaxCode,
+		&p.Description, &p.Attributes, &p.MetaTitle, &p.MetaDescription,
+		&p.Status, &p.PublishAt, &p.UnpublishAt, &p.Created, &p.Modified); err != nil {
 		tx.Rollback()
 		return nil, errors.Wrapf(err, "postgres: query row context q4=%q failed", q4)
 	}
 
+	// Keep the old path working by redirecting it to the new one.
+	if p.Path != oldPath {
+		if err := addProductRedirect(ctx, tx, productID, oldPath, p.Path); err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "postgres: addProductRedirect(ctx, tx, productID=%d, oldPath=%q, newPath=%q) failed", productID, oldPath, p.Path)
+		}
+	}
+
 	// Delete all existing products. This is not the most efficient
 	// way, but is easier that comparing the state of a list of new
 	// images with the underlying database. Product updates don't
@@ -562,8 +689,19 @@ func (m *PgModel) DeleteProduct(ctx context.Context, productUUID string) error {
 		return errors.Wrapf(err, "postgres: query row context failed for q1=%q", q1)
 	}
 
-	// 2. TODO: check if the product is part of a promo rule targeting an individual product.
+	// 2. Variants must be deleted before their parent product.
+	// TODO: check if the product is part of a promo rule targeting an individual product.
 	// return an error if it is in use.
+	q2 := "SELECT EXISTS(SELECT 1 FROM product WHERE parent_id = $1) AS exists"
+	var hasVariants bool
+	if err := tx.QueryRowContext(ctx, q2, productID).Scan(&hasVariants); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	if hasVariants {
+		tx.Rollback()
+		return ErrProductHasVariants
+	}
 
 	// 3. Remove the product from all cart items (one to many)
 	q3 := "DELETE FROM cart_product WHERE product_id = $1"
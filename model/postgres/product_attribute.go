@@ -0,0 +1,205 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrProductAttributeNotFound error
+var ErrProductAttributeNotFound = errors.New("postgres: product attribute not found")
+
+// ErrProductAttributeCodeExists error for duplicates.
+var ErrProductAttributeCodeExists = errors.New("postgres: product attribute code exists")
+
+// ErrProductAttributeInUse error
+var ErrProductAttributeInUse = errors.New("postgres: product attribute is used by products")
+
+// ProductAttributes holds the custom attribute values of a product
+// keyed by attribute code. It is stored as JSONB.
+type ProductAttributes map[string]interface{}
+
+// Value marshals the attributes to JSON.
+func (a ProductAttributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "json marshal of product attributes failed")
+	}
+	return b, nil
+}
+
+// Scan unmarshals JSON data into a ProductAttributes map.
+func (a *ProductAttributes) Scan(value interface{}) error {
+	sv, err := driver.String.ConvertValue(value)
+	if err != nil {
+		return errors.Wrap(err, "convert value failed")
+	}
+	if v, ok := sv.([]byte); ok {
+		attrs := make(ProductAttributes)
+		if err := json.Unmarshal(v, &attrs); err != nil {
+			return errors.Wrap(err, "json unmarshal of product attributes failed")
+		}
+		*a = attrs
+		return nil
+	}
+	return fmt.Errorf("scan value failed")
+}
+
+// ProductAttributeRow maps to a row in the product_attribute table.
+type ProductAttributeRow struct {
+	id       int
+	UUID     string
+	Code     string
+	Name     string
+	Typ      string
+	Created  time.Time
+	Modified time.Time
+}
+
+// CreateProductAttribute creates a new product attribute definition.
+func (m *PgModel) CreateProductAttribute(ctx context.Context, code, name, typ string) (*ProductAttributeRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: CreateProductAttribute(ctx, code=%q, name=%q, typ=%q) started", code, name, typ)
+
+	// 1. Check if the product attribute code exists
+	q1 := `SELECT EXISTS(SELECT 1 FROM product_attribute WHERE code = $1) AS exists`
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, q1, code).Scan(&exists); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if exists {
+		return nil, ErrProductAttributeCodeExists
+	}
+
+	// 2. Insert the new product attribute
+	q2 := `
+		INSERT INTO product_attribute
+		  (code, name, typ, created, modified)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING
+		  id, uuid, code, name, typ, created, modified
+	`
+	var a ProductAttributeRow
+	row := m.db.QueryRowContext(ctx, q2, code, name, typ)
+	if err := row.Scan(&a.id, &a.UUID, &a.Code, &a.Name, &a.Typ, &a.Created, &a.Modified); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	return &a, nil
+}
+
+// GetProductAttributeByUUID returns a single ProductAttributeRow by uuid.
+func (m *PgModel) GetProductAttributeByUUID(ctx context.Context, productAttributeUUID string) (*ProductAttributeRow, error) {
+	q1 := `
+		SELECT id, uuid, code, name, typ, created, modified
+		FROM product_attribute
+		WHERE uuid = $1
+	`
+	var a ProductAttributeRow
+	err := m.db.QueryRowContext(ctx, q1, productAttributeUUID).Scan(&a.id, &a.UUID, &a.Code,
+		&a.Name, &a.Typ, &a.Created, &a.Modified)
+	if err == sql.ErrNoRows {
+		return nil, ErrProductAttributeNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return &a, nil
+}
+
+// GetProductAttributes returns a page of product attribute definitions
+// ordered by code by default. If opts is nil all definitions are
+// returned.
+func (m *PgModel) GetProductAttributes(ctx context.Context, opts *ListOptions) ([]*ProductAttributeRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  id, uuid, code, name, typ, created, modified`,
+		from:  "product_attribute AS a",
+		alias: "a",
+		fields: map[string]string{
+			"code":     "a.code",
+			"name":     "a.name",
+			"created":  "a.created",
+			"modified": "a.modified",
+		},
+		orderBy:  "a.code",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	attributes := make([]*ProductAttributeRow, 0, 8)
+	for rows.Next() {
+		var a ProductAttributeRow
+		if err := rows.Scan(&a.id, &a.UUID, &a.Code, &a.Name, &a.Typ, &a.Created, &a.Modified); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		attributes = append(attributes, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return attributes, opts.page(&attributes), nil
+}
+
+// DeleteProductAttribute deletes a product attribute definition. Returns
+// ErrProductAttributeInUse if any product has a value for the attribute.
+func (m *PgModel) DeleteProductAttribute(ctx context.Context, productAttributeUUID string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "postgres: db.BeginTx")
+	}
+
+	q1 := "SELECT id, code FROM product_attribute WHERE uuid = $1"
+	var attributeID int
+	var code string
+	err = tx.QueryRowContext(ctx, q1, productAttributeUUID).Scan(&attributeID, &code)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return ErrProductAttributeNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: query row context failed for q1=%q", q1)
+	}
+
+	q2 := "SELECT EXISTS(SELECT 1 FROM product WHERE attributes ? $1) AS exists"
+	var exists bool
+	if err := tx.QueryRowContext(ctx, q2, code).Scan(&exists); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	if exists {
+		tx.Rollback()
+		return ErrProductAttributeInUse
+	}
+
+	q3 := "DELETE FROM product_attribute WHERE id = $1"
+	if _, err := tx.ExecContext(ctx, q3, attributeID); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "postgres: exec context q3=%q", q3)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	return nil
+}
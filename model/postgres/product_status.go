@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Product status values. Products are created as drafts.
+const (
+	ProductStatusDraft    = "draft"
+	ProductStatusActive   = "active"
+	ProductStatusArchived = "archived"
+)
+
+// publishedCondition is the SQL condition matching published products
+// in the product table aliased as p. It must be kept in line with
+// ProductRow.Published.
+const publishedCondition = `
+	p.status = 'active' AND
+	(p.publish_at IS NULL OR p.publish_at <= NOW()) AND
+	(p.unpublish_at IS NULL OR p.unpublish_at > NOW())
+`
+
+// purchasableCondition is the SQL condition matching published products
+// in the product table aliased as p whose parent product, if any, is also
+// published. Only purchasable products can be added to carts and ordered.
+const purchasableCondition = publishedCondition + ` AND
+	NOT EXISTS (
+	  SELECT 1 FROM product AS pp
+	  WHERE pp.id = p.parent_id AND NOT (
+	    pp.status = 'active' AND
+	    (pp.publish_at IS NULL OR pp.publish_at <= NOW()) AND
+	    (pp.unpublish_at IS NULL OR pp.unpublish_at > NOW())
+	  )
+	)
+`
+
+// Published returns true if the product is active and now is within its
+// publish window. Active products with a PublishAt time in the future
+// are scheduled to be published.
+func (p *ProductRow) Published(now time.Time) bool {
+	if p.Status != ProductStatusActive {
+		return false
+	}
+	if p.PublishAt != nil && p.PublishAt.After(now) {
+		return false
+	}
+	if p.UnpublishAt != nil && !p.UnpublishAt.After(now) {
+		return false
+	}
+	return true
+}
+
+// UpdateProductStatus sets the status and publish window of a product.
+// A nil publishAt or unpublishAt clears the time. Returns
+// ErrProductNotFound if the product does not exist.
+func (m *PgModel) UpdateProductStatus(ctx context.Context, productUUID, status string, publishAt, unpublishAt *time.Time) (*ProductRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: UpdateProductStatus(ctx, productUUID=%q, status=%q, publishAt=%v, unpublishAt=%v) started", productUUID, status, publishAt, unpublishAt)
+
+	q1 := `
+		UPDATE product
+		SET status = $2, publish_at = $3, unpublish_at = $4, modified = NOW()
+		WHERE uuid = $1
+	`
+	res, err := m.db.ExecContext(ctx, q1, productUUID, status, publishAt, unpublishAt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err, "postgres: res.RowsAffected()")
+	}
+	if count == 0 {
+		return nil, ErrProductNotFound
+	}
+	return m.GetProduct(ctx, productUUID)
+}
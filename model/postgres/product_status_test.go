@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductPublished(t *testing.T) {
+	now := time.Date(2019, 12, 11, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		status                 string
+		publishAt, unpublishAt *time.Time
+		want                   bool
+	}{
+		{ProductStatusDraft, nil, nil, false},
+		{ProductStatusArchived, nil, nil, false},
+		{ProductStatusActive, nil, nil, true},
+		{ProductStatusActive, &past, nil, true},
+		{ProductStatusActive, &future, nil, false},
+		{ProductStatusActive, nil, &future, true},
+		{ProductStatusActive, nil, &past, false},
+		{ProductStatusActive, &now, &now, false},
+		{ProductStatusActive, &past, &future, true},
+		{ProductStatusDraft, &past, &future, false},
+	}
+	for _, tt := range tests {
+		p := ProductRow{Status: tt.status, PublishAt: tt.publishAt, UnpublishAt: tt.unpublishAt}
+		assert.Equal(t, tt.want, p.Published(now), "status=%s publishAt=%v unpublishAt=%v", tt.status, tt.publishAt, tt.unpublishAt)
+	}
+}
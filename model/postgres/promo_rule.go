@@ -486,31 +486,43 @@ func (m *PgModel) GetPromoRule(ctx context.Context, promoRuleUUID string) (*Prom
 	return &p, nil
 }
 
-// GetPromoRules returns a list of promo rules.
-func (m *PgModel) GetPromoRules(ctx context.Context) ([]*PromoRuleJoinProductRow, error) {
-	q1 := `
-		SELECT
+// GetPromoRules returns a page of promo rules.
+func (m *PgModel) GetPromoRules(ctx context.Context, opts *ListOptions) ([]*PromoRuleJoinProductRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
 		  r.id, r.uuid, promo_rule_code,
 		  product_id, p.uuid as product_uuid, p.path as product_path, p.sku as product_sku,
 		  product_set_id, t.uuid as product_set_uuid,
 		  category_id, c.uuid as category_uuid, c.path as category_path,
 		  shipping_tariff_id, s.uuid as shipping_tariff_uuid, s.shipping_code as shipping_tarrif_code,
 		  r.name, start_at, end_at, amount, total_threshold, type,
-		  target, r.created, r.modified
-		FROM
+		  target, r.created, r.modified`,
+		from: `
 		  promo_rule AS r
-		LEFT JOIN product AS p
-		  ON p.id = r.product_id
-		LEFT JOIN category AS c
-		  ON c.id = r.category_id
-		LEFT JOIN shipping_tariff AS s
-		  ON s.id = r.shipping_tariff_id
-		LEFT JOIN product_set AS t
-		  ON t.id = r.product_set_id
-	`
-	rows, err := m.db.QueryContext(ctx, q1)
+		  LEFT JOIN product AS p ON p.id = r.product_id
+		  LEFT JOIN category AS c ON c.id = r.category_id
+		  LEFT JOIN shipping_tariff AS s ON s.id = r.shipping_tariff_id
+		  LEFT JOIN product_set AS t ON t.id = r.product_set_id`,
+		alias: "r",
+		fields: map[string]string{
+			"promo_rule_code": "r.promo_rule_code",
+			"name":            "r.name",
+			"created":         "r.created",
+			"modified":        "r.modified",
+		},
+		orderBy:  "r.created",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
 	}
 	defer rows.Close()
 
@@ -525,14 +537,14 @@ func (m *PgModel) GetPromoRules(ctx context.Context) ([]*PromoRuleJoinProductRow
 			&p.Name, &p.StartAt, &p.EndAt,
 			&p.Amount, &p.TotalThreshold, &p.Type, &p.Target, &p.Created, &p.Modified)
 		if err != nil {
-			return nil, errors.Wrap(err, "postgres: scan failed")
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
 		}
 		rules = append(rules, &p)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows.Err()")
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
 	}
-	return rules, nil
+	return rules, opts.page(&rules), nil
 }
 
 // DeletePromoRule deletes a promo rule row from the promo_rule table.
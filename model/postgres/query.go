@@ -2,217 +2,192 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"fmt"
+	"reflect"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
+// ErrOrderByInvalid is returned when a list is ordered by a field that
+// is not sortable.
+var ErrOrderByInvalid = errors.New("postgres: order by field invalid")
+
+// ErrCursorNotFound is returned when the row given by StartAfter or
+// EndBefore is not in the list.
+var ErrCursorNotFound = errors.New("postgres: cursor not found")
+
 // OrderDirection is a named type for ASC or DESC
 type OrderDirection string
 
-// Query defines a query for pagination on a given table
-type Query struct {
-	table      string
-	sfields    map[string]bool
-	sel        []string
-	orderBy    string
-	orderDir   OrderDirection
-	limit      int
-	startAfter string
-	endBefore  string
-	err        error
-}
+// Order directions
+const (
+	OrderAsc  OrderDirection = "ASC"
+	OrderDesc OrderDirection = "DESC"
+)
 
 func (o OrderDirection) toggle() OrderDirection {
-	if o == "ASC" {
-		return "DESC"
+	if o == OrderAsc {
+		return OrderDesc
 	}
-	return "ASC"
+	return OrderAsc
 }
 
-// NewQuery builds a new query for paginating a table
-func NewQuery(table string, sf map[string]bool) *Query {
-	return &Query{
-		table:      table,
-		sfields:    sf,
-		sel:        make([]string, 0, 16),
-		orderBy:    "id",
-		orderDir:   OrderDirection("ASC"),
-		limit:      0,
-		startAfter: "",
-		endBefore:  "",
-		err:        nil,
-	}
+// ListOptions holds the page size, cursor and sort order of a list.
+// StartAfter and EndBefore hold the uuid of the row before and after
+// the page respectively and at most one of them is set. If Limit is
+// zero all rows are returned. If OrderBy is empty the default order
+// of the list is used.
+type ListOptions struct {
+	Limit      int
+	StartAfter string
+	EndBefore  string
+	OrderBy    string
+	OrderDir   OrderDirection
 }
 
-// Select indicates which columns to select
-func (q *Query) Select(s []string) *Query {
-	return &Query{
-		table:      q.table,
-		sfields:    q.sfields,
-		sel:        s,
-		orderBy:    q.orderBy,
-		orderDir:   q.orderDir,
-		limit:      q.limit,
-		startAfter: q.startAfter,
-		err:        nil,
-	}
+// ListContext describes where a page sits in a list.
+type ListContext struct {
+	HasPrev bool
+	HasNext bool
 }
 
-// OrderBy indicates which column to sort on
-func (q *Query) OrderBy(o string) *Query {
-	return &Query{
-		table:      q.table,
-		sfields:    q.sfields,
-		sel:        q.sel,
-		orderBy:    o,
-		orderDir:   q.orderDir,
-		limit:      q.limit,
-		startAfter: q.startAfter,
-		err:        q.err,
-	}
+// listQuery holds the parts of an SQL query that lists rows of a table.
+// alias is the alias of the table in from and its id and uuid columns
+// are used as the cursor and tie breaker. fields maps each sortable
+// field to its column expression. orderBy and orderDir are the default
+// sort order.
+type listQuery struct {
+	sel      string
+	from     string
+	alias    string
+	conds    []string
+	args     []interface{}
+	groupBy  string
+	fields   map[string]string
+	orderBy  string
+	orderDir OrderDirection
 }
 
-// OrderDir determines the ordering of the result set.
-func (q *Query) OrderDir(d OrderDirection) *Query {
-	return &Query{
-		table:      q.table,
-		sfields:    q.sfields,
-		sel:        q.sel,
-		orderBy:    q.orderBy,
-		orderDir:   OrderDirection(strings.ToUpper(string(d))),
-		limit:      q.limit,
-		startAfter: q.startAfter,
-		err:        q.err,
+// where adds a condition to the query. Each argument placeholder in
+// cond is written as %s and is replaced by its $n parameter.
+func (q *listQuery) where(cond string, args ...interface{}) {
+	params := make([]interface{}, 0, len(args))
+	for _, a := range args {
+		q.args = append(q.args, a)
+		params = append(params, fmt.Sprintf("$%d", len(q.args)))
 	}
+	q.conds = append(q.conds, fmt.Sprintf(cond, params...))
 }
 
-// Limit restricts the number of results in the result set to a maximum of n.
-func (q *Query) Limit(n int) *Query {
-	return &Query{
-		table:      q.table,
-		sfields:    q.sfields,
-		sel:        q.sel,
-		orderBy:    q.orderBy,
-		orderDir:   q.orderDir,
-		limit:      n,
-		startAfter: q.startAfter,
-		err:        q.err,
-	}
+// cursorCondition returns the condition of rows that exist in the list
+// with the given uuid.
+func (q *listQuery) cursorCondition(placeholder string) string {
+	conds := append([]string{q.alias + ".uuid = " + placeholder}, q.conds...)
+	return strings.Join(conds, " AND ")
 }
 
-// StartAfter indicates the UUID of the row to return the next page of results.
-func (q *Query) StartAfter(s string) *Query {
-	return &Query{
-		table:      q.table,
-		sfields:    q.sfields,
-		sel:        q.sel,
-		orderBy:    q.orderBy,
-		orderDir:   q.orderDir,
-		limit:      q.limit,
-		startAfter: s,
-		endBefore:  "",
-		err:        q.err,
+// build returns the SQL and arguments of the query for a page of the
+// list. One extra row beyond the limit is fetched so the caller can tell
+// if there are more rows. Pages fetched with EndBefore are returned in
+// reverse order and put back in order by ListOptions.page.
+func (q *listQuery) build(o *ListOptions) (string, []interface{}, error) {
+	if o == nil {
+		o = &ListOptions{}
 	}
-}
-
-// EndBefore indicates the UUID of the row to return the previous page of results.
-func (q *Query) EndBefore(s string) *Query {
-	return &Query{
-		table:      q.table,
-		sfields:    q.sfields,
-		sel:        q.sel,
-		orderBy:    q.orderBy,
-		orderDir:   q.orderDir,
-		limit:      q.limit,
-		startAfter: "",
-		endBefore:  s,
-		err:        q.err,
-	}
-}
-
-// QueryContextQ builds an SQL statement based on the given Query q and
-// returns make a call to sql.QueryContext returning a *sql.Rows.
-func (m *PgModel) QueryContextQ(ctx context.Context, q *Query) (*sql.Rows, error) {
-	for _, v := range q.sel {
-		_, ok := q.sfields[v]
+	orderBy, dir := q.orderBy, q.orderDir
+	if o.OrderBy != "" {
+		col, ok := q.fields[o.OrderBy]
 		if !ok {
-			return nil, fmt.Errorf("query: Select contains non searchable field %q", v)
+			return "", nil, ErrOrderByInvalid
 		}
+		orderBy = col
+		dir = OrderAsc
 	}
-
-	// if q.startVal == "" {
-	// 	return nil, errors.New("query: StartAt/StartAfter must be called with at least one value")
-	// }
-
-	if q.orderDir != "ASC" && q.orderDir != "DESC" {
-		return nil, errors.New(`query: OrderDirection must be called with either "ASC" or "DESC"`)
+	if o.OrderDir != "" {
+		dir = OrderDirection(strings.ToUpper(string(o.OrderDir)))
+		if dir != OrderAsc && dir != OrderDesc {
+			return "", nil, errors.Errorf("postgres: order direction %q invalid", o.OrderDir)
+		}
 	}
 
-	if len(q.sel) == 0 {
-		q.sel = append(q.sel, "*")
+	args := append([]interface{}{}, q.args...)
+	conds := append([]string{}, q.conds...)
+	cursor := o.StartAfter
+	if o.EndBefore != "" {
+		cursor = o.EndBefore
+		dir = dir.toggle()
 	}
-
-	if q.startAfter == "" && q.endBefore == "" {
-		var sql string
-		sel := `SELECT %s FROM %s ORDER BY %s %s, id %s`
-		sel = fmt.Sprintf(sel, strings.Join(q.sel, ", "), q.table, q.orderBy, q.orderDir, q.orderDir)
-		var lmt string
-		if q.limit > 0 {
-			lmt = fmt.Sprintf(" FETCH FIRST %d ROWS ONLY", q.limit)
+	if cursor != "" {
+		args = append(args, cursor)
+		cmp := ">"
+		if dir == OrderDesc {
+			cmp = "<"
 		}
-		sql = sel + lmt
+		conds = append(conds, fmt.Sprintf(
+			"(%s, %s.id) %s (SELECT %s, %s.id FROM %s WHERE %s LIMIT 1)",
+			orderBy, q.alias, cmp, orderBy, q.alias, q.from,
+			q.cursorCondition(fmt.Sprintf("$%d", len(args)))))
+	}
 
-		rows, err := m.db.QueryContext(ctx, sql)
-		if err != nil {
-			return nil, fmt.Errorf("QueryContext with %q: %v", sql, err)
-		}
-		return rows, err
+	sql := "SELECT " + q.sel + " FROM " + q.from
+	if len(conds) > 0 {
+		sql = sql + " WHERE " + strings.Join(conds, " AND ")
+	}
+	if q.groupBy != "" {
+		sql = sql + " GROUP BY " + q.groupBy
+	}
+	sql = sql + fmt.Sprintf(" ORDER BY %s %s, %s.id %s", orderBy, dir, q.alias, dir)
+	if o.Limit > 0 {
+		sql = sql + fmt.Sprintf(" LIMIT %d", o.Limit+1)
+	}
+	return sql, args, nil
+}
+
+// checkCursor returns ErrCursorNotFound if the StartAfter or EndBefore
+// row is not in the list.
+func (m *PgModel) checkCursor(ctx context.Context, q *listQuery, o *ListOptions) error {
+	if o == nil || (o.StartAfter == "" && o.EndBefore == "") {
+		return nil
 	}
+	cursor := o.StartAfter
+	if cursor == "" {
+		cursor = o.EndBefore
+	}
+	args := append(append([]interface{}{}, q.args...), cursor)
+	sql := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s)",
+		q.from, q.cursorCondition(fmt.Sprintf("$%d", len(args))))
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, sql, args...).Scan(&exists); err != nil {
+		return errors.Wrapf(err, "postgres: query row context sql=%q", sql)
+	}
+	if !exists {
+		return ErrCursorNotFound
+	}
+	return nil
+}
 
-	// Assume startAfter only
-	var comparitor string
-	var uuid string
-	if q.startAfter != "" {
-		comparitor = "<"
-		uuid = q.startAfter
+// page trims the extra row fetched by a list query and puts the rows of
+// a page fetched with EndBefore back in order. rows must be a pointer to
+// a slice.
+func (o *ListOptions) page(rows interface{}) *ListContext {
+	lc := ListContext{}
+	if o == nil {
+		return &lc
+	}
+	v := reflect.ValueOf(rows).Elem()
+	more := o.Limit > 0 && v.Len() > o.Limit
+	if more {
+		v.Set(v.Slice(0, o.Limit))
+	}
+	if o.EndBefore != "" {
+		swap := reflect.Swapper(v.Interface())
+		for i, j := 0, v.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+		lc.HasPrev, lc.HasNext = more, true
 	} else {
-		// q.endBefore must be set
-		comparitor = ">"
-		uuid = q.endBefore
-		q.orderDir = q.orderDir.toggle()
-	}
-
-	// if q.orderDir == "ASC" {
-	// 	comparitor = ">"
-	// } else {
-	// 	comparitor = "<"
-	// }
-
-	sql := `
-		SELECT %s FROM %s
-		WHERE (%s, id) %s (
-			SELECT %s, id FROM usr
-		 	WHERE uuid = $1
-		)
-		ORDER BY %s %s, id %s
-		FETCH FIRST %d ROWS ONLY
-	`
-	sql = fmt.Sprintf(sql,
-		strings.Join(q.sel, ", "), q.table,
-		q.orderBy, comparitor,
-		q.orderBy,
-		q.orderBy, q.orderDir, q.orderDir,
-		q.limit)
-	rows, err := m.db.QueryContext(ctx, sql, uuid)
-	if err != nil {
-		return nil, fmt.Errorf("QueryContext with %q: %v", sql, err)
-	}
-
-	if q.endBefore != "" {
-		// reverse the rows
-	}
-
-	return rows, err
+		lc.HasPrev, lc.HasNext = o.StartAfter != "", more
+	}
+	return &lc
 }
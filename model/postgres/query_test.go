@@ -1,26 +1,86 @@
-package postgres_test
+package postgres
 
 import (
 	"testing"
 
-	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/stretchr/testify/assert"
 )
 
-func TestNewQuery(t *testing.T) {
-	q := postgres.NewQuery("usr", map[string]bool{
-		"id":        true,
-		"uuid":      false,
-		"uid":       false,
-		"email":     true,
-		"firstname": true,
-		"lastname":  true,
-		"created":   true,
-		"modified":  true,
+func testListQuery() *listQuery {
+	q := listQuery{
+		sel:   "w.id, w.uuid, w.url",
+		from:  "webhook AS w",
+		alias: "w",
+		fields: map[string]string{
+			"url":     "w.url",
+			"created": "w.created",
+		},
+		orderBy:  "w.created",
+		orderDir: OrderDesc,
+	}
+	q.where("w.enabled = %s", true)
+	return &q
+}
+
+func TestListQueryBuild(t *testing.T) {
+	sql, args, err := testListQuery().build(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{true}, args)
+	assert.Equal(t, "SELECT w.id, w.uuid, w.url FROM webhook AS w WHERE w.enabled = $1 ORDER BY w.created DESC, w.id DESC", sql)
+
+	sql, args, err = testListQuery().build(&ListOptions{
+		Limit:      10,
+		StartAfter: "93b8ea3a-6d4b-4fdc-abb3-49009198775c",
+		OrderBy:    "url",
 	})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{true, "93b8ea3a-6d4b-4fdc-abb3-49009198775c"}, args)
+	assert.Contains(t, sql, "(w.url, w.id) > (SELECT w.url, w.id FROM webhook AS w WHERE w.uuid = $2 AND w.enabled = $1 LIMIT 1)")
+	assert.Contains(t, sql, "ORDER BY w.url ASC, w.id ASC LIMIT 11")
+
+	// pages before the cursor are fetched in reverse order.
+	sql, _, err = testListQuery().build(&ListOptions{
+		Limit:     10,
+		EndBefore: "93b8ea3a-6d4b-4fdc-abb3-49009198775c",
+		OrderBy:   "url",
+		OrderDir:  OrderDesc,
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, sql, "(w.url, w.id) > (SELECT")
+	assert.Contains(t, sql, "ORDER BY w.url ASC, w.id ASC LIMIT 11")
+
+	_, _, err = testListQuery().build(&ListOptions{OrderBy: "signing_key"})
+	assert.Equal(t, ErrOrderByInvalid, err)
+
+	_, _, err = testListQuery().build(&ListOptions{OrderDir: "sideways"})
+	assert.Error(t, err)
+}
+
+func TestListOptionsPage(t *testing.T) {
+	var nilOpts *ListOptions
+	rows := []int{1, 2, 3}
+	assert.Equal(t, &ListContext{}, nilOpts.page(&rows))
+	assert.Equal(t, []int{1, 2, 3}, rows)
+
+	// the extra row means there is a next page.
+	rows = []int{1, 2, 3}
+	lc := (&ListOptions{Limit: 2}).page(&rows)
+	assert.Equal(t, []int{1, 2}, rows)
+	assert.Equal(t, &ListContext{HasPrev: false, HasNext: true}, lc)
+
+	rows = []int{3, 4}
+	lc = (&ListOptions{Limit: 2, StartAfter: "x"}).page(&rows)
+	assert.Equal(t, []int{3, 4}, rows)
+	assert.Equal(t, &ListContext{HasPrev: true, HasNext: false}, lc)
+
+	// rows fetched before the cursor come back in reverse order.
+	rows = []int{4, 3, 2}
+	lc = (&ListOptions{Limit: 2, EndBefore: "x"}).page(&rows)
+	assert.Equal(t, []int{3, 4}, rows)
+	assert.Equal(t, &ListContext{HasPrev: true, HasNext: true}, lc)
 
-	q = q.Select([]string{"id", "uuid", "firstname", "lastname", "email"})
-	q = q.OrderBy("firstname")
-	q = q.OrderDir("asc")
-	q = q.Limit(2)
-	q = q.StartAfter("93b8ea3a-6d4b-4fdc-abb3-49009198775c")
+	rows = []int{2, 1}
+	lc = (&ListOptions{Limit: 2, EndBefore: "x"}).page(&rows)
+	assert.Equal(t, []int{1, 2}, rows)
+	assert.Equal(t, &ListContext{HasPrev: false, HasNext: true}, lc)
 }
@@ -0,0 +1,584 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrRefundNotFound is returned when the refund could not be found or
+// is no longer pending.
+var ErrRefundNotFound = errors.New("postgres: refund not found")
+
+// ErrOrderNotRefundable is returned when a refund is made against an
+// order that has not been paid or has been refunded in full.
+var ErrOrderNotRefundable = errors.New("postgres: order not refundable")
+
+// ErrRefundOrderItemNotFound is returned when a refund item refers to an
+// order item that is not part of the order.
+var ErrRefundOrderItemNotFound = errors.New("postgres: refund order item not found")
+
+// ErrRefundQtyExceeded is returned when a refund item would refund more
+// than the quantity left to refund for the order item.
+var ErrRefundQtyExceeded = errors.New("postgres: refund qty exceeded")
+
+// ErrRefundAmountExceeded is returned when the refund amount is more
+// than the amount left to refund for the order.
+var ErrRefundAmountExceeded = errors.New("postgres: refund amount exceeded")
+
+// NewRefundItem holds an order item and the quantity to refund.
+type NewRefundItem struct {
+	OrderItemUUID string
+	Qty           int
+}
+
+// RefundRow holds a single row of data from the refund table.
+type RefundRow struct {
+	id             int
+	UUID           string
+	orderID        int
+	OrderUUID      string
+	paymentID      int
+	StripeRefundID *string
+	Status         string
+	Amount         int
+	Reason         *string
+	Restock        bool
+	Created        time.Time
+	Modified       time.Time
+}
+
+// RefundItemRow holds a single row of data from the refund_item table.
+type RefundItemRow struct {
+	id            int
+	refundID      int
+	orderItemID   int
+	OrderItemUUID string
+	Qty           int
+	Amount        int
+	Created       time.Time
+}
+
+// refundableLine holds an order item with the amount paid for the line
+// including tax and the quantity already refunded.
+type refundableLine struct {
+	orderItemID   int
+	orderItemUUID string
+	qty           int
+	paid          int
+	refundedQty   int
+}
+
+// refundLineAmount returns the amount to refund for q units of a line
+// where refundedQty units have already been refunded. Each unit is given
+// its share of the amount paid for the line so that refunding every
+// unit returns exactly the amount paid.
+func refundLineAmount(paid, qty, refundedQty, q int) int {
+	if qty == 0 {
+		return 0
+	}
+	return paid*(refundedQty+q)/qty - paid*refundedQty/qty
+}
+
+// calcRefund returns the amount and the items of a refund. If both amount
+// and items are empty everything left to refund is refunded including
+// the remaining quantity of every line. If items are given the amount
+// is the sum of the line amounts unless an amount is also given.
+// Returns ErrRefundOrderItemNotFound, ErrRefundQtyExceeded or
+// ErrRefundAmountExceeded.
+func calcRefund(lines []*refundableLine, remaining int, amount *int, items []*NewRefundItem) (int, []*RefundItemRow, error) {
+	byUUID := make(map[string]*refundableLine, len(lines))
+	for _, l := range lines {
+		byUUID[l.orderItemUUID] = l
+	}
+
+	full := amount == nil && len(items) == 0
+	if full {
+		for _, l := range lines {
+			if l.qty > l.refundedQty {
+				items = append(items, &NewRefundItem{
+					OrderItemUUID: l.orderItemUUID,
+					Qty:           l.qty - l.refundedQty,
+				})
+			}
+		}
+	}
+
+	total := 0
+	refundItems := make([]*RefundItemRow, 0, len(items))
+	for _, item := range items {
+		l, ok := byUUID[item.OrderItemUUID]
+		if !ok {
+			return 0, nil, ErrRefundOrderItemNotFound
+		}
+		if l.refundedQty+item.Qty > l.qty {
+			return 0, nil, ErrRefundQtyExceeded
+		}
+		a := refundLineAmount(l.paid, l.qty, l.refundedQty, item.Qty)
+		total += a
+		refundItems = append(refundItems, &RefundItemRow{
+			orderItemID:   l.orderItemID,
+			OrderItemUUID: l.orderItemUUID,
+			Qty:           item.Qty,
+			Amount:        a,
+		})
+	}
+	if full {
+		total = remaining
+	} else if amount != nil {
+		total = *amount
+	}
+	if total <= 0 || total > remaining {
+		return 0, nil, ErrRefundAmountExceeded
+	}
+	return total, refundItems, nil
+}
+
+// CreateRefund adds a pending refund against the most recent payment of
+// an order. See calcRefund for how the amount and items are determined.
+// The Stripe payment intent of the order is returned so the refund can
+// be made with Stripe. Returns ErrOrderNotFound, ErrOrderNotRefundable,
+// ErrRefundOrderItemNotFound, ErrRefundQtyExceeded or
+// ErrRefundAmountExceeded.
+func (m *PgModel) CreateRefund(ctx context.Context, orderUUID string, amount *int, items []*NewRefundItem, restock bool, reason *string) (*RefundRow, []*RefundItemRow, *string, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: CreateRefund(ctx, orderUUID=%q, amount=%v, items=%v, restock=%t, reason=%v) started", orderUUID, amount, items, restock, reason)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "postgres: db.BeginTx")
+	}
+
+	// 1. Lock the order so concurrent refunds see the same amounts.
+	q1 := `
+		SELECT id, payment, stripe_pi, total_inc_vat, inc_tax
+		FROM "order" WHERE uuid = $1 FOR UPDATE
+	`
+	var orderID, totalIncVAT int
+	var payment string
+	var stripePI *string
+	var incTax bool
+	err = tx.QueryRowContext(ctx, q1, orderUUID).Scan(&orderID, &payment, &stripePI, &totalIncVAT, &incTax)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil, nil, ErrOrderNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if payment != "paid" && payment != "partially_refunded" {
+		tx.Rollback()
+		return nil, nil, nil, ErrOrderNotRefundable
+	}
+
+	// 2. Get the payment being refunded.
+	q2 := "SELECT id FROM payment WHERE order_id = $1 ORDER BY created DESC, id DESC LIMIT 1"
+	var paymentID int
+	err = tx.QueryRowContext(ctx, q2, orderID).Scan(&paymentID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil, nil, ErrOrderNotRefundable
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+
+	// 3. Get the amount already refunded.
+	q3 := "SELECT COALESCE(SUM(amount), 0) FROM refund WHERE order_id = $1 AND status <> 'failed'"
+	var refunded int
+	if err := tx.QueryRowContext(ctx, q3, orderID).Scan(&refunded); err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+	}
+
+	// 4. Get the order items with the quantity already refunded.
+	q4 := `
+		SELECT
+		  i.id, i.uuid, i.qty, i.unit_price, COALESCE(i.discount, 0) + i.order_discount, i.vat,
+		  COALESCE(x.qty, 0)
+		FROM order_item AS i
+		LEFT OUTER JOIN (
+		  SELECT ri.order_item_id, SUM(ri.qty) AS qty
+		  FROM refund_item AS ri
+		  INNER JOIN refund AS r
+		    ON r.id = ri.refund_id
+		  WHERE r.order_id = $1 AND r.status <> 'failed'
+		  GROUP BY ri.order_item_id
+		) AS x
+		  ON x.order_item_id = i.id
+		WHERE i.order_id = $1
+		ORDER BY i.id ASC
+	`
+	rows, err := tx.QueryContext(ctx, q4, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q4=%q, orderID=%d) failed", q4, orderID)
+	}
+	defer rows.Close()
+
+	lines := make([]*refundableLine, 0, 8)
+	for rows.Next() {
+		var l refundableLine
+		var unitPrice, discount, vat int
+		if err := rows.Scan(&l.orderItemID, &l.orderItemUUID, &l.qty, &unitPrice, &discount, &vat, &l.refundedQty); err != nil {
+			tx.Rollback()
+			return nil, nil, nil, errors.Wrapf(err, "postgres: scan q4=%q", q4)
+		}
+		l.paid = l.qty*unitPrice - discount
+		if !incTax {
+			l.paid += vat
+		}
+		lines = append(lines, &l)
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	total, refundItems, err := calcRefund(lines, totalIncVAT-refunded, amount, items)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, err
+	}
+
+	// 5. Insert the pending refund and its items.
+	q5 := `
+		INSERT INTO refund
+		  (order_id, payment_id, status, amount, reason, restock, created, modified)
+		VALUES
+		  ($1, $2, 'pending', $3, $4, $5, NOW(), NOW())
+		RETURNING
+		  id, uuid, order_id, payment_id, stripe_refund_id, status, amount,
+		  reason, restock, created, modified
+	`
+	r := RefundRow{}
+	row := tx.QueryRowContext(ctx, q5, orderID, paymentID, total, reason, restock)
+	if err := row.Scan(&r.id, &r.UUID, &r.orderID, &r.paymentID, &r.StripeRefundID, &r.Status,
+		&r.Amount, &r.Reason, &r.Restock, &r.Created, &r.Modified); err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: query row context q5=%q", q5)
+	}
+	r.OrderUUID = orderUUID
+
+	q6 := `
+		INSERT INTO refund_item
+		  (refund_id, order_item_id, qty, amount, created)
+		VALUES
+		  ($1, $2, $3, $4, NOW())
+		RETURNING id, refund_id, created
+	`
+	stmt6, err := tx.PrepareContext(ctx, q6)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: tx prepare for q6=%q", q6)
+	}
+	defer stmt6.Close()
+
+	for _, i := range refundItems {
+		row := stmt6.QueryRowContext(ctx, r.id, i.orderItemID, i.Qty, i.Amount)
+		if err := row.Scan(&i.id, &i.refundID, &i.Created); err != nil {
+			tx.Rollback()
+			return nil, nil, nil, errors.Wrapf(err, "postgres: scan q6=%q", q6)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "postgres: tx.Commit() failed")
+	}
+	return &r, refundItems, stripePI, nil
+}
+
+// succeedRefund restocks the items of a refund if requested and sets the
+// payment status of the order to partially_refunded or refunded once the
+// succeeded refunds reach the order total. The new payment status is
+// returned.
+func succeedRefund(ctx context.Context, tx *sql.Tx, refundID, orderID int, restock bool) (string, error) {
+	// 1. Return the refunded items to stock.
+	if restock {
+		if err := restockRefund(ctx, tx, refundID, orderID); err != nil {
+			return "", err
+		}
+	}
+
+	// 2. Update the payment status.
+	q2 := `
+		UPDATE "order" AS o
+		SET payment = CASE
+		  WHEN x.refunded >= o.total_inc_vat THEN 'refunded'::order_payment_status_t
+		  ELSE 'partially_refunded'::order_payment_status_t
+		END, modified = NOW()
+		FROM (
+		  SELECT COALESCE(SUM(amount), 0) AS refunded
+		  FROM refund
+		  WHERE order_id = $1 AND status = 'succeeded'
+		) AS x
+		WHERE o.id = $1
+		RETURNING o.payment
+	`
+	var payment string
+	if err := tx.QueryRowContext(ctx, q2, orderID).Scan(&payment); err != nil {
+		return "", errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	return payment, nil
+}
+
+// CompleteRefund records the outcome of a pending refund made with
+// Stripe. The status is succeeded, pending or failed. A refund still
+// pending only has its Stripe refund id set and is settled later. If the
+// refund succeeded the items are restocked if requested and the payment
+// status of the order is updated. The refund and the payment status of
+// the order are returned. Returns ErrRefundNotFound if the refund does
+// not exist or is no longer pending.
+func (m *PgModel) CompleteRefund(ctx context.Context, refundUUID string, stripeRefundID *string, status string) (*RefundRow, string, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: CompleteRefund(ctx, refundUUID=%q, stripeRefundID=%v, status=%q) started", refundUUID, stripeRefundID, status)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "postgres: db.BeginTx")
+	}
+
+	q1 := `
+		UPDATE refund AS r
+		SET status = $2, stripe_refund_id = COALESCE($3, r.stripe_refund_id), modified = NOW()
+		FROM "order" AS o
+		WHERE r.uuid = $1 AND r.status = 'pending' AND o.id = r.order_id
+		RETURNING
+		  r.id, r.uuid, r.order_id, o.uuid, r.payment_id, r.stripe_refund_id,
+		  r.status, r.amount, r.reason, r.restock, r.created, r.modified,
+		  o.payment
+	`
+	r := RefundRow{}
+	var payment string
+	row := tx.QueryRowContext(ctx, q1, refundUUID, status, stripeRefundID)
+	err = row.Scan(&r.id, &r.UUID, &r.orderID, &r.OrderUUID, &r.paymentID, &r.StripeRefundID,
+		&r.Status, &r.Amount, &r.Reason, &r.Restock, &r.Created, &r.Modified, &payment)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, "", ErrRefundNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, "", errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+
+	if status == "succeeded" {
+		payment, err = succeedRefund(ctx, tx, r.id, r.orderID, r.Restock)
+		if err != nil {
+			tx.Rollback()
+			return nil, "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", errors.Wrap(err, "postgres: tx.Commit() failed")
+	}
+	return &r, payment, nil
+}
+
+// RecordPaymentRefund records a refund reported by the payment provider
+// against the order with the given payment intent. Refunds made through
+// this API are found by the provider's refund id or by refundUUID and
+// are marked as succeeded if still pending. Refunds made elsewhere, for
+// example from the Stripe dashboard, are added. The refund is returned along with the payment status of the
+// order and true if the refund was not already recorded as succeeded.
+// Returns ErrOrderNotFound if no order has the payment intent.
+func (m *PgModel) RecordPaymentRefund(ctx context.Context, pi, stripeRefundID string, refundUUID *string, amount int) (*RefundRow, string, bool, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: RecordPaymentRefund(ctx, pi=%q, stripeRefundID=%q, refundUUID=%v, amount=%d) started", pi, stripeRefundID, refundUUID, amount)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", false, errors.Wrap(err, "postgres: db.BeginTx")
+	}
+
+	// 1. Lock the order.
+	q1 := `SELECT id, uuid, payment FROM "order" WHERE stripe_pi = $1 FOR UPDATE`
+	var orderID int
+	var orderUUID, payment string
+	err = tx.QueryRowContext(ctx, q1, pi).Scan(&orderID, &orderUUID, &payment)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, "", false, ErrOrderNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, "", false, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+
+	// 2. Find the refund by its Stripe id or uuid.
+	q2 := `
+		SELECT
+		  id, uuid, order_id, payment_id, stripe_refund_id, status, amount,
+		  reason, restock, created, modified
+		FROM refund
+		WHERE order_id = $1 AND (stripe_refund_id = $2 OR uuid::text = $3)
+	`
+	r := RefundRow{}
+	err = tx.QueryRowContext(ctx, q2, orderID, stripeRefundID, refundUUID).Scan(&r.id, &r.UUID, &r.orderID, &r.paymentID,
+		&r.StripeRefundID, &r.Status, &r.Amount, &r.Reason, &r.Restock, &r.Created, &r.Modified)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return nil, "", false, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+	}
+	r.OrderUUID = orderUUID
+	if err == nil && r.Status == "succeeded" {
+		tx.Rollback()
+		return &r, payment, false, nil
+	}
+
+	if err == sql.ErrNoRows {
+		// 3. Add the refund against the most recent payment.
+		q3 := `
+			INSERT INTO refund
+			  (order_id, payment_id, stripe_refund_id, status, amount, created, modified)
+			SELECT
+			  $1, p.id, $2, 'succeeded', $3, NOW(), NOW()
+			FROM payment AS p
+			WHERE p.order_id = $1
+			ORDER BY p.created DESC, p.id DESC
+			LIMIT 1
+			RETURNING
+			  id, uuid, order_id, payment_id, stripe_refund_id, status, amount,
+			  reason, restock, created, modified
+		`
+		err = tx.QueryRowContext(ctx, q3, orderID, stripeRefundID, amount).Scan(&r.id, &r.UUID, &r.orderID, &r.paymentID,
+			&r.StripeRefundID, &r.Status, &r.Amount, &r.Reason, &r.Restock, &r.Created, &r.Modified)
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			return nil, "", false, ErrOrderNotRefundable
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, "", false, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+		}
+	} else {
+		// 4. Mark the pending refund as succeeded.
+		q4 := `
+			UPDATE refund
+			SET status = 'succeeded', stripe_refund_id = $2, modified = NOW()
+			WHERE id = $1
+			RETURNING stripe_refund_id, status, modified
+		`
+		if err := tx.QueryRowContext(ctx, q4, r.id, stripeRefundID).Scan(&r.StripeRefundID, &r.Status, &r.Modified); err != nil {
+			tx.Rollback()
+			return nil, "", false, errors.Wrapf(err, "postgres: query row context q4=%q", q4)
+		}
+	}
+
+	payment, err = succeedRefund(ctx, tx, r.id, orderID, r.Restock)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", false, errors.Wrap(err, "postgres: tx.Commit() failed")
+	}
+	return &r, payment, true, nil
+}
+
+// GetRefundByUUID returns a single refund. Returns ErrRefundNotFound if
+// the refund does not exist.
+func (m *PgModel) GetRefundByUUID(ctx context.Context, refundUUID string) (*RefundRow, error) {
+	q1 := `
+		SELECT
+		  r.id, r.uuid, r.order_id, o.uuid, r.payment_id, r.stripe_refund_id,
+		  r.status, r.amount, r.reason, r.restock, r.created, r.modified
+		FROM refund AS r
+		INNER JOIN "order" AS o
+		  ON o.id = r.order_id
+		WHERE r.uuid = $1
+	`
+	r := RefundRow{}
+	err := m.db.QueryRowContext(ctx, q1, refundUUID).Scan(&r.id, &r.UUID, &r.orderID, &r.OrderUUID, &r.paymentID,
+		&r.StripeRefundID, &r.Status, &r.Amount, &r.Reason, &r.Restock, &r.Created, &r.Modified)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefundNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context refundUUID=%q q1=%q", refundUUID, q1)
+	}
+	return &r, nil
+}
+
+// GetRefundItems returns the items of a refund.
+func (m *PgModel) GetRefundItems(ctx context.Context, refundUUID string) ([]*RefundItemRow, error) {
+	q1 := `
+		SELECT
+		  ri.id, ri.refund_id, ri.order_item_id, oi.uuid, ri.qty, ri.amount, ri.created
+		FROM refund_item AS ri
+		INNER JOIN refund AS r
+		  ON r.id = ri.refund_id
+		INNER JOIN order_item AS oi
+		  ON oi.id = ri.order_item_id
+		WHERE r.uuid = $1
+		ORDER BY ri.id ASC
+	`
+	rows, err := m.db.QueryContext(ctx, q1, refundUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q, refundUUID=%q) failed", q1, refundUUID)
+	}
+	defer rows.Close()
+
+	items := make([]*RefundItemRow, 0, 4)
+	for rows.Next() {
+		var i RefundItemRow
+		if err := rows.Scan(&i.id, &i.refundID, &i.orderItemID, &i.OrderItemUUID, &i.Qty, &i.Amount, &i.Created); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return items, nil
+}
+
+// GetRefundsByOrderUUID returns the refunds of an order oldest first.
+// Returns ErrOrderNotFound if the order does not exist.
+func (m *PgModel) GetRefundsByOrderUUID(ctx context.Context, orderUUID string) ([]*RefundRow, error) {
+	q1 := `SELECT id FROM "order" WHERE uuid = $1`
+	var orderID int
+	err := m.db.QueryRowContext(ctx, q1, orderUUID).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+
+	q2 := `
+		SELECT
+		  id, uuid, order_id, payment_id, stripe_refund_id, status, amount,
+		  reason, restock, created, modified
+		FROM refund
+		WHERE order_id = $1
+		ORDER BY created ASC, id ASC
+	`
+	rows, err := m.db.QueryContext(ctx, q2, orderID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q2=%q, orderID=%d) failed", q2, orderID)
+	}
+	defer rows.Close()
+
+	refunds := make([]*RefundRow, 0, 4)
+	for rows.Next() {
+		var r RefundRow
+		if err := rows.Scan(&r.id, &r.UUID, &r.orderID, &r.paymentID, &r.StripeRefundID, &r.Status,
+			&r.Amount, &r.Reason, &r.Restock, &r.Created, &r.Modified); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		r.OrderUUID = orderUUID
+		refunds = append(refunds, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return refunds, nil
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRefundableLines() []*refundableLine {
+	return []*refundableLine{
+		{orderItemID: 1, orderItemUUID: "a", qty: 3, paid: 1000},
+		{orderItemID: 2, orderItemUUID: "b", qty: 1, paid: 600, refundedQty: 1},
+	}
+}
+
+func TestRefundLineAmount(t *testing.T) {
+	// units are refunded one at a time and add up to the amount paid.
+	assert.Equal(t, 333, refundLineAmount(1000, 3, 0, 1))
+	assert.Equal(t, 333, refundLineAmount(1000, 3, 1, 1))
+	assert.Equal(t, 334, refundLineAmount(1000, 3, 2, 1))
+	assert.Equal(t, 1000, refundLineAmount(1000, 3, 0, 3))
+	assert.Equal(t, 0, refundLineAmount(1000, 0, 0, 1))
+}
+
+func TestCalcRefundFull(t *testing.T) {
+	total, items, err := calcRefund(testRefundableLines(), 1500, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, total)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "a", items[0].OrderItemUUID)
+	assert.Equal(t, 3, items[0].Qty)
+	assert.Equal(t, 1000, items[0].Amount)
+}
+
+func TestCalcRefundItems(t *testing.T) {
+	items := []*NewRefundItem{{OrderItemUUID: "a", Qty: 2}}
+	total, refundItems, err := calcRefund(testRefundableLines(), 1500, nil, items)
+	assert.NoError(t, err)
+	assert.Equal(t, 666, total)
+	assert.Equal(t, 666, refundItems[0].Amount)
+
+	// an amount overrides the sum of the lines.
+	amount := 500
+	total, _, err = calcRefund(testRefundableLines(), 1500, &amount, items)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, total)
+}
+
+func TestCalcRefundErrors(t *testing.T) {
+	_, _, err := calcRefund(testRefundableLines(), 1500, nil, []*NewRefundItem{{OrderItemUUID: "x", Qty: 1}})
+	assert.Equal(t, ErrRefundOrderItemNotFound, err)
+
+	_, _, err = calcRefund(testRefundableLines(), 1500, nil, []*NewRefundItem{{OrderItemUUID: "b", Qty: 1}})
+	assert.Equal(t, ErrRefundQtyExceeded, err)
+
+	amount := 1501
+	_, _, err = calcRefund(testRefundableLines(), 1500, &amount, nil)
+	assert.Equal(t, ErrRefundAmountExceeded, err)
+
+	_, _, err = calcRefund(testRefundableLines(), 0, nil, nil)
+	assert.Equal(t, ErrRefundAmountExceeded, err)
+}
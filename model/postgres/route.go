@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// Route types.
+const (
+	RouteTypeProduct  = "product"
+	RouteTypeCategory = "category"
+)
+
+// RouteRow is a product or category that a storefront path resolves to.
+// Path is the current path of the product or category and Redirect is
+// true if the path resolved through the path history. Visible is false
+// for unpublished products and hidden categories.
+type RouteRow struct {
+	Type     string
+	UUID     string
+	Path     string
+	Redirect bool
+	Visible  bool
+}
+
+// GetRoutes returns the products and categories the given path resolves
+// to in order of precedence. A product or category with the path comes
+// before those that used to have the path. Products come before
+// categories.
+func (m *PgModel) GetRoutes(ctx context.Context, path string) ([]*RouteRow, error) {
+	q1 := `
+		SELECT 'product', p.uuid, p.path, false, (` + publishedCondition + `), 1
+		FROM product AS p
+		WHERE p.path = $1
+		UNION ALL
+		SELECT 'category', c.uuid, c.path, false, NOT ` + hiddenCategoryCondition + `, 2
+		FROM category AS c
+		WHERE c.path = $1
+		UNION ALL
+		SELECT 'product', p.uuid, p.path, true, (` + publishedCondition + `), 3
+		FROM path_redirect AS r
+		INNER JOIN product AS p
+		  ON p.id = r.product_id
+		WHERE r.from_path = $1
+		UNION ALL
+		SELECT 'category', c.uuid, c.path, true, NOT ` + hiddenCategoryCondition + `, 4
+		FROM path_redirect AS r
+		INNER JOIN category AS c
+		  ON c.id = r.category_id
+		WHERE r.from_path = $1
+		ORDER BY 6
+	`
+	rows, err := m.db.QueryContext(ctx, q1, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query context q1=%q", q1)
+	}
+	defer rows.Close()
+
+	routes := make([]*RouteRow, 0, 4)
+	for rows.Next() {
+		var r RouteRow
+		var precedence int
+		if err := rows.Scan(&r.Type, &r.UUID, &r.Path, &r.Redirect, &r.Visible, &precedence); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		routes = append(routes, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return routes, nil
+}
+
+// addProductRedirect records that the product with the given id has
+// moved from oldPath to newPath. Any redirect from newPath to another
+// product is removed as the path is in use again.
+func addProductRedirect(ctx context.Context, tx *sql.Tx, productID int, oldPath, newPath string) error {
+	q1 := "DELETE FROM path_redirect WHERE product_id IS NOT NULL AND from_path = $1"
+	if _, err := tx.ExecContext(ctx, q1, newPath); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+	q2 := `
+		INSERT INTO path_redirect (from_path, product_id, created)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (from_path) WHERE product_id IS NOT NULL
+		DO UPDATE SET product_id = EXCLUDED.product_id, created = NOW()
+	`
+	if _, err := tx.ExecContext(ctx, q2, oldPath, productID); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+	}
+	return nil
+}
+
+// addCategoryRedirect records that the category with the given id has
+// moved from oldPath to newPath. Any redirect from newPath to another
+// category is removed as the path is in use again.
+func addCategoryRedirect(ctx context.Context, tx *sql.Tx, categoryID int, oldPath, newPath string) error {
+	q1 := "DELETE FROM path_redirect WHERE category_id IS NOT NULL AND from_path = $1"
+	if _, err := tx.ExecContext(ctx, q1, newPath); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+	q2 := `
+		INSERT INTO path_redirect (from_path, category_id, created)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (from_path) WHERE category_id IS NOT NULL
+		DO UPDATE SET category_id = EXCLUDED.category_id, created = NOW()
+	`
+	if _, err := tx.ExecContext(ctx, q2, oldPath, categoryID); err != nil {
+		return errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+	}
+	return nil
+}
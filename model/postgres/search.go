@@ -0,0 +1,275 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// productSearchVector is the full-text search document of a product in
+// the product table aliased as p. It must match the expression of the
+// idx_product_search index so the index is used.
+const productSearchVector = `(
+	setweight(to_tsvector('english', p.name), 'A') ||
+	setweight(to_tsvector('simple', p.sku), 'A') ||
+	setweight(to_tsvector('english', p.description), 'B') ||
+	setweight(jsonb_to_tsvector('english', p.attributes, '["string", "numeric", "boolean"]'), 'C')
+)`
+
+// ProductSearch holds the filters of a product search. Query is a web
+// search style query that is matched against the name, SKU, description
+// and attributes of products. CategoryPath restricts the results to
+// products in the category subtree. MinPrice and MaxPrice are matched
+// against the unit price of the products in the price list with
+// PriceListUUID. Attributes holds attribute values keyed by attribute
+// code. Variants are not returned on their own but are considered when
+// filtering for products in stock. If PublishedOnly is set only
+// published products are matched and hidden categories are left out of
+// the category filter and facets.
+type ProductSearch struct {
+	Query         string
+	CategoryPath  *string
+	PriceListUUID string
+	MinPrice      *int
+	MaxPrice      *int
+	InStock       bool
+	Attributes    map[string]string
+	PublishedOnly bool
+	Limit         int
+}
+
+// CategoryFacetRow holds the number of matching products in a category
+// and all of its descendants.
+type CategoryFacetRow struct {
+	UUID  string
+	Path  string
+	Name  string
+	Count int
+}
+
+// AttributeFacetRow holds the number of matching products with an
+// attribute value.
+type AttributeFacetRow struct {
+	Code  string
+	Value string
+	Count int
+}
+
+// ProductSearchResult holds a page of matching products ordered by
+// relevance along with the total number of matches and the facet counts.
+type ProductSearchResult struct {
+	Products        []*ProductRow
+	Total           int
+	CategoryFacets  []*CategoryFacetRow
+	AttributeFacets []*AttributeFacetRow
+}
+
+// buildProductSearch returns the SQL of a common table expression named
+// matched holding the id, attributes and rank of every product that
+// matches the search along with its arguments. lft and rgt bound the
+// category subtree if the search has a CategoryPath.
+func buildProductSearch(s *ProductSearch, lft, rgt int) (string, []interface{}) {
+	args := make([]interface{}, 0, 8)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	rank := "0"
+	conds := []string{"p.parent_id IS NULL"}
+	if s.Query != "" {
+		q := arg(s.Query)
+		rank = fmt.Sprintf("ts_rank(%s, websearch_to_tsquery('english', %s))", productSearchVector, q)
+		conds = append(conds, fmt.Sprintf("%s @@ websearch_to_tsquery('english', %s)", productSearchVector, q))
+	}
+	if s.PublishedOnly {
+		conds = append(conds, "("+publishedCondition+")")
+	}
+	if s.CategoryPath != nil {
+		subtree := fmt.Sprintf(`EXISTS(
+		  SELECT 1 FROM product_category AS pc
+		  INNER JOIN category AS c
+		    ON c.id = pc.category_id
+		  WHERE pc.product_id = p.id AND c.lft >= %s AND c.rgt <= %s`, arg(lft), arg(rgt))
+		if s.PublishedOnly {
+			subtree = subtree + " AND NOT " + hiddenCategoryCondition
+		}
+		conds = append(conds, subtree+"\n\t\t)")
+	}
+	if s.MinPrice != nil || s.MaxPrice != nil {
+		cond := fmt.Sprintf(`EXISTS(
+		  SELECT 1 FROM price AS pr
+		  INNER JOIN price_list AS pl
+		    ON pl.id = pr.price_list_id
+		  WHERE pr.product_id = p.id AND pr.break = 1 AND pl.uuid = %s`, arg(s.PriceListUUID))
+		if s.MinPrice != nil {
+			cond = cond + " AND COALESCE(pr.offer_price, pr.unit_price) >= " + arg(*s.MinPrice)
+		}
+		if s.MaxPrice != nil {
+			cond = cond + " AND COALESCE(pr.offer_price, pr.unit_price) <= " + arg(*s.MaxPrice)
+		}
+		conds = append(conds, cond+")")
+	}
+	if s.InStock {
+		// products without inventory are always in stock.
+		conds = append(conds, `EXISTS(
+		  SELECT 1 FROM product AS sp
+		  LEFT OUTER JOIN inventory AS v
+		    ON v.product_id = sp.id
+		  LEFT OUTER JOIN (`+activeStock+`) AS a
+		    ON a.inventory_id = v.id
+		  WHERE (sp.id = p.id OR sp.parent_id = p.id) AND (v.id IS NULL OR a.onhand > 0)
+		)`)
+	}
+
+	// sort the attribute codes so the same search builds the same SQL.
+	codes := make([]string, 0, len(s.Attributes))
+	for code := range s.Attributes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		conds = append(conds, fmt.Sprintf("p.attributes ->> %s = %s", arg(code), arg(s.Attributes[code])))
+	}
+
+	cte := fmt.Sprintf(`
+		WITH matched AS (
+		  SELECT p.id, p.attributes, %s AS rank
+		  FROM product AS p
+		  WHERE %s
+		)
+	`, rank, strings.Join(conds, " AND\n\t\t  "))
+	return cte, args
+}
+
+// SearchProducts returns the products matching the search ordered by
+// relevance and then by name along with the facet counts of all matching
+// products. Returns ErrCategoryNotFound if the search has a CategoryPath
+// that does not exist or is hidden when PublishedOnly is set.
+func (m *PgModel) SearchProducts(ctx context.Context, s *ProductSearch) (*ProductSearchResult, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: SearchProducts(ctx, s=%+v) started", s)
+
+	// 1. Find the bounds of the category subtree.
+	var lft, rgt int
+	if s.CategoryPath != nil {
+		q1 := "SELECT c.lft, c.rgt FROM category AS c WHERE c.path = $1"
+		if s.PublishedOnly {
+			q1 = q1 + " AND NOT " + hiddenCategoryCondition
+		}
+		err := m.db.QueryRowContext(ctx, q1, *s.CategoryPath).Scan(&lft, &rgt)
+		if err == sql.ErrNoRows {
+			return nil, ErrCategoryNotFound
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+		}
+	}
+	cte, args := buildProductSearch(s, lft, rgt)
+
+	// 2. Get a page of the matching products.
+	q2 := cte + `
+		SELECT
+		  p.id, p.parent_id, p.uuid, p.sku, p.path, p.name, p.tax_code,
+		  p.description, p.attributes, p.meta_title, p.meta_description,
+		  p.status, p.publish_at, p.unpublish_at, p.created, p.modified
+		FROM matched AS x
+		INNER JOIN product AS p
+		  ON p.id = x.id
+		ORDER BY x.rank DESC, p.name, p.id
+		LIMIT ` + fmt.Sprintf("%d", s.Limit)
+	rows, err := m.db.QueryContext(ctx, q2, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q2=%q) failed", q2)
+	}
+	defer rows.Close()
+
+	result := ProductSearchResult{
+		Products:        make([]*ProductRow, 0, s.Limit),
+		CategoryFacets:  make([]*CategoryFacetRow, 0, 16),
+		AttributeFacets: make([]*AttributeFacetRow, 0, 16),
+	}
+	for rows.Next() {
+		var p ProductRow
+		if err := rows.Scan(&p.id, &p.parentID, &p.UUID, &p.SKU, &p.Path, &p.Name, &p.TaxCode,
+			&p.Description, &p.Attributes, &p.MetaTitle, &p.MetaDescription,
+			&p.Status, &p.PublishAt, &p.UnpublishAt, &p.Created, &p.Modified); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		result.Products = append(result.Products, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	// 3. Count all the matching products.
+	q3 := cte + "SELECT COUNT(*) FROM matched"
+	if err := m.db.QueryRowContext(ctx, q3, args...).Scan(&result.Total); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+	}
+
+	// 4. Count the matching products in each category including
+	// those in its descendants. A product in a category that is
+	// hidden or has a hidden ancestor is not counted in any facet.
+	q4 := cte + `
+		SELECT fc.uuid, fc.path, fc.name, COUNT(DISTINCT x.id)
+		FROM category AS fc
+		INNER JOIN category AS c
+		  ON c.lft >= fc.lft AND c.rgt <= fc.rgt
+		INNER JOIN product_category AS pc
+		  ON pc.category_id = c.id
+		INNER JOIN matched AS x
+		  ON x.id = pc.product_id
+	`
+	if s.PublishedOnly {
+		q4 = q4 + "WHERE NOT " + hiddenCategoryCondition + "\n"
+	}
+	q4 = q4 + `
+		GROUP BY fc.id
+		ORDER BY fc.lft
+	`
+	rows4, err := m.db.QueryContext(ctx, q4, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q4=%q) failed", q4)
+	}
+	defer rows4.Close()
+	for rows4.Next() {
+		var f CategoryFacetRow
+		if err := rows4.Scan(&f.UUID, &f.Path, &f.Name, &f.Count); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		result.CategoryFacets = append(result.CategoryFacets, &f)
+	}
+	if err := rows4.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows4.Err()")
+	}
+
+	// 5. Count the matching products with each attribute value.
+	q5 := cte + `
+		SELECT a.key, a.value, COUNT(*)
+		FROM matched AS x, jsonb_each_text(x.attributes) AS a
+		GROUP BY a.key, a.value
+		ORDER BY a.key, COUNT(*) DESC, a.value
+	`
+	rows5, err := m.db.QueryContext(ctx, q5, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q5=%q) failed", q5)
+	}
+	defer rows5.Close()
+	for rows5.Next() {
+		var f AttributeFacetRow
+		if err := rows5.Scan(&f.Code, &f.Value, &f.Count); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		result.AttributeFacets = append(result.AttributeFacets, &f)
+	}
+	if err := rows5.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows5.Err()")
+	}
+	return &result, nil
+}
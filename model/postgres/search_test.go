@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildProductSearch(t *testing.T) {
+	cte, args := buildProductSearch(&ProductSearch{Limit: 10}, 0, 0)
+	assert.Empty(t, args)
+	assert.Contains(t, cte, "p.parent_id IS NULL")
+	assert.Contains(t, cte, "0 AS rank")
+	assert.NotContains(t, cte, "websearch_to_tsquery")
+
+	path := "a/b"
+	cte, _ = buildProductSearch(&ProductSearch{CategoryPath: &path, Limit: 10}, 4, 9)
+	assert.NotContains(t, cte, "h.hidden")
+
+	minPrice, maxPrice := 500, 2000
+	s := ProductSearch{
+		Query:         "water bottle",
+		CategoryPath:  &path,
+		PriceListUUID: "b4d5e2a4-1d9f-4f6c-9f0b-4a8d1c9c6a71",
+		MinPrice:      &minPrice,
+		MaxPrice:      &maxPrice,
+		InStock:       true,
+		Attributes: map[string]string{
+			"material": "steel",
+			"colour":   "red",
+		},
+		PublishedOnly: true,
+		Limit:         10,
+	}
+	cte, args = buildProductSearch(&s, 4, 9)
+	assert.Equal(t, []interface{}{
+		"water bottle",
+		4, 9,
+		"b4d5e2a4-1d9f-4f6c-9f0b-4a8d1c9c6a71", 500, 2000,
+		"colour", "red",
+		"material", "steel",
+	}, args)
+	assert.Equal(t, 2, strings.Count(cte, "websearch_to_tsquery('english', $1)"))
+	assert.Contains(t, cte, "c.lft >= $2 AND c.rgt <= $3")
+	assert.Contains(t, cte, "pl.uuid = $4")
+	assert.Contains(t, cte, ">= $5")
+	assert.Contains(t, cte, "<= $6")
+	assert.Contains(t, cte, "p.attributes ->> $7 = $8")
+	assert.Contains(t, cte, "p.attributes ->> $9 = $10")
+	assert.Contains(t, cte, "p.status = 'active'")
+	assert.Contains(t, cte, "a.onhand > 0")
+	assert.Contains(t, cte, "h.hidden AND h.lft <= c.lft")
+}
@@ -0,0 +1,348 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrShipmentNotFound is returned when the shipment could not be found.
+var ErrShipmentNotFound = errors.New("postgres: shipment not found")
+
+// ErrShipmentOrderItemNotFound is returned when a shipment item refers to
+// an order item that is not part of the order.
+var ErrShipmentOrderItemNotFound = errors.New("postgres: shipment order item not found")
+
+// ErrShipmentQtyExceeded is returned when a shipment item would ship more
+// than the quantity left to ship for the order item.
+var ErrShipmentQtyExceeded = errors.New("postgres: shipment qty exceeded")
+
+// NewShipmentItem holds an order item and the quantity to ship.
+type NewShipmentItem struct {
+	OrderItemUUID string
+	Qty           int
+}
+
+// ShipmentRow holds a single row of data from the shipment table.
+type ShipmentRow struct {
+	id             int
+	UUID           string
+	orderID        int
+	OrderUUID      string
+	Carrier        string
+	TrackingNumber string
+	TrackingURL    *string
+	Created        time.Time
+	Modified       time.Time
+}
+
+// ShipmentItemRow holds a single row of data from the shipment_item
+// table.
+type ShipmentItemRow struct {
+	id            int
+	shipmentID    int
+	orderItemID   int
+	OrderItemUUID string
+	Qty           int
+	Created       time.Time
+}
+
+// allItemsShipped returns true if the shipped quantity of every order
+// item has reached the ordered quantity. Both maps are keyed by order
+// item id.
+func allItemsShipped(ordered, shipped map[int]int) bool {
+	for id, qty := range ordered {
+		if shipped[id] < qty {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateShipment adds a shipment of the given order items to an order.
+// The order must have the from status. Once the shipment is added the
+// order moves to shipped if every order item has been shipped in full or
+// partially_shipped otherwise. Backordered units are taken from stock as
+// they ship. If the order status changes the history row is returned,
+// otherwise nil. Returns ErrOrderNotFound, ErrOrderStatusChanged,
+// ErrShipmentOrderItemNotFound or ErrShipmentQtyExceeded.
+func (m *PgModel) CreateShipment(ctx context.Context, orderUUID, from, carrier, trackingNumber string, trackingURL *string, items []*NewShipmentItem) (*ShipmentRow, []*ShipmentItemRow, *OrderStatusHistoryRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: CreateShipment(ctx, orderUUID=%q, from=%q, carrier=%q, trackingNumber=%q, trackingURL=%v, items=%v) started", orderUUID, from, carrier, trackingNumber, trackingURL, items)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "postgres: db.BeginTx")
+	}
+
+	// 1. Lock the order so concurrent shipments see the same quantities.
+	q1 := `SELECT id, status FROM "order" WHERE uuid = $1 FOR UPDATE`
+	var orderID int
+	var status string
+	err = tx.QueryRowContext(ctx, q1, orderUUID).Scan(&orderID, &status)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil, nil, ErrOrderNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if status != from {
+		tx.Rollback()
+		return nil, nil, nil, ErrOrderStatusChanged
+	}
+
+	// 2. Get the ordered and already shipped quantities of each item.
+	q2 := `
+		SELECT
+		  i.id, i.uuid, i.qty, COALESCE(SUM(s.qty), 0)
+		FROM order_item AS i
+		LEFT OUTER JOIN shipment_item AS s
+		  ON s.order_item_id = i.id
+		WHERE i.order_id = $1
+		GROUP BY i.id, i.uuid, i.qty
+	`
+	rows, err := tx.QueryContext(ctx, q2, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q2=%q, orderID=%d) failed", q2, orderID)
+	}
+	defer rows.Close()
+
+	orderItemIDs := make(map[string]int)
+	ordered := make(map[int]int)
+	shipped := make(map[int]int)
+	for rows.Next() {
+		var id, qty, shippedQty int
+		var uuid string
+		if err := rows.Scan(&id, &uuid, &qty, &shippedQty); err != nil {
+			tx.Rollback()
+			return nil, nil, nil, errors.Wrapf(err, "postgres: scan q2=%q", q2)
+		}
+		orderItemIDs[uuid] = id
+		ordered[id] = qty
+		shipped[id] = shippedQty
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	shipping := make(map[int]int)
+	for _, item := range items {
+		id, ok := orderItemIDs[item.OrderItemUUID]
+		if !ok {
+			tx.Rollback()
+			return nil, nil, nil, ErrShipmentOrderItemNotFound
+		}
+		if shipped[id]+item.Qty > ordered[id] {
+			tx.Rollback()
+			return nil, nil, nil, ErrShipmentQtyExceeded
+		}
+		shipped[id] += item.Qty
+		shipping[id] += item.Qty
+	}
+
+	// Backordered units were never taken from stock when the order was
+	// placed so take them now they ship.
+	if err := takeBackorderedStock(ctx, tx, orderID, shipping); err != nil {
+		tx.Rollback()
+		return nil, nil, nil, err
+	}
+
+	// 3. Insert the shipment.
+	q3 := `
+		INSERT INTO shipment
+		  (order_id, carrier, tracking_number, tracking_url, created, modified)
+		VALUES
+		  ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING
+		  id, uuid, order_id, carrier, tracking_number, tracking_url,
+		  created, modified
+	`
+	s := ShipmentRow{}
+	row := tx.QueryRowContext(ctx, q3, orderID, carrier, trackingNumber, trackingURL)
+	if err := row.Scan(&s.id, &s.UUID, &s.orderID, &s.Carrier, &s.TrackingNumber,
+		&s.TrackingURL, &s.Created, &s.Modified); err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
+	}
+	s.OrderUUID = orderUUID
+
+	// 4. Insert the shipment items.
+	q4 := `
+		INSERT INTO shipment_item
+		  (shipment_id, order_item_id, qty, created)
+		VALUES
+		  ($1, $2, $3, NOW())
+		RETURNING
+		  id, shipment_id, order_item_id, qty, created
+	`
+	stmt4, err := tx.PrepareContext(ctx, q4)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, errors.Wrapf(err, "postgres: tx prepare for q4=%q", q4)
+	}
+	defer stmt4.Close()
+
+	shipmentItems := make([]*ShipmentItemRow, 0, len(items))
+	for _, item := range items {
+		i := ShipmentItemRow{}
+		row := stmt4.QueryRowContext(ctx, s.id, orderItemIDs[item.OrderItemUUID], item.Qty)
+		if err := row.Scan(&i.id, &i.shipmentID, &i.orderItemID, &i.Qty, &i.Created); err != nil {
+			tx.Rollback()
+			return nil, nil, nil, errors.Wrapf(err, "postgres: scan q4=%q", q4)
+		}
+		i.OrderItemUUID = item.OrderItemUUID
+		shipmentItems = append(shipmentItems, &i)
+	}
+
+	// 5. Move the order along if the status changes.
+	to := "partially_shipped"
+	if allItemsShipped(ordered, shipped) {
+		to = "shipped"
+	}
+	var history *OrderStatusHistoryRow
+	if to != status {
+		q5 := `UPDATE "order" SET status = $2, modified = NOW() WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, q5, orderID, to); err != nil {
+			tx.Rollback()
+			return nil, nil, nil, errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q5=%q, orderID=%d, to=%q) failed", q5, orderID, to)
+		}
+		if err := insertOrderStatusHistory(ctx, tx, orderID, &status, to, nil); err != nil {
+			tx.Rollback()
+			return nil, nil, nil, err
+		}
+		history = &OrderStatusHistoryRow{
+			orderID:    orderID,
+			FromStatus: &status,
+			ToStatus:   to,
+			Created:    time.Now(),
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "postgres: tx.Commit() failed")
+	}
+	return &s, shipmentItems, history, nil
+}
+
+// GetShipmentByUUID returns a shipment and its items. Returns
+// ErrShipmentNotFound if the shipment does not exist.
+func (m *PgModel) GetShipmentByUUID(ctx context.Context, shipmentUUID string) (*ShipmentRow, []*ShipmentItemRow, error) {
+	q1 := `
+		SELECT
+		  s.id, s.uuid, s.order_id, o.uuid, s.carrier, s.tracking_number,
+		  s.tracking_url, s.created, s.modified
+		FROM shipment AS s
+		INNER JOIN "order" AS o
+		  ON o.id = s.order_id
+		WHERE s.uuid = $1
+	`
+	s := ShipmentRow{}
+	err := m.db.QueryRowContext(ctx, q1, shipmentUUID).Scan(&s.id, &s.UUID, &s.orderID, &s.OrderUUID,
+		&s.Carrier, &s.TrackingNumber, &s.TrackingURL, &s.Created, &s.Modified)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: query row context shipmentUUID=%q q1=%q", shipmentUUID, q1)
+	}
+
+	items, err := m.getShipmentItems(ctx, s.orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &s, items[s.id], nil
+}
+
+// GetShipmentsByOrderUUID returns the shipments of an order oldest first
+// along with the items of each shipment keyed by shipment UUID. Returns
+// ErrOrderNotFound if the order does not exist.
+func (m *PgModel) GetShipmentsByOrderUUID(ctx context.Context, orderUUID string) ([]*ShipmentRow, map[string][]*ShipmentItemRow, error) {
+	q1 := `SELECT id FROM "order" WHERE uuid = $1`
+	var orderID int
+	err := m.db.QueryRowContext(ctx, q1, orderUUID).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+
+	q2 := `
+		SELECT
+		  id, uuid, order_id, carrier, tracking_number, tracking_url,
+		  created, modified
+		FROM shipment
+		WHERE order_id = $1
+		ORDER BY created ASC, id ASC
+	`
+	rows, err := m.db.QueryContext(ctx, q2, orderID)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q2=%q, orderID=%d) failed", q2, orderID)
+	}
+	defer rows.Close()
+
+	shipments := make([]*ShipmentRow, 0, 4)
+	for rows.Next() {
+		var s ShipmentRow
+		if err := rows.Scan(&s.id, &s.UUID, &s.orderID, &s.Carrier, &s.TrackingNumber,
+			&s.TrackingURL, &s.Created, &s.Modified); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		s.OrderUUID = orderUUID
+		shipments = append(shipments, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	items, err := m.getShipmentItems(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	byUUID := make(map[string][]*ShipmentItemRow, len(shipments))
+	for _, s := range shipments {
+		byUUID[s.UUID] = items[s.id]
+	}
+	return shipments, byUUID, nil
+}
+
+// getShipmentItems returns the shipment items of every shipment of an
+// order keyed by shipment id.
+func (m *PgModel) getShipmentItems(ctx context.Context, orderID int) (map[int][]*ShipmentItemRow, error) {
+	q1 := `
+		SELECT
+		  si.id, si.shipment_id, si.order_item_id, oi.uuid, si.qty, si.created
+		FROM shipment_item AS si
+		INNER JOIN shipment AS s
+		  ON s.id = si.shipment_id
+		INNER JOIN order_item AS oi
+		  ON oi.id = si.order_item_id
+		WHERE s.order_id = $1
+		ORDER BY si.id ASC
+	`
+	rows, err := m.db.QueryContext(ctx, q1, orderID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q, orderID=%d) failed", q1, orderID)
+	}
+	defer rows.Close()
+
+	items := make(map[int][]*ShipmentItemRow)
+	for rows.Next() {
+		var i ShipmentItemRow
+		if err := rows.Scan(&i.id, &i.shipmentID, &i.orderItemID, &i.OrderItemUUID, &i.Qty, &i.Created); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		items[i.shipmentID] = append(items[i.shipmentID], &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return items, nil
+}
@@ -0,0 +1,15 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllItemsShipped(t *testing.T) {
+	ordered := map[int]int{1: 2, 2: 1}
+	assert.False(t, allItemsShipped(ordered, map[int]int{}))
+	assert.False(t, allItemsShipped(ordered, map[int]int{1: 2}))
+	assert.False(t, allItemsShipped(ordered, map[int]int{1: 1, 2: 1}))
+	assert.True(t, allItemsShipped(ordered, map[int]int{1: 2, 2: 1}))
+}
@@ -84,16 +84,33 @@ func (m *PgModel) GetShippingTariffByUUID(ctx context.Context, shippingTariffUUI
 	return &s, nil
 }
 
-// GetShippingTariffs returns a list of all ShippingTariffRows from the
-// shipping_tariff table.
-func (m *PgModel) GetShippingTariffs(ctx context.Context) ([]*ShippingTariffRow, error) {
-	q1 := `
-		SELECT id, uuid, country_code, shipping_code, name, price, tax_code, created, modified
-		FROM shipping_tariff
-	`
-	rows, err := m.db.QueryContext(ctx, q1)
+// GetShippingTariffs returns a page of shipping tariffs.
+func (m *PgModel) GetShippingTariffs(ctx context.Context, opts *ListOptions) ([]*ShippingTariffRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  id, uuid, country_code, shipping_code, name, price, tax_code, created, modified`,
+		from:  "shipping_tariff AS s",
+		alias: "s",
+		fields: map[string]string{
+			"country_code":  "s.country_code",
+			"shipping_code": "s.shipping_code",
+			"price":         "s.price",
+			"created":       "s.created",
+			"modified":      "s.modified",
+		},
+		orderBy:  "s.created",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
 	}
 	defer rows.Close()
 
@@ -102,17 +119,17 @@ func (m *PgModel) GetShippingTariffs(ctx context.Context) ([]*ShippingTariffRow,
 		var s ShippingTariffRow
 		err = rows.Scan(&s.id, &s.UUID, &s.CountryCode, &s.ShippingCode, &s.Name, &s.Price, &s.TaxCode, &s.Created, &s.Modified)
 		if err == sql.ErrNoRows {
-			return nil, ErrShippingTariffNotFound
+			return nil, nil, ErrShippingTariffNotFound
 		}
 		if err != nil {
-			return nil, errors.Wrap(err, "postgres: scan failed")
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
 		}
 		tariffs = append(tariffs, &s)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows.Err()")
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
 	}
-	return tariffs, nil
+	return tariffs, opts.page(&tariffs), nil
 }
 
 // UpdateShippingTariff updates a shipping tariff.
@@ -0,0 +1,593 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// InsufficientStockError is returned when an order cannot be placed
+// because there is not enough stock of one or more products that do not
+// allow overselling or backorders or have reached the backorder limit.
+type InsufficientStockError struct {
+	SKUs []string
+}
+
+func (e *InsufficientStockError) Error() string {
+	return "postgres: insufficient stock for " + strings.Join(e.SKUs, ", ")
+}
+
+// Stock allocation strategies used to choose the locations stock is
+// taken from when an order is placed.
+const (
+	// AllocationPriority takes stock from locations in priority order.
+	AllocationPriority = "priority"
+
+	// AllocationNearest takes stock from locations in the shipping
+	// country first and then in priority order.
+	AllocationNearest = "nearest"
+)
+
+// activeStock is a sub query of the onhand of each inventory across the
+// active locations. Stock at inactive locations cannot be taken by
+// orders so availability uses the same total as reserveStock.
+const activeStock = `
+	SELECT il.inventory_id, SUM(il.onhand) AS onhand
+	FROM inventory_location AS il
+	INNER JOIN location AS l
+	  ON l.id = il.location_id
+	WHERE l.active
+	GROUP BY il.inventory_id
+`
+
+// stockLevel holds the stock of a single product locked for update.
+// onhand is the total of the active locations and backordered is the
+// quantity already backordered by open orders. Stock arriving is owed to
+// the open backorders first so only onhand less backordered is free to
+// take.
+type stockLevel struct {
+	inventoryID    int
+	onhand         int
+	overselling    bool
+	backorder      string
+	backorderLimit *int
+	backordered    int
+	locations      []*locationStock
+}
+
+// locationStock holds the stock of a product at a single location.
+type locationStock struct {
+	locationID  int
+	countryCode string
+	priority    int
+	onhand      int
+}
+
+// allocation holds the quantity taken from a single location.
+type allocation struct {
+	locationID int
+	qty        int
+}
+
+// rankLocations sorts the stock of a product into the order the
+// locations are allocated using the given strategy.
+func rankLocations(stocks []*locationStock, strategy, countryCode string) {
+	sort.SliceStable(stocks, func(i, j int) bool {
+		if strategy == AllocationNearest {
+			ni := stocks[i].countryCode == countryCode
+			nj := stocks[j].countryCode == countryCode
+			if ni != nj {
+				return ni
+			}
+		}
+		if stocks[i].priority != stocks[j].priority {
+			return stocks[i].priority < stocks[j].priority
+		}
+		return stocks[i].locationID < stocks[j].locationID
+	})
+}
+
+// allocateStock takes qty from each of the ranked stocks in turn until
+// the quantity is met returning the quantity taken from each location.
+func allocateStock(stocks []*locationStock, qty int) []*allocation {
+	var allocs []*allocation
+	for _, v := range stocks {
+		if qty == 0 {
+			break
+		}
+		take := v.onhand
+		if take > qty {
+			take = qty
+		}
+		if take <= 0 {
+			continue
+		}
+		v.onhand -= take
+		qty -= take
+		allocs = append(allocs, &allocation{locationID: v.locationID, qty: take})
+	}
+	return allocs
+}
+
+// calcReservations returns the quantity of each line to take from stock
+// keyed by product id. Products without a stock level are not tracked
+// and nothing is taken. Stock owed to open backorders is not available
+// to new orders. Products that allow overselling or backorders
+// take whatever is left in stock and the rest is backordered up to the
+// backorder limit. The SKUs of lines that cannot be met are returned
+// sorted.
+func calcReservations(levels map[int]*stockLevel, lines []*PricingLine) (map[int]int, []string) {
+	reserved := make(map[int]int)
+	backordered := make(map[int]int)
+	var short []string
+	for _, l := range lines {
+		v, ok := levels[l.productID]
+		if !ok {
+			continue
+		}
+		available := v.onhand - v.backordered - reserved[l.productID]
+		if available < 0 {
+			available = 0
+		}
+		if l.Qty <= available {
+			reserved[l.productID] += l.Qty
+			continue
+		}
+		backorders := v.backorder == BackorderAllowed || v.backorder == BackorderPreorder
+		if !v.overselling && !backorders {
+			short = append(short, l.SKU)
+			continue
+		}
+		if backorders && v.backorderLimit != nil &&
+			v.backordered+backordered[l.productID]+l.Qty-available > *v.backorderLimit {
+			short = append(short, l.SKU)
+			continue
+		}
+		reserved[l.productID] += available
+		backordered[l.productID] += l.Qty - available
+	}
+	sort.Strings(short)
+	return reserved, short
+}
+
+// reserveStock locks the inventory of the products of an order and takes
+// the quantity ordered from stock. Stock is taken from the locations in
+// the order given by the allocation strategy and the quantity taken from
+// each location is recorded against each order item so it can be
+// released later. The quantity of each order item not taken from stock
+// is recorded as backordered and returned keyed by SKU. Returns an
+// InsufficientStockError if any product is short of stock and does not
+// allow overselling or backorders. Any stock alerts raised are returned.
+func reserveStock(ctx context.Context, tx *sql.Tx, orderID int, lines []*PricingLine, strategy, countryCode string) (map[string]int, []*StockAlertRow, error) {
+	productIDs := make([]int64, 0, len(lines))
+	for _, l := range lines {
+		productIDs = append(productIDs, int64(l.productID))
+	}
+
+	// 1. Lock the inventory rows in a consistent order.
+	q1 := `
+		SELECT id, product_id, overselling, backorder, backorder_limit
+		FROM inventory
+		WHERE product_id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`
+	rows1, err := tx.QueryContext(ctx, q1, pq.Array(productIDs))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows1.Close()
+
+	levels := make(map[int]*stockLevel)
+	inventoryProducts := make(map[int]int)
+	for rows1.Next() {
+		var productID int
+		var v stockLevel
+		if err := rows1.Scan(&v.inventoryID, &productID, &v.overselling, &v.backorder, &v.backorderLimit); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		levels[productID] = &v
+		inventoryProducts[v.inventoryID] = productID
+	}
+	if err := rows1.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	// 2. Get the quantity already backordered by open orders.
+	q2 := `
+		SELECT b.product_id, SUM(b.qty)
+		FROM (` + outstandingBackorders + `) AS b
+		WHERE b.product_id = ANY($1)
+		GROUP BY b.product_id
+	`
+	rows2, err := tx.QueryContext(ctx, q2, pq.Array(productIDs))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q2=%q) failed", q2)
+	}
+	defer rows2.Close()
+
+	for rows2.Next() {
+		var productID, qty int
+		if err := rows2.Scan(&productID, &qty); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		if v, ok := levels[productID]; ok {
+			v.backordered = qty
+		}
+	}
+	if err := rows2.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	// 3. Get the stock held at each active location.
+	q3 := `
+		SELECT il.inventory_id, l.id, l.country_code, l.priority, il.onhand
+		FROM inventory_location AS il
+		INNER JOIN location AS l
+		  ON l.id = il.location_id
+		INNER JOIN inventory AS v
+		  ON v.id = il.inventory_id
+		WHERE v.product_id = ANY($1) AND l.active AND il.onhand > 0
+	`
+	rows3, err := tx.QueryContext(ctx, q3, pq.Array(productIDs))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q3=%q) failed", q3)
+	}
+	defer rows3.Close()
+
+	for rows3.Next() {
+		var inventoryID int
+		var ls locationStock
+		if err := rows3.Scan(&inventoryID, &ls.locationID, &ls.countryCode, &ls.priority, &ls.onhand); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		v := levels[inventoryProducts[inventoryID]]
+		v.onhand += ls.onhand
+		v.locations = append(v.locations, &ls)
+	}
+	if err := rows3.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	reserved, short := calcReservations(levels, lines)
+	if len(short) > 0 {
+		return nil, nil, &InsufficientStockError{SKUs: short}
+	}
+
+	// 4. Take the stock from each location and record it against the
+	// order items along with the quantity backordered.
+	q4 := `
+		INSERT INTO order_item_allocation (order_item_id, location_id, qty, created)
+		SELECT oi.id, $3, $4, NOW()
+		FROM order_item AS oi
+		INNER JOIN product AS p
+		  ON p.sku = oi.sku
+		WHERE oi.order_id = $1 AND p.id = $2
+	`
+	q5 := `
+		UPDATE order_item AS oi
+		SET reserved = $3, backordered = oi.qty - $3
+		FROM product AS p
+		WHERE oi.order_id = $1 AND p.id = $2 AND oi.sku = p.sku
+	`
+	changes := newStockChanges()
+	backordered := make(map[string]int)
+	for _, l := range lines {
+		v, ok := levels[l.productID]
+		if !ok {
+			continue
+		}
+		qty := reserved[l.productID]
+		rankLocations(v.locations, strategy, countryCode)
+		for _, a := range allocateStock(v.locations, qty) {
+			if _, err := moveStock(ctx, tx, changes, v.inventoryID, a.locationID, -a.qty, "order", &orderID, nil, nil); err != nil {
+				return nil, nil, err
+			}
+			if _, err := tx.ExecContext(ctx, q4, orderID, l.productID, a.locationID, a.qty); err != nil {
+				return nil, nil, errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q4=%q, orderID=%d, ...) failed", q4, orderID)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, q5, orderID, l.productID, qty); err != nil {
+			return nil, nil, errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q5=%q, orderID=%d, ...) failed", q5, orderID)
+		}
+		if qty < l.Qty {
+			backordered[l.SKU] = l.Qty - qty
+		}
+	}
+	alerts, err := changes.alerts(ctx, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return backordered, alerts, nil
+}
+
+// backorderedToShip returns how many of the shipping units of an order
+// item must be taken from stock given the quantity ordered, backordered
+// and already shipped. Units held in stock ship first and the rest come
+// out of the quantity still backordered.
+func backorderedToShip(qty, backordered, shipped, shipping int) int {
+	outstanding := qty - shipped
+	if outstanding > backordered {
+		outstanding = backordered
+	}
+	if outstanding <= 0 {
+		return 0
+	}
+	n := shipping - (qty - shipped - outstanding)
+	if n < 0 {
+		return 0
+	}
+	if n > outstanding {
+		return outstanding
+	}
+	return n
+}
+
+// takeBackorderedStock takes the backordered units of the order items
+// about to ship from stock. shipping holds the quantity about to ship
+// keyed by order item id and must be called before the shipment items
+// are added. Stock is taken from the locations in priority order and the
+// quantity taken moves from backordered to reserved against each order
+// item. Oversold units with no stock left to take are shipped without
+// changing stock.
+func takeBackorderedStock(ctx context.Context, tx *sql.Tx, orderID int, shipping map[int]int) error {
+	q1 := `
+		SELECT oi.id, oi.qty, oi.backordered, v.id, COALESCE(s.qty, 0)
+		FROM order_item AS oi
+		INNER JOIN product AS p
+		  ON p.sku = oi.sku
+		INNER JOIN inventory AS v
+		  ON v.product_id = p.id
+		LEFT OUTER JOIN (
+		  SELECT order_item_id, SUM(qty) AS qty
+		  FROM shipment_item
+		  GROUP BY order_item_id
+		) AS s
+		  ON s.order_item_id = oi.id
+		WHERE oi.order_id = $1 AND oi.backordered > 0
+		ORDER BY v.id
+	`
+	rows, err := tx.QueryContext(ctx, q1, orderID)
+	if err != nil {
+		return errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q1=%q, orderID=%d) failed", q1, orderID)
+	}
+	defer rows.Close()
+
+	type take struct {
+		orderItemID int
+		inventoryID int
+		qty         int
+	}
+	var takes []*take
+	for rows.Next() {
+		var t take
+		var qty, backordered, shipped int
+		if err := rows.Scan(&t.orderItemID, &qty, &backordered, &t.inventoryID, &shipped); err != nil {
+			return errors.Wrap(err, "postgres: scan failed")
+		}
+		t.qty = backorderedToShip(qty, backordered, shipped, shipping[t.orderItemID])
+		if t.qty > 0 {
+			takes = append(takes, &t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	q2 := `SELECT id FROM inventory WHERE id = $1 FOR UPDATE`
+	q3 := `
+		SELECT l.id, l.country_code, l.priority, il.onhand
+		FROM inventory_location AS il
+		INNER JOIN location AS l
+		  ON l.id = il.location_id
+		WHERE il.inventory_id = $1 AND l.active AND il.onhand > 0
+	`
+	q4 := `
+		INSERT INTO order_item_allocation (order_item_id, location_id, qty, created)
+		VALUES ($1, $2, $3, NOW())
+	`
+	q5 := `
+		UPDATE order_item
+		SET reserved = reserved + $2, backordered = backordered - $2
+		WHERE id = $1
+	`
+	for _, t := range takes {
+		var inventoryID int
+		if err := tx.QueryRowContext(ctx, q2, t.inventoryID).Scan(&inventoryID); err != nil {
+			return errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+		}
+		locations, err := queryLocationStock(ctx, tx, q3, t.inventoryID)
+		if err != nil {
+			return err
+		}
+		rankLocations(locations, AllocationPriority, "")
+		for _, a := range allocateStock(locations, t.qty) {
+			if _, err := moveStock(ctx, tx, nil, t.inventoryID, a.locationID, -a.qty, "order", &orderID, nil, nil); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, q4, t.orderItemID, a.locationID, a.qty); err != nil {
+				return errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q4=%q, orderItemID=%d, ...) failed", q4, t.orderItemID)
+			}
+			if _, err := tx.ExecContext(ctx, q5, t.orderItemID, a.qty); err != nil {
+				return errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q5=%q, orderItemID=%d, ...) failed", q5, t.orderItemID)
+			}
+		}
+	}
+	return nil
+}
+
+func queryLocationStock(ctx context.Context, tx *sql.Tx, q string, inventoryID int) ([]*locationStock, error) {
+	rows, err := tx.QueryContext(ctx, q, inventoryID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q=%q, inventoryID=%d) failed", q, inventoryID)
+	}
+	defer rows.Close()
+
+	var locations []*locationStock
+	for rows.Next() {
+		var ls locationStock
+		if err := rows.Scan(&ls.locationID, &ls.countryCode, &ls.priority, &ls.onhand); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		locations = append(locations, &ls)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return locations, nil
+}
+
+// releaseOrderStock returns the stock held by each order item of an
+// order to the locations it was taken from.
+func releaseOrderStock(ctx context.Context, tx *sql.Tx, orderID int) error {
+	q1 := `
+		SELECT a.id, oi.id, v.id, a.location_id, a.qty
+		FROM order_item_allocation AS a
+		INNER JOIN order_item AS oi
+		  ON oi.id = a.order_item_id
+		INNER JOIN product AS p
+		  ON p.sku = oi.sku
+		INNER JOIN inventory AS v
+		  ON v.product_id = p.id
+		WHERE oi.order_id = $1 AND a.qty > 0
+		ORDER BY a.id
+		FOR UPDATE OF a, oi
+	`
+	restocks, err := queryRestocks(ctx, tx, q1, orderID)
+	if err != nil {
+		return err
+	}
+	return applyRestocks(ctx, tx, restocks, "order", orderID)
+}
+
+// restockRefund returns the items of a refund to stock. Order items keep
+// the stock taken for them as reserved once shipped so returned goods can
+// be restocked. No more than the reserved quantity of each order item is
+// returned so stock already returned by an earlier refund or released
+// when the order was cancelled is not returned twice, and units that
+// were backordered and shipped without stock are not returned. Stock is
+// returned to the locations it was taken from last first.
+func restockRefund(ctx context.Context, tx *sql.Tx, refundID, orderID int) error {
+	q1 := `
+		SELECT oi.id, LEAST(ri.qty, oi.reserved)
+		FROM refund_item AS ri
+		INNER JOIN order_item AS oi
+		  ON oi.id = ri.order_item_id
+		WHERE ri.refund_id = $1 AND oi.reserved > 0
+		FOR UPDATE OF oi
+	`
+	rows, err := tx.QueryContext(ctx, q1, refundID)
+	if err != nil {
+		return errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q1=%q, refundID=%d) failed", q1, refundID)
+	}
+	defer rows.Close()
+
+	qtys := make(map[int]int)
+	var orderItemIDs []int
+	for rows.Next() {
+		var orderItemID, qty int
+		if err := rows.Scan(&orderItemID, &qty); err != nil {
+			return errors.Wrap(err, "postgres: scan failed")
+		}
+		qtys[orderItemID] = qty
+		orderItemIDs = append(orderItemIDs, orderItemID)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	q2 := `
+		SELECT a.id, oi.id, v.id, a.location_id, a.qty
+		FROM order_item_allocation AS a
+		INNER JOIN order_item AS oi
+		  ON oi.id = a.order_item_id
+		INNER JOIN product AS p
+		  ON p.sku = oi.sku
+		INNER JOIN inventory AS v
+		  ON v.product_id = p.id
+		WHERE a.order_item_id = $1 AND a.qty > 0
+		ORDER BY a.id DESC
+		FOR UPDATE OF a
+	`
+	var restocks []*restock
+	for _, orderItemID := range orderItemIDs {
+		held, err := queryRestocks(ctx, tx, q2, orderItemID)
+		if err != nil {
+			return err
+		}
+		restocks = append(restocks, takeRestocks(held, qtys[orderItemID])...)
+	}
+	return applyRestocks(ctx, tx, restocks, "refund", orderID)
+}
+
+// restock holds the quantity of an order item to return to a location.
+type restock struct {
+	allocationID int
+	orderItemID  int
+	inventoryID  int
+	locationID   int
+	qty          int
+}
+
+// takeRestocks returns the restocks needed to return qty from the stock
+// held in turn.
+func takeRestocks(held []*restock, qty int) []*restock {
+	var restocks []*restock
+	for _, h := range held {
+		if qty == 0 {
+			break
+		}
+		r := *h
+		if r.qty > qty {
+			r.qty = qty
+		}
+		qty -= r.qty
+		restocks = append(restocks, &r)
+	}
+	return restocks
+}
+
+func queryRestocks(ctx context.Context, tx *sql.Tx, q string, id int) ([]*restock, error) {
+	rows, err := tx.QueryContext(ctx, q, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q=%q, id=%d) failed", q, id)
+	}
+	defer rows.Close()
+
+	var restocks []*restock
+	for rows.Next() {
+		var r restock
+		if err := rows.Scan(&r.allocationID, &r.orderItemID, &r.inventoryID, &r.locationID, &r.qty); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		restocks = append(restocks, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return restocks, nil
+}
+
+// applyRestocks returns each restock to its location recording the
+// movement against the order and reduces the stock held by the order
+// item.
+func applyRestocks(ctx context.Context, tx *sql.Tx, restocks []*restock, reason string, orderID int) error {
+	q1 := `UPDATE order_item SET reserved = reserved - $2 WHERE id = $1`
+	q2 := `UPDATE order_item_allocation SET qty = qty - $2 WHERE id = $1`
+	for _, r := range restocks {
+		if _, err := tx.ExecContext(ctx, q1, r.orderItemID, r.qty); err != nil {
+			return errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q1=%q, orderItemID=%d, ...) failed", q1, r.orderItemID)
+		}
+		if _, err := tx.ExecContext(ctx, q2, r.allocationID, r.qty); err != nil {
+			return errors.Wrapf(err, "postgres: tx.ExecContext(ctx, q2=%q, allocationID=%d, ...) failed", q2, r.allocationID)
+		}
+		if _, err := moveStock(ctx, tx, nil, r.inventoryID, r.locationID, r.qty, reason, &orderID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
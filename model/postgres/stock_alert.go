@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Stock alerts raised when the onhand of inventory crosses a threshold.
+const (
+	// StockAlertLowStock is raised when onhand drops to or below the
+	// reorder level.
+	StockAlertLowStock = "low_stock"
+
+	// StockAlertOutOfStock is raised when onhand drops to zero.
+	StockAlertOutOfStock = "out_of_stock"
+
+	// StockAlertRestocked is raised when onhand rises above the reorder
+	// level.
+	StockAlertRestocked = "restocked"
+)
+
+// StockAlertRow holds a stock alert raised for a single inventory.
+type StockAlertRow struct {
+	Alert         string
+	InventoryUUID string
+	ProductUUID   string
+	ProductSKU    string
+	Onhand        int
+	ReorderLevel  int
+}
+
+// stockAlert returns the alert raised when the onhand of inventory moves
+// from before to after or an empty string if no threshold is crossed.
+func stockAlert(before, after, reorderLevel int) string {
+	switch {
+	case after <= 0 && before > 0:
+		return StockAlertOutOfStock
+	case after <= reorderLevel && before > reorderLevel:
+		return StockAlertLowStock
+	case after > reorderLevel && before <= reorderLevel:
+		return StockAlertRestocked
+	}
+	return ""
+}
+
+// stockChanges collects the onhand of each inventory before and after
+// the stock moves made in a transaction.
+type stockChanges struct {
+	inventoryIDs []int
+	before       map[int]int
+	after        map[int]int
+}
+
+func newStockChanges() *stockChanges {
+	return &stockChanges{
+		before: make(map[int]int),
+		after:  make(map[int]int),
+	}
+}
+
+// record records a move of the onhand of inventory. The onhand before
+// the first move is kept.
+func (c *stockChanges) record(inventoryID, before, after int) {
+	if _, ok := c.before[inventoryID]; !ok {
+		c.inventoryIDs = append(c.inventoryIDs, inventoryID)
+		c.before[inventoryID] = before
+	}
+	c.after[inventoryID] = after
+}
+
+// alerts returns the stock alerts raised by the changes.
+func (c *stockChanges) alerts(ctx context.Context, tx *sql.Tx) ([]*StockAlertRow, error) {
+	var ids []int64
+	for _, id := range c.inventoryIDs {
+		if c.before[id] != c.after[id] {
+			ids = append(ids, int64(id))
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	q1 := `
+		SELECT v.id, v.uuid, p.uuid, p.sku, v.reorder_level
+		FROM inventory AS v
+		INNER JOIN product AS p
+		  ON p.id = v.product_id
+		WHERE v.id = ANY($1)
+	`
+	rows, err := tx.QueryContext(ctx, q1, pq.Array(ids))
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	alerts := make(map[int]*StockAlertRow)
+	for rows.Next() {
+		var inventoryID int
+		var a StockAlertRow
+		if err := rows.Scan(&inventoryID, &a.InventoryUUID, &a.ProductUUID, &a.ProductSKU, &a.ReorderLevel); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		a.Alert = stockAlert(c.before[inventoryID], c.after[inventoryID], a.ReorderLevel)
+		a.Onhand = c.after[inventoryID]
+		alerts[inventoryID] = &a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	var list []*StockAlertRow
+	for _, id := range c.inventoryIDs {
+		if a, ok := alerts[id]; ok && a.Alert != "" {
+			list = append(list, a)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStockAlert(t *testing.T) {
+	tests := []struct {
+		before, after, reorderLevel int
+		want                        string
+	}{
+		{10, 8, 5, ""},
+		{10, 5, 5, StockAlertLowStock},
+		{10, 0, 5, StockAlertOutOfStock},
+		{3, 0, 5, StockAlertOutOfStock},
+		{3, 2, 5, ""},
+		{3, 6, 5, StockAlertRestocked},
+		{0, 6, 5, StockAlertRestocked},
+		{0, 3, 5, ""},
+		{1, 0, 0, StockAlertOutOfStock},
+		{0, 1, 0, StockAlertRestocked},
+	}
+	for _, tt := range tests {
+		got := stockAlert(tt.before, tt.after, tt.reorderLevel)
+		assert.Equal(t, tt.want, got, "before=%d after=%d reorderLevel=%d", tt.before, tt.after, tt.reorderLevel)
+	}
+}
+
+func TestStockChangesRecord(t *testing.T) {
+	c := newStockChanges()
+	c.record(2, 10, 6)
+	c.record(1, 4, 3)
+	c.record(2, 6, 2)
+	assert.Equal(t, []int{2, 1}, c.inventoryIDs)
+	assert.Equal(t, map[int]int{2: 10, 1: 4}, c.before)
+	assert.Equal(t, map[int]int{2: 2, 1: 3}, c.after)
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalcReservations(t *testing.T) {
+	levels := map[int]*stockLevel{
+		1: {onhand: 5, overselling: false},
+		2: {onhand: 1, overselling: false},
+		3: {onhand: 2, overselling: true},
+	}
+	lines := []*PricingLine{
+		{productID: 1, SKU: "A", Qty: 5},
+		{productID: 3, SKU: "C", Qty: 4},
+		{productID: 4, SKU: "D", Qty: 9},
+	}
+	reserved, short := calcReservations(levels, lines)
+	assert.Empty(t, short)
+	assert.Equal(t, map[int]int{1: 5, 3: 2}, reserved)
+
+	lines = append(lines, &PricingLine{productID: 2, SKU: "B", Qty: 2})
+	_, short = calcReservations(levels, lines)
+	assert.Equal(t, []string{"B"}, short)
+}
+
+func TestRankLocations(t *testing.T) {
+	stocks := func() []*locationStock {
+		return []*locationStock{
+			{locationID: 1, countryCode: "GB", priority: 2, onhand: 5},
+			{locationID: 2, countryCode: "FR", priority: 1, onhand: 5},
+			{locationID: 3, countryCode: "GB", priority: 1, onhand: 5},
+		}
+	}
+	ids := func(s []*locationStock) []int {
+		var ids []int
+		for _, v := range s {
+			ids = append(ids, v.locationID)
+		}
+		return ids
+	}
+
+	s := stocks()
+	rankLocations(s, AllocationPriority, "FR")
+	assert.Equal(t, []int{2, 3, 1}, ids(s))
+
+	s = stocks()
+	rankLocations(s, AllocationNearest, "GB")
+	assert.Equal(t, []int{3, 1, 2}, ids(s))
+
+	s = stocks()
+	rankLocations(s, AllocationNearest, "DE")
+	assert.Equal(t, []int{2, 3, 1}, ids(s))
+}
+
+func TestAllocateStock(t *testing.T) {
+	stocks := []*locationStock{
+		{locationID: 1, onhand: 2},
+		{locationID: 2, onhand: 0},
+		{locationID: 3, onhand: 4},
+	}
+	allocs := allocateStock(stocks, 5)
+	assert.Equal(t, []*allocation{{locationID: 1, qty: 2}, {locationID: 3, qty: 3}}, allocs)
+	assert.Equal(t, 1, stocks[2].onhand)
+
+	// a second line for the same product takes what is left.
+	allocs = allocateStock(stocks, 3)
+	assert.Equal(t, []*allocation{{locationID: 3, qty: 1}}, allocs)
+}
+
+func TestTakeRestocks(t *testing.T) {
+	held := []*restock{
+		{allocationID: 2, locationID: 3, qty: 3},
+		{allocationID: 1, locationID: 1, qty: 2},
+	}
+	restocks := takeRestocks(held, 4)
+	assert.Equal(t, []*restock{
+		{allocationID: 2, locationID: 3, qty: 3},
+		{allocationID: 1, locationID: 1, qty: 1},
+	}, restocks)
+	assert.Equal(t, 2, held[1].qty)
+
+	assert.Empty(t, takeRestocks(held, 0))
+}
+
+func TestCalcReservationsBackorder(t *testing.T) {
+	limit := 3
+	levels := map[int]*stockLevel{
+		1: {onhand: 2, backorder: BackorderAllowed},
+		2: {onhand: 0, backorder: BackorderPreorder, backorderLimit: &limit, backordered: 1},
+	}
+	lines := []*PricingLine{
+		{productID: 1, SKU: "A", Qty: 5},
+		{productID: 2, SKU: "B", Qty: 2},
+	}
+	reserved, short := calcReservations(levels, lines)
+	assert.Empty(t, short)
+	assert.Equal(t, map[int]int{1: 2, 2: 0}, reserved)
+
+	lines[1].Qty = 3
+	_, short = calcReservations(levels, lines)
+	assert.Equal(t, []string{"B"}, short)
+
+	// stock owed to open backorders is not available to new orders.
+	levels = map[int]*stockLevel{
+		1: {onhand: 4, backorder: BackorderAllowed, backordered: 3},
+		2: {onhand: 2, backorder: BackorderNone, backordered: 2},
+	}
+	lines = []*PricingLine{{productID: 1, SKU: "A", Qty: 2}}
+	reserved, short = calcReservations(levels, lines)
+	assert.Empty(t, short)
+	assert.Equal(t, map[int]int{1: 1}, reserved)
+
+	lines = []*PricingLine{{productID: 2, SKU: "B", Qty: 1}}
+	_, short = calcReservations(levels, lines)
+	assert.Equal(t, []string{"B"}, short)
+}
+
+func TestBackorderedToShip(t *testing.T) {
+	// 2 reserved and 3 backordered ship reserved units first.
+	assert.Equal(t, 0, backorderedToShip(5, 3, 0, 2))
+	assert.Equal(t, 3, backorderedToShip(5, 3, 2, 3))
+	assert.Equal(t, 3, backorderedToShip(5, 3, 0, 5))
+	assert.Equal(t, 1, backorderedToShip(5, 3, 0, 3))
+	assert.Equal(t, 2, backorderedToShip(5, 3, 3, 2))
+
+	// nothing backordered or already shipped.
+	assert.Equal(t, 0, backorderedToShip(5, 0, 0, 5))
+	assert.Equal(t, 0, backorderedToShip(5, 3, 5, 0))
+}
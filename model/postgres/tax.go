@@ -0,0 +1,252 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTaxCode is used for products and shipping tariffs that have
+// no tax code set.
+const DefaultTaxCode = "T20"
+
+// DefaultTaxCountryCode is the country used to tax a cart when no
+// destination is known.
+const DefaultTaxCountryCode = "GB"
+
+// ErrTaxRateNotFound is returned when no tax rate is in effect for a
+// tax code and destination.
+var ErrTaxRateNotFound = errors.New("postgres: tax rate not found")
+
+// ErrTaxCodeNotFound is returned when a product is given a tax code that
+// has no tax rates.
+var ErrTaxCodeNotFound = errors.New("postgres: tax code not found")
+
+// TaxDestination holds where the goods are being delivered to. Region
+// is optional and is used to find a region specific rate before
+// falling back to the country rate.
+type TaxDestination struct {
+	CountryCode string
+	Region      *string
+}
+
+// TaxCalculator calculates the tax due on an amount for a tax code at
+// the given time. If incTax is true the amount already includes tax and
+// the tax contained in the amount is returned.
+type TaxCalculator interface {
+	CalcTax(ctx context.Context, dest *TaxDestination, taxCode string, amount int, incTax bool, at time.Time) (int, error)
+}
+
+// TaxRateRow maps to a row in the tax_rate table. Rate is held in basis
+// points so 2000 is 20.00%.
+type TaxRateRow struct {
+	id            int
+	UUID          string
+	TaxCode       string
+	CountryCode   string
+	Region        *string
+	Rate          int
+	EffectiveFrom time.Time
+	EffectiveTo   *time.Time
+	Created       time.Time
+	Modified      time.Time
+}
+
+// taxOnAmount returns the tax due on amount at the given rate. If
+// incTax is true the amount includes tax and the tax is extracted.
+func taxOnAmount(rate, amount int, incTax bool) int {
+	if incTax {
+		return int(math.Round(float64(amount) * float64(rate) / float64(10000+rate)))
+	}
+	return int(math.Round(float64(amount) * float64(rate) / 10000.0))
+}
+
+// queryerTaxCalculator is implemented by tax calculators that read from
+// the database so rates can be read inside the transaction pricing an
+// order.
+type queryerTaxCalculator interface {
+	withQueryer(q queryer) TaxCalculator
+}
+
+// taxCalculatorFor returns tc reading from q if tc reads from the
+// database.
+func taxCalculatorFor(tc TaxCalculator, q queryer) TaxCalculator {
+	if qtc, ok := tc.(queryerTaxCalculator); ok {
+		return qtc.withQueryer(q)
+	}
+	return tc
+}
+
+// rateTableTaxCalculator looks up tax rates from the tax_rate table.
+type rateTableTaxCalculator struct {
+	q queryer
+}
+
+// NewRateTableTaxCalculator returns a TaxCalculator that uses the rates
+// held in the tax_rate table.
+func NewRateTableTaxCalculator(db *sql.DB) TaxCalculator {
+	return &rateTableTaxCalculator{q: db}
+}
+
+func (c *rateTableTaxCalculator) withQueryer(q queryer) TaxCalculator {
+	return &rateTableTaxCalculator{q: q}
+}
+
+// CalcTax uses the rate in effect at the given time preferring a rate
+// for the destination region over the rate for the whole country.
+// Returns ErrTaxRateNotFound if no rate is in effect.
+func (c *rateTableTaxCalculator) CalcTax(ctx context.Context, dest *TaxDestination, taxCode string, amount int, incTax bool, at time.Time) (int, error) {
+	q1 := `
+		SELECT rate
+		FROM tax_rate
+		WHERE
+		  tax_code = $1 AND country_code = $2 AND
+		  (region IS NULL OR LOWER(region) = LOWER($3)) AND
+		  effective_from <= $4 AND (effective_to IS NULL OR effective_to > $4)
+		ORDER BY region IS NULL, effective_from DESC
+		LIMIT 1
+	`
+	var rate int
+	err := c.q.QueryRowContext(ctx, q1, taxCode, strings.ToUpper(dest.CountryCode), dest.Region, at).Scan(&rate)
+	if err == sql.ErrNoRows {
+		return 0, ErrTaxRateNotFound
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return taxOnAmount(rate, amount, incTax), nil
+}
+
+// checkTaxCode returns ErrTaxCodeNotFound if the tax code has no tax
+// rates.
+func checkTaxCode(ctx context.Context, q queryer, taxCode string) error {
+	q1 := "SELECT EXISTS(SELECT 1 FROM tax_rate WHERE tax_code = $1) AS exists"
+	var exists bool
+	if err := q.QueryRowContext(ctx, q1, taxCode).Scan(&exists); err != nil {
+		return errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	if !exists {
+		return ErrTaxCodeNotFound
+	}
+	return nil
+}
+
+// SetTaxCalculator replaces the tax calculator used to price carts and
+// orders.
+func (m *PgModel) SetTaxCalculator(tc TaxCalculator) {
+	m.tax = tc
+}
+
+// CreateTaxRate adds a new tax rate.
+func (m *PgModel) CreateTaxRate(ctx context.Context, taxCode, countryCode string, region *string, rate int, effectiveFrom time.Time, effectiveTo *time.Time) (*TaxRateRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: CreateTaxRate(ctx, taxCode=%q, countryCode=%q, region=%v, rate=%d, ...) started", taxCode, countryCode, region, rate)
+
+	q1 := `
+		INSERT INTO tax_rate
+		  (tax_code, country_code, region, rate, effective_from, effective_to, created, modified)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING
+		  id, uuid, tax_code, country_code, region, rate,
+		  effective_from, effective_to, created, modified
+	`
+	t := TaxRateRow{}
+	row := m.db.QueryRowContext(ctx, q1, taxCode, strings.ToUpper(countryCode), region, rate, effectiveFrom, effectiveTo)
+	if err := row.Scan(&t.id, &t.UUID, &t.TaxCode, &t.CountryCode, &t.Region, &t.Rate,
+		&t.EffectiveFrom, &t.EffectiveTo, &t.Created, &t.Modified); err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return &t, nil
+}
+
+// GetTaxRateByUUID returns a single tax rate.
+func (m *PgModel) GetTaxRateByUUID(ctx context.Context, taxRateUUID string) (*TaxRateRow, error) {
+	q1 := `
+		SELECT
+		  id, uuid, tax_code, country_code, region, rate,
+		  effective_from, effective_to, created, modified
+		FROM tax_rate
+		WHERE uuid = $1
+	`
+	t := TaxRateRow{}
+	err := m.db.QueryRowContext(ctx, q1, taxRateUUID).Scan(&t.id, &t.UUID, &t.TaxCode, &t.CountryCode,
+		&t.Region, &t.Rate, &t.EffectiveFrom, &t.EffectiveTo, &t.Created, &t.Modified)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaxRateNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: query row context taxRateUUID=%q q1=%q", taxRateUUID, q1)
+	}
+	return &t, nil
+}
+
+// GetTaxRates returns a page of tax rates ordered by tax code by
+// default.
+func (m *PgModel) GetTaxRates(ctx context.Context, opts *ListOptions) ([]*TaxRateRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  id, uuid, tax_code, country_code, region, rate,
+		  effective_from, effective_to, created, modified`,
+		from:  "tax_rate AS t",
+		alias: "t",
+		fields: map[string]string{
+			"tax_code":       "t.tax_code",
+			"country_code":   "t.country_code",
+			"rate":           "t.rate",
+			"effective_from": "t.effective_from",
+			"created":        "t.created",
+			"modified":       "t.modified",
+		},
+		orderBy:  "t.tax_code",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	rates := make([]*TaxRateRow, 0, 8)
+	for rows.Next() {
+		var t TaxRateRow
+		if err := rows.Scan(&t.id, &t.UUID, &t.TaxCode, &t.CountryCode, &t.Region, &t.Rate,
+			&t.EffectiveFrom, &t.EffectiveTo, &t.Created, &t.Modified); err != nil {
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		rates = append(rates, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return rates, opts.page(&rates), nil
+}
+
+// DeleteTaxRateByUUID deletes a tax rate.
+func (m *PgModel) DeleteTaxRateByUUID(ctx context.Context, taxRateUUID string) error {
+	q1 := "DELETE FROM tax_rate WHERE uuid = $1"
+	res, err := m.db.ExecContext(ctx, q1, taxRateUUID)
+	if err != nil {
+		return errors.Wrapf(err, "postgres: exec context q1=%q", q1)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "postgres: res.RowsAffected()")
+	}
+	if n == 0 {
+		return ErrTaxRateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package postgres
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaxCalculatorFor(t *testing.T) {
+	db := &sql.DB{}
+	tx := &sql.Tx{}
+
+	tc := taxCalculatorFor(NewRateTableTaxCalculator(db), tx)
+	assert.Equal(t, &rateTableTaxCalculator{q: tx}, tc)
+
+	// calculators that do not read from the database are used as is.
+	assert.Equal(t, ukVAT, taxCalculatorFor(ukVAT, tx))
+}
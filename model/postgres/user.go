@@ -3,7 +3,6 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"time"
 
 	"github.com/pkg/errors"
@@ -46,28 +45,6 @@ type UsrJoinRow struct {
 	Modified      time.Time
 }
 
-// PaginationResultSet contains both the underlying result set as well as
-// context about the data including Total; the total number of rows in
-// the table, First; set to true if this result set represents the first
-// page, Last; set to true if this result set represents the last page of
-// results.
-type PaginationResultSet struct {
-	RContext struct {
-		Total               int
-		FirstUUID, LastUUID string
-	}
-	RSet interface{}
-}
-
-// PaginationQuery for querying the database with paging.
-type PaginationQuery struct {
-	OrderBy    string
-	OrderDir   string
-	Limit      int
-	StartAfter string
-	EndBefore  string
-}
-
 // CreateUser creates a new user
 func (m *PgModel) CreateUser(ctx context.Context, uid, role, email, firstname, lastname string) (*UsrJoinRow, error) {
 	// 1. Look up the default price list
@@ -101,97 +78,51 @@ func (m *PgModel) CreateUser(ctx context.Context, uid, role, email, firstname, l
 	return &u, nil
 }
 
-// GetUsers gets the next size user starting at page page
-func (m *PgModel) GetUsers(ctx context.Context, pq *PaginationQuery) (*PaginationResultSet, error) {
-	q := NewQuery("usr", map[string]bool{
-		"id":            true,
-		"uuid":          false,
-		"uid":           false,
-		"price_list_id": false,
-		"role":          true,
-		"email":         true,
-		"firstname":     true,
-		"lastname":      true,
-		"created":       true,
-		"modified":      true,
-	})
-	q = q.Select([]string{
-		"id",
-		"uuid",
-		"uid",
-		"price_list_id",
-		"role",
-		"email",
-		"firstname",
-		"lastname",
-		"created",
-		"modified",
-	})
-
-	// if not set, default Order By, Order Direction and Limit is "created DESC LIMIT 10"
-	if pq.OrderBy != "" {
-		q = q.OrderBy(pq.OrderBy)
-	} else {
-		q = q.OrderBy("created")
-	}
-	if pq.OrderDir != "" {
-		q = q.OrderDir(OrderDirection(pq.OrderDir))
-	} else {
-		q = q.OrderDir("DESC")
-	}
-	q = q.Limit(pq.Limit)
-
-	if pq.StartAfter != "" {
-		q = q.StartAfter(pq.StartAfter)
-	}
-	if pq.EndBefore != "" {
-		q = q.EndBefore(pq.EndBefore)
-	}
-
-	// 1. calculate the total count, first and last items in the result set
-	pr := PaginationResultSet{}
-	q1 := "SELECT COUNT(*) AS count FROM %s"
-	q1 = fmt.Sprintf(q1, q.table)
-	err := m.db.QueryRowContext(ctx, q1).Scan(&pr.RContext.Total)
-	if err != nil {
-		return nil, errors.Wrapf(err, "query row context scan q1=%q", q1)
-	}
-
-	// 2. book mark either end of the result set
-	q2 := "SELECT uuid FROM %s ORDER BY %s %s, id %s FETCH FIRST 1 ROW ONLY"
-	q2 = fmt.Sprintf(q2, q.table, q.orderBy, string(q.orderDir), string(q.orderDir))
-	err = m.db.QueryRowContext(ctx, q2).Scan(&pr.RContext.FirstUUID)
+// GetUsers returns a page of users.
+func (m *PgModel) GetUsers(ctx context.Context, opts *ListOptions) ([]*UsrJoinRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  u.id, u.uuid, u.uid, u.price_list_id, l.uuid, u.role, u.email,
+		  u.firstname, u.lastname, u.created, u.modified`,
+		from:  "usr AS u INNER JOIN price_list AS l ON l.id = u.price_list_id",
+		alias: "u",
+		fields: map[string]string{
+			"role":      "u.role",
+			"email":     "u.email",
+			"firstname": "u.firstname",
+			"lastname":  "u.lastname",
+			"created":   "u.created",
+			"modified":  "u.modified",
+		},
+		orderBy:  "u.created",
+		orderDir: OrderDesc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: query row context q2=%q", q2)
+		return nil, nil, err
 	}
-	q3 := "SELECT uuid FROM %s ORDER BY %s %s, id %s FETCH FIRST 1 ROW ONLY"
-	q3 = fmt.Sprintf(q3, q.table, q.orderBy, string(q.orderDir.toggle()), string(q.orderDir.toggle()))
-	err = m.db.QueryRowContext(ctx, q3).Scan(&pr.RContext.LastUUID)
+	rows, err := m.db.QueryContext(ctx, q1, args...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: query row context q3=%q", q3)
-	}
-
-	rows, err := m.QueryContextQ(ctx, q)
-	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: model query context q=%v", q)
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
 	}
 	defer rows.Close()
 
-	usrs := make([]*UsrJoinRow, 0)
+	usrs := make([]*UsrJoinRow, 0, 16)
 	for rows.Next() {
 		var u UsrJoinRow
-		if err = rows.Scan(&u.id, &u.UUID, &u.UID, &u.priceListID, &u.Role, &u.Email,
-			&u.Firstname, &u.Lastname, &u.Created, &u.Modified); err != nil {
-			return nil, errors.Wrapf(err, "postgres: rows scan User=%v", u)
+		if err = rows.Scan(&u.id, &u.UUID, &u.UID, &u.priceListID, &u.PriceListUUID, &u.Role,
+			&u.Email, &u.Firstname, &u.Lastname, &u.Created, &u.Modified); err != nil {
+			return nil, nil, errors.Wrapf(err, "postgres: rows scan User=%v", u)
 		}
 		usrs = append(usrs, &u)
 	}
-
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows err")
+		return nil, nil, errors.Wrap(err, "postgres: rows err")
 	}
-	pr.RSet = usrs
-	return &pr, nil
+	return usrs, opts.page(&usrs), nil
 }
 
 // GetUserByUUID gets a user by user UUID
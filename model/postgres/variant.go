@@ -0,0 +1,333 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrVariantNotFound is returned when no variant of a product has the
+// requested options.
+var ErrVariantNotFound = errors.New("postgres: variant not found")
+
+// ErrVariantOptionsExist is returned when creating a variant with the
+// same options as an existing variant of the same product.
+var ErrVariantOptionsExist = errors.New("postgres: variant options exist")
+
+// ErrProductIsVariant is returned when attempting to create a variant
+// of a product that is itself a variant.
+var ErrProductIsVariant = errors.New("postgres: product is a variant")
+
+// VariantRow holds a product row of a variant with the values of each
+// of its options keyed by option type code.
+type VariantRow struct {
+	ProductRow
+	Options map[string]string
+}
+
+// sameOptions returns true if a and b hold the same option values.
+func sameOptions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// matchVariant returns the variant with the given options or nil if
+// none match.
+func matchVariant(variants []*VariantRow, options map[string]string) *VariantRow {
+	for _, v := range variants {
+		if sameOptions(v.Options, options) {
+			return v
+		}
+	}
+	return nil
+}
+
+// productIDByUUID returns the id and parent id of the product with
+// the given uuid.
+func productIDByUUID(ctx context.Context, q queryer, productUUID string) (int, *int, error) {
+	q1 := "SELECT id, parent_id FROM product WHERE uuid = $1"
+	var productID int
+	var parentID *int
+	err := q.QueryRowContext(ctx, q1, productUUID).Scan(&productID, &parentID)
+	if err == sql.ErrNoRows {
+		return 0, nil, ErrProductNotFound
+	}
+	if err != nil {
+		return 0, nil, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return productID, parentID, nil
+}
+
+// getVariants returns the variants of the parent product with their
+// options ordered by id.
+func getVariants(ctx context.Context, q queryer, parentID int, parentUUID string) ([]*VariantRow, error) {
+	q1 := `
+		SELECT
+		  id, parent_id, uuid, sku, path, name, tax_code, description, attributes,
+		  meta_title, meta_description, status, publish_at, unpublish_at, created, modified
+		FROM product
+		WHERE parent_id = $1
+		ORDER BY id
+	`
+	rows, err := q.QueryContext(ctx, q1, parentID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: q.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	variants := make([]*VariantRow, 0, 8)
+	byID := make(map[int]*VariantRow)
+	for rows.Next() {
+		v := VariantRow{Options: make(map[string]string)}
+		if err := rows.Scan(&v.id, &v.parentID, &v.UUID, &v.SKU, &v.Path, &v.Name,
+			&v.TaxCode, &v.Description, &v.Attributes, &v.MetaTitle, &v.MetaDescription,
+			&v.Status, &v.PublishAt, &v.UnpublishAt, &v.Created, &v.Modified); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		v.ParentUUID = &parentUUID
+		variants = append(variants, &v)
+		byID[v.id] = &v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+
+	q2 := `
+		SELECT po.product_id, o.code, po.value
+		FROM product_option AS po
+		INNER JOIN option_type AS o
+		  ON o.id = po.option_type_id
+		INNER JOIN product AS p
+		  ON p.id = po.product_id
+		WHERE p.parent_id = $1
+	`
+	rows2, err := q.QueryContext(ctx, q2, parentID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: q.QueryContext(ctx, q2=%q) failed", q2)
+	}
+	defer rows2.Close()
+
+	for rows2.Next() {
+		var productID int
+		var code, value string
+		if err := rows2.Scan(&productID, &code, &value); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		if v, ok := byID[productID]; ok {
+			v.Options[code] = value
+		}
+	}
+	if err := rows2.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows2.Err()")
+	}
+	return variants, nil
+}
+
+// CreateVariant creates a new product as a variant of the parent
+// product. Each variant has its own price, inventory and images.
+// options holds the value of each option type keyed by option type
+// code. Returns ErrProductNotFound if the parent does not exist,
+// ErrProductIsVariant if the parent is a variant, ErrOptionTypeNotFound
+// if any of the option type codes do not exist and
+// ErrVariantOptionsExist if another variant has the same options.
+func (m *PgModel) CreateVariant(ctx context.Context, userUUID, parentUUID, path, sku, name, taxCode string, content *ProductContent, options map[string]string) (*VariantRow, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("postgres: CreateVariant(ctx, userUUID=%s, parentUUID=%s, path=%s, sku=%s, name=%q, taxCode=%q, options=%v) started", userUUID, parentUUID, path, sku, name, taxCode, options)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "postgres: db.BeginTx failed")
+	}
+
+	// 1. Check the parent exists and is not a variant.
+	parentID, grandParentID, err := productIDByUUID(ctx, tx, parentUUID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if grandParentID != nil {
+		tx.Rollback()
+		return nil, ErrProductIsVariant
+	}
+
+	v, err := createVariant(ctx, tx, userUUID, parentID, parentUUID, path, sku, name, taxCode, content, options)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "postgres: tx.Commit failed")
+	}
+	return v, nil
+}
+
+// createVariant creates a variant of the product with the given
+// parentID and parentUUID that must not itself be a variant. The caller
+// is responsible for rolling back tx on error.
+func createVariant(ctx context.Context, tx *sql.Tx, userUUID string, parentID int, parentUUID, path, sku, name, taxCode string, content *ProductContent, options map[string]string) (*VariantRow, error) {
+	// 1. Resolve the option type ids by code.
+	codes := make([]string, 0, len(options))
+	for code := range options {
+		codes = append(codes, code)
+	}
+	q1 := "SELECT id, code FROM option_type WHERE code = ANY($1)"
+	rows, err := tx.QueryContext(ctx, q1, pq.Array(codes))
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: tx.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	optionTypeIDs := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var code string
+		if err := rows.Scan(&id, &code); err != nil {
+			rows.Close()
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		optionTypeIDs[code] = id
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	rows.Close()
+	if len(optionTypeIDs) != len(options) {
+		return nil, ErrOptionTypeNotFound
+	}
+
+	// 2. Check no other variant has the same options.
+	variants, err := getVariants(ctx, tx, parentID, parentUUID)
+	if err != nil {
+		return nil, err
+	}
+	if matchVariant(variants, options) != nil {
+		return nil, ErrVariantOptionsExist
+	}
+
+	// 3. Create the variant product with its price and inventory.
+	p, err := createProduct(ctx, tx, userUUID, &parentID, path, sku, name, taxCode, content)
+	if err != nil {
+		return nil, err
+	}
+	p.ParentUUID = &parentUUID
+
+	// 4. Set the options of the variant.
+	q2 := `
+		INSERT INTO product_option (product_id, option_type_id, value, created)
+		VALUES ($1, $2, $3, NOW())
+	`
+	for code, value := range options {
+		if _, err := tx.ExecContext(ctx, q2, p.id, optionTypeIDs[code], value); err != nil {
+			return nil, errors.Wrapf(err, "postgres: exec context q2=%q", q2)
+		}
+	}
+	return &VariantRow{ProductRow: *p, Options: options}, nil
+}
+
+// GetVariants returns the variants of the product with the given
+// uuid. Returns ErrProductNotFound if the product does not exist.
+func (m *PgModel) GetVariants(ctx context.Context, productUUID string) ([]*VariantRow, error) {
+	productID, _, err := productIDByUUID(ctx, m.db, productUUID)
+	if err != nil {
+		return nil, err
+	}
+	return getVariants(ctx, m.db, productID, productUUID)
+}
+
+// GetVariantByOptions returns the variant of the product with the given
+// options. Returns ErrProductNotFound if the product does not exist or
+// ErrVariantNotFound if no variant has the options.
+func (m *PgModel) GetVariantByOptions(ctx context.Context, productUUID string, options map[string]string) (*VariantRow, error) {
+	variants, err := m.GetVariants(ctx, productUUID)
+	if err != nil {
+		return nil, err
+	}
+	v := matchVariant(variants, options)
+	if v == nil {
+		return nil, ErrVariantNotFound
+	}
+	return v, nil
+}
+
+// HasVariants returns true if the product with the given uuid has one
+// or more variants.
+func (m *PgModel) HasVariants(ctx context.Context, productUUID string) (bool, error) {
+	q1 := `
+		SELECT EXISTS(
+		  SELECT 1 FROM product AS v
+		  INNER JOIN product AS p
+		    ON p.id = v.parent_id
+		  WHERE p.uuid = $1
+		) AS exists
+	`
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, q1, productUUID).Scan(&exists); err != nil {
+		return false, errors.Wrapf(err, "postgres: query row context q1=%q", q1)
+	}
+	return exists, nil
+}
+
+// GetProductOptions returns the option values of a variant keyed by
+// option type code. Products that are not variants have no options.
+func (m *PgModel) GetProductOptions(ctx context.Context, productUUID string) (map[string]string, error) {
+	q1 := `
+		SELECT o.code, po.value
+		FROM product_option AS po
+		INNER JOIN option_type AS o
+		  ON o.id = po.option_type_id
+		INNER JOIN product AS p
+		  ON p.id = po.product_id
+		WHERE p.uuid = $1
+	`
+	rows, err := m.db.QueryContext(ctx, q1, productUUID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	options := make(map[string]string)
+	for rows.Next() {
+		var code, value string
+		if err := rows.Scan(&code, &value); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		options[code] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return options, nil
+}
+
+// variantIDsByParent returns the ids of the variants of each of the
+// given parent products keyed by parent id.
+func variantIDsByParent(ctx context.Context, q queryer, parentIDs []int) (map[int][]int, error) {
+	q1 := "SELECT id, parent_id FROM product WHERE parent_id = ANY($1)"
+	rows, err := q.QueryContext(ctx, q1, pq.Array(parentIDs))
+	if err != nil {
+		return nil, errors.Wrapf(err, "postgres: q.QueryContext(ctx, q1=%q) failed", q1)
+	}
+	defer rows.Close()
+
+	variants := make(map[int][]int)
+	for rows.Next() {
+		var id, parentID int
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, errors.Wrap(err, "postgres: scan failed")
+		}
+		variants[parentID] = append(variants[parentID], id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "postgres: rows.Err()")
+	}
+	return variants, nil
+}
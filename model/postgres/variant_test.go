@@ -0,0 +1,21 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchVariant(t *testing.T) {
+	variants := []*VariantRow{
+		{ProductRow: ProductRow{UUID: "a"}, Options: map[string]string{"size": "M", "colour": "red"}},
+		{ProductRow: ProductRow{UUID: "b"}, Options: map[string]string{"size": "XL", "colour": "red"}},
+	}
+	v := matchVariant(variants, map[string]string{"colour": "red", "size": "XL"})
+	if assert.NotNil(t, v) {
+		assert.Equal(t, "b", v.UUID)
+	}
+	assert.Nil(t, matchVariant(variants, map[string]string{"size": "XL"}))
+	assert.Nil(t, matchVariant(variants, map[string]string{"size": "XL", "colour": "blue"}))
+	assert.Nil(t, matchVariant(variants, map[string]string{"size": "M", "colour": "red", "fit": "slim"}))
+}
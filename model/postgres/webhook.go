@@ -85,12 +85,32 @@ func (m *PgModel) GetWebhook(ctx context.Context, webhookUUID string) (*WebhookR
 	return &w, nil
 }
 
-// GetWebhooks retrieves all webhooks.
-func (m *PgModel) GetWebhooks(ctx context.Context) ([]*WebhookRow, error) {
-	q1 := "SELECT id, uuid, signing_key, url, events, enabled, created, modified FROM webhook"
-	rows, err := m.db.QueryContext(ctx, q1)
+// GetWebhooks retrieves a page of webhooks.
+func (m *PgModel) GetWebhooks(ctx context.Context, opts *ListOptions) ([]*WebhookRow, *ListContext, error) {
+	q := listQuery{
+		sel: `
+		  id, uuid, signing_key, url, events, enabled, created, modified`,
+		from:  "webhook AS w",
+		alias: "w",
+		fields: map[string]string{
+			"url":      "w.url",
+			"enabled":  "w.enabled",
+			"created":  "w.created",
+			"modified": "w.modified",
+		},
+		orderBy:  "w.created",
+		orderDir: OrderAsc,
+	}
+	if err := m.checkCursor(ctx, &q, opts); err != nil {
+		return nil, nil, err
+	}
+	q1, args, err := q.build(opts)
 	if err != nil {
-		return nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
+		return nil, nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, q1, args...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "postgres: m.db.QueryContext(ctx) failed")
 	}
 	defer rows.Close()
 
@@ -98,20 +118,20 @@ func (m *PgModel) GetWebhooks(ctx context.Context) ([]*WebhookRow, error) {
 	for rows.Next() {
 		var w WebhookRow
 		if err = rows.Scan(&w.id, &w.UUID, &w.SigningKey, &w.URL, pq.Array(&w.Events), &w.Enabled, &w.Created, &w.Modified); err != nil {
-			return nil, errors.Wrap(err, "postgres: scan failed")
+			return nil, nil, errors.Wrap(err, "postgres: scan failed")
 		}
 		webhooks = append(webhooks, &w)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "postgres: rows.Err()")
+		return nil, nil, errors.Wrap(err, "postgres: rows.Err()")
 	}
-	return webhooks, nil
+	return webhooks, opts.page(&webhooks), nil
 }
 
 // UpdateWebhook does a partial update to a row in the webhook table.
 func (m *PgModel) UpdateWebhook(ctx context.Context, webhookUUID string, url *string, events []string, enabled *bool) (*WebhookRow, error) {
 	contextLogger := log.WithContext(ctx)
-	contextLogger.Infof("postgres: UpdateWebhook(ctx, webhookUUID=%q, ...) started")
+	contextLogger.Infof("postgres: UpdateWebhook(ctx, webhookUUID=%q, ...) started", webhookUUID)
 
 	// 1. Check the webhook exists
 	q1 := "SELECT id FROM webhook WHERE uuid = $1"
@@ -0,0 +1,149 @@
+// Package manual implements an in-process payment.Provider that never
+// leaves the process. It is used to run checkout flows in CI and staging
+// without network access to a real payment provider.
+//
+// Checkouts and refunds always succeed and ids are derived from the
+// order and refund ids so the same requests give the same results.
+// Payments are completed by posting an event to the payment webhook:
+//
+//	{
+//	  "id": "evt_1",
+//	  "type": "payment.succeeded",
+//	  "order_id": "6f1a...",
+//	  "payment_intent_id": "manual_pi_6f1a..."
+//	}
+//
+// If the id is left out the event id is derived from the body so the
+// same body is only processed once.
+//
+// If a signing secret is set the request must include the
+// X-Ecom-Manual-Signature header holding the base64 encoded HMAC-SHA256
+// of the body. See Sign.
+package manual
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/payment"
+	"github.com/pkg/errors"
+)
+
+// SignatureHeader holds the signature of a webhook request body.
+const SignatureHeader = "X-Ecom-Manual-Signature"
+
+// Provider is an in-process payment provider.
+type Provider struct {
+	signingSecret string
+}
+
+// NewProvider returns a manual payment provider. If signingSecret is
+// empty webhook requests are not verified.
+func NewProvider(signingSecret string) *Provider {
+	return &Provider{signingSecret: signingSecret}
+}
+
+// Sign returns the signature of a webhook request body for the signing
+// secret.
+func Sign(signingSecret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(signingSecret))
+	h.Write(body)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Name returns manual.
+func (p *Provider) Name() string {
+	return "manual"
+}
+
+// CreateCheckout returns a checkout with ids derived from the order id.
+func (p *Provider) CreateCheckout(ctx context.Context, req *payment.CheckoutRequest) (*payment.Checkout, error) {
+	return &payment.Checkout{
+		ID:              "manual_cs_" + req.OrderID,
+		PaymentIntentID: "manual_pi_" + req.OrderID,
+	}, nil
+}
+
+type webhookRefund struct {
+	ID       string  `json:"id"`
+	RefundID *string `json:"refund_id"`
+	Amount   int     `json:"amount"`
+}
+
+type webhookDispute struct {
+	ID     string `json:"id"`
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type webhookEvent struct {
+	ID              string           `json:"id"`
+	Type            string           `json:"type"`
+	OrderID         string           `json:"order_id"`
+	PaymentIntentID string           `json:"payment_intent_id"`
+	Reason          string           `json:"reason"`
+	Refunds         []*webhookRefund `json:"refunds"`
+	Dispute         *webhookDispute  `json:"dispute"`
+}
+
+// VerifyWebhook checks the signature if a signing secret is set and
+// returns the event held in the body.
+func (p *Provider) VerifyWebhook(ctx context.Context, body []byte, header http.Header) (*payment.Event, error) {
+	if p.signingSecret != "" {
+		expected := Sign(p.signingSecret, body)
+		if !hmac.Equal([]byte(expected), []byte(header.Get(SignatureHeader))) {
+			return nil, payment.ErrWebhookSignatureInvalid
+		}
+	}
+
+	var we webhookEvent
+	if err := json.Unmarshal(body, &we); err != nil {
+		return nil, errors.Wrap(err, "manual: failed to parse webhook event")
+	}
+	e := payment.Event{
+		ID:              we.ID,
+		Type:            we.Type,
+		OrderID:         we.OrderID,
+		PaymentIntentID: we.PaymentIntentID,
+		Reason:          we.Reason,
+		Raw:             body,
+	}
+	if e.ID == "" {
+		sum := sha256.Sum256(body)
+		e.ID = "manual_evt_" + hex.EncodeToString(sum[:])
+	}
+	if we.Dispute != nil {
+		e.Dispute = &payment.DisputeEvent{
+			ID:     we.Dispute.ID,
+			Amount: we.Dispute.Amount,
+			Reason: we.Dispute.Reason,
+		}
+	}
+	for _, r := range we.Refunds {
+		e.Refunds = append(e.Refunds, &payment.RefundEvent{
+			ID:       r.ID,
+			RefundID: r.RefundID,
+			Amount:   r.Amount,
+			Status:   payment.RefundSucceeded,
+		})
+	}
+	return &e, nil
+}
+
+// Capture always succeeds.
+func (p *Provider) Capture(ctx context.Context, paymentIntentID string, amount int) error {
+	return nil
+}
+
+// Refund always succeeds returning an id derived from the refund id.
+func (p *Provider) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.Refund, error) {
+	return &payment.Refund{
+		ID:     "manual_re_" + req.RefundID,
+		Status: payment.RefundSucceeded,
+	}, nil
+}
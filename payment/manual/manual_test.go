@@ -0,0 +1,62 @@
+package manual
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/payment"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCheckout(t *testing.T) {
+	p := NewProvider("")
+	c, err := p.CreateCheckout(context.Background(), &payment.CheckoutRequest{OrderID: "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "manual_cs_abc", c.ID)
+	assert.Equal(t, "manual_pi_abc", c.PaymentIntentID)
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"type":"payment.succeeded","order_id":"abc","payment_intent_id":"manual_pi_abc"}`)
+	p := NewProvider("secret")
+
+	header := http.Header{}
+	_, err := p.VerifyWebhook(context.Background(), body, header)
+	assert.Equal(t, payment.ErrWebhookSignatureInvalid, err)
+
+	header.Set(SignatureHeader, Sign("secret", body))
+	e, err := p.VerifyWebhook(context.Background(), body, header)
+	assert.NoError(t, err)
+	assert.Equal(t, payment.EventPaymentSucceeded, e.Type)
+	assert.Equal(t, "abc", e.OrderID)
+	assert.Equal(t, "manual_pi_abc", e.PaymentIntentID)
+}
+
+func TestVerifyWebhookRefunded(t *testing.T) {
+	body := []byte(`{"type":"refunded","payment_intent_id":"manual_pi_abc","refunds":[{"id":"manual_re_1","amount":500}]}`)
+	e, err := NewProvider("").VerifyWebhook(context.Background(), body, http.Header{})
+	assert.NoError(t, err)
+	assert.Equal(t, payment.EventRefunded, e.Type)
+	assert.Len(t, e.Refunds, 1)
+	assert.Equal(t, 500, e.Refunds[0].Amount)
+	assert.Nil(t, e.Refunds[0].RefundID)
+}
+
+func TestVerifyWebhookEventID(t *testing.T) {
+	p := NewProvider("")
+	body := []byte(`{"type":"payment.failed","order_id":"abc","payment_intent_id":"manual_pi_abc","reason":"card declined"}`)
+	e1, err := p.VerifyWebhook(context.Background(), body, http.Header{})
+	assert.NoError(t, err)
+	e2, err := p.VerifyWebhook(context.Background(), body, http.Header{})
+	assert.NoError(t, err)
+	assert.Equal(t, e1.ID, e2.ID)
+	assert.Equal(t, "card declined", e1.Reason)
+
+	body = []byte(`{"id":"evt_1","type":"dispute.created","payment_intent_id":"manual_pi_abc","dispute":{"id":"dp_1","amount":1000,"reason":"fraudulent"}}`)
+	e, err := p.VerifyWebhook(context.Background(), body, http.Header{})
+	assert.NoError(t, err)
+	assert.Equal(t, "evt_1", e.ID)
+	assert.Equal(t, payment.EventDisputeCreated, e.Type)
+	assert.Equal(t, 1000, e.Dispute.Amount)
+}
@@ -0,0 +1,139 @@
+// Package payment defines the interface implemented by each payment
+// provider used to take and refund payments for orders.
+package payment
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWebhookSignatureInvalid is returned when a webhook request cannot be
+// verified as coming from the payment provider.
+var ErrWebhookSignatureInvalid = errors.New("payment: webhook signature invalid")
+
+// Webhook event types.
+const (
+	// EventPaymentSucceeded is received once an order has been paid.
+	// It may be received more than once for the same payment.
+	EventPaymentSucceeded = "payment.succeeded"
+
+	// EventPaymentFailed is received when an attempt to pay for an order
+	// fails. The customer may try again.
+	EventPaymentFailed = "payment.failed"
+
+	// EventCheckoutExpired is received when a checkout expires without
+	// payment.
+	EventCheckoutExpired = "checkout.expired"
+
+	// EventRefunded is received when a payment is refunded in part or in
+	// full. Refunds made outside of this API are included.
+	EventRefunded = "refunded"
+
+	// EventDisputeCreated is received when the customer disputes a
+	// payment with their card issuer.
+	EventDisputeCreated = "dispute.created"
+)
+
+// Refund statuses. A pending refund is settled by a later EventRefunded
+// event.
+const (
+	RefundSucceeded = "succeeded"
+	RefundPending   = "pending"
+	RefundFailed    = "failed"
+)
+
+// Provider takes and refunds payments for orders.
+type Provider interface {
+	// Name returns the name of the provider recorded against each payment.
+	Name() string
+
+	// CreateCheckout starts a checkout for an order returning the
+	// checkout id and the payment intent used to identify the payment.
+	CreateCheckout(ctx context.Context, req *CheckoutRequest) (*Checkout, error)
+
+	// VerifyWebhook verifies a webhook request from the provider and
+	// returns the event. Returns ErrWebhookSignatureInvalid if the request
+	// cannot be verified. Events of any other type are returned with the
+	// provider's own type and should be ignored.
+	VerifyWebhook(ctx context.Context, body []byte, header http.Header) (*Event, error)
+
+	// Capture captures an amount held against a payment intent.
+	Capture(ctx context.Context, paymentIntentID string, amount int) error
+
+	// Refund refunds an amount of a payment.
+	Refund(ctx context.Context, req *RefundRequest) (*Refund, error)
+}
+
+// CheckoutLine holds a single line charged at checkout. Amount is the
+// total for the line including tax.
+type CheckoutLine struct {
+	Name        string
+	Description string
+	Amount      int
+}
+
+// CheckoutRequest holds the details of an order to be paid.
+type CheckoutRequest struct {
+	OrderID    string
+	Currency   string
+	Lines      []*CheckoutLine
+	SuccessURL string
+	CancelURL  string
+}
+
+// Checkout holds a checkout started with the provider.
+type Checkout struct {
+	ID              string
+	PaymentIntentID string
+}
+
+// RefundEvent holds a single refund received with an EventRefunded event.
+// RefundID is the id of the refund in this API if the refund was made
+// through it. Status is one of RefundSucceeded, RefundPending or
+// RefundFailed.
+type RefundEvent struct {
+	ID       string
+	RefundID *string
+	Amount   int
+	Status   string
+}
+
+// DisputeEvent holds the dispute received with an EventDisputeCreated
+// event.
+type DisputeEvent struct {
+	ID     string
+	Amount int
+	Reason string
+}
+
+// Event holds a verified webhook event. ID is the provider's id for the
+// event and is the same each time the event is delivered. Reason is set
+// for EventPaymentFailed. Raw is the body of the request and is recorded
+// against the payment.
+type Event struct {
+	ID              string
+	Type            string
+	OrderID         string
+	PaymentIntentID string
+	Reason          string
+	Refunds         []*RefundEvent
+	Dispute         *DisputeEvent
+	Raw             []byte
+}
+
+// RefundRequest holds the details of a refund to be made.
+type RefundRequest struct {
+	PaymentIntentID string
+	Amount          int
+	RefundID        string
+	OrderID         string
+}
+
+// Refund holds the outcome of a refund made with the provider. Status is
+// one of RefundSucceeded, RefundPending or RefundFailed.
+type Refund struct {
+	ID     string
+	Status string
+}
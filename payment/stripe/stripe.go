@@ -0,0 +1,225 @@
+// Package stripe implements the payment.Provider interface using Stripe
+// Checkout.
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/payment"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	stripego "github.com/stripe/stripe-go"
+	"github.com/stripe/stripe-go/checkout/session"
+	"github.com/stripe/stripe-go/paymentintent"
+	"github.com/stripe/stripe-go/refund"
+	"github.com/stripe/stripe-go/webhook"
+)
+
+// Provider takes payments using Stripe Checkout.
+type Provider struct {
+	signingSecret string
+}
+
+// NewProvider returns a Stripe payment provider. The secret key is set
+// in the Stripe library and the signing secret is used to verify
+// webhook requests.
+func NewProvider(secretKey, signingSecret string) *Provider {
+	if secretKey != "" {
+		stripego.Key = secretKey
+	}
+	return &Provider{signingSecret: signingSecret}
+}
+
+// Name returns stripe.
+func (p *Provider) Name() string {
+	return "stripe"
+}
+
+// CreateCheckout creates a Stripe Checkout session for an order. Each
+// line is sent as a single line item.
+func (p *Provider) CreateCheckout(ctx context.Context, req *payment.CheckoutRequest) (*payment.Checkout, error) {
+	contextLogger := log.WithContext(ctx)
+
+	items := make([]*stripego.CheckoutSessionLineItemParams, 0, len(req.Lines))
+	for _, l := range req.Lines {
+		t := stripego.CheckoutSessionLineItemParams{
+			Name:     stripego.String(l.Name),
+			Amount:   stripego.Int64(int64(l.Amount)),
+			Currency: stripego.String(req.Currency),
+			Quantity: stripego.Int64(1),
+		}
+		if l.Description != "" {
+			t.Description = stripego.String(l.Description)
+		}
+		items = append(items, &t)
+	}
+
+	paymentIntentDataParams := &stripego.CheckoutSessionPaymentIntentDataParams{}
+	paymentIntentDataParams.AddMetadata("order_id", req.OrderID)
+
+	params := &stripego.CheckoutSessionParams{
+		PaymentIntentData: paymentIntentDataParams,
+		ClientReferenceID: stripego.String(req.OrderID),
+		PaymentMethodTypes: stripego.StringSlice([]string{
+			"card",
+		}),
+		LineItems:  items,
+		SuccessURL: stripego.String(req.SuccessURL),
+		CancelURL:  stripego.String(req.CancelURL),
+	}
+
+	cs, err := session.New(params)
+	if err != nil {
+		return nil, errors.Wrap(err, "stripe: failed to create new Stripe session")
+	}
+	contextLogger.Debugf("stripe: checkout session id %s", cs.ID)
+	contextLogger.Debugf("stripe: payment intent id %s", cs.PaymentIntent.ID)
+	return &payment.Checkout{
+		ID:              cs.ID,
+		PaymentIntentID: cs.PaymentIntent.ID,
+	}, nil
+}
+
+// VerifyWebhook verifies the Stripe-Signature header using the signing
+// secret. The checkout.session.completed and payment_intent.succeeded
+// events are returned as payment.EventPaymentSucceeded,
+// payment_intent.payment_failed as payment.EventPaymentFailed,
+// checkout.session.expired as payment.EventCheckoutExpired,
+// charge.refunded and charge.refund.updated as payment.EventRefunded and
+// charge.dispute.created as payment.EventDisputeCreated.
+func (p *Provider) VerifyWebhook(ctx context.Context, body []byte, header http.Header) (*payment.Event, error) {
+	event, err := webhook.ConstructEvent(body, header.Get("Stripe-Signature"), p.signingSecret)
+	if err != nil {
+		log.WithContext(ctx).Warnf("stripe: failed to verify webhook signature: %v", err)
+		return nil, payment.ErrWebhookSignatureInvalid
+	}
+
+	e := payment.Event{
+		ID:   event.ID,
+		Type: string(event.Type),
+		Raw:  body,
+	}
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.expired":
+		var cs stripego.CheckoutSession
+		if err := json.Unmarshal(event.Data.Raw, &cs); err != nil {
+			return nil, errors.Wrap(err, "stripe: failed to parse checkout session")
+		}
+		e.Type = payment.EventPaymentSucceeded
+		if event.Type == "checkout.session.expired" {
+			e.Type = payment.EventCheckoutExpired
+		}
+		e.OrderID = cs.ClientReferenceID
+		if cs.PaymentIntent != nil {
+			e.PaymentIntentID = cs.PaymentIntent.ID
+		}
+	case "payment_intent.succeeded", "payment_intent.payment_failed":
+		var pi stripego.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return nil, errors.Wrap(err, "stripe: failed to parse payment intent")
+		}
+		e.Type = payment.EventPaymentSucceeded
+		if event.Type == "payment_intent.payment_failed" {
+			e.Type = payment.EventPaymentFailed
+			if pi.LastPaymentError != nil {
+				e.Reason = pi.LastPaymentError.Msg
+			}
+		}
+		e.OrderID = pi.Metadata["order_id"]
+		e.PaymentIntentID = pi.ID
+	case "charge.refunded":
+		var charge stripego.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return nil, errors.Wrap(err, "stripe: failed to parse charge")
+		}
+		e.Type = payment.EventRefunded
+		e.PaymentIntentID = charge.PaymentIntent
+		if charge.Refunds != nil {
+			for _, r := range charge.Refunds.Data {
+				e.Refunds = append(e.Refunds, newRefundEvent(r))
+			}
+		}
+	case "charge.refund.updated":
+		var r stripego.Refund
+		if err := json.Unmarshal(event.Data.Raw, &r); err != nil {
+			return nil, errors.Wrap(err, "stripe: failed to parse refund")
+		}
+		e.Type = payment.EventRefunded
+		if r.PaymentIntent != nil {
+			e.PaymentIntentID = r.PaymentIntent.ID
+		}
+		e.Refunds = append(e.Refunds, newRefundEvent(&r))
+	case "charge.dispute.created":
+		var dispute stripego.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return nil, errors.Wrap(err, "stripe: failed to parse dispute")
+		}
+		e.Type = payment.EventDisputeCreated
+		if dispute.PaymentIntent != nil {
+			e.PaymentIntentID = dispute.PaymentIntent.ID
+		}
+		e.Dispute = &payment.DisputeEvent{
+			ID:     dispute.ID,
+			Amount: int(dispute.Amount),
+			Reason: string(dispute.Reason),
+		}
+	}
+	return &e, nil
+}
+
+// Capture captures an amount held against a payment intent.
+func (p *Provider) Capture(ctx context.Context, paymentIntentID string, amount int) error {
+	params := &stripego.PaymentIntentCaptureParams{
+		AmountToCapture: stripego.Int64(int64(amount)),
+	}
+	if _, err := paymentintent.Capture(paymentIntentID, params); err != nil {
+		return errors.Wrapf(err, "stripe: paymentintent.Capture(id=%q, params) failed", paymentIntentID)
+	}
+	return nil
+}
+
+// Refund refunds an amount of a payment intent. The refund id and order
+// id are passed as metadata so the charge.refunded webhook can be matched
+// to the refund.
+func (p *Provider) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.Refund, error) {
+	params := &stripego.RefundParams{
+		PaymentIntent: stripego.String(req.PaymentIntentID),
+		Amount:        stripego.Int64(int64(req.Amount)),
+	}
+	params.AddMetadata("refund_id", req.RefundID)
+	params.AddMetadata("order_id", req.OrderID)
+	r, err := refund.New(params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "stripe: refund.New(params) for refund %q failed", req.RefundID)
+	}
+	return &payment.Refund{
+		ID:     r.ID,
+		Status: refundStatus(r.Status),
+	}, nil
+}
+
+// refundStatus returns the payment refund status of a Stripe refund
+// status. Cancelled refunds have failed.
+func refundStatus(status stripego.RefundStatus) string {
+	switch status {
+	case stripego.RefundStatusSucceeded:
+		return payment.RefundSucceeded
+	case stripego.RefundStatusPending:
+		return payment.RefundPending
+	}
+	return payment.RefundFailed
+}
+
+func newRefundEvent(r *stripego.Refund) *payment.RefundEvent {
+	re := payment.RefundEvent{
+		ID:     r.ID,
+		Amount: int(r.Amount),
+		Status: refundStatus(r.Status),
+	}
+	if v, ok := r.Metadata["refund_id"]; ok {
+		re.RefundID = &v
+	}
+	return &re
+}
@@ -0,0 +1,19 @@
+-- A catalog_import holds the rows of a bulk catalogue import along with
+-- the errors found validating them. Rows are applied in chunks and
+-- processed counts the rows applied so far so that a failed import can
+-- be resumed.
+CREATE TABLE IF NOT EXISTS catalog_import (
+  id             SERIAL PRIMARY KEY,
+  uuid           UUID DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+  format         VARCHAR(8) NOT NULL CHECK (format IN ('csv', 'jsonl')),
+  status         VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('invalid', 'pending', 'processing', 'completed', 'failed')),
+  data           JSONB NOT NULL DEFAULT '[]',
+  total          INTEGER NOT NULL CHECK (total >= 0),
+  processed      INTEGER NOT NULL DEFAULT 0 CHECK (processed >= 0 AND processed <= total),
+  errors         JSONB NOT NULL DEFAULT '[]',
+  error_message  TEXT NULL,
+  created        TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified       TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_catalog_import_created ON catalog_import (created DESC);
@@ -1,14 +1,23 @@
+-- Hidden categories and their descendants are not shown to shoppers.
+-- product_sort sets the order of the products of a leaf category. Manual
+-- uses product_category.pri.
 CREATE TABLE IF NOT EXISTS category (
-  id        SERIAL PRIMARY KEY,
-  uuid      UUID UNIQUE NOT NULL DEFAULT uuid_generate_v4(),
-  segment   VARCHAR(512) NOT NULL,
-  path      VARCHAR(1024) NOT NULL,
-  name      VARCHAR(1024) NOT NULL,
-  lft       INTEGER NOT NULL UNIQUE,
-  rgt       INTEGER NOT NULL UNIQUE,
-  depth     INTEGER NOT NULL,
-  created   TIMESTAMP NOT NULL DEFAULT NOW(),
-  modified  TIMESTAMP NOT NULL DEFAULT NOW(),
+  id               SERIAL PRIMARY KEY,
+  uuid             UUID UNIQUE NOT NULL DEFAULT uuid_generate_v4(),
+  segment          VARCHAR(512) NOT NULL,
+  path             VARCHAR(1024) NOT NULL,
+  name             VARCHAR(1024) NOT NULL,
+  lft              INTEGER NOT NULL UNIQUE,
+  rgt              INTEGER NOT NULL UNIQUE,
+  depth            INTEGER NOT NULL,
+  description      TEXT NOT NULL DEFAULT '',
+  hero_image       VARCHAR(1024) NOT NULL DEFAULT '',
+  meta_title       VARCHAR(512) NOT NULL DEFAULT '',
+  meta_description VARCHAR(1024) NOT NULL DEFAULT '',
+  hidden           BOOLEAN NOT NULL DEFAULT false,
+  product_sort     VARCHAR(16) NOT NULL DEFAULT 'manual' CHECK (product_sort IN ('manual', 'price_asc', 'price_desc', 'newest', 'bestselling')),
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified         TIMESTAMP NOT NULL DEFAULT NOW(),
   UNIQUE (lft, rgt),
   UNIQUE (id, path),
   UNIQUE (path)
@@ -1,9 +1,23 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'inventory_backorder_t') THEN
+        CREATE TYPE inventory_backorder_t AS ENUM('none', 'backorder', 'preorder');
+    END IF;
+END$$;
+
+-- backorder lets orders be placed beyond onhand. available_date is the
+-- date stock is expected and backorder_limit caps the outstanding
+-- backordered quantity.
 CREATE TABLE IF NOT EXISTS inventory (
   id               SERIAL PRIMARY KEY,
   uuid             UUID DEFAULT uuid_generate_v4() UNIQUE,
   product_id       INTEGER NOT NULL,
   onhand           INTEGER CHECK (onhand >= 0),
   overselling      BOOLEAN NOT NULL DEFAULT true,
+  reorder_level    INTEGER NOT NULL DEFAULT 0 CHECK (reorder_level >= 0),
+  backorder        inventory_backorder_t NOT NULL DEFAULT 'none',
+  available_date   DATE NULL DEFAULT NULL,
+  backorder_limit  INTEGER NULL DEFAULT NULL CHECK (backorder_limit >= 0),
   created          TIMESTAMP NOT NULL DEFAULT NOW(),
   modified         TIMESTAMP NOT NULL DEFAULT NOW(),
   FOREIGN KEY (product_id) REFERENCES product (id)
@@ -0,0 +1,24 @@
+-- inventory_location holds the onhand of a product at a single location.
+-- inventory onhand is the total of every location.
+CREATE TABLE IF NOT EXISTS inventory_location (
+  id               SERIAL PRIMARY KEY,
+  inventory_id     INTEGER NOT NULL,
+  location_id      INTEGER NOT NULL,
+  onhand           INTEGER NOT NULL DEFAULT 0 CHECK (onhand >= 0),
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified         TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (inventory_id) REFERENCES inventory (id) ON DELETE CASCADE,
+  FOREIGN KEY (location_id) REFERENCES location (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS inventory_location_idx ON inventory_location (inventory_id, location_id);
+
+-- stock recorded before locations existed is held at the default location.
+INSERT INTO inventory_location (inventory_id, location_id, onhand, created, modified)
+SELECT v.id, l.id, v.onhand, NOW(), NOW()
+FROM inventory AS v
+INNER JOIN location AS l
+  ON l.code = 'default'
+WHERE v.onhand > 0 AND NOT EXISTS (
+  SELECT 1 FROM inventory_location AS il WHERE il.inventory_id = v.id
+)
+ON CONFLICT (inventory_id, location_id) DO NOTHING;
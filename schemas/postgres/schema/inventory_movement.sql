@@ -0,0 +1,31 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'inventory_movement_reason_t') THEN
+        CREATE TYPE inventory_movement_reason_t AS ENUM('order', 'refund', 'adjustment', 'stock_take', 'import');
+    END IF;
+END$$;
+
+-- inventory_movement is an append-only ledger of every change to
+-- inventory onhand. balance is the inventory onhand after the movement
+-- and location is where the stock moved. Movements outlive their
+-- inventory so the sku of the product is recorded with each movement.
+CREATE TABLE IF NOT EXISTS inventory_movement (
+  id               SERIAL PRIMARY KEY,
+  uuid             UUID DEFAULT uuid_generate_v4() UNIQUE,
+  inventory_id     INTEGER NULL DEFAULT NULL,
+  sku              VARCHAR(64) NOT NULL,
+  location_id      INTEGER NULL DEFAULT NULL,
+  reason           inventory_movement_reason_t NOT NULL,
+  delta            INTEGER NOT NULL,
+  balance          INTEGER NOT NULL,
+  order_id         INTEGER NULL DEFAULT NULL,
+  usr_id           INTEGER NULL DEFAULT NULL,
+  note             VARCHAR(1024) NULL DEFAULT NULL,
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (inventory_id) REFERENCES inventory (id) ON DELETE SET NULL,
+  FOREIGN KEY (location_id) REFERENCES location (id),
+  FOREIGN KEY (order_id) REFERENCES "order" (id),
+  FOREIGN KEY (usr_id) REFERENCES usr (id)
+);
+
+CREATE INDEX IF NOT EXISTS inventory_movement_inventory_id_idx ON inventory_movement (inventory_id, created, id);
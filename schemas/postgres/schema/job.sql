@@ -0,0 +1,28 @@
+-- A job is a long-running admin operation run in the background by the
+-- worker pool. Workers claim pending jobs and record a heartbeat while
+-- running so that jobs left running by a worker that has gone away are
+-- picked up again. cancel_requested asks the worker running a job to
+-- stop.
+CREATE TABLE IF NOT EXISTS job (
+  id                SERIAL PRIMARY KEY,
+  uuid              UUID DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+  typ               VARCHAR(32) NOT NULL,
+  status            VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled')),
+  usr_id            INTEGER NULL DEFAULT NULL,
+  payload           JSONB NOT NULL DEFAULT '{}',
+  result            JSONB NULL DEFAULT NULL,
+  progress          INTEGER NOT NULL DEFAULT 0 CHECK (progress >= 0),
+  total             INTEGER NOT NULL DEFAULT 0 CHECK (total >= 0),
+  error_code        VARCHAR(128) NULL DEFAULT NULL,
+  error_message     TEXT NULL DEFAULT NULL,
+  cancel_requested  BOOLEAN NOT NULL DEFAULT false,
+  attempts          INTEGER NOT NULL DEFAULT 0,
+  heartbeat         TIMESTAMP NULL DEFAULT NULL,
+  started           TIMESTAMP NULL DEFAULT NULL,
+  finished          TIMESTAMP NULL DEFAULT NULL,
+  created           TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified          TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (usr_id) REFERENCES usr (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_status ON job (status, id) WHERE status IN ('pending', 'running');
@@ -0,0 +1,16 @@
+-- location is a place stock is held such as a warehouse or retail shop.
+-- Locations with a lower priority are allocated stock first.
+CREATE TABLE IF NOT EXISTS location (
+  id               SERIAL PRIMARY KEY,
+  uuid             UUID DEFAULT uuid_generate_v4() UNIQUE,
+  code             VARCHAR(32) NOT NULL UNIQUE,
+  name             VARCHAR(512) NOT NULL,
+  country_code     CHAR(2) NOT NULL,
+  priority         INTEGER NOT NULL DEFAULT 0,
+  active           BOOLEAN NOT NULL DEFAULT true,
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified         TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- the default location holds stock updated without a location.
+INSERT INTO location (code, name, country_code) VALUES ('default', 'Default', 'GB') ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,9 @@
+-- option_type is a way variants of a product differ such as size or colour.
+CREATE TABLE IF NOT EXISTS option_type (
+  id               SERIAL PRIMARY KEY,
+  uuid             UUID DEFAULT uuid_generate_v4() UNIQUE,
+  code             VARCHAR(32) NOT NULL UNIQUE,
+  name             VARCHAR(512) NOT NULL,
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified         TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -1,14 +1,14 @@
 CREATE TYPE order_status_t
-  AS ENUM ('incomplete', 'completed');
+  AS ENUM ('pending', 'paid', 'processing', 'partially_shipped', 'shipped', 'delivered', 'cancelled', 'refunded');
 
 CREATE TYPE order_payment_status_t
-  AS ENUM ('unpaid', 'paid');
+  AS ENUM ('unpaid', 'failed', 'paid', 'partially_refunded', 'refunded', 'disputed');
 
 CREATE TABLE IF NOT EXISTS "order" (
   id              SERIAL PRIMARY KEY,
   uuid            UUID DEFAULT uuid_generate_v4() UNIQUE,
   usr_id          INTEGER NULL,
-  status          order_status_t NOT NULL DEFAULT 'incomplete',
+  status          order_status_t NOT NULL DEFAULT 'pending',
   payment         order_payment_status_t NOT NULL DEFAULT 'unpaid',
   contact_name    VARCHAR(512) NULL DEFAULT NULL,
   email           VARCHAR(512) NULL DEFAULT NULL,
@@ -16,9 +16,15 @@ CREATE TABLE IF NOT EXISTS "order" (
   billing_id      INTEGER NOT NULL,
   shipping_id     INTEGER NOT NULL,
   currency        CHAR(3) NOT NULL DEFAULT 'GBP',
+  inc_tax         BOOL NOT NULL DEFAULT false,
   total_ex_vat    INTEGER NOT NULL CHECK (total_ex_vat >= 0),
   vat_total       INTEGER NOT NULL CHECK (vat_total >= 0),
   total_inc_vat   INTEGER NOT NULL CHECK (total_inc_vat >= 0 AND total_inc_vat = total_ex_vat + vat_total),
+  discount          INTEGER NOT NULL DEFAULT 0 CHECK (discount >= 0),
+  shipping_code     VARCHAR(256) NULL DEFAULT NULL,
+  shipping_price    INTEGER NOT NULL DEFAULT 0 CHECK (shipping_price >= 0),
+  shipping_discount INTEGER NOT NULL DEFAULT 0 CHECK (shipping_discount >= 0 AND shipping_discount <= shipping_price),
+  shipping_vat      INTEGER NOT NULL DEFAULT 0 CHECK (shipping_vat >= 0),
   created         TIMESTAMP NOT NULL DEFAULT NOW(),
   modified        TIMESTAMP NOT NULL DEFAULT NOW(),
   FOREIGN KEY (usr_id) REFERENCES usr (id),
@@ -26,4 +32,24 @@ CREATE TABLE IF NOT EXISTS "order" (
   FOREIGN KEY (shipping_id) REFERENCES order_address (id)
 );
 
+-- upgrade databases created with the old incomplete and completed order
+-- statuses and the unpaid and paid payment statuses. A value added with
+-- ADD VALUE cannot be used in the same transaction so each statement
+-- runs on its own.
+ALTER TYPE order_status_t ADD VALUE IF NOT EXISTS 'pending';
+ALTER TYPE order_status_t ADD VALUE IF NOT EXISTS 'paid';
+ALTER TYPE order_status_t ADD VALUE IF NOT EXISTS 'processing';
+ALTER TYPE order_status_t ADD VALUE IF NOT EXISTS 'partially_shipped';
+ALTER TYPE order_status_t ADD VALUE IF NOT EXISTS 'shipped';
+ALTER TYPE order_status_t ADD VALUE IF NOT EXISTS 'delivered';
+ALTER TYPE order_status_t ADD VALUE IF NOT EXISTS 'cancelled';
+ALTER TYPE order_status_t ADD VALUE IF NOT EXISTS 'refunded';
+ALTER TYPE order_payment_status_t ADD VALUE IF NOT EXISTS 'failed';
+ALTER TYPE order_payment_status_t ADD VALUE IF NOT EXISTS 'partially_refunded';
+ALTER TYPE order_payment_status_t ADD VALUE IF NOT EXISTS 'refunded';
+ALTER TYPE order_payment_status_t ADD VALUE IF NOT EXISTS 'disputed';
+ALTER TABLE "order" ALTER COLUMN status SET DEFAULT 'pending';
+UPDATE "order" SET status = 'pending', modified = NOW() WHERE status::text = 'incomplete';
+UPDATE "order" SET status = 'paid', modified = NOW() WHERE status::text = 'completed';
+
 ALTER SEQUENCE order_id_seq RESTART WITH 100001;
@@ -8,10 +8,14 @@ CREATE TABLE IF NOT EXISTS order_item (
   qty              SMALLINT NOT NULL CHECK (qty >= 1 AND qty < 10000),
   unit_price       INTEGER NOT NULL CHECK (unit_price >= 0),
   currency         CHAR(3) NOT NULL DEFAULT 'GBP',
-  discount         INTEGER DEFAULT NULL CHECK (discount >= 0 AND discount <= 10000),
+  discount         INTEGER DEFAULT NULL CHECK (discount >= 0 AND discount <= qty * unit_price),
+  order_discount   INTEGER NOT NULL DEFAULT 0 CHECK (order_discount >= 0),
   tax_code         VARCHAR(32) NULL DEFAULT NULL,
   vat              INTEGER NOT NULL CHECK (vat >= 0),
+  reserved         SMALLINT NOT NULL DEFAULT 0 CHECK (reserved >= 0 AND reserved <= qty),
+  backordered      SMALLINT NOT NULL DEFAULT 0 CHECK (backordered >= 0 AND backordered <= qty),
   created          TIMESTAMP NOT NULL DEFAULT NOW(),
   FOREIGN KEY (order_id) REFERENCES "order" (id)
 );
 CREATE UNIQUE INDEX IF NOT EXISTS order_item_idx ON order_item (order_id, sku);
+ALTER TABLE order_item ADD COLUMN IF NOT EXISTS order_discount INTEGER NOT NULL DEFAULT 0 CHECK (order_discount >= 0);
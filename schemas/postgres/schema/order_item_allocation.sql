@@ -0,0 +1,13 @@
+-- order_item_allocation holds the stock of an order item taken from each
+-- location. qty is the quantity still held and is reduced as stock is
+-- released.
+CREATE TABLE IF NOT EXISTS order_item_allocation (
+  id               SERIAL PRIMARY KEY,
+  order_item_id    INTEGER NOT NULL,
+  location_id      INTEGER NOT NULL,
+  qty              SMALLINT NOT NULL CHECK (qty >= 0),
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (order_item_id) REFERENCES order_item (id) ON DELETE CASCADE,
+  FOREIGN KEY (location_id) REFERENCES location (id)
+);
+CREATE INDEX IF NOT EXISTS order_item_allocation_order_item_id_idx ON order_item_allocation (order_item_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS order_status_history (
+  id              SERIAL PRIMARY KEY,
+  order_id        INTEGER NOT NULL,
+  from_status     order_status_t NULL DEFAULT NULL,
+  to_status       order_status_t NOT NULL,
+  note            VARCHAR(1024) NULL DEFAULT NULL,
+  created         TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (order_id) REFERENCES "order" (id)
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_idx ON order_status_history (order_id, created);
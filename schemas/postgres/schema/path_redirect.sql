@@ -0,0 +1,17 @@
+-- A path_redirect maps a path that a product or category used to have to
+-- the product or category. Rows are added when a path changes so that
+-- old storefront URLs keep working. The target is referenced by id so a
+-- redirect always leads to the current path.
+CREATE TABLE IF NOT EXISTS path_redirect (
+  id           SERIAL PRIMARY KEY,
+  from_path    VARCHAR(1024) NOT NULL,
+  product_id   INTEGER NULL DEFAULT NULL,
+  category_id  INTEGER NULL DEFAULT NULL,
+  created      TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (product_id) REFERENCES product (id) ON DELETE CASCADE,
+  FOREIGN KEY (category_id) REFERENCES category (id) ON DELETE CASCADE,
+  CHECK ((product_id IS NULL) <> (category_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_path_redirect_product ON path_redirect (from_path) WHERE product_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_path_redirect_category ON path_redirect (from_path) WHERE category_id IS NOT NULL;
@@ -1,7 +1,7 @@
 DO $$
 BEGIN
     IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'payment_t') THEN
-        CREATE TYPE payment_t AS ENUM('stripe', 'paypal');
+        CREATE TYPE payment_t AS ENUM('stripe', 'paypal', 'manual');
     END IF;
 END$$;
 
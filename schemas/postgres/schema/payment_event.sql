@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS payment_event (
+  id            SERIAL PRIMARY KEY,
+  typ           payment_t NOT NULL,
+  event_id      VARCHAR(255) NOT NULL,
+  event_type    VARCHAR(255) NOT NULL,
+  created       TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (typ, event_id)
+);
@@ -1,12 +1,38 @@
+-- A product with a parent_id is a variant of its parent product such as
+-- a single size and colour of a t-shirt. attributes holds a value for
+-- each product_attribute keyed by the attribute code. Products are
+-- only visible to shoppers once active and within the optional
+-- publish_at and unpublish_at window.
 CREATE TABLE IF NOT EXISTS product (
-  id            SERIAL PRIMARY KEY,
-  uuid          UUID DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
-  path          VARCHAR(512) NOT NULL UNIQUE,
-  sku           VARCHAR(64) NOT NULL UNIQUE,
-  name          VARCHAR(1024) NOT NULL,
-  created       TIMESTAMP NOT NULL DEFAULT NOW(),
-  modified      TIMESTAMP NOT NULL DEFAULT NOW()
+  id               SERIAL PRIMARY KEY,
+  parent_id        INTEGER REFERENCES product (id),
+  uuid             UUID DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+  path             VARCHAR(512) NOT NULL UNIQUE,
+  sku              VARCHAR(64) NOT NULL UNIQUE,
+  name             VARCHAR(1024) NOT NULL,
+  tax_code         VARCHAR(32) NOT NULL DEFAULT 'T20',
+  description      TEXT NOT NULL DEFAULT '',
+  attributes       JSONB NOT NULL DEFAULT '{}',
+  meta_title       VARCHAR(512) NOT NULL DEFAULT '',
+  meta_description VARCHAR(1024) NOT NULL DEFAULT '',
+  status           VARCHAR(16) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'active', 'archived')),
+  publish_at       TIMESTAMP,
+  unpublish_at     TIMESTAMP,
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified         TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+CREATE INDEX IF NOT EXISTS idx_product_parent_id ON product (parent_id);
+CREATE INDEX IF NOT EXISTS idx_product_status ON product (status);
+
+-- Full-text search over the name, sku, description and attributes of
+-- products. The expression must match productSearchVector in the model.
+CREATE INDEX IF NOT EXISTS idx_product_search ON product USING GIN ((
+  setweight(to_tsvector('english', name), 'A') ||
+  setweight(to_tsvector('simple', sku), 'A') ||
+  setweight(to_tsvector('english', description), 'B') ||
+  setweight(jsonb_to_tsvector('english', attributes, '["string", "numeric", "boolean"]'), 'C')
+));
+CREATE INDEX IF NOT EXISTS idx_product_attributes ON product USING GIN (attributes);
 CREATE INDEX IF NOT EXISTS idx_product_created_desc ON product (created DESC);
 CREATE INDEX IF NOT EXISTS idx_product_modified ON product (modified DESC);
@@ -0,0 +1,11 @@
+-- product_attribute defines a custom attribute of products such as
+-- weight or material and the type of its values.
+CREATE TABLE IF NOT EXISTS product_attribute (
+  id               SERIAL PRIMARY KEY,
+  uuid             UUID DEFAULT uuid_generate_v4() UNIQUE,
+  code             VARCHAR(32) NOT NULL UNIQUE,
+  name             VARCHAR(512) NOT NULL,
+  typ              VARCHAR(16) NOT NULL CHECK (typ IN ('string', 'integer', 'number', 'boolean')),
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified         TIMESTAMP NOT NULL DEFAULT NOW()
+);
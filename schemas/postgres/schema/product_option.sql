@@ -0,0 +1,12 @@
+-- product_option holds the value of a single option type of a variant
+-- such as size XL.
+CREATE TABLE IF NOT EXISTS product_option (
+  id               SERIAL PRIMARY KEY,
+  product_id       INTEGER NOT NULL,
+  option_type_id   INTEGER NOT NULL,
+  value            VARCHAR(64) NOT NULL,
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (product_id) REFERENCES product (id) ON DELETE CASCADE,
+  FOREIGN KEY (option_type_id) REFERENCES option_type (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS product_option_idx ON product_option (product_id, option_type_id);
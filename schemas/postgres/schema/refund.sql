@@ -0,0 +1,24 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'refund_status_t') THEN
+        CREATE TYPE refund_status_t AS ENUM('pending', 'succeeded', 'failed');
+    END IF;
+END$$;
+
+CREATE TABLE IF NOT EXISTS refund (
+  id               SERIAL PRIMARY KEY,
+  uuid             UUID DEFAULT uuid_generate_v4() UNIQUE,
+  order_id         INTEGER NOT NULL,
+  payment_id       INTEGER NOT NULL,
+  stripe_refund_id VARCHAR(255) NULL DEFAULT NULL UNIQUE,
+  status           refund_status_t NOT NULL DEFAULT 'pending',
+  amount           INTEGER NOT NULL CHECK (amount > 0),
+  reason           VARCHAR(1024) NULL DEFAULT NULL,
+  restock          BOOLEAN NOT NULL DEFAULT false,
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified         TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (order_id) REFERENCES "order" (id),
+  FOREIGN KEY (payment_id) REFERENCES payment (id)
+);
+
+CREATE INDEX IF NOT EXISTS refund_order_id_idx ON refund (order_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS refund_item (
+  id               SERIAL PRIMARY KEY,
+  refund_id        INTEGER NOT NULL,
+  order_item_id    INTEGER NOT NULL,
+  qty              SMALLINT NOT NULL CHECK (qty >= 1 AND qty < 10000),
+  amount           INTEGER NOT NULL CHECK (amount >= 0),
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (refund_id) REFERENCES refund (id) ON DELETE CASCADE,
+  FOREIGN KEY (order_item_id) REFERENCES order_item (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS refund_item_idx ON refund_item (refund_id, order_item_id);
+CREATE INDEX IF NOT EXISTS refund_item_order_item_id_idx ON refund_item (order_item_id);
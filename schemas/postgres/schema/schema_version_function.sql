@@ -2,6 +2,6 @@ create function schema_version()
   returns text
 as
 $$
-  select 'v0.64.0' || '';
+  select 'v0.65.0' || '';
 $$
 language sql;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS shipment (
+  id               SERIAL PRIMARY KEY,
+  uuid             UUID DEFAULT uuid_generate_v4() UNIQUE,
+  order_id         INTEGER NOT NULL,
+  carrier          VARCHAR(128) NOT NULL,
+  tracking_number  VARCHAR(128) NOT NULL,
+  tracking_url     VARCHAR(2048) NULL DEFAULT NULL,
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified         TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (order_id) REFERENCES "order" (id)
+);
+
+CREATE INDEX IF NOT EXISTS shipment_order_id_idx ON shipment (order_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS shipment_item (
+  id               SERIAL PRIMARY KEY,
+  shipment_id      INTEGER NOT NULL,
+  order_item_id    INTEGER NOT NULL,
+  qty              SMALLINT NOT NULL CHECK (qty >= 1 AND qty < 10000),
+  created          TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (shipment_id) REFERENCES shipment (id) ON DELETE CASCADE,
+  FOREIGN KEY (order_item_id) REFERENCES order_item (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS shipment_item_idx ON shipment_item (shipment_id, order_item_id);
+CREATE INDEX IF NOT EXISTS shipment_item_order_item_id_idx ON shipment_item (order_item_id);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS tax_rate (
+  id              SERIAL PRIMARY KEY,
+  uuid            UUID DEFAULT uuid_generate_v4() NOT NULL UNIQUE,
+  tax_code        VARCHAR(32) NOT NULL,
+  country_code    CHAR(2) NOT NULL,
+  region          VARCHAR(512) NULL DEFAULT NULL,
+  rate            INTEGER NOT NULL CHECK (rate >= 0 AND rate <= 10000),
+  effective_from  TIMESTAMP NOT NULL DEFAULT NOW(),
+  effective_to    TIMESTAMP NULL DEFAULT NULL CHECK (effective_to IS NULL OR effective_to > effective_from),
+  created         TIMESTAMP NOT NULL DEFAULT NOW(),
+  modified        TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_rate_lookup ON tax_rate (tax_code, country_code, effective_from DESC);
+
+-- UK VAT rates since 4 January 2011. Rates are held in basis points
+-- so 2000 is 20.00%.
+INSERT INTO tax_rate (tax_code, country_code, rate, effective_from) VALUES ('T20', 'GB', 2000, '2011-01-04 00:00:00');
+INSERT INTO tax_rate (tax_code, country_code, rate, effective_from) VALUES ('T5', 'GB', 500, '2011-01-04 00:00:00');
+INSERT INTO tax_rate (tax_code, country_code, rate, effective_from) VALUES ('T0', 'GB', 0, '2011-01-04 00:00:00');
@@ -0,0 +1,76 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// Availability holds the availability of a product. Status is one of
+// in_stock, out_of_stock, backorder or preorder. AvailableDate is the
+// date stock is expected for products on backorder or pre-order.
+type Availability struct {
+	Status        string     `json:"status"`
+	AvailableDate *time.Time `json:"available_date,omitempty"`
+}
+
+// Backorder holds the outstanding backordered quantity of a single
+// product across all open orders.
+type Backorder struct {
+	Object        string     `json:"object"`
+	ProductID     string     `json:"product_id"`
+	SKU           string     `json:"sku"`
+	Name          string     `json:"name"`
+	Backorder     string     `json:"backorder"`
+	AvailableDate *time.Time `json:"available_date,omitempty"`
+	Backordered   int        `json:"backordered"`
+	Orders        int        `json:"orders"`
+}
+
+// getAvailability returns the availability of each of the products with
+// the given ids keyed by product id.
+func (s *Service) getAvailability(ctx context.Context, productIDs []string) (map[string]*Availability, error) {
+	rows, err := s.model.GetProductAvailability(ctx, productIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "service: s.model.GetProductAvailability(ctx, productUUIDs) failed")
+	}
+	availability := make(map[string]*Availability, len(rows))
+	for id, row := range rows {
+		a := Availability{
+			Status: row.Status,
+		}
+		if row.Status == postgres.AvailabilityBackorder || row.Status == postgres.AvailabilityPreorder {
+			a.AvailableDate = row.AvailableDate
+		}
+		availability[id] = &a
+	}
+	return availability, nil
+}
+
+// GetBackorders returns a page of the outstanding backordered quantity
+// of each product across all open orders.
+func (s *Service) GetBackorders(ctx context.Context, opts *ListOptions) ([]*Backorder, *Pagination, error) {
+	rows, lc, err := s.model.GetBackorders(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "service: s.model.GetBackorders(ctx) failed")
+	}
+	backorders := make([]*Backorder, 0, len(rows))
+	for _, row := range rows {
+		backorders = append(backorders, &Backorder{
+			Object:        "backorder",
+			ProductID:     row.ProductUUID,
+			SKU:           row.SKU,
+			Name:          row.Name,
+			Backorder:     row.Backorder,
+			AvailableDate: row.AvailableDate,
+			Backordered:   row.Backordered,
+			Orders:        row.Orders,
+		})
+	}
+	return backorders, newPagination(opts, lc, len(backorders), func(i int) string { return backorders[i].ProductID }), nil
+}
@@ -0,0 +1,180 @@
+package firebase
+
+import (
+	"context"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// CartTotalsLine holds the price of a single product in a cart after
+// discounts. Discount is the sum of all product, product set and
+// category promotions applied to the line.
+type CartTotalsLine struct {
+	ProductID      string   `json:"product_id"`
+	Path           string   `json:"path"`
+	SKU            string   `json:"sku"`
+	Name           string   `json:"name"`
+	Qty            int      `json:"qty"`
+	UnitPrice      int      `json:"unit_price"`
+	Total          int      `json:"total"`
+	Discount       int      `json:"discount"`
+	TaxCode        string   `json:"tax_code"`
+	VAT            int      `json:"vat"`
+	PromoRuleCodes []string `json:"promo_rule_codes"`
+}
+
+// CartTotalsPromo holds a promo rule that discounted the cart.
+type CartTotalsPromo struct {
+	PromoRuleID   string  `json:"promo_rule_id"`
+	PromoRuleCode string  `json:"promo_rule_code"`
+	CouponCode    *string `json:"coupon_code,omitempty"`
+	Target        string  `json:"target"`
+	Discount      int     `json:"discount"`
+}
+
+// CartTotalsShipping holds the shipping charge for a cart.
+type CartTotalsShipping struct {
+	ShippingTariffID string `json:"shipping_tariff_id"`
+	ShippingCode     string `json:"shipping_code"`
+	Name             string `json:"name"`
+	Price            int    `json:"price"`
+	Discount         int    `json:"discount"`
+	VAT              int    `json:"vat"`
+}
+
+// CartTotalsTax holds the tax due for a single tax code.
+type CartTotalsTax struct {
+	TaxCode string `json:"tax_code"`
+	Taxable int    `json:"taxable"`
+	Tax     int    `json:"tax"`
+}
+
+// CartTotals holds a quote for a cart using the same calculation
+// used when placing an order. If IncTax is true the unit prices of the
+// lines include tax.
+type CartTotals struct {
+	Object        string              `json:"object"`
+	CartID        string              `json:"cart_id"`
+	Currency      string              `json:"currency"`
+	IncTax        bool                `json:"inc_tax"`
+	Lines         []*CartTotalsLine   `json:"lines"`
+	Promos        []*CartTotalsPromo  `json:"promos"`
+	Subtotal      int                 `json:"subtotal"`
+	LinesDiscount int                 `json:"lines_discount"`
+	Discount      int                 `json:"discount"`
+	Shipping      *CartTotalsShipping `json:"shipping,omitempty"`
+	Taxes         []*CartTotalsTax    `json:"taxes"`
+	TotalExVAT    int                 `json:"total_ex_vat"`
+	VATTotal      int                 `json:"vat_total"`
+	TotalIncVAT   int                 `json:"total_inc_vat"`
+}
+
+// GetCartTotals returns the totals for a cart including any discounts
+// from active offers and coupons applied to the cart. If
+// shippingTariffID is not nil the shipping charge is included. Taxes
+// are calculated for the country code and optional region. If
+// countryCode is nil the country of the shipping tariff is used.
+func (s *Service) GetCartTotals(ctx context.Context, userID, cartID string, shippingTariffID, countryCode, region *string) (*CartTotals, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: GetCartTotals(ctx, userID=%q, cartID=%q, shippingTariffID=%v, countryCode=%v, region=%v) started", userID, cartID, shippingTariffID, countryCode, region)
+
+	var dest *postgres.TaxDestination
+	if countryCode != nil {
+		dest = &postgres.TaxDestination{
+			CountryCode: *countryCode,
+			Region:      region,
+		}
+	}
+	p, err := s.model.GetCartTotals(ctx, cartID, userID, shippingTariffID, dest)
+	if err == postgres.ErrCartNotFound {
+		return nil, ErrCartNotFound
+	}
+	if err == postgres.ErrUserNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err == postgres.ErrDefaultPriceListNotFound {
+		return nil, ErrDefaultPriceListNotFound
+	}
+	if err == postgres.ErrShippingTariffNotFound {
+		return nil, ErrShippingTariffNotFound
+	}
+	if err == postgres.ErrTaxRateNotFound {
+		return nil, ErrTaxRateNotFound
+	}
+	if err == postgres.ErrCartProductUnpublished {
+		return nil, ErrCartProductUnpublished
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetCartTotals(ctx, cartID=%q, userID=%q, ...) failed", cartID, userID)
+	}
+
+	lines := make([]*CartTotalsLine, 0, len(p.Lines))
+	for _, l := range p.Lines {
+		codes := l.PromoRuleCodes
+		if codes == nil {
+			codes = []string{}
+		}
+		lines = append(lines, &CartTotalsLine{
+			ProductID:      l.ProductUUID,
+			Path:           l.Path,
+			SKU:            l.SKU,
+			Name:           l.Name,
+			Qty:            l.Qty,
+			UnitPrice:      l.UnitPrice,
+			Total:          l.Total,
+			Discount:       l.Discount,
+			TaxCode:        l.TaxCode,
+			VAT:            l.VAT,
+			PromoRuleCodes: codes,
+		})
+	}
+
+	promos := make([]*CartTotalsPromo, 0, len(p.Promos))
+	for _, v := range p.Promos {
+		promos = append(promos, &CartTotalsPromo{
+			PromoRuleID:   v.PromoRuleUUID,
+			PromoRuleCode: v.PromoRuleCode,
+			CouponCode:    v.CouponCode,
+			Target:        v.Target,
+			Discount:      v.Discount,
+		})
+	}
+
+	taxes := make([]*CartTotalsTax, 0, len(p.Taxes))
+	for _, t := range p.Taxes {
+		taxes = append(taxes, &CartTotalsTax{
+			TaxCode: t.TaxCode,
+			Taxable: t.Taxable,
+			Tax:     t.Tax,
+		})
+	}
+
+	totals := CartTotals{
+		Object:        "cart_totals",
+		CartID:        cartID,
+		Currency:      "GBP",
+		IncTax:        p.IncTax,
+		Lines:         lines,
+		Promos:        promos,
+		Subtotal:      p.SubTotal,
+		LinesDiscount: p.LinesDiscount,
+		Discount:      p.Discount,
+		Taxes:         taxes,
+		TotalExVAT:    p.TotalExVAT,
+		VATTotal:      p.VATTotal,
+		TotalIncVAT:   p.TotalIncVAT,
+	}
+	if p.ShippingTariff != nil {
+		totals.Shipping = &CartTotalsShipping{
+			ShippingTariffID: p.ShippingTariff.UUID,
+			ShippingCode:     p.ShippingTariff.ShippingCode,
+			Name:             p.ShippingTariff.Name,
+			Price:            p.ShippingPrice,
+			Discount:         p.ShippingDiscount,
+			VAT:              p.ShippingVAT,
+		}
+	}
+	return &totals, nil
+}
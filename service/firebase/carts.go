@@ -25,6 +25,10 @@ var (
 
 	// ErrProductHasNoPrices error
 	ErrProductHasNoPrices = errors.New("service: product has no prices")
+
+	// ErrCartProductUnpublished is returned when pricing or ordering a cart
+	// that holds a product that is no longer published.
+	ErrCartProductUnpublished = errors.New("service: cart product unpublished")
 )
 
 // Cart holds the details of a shopping cart.
@@ -38,16 +42,17 @@ type Cart struct {
 
 // CartProduct structure holds the details individual cart product.
 type CartProduct struct {
-	Object    string    `json:"object"`
-	ID        string    `json:"id"`
-	CartID    string    `json:"cart_id"`
-	ProductID string    `json:"product_id"`
-	SKU       string    `json:"sku"`
-	Name      string    `json:"name"`
-	Qty       int       `json:"qty"`
-	UnitPrice int       `json:"unit_price"`
-	Created   time.Time `json:"created"`
-	Modified  time.Time `json:"modified"`
+	Object       string        `json:"object"`
+	ID           string        `json:"id"`
+	CartID       string        `json:"cart_id"`
+	ProductID    string        `json:"product_id"`
+	SKU          string        `json:"sku"`
+	Name         string        `json:"name"`
+	Qty          int           `json:"qty"`
+	UnitPrice    int           `json:"unit_price"`
+	Availability *Availability `json:"availability,omitempty"`
+	Created      time.Time     `json:"created"`
+	Modified     time.Time     `json:"modified"`
 }
 
 // CreateCart generates a new random id to be used for subseqent cart calls.
@@ -66,10 +71,20 @@ func (s *Service) CreateCart(ctx context.Context) (*Cart, error) {
 	return &cart, nil
 }
 
-// AddProductToCart adds a single product to a given cart.
-// Returns `ErrCartNotFound` if the cart with `cartID` does not exist.
-func (s *Service) AddProductToCart(ctx context.Context, userID, cartID, productID string, qty int) (*CartProduct, error) {
-	log.WithContext(ctx).Debugf("service: s.AddProductToCart(userID=%q, cartID=%q, userID=%q, productID=%q, qty=%d) started", userID, cartID, userID, productID, qty)
+// AddProductToCart adds a single product to a given cart. If options is
+// not empty the variant of the product with the given options is added.
+// Returns `ErrCartNotFound` if the cart with `cartID` does not exist,
+// `ErrProductNotFound` if the product or variant is not published,
+// `ErrVariantNotFound` if no variant has the options or
+// `ErrProductHasVariants` if the product has variants and no options are
+// given.
+func (s *Service) AddProductToCart(ctx context.Context, userID, cartID, productID string, options map[string]string, qty int) (*CartProduct, error) {
+	log.WithContext(ctx).Debugf("service: s.AddProductToCart(userID=%q, cartID=%q, userID=%q, productID=%q, options=%v, qty=%d) started", userID, cartID, userID, productID, options, qty)
+
+	productID, err := s.resolveVariant(ctx, productID, options)
+	if err != nil {
+		return nil, err
+	}
 
 	item, err := s.model.AddProductToCart(ctx, cartID, userID, productID, qty)
 	if err == postgres.ErrCartNotFound {
@@ -134,19 +149,29 @@ func (s *Service) GetCartProducts(ctx context.Context, userID, cartID string) ([
 		return nil, errors.Wrapf(err, "service: s.model.GetCartProducts(ctx, cartID=%q, userID=%q) failed", userID, cartID)
 	}
 
+	productIDs := make([]string, 0, len(cartProducts))
+	for _, v := range cartProducts {
+		productIDs = append(productIDs, v.ProductUUID)
+	}
+	availability, err := s.getAvailability(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	results := make([]*CartProduct, 0, 32)
 	for _, v := range cartProducts {
 		i := CartProduct{
-			Object:    "cart_product",
-			ID:        v.UUID,
-			CartID:    cartID,
-			ProductID: v.ProductUUID,
-			SKU:       v.SKU,
-			Name:      v.Name,
-			Qty:       v.Qty,
-			UnitPrice: v.UnitPrice,
-			Created:   v.Created,
-			Modified:  v.Modified,
+			Object:       "cart_product",
+			ID:           v.UUID,
+			CartID:       cartID,
+			ProductID:    v.ProductUUID,
+			SKU:          v.SKU,
+			Name:         v.Name,
+			Qty:          v.Qty,
+			UnitPrice:    v.UnitPrice,
+			Availability: availability[v.ProductUUID],
+			Created:      v.Created,
+			Modified:     v.Modified,
 		}
 		results = append(results, &i)
 	}
@@ -0,0 +1,322 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+const maxSKULength = 64
+
+// CatalogItem holds the values of a single product in a catalog import or
+// export keyed by SKU. Fields of an import that are nil are left
+// unchanged. New products must have a path and name and are created as
+// drafts unless Status is set. Status changes made by an import do not
+// publish product events. New products with a ParentSKU are created as
+// variants of the parent and must have Options holding the value of each
+// option type keyed by option type code. The parent SKU and options of
+// existing products cannot be changed. Prices maps price list codes to
+// the unit price for a quantity of one. Onhand is the stock at the
+// default location. Categories holds leaf category paths and Images
+// image paths in display order.
+type CatalogItem struct {
+	SKU             string                 `json:"sku"`
+	ParentSKU       *string                `json:"parent_sku"`
+	Options         map[string]string      `json:"options"`
+	Status          *string                `json:"status"`
+	Path            *string                `json:"path"`
+	Name            *string                `json:"name"`
+	TaxCode         *string                `json:"tax_code"`
+	Description     *string                `json:"description"`
+	Attributes      map[string]interface{} `json:"attributes"`
+	MetaTitle       *string                `json:"meta_title"`
+	MetaDescription *string                `json:"meta_description"`
+	Prices          map[string]int         `json:"prices"`
+	Onhand          *int                   `json:"onhand"`
+	Categories      []string               `json:"categories"`
+	Images          []string               `json:"images"`
+}
+
+func catalogItemFromRow(row *postgres.CatalogItemRow) *CatalogItem {
+	return &CatalogItem{
+		SKU:             row.SKU,
+		ParentSKU:       row.ParentSKU,
+		Options:         row.Options,
+		Status:          row.Status,
+		Path:            row.Path,
+		Name:            row.Name,
+		TaxCode:         row.TaxCode,
+		Description:     row.Description,
+		Attributes:      row.Attributes,
+		MetaTitle:       row.MetaTitle,
+		MetaDescription: row.MetaDescription,
+		Prices:          row.Prices,
+		Onhand:          row.Onhand,
+		Categories:      row.Categories,
+		Images:          row.Images,
+	}
+}
+
+func (c *CatalogItem) row() *postgres.CatalogItemRow {
+	if c == nil {
+		return nil
+	}
+	return &postgres.CatalogItemRow{
+		SKU:             c.SKU,
+		ParentSKU:       c.ParentSKU,
+		Options:         c.Options,
+		Status:          c.Status,
+		Path:            c.Path,
+		Name:            c.Name,
+		TaxCode:         c.TaxCode,
+		Description:     c.Description,
+		Attributes:      c.Attributes,
+		MetaTitle:       c.MetaTitle,
+		MetaDescription: c.MetaDescription,
+		Prices:          c.Prices,
+		Onhand:          c.Onhand,
+		Categories:      c.Categories,
+		Images:          c.Images,
+	}
+}
+
+// catalogRefs holds the existing catalog data that import rows are
+// validated against.
+type catalogRefs struct {
+	products    map[string]*postgres.CatalogProductRef // by sku
+	priceLists  map[string]bool                        // price list codes
+	categories  map[string]bool                        // leaf category paths
+	optionTypes map[string]bool                        // option type codes
+	attributes  []*postgres.ProductAttributeRow
+}
+
+func (s *Service) getCatalogRefs(ctx context.Context) (*catalogRefs, error) {
+	products, err := s.model.GetCatalogProductRefs(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "service: s.model.GetCatalogProductRefs(ctx) failed")
+	}
+	priceLists, _, err := s.model.GetPriceLists(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "service: s.model.GetPriceLists(ctx) failed")
+	}
+	categories, err := s.model.GetCategories(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "service: s.model.GetCategories(ctx) failed")
+	}
+	attributes, _, err := s.model.GetProductAttributes(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "service: s.model.GetProductAttributes(ctx) failed")
+	}
+	optionTypes, _, err := s.model.GetOptionTypes(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "service: s.model.GetOptionTypes(ctx) failed")
+	}
+
+	refs := catalogRefs{
+		products:    products,
+		priceLists:  make(map[string]bool),
+		categories:  make(map[string]bool),
+		optionTypes: make(map[string]bool),
+		attributes:  attributes,
+	}
+	for _, l := range priceLists {
+		refs.priceLists[l.Code] = true
+	}
+	for _, c := range categories {
+		if c.Lft == c.Rgt-1 {
+			refs.categories[c.Path] = true
+		}
+	}
+	for _, t := range optionTypes {
+		refs.optionTypes[t.Code] = true
+	}
+	return &refs, nil
+}
+
+// validateCatalogItems returns the errors of the items of an import. Nil
+// items could not be read and are skipped. The path of a row must not
+// be in use by another product once the rows before it are applied and
+// the parent of a new variant must exist or be created by an earlier
+// row.
+func validateCatalogItems(items []*CatalogItem, refs *catalogRefs) []*CatalogImportRowError {
+	var rowErrors []*CatalogImportRowError
+	report := func(row int, sku, format string, a ...interface{}) {
+		rowErrors = append(rowErrors, &CatalogImportRowError{Row: row, SKU: sku, Message: fmt.Sprintf(format, a...)})
+	}
+
+	// owners holds the sku of the product at each path, parents the
+	// parent sku of each product or an empty string if it is not a
+	// variant and variants the options of the variants of each parent
+	// as the rows are applied in order.
+	owners := make(map[string]string)
+	parents := make(map[string]string)
+	variants := make(map[string][]map[string]string)
+	for sku, p := range refs.products {
+		owners[p.Path] = sku
+		parents[sku] = ""
+		if p.ParentSKU != nil {
+			parents[sku] = *p.ParentSKU
+			variants[*p.ParentSKU] = append(variants[*p.ParentSKU], p.Options)
+		}
+	}
+	skuRows := make(map[string]int)
+	for i, item := range items {
+		if item == nil {
+			continue
+		}
+		row := i + 1
+
+		if item.SKU == "" {
+			report(row, item.SKU, "sku must be set")
+			continue
+		}
+		if len(item.SKU) > maxSKULength {
+			report(row, item.SKU, "sku must be at most %d characters", maxSKULength)
+		}
+		if r, ok := skuRows[item.SKU]; ok {
+			report(row, item.SKU, "sku already appears in row %d", r)
+			continue
+		}
+		skuRows[item.SKU] = row
+
+		if item.Path != nil && *item.Path == "" {
+			report(row, item.SKU, "path must not be empty")
+		}
+		if item.Name != nil && *item.Name == "" {
+			report(row, item.SKU, "name must not be empty")
+		}
+		existing, ok := refs.products[item.SKU]
+		if !ok && (item.Path == nil || item.Name == nil) {
+			report(row, item.SKU, "path and name must be set for new products")
+		}
+		if item.Path != nil && *item.Path != "" {
+			if sku, ok := owners[*item.Path]; ok && sku != item.SKU {
+				report(row, item.SKU, "path %q is used by product %q", *item.Path, sku)
+			} else {
+				if existing != nil {
+					delete(owners, existing.Path)
+				}
+				owners[*item.Path] = item.SKU
+			}
+		}
+		if item.Status != nil && !IsValidProductStatus(*item.Status) {
+			report(row, item.SKU, "status must be one of %s, %s or %s", ProductStatusDraft, ProductStatusActive, ProductStatusArchived)
+		}
+
+		if existing != nil {
+			if item.ParentSKU != nil && (existing.ParentSKU == nil || *existing.ParentSKU != *item.ParentSKU) {
+				report(row, item.SKU, "parent_sku of an existing product cannot be changed")
+			}
+			if item.Options != nil && !sameOptions(item.Options, existing.Options) {
+				report(row, item.SKU, "options of an existing product cannot be changed")
+			}
+		} else if item.ParentSKU != nil {
+			parent := *item.ParentSKU
+			if grandParent, ok := parents[parent]; !ok {
+				report(row, item.SKU, "parent product %q not found in the catalog or an earlier row", parent)
+			} else if grandParent != "" {
+				report(row, item.SKU, "parent product %q is a variant", parent)
+			}
+			if len(item.Options) == 0 {
+				report(row, item.SKU, "options must be set for variants")
+			}
+			codes := make([]string, 0, len(item.Options))
+			for code := range item.Options {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			for _, code := range codes {
+				if !refs.optionTypes[code] {
+					report(row, item.SKU, "option type %q not found", code)
+				}
+			}
+			for _, options := range variants[parent] {
+				if sameOptions(options, item.Options) {
+					report(row, item.SKU, "another variant of %q has the same options", parent)
+					break
+				}
+			}
+			parents[item.SKU] = parent
+			variants[parent] = append(variants[parent], item.Options)
+		} else {
+			if len(item.Options) > 0 {
+				report(row, item.SKU, "options can only be set for variants")
+			}
+			parents[item.SKU] = ""
+		}
+
+		if err := validateAttributes(refs.attributes, item.Attributes); err != nil {
+			if aerr, ok := err.(*ProductAttributeError); ok {
+				report(row, item.SKU, "attribute %q %s", aerr.Code, aerr.Message)
+			}
+		}
+		codes := make([]string, 0, len(item.Prices))
+		for code := range item.Prices {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			if !refs.priceLists[code] {
+				report(row, item.SKU, "price list %q not found", code)
+			} else if item.Prices[code] < 0 {
+				report(row, item.SKU, "price for price list %q must not be negative", code)
+			}
+		}
+		if item.Onhand != nil && *item.Onhand < 0 {
+			report(row, item.SKU, "onhand must not be negative")
+		}
+		for _, path := range item.Categories {
+			if !refs.categories[path] {
+				report(row, item.SKU, "leaf category %q not found", path)
+			}
+		}
+	}
+	return rowErrors
+}
+
+// sameOptions returns true if a and b hold the same option values.
+func sameOptions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ExportCatalog writes every product in the catalog to w in the given
+// format. The products are streamed from the database as they are
+// written.
+func (s *Service) ExportCatalog(ctx context.Context, format string, w io.Writer) error {
+	priceLists, _, err := s.model.GetPriceLists(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "service: s.model.GetPriceLists(ctx) failed")
+	}
+	codes := make([]string, 0, len(priceLists))
+	for _, l := range priceLists {
+		codes = append(codes, l.Code)
+	}
+	sort.Strings(codes)
+
+	cw, err := newCatalogWriter(format, w, codes)
+	if err != nil {
+		return err
+	}
+	err = s.model.ExportCatalog(ctx, func(row *postgres.CatalogItemRow) error {
+		return cw.Write(catalogItemFromRow(row))
+	})
+	if err != nil {
+		return errors.Wrap(err, "service: s.model.ExportCatalog(ctx) failed")
+	}
+	if err := cw.Flush(); err != nil {
+		return errors.Wrap(err, "service: flush of catalog export failed")
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package firebase
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Catalog import and export formats. CSV files have a header row naming
+// the columns and JSON Lines files hold one JSON object per line.
+const (
+	CatalogFormatCSV   = "csv"
+	CatalogFormatJSONL = "jsonl"
+)
+
+// catalogCSVColumns are the CSV columns other than prices. Each price
+// list has a column named with catalogCSVPricePrefix followed by the
+// price list code. Categories and images are separated by
+// catalogCSVListSeparator.
+var catalogCSVColumns = []string{
+	"sku", "parent_sku", "status", "path", "name", "tax_code", "description",
+	"meta_title", "meta_description", "attributes", "options", "onhand",
+	"categories", "images",
+}
+
+const (
+	catalogCSVPricePrefix   = "price:"
+	catalogCSVListSeparator = "|"
+	maxCatalogJSONLLineSize = 1 << 20
+)
+
+// IsValidCatalogFormat returns true if format is csv or jsonl.
+func IsValidCatalogFormat(format string) bool {
+	return format == CatalogFormatCSV || format == CatalogFormatJSONL
+}
+
+// CatalogFormatError is returned when a catalog import cannot be read
+// at all such as a CSV file with an unknown column.
+type CatalogFormatError struct {
+	Message string
+}
+
+func (e *CatalogFormatError) Error() string {
+	return "service: catalog format: " + e.Message
+}
+
+// decodeCatalog reads the items of a catalog import in the given
+// format. The item at index i is row i+1 of the import and is nil if the
+// row could not be read in which case the row errors say why.
+func decodeCatalog(format string, r io.Reader) ([]*CatalogItem, []*CatalogImportRowError, error) {
+	if format == CatalogFormatJSONL {
+		return decodeCatalogJSONL(r)
+	}
+	return decodeCatalogCSV(r)
+}
+
+func decodeCatalogCSV(r io.Reader) ([]*CatalogItem, []*CatalogImportRowError, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, &CatalogFormatError{Message: "csv header row missing"}
+	}
+	if err != nil {
+		return nil, nil, &CatalogFormatError{Message: err.Error()}
+	}
+	if err := checkCatalogCSVHeader(header); err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]*CatalogItem, 0, 256)
+	var rowErrors []*CatalogImportRowError
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		row := len(items) + 1
+		if perr, ok := err.(*csv.ParseError); ok && perr.Err == csv.ErrFieldCount {
+			rowErrors = append(rowErrors, &CatalogImportRowError{
+				Row:     row,
+				Message: fmt.Sprintf("row has %d fields but the header has %d", len(record), len(header)),
+			})
+			items = append(items, nil)
+			continue
+		}
+		if err != nil {
+			return nil, nil, &CatalogFormatError{Message: err.Error()}
+		}
+
+		item, messages := catalogItemFromCSV(header, record)
+		for _, m := range messages {
+			rowErrors = append(rowErrors, &CatalogImportRowError{Row: row, SKU: item.SKU, Message: m})
+		}
+		if len(messages) > 0 {
+			item = nil
+		}
+		items = append(items, item)
+	}
+	return items, rowErrors, nil
+}
+
+// checkCatalogCSVHeader checks every column of the header is known,
+// appears once and that the sku column is present.
+func checkCatalogCSVHeader(header []string) error {
+	known := make(map[string]bool)
+	for _, c := range catalogCSVColumns {
+		known[c] = true
+	}
+	seen := make(map[string]bool)
+	for _, c := range header {
+		if !known[c] && !(strings.HasPrefix(c, catalogCSVPricePrefix) && len(c) > len(catalogCSVPricePrefix)) {
+			return &CatalogFormatError{Message: fmt.Sprintf("csv column %q unknown", c)}
+		}
+		if seen[c] {
+			return &CatalogFormatError{Message: fmt.Sprintf("csv column %q appears more than once", c)}
+		}
+		seen[c] = true
+	}
+	if !seen["sku"] {
+		return &CatalogFormatError{Message: "csv column sku missing"}
+	}
+	return nil
+}
+
+// catalogItemFromCSV returns the item of a CSV record along with any
+// messages describing invalid fields. Empty fields other than the sku
+// are left unset.
+func catalogItemFromCSV(header, record []string) (*CatalogItem, []string) {
+	var item CatalogItem
+	var messages []string
+	for i, c := range header {
+		v := record[i]
+		if c == "sku" {
+			item.SKU = strings.TrimSpace(v)
+			continue
+		}
+		if v == "" {
+			continue
+		}
+		s := v
+		switch c {
+		case "parent_sku":
+			s = strings.TrimSpace(s)
+			item.ParentSKU = &s
+		case "status":
+			s = strings.TrimSpace(s)
+			item.Status = &s
+		case "path":
+			item.Path = &s
+		case "name":
+			item.Name = &s
+		case "tax_code":
+			item.TaxCode = &s
+		case "description":
+			item.Description = &s
+		case "meta_title":
+			item.MetaTitle = &s
+		case "meta_description":
+			item.MetaDescription = &s
+		case "attributes":
+			if err := json.Unmarshal([]byte(v), &item.Attributes); err != nil || item.Attributes == nil {
+				messages = append(messages, "attributes must be a JSON object")
+			}
+		case "options":
+			if err := json.Unmarshal([]byte(v), &item.Options); err != nil || item.Options == nil {
+				messages = append(messages, "options must be a JSON object of strings")
+			}
+		case "onhand":
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				messages = append(messages, "onhand must be an integer")
+				continue
+			}
+			item.Onhand = &n
+		case "categories":
+			item.Categories = splitCatalogList(v)
+		case "images":
+			item.Images = splitCatalogList(v)
+		default:
+			code := strings.TrimPrefix(c, catalogCSVPricePrefix)
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				messages = append(messages, fmt.Sprintf("%s must be an integer", c))
+				continue
+			}
+			if item.Prices == nil {
+				item.Prices = make(map[string]int)
+			}
+			item.Prices[code] = n
+		}
+	}
+	return &item, messages
+}
+
+func splitCatalogList(v string) []string {
+	list := make([]string, 0, 4)
+	for _, s := range strings.Split(v, catalogCSVListSeparator) {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func decodeCatalogJSONL(r io.Reader) ([]*CatalogItem, []*CatalogImportRowError, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxCatalogJSONLLineSize)
+
+	items := make([]*CatalogItem, 0, 256)
+	var rowErrors []*CatalogImportRowError
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row := len(items) + 1
+
+		var item CatalogItem
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&item); err != nil {
+			rowErrors = append(rowErrors, &CatalogImportRowError{Row: row, Message: err.Error()})
+			items = append(items, nil)
+			continue
+		}
+		items = append(items, &item)
+	}
+	if err := sc.Err(); err == bufio.ErrTooLong {
+		return nil, nil, &CatalogFormatError{Message: fmt.Sprintf("line %d longer than %d bytes", len(items)+1, maxCatalogJSONLLineSize)}
+	} else if err != nil {
+		return nil, nil, errors.Wrap(err, "service: scan of catalog import failed")
+	}
+	return items, rowErrors, nil
+}
+
+// catalogWriter writes the items of a catalog export.
+type catalogWriter interface {
+	Write(item *CatalogItem) error
+	Flush() error
+}
+
+// newCatalogWriter returns a catalog writer for the given format. CSV
+// exports have a price column for each of the priceListCodes.
+func newCatalogWriter(format string, w io.Writer, priceListCodes []string) (catalogWriter, error) {
+	if format == CatalogFormatJSONL {
+		return &catalogJSONLWriter{enc: json.NewEncoder(w)}, nil
+	}
+	cw := catalogCSVWriter{w: csv.NewWriter(w), priceListCodes: priceListCodes}
+	header := append([]string{}, catalogCSVColumns...)
+	for _, code := range priceListCodes {
+		header = append(header, catalogCSVPricePrefix+code)
+	}
+	if err := cw.w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "service: write of csv header failed")
+	}
+	return &cw, nil
+}
+
+type catalogCSVWriter struct {
+	w              *csv.Writer
+	priceListCodes []string
+}
+
+func (cw *catalogCSVWriter) Write(item *CatalogItem) error {
+	attributes := ""
+	if item.Attributes != nil {
+		b, err := json.Marshal(item.Attributes)
+		if err != nil {
+			return errors.Wrap(err, "service: json marshal of attributes failed")
+		}
+		attributes = string(b)
+	}
+	options := ""
+	if item.Options != nil {
+		b, err := json.Marshal(item.Options)
+		if err != nil {
+			return errors.Wrap(err, "service: json marshal of options failed")
+		}
+		options = string(b)
+	}
+	onhand := ""
+	if item.Onhand != nil {
+		onhand = strconv.Itoa(*item.Onhand)
+	}
+	record := []string{
+		item.SKU, stringValue(item.ParentSKU), stringValue(item.Status),
+		stringValue(item.Path), stringValue(item.Name), stringValue(item.TaxCode),
+		stringValue(item.Description), stringValue(item.MetaTitle), stringValue(item.MetaDescription),
+		attributes, options, onhand,
+		strings.Join(item.Categories, catalogCSVListSeparator),
+		strings.Join(item.Images, catalogCSVListSeparator),
+	}
+	for _, code := range cw.priceListCodes {
+		price := ""
+		if p, ok := item.Prices[code]; ok {
+			price = strconv.Itoa(p)
+		}
+		record = append(record, price)
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *catalogCSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type catalogJSONLWriter struct {
+	enc *json.Encoder
+}
+
+func (jw *catalogJSONLWriter) Write(item *CatalogItem) error {
+	return jw.enc.Encode(item)
+}
+
+func (jw *catalogJSONLWriter) Flush() error {
+	return nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,255 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// catalogImportChunkSize is the number of rows of a catalog import
+// applied in each transaction.
+const catalogImportChunkSize = 100
+
+// ErrCatalogImportNotFound error
+var ErrCatalogImportNotFound = errors.New("service: catalog import not found")
+
+// ErrCatalogImportNotResumable is returned when resuming an import that
+// is invalid or has already completed.
+var ErrCatalogImportNotResumable = errors.New("service: catalog import not resumable")
+
+// CatalogImportRowError describes why a row of a catalog import is
+// invalid. Row is the 1-based row number not counting the CSV header.
+type CatalogImportRowError struct {
+	Row     int    `json:"row"`
+	SKU     string `json:"sku"`
+	Message string `json:"message"`
+}
+
+// CatalogImport is a bulk import of catalog items. Status is invalid if
+// any row failed validation in which case nothing is applied. Otherwise
+// the rows are applied in chunks and ProcessedRows counts the rows
+// applied. A failed import has an ErrorMessage and can be resumed.
+type CatalogImport struct {
+	Object        string                   `json:"object"`
+	ID            string                   `json:"id"`
+	Format        string                   `json:"format"`
+	Status        string                   `json:"status"`
+	TotalRows     int                      `json:"total_rows"`
+	ProcessedRows int                      `json:"processed_rows"`
+	Errors        []*CatalogImportRowError `json:"errors"`
+	ErrorMessage  *string                  `json:"error_message"`
+	Created       time.Time                `json:"created"`
+	Modified      time.Time                `json:"modified"`
+}
+
+func catalogImportFromRow(row *postgres.CatalogImportRow) *CatalogImport {
+	rowErrors := make([]*CatalogImportRowError, 0, len(row.Errors))
+	for _, e := range row.Errors {
+		rowErrors = append(rowErrors, &CatalogImportRowError{Row: e.Row, SKU: e.SKU, Message: e.Message})
+	}
+	return &CatalogImport{
+		Object:        "catalog_import",
+		ID:            row.UUID,
+		Format:        row.Format,
+		Status:        row.Status,
+		TotalRows:     row.Total,
+		ProcessedRows: row.Processed,
+		Errors:        rowErrors,
+		ErrorMessage:  row.ErrorMessage,
+		Created:       row.Created,
+		Modified:      row.Modified,
+	}
+}
+
+// catalogItemErrorMessages holds the messages of the causes of a row of
+// an import failing to apply.
+var catalogItemErrorMessages = map[error]string{
+	postgres.ErrProductNotFound:          "product not found and path or name not set",
+	postgres.ErrProductPathExists:        "path is used by another product",
+	postgres.ErrProductSKUExists:         "sku is used by another product",
+	postgres.ErrPriceListNotFound:        "price list not found",
+	postgres.ErrDefaultPriceListNotFound: "default price list not found",
+	postgres.ErrLeafCategoryNotFound:     "leaf category not found",
+	postgres.ErrInventoryNotFound:        "inventory not found",
+	postgres.ErrLocationNotFound:         "default location not found",
+	postgres.ErrUserNotFound:             "user not found",
+	postgres.ErrTaxCodeNotFound:          "tax code has no tax rates",
+	postgres.ErrParentProductNotFound:    "parent product not found",
+	postgres.ErrParentSKUChanged:         "parent_sku of an existing product cannot be changed",
+	postgres.ErrProductIsVariant:         "parent product is a variant",
+	postgres.ErrOptionTypeNotFound:       "option type not found",
+	postgres.ErrVariantOptionsExist:      "another variant has the same options",
+}
+
+// catalogImportJob is the payload of a catalog import job.
+type catalogImportJob struct {
+	CatalogImportID string `json:"catalog_import_id"`
+}
+
+// ImportCatalog reads and validates every row of a catalog import in the
+// given format before applying them. Imports with invalid rows are
+// stored with the row errors and nothing is applied. Changes to stock
+// are recorded against the user with the given userID.
+func (s *Service) ImportCatalog(ctx context.Context, userID, format string, r io.Reader) (*CatalogImport, error) {
+	row, err := s.createCatalogImport(ctx, format, r)
+	if err != nil {
+		return nil, err
+	}
+	if row.Status == postgres.CatalogImportStatusInvalid {
+		return catalogImportFromRow(row), nil
+	}
+	return s.applyCatalogImport(ctx, userID, row.UUID, nil)
+}
+
+// ImportCatalogAsync reads and validates every row of a catalog import
+// and creates a job to apply the rows in the background. Invalid imports
+// are returned without a job. The result of the job is the import.
+func (s *Service) ImportCatalogAsync(ctx context.Context, userID, format string, r io.Reader) (*CatalogImport, *Job, error) {
+	row, err := s.createCatalogImport(ctx, format, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if row.Status == postgres.CatalogImportStatusInvalid {
+		return catalogImportFromRow(row), nil, nil
+	}
+	job, err := s.enqueueJob(ctx, JobTypeCatalogImport, userID, &catalogImportJob{CatalogImportID: row.UUID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return catalogImportFromRow(row), job, nil
+}
+
+// createCatalogImport reads, validates and stores a catalog import.
+func (s *Service) createCatalogImport(ctx context.Context, format string, r io.Reader) (*postgres.CatalogImportRow, error) {
+	contextLogger := log.WithContext(ctx)
+
+	items, rowErrors, err := decodeCatalog(format, r)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := s.getCatalogRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rowErrors = append(rowErrors, validateCatalogItems(items, refs)...)
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+	contextLogger.Infof("service: catalog import of %d rows has %d errors", len(items), len(rowErrors))
+
+	status := postgres.CatalogImportStatusPending
+	if len(rowErrors) > 0 {
+		status = postgres.CatalogImportStatusInvalid
+	}
+	itemRows := make([]*postgres.CatalogItemRow, 0, len(items))
+	for _, item := range items {
+		itemRows = append(itemRows, item.row())
+	}
+	errorRows := make([]*postgres.CatalogImportErrorRow, 0, len(rowErrors))
+	for _, e := range rowErrors {
+		errorRows = append(errorRows, &postgres.CatalogImportErrorRow{Row: e.Row, SKU: e.SKU, Message: e.Message})
+	}
+	row, err := s.model.CreateCatalogImport(ctx, format, status, itemRows, errorRows)
+	if err != nil {
+		return nil, errors.Wrap(err, "service: s.model.CreateCatalogImport(ctx, ...) failed")
+	}
+	return row, nil
+}
+
+// GetCatalogImport returns the catalog import with the given id.
+func (s *Service) GetCatalogImport(ctx context.Context, catalogImportID string) (*CatalogImport, error) {
+	row, err := s.model.GetCatalogImport(ctx, catalogImportID)
+	if err == postgres.ErrCatalogImportNotFound {
+		return nil, ErrCatalogImportNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetCatalogImport(ctx, catalogImportUUID=%q) failed", catalogImportID)
+	}
+	return catalogImportFromRow(row), nil
+}
+
+// ResumeCatalogImport applies the remaining rows of a catalog import that
+// failed or was interrupted.
+func (s *Service) ResumeCatalogImport(ctx context.Context, userID, catalogImportID string) (*CatalogImport, error) {
+	return s.applyCatalogImport(ctx, userID, catalogImportID, nil)
+}
+
+// ResumeCatalogImportAsync creates a job to apply the remaining rows of
+// a catalog import in the background.
+func (s *Service) ResumeCatalogImportAsync(ctx context.Context, userID, catalogImportID string) (*Job, error) {
+	catalogImport, err := s.GetCatalogImport(ctx, catalogImportID)
+	if err != nil {
+		return nil, err
+	}
+	if catalogImport.Status == postgres.CatalogImportStatusInvalid || catalogImport.Status == postgres.CatalogImportStatusCompleted {
+		return nil, ErrCatalogImportNotResumable
+	}
+	return s.enqueueJob(ctx, JobTypeCatalogImport, userID, &catalogImportJob{CatalogImportID: catalogImportID})
+}
+
+func (s *Service) runCatalogImport(ctx context.Context, j *jobRun) (interface{}, error) {
+	var payload catalogImportJob
+	if err := json.Unmarshal(j.payload, &payload); err != nil {
+		return nil, errors.Wrap(err, "service: json unmarshal of catalog import job failed")
+	}
+	catalogImport, err := s.applyCatalogImport(ctx, j.userID, payload.CatalogImportID, j.progress)
+	if err != nil {
+		return nil, err
+	}
+	if catalogImport.Status == postgres.CatalogImportStatusFailed {
+		return catalogImport, &JobError{Code: "catalog-imports/catalog-import-failed", Message: *catalogImport.ErrorMessage}
+	}
+	return catalogImport, nil
+}
+
+// applyCatalogImport applies the unprocessed rows of a catalog import a
+// chunk at a time calling progress if set after each chunk. If a chunk
+// fails the import is marked as failed and returned so it can be
+// resumed later. If ctx is cancelled the import is left as it is.
+func (s *Service) applyCatalogImport(ctx context.Context, userID, catalogImportID string, progress func(ctx context.Context, processed, total int) error) (*CatalogImport, error) {
+	contextLogger := log.WithContext(ctx)
+	for {
+		row, alerts, err := s.model.ApplyCatalogImportChunk(ctx, catalogImportID, catalogImportChunkSize, optionalString(userID))
+		if err == postgres.ErrCatalogImportNotFound {
+			return nil, ErrCatalogImportNotFound
+		}
+		if err == postgres.ErrCatalogImportNotResumable {
+			return nil, ErrCatalogImportNotResumable
+		}
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			message := "internal error"
+			if ierr, ok := err.(*postgres.CatalogItemError); ok {
+				if m, ok := catalogItemErrorMessages[ierr.Err]; ok {
+					message = m
+				}
+				message = fmt.Sprintf("row %d sku %q: %s", ierr.Row, ierr.SKU, message)
+			}
+			contextLogger.Errorf("service: s.model.ApplyCatalogImportChunk(ctx, catalogImportUUID=%q, ...) failed: %+v", catalogImportID, err)
+
+			row, ferr := s.model.FailCatalogImport(ctx, catalogImportID, message)
+			if ferr != nil {
+				return nil, errors.Wrapf(ferr, "service: s.model.FailCatalogImport(ctx, catalogImportUUID=%q, ...) failed", catalogImportID)
+			}
+			return catalogImportFromRow(row), nil
+		}
+		if err := s.publishStockAlerts(ctx, alerts); err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			if err := progress(ctx, row.Processed, row.Total); err != nil {
+				return nil, err
+			}
+		}
+		if row.Status == postgres.CatalogImportStatusCompleted {
+			return catalogImportFromRow(row), nil
+		}
+	}
+}
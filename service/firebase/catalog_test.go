@@ -0,0 +1,226 @@
+package firebase
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/stretchr/testify/assert"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestDecodeCatalogCSV(t *testing.T) {
+	in := "sku,name,onhand,categories,price:default,attributes\n" +
+		"MUG-1,Mug,12,/kitchen/mugs|/gifts,1299,\"{\"\"material\"\":\"\"steel\"\"}\"\n" +
+		"MUG-2,,x,,,\n" +
+		"MUG-3\n"
+	items, rowErrors, err := decodeCatalog(CatalogFormatCSV, strings.NewReader(in))
+	assert.NoError(t, err)
+	if !assert.Len(t, items, 3) {
+		return
+	}
+
+	mug := items[0]
+	assert.Equal(t, "MUG-1", mug.SKU)
+	assert.Equal(t, "Mug", *mug.Name)
+	assert.Nil(t, mug.Path)
+	assert.Equal(t, 12, *mug.Onhand)
+	assert.Equal(t, []string{"/kitchen/mugs", "/gifts"}, mug.Categories)
+	assert.Equal(t, map[string]int{"default": 1299}, mug.Prices)
+	assert.Equal(t, map[string]interface{}{"material": "steel"}, mug.Attributes)
+	assert.Nil(t, mug.Images)
+
+	assert.Nil(t, items[1])
+	assert.Nil(t, items[2])
+	if assert.Len(t, rowErrors, 2) {
+		assert.Equal(t, &CatalogImportRowError{Row: 2, SKU: "MUG-2", Message: "onhand must be an integer"}, rowErrors[0])
+		assert.Equal(t, 3, rowErrors[1].Row)
+	}
+
+	_, _, err = decodeCatalog(CatalogFormatCSV, strings.NewReader("sku,colour\n"))
+	assert.IsType(t, &CatalogFormatError{}, err)
+
+	_, _, err = decodeCatalog(CatalogFormatCSV, strings.NewReader("name,path\n"))
+	assert.IsType(t, &CatalogFormatError{}, err)
+
+	_, _, err = decodeCatalog(CatalogFormatCSV, strings.NewReader(""))
+	assert.IsType(t, &CatalogFormatError{}, err)
+}
+
+func TestDecodeCatalogJSONL(t *testing.T) {
+	in := `{"sku":"MUG-1","path":"mug","name":"Mug","prices":{"default":1299},"categories":[]}
+
+{"sku":"MUG-2","colour":"red"}
+`
+	items, rowErrors, err := decodeCatalog(CatalogFormatJSONL, strings.NewReader(in))
+	assert.NoError(t, err)
+	if !assert.Len(t, items, 2) {
+		return
+	}
+	assert.Equal(t, "mug", *items[0].Path)
+	assert.Equal(t, map[string]int{"default": 1299}, items[0].Prices)
+	assert.Equal(t, []string{}, items[0].Categories)
+	assert.Nil(t, items[0].Images)
+	assert.Nil(t, items[1])
+	if assert.Len(t, rowErrors, 1) {
+		assert.Equal(t, 2, rowErrors[0].Row)
+	}
+}
+
+func TestCatalogWriterRoundTrip(t *testing.T) {
+	onhand := 4
+	item := &CatalogItem{
+		SKU:             "MUG-1",
+		ParentSKU:       strPtr("MUG"),
+		Options:         map[string]string{"size": "large"},
+		Status:          strPtr("active"),
+		Path:            strPtr("mug"),
+		Name:            strPtr("Mug, large"),
+		TaxCode:         strPtr("T20"),
+		Description:     strPtr("A \"large\" mug"),
+		Attributes:      map[string]interface{}{"material": "steel"},
+		MetaTitle:       strPtr(""),
+		MetaDescription: strPtr(""),
+		Prices:          map[string]int{"default": 1299},
+		Onhand:          &onhand,
+		Categories:      []string{"/kitchen/mugs"},
+		Images:          []string{"images/mug-1.jpg", "images/mug-2.jpg"},
+	}
+
+	for _, format := range []string{CatalogFormatCSV, CatalogFormatJSONL} {
+		var buf bytes.Buffer
+		cw, err := newCatalogWriter(format, &buf, []string{"default", "trade"})
+		assert.NoError(t, err)
+		assert.NoError(t, cw.Write(item))
+		assert.NoError(t, cw.Flush())
+
+		items, rowErrors, err := decodeCatalog(format, &buf)
+		assert.NoError(t, err)
+		assert.Empty(t, rowErrors)
+		if assert.Len(t, items, 1) {
+			got := items[0]
+			assert.Equal(t, item.SKU, got.SKU, format)
+			assert.Equal(t, *item.ParentSKU, *got.ParentSKU, format)
+			assert.Equal(t, item.Options, got.Options, format)
+			assert.Equal(t, *item.Status, *got.Status, format)
+			assert.Equal(t, *item.Name, *got.Name, format)
+			assert.Equal(t, *item.Description, *got.Description, format)
+			assert.Equal(t, item.Attributes, got.Attributes, format)
+			assert.Equal(t, item.Prices, got.Prices, format)
+			assert.Equal(t, 4, *got.Onhand, format)
+			assert.Equal(t, item.Categories, got.Categories, format)
+			assert.Equal(t, item.Images, got.Images, format)
+		}
+	}
+}
+
+func TestValidateCatalogItems(t *testing.T) {
+	refs := &catalogRefs{
+		products: map[string]*postgres.CatalogProductRef{
+			"MUG-1": {Path: "mug", Options: map[string]string{}},
+			"CUP-1": {Path: "cup", Options: map[string]string{}},
+		},
+		priceLists:  map[string]bool{"default": true},
+		categories:  map[string]bool{"/kitchen/mugs": true},
+		optionTypes: map[string]bool{"colour": true},
+		attributes:  []*postgres.ProductAttributeRow{{Code: "material", Typ: "string"}},
+	}
+	negative := -1
+
+	// updates to existing products only need a sku.
+	assert.Empty(t, validateCatalogItems([]*CatalogItem{
+		{SKU: "MUG-1", Prices: map[string]int{"default": 999}, Categories: []string{"/kitchen/mugs"}},
+		{SKU: "BOWL-1", Path: strPtr("bowl"), Name: strPtr("Bowl")},
+		nil,
+	}, refs))
+
+	rowErrors := validateCatalogItems([]*CatalogItem{
+		{SKU: "MUG-1", Prices: map[string]int{"trade": 999}},
+		{SKU: "BOWL-1", Name: strPtr("Bowl")},
+		{SKU: "MUG-1"},
+		{SKU: "CUP-1", Path: strPtr("mug")},
+		{SKU: "PLATE-1", Path: strPtr("plate"), Name: strPtr("Plate"), Onhand: &negative,
+			Categories: []string{"/kitchen"}, Attributes: map[string]interface{}{"colour": "red"}},
+		{SKU: ""},
+	}, refs)
+
+	messages := make([]string, 0, len(rowErrors))
+	rows := make([]int, 0, len(rowErrors))
+	for _, e := range rowErrors {
+		messages = append(messages, e.Message)
+		rows = append(rows, e.Row)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 5, 5, 6}, rows)
+	assert.Equal(t, []string{
+		`price list "trade" not found`,
+		"path and name must be set for new products",
+		"sku already appears in row 1",
+		`path "mug" is used by product "MUG-1"`,
+		`attribute "colour" is not a defined product attribute`,
+		"onhand must not be negative",
+		`leaf category "/kitchen" not found`,
+		"sku must be set",
+	}, messages)
+
+	// a path is free once its product has moved in an earlier row.
+	assert.Empty(t, validateCatalogItems([]*CatalogItem{
+		{SKU: "MUG-1", Path: strPtr("large-mug")},
+		{SKU: "CUP-1", Path: strPtr("mug")},
+	}, refs))
+	assert.Len(t, validateCatalogItems([]*CatalogItem{
+		{SKU: "MUG-1", Path: strPtr("cup")},
+		{SKU: "CUP-1", Path: strPtr("mug")},
+	}, refs), 2)
+}
+
+func TestValidateCatalogVariants(t *testing.T) {
+	refs := &catalogRefs{
+		products: map[string]*postgres.CatalogProductRef{
+			"MUG":     {Path: "mug", Options: map[string]string{}},
+			"MUG-RED": {Path: "mug-red", ParentSKU: strPtr("MUG"), Options: map[string]string{"colour": "red"}},
+		},
+		optionTypes: map[string]bool{"colour": true},
+	}
+
+	// variants may follow a new parent in an earlier row.
+	assert.Empty(t, validateCatalogItems([]*CatalogItem{
+		{SKU: "MUG-BLUE", ParentSKU: strPtr("MUG"), Options: map[string]string{"colour": "blue"},
+			Path: strPtr("mug-blue"), Name: strPtr("Blue Mug"), Status: strPtr("active")},
+		{SKU: "MUG-RED", ParentSKU: strPtr("MUG"), Options: map[string]string{"colour": "red"}},
+		{SKU: "CUP", Path: strPtr("cup"), Name: strPtr("Cup")},
+		{SKU: "CUP-RED", ParentSKU: strPtr("CUP"), Options: map[string]string{"colour": "red"},
+			Path: strPtr("cup-red"), Name: strPtr("Red Cup")},
+	}, refs))
+
+	rowErrors := validateCatalogItems([]*CatalogItem{
+		{SKU: "BOWL-RED", ParentSKU: strPtr("BOWL"), Options: map[string]string{"colour": "red"},
+			Path: strPtr("bowl-red"), Name: strPtr("Red Bowl")},
+		{SKU: "BOWL", Path: strPtr("bowl"), Name: strPtr("Bowl"), Status: strPtr("live")},
+		{SKU: "MUG-PINK", ParentSKU: strPtr("MUG-RED"), Options: map[string]string{"size": "large"},
+			Path: strPtr("mug-pink"), Name: strPtr("Pink Mug")},
+		{SKU: "MUG-RED-2", ParentSKU: strPtr("MUG"), Options: map[string]string{"colour": "red"},
+			Path: strPtr("mug-red-2"), Name: strPtr("Red Mug")},
+		{SKU: "MUG", ParentSKU: strPtr("CUP")},
+		{SKU: "MUG-RED", Options: map[string]string{"colour": "blue"}},
+		{SKU: "PLATE", ParentSKU: strPtr("MUG"), Path: strPtr("plate"), Name: strPtr("Plate")},
+		{SKU: "DISH", Options: map[string]string{"colour": "red"}, Path: strPtr("dish"), Name: strPtr("Dish")},
+	}, refs)
+
+	messages := make([]string, 0, len(rowErrors))
+	for _, e := range rowErrors {
+		messages = append(messages, e.Message)
+	}
+	assert.Equal(t, []string{
+		`parent product "BOWL" not found in the catalog or an earlier row`,
+		"status must be one of draft, active or archived",
+		`parent product "MUG-RED" is a variant`,
+		`option type "size" not found`,
+		`another variant of "MUG" has the same options`,
+		"parent_sku of an existing product cannot be changed",
+		"options of an existing product cannot be changed",
+		"options must be set for variants",
+		"options can only be set for variants",
+	}, messages)
+}
@@ -2,6 +2,7 @@ package firebase
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -32,6 +33,42 @@ var ErrCategoriesInUse = errors.New("service: categories in use")
 // ErrCategoriesEmpty error
 var ErrCategoriesEmpty = errors.New("service: categories empty")
 
+// ErrCategoryPathExists error
+var ErrCategoryPathExists = errors.New("service: category path exists")
+
+// ErrCategoryHasProducts is returned when adding children to or deleting
+// a leaf category that has products.
+var ErrCategoryHasProducts = errors.New("service: category has products")
+
+// ErrCategoryRootExists is returned when creating a category without a
+// parent once the root category exists.
+var ErrCategoryRootExists = errors.New("service: category root exists")
+
+// ErrCategoryMoveInvalid is returned when moving the root category or
+// moving a category under itself or one of its descendants.
+var ErrCategoryMoveInvalid = errors.New("service: category move invalid")
+
+// Category product sort values. Manual uses the order set by
+// ReorderCategoryProducts.
+const (
+	CategoryProductSortManual      = postgres.CategoryProductSortManual
+	CategoryProductSortPriceAsc    = postgres.CategoryProductSortPriceAsc
+	CategoryProductSortPriceDesc   = postgres.CategoryProductSortPriceDesc
+	CategoryProductSortNewest      = postgres.CategoryProductSortNewest
+	CategoryProductSortBestselling = postgres.CategoryProductSortBestselling
+)
+
+// IsValidCategoryProductSort returns true if sort is a known category
+// product sort.
+func IsValidCategoryProductSort(sort string) bool {
+	switch sort {
+	case CategoryProductSortManual, CategoryProductSortPriceAsc, CategoryProductSortPriceDesc,
+		CategoryProductSortNewest, CategoryProductSortBestselling:
+		return true
+	}
+	return false
+}
+
 // CategoryList is a container for a list of category objects
 type CategoryList struct {
 	Object string          `json:"object"`
@@ -51,31 +88,56 @@ type CategoryRequest struct {
 
 // A CategoryNode represents an individual category in the catalog hierarchy.
 type CategoryNode struct {
-	Object   string `json:"object,omitempty"`
-	ID       string `json:"id,omitempty"`
-	Segment  string `json:"segment"`
-	path     string
-	Name     string `json:"name"`
-	lft      int
-	rgt      int
-	depth    int
-	parent   *CategoryNode
-	Nodes    *CategoryList `json:"categories"`
-	Products *ProductList  `json:"products,omitempty"`
+	Object          string `json:"object,omitempty"`
+	ID              string `json:"id,omitempty"`
+	Segment         string `json:"segment"`
+	path            string
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	HeroImage       string `json:"hero_image"`
+	MetaTitle       string `json:"meta_title"`
+	MetaDescription string `json:"meta_description"`
+	Hidden          bool   `json:"hidden"`
+	ProductSort     string `json:"product_sort"`
+	lft             int
+	rgt             int
+	depth           int
+	parent          *CategoryNode
+	Nodes           *CategoryList `json:"categories"`
+	Products        *ProductList  `json:"products,omitempty"`
 }
 
 // Category represents a single entry from the nested set
 type Category struct {
-	Object   string `json:"object"`
-	ID       string `json:"id"`
-	Segment  string `json:"segment"`
-	Path     string `json:"path"`
-	Name     string `json:"name"`
-	Lft      int    `json:"lft"`
-	Rgt      int    `json:"rgt"`
-	Depth    int    `json:"depth"`
-	Created  time.Time
-	Modified time.Time
+	Object          string `json:"object"`
+	ID              string `json:"id"`
+	Segment         string `json:"segment"`
+	Path            string `json:"path"`
+	Name            string `json:"name"`
+	Lft             int    `json:"lft"`
+	Rgt             int    `json:"rgt"`
+	Depth           int    `json:"depth"`
+	Description     string `json:"description"`
+	HeroImage       string `json:"hero_image"`
+	MetaTitle       string `json:"meta_title"`
+	MetaDescription string `json:"meta_description"`
+	Hidden          bool   `json:"hidden"`
+	ProductSort     string `json:"product_sort"`
+	Created         time.Time
+	Modified        time.Time
+}
+
+// CategoryUpdate contains the changes to a category. Nil values are left
+// unchanged.
+type CategoryUpdate struct {
+	Segment         *string
+	Name            *string
+	Description     *string
+	HeroImage       *string
+	MetaTitle       *string
+	MetaDescription *string
+	Hidden          *bool
+	ProductSort     *string
 }
 
 // AddChild attaches a Category to its parent Category.
@@ -208,6 +270,33 @@ func (s *Service) UpdateCatalog(ctx context.Context, root *CategoryRequest) erro
 	return nil
 }
 
+// UpdateCatalogAsync checks the categories tree can be replaced and
+// creates a job to replace it in the background.
+func (s *Service) UpdateCatalogAsync(ctx context.Context, userID string, root *CategoryRequest) (*Job, error) {
+	hasAssocs, err := s.HasProductCategoryRelations(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "HasProductCategoryRelations(ctx) error")
+	}
+	if hasAssocs {
+		return nil, ErrAssocsAlreadyExist
+	}
+	return s.enqueueJob(ctx, JobTypeUpdateCategoriesTree, userID, root)
+}
+
+func (s *Service) runUpdateCategoriesTree(ctx context.Context, j *jobRun) (interface{}, error) {
+	var root CategoryRequest
+	if err := json.Unmarshal(j.payload, &root); err != nil {
+		return nil, errors.Wrap(err, "service: json unmarshal of categories tree failed")
+	}
+	if err := j.progress(ctx, 0, 1); err != nil {
+		return nil, err
+	}
+	if err := s.UpdateCatalog(ctx, &root); err != nil {
+		return nil, err
+	}
+	return nil, j.progress(ctx, 1, 1)
+}
+
 func (n *CategoryNode) addChild(c *CategoryNode) {
 	n.Nodes.Data = append(n.Nodes.Data, c)
 }
@@ -251,12 +340,18 @@ func (n *CategoryNode) FindNodeByPath(path string) *CategoryNode {
 // BuildTree builds a Tree hierarchy from a Nested Set.
 func BuildTree(nestedset []*postgres.CategoryRow, cmap map[string][]*Product) *CategoryNode {
 	context := &CategoryNode{
-		Object:  "category",
-		ID:      nestedset[0].UUID,
-		Segment: nestedset[0].Segment,
-		path:    nestedset[0].Path,
-		Name:    nestedset[0].Name,
-		parent:  nil,
+		Object:          "category",
+		ID:              nestedset[0].UUID,
+		Segment:         nestedset[0].Segment,
+		path:            nestedset[0].Path,
+		Name:            nestedset[0].Name,
+		Description:     nestedset[0].Description,
+		HeroImage:       nestedset[0].HeroImage,
+		MetaTitle:       nestedset[0].MetaTitle,
+		MetaDescription: nestedset[0].MetaDescription,
+		Hidden:          nestedset[0].Hidden,
+		ProductSort:     nestedset[0].ProductSort,
+		parent:          nil,
 		Nodes: &CategoryList{
 			Object: "list",
 			Data:   make([]*CategoryNode, 0),
@@ -284,12 +379,18 @@ func BuildTree(nestedset []*postgres.CategoryRow, cmap map[string][]*Product) *C
 			products = nil
 		}
 		n := &CategoryNode{
-			Object:  "category",
-			ID:      cur.UUID,
-			Segment: cur.Segment,
-			path:    cur.Path,
-			Name:    cur.Name,
-			parent:  context,
+			Object:          "category",
+			ID:              cur.UUID,
+			Segment:         cur.Segment,
+			path:            cur.Path,
+			Name:            cur.Name,
+			Description:     cur.Description,
+			HeroImage:       cur.HeroImage,
+			MetaTitle:       cur.MetaTitle,
+			MetaDescription: cur.MetaDescription,
+			Hidden:          cur.Hidden,
+			ProductSort:     cur.ProductSort,
+			parent:          context,
 			Nodes: &CategoryList{
 				Object: "list",
 				Data:   make([]*CategoryNode, 0),
@@ -342,6 +443,36 @@ func BuildTree(nestedset []*postgres.CategoryRow, cmap map[string][]*Product) *C
 	return context
 }
 
+// removeHidden removes the hidden descendants of n.
+func (n *CategoryNode) removeHidden() {
+	visible := n.Nodes.Data[:0]
+	for _, c := range n.Nodes.Data {
+		if !c.Hidden {
+			c.removeHidden()
+			visible = append(visible, c)
+		}
+	}
+	n.Nodes.Data = visible
+}
+
+// visibleCategoryRows returns the rows of a nested set that are not
+// hidden or the descendant of a hidden category.
+func visibleCategoryRows(rows []*postgres.CategoryRow) []*postgres.CategoryRow {
+	visible := make([]*postgres.CategoryRow, 0, len(rows))
+	hiddenRgt := 0
+	for _, row := range rows {
+		if row.Lft < hiddenRgt {
+			continue
+		}
+		if row.Hidden {
+			hiddenRgt = row.Rgt
+			continue
+		}
+		visible = append(visible, row)
+	}
+	return visible
+}
+
 // HasCatalog returns true if the catalog exists.
 func (s *Service) HasCatalog(ctx context.Context) (bool, error) {
 	has, err := s.model.HasCatalog(ctx)
@@ -351,20 +482,37 @@ func (s *Service) HasCatalog(ctx context.Context) (bool, error) {
 	return has, nil
 }
 
-// GetCategoriesTree returns a tree of all categories as a hierarchy of nodes.
-func (s *Service) GetCategoriesTree(ctx context.Context) (*CategoryNode, error) {
+// GetCategoriesTree returns a tree of all categories as a hierarchy of
+// nodes. The products of each leaf category are in the product sort of
+// the category with prices taken from the price list of the user. If
+// visibleOnly is true hidden categories and their descendants and
+// unpublished products are left out.
+func (s *Service) GetCategoriesTree(ctx context.Context, userID string, visibleOnly bool) (*CategoryNode, error) {
 	log.WithContext(ctx).Debug("service: GetCatalog started")
 	ns, err := s.model.GetCategories(ctx)
 	if err != nil {
 		return nil, errors.Wrapf(err, "s.model.GetCategories(ctx) failed")
 	}
-	if len(ns) == 0 {
+	if len(ns) == 0 || (visibleOnly && ns[0].Hidden) {
 		log.WithContext(ctx).Debug("service: s.model.GetCategories(ctx) returned an empty list")
 		return nil, ErrCategoriesEmpty
 	}
-	cpas, err := s.model.GetProductCategoryRelationsFull(ctx)
+
+	// The price list is only needed to sort by price.
+	var priceListID *string
+	for _, c := range ns {
+		if c.ProductSort == CategoryProductSortPriceAsc || c.ProductSort == CategoryProductSortPriceDesc {
+			id, err := s.userPriceListID(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			priceListID = &id
+			break
+		}
+	}
+	cpas, err := s.model.GetCategoryProducts(ctx, priceListID, visibleOnly)
 	if err != nil {
-		return nil, errors.Wrapf(err, "service: GetProductCategoryAssocsFull(ctx) failed")
+		return nil, errors.Wrapf(err, "service: s.model.GetCategoryProducts(ctx, priceListUUID=%v) failed", priceListID)
 	}
 
 	// convert slice into map
@@ -381,6 +529,9 @@ func (s *Service) GetCategoriesTree(ctx context.Context) (*CategoryNode, error)
 		})
 	}
 	tree := BuildTree(ns, cmap)
+	if visibleOnly {
+		tree.removeHidden()
+	}
 	return tree, nil
 }
 
@@ -396,28 +547,128 @@ func (s *Service) DeleteCategories(ctx context.Context) error {
 
 // Category raw nested set
 
-// GetCategories returns a list of categories.
-func (s *Service) GetCategories(ctx context.Context) ([]*Category, error) {
+// GetCategories returns a list of categories. If visibleOnly is true
+// hidden categories and their descendants are left out.
+func (s *Service) GetCategories(ctx context.Context, visibleOnly bool) ([]*Category, error) {
 	cats, err := s.model.GetCategories(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "service: s.model.GetCategories(ctx) failed")
 	}
+	if visibleOnly {
+		cats = visibleCategoryRows(cats)
+	}
 
 	categories := make([]*Category, 0, len(cats))
 	for _, c := range cats {
-		category := Category{
-			Object:   "category",
-			ID:       c.UUID,
-			Segment:  c.Segment,
-			Path:     c.Path,
-			Name:     c.Name,
-			Lft:      c.Lft,
-			Rgt:      c.Rgt,
-			Depth:    c.Depth,
-			Created:  c.Created,
-			Modified: c.Modified,
-		}
-		categories = append(categories, &category)
+		categories = append(categories, categoryFromRow(c))
 	}
 	return categories, nil
 }
+
+func categoryFromRow(row *postgres.CategoryRow) *Category {
+	return &Category{
+		Object:          "category",
+		ID:              row.UUID,
+		Segment:         row.Segment,
+		Path:            row.Path,
+		Name:            row.Name,
+		Lft:             row.Lft,
+		Rgt:             row.Rgt,
+		Depth:           row.Depth,
+		Description:     row.Description,
+		HeroImage:       row.HeroImage,
+		MetaTitle:       row.MetaTitle,
+		MetaDescription: row.MetaDescription,
+		Hidden:          row.Hidden,
+		ProductSort:     row.ProductSort,
+		Created:         row.Created,
+		Modified:        row.Modified,
+	}
+}
+
+// categoryEditErrors maps the errors of the model category edits to
+// service errors.
+var categoryEditErrors = map[error]error{
+	postgres.ErrCategoryNotFound:    ErrCategoryNotFound,
+	postgres.ErrCategoryNotLeaf:     ErrCategoryNotLeaf,
+	postgres.ErrCategoriesInUse:     ErrCategoriesInUse,
+	postgres.ErrCategoryPathExists:  ErrCategoryPathExists,
+	postgres.ErrCategoryHasProducts: ErrCategoryHasProducts,
+	postgres.ErrCategoryRootExists:  ErrCategoryRootExists,
+	postgres.ErrCategoryMoveInvalid: ErrCategoryMoveInvalid,
+}
+
+// GetCategory returns the category with the given id.
+func (s *Service) GetCategory(ctx context.Context, categoryID string) (*Category, error) {
+	row, err := s.model.GetCategoryByUUID(ctx, categoryID)
+	if err == postgres.ErrCategoryNotFound {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetCategoryByUUID(ctx, categoryUUID=%q) failed", categoryID)
+	}
+	return categoryFromRow(row), nil
+}
+
+// CreateCategory adds a category as a child of the category with the
+// given parentID at the given position among its siblings or after them
+// if position is nil. If parentID is nil the root category is created.
+func (s *Service) CreateCategory(ctx context.Context, parentID *string, segment, name string, position *int) (*Category, error) {
+	row, err := s.model.CreateCategory(ctx, parentID, segment, name, position)
+	if serr, ok := categoryEditErrors[err]; ok {
+		return nil, serr
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateCategory(ctx, parentUUID=%v, segment=%q, name=%q, position=%v) failed", parentID, segment, name, position)
+	}
+	return categoryFromRow(row), nil
+}
+
+// UpdateCategory renames a category and sets its metadata. Changing the
+// segment changes the path of the category and its descendants.
+func (s *Service) UpdateCategory(ctx context.Context, categoryID string, cu *CategoryUpdate) (*Category, error) {
+	row, err := s.model.UpdateCategory(ctx, categoryID, &postgres.CategoryUpdate{
+		Segment:         cu.Segment,
+		Name:            cu.Name,
+		Description:     cu.Description,
+		HeroImage:       cu.HeroImage,
+		MetaTitle:       cu.MetaTitle,
+		MetaDescription: cu.MetaDescription,
+		Hidden:          cu.Hidden,
+		ProductSort:     cu.ProductSort,
+	})
+	if serr, ok := categoryEditErrors[err]; ok {
+		return nil, serr
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.UpdateCategory(ctx, categoryUUID=%q, ...) failed", categoryID)
+	}
+	return categoryFromRow(row), nil
+}
+
+// MoveCategory moves a category and its descendants under the category
+// with the given parentID at the given position among its new siblings
+// or after them if position is nil.
+func (s *Service) MoveCategory(ctx context.Context, categoryID, parentID string, position *int) (*Category, error) {
+	row, err := s.model.MoveCategory(ctx, categoryID, parentID, position)
+	if serr, ok := categoryEditErrors[err]; ok {
+		return nil, serr
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.MoveCategory(ctx, categoryUUID=%q, parentUUID=%q, position=%v) failed", categoryID, parentID, position)
+	}
+	return categoryFromRow(row), nil
+}
+
+// DeleteCategory deletes a leaf category that has no products and is not
+// used by any promo rules.
+func (s *Service) DeleteCategory(ctx context.Context, categoryID string) error {
+	err := s.model.DeleteCategoryByUUID(ctx, categoryID)
+	if serr, ok := categoryEditErrors[err]; ok {
+		return serr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.DeleteCategoryByUUID(ctx, categoryUUID=%q) failed", categoryID)
+	}
+	return nil
+}
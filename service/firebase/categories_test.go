@@ -178,3 +178,26 @@ func TestFindNodeByPath(t *testing.T) {
 	n = root.FindNodeByPath("a/c/f/i")
 	assert.Equal(t, true, n.IsLeaf(), fmt.Sprintf("Node %q IsLeaf() should be %t; got %t", "a/c/f/i", true, n.IsLeaf()))
 }
+
+func TestHiddenCategories(t *testing.T) {
+	nodes := []*postgres.CategoryRow{
+		{Segment: "a", Path: "a", Name: "Category A", Lft: 1, Rgt: 12, Depth: 0},
+		{Segment: "b", Path: "a/b", Name: "Category B", Lft: 2, Rgt: 7, Depth: 1, Hidden: true},
+		{Segment: "d", Path: "a/b/d", Name: "Category D", Lft: 3, Rgt: 4, Depth: 2},
+		{Segment: "e", Path: "a/b/e", Name: "Category E", Lft: 5, Rgt: 6, Depth: 2},
+		{Segment: "c", Path: "a/c", Name: "Category C", Lft: 8, Rgt: 11, Depth: 1},
+		{Segment: "f", Path: "a/c/f", Name: "Category F", Lft: 9, Rgt: 10, Depth: 2, Hidden: true},
+	}
+
+	var paths []string
+	for _, row := range visibleCategoryRows(nodes) {
+		paths = append(paths, row.Path)
+	}
+	assert.Equal(t, []string{"a", "a/c"}, paths)
+
+	root := BuildTree(nodes, nil)
+	root.removeHidden()
+	assert.Nil(t, root.FindNodeByPath("a/b"))
+	assert.Nil(t, root.FindNodeByPath("a/c/f"))
+	assert.NotNil(t, root.FindNodeByPath("a/c"))
+}
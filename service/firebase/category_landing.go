@@ -0,0 +1,145 @@
+package firebase
+
+import (
+	"context"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// Breadcrumb is an ancestor of a category on the path down from the
+// root category.
+type Breadcrumb struct {
+	ID      string `json:"id"`
+	Segment string `json:"segment"`
+	Path    string `json:"path"`
+	Name    string `json:"name"`
+}
+
+// CategoryProductList is a page of the products of a category subtree.
+type CategoryProductList struct {
+	Object     string      `json:"object"`
+	Data       []*Product  `json:"data"`
+	Pagination *Pagination `json:"pagination"`
+}
+
+// CategoryLanding holds everything a storefront needs to render the page
+// of a category. Breadcrumbs run from the root category down to the
+// parent of the category.
+type CategoryLanding struct {
+	Object      string               `json:"object"`
+	Category    *Category            `json:"category"`
+	Breadcrumbs []*Breadcrumb        `json:"breadcrumbs"`
+	Children    []*Category          `json:"children"`
+	Products    *CategoryProductList `json:"products"`
+}
+
+// newCategoryLanding returns the landing of a category without products.
+// If visibleOnly is true ErrCategoryNotFound is returned if the category
+// or any of its ancestors is hidden and hidden children are left out.
+func newCategoryLanding(row *postgres.CategoryRow, ancestors, children []*postgres.CategoryRow, visibleOnly bool) (*CategoryLanding, error) {
+	if visibleOnly && row.Hidden {
+		return nil, ErrCategoryNotFound
+	}
+	landing := CategoryLanding{
+		Object:      "category_landing",
+		Category:    categoryFromRow(row),
+		Breadcrumbs: make([]*Breadcrumb, 0, len(ancestors)),
+		Children:    make([]*Category, 0, len(children)),
+	}
+	for _, a := range ancestors {
+		if visibleOnly && a.Hidden {
+			return nil, ErrCategoryNotFound
+		}
+		landing.Breadcrumbs = append(landing.Breadcrumbs, &Breadcrumb{
+			ID:      a.UUID,
+			Segment: a.Segment,
+			Path:    a.Path,
+			Name:    a.Name,
+		})
+	}
+	for _, c := range children {
+		if visibleOnly && c.Hidden {
+			continue
+		}
+		landing.Children = append(landing.Children, categoryFromRow(c))
+	}
+	return &landing, nil
+}
+
+// GetCategoryLanding returns the category with the given path along with
+// its breadcrumbs, children and a page of the products in its subtree.
+// Products are in the product sort of the category unless opts gives an
+// order and include their prices in the price list of the user. If
+// visibleOnly is true hidden categories and unpublished products are
+// left out.
+func (s *Service) GetCategoryLanding(ctx context.Context, userID, path string, opts *ListOptions, visibleOnly bool) (*CategoryLanding, error) {
+	row, err := s.model.GetCategoryByPath(ctx, path)
+	if err == postgres.ErrCategoryNotFound {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetCategoryByPath(ctx, path=%q) failed", path)
+	}
+	ancestors, err := s.model.GetCategoryAncestors(ctx, row)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetCategoryAncestors(ctx, path=%q) failed", path)
+	}
+	children, err := s.model.GetCategoryChildren(ctx, row)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetCategoryChildren(ctx, path=%q) failed", path)
+	}
+	landing, err := newCategoryLanding(row, ancestors, children, visibleOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	priceListID, err := s.userPriceListID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	products, lc, err := s.model.GetCategorySubtreeProducts(ctx, row, priceListID, opts.model(), visibleOnly, visibleOnly)
+	if lerr := listError(err); lerr != nil {
+		return nil, lerr
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetCategorySubtreeProducts(ctx, path=%q, ...) failed", path)
+	}
+	productIDs := make([]string, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.UUID)
+	}
+	availability, err := s.getAvailability(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	priceRows, err := s.model.GetPricesByProducts(ctx, productIDs, priceListID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetPricesByProducts(ctx, productUUIDs=%v, priceListUUID=%q) failed", productIDs, priceListID)
+	}
+	prices := make(map[string][]*Price, len(products))
+	for _, p := range priceRows {
+		prices[p.ProductUUID] = append(prices[p.ProductUUID], priceFromRow(p))
+	}
+
+	data := make([]*Product, 0, len(products))
+	for _, p := range products {
+		product := productFromRow(p)
+		product.Availability = availability[p.UUID]
+		productPrices, ok := prices[p.UUID]
+		if !ok {
+			productPrices = make([]*Price, 0)
+		}
+		product.Prices = &priceListContainer{
+			Object: "list",
+			Data:   productPrices,
+		}
+		data = append(data, product)
+	}
+	landing.Products = &CategoryProductList{
+		Object:     "list",
+		Data:       data,
+		Pagination: newPagination(opts, lc, len(data), func(i int) string { return data[i].ID }),
+	}
+	return landing, nil
+}
@@ -0,0 +1,36 @@
+package firebase
+
+import (
+	"testing"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCategoryLanding(t *testing.T) {
+	ancestors := []*postgres.CategoryRow{
+		{UUID: "a", Segment: "a", Path: "a", Name: "Category A", Lft: 1, Rgt: 12, Depth: 0},
+		{UUID: "c", Segment: "c", Path: "a/c", Name: "Category C", Lft: 6, Rgt: 11, Depth: 1},
+	}
+	row := &postgres.CategoryRow{UUID: "f", Segment: "f", Path: "a/c/f", Name: "Category F", Lft: 7, Rgt: 10, Depth: 2}
+	children := []*postgres.CategoryRow{
+		{UUID: "g", Segment: "g", Path: "a/c/f/g", Name: "Category G", Lft: 8, Rgt: 9, Depth: 3, Hidden: true},
+	}
+
+	landing, err := newCategoryLanding(row, ancestors, children, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "a/c/f", landing.Category.Path)
+	assert.Equal(t, []*Breadcrumb{
+		{ID: "a", Segment: "a", Path: "a", Name: "Category A"},
+		{ID: "c", Segment: "c", Path: "a/c", Name: "Category C"},
+	}, landing.Breadcrumbs)
+	assert.Len(t, landing.Children, 1)
+
+	landing, err = newCategoryLanding(row, ancestors, children, true)
+	assert.NoError(t, err)
+	assert.Len(t, landing.Children, 0)
+
+	ancestors[1].Hidden = true
+	_, err = newCategoryLanding(row, ancestors, children, true)
+	assert.Equal(t, ErrCategoryNotFound, err)
+}
@@ -0,0 +1,48 @@
+package firebase
+
+import (
+	"context"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ErrCategoryProductsMismatch is returned when reordering the products of
+// a category with a list that is not exactly the products of the category.
+var ErrCategoryProductsMismatch = errors.New("service: category products mismatch")
+
+// ReorderCategoryProducts sets the manual order of the products of a leaf
+// category. productIDs must hold each product of the category once.
+func (s *Service) ReorderCategoryProducts(ctx context.Context, categoryID string, productIDs []string) ([]*ProductsCategories, error) {
+	rows, err := s.model.ReorderCategoryProducts(ctx, categoryID, productIDs)
+	if err == postgres.ErrCategoryNotFound {
+		return nil, ErrCategoryNotFound
+	}
+	if err == postgres.ErrCategoryNotLeaf {
+		return nil, ErrCategoryNotLeaf
+	}
+	if err == postgres.ErrCategoryProductsMismatch {
+		return nil, ErrCategoryProductsMismatch
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.ReorderCategoryProducts(ctx, categoryUUID=%q, productUUIDs=%v) failed", categoryID, productIDs)
+	}
+
+	results := make([]*ProductsCategories, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, &ProductsCategories{
+			Object:       "products_categories",
+			ID:           row.UUID,
+			ProductID:    row.ProductUUID,
+			ProductPath:  row.ProductPath,
+			ProductSKU:   row.ProductSKU,
+			ProductName:  row.ProductName,
+			CategoryID:   row.CategoryUUID,
+			CategoryPath: row.CategoryPath,
+			Pri:          row.Pri,
+			Created:      row.Created,
+			Modified:     row.Modified,
+		})
+	}
+	return results, nil
+}
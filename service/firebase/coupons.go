@@ -84,11 +84,14 @@ func (s *Service) GetCoupon(ctx context.Context, couponID string) (*Coupon, erro
 	return &coupon, nil
 }
 
-// GetCoupons returns a list coupons.
-func (s *Service) GetCoupons(ctx context.Context) ([]*Coupon, error) {
-	rows, err := s.model.GetCoupons(ctx)
+// GetCoupons returns a page of coupons.
+func (s *Service) GetCoupons(ctx context.Context, opts *ListOptions) ([]*Coupon, *Pagination, error) {
+	rows, lc, err := s.model.GetCoupons(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "service: s.model.GetCoupons(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "service: s.model.GetCoupons(ctx) failed")
 	}
 
 	coupons := make([]*Coupon, 0, len(rows))
@@ -107,7 +110,7 @@ func (s *Service) GetCoupons(ctx context.Context) ([]*Coupon, error) {
 		}
 		coupons = append(coupons, &coupon)
 	}
-	return coupons, nil
+	return coupons, newPagination(opts, lc, len(coupons), func(i int) string { return coupons[i].ID }), nil
 }
 
 // UpdateCoupon partially updates am existing coupon. Returns
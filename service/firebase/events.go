@@ -32,6 +32,43 @@ const (
 
 	// EventOrderUpdated event
 	EventOrderUpdated string = "order.updated"
+
+	// EventOrderPaymentFailed triggered after an attempt to pay for an
+	// order has failed.
+	EventOrderPaymentFailed string = "order.payment_failed"
+
+	// EventOrderDisputed triggered after the payment for an order has
+	// been disputed by the customer.
+	EventOrderDisputed string = "order.disputed"
+
+	// EventOrderRefunded triggered after a refund against an order has
+	// succeeded.
+	EventOrderRefunded string = "order.refunded"
+
+	// EventShipmentCreated triggered after a shipment has been added to
+	// an order.
+	EventShipmentCreated string = "shipment.created"
+
+	// EventInventoryLowStock triggered after the onhand of inventory has
+	// dropped to or below its reorder level.
+	EventInventoryLowStock string = "inventory.low_stock"
+
+	// EventInventoryOutOfStock triggered after the onhand of inventory
+	// has dropped to zero.
+	EventInventoryOutOfStock string = "inventory.out_of_stock"
+
+	// EventInventoryRestocked triggered after the onhand of inventory has
+	// risen above its reorder level.
+	EventInventoryRestocked string = "inventory.restocked"
+
+	// EventProductPublished triggered after a product has been made
+	// active. Products with a publish_at time in the future are visible
+	// to shoppers from that time. No event is triggered when the
+	// publish_at or unpublish_at time of a product is reached.
+	EventProductPublished string = "product.published"
+
+	// EventProductArchived triggered after a product has been archived.
+	EventProductArchived string = "product.archived"
 )
 
 var validEvents map[string]struct{}
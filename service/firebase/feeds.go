@@ -0,0 +1,349 @@
+package firebase
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// SitemapMaxURLs is the most URLs a single sitemap file may hold. Larger
+// sitemaps are split into chunks listed by a sitemap index.
+const SitemapMaxURLs = 50000
+
+// Product attribute codes used to fill the identifiers of products in the
+// Google Merchant product feed.
+const (
+	FeedAttributeGTIN  = "gtin"
+	FeedAttributeBrand = "brand"
+	FeedAttributeMPN   = "mpn"
+)
+
+// ErrSitemapChunkNotFound is returned when asking for a sitemap chunk
+// that does not exist.
+var ErrSitemapChunkNotFound = errors.New("service: sitemap chunk not found")
+
+const (
+	sitemapXMLNS    = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	googleBaseXMLNS = "http://base.google.com/ns/1.0"
+	gcsPublicURL    = "https://storage.googleapis.com/"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name      `xml:"urlset"`
+	XMLNS   string        `xml:"xmlns,attr"`
+	URLs    []*sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name      `xml:"sitemapindex"`
+	XMLNS    string        `xml:"xmlns,attr"`
+	Sitemaps []*sitemapURL `xml:"sitemap"`
+}
+
+// Sitemap holds the storefront URLs of all published products and
+// visible categories. Sitemaps of more than SitemapMaxURLs URLs are
+// written as a sitemap index of chunks named sitemap-1.xml,
+// sitemap-2.xml and so on alongside sitemap.xml. The index lists the
+// chunks under the storefront URL so the storefront must serve them,
+// either by proxying to the API or from files.
+type Sitemap struct {
+	baseURL string
+	urls    []*sitemapURL
+}
+
+// storefrontURL returns the absolute URL of a storefront path.
+func storefrontURL(baseURL, path string) string {
+	u := url.URL{Path: path}
+	return strings.TrimRight(baseURL, "/") + "/" + u.EscapedPath()
+}
+
+func newSitemap(baseURL string, rows []*postgres.SitemapRow) *Sitemap {
+	urls := make([]*sitemapURL, 0, len(rows))
+	for _, r := range rows {
+		urls = append(urls, &sitemapURL{
+			Loc:     storefrontURL(baseURL, r.Path),
+			LastMod: r.Modified.UTC().Format("2006-01-02"),
+		})
+	}
+	return &Sitemap{
+		baseURL: baseURL,
+		urls:    urls,
+	}
+}
+
+// IsIndex returns true if sitemap.xml is a sitemap index.
+func (sm *Sitemap) IsIndex() bool {
+	return len(sm.urls) > SitemapMaxURLs
+}
+
+// Chunks returns the number of chunks listed by the sitemap index or 0 if
+// the sitemap is not split.
+func (sm *Sitemap) Chunks() int {
+	if !sm.IsIndex() {
+		return 0
+	}
+	return (len(sm.urls) + SitemapMaxURLs - 1) / SitemapMaxURLs
+}
+
+// SitemapChunkName returns the file name of the nth sitemap chunk
+// counting from 1.
+func SitemapChunkName(n int) string {
+	return fmt.Sprintf("sitemap-%d.xml", n)
+}
+
+// Write writes sitemap.xml to w. This is either every URL or a sitemap
+// index of the chunks.
+func (sm *Sitemap) Write(w io.Writer) error {
+	if !sm.IsIndex() {
+		return writeXML(w, &sitemapURLSet{XMLNS: sitemapXMLNS, URLs: sm.urls})
+	}
+	index := sitemapIndex{
+		XMLNS:    sitemapXMLNS,
+		Sitemaps: make([]*sitemapURL, 0, sm.Chunks()),
+	}
+	for n := 1; n <= sm.Chunks(); n++ {
+		index.Sitemaps = append(index.Sitemaps, &sitemapURL{
+			Loc: storefrontURL(sm.baseURL, SitemapChunkName(n)),
+		})
+	}
+	return writeXML(w, &index)
+}
+
+// WriteChunk writes the nth chunk of the sitemap counting from 1 to w.
+func (sm *Sitemap) WriteChunk(w io.Writer, n int) error {
+	if n < 1 || n > sm.Chunks() {
+		return ErrSitemapChunkNotFound
+	}
+	start := (n - 1) * SitemapMaxURLs
+	end := start + SitemapMaxURLs
+	if end > len(sm.urls) {
+		end = len(sm.urls)
+	}
+	return writeXML(w, &sitemapURLSet{XMLNS: sitemapXMLNS, URLs: sm.urls[start:end]})
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.Wrap(err, "service: write xml header failed")
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return errors.Wrap(err, "service: xml encode failed")
+	}
+	return nil
+}
+
+// GetSitemap returns the sitemap of all published products and visible
+// categories. URLs are the paths of the products and categories relative
+// to the storefront baseURL.
+func (s *Service) GetSitemap(ctx context.Context, baseURL string) (*Sitemap, error) {
+	rows, err := s.model.GetSitemapRows(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "service: s.model.GetSitemapRows(ctx) failed")
+	}
+	return newSitemap(baseURL, rows), nil
+}
+
+type productFeedRSS struct {
+	XMLName xml.Name           `xml:"rss"`
+	Version string             `xml:"version,attr"`
+	XMLNSG  string             `xml:"xmlns:g,attr"`
+	Channel productFeedChannel `xml:"channel"`
+}
+
+type productFeedChannel struct {
+	Title       string             `xml:"title"`
+	Link        string             `xml:"link"`
+	Description string             `xml:"description"`
+	Items       []*ProductFeedItem `xml:"item"`
+}
+
+// ProductFeedItem is a product in the Google Merchant product feed.
+type ProductFeedItem struct {
+	ID                   string   `xml:"g:id"`
+	Title                string   `xml:"g:title"`
+	Description          string   `xml:"g:description"`
+	Link                 string   `xml:"g:link"`
+	ImageLink            string   `xml:"g:image_link,omitempty"`
+	AdditionalImageLinks []string `xml:"g:additional_image_link,omitempty"`
+	Availability         string   `xml:"g:availability"`
+	AvailabilityDate     string   `xml:"g:availability_date,omitempty"`
+	Price                string   `xml:"g:price"`
+	SalePrice            string   `xml:"g:sale_price,omitempty"`
+	Condition            string   `xml:"g:condition"`
+	Brand                string   `xml:"g:brand,omitempty"`
+	GTIN                 string   `xml:"g:gtin,omitempty"`
+	MPN                  string   `xml:"g:mpn,omitempty"`
+	IdentifierExists     string   `xml:"g:identifier_exists,omitempty"`
+	ItemGroupID          string   `xml:"g:item_group_id,omitempty"`
+}
+
+// ProductFeed is a Google Merchant product feed in RSS 2.0 format.
+type ProductFeed struct {
+	rss productFeedRSS
+}
+
+// Items returns the products in the feed.
+func (f *ProductFeed) Items() []*ProductFeedItem {
+	return f.rss.Channel.Items
+}
+
+// Write writes the feed to w.
+func (f *ProductFeed) Write(w io.Writer) error {
+	return writeXML(w, &f.rss)
+}
+
+// formatPrice formats a price given in the smallest unit of the currency,
+// such as pence, the way Google Merchant expects, for example 12.99 GBP.
+func formatPrice(price int, currencyCode string) string {
+	return fmt.Sprintf("%d.%02d %s", price/100, price%100, currencyCode)
+}
+
+// feedAttribute returns the value of the product attribute with the
+// given code as a string or an empty string if not set.
+func feedAttribute(attrs postgres.ProductAttributes, code string) string {
+	switch v := attrs[code].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// feedTitle returns the host name of the storefront.
+func feedTitle(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
+
+// imageURL returns the public URL of an image stored at gsurl.
+func imageURL(gsurl string) string {
+	return gcsPublicURL + strings.TrimPrefix(gsurl, "gs://")
+}
+
+// newProductFeedItem returns the feed item of a product or nil if the
+// product has no price or a price of zero. Products without inventory are
+// in stock.
+func newProductFeedItem(row *postgres.ProductFeedRow, baseURL, currencyCode string, availability *Availability) *ProductFeedItem {
+	if row.UnitPrice == nil || *row.UnitPrice <= 0 {
+		return nil
+	}
+	item := ProductFeedItem{
+		ID:           row.SKU,
+		Title:        row.Name,
+		Description:  row.Description,
+		Link:         storefrontURL(baseURL, row.Path),
+		Availability: postgres.AvailabilityInStock,
+		Price:        formatPrice(*row.UnitPrice, currencyCode),
+		Condition:    "new",
+		Brand:        feedAttribute(row.Attributes, FeedAttributeBrand),
+		GTIN:         feedAttribute(row.Attributes, FeedAttributeGTIN),
+		MPN:          feedAttribute(row.Attributes, FeedAttributeMPN),
+	}
+	if item.Description == "" {
+		item.Description = row.Name
+	}
+	if item.GTIN == "" && item.MPN == "" {
+		item.IdentifierExists = "no"
+	}
+	if row.OfferPrice != nil && *row.OfferPrice > 0 && *row.OfferPrice < *row.UnitPrice {
+		item.SalePrice = formatPrice(*row.OfferPrice, currencyCode)
+	}
+	if row.ParentUUID != nil {
+		item.ItemGroupID = *row.ParentUUID
+	}
+	for i, gsurl := range row.Images {
+		if i == 0 {
+			item.ImageLink = imageURL(gsurl)
+			continue
+		}
+		// Google Merchant accepts up to 10 additional images.
+		if i > 10 {
+			break
+		}
+		item.AdditionalImageLinks = append(item.AdditionalImageLinks, imageURL(gsurl))
+	}
+	if availability != nil {
+		item.Availability = availability.Status
+		if availability.AvailableDate != nil {
+			item.AvailabilityDate = availability.AvailableDate.UTC().Format(time.RFC3339)
+		}
+	}
+	return &item
+}
+
+// GetProductFeed returns the Google Merchant product feed of all
+// purchasable products with prices from the price list with the given
+// priceListID. The default price list is used if priceListID is empty.
+// Products without a price in the price list are left out as are parent
+// products with variants. Prices include UK tax.
+func (s *Service) GetProductFeed(ctx context.Context, baseURL, priceListID string) (*ProductFeed, error) {
+	if priceListID == "" {
+		id, err := s.userPriceListID(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		priceListID = id
+	}
+	priceList, err := s.model.GetPriceList(ctx, priceListID)
+	if err == postgres.ErrPriceListNotFound {
+		return nil, ErrPriceListNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetPriceList(ctx, priceListUUID=%q) failed", priceListID)
+	}
+
+	dest := postgres.TaxDestination{CountryCode: postgres.DefaultTaxCountryCode}
+	rows, err := s.model.GetProductFeed(ctx, priceListID, &dest)
+	if err == postgres.ErrTaxRateNotFound {
+		return nil, ErrTaxRateNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetProductFeed(ctx, priceListUUID=%q) failed", priceListID)
+	}
+	productIDs := make([]string, 0, len(rows))
+	for _, r := range rows {
+		productIDs = append(productIDs, r.UUID)
+	}
+	availability, err := s.getAvailability(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*ProductFeedItem, 0, len(rows))
+	for _, r := range rows {
+		if item := newProductFeedItem(r, baseURL, priceList.CurrencyCode, availability[r.UUID]); item != nil {
+			items = append(items, item)
+		}
+	}
+	return &ProductFeed{
+		rss: productFeedRSS{
+			Version: "2.0",
+			XMLNSG:  googleBaseXMLNS,
+			Channel: productFeedChannel{
+				Title:       feedTitle(baseURL),
+				Link:        baseURL,
+				Description: fmt.Sprintf("Products priced in %s", priceList.Name),
+				Items:       items,
+			},
+		},
+	}, nil
+}
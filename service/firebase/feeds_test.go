@@ -0,0 +1,124 @@
+package firebase
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSitemapChunks(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	rows := []*postgres.SitemapRow{
+		{Path: "water-bottle", Modified: modified},
+		{Path: "spy/shop-by-solution", Modified: modified},
+	}
+	sm := newSitemap("https://example.com/", rows)
+	assert.False(t, sm.IsIndex())
+	assert.Equal(t, 0, sm.Chunks())
+
+	var b bytes.Buffer
+	assert.NoError(t, sm.Write(&b))
+	assert.Contains(t, b.String(), "<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">")
+	assert.Contains(t, b.String(), "<loc>https://example.com/spy/shop-by-solution</loc>")
+	assert.Contains(t, b.String(), "<lastmod>2020-01-02</lastmod>")
+	assert.Equal(t, ErrSitemapChunkNotFound, sm.WriteChunk(&b, 1))
+
+	for i := len(rows); i <= SitemapMaxURLs; i++ {
+		rows = append(rows, &postgres.SitemapRow{Path: "p", Modified: modified})
+	}
+	sm = newSitemap("https://example.com", rows)
+	assert.True(t, sm.IsIndex())
+	assert.Equal(t, 2, sm.Chunks())
+
+	b.Reset()
+	assert.NoError(t, sm.Write(&b))
+	assert.Contains(t, b.String(), "<sitemapindex")
+	assert.Contains(t, b.String(), "<loc>https://example.com/sitemap-2.xml</loc>")
+
+	b.Reset()
+	assert.NoError(t, sm.WriteChunk(&b, 2))
+	assert.Equal(t, 1, strings.Count(b.String(), "<url>"))
+	assert.Equal(t, ErrSitemapChunkNotFound, sm.WriteChunk(&b, 3))
+}
+
+func TestNewProductFeedItem(t *testing.T) {
+	unitPrice, offerPrice := 1299, 999
+	parentID := "a0e0e3a4-5b0f-4c36-9d2e-6b0e1e7f3d11"
+	available := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	row := &postgres.ProductFeedRow{
+		UUID:       "f9c2c5c2-5e4d-4b8e-b5a5-6b6f4e5d2c01",
+		ParentUUID: &parentID,
+		Path:       "water-bottle-blue",
+		SKU:        "WATER-BLUE",
+		Name:       "Water Bottle Blue",
+		Attributes: postgres.ProductAttributes{
+			"brand": "Acme",
+			"gtin":  float64(5012345678900),
+		},
+		UnitPrice:  &unitPrice,
+		OfferPrice: &offerPrice,
+		Images:     []string{"gs://bucket/a.jpg", "gs://bucket/b.jpg"},
+	}
+
+	item := newProductFeedItem(row, "https://example.com", "GBP", &Availability{
+		Status:        postgres.AvailabilityPreorder,
+		AvailableDate: &available,
+	})
+	assert.Equal(t, &ProductFeedItem{
+		ID:                   "WATER-BLUE",
+		Title:                "Water Bottle Blue",
+		Description:          "Water Bottle Blue",
+		Link:                 "https://example.com/water-bottle-blue",
+		ImageLink:            "https://storage.googleapis.com/bucket/a.jpg",
+		AdditionalImageLinks: []string{"https://storage.googleapis.com/bucket/b.jpg"},
+		Availability:         "preorder",
+		AvailabilityDate:     "2020-03-01T00:00:00Z",
+		Price:                "12.99 GBP",
+		SalePrice:            "9.99 GBP",
+		Condition:            "new",
+		Brand:                "Acme",
+		GTIN:                 "5012345678900",
+		ItemGroupID:          parentID,
+	}, item)
+
+	row.Attributes = nil
+	row.OfferPrice = nil
+	item = newProductFeedItem(row, "https://example.com", "GBP", nil)
+	assert.Equal(t, "in_stock", item.Availability)
+	assert.Equal(t, "no", item.IdentifierExists)
+	assert.Equal(t, "", item.SalePrice)
+
+	zero := 0
+	row.OfferPrice = &zero
+	item = newProductFeedItem(row, "https://example.com", "GBP", nil)
+	assert.Equal(t, "", item.SalePrice)
+
+	row.UnitPrice = &zero
+	assert.Nil(t, newProductFeedItem(row, "https://example.com", "GBP", nil))
+
+	row.UnitPrice = nil
+	assert.Nil(t, newProductFeedItem(row, "https://example.com", "GBP", nil))
+}
+
+func TestProductFeedWrite(t *testing.T) {
+	feed := ProductFeed{
+		rss: productFeedRSS{
+			Version: "2.0",
+			XMLNSG:  googleBaseXMLNS,
+			Channel: productFeedChannel{
+				Title: "example.com",
+				Link:  "https://example.com",
+				Items: []*ProductFeedItem{{ID: "WATER-BLUE", Price: "12.99 GBP"}},
+			},
+		},
+	}
+	var b bytes.Buffer
+	assert.NoError(t, feed.Write(&b))
+	assert.Contains(t, b.String(), `<rss version="2.0" xmlns:g="http://base.google.com/ns/1.0">`)
+	assert.Contains(t, b.String(), "<g:id>WATER-BLUE</g:id>")
+	assert.Contains(t, b.String(), "<g:price>12.99 GBP</g:price>")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"bitbucket.org/andyfusniakteam/ecom-api-go/payment"
 	"cloud.google.com/go/pubsub"
 	firebase "firebase.google.com/go"
 	"firebase.google.com/go/auth"
@@ -16,15 +17,19 @@ type Service struct {
 	fbApp            *firebase.App
 	eventsTopic      *pubsub.Topic
 	whBroadcastTopic *pubsub.Topic
+	payment          payment.Provider
+	jobWake          chan struct{}
 }
 
 // NewService creates a new Service
-func NewService(model *postgres.PgModel, fbApp *firebase.App, eventsTopic, whBroadcastTopic *pubsub.Topic) *Service {
+func NewService(model *postgres.PgModel, fbApp *firebase.App, eventsTopic, whBroadcastTopic *pubsub.Topic, paymentProvider payment.Provider) *Service {
 	return &Service{
 		model:            model,
 		fbApp:            fbApp,
 		eventsTopic:      eventsTopic,
 		whBroadcastTopic: whBroadcastTopic,
+		payment:          paymentProvider,
+		jobWake:          make(chan struct{}, 1),
 	}
 }
 
@@ -13,24 +13,139 @@ import (
 // for inventory returns no results.
 var ErrInventoryNotFound = errors.New("service: inventory not found")
 
-// InventoryUpdateRequest for a single inventory update.
+// ErrInventoryBelowZero is returned when an adjustment would take the
+// onhand of inventory below zero.
+var ErrInventoryBelowZero = errors.New("service: inventory below zero")
+
+// ErrInventoryMovementNotFound error
+var ErrInventoryMovementNotFound = errors.New("service: inventory movement not found")
+
+// InventoryUpdateRequest for a single inventory update. Location is the
+// code of the location to set onhand at.
 type InventoryUpdateRequest struct {
-	ProductID   *string `json:"product_id"`
-	Onhand      *int    `json:"onhand"`
-	Overselling *bool   `json:"overselling"`
+	ProductID    *string `json:"product_id"`
+	Location     *string `json:"location"`
+	Onhand       *int    `json:"onhand"`
+	Overselling  *bool   `json:"overselling"`
+	ReorderLevel *int    `json:"reorder_level"`
 }
 
 // Inventory holds inventory for a single product
 type Inventory struct {
+	Object         string               `json:"object"`
+	ID             string               `json:"id"`
+	ProductID      string               `json:"product_id"`
+	ProductPath    string               `json:"product_path"`
+	ProductSKU     string               `json:"product_sku"`
+	Onhand         int                  `json:"onhand"`
+	Overselling    bool                 `json:"overselling"`
+	ReorderLevel   int                  `json:"reorder_level"`
+	Backorder      string               `json:"backorder"`
+	AvailableDate  *time.Time           `json:"available_date"`
+	BackorderLimit *int                 `json:"backorder_limit"`
+	Locations      []*InventoryLocation `json:"locations,omitempty"`
+	Created        time.Time            `json:"created"`
+	Modified       time.Time            `json:"modified"`
+}
+
+// InventoryLocation holds the onhand of inventory at a single location.
+type InventoryLocation struct {
+	LocationID string `json:"location_id"`
+	Location   string `json:"location"`
+	Onhand     int    `json:"onhand"`
+}
+
+func inventoryFromRow(row *postgres.InventoryJoinRow) *Inventory {
+	locations := make([]*InventoryLocation, 0, len(row.Locations))
+	for _, l := range row.Locations {
+		locations = append(locations, &InventoryLocation{
+			LocationID: l.LocationUUID,
+			Location:   l.LocationCode,
+			Onhand:     l.Onhand,
+		})
+	}
+	return &Inventory{
+		Object:         "inventory",
+		ID:             row.UUID,
+		ProductID:      row.ProductUUID,
+		ProductPath:    row.ProductPath,
+		ProductSKU:     row.ProductSKU,
+		Onhand:         row.Onhand,
+		Overselling:    row.Overselling,
+		ReorderLevel:   row.ReorderLevel,
+		Backorder:      row.Backorder,
+		AvailableDate:  row.AvailableDate,
+		BackorderLimit: row.BackorderLimit,
+		Locations:      locations,
+		Created:        row.Created,
+		Modified:       row.Modified,
+	}
+}
+
+// InventoryBackorderRequest holds the backorder policy of inventory.
+// Backorder is one of none, backorder or preorder. AvailableDate is the
+// date stock is expected and Limit caps the outstanding backordered
+// quantity if not nil.
+type InventoryBackorderRequest struct {
+	Backorder     string
+	AvailableDate *time.Time
+	Limit         *int
+}
+
+// InventoryStockEventData is published with the inventory.low_stock,
+// inventory.out_of_stock and inventory.restocked events.
+type InventoryStockEventData struct {
+	InventoryID  string `json:"inventory_id"`
+	ProductID    string `json:"product_id"`
+	ProductSKU   string `json:"product_sku"`
+	Onhand       int    `json:"onhand"`
+	ReorderLevel int    `json:"reorder_level"`
+}
+
+// stockAlertEvents maps each stock alert to the event published.
+var stockAlertEvents = map[string]string{
+	postgres.StockAlertLowStock:   EventInventoryLowStock,
+	postgres.StockAlertOutOfStock: EventInventoryOutOfStock,
+	postgres.StockAlertRestocked:  EventInventoryRestocked,
+}
+
+// publishStockAlerts publishes an event for each stock alert.
+func (s *Service) publishStockAlerts(ctx context.Context, alerts []*postgres.StockAlertRow) error {
+	contextLogger := log.WithContext(ctx)
+	for _, a := range alerts {
+		event := stockAlertEvents[a.Alert]
+		data := InventoryStockEventData{
+			InventoryID:  a.InventoryUUID,
+			ProductID:    a.ProductUUID,
+			ProductSKU:   a.ProductSKU,
+			Onhand:       a.Onhand,
+			ReorderLevel: a.ReorderLevel,
+		}
+		if err := s.PublishTopicEvent(ctx, event, &data); err != nil {
+			return errors.Wrapf(err,
+				"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+				event, data)
+		}
+		contextLogger.Infof("service: %s published for inventory %q", event, a.InventoryUUID)
+	}
+	return nil
+}
+
+// InventoryMovement holds a single change to the onhand of inventory at
+// a location. Balance is the inventory onhand after the movement.
+type InventoryMovement struct {
 	Object      string    `json:"object"`
 	ID          string    `json:"id"`
-	ProductID   string    `json:"product_id"`
-	ProductPath string    `json:"product_path"`
-	ProductSKU  string    `json:"product_sku"`
-	Onhand      int       `json:"onhand"`
-	Overselling bool      `json:"overselling"`
+	InventoryID string    `json:"inventory_id"`
+	SKU         string    `json:"sku"`
+	Location    *string   `json:"location,omitempty"`
+	Reason      string    `json:"reason"`
+	Delta       int       `json:"delta"`
+	Balance     int       `json:"balance"`
+	OrderID     *string   `json:"order_id,omitempty"`
+	UserID      *string   `json:"user_id,omitempty"`
+	Note        *string   `json:"note,omitempty"`
 	Created     time.Time `json:"created"`
-	Modified    time.Time `json:"modified"`
 }
 
 // GetInventory returns a single Inventory by id.
@@ -45,50 +160,49 @@ func (s *Service) GetInventory(ctx context.Context, inventoryID string) (*Invent
 	if err != nil {
 		return nil, errors.Wrapf(err, "service: s.model.GetInventoryByUUID(ctx, inventoryUUID=%q)", inventoryID)
 	}
-
-	inventory := Inventory{
-		Object:      "inventory",
-		ID:          row.UUID,
-		ProductID:   row.ProductUUID,
-		ProductPath: row.ProductPath,
-		ProductSKU:  row.ProductSKU,
-		Onhand:      row.Onhand,
-		Overselling: row.Overselling,
-		Created:     row.Created,
-		Modified:    row.Modified,
-	}
-	return &inventory, nil
+	return inventoryFromRow(row), nil
 }
 
-// GetAllInventory returns a slice of all inventory for all products.
-func (s *Service) GetAllInventory(ctx context.Context) ([]*Inventory, error) {
+// GetAllInventory returns a page of inventory. If location is set the
+// onhand of each inventory is the onhand at the location with that code.
+func (s *Service) GetAllInventory(ctx context.Context, opts *ListOptions, location *string) ([]*Inventory, *Pagination, error) {
 	contextLogger := log.WithContext(ctx)
-	contextLogger.Info("service: GetAllInventory(ctx) started")
+	contextLogger.Infof("service: GetAllInventory(ctx, opts=%+v, location=%v) started", opts, location)
+
+	if location != nil {
+		if _, err := s.GetLocationByCode(ctx, *location); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	rows, err := s.model.GetAllInventory(ctx)
+	rows, lc, err := s.model.GetAllInventory(ctx, opts.model())
 	if err == postgres.ErrInventoryNotFound {
-		return nil, ErrInventoryNotFound
+		return nil, nil, ErrInventoryNotFound
+	}
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
 	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "service: s.model.GetAllInventory(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "service: s.model.GetAllInventory(ctx) failed")
 	}
 
 	inventory := make([]*Inventory, 0, len(rows))
 	for _, row := range rows {
-		inv := Inventory{
-			Object:      "inventory",
-			ID:          row.UUID,
-			ProductID:   row.ProductUUID,
-			ProductPath: row.ProductPath,
-			ProductSKU:  row.ProductSKU,
-			Onhand:      row.Onhand,
-			Overselling: row.Overselling,
-			Created:     row.Created,
-			Modified:    row.Modified,
+		inv := inventoryFromRow(row)
+		if location != nil {
+			inv.Onhand = 0
+			locations := make([]*InventoryLocation, 0, 1)
+			for _, l := range inv.Locations {
+				if l.Location == *location {
+					inv.Onhand = l.Onhand
+					locations = append(locations, l)
+				}
+			}
+			inv.Locations = locations
 		}
-		inventory = append(inventory, &inv)
+		inventory = append(inventory, inv)
 	}
-	return inventory, nil
+	return inventory, newPagination(opts, lc, len(inventory), func(i int) string { return inventory[i].ID }), nil
 }
 
 // GetInventoryByProductID returns an Inventory for the given product.
@@ -103,86 +217,155 @@ func (s *Service) GetInventoryByProductID(ctx context.Context, productID string)
 	if err != nil {
 		return nil, errors.Wrapf(err, "service: s.model.GetInventoryByProductUUID(ctx, productUUID=%q)", productID)
 	}
-
-	inventory := Inventory{
-		Object:      "inventory",
-		ID:          row.UUID,
-		ProductID:   row.ProductUUID,
-		ProductPath: row.ProductPath,
-		ProductSKU:  row.ProductSKU,
-		Onhand:      row.Onhand,
-		Overselling: row.Overselling,
-		Created:     row.Created,
-		Modified:    row.Modified,
-	}
-	return &inventory, nil
+	return inventoryFromRow(row), nil
 }
 
 // UpdateInventory updates the inventory with the given inventoryID,
-// to the new onhand value.
-func (s *Service) UpdateInventory(ctx context.Context, inventoryID string, onhand *int, overselling *bool) (*Inventory, error) {
+// to the new onhand value at the location with the code location or the
+// default location if location is nil. A change to onhand is recorded as
+// a stock take by the user. If backorder is not nil the backorder policy
+// is replaced. An event is published if onhand crosses the reorder level
+// or drops to zero.
+func (s *Service) UpdateInventory(ctx context.Context, userID, inventoryID string, location *string, onhand *int, overselling *bool, reorderLevel *int, backorder *InventoryBackorderRequest) (*Inventory, error) {
 	contextLogger := log.WithContext(ctx)
-	contextLogger.Infof("service: UpdateInventory(ctx, inventoryID=%q, onhand=%v, overselling=%v) started", inventoryID, onhand, overselling)
+	contextLogger.Infof("service: UpdateInventory(ctx, userID=%q, inventoryID=%q, location=%v, onhand=%v, overselling=%v, reorderLevel=%v, backorder=%v) started", userID, inventoryID, location, onhand, overselling, reorderLevel, backorder)
 
-	row, err := s.model.UpdateInventoryByUUID(ctx, inventoryID, onhand, overselling)
+	var pgBackorder *postgres.InventoryBackorder
+	if backorder != nil {
+		pgBackorder = &postgres.InventoryBackorder{
+			Backorder:     backorder.Backorder,
+			AvailableDate: backorder.AvailableDate,
+			Limit:         backorder.Limit,
+		}
+	}
+	row, alerts, err := s.model.UpdateInventoryByUUID(ctx, inventoryID, location, onhand, overselling, reorderLevel, pgBackorder, optionalString(userID))
 	if err == postgres.ErrInventoryNotFound {
 		return nil, ErrInventoryNotFound
 	}
+	if err == postgres.ErrLocationNotFound {
+		return nil, ErrLocationNotFound
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "service: s.model.UpdateInventoryByUUID(ctx, inventoryID=%q, onhand=%d) failed", inventoryID, onhand)
 	}
-
-	inventory := Inventory{
-		Object:      "inventory",
-		ID:          row.UUID,
-		ProductID:   row.ProductUUID,
-		ProductPath: row.ProductPath,
-		ProductSKU:  row.ProductSKU,
-		Onhand:      row.Onhand,
-		Overselling: row.Overselling,
-		Created:     row.Created,
-		Modified:    row.Modified,
+	if err := s.publishStockAlerts(ctx, alerts); err != nil {
+		return nil, err
 	}
-	return &inventory, nil
+	return inventoryFromRow(row), nil
 }
 
 // BatchUpdateInventory updates the inventory for multiple products in a single operations.
-func (s *Service) BatchUpdateInventory(ctx context.Context, inventoryUpdates []*InventoryUpdateRequest) ([]*Inventory, error) {
+// Each change to onhand is recorded as a stock take by the user and an
+// event is published for each inventory crossing a threshold.
+func (s *Service) BatchUpdateInventory(ctx context.Context, userID string, inventoryUpdates []*InventoryUpdateRequest) ([]*Inventory, error) {
 	contextLogger := log.WithContext(ctx)
 	contextLogger.Info("service: BatchUpdateInventory(ctx, inventoryUpdates) started")
 
 	inventoryRows := make([]*postgres.InventoryRowUpdate, 0, len(inventoryUpdates))
 	for _, i := range inventoryUpdates {
 		pinv := postgres.InventoryRowUpdate{
-			ProductUUID: *i.ProductID,
-			Onhand:      *i.Onhand,
-			Overselling: *i.Overselling,
+			ProductUUID:  *i.ProductID,
+			LocationCode: i.Location,
+			Onhand:       *i.Onhand,
+			Overselling:  *i.Overselling,
+			ReorderLevel: i.ReorderLevel,
 		}
 		inventoryRows = append(inventoryRows, &pinv)
 	}
 
-	rows, err := s.model.BatchUpdateInventory(ctx, inventoryRows)
+	rows, alerts, err := s.model.BatchUpdateInventory(ctx, inventoryRows, optionalString(userID))
 	if err == postgres.ErrProductNotFound {
 		return nil, ErrProductNotFound
 	}
+	if err == postgres.ErrLocationNotFound {
+		return nil, ErrLocationNotFound
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "service: s.model.BatchUpdateInventory(ctx, inventoryRows) failed")
 	}
+	if err := s.publishStockAlerts(ctx, alerts); err != nil {
+		return nil, err
+	}
 
 	inventory := make([]*Inventory, 0, len(rows))
 	for _, row := range rows {
-		pc := Inventory{
-			Object:      "inventory",
+		inventory = append(inventory, inventoryFromRow(row))
+	}
+	return inventory, nil
+}
+
+// AdjustInventory changes the onhand of the inventory with the given
+// inventoryID at the location with the code location or the default
+// location if location is nil by delta recording the adjustment made by
+// the user. An event is published if onhand crosses the reorder level or
+// drops to zero.
+func (s *Service) AdjustInventory(ctx context.Context, userID, inventoryID string, location *string, delta int, note *string) (*Inventory, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Infof("service: AdjustInventory(ctx, userID=%q, inventoryID=%q, location=%v, delta=%d, note=%v) started", userID, inventoryID, location, delta, note)
+
+	row, alerts, err := s.model.AdjustInventoryByUUID(ctx, inventoryID, location, delta, optionalString(userID), note)
+	if err == postgres.ErrInventoryNotFound {
+		return nil, ErrInventoryNotFound
+	}
+	if err == postgres.ErrLocationNotFound {
+		return nil, ErrLocationNotFound
+	}
+	if err == postgres.ErrInventoryBelowZero {
+		return nil, ErrInventoryBelowZero
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.AdjustInventoryByUUID(ctx, inventoryID=%q, delta=%d, ...) failed", inventoryID, delta)
+	}
+	if err := s.publishStockAlerts(ctx, alerts); err != nil {
+		return nil, err
+	}
+	return inventoryFromRow(row), nil
+}
+
+// GetInventoryMovements returns a page of movements of the inventory
+// with the given inventoryID newest first by default.
+func (s *Service) GetInventoryMovements(ctx context.Context, inventoryID string, opts *ListOptions) ([]*InventoryMovement, *Pagination, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Infof("service: GetInventoryMovements(ctx, inventoryID=%q, opts=%+v) started", inventoryID, opts)
+
+	rows, lc, err := s.model.GetInventoryMovementsByUUID(ctx, inventoryID, opts.model())
+	if err == postgres.ErrInventoryNotFound {
+		return nil, nil, ErrInventoryNotFound
+	}
+	if err == postgres.ErrInventoryMovementNotFound {
+		return nil, nil, ErrInventoryMovementNotFound
+	}
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "service: s.model.GetInventoryMovementsByUUID(ctx, inventoryID=%q, ...) failed", inventoryID)
+	}
+
+	movements := make([]*InventoryMovement, 0, len(rows))
+	for _, row := range rows {
+		movements = append(movements, &InventoryMovement{
+			Object:      "inventory_movement",
 			ID:          row.UUID,
-			ProductID:   row.ProductUUID,
-			ProductPath: row.ProductPath,
-			ProductSKU:  row.ProductSKU,
-			Onhand:      row.Onhand,
-			Overselling: row.Overselling,
+			InventoryID: row.InventoryUUID,
+			SKU:         row.SKU,
+			Location:    row.LocationCode,
+			Reason:      row.Reason,
+			Delta:       row.Delta,
+			Balance:     row.Balance,
+			OrderID:     row.OrderUUID,
+			UserID:      row.UsrUUID,
+			Note:        row.Note,
 			Created:     row.Created,
-			Modified:    row.Modified,
-		}
-		inventory = append(inventory, &pc)
+		})
 	}
-	return inventory, nil
+	return movements, newPagination(opts, lc, len(movements), func(i int) string { return movements[i].ID }), nil
+}
+
+// optionalString returns nil for an empty string.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
@@ -0,0 +1,407 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Job types
+const (
+	JobTypeUpdateCategoriesTree     = "update_categories_tree"
+	JobTypeCalcOfferPrices          = "calc_offer_prices"
+	JobTypeUpdateProductsCategories = "update_products_categories"
+	JobTypeCatalogImport            = "catalog_import"
+)
+
+const (
+	// jobPollInterval is how often idle workers look for pending jobs.
+	jobPollInterval = 5 * time.Second
+
+	// jobHeartbeatInterval is how often a running job records a
+	// heartbeat and checks whether it has been asked to cancel.
+	jobHeartbeatInterval = 5 * time.Second
+
+	// jobStaleAfter is how long a running job can go without a heartbeat
+	// before it is claimed by another worker.
+	jobStaleAfter = 2 * time.Minute
+
+	// maxJobAttempts is the number of times a job is claimed before it
+	// is failed.
+	maxJobAttempts = 3
+
+	// jobFinishTimeout is how long a worker waits to record the outcome
+	// of a job. The outcome is recorded even if the worker is stopping.
+	jobFinishTimeout = 10 * time.Second
+)
+
+// ErrJobNotFound error
+var ErrJobNotFound = errors.New("service: job not found")
+
+// ErrJobNotCancellable is returned when cancelling a job that has
+// finished.
+var ErrJobNotCancellable = errors.New("service: job not cancellable")
+
+// JobError holds the error code and message of a failed job. The codes
+// are the same as those returned by the synchronous operations.
+type JobError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("service: job error %s: %s", e.Code, e.Message)
+}
+
+// jobErrors maps the errors of job runners to the error reported by the
+// job.
+var jobErrors = map[error]*JobError{
+	ErrCategoriesInUse:      {Code: "categories/categories-in-use", Message: "one or more categories in use - check promo rules"},
+	ErrAssocsAlreadyExist:   {Code: "assocs/assocs-exists", Message: "product to category relations already exist"},
+	ErrCategoryNotFound:     {Code: "categories/category-not-found", Message: "category not found"},
+	ErrProductNotFound:      {Code: "products/product-not-found", Message: "one or more product ids cannot be found"},
+	ErrLeafCategoryNotFound: {Code: "categories/leaf-category-not-found", Message: "one or more leaf category ids cannot be found"},
+
+	ErrCatalogImportNotFound:     {Code: "catalog-imports/catalog-import-not-found", Message: "catalog import not found"},
+	ErrCatalogImportNotResumable: {Code: "catalog-imports/catalog-import-not-resumable", Message: "catalog import is invalid or has completed"},
+}
+
+var errJobInternal = &JobError{Code: "internal-server-error", Message: "internal error"}
+
+// Job is a long-running operation run in the background. Progress counts
+// the units of work completed out of Total. Result holds the response of
+// the operation once completed and Error why it failed.
+type Job struct {
+	Object          string          `json:"object"`
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Status          string          `json:"status"`
+	Progress        int             `json:"progress"`
+	Total           int             `json:"total"`
+	Result          json.RawMessage `json:"result"`
+	Error           *JobError       `json:"error"`
+	CancelRequested bool            `json:"cancel_requested"`
+	Started         *time.Time      `json:"started"`
+	Finished        *time.Time      `json:"finished"`
+	Created         time.Time       `json:"created"`
+	Modified        time.Time       `json:"modified"`
+}
+
+func jobFromRow(row *postgres.JobRow) *Job {
+	job := Job{
+		Object:          "job",
+		ID:              row.UUID,
+		Type:            row.Typ,
+		Status:          row.Status,
+		Progress:        row.Progress,
+		Total:           row.Total,
+		Result:          row.Result,
+		CancelRequested: row.CancelRequested,
+		Started:         row.Started,
+		Finished:        row.Finished,
+		Created:         row.Created,
+		Modified:        row.Modified,
+	}
+	if row.ErrorCode != nil {
+		job.Error = &JobError{Code: *row.ErrorCode}
+		if row.ErrorMessage != nil {
+			job.Error.Message = *row.ErrorMessage
+		}
+	}
+	return &job
+}
+
+// jobRun is the job passed to a job runner. attempt identifies the claim
+// of the worker running the job.
+type jobRun struct {
+	s       *Service
+	id      string
+	attempt int
+	userID  string
+	payload []byte
+}
+
+// progress records the progress of the job out of total.
+func (j *jobRun) progress(ctx context.Context, progress, total int) error {
+	if err := j.s.model.UpdateJobProgress(ctx, j.id, j.attempt, progress, total); err != nil {
+		return errors.Wrapf(err, "service: s.model.UpdateJobProgress(ctx, jobUUID=%q, progress=%d, total=%d) failed", j.id, progress, total)
+	}
+	return nil
+}
+
+// A jobRunner runs a job returning its result. The context is cancelled
+// if the job is cancelled or the worker is stopped. Runners that return
+// a *JobError along with a result fail the job but keep the result.
+type jobRunner func(ctx context.Context, j *jobRun) (interface{}, error)
+
+func (s *Service) jobRunner(typ string) jobRunner {
+	switch typ {
+	case JobTypeUpdateCategoriesTree:
+		return s.runUpdateCategoriesTree
+	case JobTypeCalcOfferPrices:
+		return s.runCalcOfferPrices
+	case JobTypeUpdateProductsCategories:
+		return s.runUpdateProductsCategories
+	case JobTypeCatalogImport:
+		return s.runCatalogImport
+	}
+	return nil
+}
+
+// enqueueJob creates a pending job with the given payload and wakes an
+// idle worker.
+func (s *Service) enqueueJob(ctx context.Context, typ, userID string, payload interface{}) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: json marshal of %s job payload failed", typ)
+	}
+	row, err := s.model.CreateJob(ctx, typ, data, optionalString(userID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateJob(ctx, typ=%q, ...) failed", typ)
+	}
+	select {
+	case s.jobWake <- struct{}{}:
+	default:
+	}
+	return jobFromRow(row), nil
+}
+
+// GetJob returns the job with the given id.
+func (s *Service) GetJob(ctx context.Context, jobID string) (*Job, error) {
+	row, err := s.model.GetJob(ctx, jobID)
+	if err == postgres.ErrJobNotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetJob(ctx, jobUUID=%q) failed", jobID)
+	}
+	return jobFromRow(row), nil
+}
+
+// CancelJob cancels a pending job or asks the worker running a running
+// job to stop. Running jobs are cancelled once the worker notices.
+func (s *Service) CancelJob(ctx context.Context, jobID string) (*Job, error) {
+	row, err := s.model.CancelJob(ctx, jobID)
+	if err == postgres.ErrJobNotFound {
+		return nil, ErrJobNotFound
+	}
+	if err == postgres.ErrJobNotCancellable {
+		return nil, ErrJobNotCancellable
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CancelJob(ctx, jobUUID=%q) failed", jobID)
+	}
+	return jobFromRow(row), nil
+}
+
+// RunJobWorkers runs n workers that claim and run pending jobs until ctx
+// is cancelled. Jobs running when ctx is cancelled are returned to
+// pending. RunJobWorkers returns once every worker has stopped.
+func (s *Service) RunJobWorkers(ctx context.Context, n int) {
+	done := make(chan struct{})
+	for i := 0; i < n; i++ {
+		go func() {
+			s.jobWorker(ctx)
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-done
+	}
+}
+
+func (s *Service) jobWorker(ctx context.Context) {
+	for {
+		for s.runNextJob(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.jobWake:
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// runNextJob claims and runs the next job returning false if there was
+// no job to run.
+func (s *Service) runNextJob(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	contextLogger := log.WithContext(ctx)
+	row, err := s.model.ClaimJob(ctx, jobStaleAfter)
+	if err == postgres.ErrJobNotFound {
+		return false
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			contextLogger.Errorf("service: s.model.ClaimJob(ctx, staleAfter=%s) failed: %+v", jobStaleAfter, err)
+		}
+		return false
+	}
+	contextLogger.Infof("service: job %s (%s) claimed attempt %d", row.UUID, row.Typ, row.Attempts)
+	s.runJob(ctx, row)
+	return true
+}
+
+func (s *Service) runJob(ctx context.Context, row *postgres.JobRow) {
+	contextLogger := log.WithContext(ctx)
+	if row.CancelRequested {
+		s.finishJob(ctx, row.UUID, row.Attempts, postgres.JobStatusCancelled, nil, nil)
+		return
+	}
+	if row.Attempts > maxJobAttempts {
+		s.finishJob(ctx, row.UUID, row.Attempts, postgres.JobStatusFailed, nil, &JobError{
+			Code:    errJobInternal.Code,
+			Message: fmt.Sprintf("job stopped without finishing %d times", maxJobAttempts),
+		})
+		return
+	}
+	run := s.jobRunner(row.Typ)
+	if run == nil {
+		s.finishJob(ctx, row.UUID, row.Attempts, postgres.JobStatusFailed, nil, &JobError{
+			Code:    errJobInternal.Code,
+			Message: fmt.Sprintf("unknown job type %q", row.Typ),
+		})
+		return
+	}
+
+	// The heartbeat cancels the job context once the job has been asked
+	// to cancel or has been claimed by another worker.
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := make(chan struct{})
+	cancelled := make(chan struct{})
+	lost := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				cancelRequested, err := s.model.HeartbeatJob(ctx, row.UUID, row.Attempts)
+				if err == postgres.ErrJobClaimLost {
+					close(lost)
+					cancel()
+					return
+				}
+				if err != nil {
+					if ctx.Err() == nil {
+						contextLogger.Errorf("service: s.model.HeartbeatJob(ctx, jobUUID=%q) failed: %+v", row.UUID, err)
+					}
+					continue
+				}
+				if cancelRequested {
+					close(cancelled)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	var userID string
+	if row.UsrUUID != nil {
+		userID = *row.UsrUUID
+	}
+	result, err := run(jobCtx, &jobRun{s: s, id: row.UUID, attempt: row.Attempts, userID: userID, payload: row.Payload})
+	close(stop)
+
+	// Another worker owns the job so leave the outcome to it.
+	select {
+	case <-lost:
+		err = postgres.ErrJobClaimLost
+	default:
+	}
+	if errors.Cause(err) == postgres.ErrJobClaimLost {
+		contextLogger.Warnf("service: job %s (%s) claimed by another worker - stopping", row.UUID, row.Typ)
+		return
+	}
+
+	var isCancelled bool
+	select {
+	case <-cancelled:
+		isCancelled = true
+	default:
+	}
+	status, jerr, release := jobOutcome(err, isCancelled, ctx.Err() != nil)
+	if release {
+		// The worker is stopping so leave the job for another worker.
+		contextLogger.Infof("service: job %s (%s) interrupted - returning to pending", row.UUID, row.Typ)
+		releaseCtx, cancel := context.WithTimeout(context.Background(), jobFinishTimeout)
+		defer cancel()
+		if err := s.model.ReleaseJob(releaseCtx, row.UUID, row.Attempts); err != nil {
+			contextLogger.Errorf("service: s.model.ReleaseJob(ctx, jobUUID=%q) failed: %+v", row.UUID, err)
+		}
+		return
+	}
+	switch {
+	case status == postgres.JobStatusCancelled:
+		contextLogger.Infof("service: job %s (%s) cancelled", row.UUID, row.Typ)
+	case jerr == errJobInternal:
+		contextLogger.Errorf("service: job %s (%s) failed: %+v", row.UUID, row.Typ, err)
+	}
+	s.finishJob(ctx, row.UUID, row.Attempts, status, result, jerr)
+}
+
+// jobOutcome returns the final status and error of a job whose runner
+// returned err. cancelled is true if the job was asked to cancel and
+// stopping is true if the worker is stopping. If release is true the job
+// should be returned to pending instead of finished. Runners that
+// complete despite being cancelled or stopped complete the job.
+func jobOutcome(err error, cancelled, stopping bool) (status string, jerr *JobError, release bool) {
+	switch {
+	case err == nil:
+		return postgres.JobStatusCompleted, nil, false
+	case cancelled:
+		return postgres.JobStatusCancelled, nil, false
+	case stopping:
+		return "", nil, true
+	}
+	jerr, ok := err.(*JobError)
+	if !ok {
+		if jerr, ok = jobErrors[errors.Cause(err)]; !ok {
+			jerr = errJobInternal
+		}
+	}
+	return postgres.JobStatusFailed, jerr, false
+}
+
+// finishJob sets the final status, result and error of a job running
+// under the claim with the given attempt. The outcome is recorded using a
+// fresh context as ctx may have been cancelled by the worker stopping.
+func (s *Service) finishJob(ctx context.Context, jobID string, attempt int, status string, result interface{}, jerr *JobError) {
+	contextLogger := log.WithContext(ctx)
+	ctx, cancel := context.WithTimeout(context.Background(), jobFinishTimeout)
+	defer cancel()
+	var data []byte
+	if result != nil {
+		var err error
+		if data, err = json.Marshal(result); err != nil {
+			contextLogger.Errorf("service: json marshal of job %s result failed: %+v", jobID, err)
+			status, data, jerr = postgres.JobStatusFailed, nil, errJobInternal
+		}
+	}
+	var code, message *string
+	if jerr != nil {
+		code, message = &jerr.Code, &jerr.Message
+	}
+	_, err := s.model.FinishJob(ctx, jobID, attempt, status, data, code, message)
+	if err == postgres.ErrJobClaimLost {
+		contextLogger.Warnf("service: job %s claimed by another worker - outcome %s not recorded", jobID, status)
+		return
+	}
+	if err != nil {
+		contextLogger.Errorf("service: s.model.FinishJob(ctx, jobUUID=%q, status=%q, ...) failed: %+v", jobID, status, err)
+		return
+	}
+	contextLogger.Infof("service: job %s %s", jobID, status)
+}
@@ -0,0 +1,88 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobFromRow(t *testing.T) {
+	now := time.Now()
+	row := &postgres.JobRow{
+		UUID:     "2b5c1a6e-2c5d-4b8e-9b6a-7d9f3c2e1a10",
+		Typ:      JobTypeCatalogImport,
+		Status:   postgres.JobStatusRunning,
+		Progress: 100,
+		Total:    250,
+		Started:  &now,
+		Created:  now,
+		Modified: now,
+	}
+	job := jobFromRow(row)
+	assert.Equal(t, "job", job.Object)
+	assert.Equal(t, row.UUID, job.ID)
+	assert.Equal(t, JobTypeCatalogImport, job.Type)
+	assert.Equal(t, 100, job.Progress)
+	assert.Equal(t, 250, job.Total)
+	assert.Nil(t, job.Error)
+	assert.Nil(t, job.Finished)
+
+	b, err := json.Marshal(job)
+	assert.NoError(t, err)
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &m))
+	assert.Nil(t, m["result"])
+	assert.Nil(t, m["error"])
+
+	code, message := "categories/categories-in-use", "one or more categories in use - check promo rules"
+	row.Status = postgres.JobStatusFailed
+	row.Result = []byte(`{"object":"catalog_import"}`)
+	row.ErrorCode, row.ErrorMessage = &code, &message
+	job = jobFromRow(row)
+	assert.Equal(t, &JobError{Code: code, Message: message}, job.Error)
+	assert.JSONEq(t, `{"object":"catalog_import"}`, string(job.Result))
+}
+
+func TestJobOutcome(t *testing.T) {
+	status, jerr, release := jobOutcome(nil, false, false)
+	assert.Equal(t, postgres.JobStatusCompleted, status)
+	assert.Nil(t, jerr)
+	assert.False(t, release)
+
+	// runners that finish despite a cancel or shutdown complete the job
+	status, _, release = jobOutcome(nil, true, true)
+	assert.Equal(t, postgres.JobStatusCompleted, status)
+	assert.False(t, release)
+
+	status, jerr, release = jobOutcome(context.Canceled, true, false)
+	assert.Equal(t, postgres.JobStatusCancelled, status)
+	assert.Nil(t, jerr)
+	assert.False(t, release)
+
+	// a cancel takes precedence over the worker stopping
+	status, _, release = jobOutcome(context.Canceled, true, true)
+	assert.Equal(t, postgres.JobStatusCancelled, status)
+	assert.False(t, release)
+
+	_, _, release = jobOutcome(context.Canceled, false, true)
+	assert.True(t, release)
+
+	status, jerr, release = jobOutcome(errors.Wrap(ErrCategoriesInUse, "service: failed"), false, false)
+	assert.Equal(t, postgres.JobStatusFailed, status)
+	assert.Equal(t, jobErrors[ErrCategoriesInUse], jerr)
+	assert.False(t, release)
+
+	custom := &JobError{Code: "catalog-imports/invalid", Message: "2 rows are invalid"}
+	status, jerr, _ = jobOutcome(custom, false, false)
+	assert.Equal(t, postgres.JobStatusFailed, status)
+	assert.Equal(t, custom, jerr)
+
+	status, jerr, _ = jobOutcome(errors.New("boom"), false, false)
+	assert.Equal(t, postgres.JobStatusFailed, status)
+	assert.Equal(t, errJobInternal, jerr)
+}
@@ -0,0 +1,85 @@
+package firebase
+
+import (
+	"strings"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ErrOrderByInvalid is returned when a list is ordered by a field that
+// is not sortable.
+var ErrOrderByInvalid = errors.New("service: order by field invalid")
+
+// ErrCursorNotFound is returned when the start after or end before id
+// of a list is not in the list.
+var ErrCursorNotFound = errors.New("service: cursor not found")
+
+// ListOptions holds the page size, cursor and sort order of a list.
+// StartAfter is the id of the last item of the previous page and
+// EndBefore the id of the first item of the next page. OrderDir is
+// either asc or desc. If Limit is zero all items are returned.
+type ListOptions struct {
+	Limit      int
+	StartAfter string
+	EndBefore  string
+	OrderBy    string
+	OrderDir   string
+}
+
+// Pagination is the context of a page of a list. FirstID and LastID
+// are the ids of the first and last item of the page to use as the
+// end_before and start_after of the previous and next pages.
+type Pagination struct {
+	Limit    int    `json:"limit"`
+	OrderBy  string `json:"order_by,omitempty"`
+	OrderDir string `json:"order_dir,omitempty"`
+	HasPrev  bool   `json:"has_prev"`
+	HasNext  bool   `json:"has_next"`
+	FirstID  string `json:"first_id,omitempty"`
+	LastID   string `json:"last_id,omitempty"`
+}
+
+func (o *ListOptions) model() *postgres.ListOptions {
+	if o == nil {
+		return nil
+	}
+	return &postgres.ListOptions{
+		Limit:      o.Limit,
+		StartAfter: o.StartAfter,
+		EndBefore:  o.EndBefore,
+		OrderBy:    o.OrderBy,
+		OrderDir:   postgres.OrderDirection(strings.ToUpper(o.OrderDir)),
+	}
+}
+
+// listError returns the service error of a model list error or nil if
+// err is not a list error.
+func listError(err error) error {
+	if err == postgres.ErrOrderByInvalid {
+		return ErrOrderByInvalid
+	}
+	if err == postgres.ErrCursorNotFound {
+		return ErrCursorNotFound
+	}
+	return nil
+}
+
+// newPagination returns the pagination context of a page of n items.
+// id returns the id of the item at index i.
+func newPagination(o *ListOptions, lc *postgres.ListContext, n int, id func(i int) string) *Pagination {
+	p := Pagination{
+		HasPrev: lc.HasPrev,
+		HasNext: lc.HasNext,
+	}
+	if o != nil {
+		p.Limit = o.Limit
+		p.OrderBy = o.OrderBy
+		p.OrderDir = o.OrderDir
+	}
+	if n > 0 {
+		p.FirstID = id(0)
+		p.LastID = id(n - 1)
+	}
+	return &p
+}
@@ -0,0 +1,47 @@
+package firebase
+
+import (
+	"testing"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPagination(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+	id := func(i int) string { return ids[i] }
+
+	opts := ListOptions{Limit: 3, OrderBy: "created", OrderDir: "desc"}
+	p := newPagination(&opts, &postgres.ListContext{HasNext: true}, len(ids), id)
+	assert.Equal(t, &Pagination{
+		Limit:    3,
+		OrderBy:  "created",
+		OrderDir: "desc",
+		HasNext:  true,
+		FirstID:  "a",
+		LastID:   "c",
+	}, p)
+
+	p = newPagination(nil, &postgres.ListContext{}, 0, id)
+	assert.Equal(t, &Pagination{}, p)
+}
+
+func TestListOptionsModel(t *testing.T) {
+	var opts *ListOptions
+	assert.Nil(t, opts.model())
+
+	opts = &ListOptions{Limit: 10, EndBefore: "x", OrderBy: "sku", OrderDir: "desc"}
+	assert.Equal(t, &postgres.ListOptions{
+		Limit:     10,
+		EndBefore: "x",
+		OrderBy:   "sku",
+		OrderDir:  postgres.OrderDesc,
+	}, opts.model())
+}
+
+func TestListError(t *testing.T) {
+	assert.Equal(t, ErrOrderByInvalid, listError(postgres.ErrOrderByInvalid))
+	assert.Equal(t, ErrCursorNotFound, listError(postgres.ErrCursorNotFound))
+	assert.Nil(t, listError(nil))
+	assert.Nil(t, listError(postgres.ErrProductNotFound))
+}
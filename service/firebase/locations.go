@@ -0,0 +1,110 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ErrLocationNotFound error
+var ErrLocationNotFound = errors.New("service: location not found")
+
+// ErrLocationCodeExists error for duplicates.
+var ErrLocationCodeExists = errors.New("service: location code exists")
+
+// Location holds a single stock location such as a warehouse or retail
+// shop. Stock is allocated from locations with a lower priority first.
+type Location struct {
+	Object      string    `json:"object"`
+	ID          string    `json:"id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	CountryCode string    `json:"country_code"`
+	Priority    int       `json:"priority"`
+	Active      bool      `json:"active"`
+	Created     time.Time `json:"created"`
+	Modified    time.Time `json:"modified"`
+}
+
+func locationFromRow(row *postgres.LocationRow) *Location {
+	return &Location{
+		Object:      "location",
+		ID:          row.UUID,
+		Code:        row.Code,
+		Name:        row.Name,
+		CountryCode: row.CountryCode,
+		Priority:    row.Priority,
+		Active:      row.Active,
+		Created:     row.Created,
+		Modified:    row.Modified,
+	}
+}
+
+// CreateLocation creates a new stock location.
+func (s *Service) CreateLocation(ctx context.Context, code, name, countryCode string, priority int, active bool) (*Location, error) {
+	row, err := s.model.CreateLocation(ctx, code, name, countryCode, priority, active)
+	if err == postgres.ErrLocationCodeExists {
+		return nil, ErrLocationCodeExists
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateLocation(ctx, code=%q, name=%q, countryCode=%q, priority=%d, active=%t) failed", code, name, countryCode, priority, active)
+	}
+	return locationFromRow(row), nil
+}
+
+// GetLocation returns a single stock location by id.
+func (s *Service) GetLocation(ctx context.Context, locationID string) (*Location, error) {
+	row, err := s.model.GetLocationByUUID(ctx, locationID)
+	if err == postgres.ErrLocationNotFound {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetLocationByUUID(ctx, locationUUID=%q) failed", locationID)
+	}
+	return locationFromRow(row), nil
+}
+
+// GetLocationByCode returns a single stock location by code.
+func (s *Service) GetLocationByCode(ctx context.Context, code string) (*Location, error) {
+	row, err := s.model.GetLocationByCode(ctx, code)
+	if err == postgres.ErrLocationNotFound {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetLocationByCode(ctx, code=%q) failed", code)
+	}
+	return locationFromRow(row), nil
+}
+
+// GetLocations returns a page of stock locations.
+func (s *Service) GetLocations(ctx context.Context, opts *ListOptions) ([]*Location, *Pagination, error) {
+	rows, lc, err := s.model.GetLocations(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "service: s.model.GetLocations(ctx) failed")
+	}
+	locations := make([]*Location, 0, len(rows))
+	for _, row := range rows {
+		locations = append(locations, locationFromRow(row))
+	}
+	return locations, newPagination(opts, lc, len(locations), func(i int) string { return locations[i].ID }), nil
+}
+
+// UpdateLocation updates a stock location.
+func (s *Service) UpdateLocation(ctx context.Context, locationID, code, name, countryCode string, priority int, active bool) (*Location, error) {
+	row, err := s.model.UpdateLocation(ctx, locationID, code, name, countryCode, priority, active)
+	if err == postgres.ErrLocationNotFound {
+		return nil, ErrLocationNotFound
+	}
+	if err == postgres.ErrLocationCodeExists {
+		return nil, ErrLocationCodeExists
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.UpdateLocation(ctx, locationUUID=%q, ...) failed", locationID)
+	}
+	return locationFromRow(row), nil
+}
@@ -2,6 +2,7 @@ package firebase
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
@@ -26,26 +27,66 @@ type Offer struct {
 
 // ActivateOffer creates an offer from a promo rule.
 func (s *Service) ActivateOffer(ctx context.Context, promoRuleID string) (*Offer, error) {
-	row, err := s.model.AddOffer(ctx, promoRuleID)
-	if err == postgres.ErrPromoRuleNotFound {
-		return nil, ErrPromoRuleNotFound
+	row, err := s.addOffer(ctx, promoRuleID)
+	if err != nil {
+		return nil, err
 	}
-	if err == postgres.ErrOfferExists {
-		return nil, ErrOfferExists
+
+	err = s.model.CalcOfferPrices(ctx)
+	if err == postgres.ErrCategoryNotFound {
+		return nil, ErrCategoryNotFound
 	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "service: s.model.AddOffer(ctx, promoRuleUUID=%q) failed", promoRuleID)
+		return nil, errors.Wrap(err, "service: s.model.CalcOfferPrices(ctx) failed")
 	}
+	return offerFromRow(row), nil
+}
 
-	err = s.model.CalcOfferPrices(ctx)
+// ActivateOfferAsync creates an offer from a promo rule and creates a
+// job to calculate the offer prices in the background. The result of
+// the job is the offer.
+func (s *Service) ActivateOfferAsync(ctx context.Context, userID, promoRuleID string) (*Job, error) {
+	row, err := s.addOffer(ctx, promoRuleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueueJob(ctx, JobTypeCalcOfferPrices, userID, offerFromRow(row))
+}
+
+func (s *Service) runCalcOfferPrices(ctx context.Context, j *jobRun) (interface{}, error) {
+	var offer Offer
+	if err := json.Unmarshal(j.payload, &offer); err != nil {
+		return nil, errors.Wrap(err, "service: json unmarshal of offer failed")
+	}
+	if err := j.progress(ctx, 0, 1); err != nil {
+		return nil, err
+	}
+	err := s.model.CalcOfferPrices(ctx)
 	if err == postgres.ErrCategoryNotFound {
 		return nil, ErrCategoryNotFound
 	}
 	if err != nil {
 		return nil, errors.Wrap(err, "service: s.model.CalcOfferPrices(ctx) failed")
 	}
+	return &offer, j.progress(ctx, 1, 1)
+}
 
-	offer := Offer{
+func (s *Service) addOffer(ctx context.Context, promoRuleID string) (*postgres.OfferJoinRow, error) {
+	row, err := s.model.AddOffer(ctx, promoRuleID)
+	if err == postgres.ErrPromoRuleNotFound {
+		return nil, ErrPromoRuleNotFound
+	}
+	if err == postgres.ErrOfferExists {
+		return nil, ErrOfferExists
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.AddOffer(ctx, promoRuleUUID=%q) failed", promoRuleID)
+	}
+	return row, nil
+}
+
+func offerFromRow(row *postgres.OfferJoinRow) *Offer {
+	return &Offer{
 		Object:        "offer",
 		ID:            row.UUID,
 		PromoRuleID:   row.PromoRuleUUID,
@@ -53,7 +94,6 @@ func (s *Service) ActivateOffer(ctx context.Context, promoRuleID string) (*Offer
 		Created:       row.Created,
 		Modified:      row.Modified,
 	}
-	return &offer, nil
 }
 
 // GetOffer returns an offer by offer id.
@@ -76,11 +116,14 @@ func (s *Service) GetOffer(ctx context.Context, offerID string) (*Offer, error)
 	return &offer, nil
 }
 
-// GetOffers returns a slice of offers.
-func (s *Service) GetOffers(ctx context.Context) ([]*Offer, error) {
-	rows, err := s.model.GetOffers(ctx)
+// GetOffers returns a page of offers.
+func (s *Service) GetOffers(ctx context.Context, opts *ListOptions) ([]*Offer, *Pagination, error) {
+	rows, lc, err := s.model.GetOffers(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err,
+		return nil, nil, errors.Wrapf(err,
 			"service: s.model.GetPriceLists(ctx) failed")
 	}
 	offers := make([]*Offer, 0, len(rows))
@@ -95,7 +138,7 @@ func (s *Service) GetOffers(ctx context.Context) ([]*Offer, error) {
 		}
 		offers = append(offers, &offer)
 	}
-	return offers, nil
+	return offers, newPagination(opts, lc, len(offers), func(i int) string { return offers[i].ID }), nil
 }
 
 // DeactivateOffer deactivates an existing offer.
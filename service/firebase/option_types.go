@@ -0,0 +1,96 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ErrOptionTypeNotFound error
+var ErrOptionTypeNotFound = errors.New("service: option type not found")
+
+// ErrOptionTypeCodeExists error for duplicates.
+var ErrOptionTypeCodeExists = errors.New("service: option type code exists")
+
+// ErrOptionTypeInUse error
+var ErrOptionTypeInUse = errors.New("service: option type is used by product variants")
+
+// OptionType is a way the variants of a product differ such as size
+// or colour.
+type OptionType struct {
+	Object   string    `json:"object"`
+	ID       string    `json:"id"`
+	Code     string    `json:"code"`
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+func optionTypeFromRow(row *postgres.OptionTypeRow) *OptionType {
+	return &OptionType{
+		Object:   "option_type",
+		ID:       row.UUID,
+		Code:     row.Code,
+		Name:     row.Name,
+		Created:  row.Created,
+		Modified: row.Modified,
+	}
+}
+
+// CreateOptionType creates a new option type.
+func (s *Service) CreateOptionType(ctx context.Context, code, name string) (*OptionType, error) {
+	row, err := s.model.CreateOptionType(ctx, code, name)
+	if err == postgres.ErrOptionTypeCodeExists {
+		return nil, ErrOptionTypeCodeExists
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateOptionType(ctx, code=%q, name=%q) failed", code, name)
+	}
+	return optionTypeFromRow(row), nil
+}
+
+// GetOptionType returns a single option type by id.
+func (s *Service) GetOptionType(ctx context.Context, optionTypeID string) (*OptionType, error) {
+	row, err := s.model.GetOptionTypeByUUID(ctx, optionTypeID)
+	if err == postgres.ErrOptionTypeNotFound {
+		return nil, ErrOptionTypeNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetOptionTypeByUUID(ctx, optionTypeUUID=%q) failed", optionTypeID)
+	}
+	return optionTypeFromRow(row), nil
+}
+
+// GetOptionTypes returns a page of option types.
+func (s *Service) GetOptionTypes(ctx context.Context, opts *ListOptions) ([]*OptionType, *Pagination, error) {
+	rows, lc, err := s.model.GetOptionTypes(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "service: s.model.GetOptionTypes(ctx) failed")
+	}
+	optionTypes := make([]*OptionType, 0, len(rows))
+	for _, row := range rows {
+		optionTypes = append(optionTypes, optionTypeFromRow(row))
+	}
+	return optionTypes, newPagination(opts, lc, len(optionTypes), func(i int) string { return optionTypes[i].ID }), nil
+}
+
+// DeleteOptionType deletes an option type that is not used by any
+// product variants.
+func (s *Service) DeleteOptionType(ctx context.Context, optionTypeID string) error {
+	err := s.model.DeleteOptionType(ctx, optionTypeID)
+	if err == postgres.ErrOptionTypeNotFound {
+		return ErrOptionTypeNotFound
+	}
+	if err == postgres.ErrOptionTypeInUse {
+		return ErrOptionTypeInUse
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.DeleteOptionType(ctx, optionTypeUUID=%q) failed", optionTypeID)
+	}
+	return nil
+}
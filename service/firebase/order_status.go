@@ -0,0 +1,180 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Order status values.
+const (
+	OrderStatusPending          = "pending"
+	OrderStatusPaid             = "paid"
+	OrderStatusProcessing       = "processing"
+	OrderStatusPartiallyShipped = "partially_shipped"
+	OrderStatusShipped          = "shipped"
+	OrderStatusDelivered        = "delivered"
+	OrderStatusCancelled        = "cancelled"
+	OrderStatusRefunded         = "refunded"
+)
+
+// ErrOrderStatusInvalid is returned when the order status is not one of
+// the known order status values.
+var ErrOrderStatusInvalid = errors.New("service: order status invalid")
+
+// ErrOrderTransitionInvalid is returned when an order cannot be moved
+// from its current status to the requested status.
+var ErrOrderTransitionInvalid = errors.New("service: order status transition invalid")
+
+// ErrOrderStatusChanged is returned when the order status was changed by
+// another request whilst being updated.
+var ErrOrderStatusChanged = errors.New("service: order status changed")
+
+// orderTransitions holds the statuses an order can move to from each
+// status. Cancelled and refunded orders cannot be moved on.
+var orderTransitions = map[string][]string{
+	OrderStatusPending: {
+		OrderStatusPaid,
+		OrderStatusCancelled,
+	},
+	OrderStatusPaid: {
+		OrderStatusProcessing,
+		OrderStatusPartiallyShipped,
+		OrderStatusShipped,
+		OrderStatusCancelled,
+		OrderStatusRefunded,
+	},
+	OrderStatusProcessing: {
+		OrderStatusPartiallyShipped,
+		OrderStatusShipped,
+		OrderStatusCancelled,
+		OrderStatusRefunded,
+	},
+	OrderStatusPartiallyShipped: {
+		OrderStatusShipped,
+		OrderStatusRefunded,
+	},
+	OrderStatusShipped: {
+		OrderStatusDelivered,
+		OrderStatusRefunded,
+	},
+	OrderStatusDelivered: {
+		OrderStatusRefunded,
+	},
+	OrderStatusCancelled: {},
+	OrderStatusRefunded:  {},
+}
+
+// OrderStatusChange holds a single change of status of an order.
+// FromStatus is nil for the change recorded when the order is placed.
+type OrderStatusChange struct {
+	Object     string    `json:"object"`
+	FromStatus *string   `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Note       *string   `json:"note,omitempty"`
+	Created    time.Time `json:"created"`
+}
+
+// OrderUpdatedEventData is published with the order.updated event.
+type OrderUpdatedEventData struct {
+	*Order
+	PreviousStatus string `json:"previous_status"`
+}
+
+// IsValidOrderStatus returns true if status is a known order status.
+func IsValidOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// validOrderTransition returns true if an order with the from status can
+// be moved to the to status.
+func validOrderTransition(from, to string) bool {
+	for _, s := range orderTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateOrderStatus moves an order to a new status and records the change
+// in the order status history. An order.updated event is published
+// holding the previous status. Returns ErrOrderNotFound,
+// ErrOrderStatusInvalid, ErrOrderTransitionInvalid if the transition is
+// not allowed from the current status or ErrOrderStatusChanged if the
+// order was updated by another request in the meantime.
+func (s *Service) UpdateOrderStatus(ctx context.Context, orderID, status string, note *string) (*Order, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: UpdateOrderStatus(ctx, orderID=%q, status=%q, note=%v) started", orderID, status, note)
+
+	if !IsValidOrderStatus(status) {
+		return nil, ErrOrderStatusInvalid
+	}
+
+	order, err := s.GetOrder(ctx, orderID)
+	if err == ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.GetOrder(ctx, orderID=%q) failed", orderID)
+	}
+	previous := order.Status
+	if !validOrderTransition(previous, status) {
+		return nil, ErrOrderTransitionInvalid
+	}
+
+	err = s.model.UpdateOrderStatus(ctx, orderID, previous, status, note)
+	if err == postgres.ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err == postgres.ErrOrderStatusChanged {
+		return nil, ErrOrderStatusChanged
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.UpdateOrderStatus(ctx, orderUUID=%q, from=%q, to=%q, ...) failed", orderID, previous, status)
+	}
+
+	order, err = s.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.GetOrder(ctx, orderID=%q) failed", orderID)
+	}
+
+	data := OrderUpdatedEventData{
+		Order:          order,
+		PreviousStatus: previous,
+	}
+	if err := s.PublishTopicEvent(ctx, EventOrderUpdated, &data); err != nil {
+		return nil, errors.Wrapf(err,
+			"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+			EventOrderUpdated, data)
+	}
+	contextLogger.Infof("service: EventOrderUpdated published")
+	return order, nil
+}
+
+// GetOrderStatusHistory returns the status changes of an order oldest
+// first. Returns ErrOrderNotFound if the order does not exist.
+func (s *Service) GetOrderStatusHistory(ctx context.Context, orderID string) ([]*OrderStatusChange, error) {
+	rows, err := s.model.GetOrderStatusHistoryByUUID(ctx, orderID)
+	if err == postgres.ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetOrderStatusHistoryByUUID(ctx, orderUUID=%q) failed", orderID)
+	}
+	history := make([]*OrderStatusChange, 0, len(rows))
+	for _, h := range rows {
+		history = append(history, &OrderStatusChange{
+			Object:     "order_status_change",
+			FromStatus: h.FromStatus,
+			ToStatus:   h.ToStatus,
+			Note:       h.Note,
+			Created:    h.Created,
+		})
+	}
+	return history, nil
+}
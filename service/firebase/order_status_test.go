@@ -0,0 +1,31 @@
+package firebase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidOrderTransition(t *testing.T) {
+	assert.True(t, validOrderTransition(OrderStatusPending, OrderStatusPaid))
+	assert.True(t, validOrderTransition(OrderStatusPaid, OrderStatusProcessing))
+	assert.True(t, validOrderTransition(OrderStatusPaid, OrderStatusShipped))
+	assert.True(t, validOrderTransition(OrderStatusPaid, OrderStatusPartiallyShipped))
+	assert.True(t, validOrderTransition(OrderStatusProcessing, OrderStatusPartiallyShipped))
+	assert.True(t, validOrderTransition(OrderStatusPartiallyShipped, OrderStatusShipped))
+	assert.True(t, validOrderTransition(OrderStatusShipped, OrderStatusDelivered))
+	assert.True(t, validOrderTransition(OrderStatusDelivered, OrderStatusRefunded))
+
+	assert.False(t, validOrderTransition(OrderStatusPending, OrderStatusShipped))
+	assert.False(t, validOrderTransition(OrderStatusShipped, OrderStatusCancelled))
+	assert.False(t, validOrderTransition(OrderStatusPaid, OrderStatusPaid))
+	assert.False(t, validOrderTransition(OrderStatusCancelled, OrderStatusPending))
+	assert.False(t, validOrderTransition(OrderStatusRefunded, OrderStatusPaid))
+	assert.False(t, validOrderTransition("completed", OrderStatusPaid))
+}
+
+func TestIsValidOrderStatus(t *testing.T) {
+	assert.True(t, IsValidOrderStatus(OrderStatusPartiallyShipped))
+	assert.False(t, IsValidOrderStatus("incomplete"))
+	assert.False(t, IsValidOrderStatus(""))
+}
@@ -2,6 +2,7 @@ package firebase
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
@@ -13,6 +14,16 @@ import (
 // empty cart.
 var ErrCartEmpty = errors.New("service: failed to place an order with empty cart")
 
+// InsufficientStockError is returned when an order cannot be placed
+// because there is not enough stock of the products with the given SKUs.
+type InsufficientStockError struct {
+	SKUs []string
+}
+
+func (e *InsufficientStockError) Error() string {
+	return "service: insufficient stock for " + strings.Join(e.SKUs, ", ")
+}
+
 // ErrOrderNotFound error.
 var ErrOrderNotFound = errors.New("service: order not found")
 
@@ -43,18 +54,28 @@ type OrderAddress struct {
 
 // OrderItem contains details of a line item within an Order.
 type OrderItem struct {
-	Object    string     `json:"object"`
-	ID        string     `json:"id"`
-	Path      string     `json:"path"`
-	SKU       string     `json:"sku"`
-	Name      string     `json:"name"`
-	Qty       int        `json:"qty"`
-	UnitPrice int        `json:"unit_price"`
-	Currency  string     `json:"currency"`
-	Discount  *int       `json:"discount,omitempty"`
-	TaxCode   string     `json:"tax_code"`
-	VAT       int        `json:"vat"`
-	Created   *time.Time `json:"created,omitempty"`
+	Object        string     `json:"object"`
+	ID            string     `json:"id"`
+	Path          string     `json:"path"`
+	SKU           string     `json:"sku"`
+	Name          string     `json:"name"`
+	Qty           int        `json:"qty"`
+	UnitPrice     int        `json:"unit_price"`
+	Currency      string     `json:"currency"`
+	Discount      *int       `json:"discount,omitempty"`
+	OrderDiscount int        `json:"order_discount"`
+	TaxCode       string     `json:"tax_code"`
+	VAT           int        `json:"vat"`
+	Backordered   int        `json:"backordered"`
+	Created       *time.Time `json:"created,omitempty"`
+}
+
+// OrderShipping contains the shipping charge for an order.
+type OrderShipping struct {
+	ShippingCode string `json:"shipping_code"`
+	Price        int    `json:"price"`
+	Discount     int    `json:"discount"`
+	VAT          int    `json:"vat"`
 }
 
 // OrderUser contains details of the guest or user that placed the order.
@@ -66,26 +87,43 @@ type OrderUser struct {
 
 // Order contains details of an existing order.
 type Order struct {
-	Object      string        `json:"object"`
-	ID          string        `json:"id"`
-	OrderID     int           `json:"order_id"`
-	Status      string        `json:"status"`
-	Payment     string        `json:"payment"`
-	User        *OrderUser    `json:"user"`
-	Billing     *OrderAddress `json:"billing_address"`
-	Shipping    *OrderAddress `json:"shipping_address"`
-	Currency    string        `json:"currency"`
-	TotalExVAT  int           `json:"total_ex_vat"`
-	VATTotal    int           `json:"vat_total"`
-	TotalIncVAT int           `json:"total_inc_vat"`
-	Items       []*OrderItem  `json:"items"`
-	Created     time.Time     `json:"created"`
-	Modified    time.Time     `json:"modified"`
+	Object         string         `json:"object"`
+	ID             string         `json:"id"`
+	OrderID        int            `json:"order_id"`
+	Status         string         `json:"status"`
+	Payment        string         `json:"payment"`
+	User           *OrderUser     `json:"user"`
+	Billing        *OrderAddress  `json:"billing_address"`
+	Shipping       *OrderAddress  `json:"shipping_address"`
+	Currency       string         `json:"currency"`
+	IncTax         bool           `json:"inc_tax"`
+	Discount       int            `json:"discount"`
+	ShippingCharge *OrderShipping `json:"shipping_charge,omitempty"`
+	TotalExVAT     int            `json:"total_ex_vat"`
+	VATTotal       int            `json:"vat_total"`
+	TotalIncVAT    int            `json:"total_inc_vat"`
+	Items          []*OrderItem   `json:"items"`
+	Created        time.Time      `json:"created"`
+	Modified       time.Time      `json:"modified"`
 }
 
-// PlaceGuestOrder places a new guest order.
+func orderShippingFromRow(row *postgres.OrderRow) *OrderShipping {
+	if row.ShippingCode == nil {
+		return nil
+	}
+	return &OrderShipping{
+		ShippingCode: *row.ShippingCode,
+		Price:        row.ShippingPrice,
+		Discount:     row.ShippingDiscount,
+		VAT:          row.ShippingVAT,
+	}
+}
+
+// PlaceGuestOrder places a new guest order. Any active offers and coupons
+// applied to the cart are used to price the order. If shippingTariffID is
+// not nil the shipping charge is added to the order.
 func (s *Service) PlaceGuestOrder(ctx context.Context, cartID, contactName,
-	email string, billing, shipping *NewOrderAddressRequest) (*Order, error) {
+	email string, billing, shipping *NewOrderAddressRequest, shippingTariffID *string) (*Order, error) {
 	contextLogger := log.WithContext(ctx)
 	contextLogger.Debugf("service: PlaceGuestOrder(ctx, cartID=%q, contactName=%s, email=%s, ...)",
 		cartID, contactName, email)
@@ -109,14 +147,29 @@ func (s *Service) PlaceGuestOrder(ctx context.Context, cartID, contactName,
 		CountryCode: *shipping.CountryCode,
 	}
 
-	orow, oirows, bill, ship, err := s.model.AddGuestOrder(ctx,
-		cartID, contactName, email, &pgBilling, &pgShipping)
+	orow, oirows, bill, ship, alerts, err := s.model.AddGuestOrder(ctx,
+		cartID, contactName, email, &pgBilling, &pgShipping, shippingTariffID)
 	if err == postgres.ErrCartNotFound {
 		return nil, ErrCartNotFound
 	}
 	if err == postgres.ErrCartEmpty {
 		return nil, ErrCartEmpty
 	}
+	if e, ok := err.(*postgres.InsufficientStockError); ok {
+		return nil, &InsufficientStockError{SKUs: e.SKUs}
+	}
+	if err == postgres.ErrShippingTariffNotFound {
+		return nil, ErrShippingTariffNotFound
+	}
+	if err == postgres.ErrCouponUsed {
+		return nil, ErrCouponUsed
+	}
+	if err == postgres.ErrTaxRateNotFound {
+		return nil, ErrTaxRateNotFound
+	}
+	if err == postgres.ErrCartProductUnpublished {
+		return nil, ErrCartProductUnpublished
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "service: s.model.AddGuestOrder(ctx, ...)")
 
@@ -125,18 +178,20 @@ func (s *Service) PlaceGuestOrder(ctx context.Context, cartID, contactName,
 	orderItems := make([]*OrderItem, 0, len(oirows))
 	for _, row := range oirows {
 		oi := OrderItem{
-			Object:    "order_item",
-			ID:        row.UUID,
-			Path:      row.Path,
-			SKU:       row.SKU,
-			Name:      row.Name,
-			Qty:       row.Qty,
-			UnitPrice: row.UnitPrice,
-			Currency:  row.Currency,
-			Discount:  row.Discount,
-			TaxCode:   row.TaxCode,
-			VAT:       row.VAT,
-			Created:   &row.Created,
+			Object:        "order_item",
+			ID:            row.UUID,
+			Path:          row.Path,
+			SKU:           row.SKU,
+			Name:          row.Name,
+			Qty:           row.Qty,
+			UnitPrice:     row.UnitPrice,
+			Currency:      row.Currency,
+			Discount:      row.Discount,
+			OrderDiscount: row.OrderDiscount,
+			TaxCode:       row.TaxCode,
+			VAT:           row.VAT,
+			Backordered:   row.Backordered,
+			Created:       &row.Created,
 		}
 		orderItems = append(orderItems, &oi)
 	}
@@ -169,13 +224,16 @@ func (s *Service) PlaceGuestOrder(ctx context.Context, cartID, contactName,
 			Postcode:    ship.Postcode,
 			Country:     ship.CountryCode,
 		},
-		Currency:    orow.Currency,
-		TotalExVAT:  orow.TotalExVAT,
-		VATTotal:    orow.VATTotal,
-		TotalIncVAT: orow.TotalIncVAT,
-		Items:       orderItems,
-		Created:     orow.Created,
-		Modified:    orow.Modified,
+		Currency:       orow.Currency,
+		IncTax:         orow.IncTax,
+		Discount:       orow.Discount,
+		ShippingCharge: orderShippingFromRow(orow),
+		TotalExVAT:     orow.TotalExVAT,
+		VATTotal:       orow.VATTotal,
+		TotalIncVAT:    orow.TotalIncVAT,
+		Items:          orderItems,
+		Created:        orow.Created,
+		Modified:       orow.Modified,
 	}
 	if err := s.PublishTopicEvent(ctx, EventOrderCreated, &order); err != nil {
 		return nil, errors.Wrapf(err,
@@ -183,25 +241,46 @@ func (s *Service) PlaceGuestOrder(ctx context.Context, cartID, contactName,
 			EventOrderCreated, order)
 	}
 	contextLogger.Infof("service: EventOrderCreated published")
+
+	if err := s.publishStockAlerts(ctx, alerts); err != nil {
+		return nil, err
+	}
 	return &order, nil
 }
 
-// PlaceOrder places a new order in the system for an existing user.
-func (s *Service) PlaceOrder(ctx context.Context, cartID, userID, billingID, shippingID string) (*Order, error) {
+// PlaceOrder places a new order in the system for an existing user. Any
+// active offers and coupons applied to the cart are used to price the order.
+// If shippingTariffID is not nil the shipping charge is added to the order.
+func (s *Service) PlaceOrder(ctx context.Context, cartID, userID, billingID, shippingID string, shippingTariffID *string) (*Order, error) {
 	contextLogger := log.WithContext(ctx)
 	contextLogger.Debugf("service: PlaceOrder(ctx, cartID=%q, customerID=%q, billingID=%q, shippingID=%q)",
 		cartID, userID, billingID, shippingID)
 
-	orow, oirows, urow, bill, ship, err := s.model.AddOrder(ctx, cartID, userID, billingID, shippingID)
+	orow, oirows, urow, bill, ship, alerts, err := s.model.AddOrder(ctx, cartID, userID, billingID, shippingID, shippingTariffID)
 	if err == postgres.ErrCartNotFound {
 		return nil, ErrCartNotFound
 	}
 	if err == postgres.ErrCartEmpty {
 		return nil, ErrCartEmpty
 	}
+	if e, ok := err.(*postgres.InsufficientStockError); ok {
+		return nil, &InsufficientStockError{SKUs: e.SKUs}
+	}
 	if err == postgres.ErrAddressNotFound {
 		return nil, ErrAddressNotFound
 	}
+	if err == postgres.ErrShippingTariffNotFound {
+		return nil, ErrShippingTariffNotFound
+	}
+	if err == postgres.ErrCouponUsed {
+		return nil, ErrCouponUsed
+	}
+	if err == postgres.ErrTaxRateNotFound {
+		return nil, ErrTaxRateNotFound
+	}
+	if err == postgres.ErrCartProductUnpublished {
+		return nil, ErrCartProductUnpublished
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "service: s.model.AddOrder(ctx, ...) failed")
 	}
@@ -209,18 +288,20 @@ func (s *Service) PlaceOrder(ctx context.Context, cartID, userID, billingID, shi
 	orderItems := make([]*OrderItem, 0, len(oirows))
 	for _, row := range oirows {
 		oi := OrderItem{
-			Object:    "order_item",
-			ID:        row.UUID,
-			Path:      row.Path,
-			SKU:       row.SKU,
-			Name:      row.Name,
-			Qty:       row.Qty,
-			UnitPrice: row.UnitPrice,
-			Currency:  row.Currency,
-			Discount:  row.Discount,
-			TaxCode:   row.TaxCode,
-			VAT:       row.VAT,
-			Created:   &row.Created,
+			Object:        "order_item",
+			ID:            row.UUID,
+			Path:          row.Path,
+			SKU:           row.SKU,
+			Name:          row.Name,
+			Qty:           row.Qty,
+			UnitPrice:     row.UnitPrice,
+			Currency:      row.Currency,
+			Discount:      row.Discount,
+			OrderDiscount: row.OrderDiscount,
+			TaxCode:       row.TaxCode,
+			VAT:           row.VAT,
+			Backordered:   row.Backordered,
+			Created:       &row.Created,
 		}
 		orderItems = append(orderItems, &oi)
 	}
@@ -252,13 +333,16 @@ func (s *Service) PlaceOrder(ctx context.Context, cartID, userID, billingID, shi
 			Postcode:    ship.Postcode,
 			Country:     ship.CountryCode,
 		},
-		Currency:    orow.Currency,
-		TotalExVAT:  orow.TotalExVAT,
-		VATTotal:    orow.VATTotal,
-		TotalIncVAT: orow.TotalIncVAT,
-		Items:       orderItems,
-		Created:     orow.Created,
-		Modified:    orow.Modified,
+		Currency:       orow.Currency,
+		IncTax:         orow.IncTax,
+		Discount:       orow.Discount,
+		ShippingCharge: orderShippingFromRow(orow),
+		TotalExVAT:     orow.TotalExVAT,
+		VATTotal:       orow.VATTotal,
+		TotalIncVAT:    orow.TotalIncVAT,
+		Items:          orderItems,
+		Created:        orow.Created,
+		Modified:       orow.Modified,
 	}
 	if err := s.PublishTopicEvent(ctx, EventOrderCreated, &order); err != nil {
 		return nil, errors.Wrapf(err,
@@ -266,38 +350,49 @@ func (s *Service) PlaceOrder(ctx context.Context, cartID, userID, billingID, shi
 			EventOrderCreated, order)
 	}
 	contextLogger.Infof("service: EventOrderCreated published")
+
+	if err := s.publishStockAlerts(ctx, alerts); err != nil {
+		return nil, err
+	}
 	return &order, nil
 }
 
-// GetOrders returns a list of order summaries.
-func (s *Service) GetOrders(ctx context.Context) ([]*Order, error) {
+// GetOrders returns a page of order summaries. If status is not empty
+// only orders with the status are returned.
+func (s *Service) GetOrders(ctx context.Context, opts *ListOptions, status string) ([]*Order, *Pagination, error) {
 	contextLogger := log.WithContext(ctx)
-	contextLogger.Debug("service: GetOrders(ctx)")
+	contextLogger.Debugf("service: GetOrders(ctx, opts=%+v, status=%q)", opts, status)
 
-	rows, err := s.model.GetOrders(ctx)
+	rows, lc, err := s.model.GetOrders(ctx, opts.model(), status)
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "service: s.model.GetOrders(ctx)")
+		return nil, nil, errors.Wrapf(err, "service: s.model.GetOrders(ctx)")
 	}
 	contextLogger.Debugf("service: s.model.GetOrders(ctx) returned %d rows", len(rows))
 
 	orders := make([]*Order, 0, len(rows))
 	for _, row := range rows {
 		o := Order{
-			Object:      "order",
-			ID:          row.UUID,
-			OrderID:     row.ID,
-			Status:      row.Status,
-			Payment:     row.Payment,
-			Currency:    row.Currency,
-			TotalExVAT:  row.TotalExVAT,
-			VATTotal:    row.VATTotal,
-			TotalIncVAT: row.TotalIncVAT,
-			Created:     row.Created,
-			Modified:    row.Modified,
+			Object:         "order",
+			ID:             row.UUID,
+			OrderID:        row.ID,
+			Status:         row.Status,
+			Payment:        row.Payment,
+			Currency:       row.Currency,
+			IncTax:         row.IncTax,
+			Discount:       row.Discount,
+			ShippingCharge: orderShippingFromRow(row),
+			TotalExVAT:     row.TotalExVAT,
+			VATTotal:       row.VATTotal,
+			TotalIncVAT:    row.TotalIncVAT,
+			Created:        row.Created,
+			Modified:       row.Modified,
 		}
 		orders = append(orders, &o)
 	}
-	return orders, nil
+	return orders, newPagination(opts, lc, len(orders), func(i int) string { return orders[i].ID }), nil
 }
 
 // GetOrder returns an order by order ID or nil if an error occurred.
@@ -321,18 +416,20 @@ func (s *Service) GetOrder(ctx context.Context, orderID string) (*Order, error)
 	orderItems := make([]*OrderItem, 0, 8)
 	for _, row := range oirows {
 		oi := OrderItem{
-			Object:    "order_item",
-			ID:        row.UUID,
-			Path:      row.Path,
-			SKU:       row.SKU,
-			Name:      row.Name,
-			Qty:       row.Qty,
-			UnitPrice: row.UnitPrice,
-			Currency:  row.Currency,
-			Discount:  row.Discount,
-			TaxCode:   row.TaxCode,
-			VAT:       row.VAT,
-			Created:   &row.Created,
+			Object:        "order_item",
+			ID:            row.UUID,
+			Path:          row.Path,
+			SKU:           row.SKU,
+			Name:          row.Name,
+			Qty:           row.Qty,
+			UnitPrice:     row.UnitPrice,
+			Currency:      row.Currency,
+			Discount:      row.Discount,
+			OrderDiscount: row.OrderDiscount,
+			TaxCode:       row.TaxCode,
+			VAT:           row.VAT,
+			Backordered:   row.Backordered,
+			Created:       &row.Created,
 		}
 		orderItems = append(orderItems, &oi)
 	}
@@ -365,13 +462,16 @@ func (s *Service) GetOrder(ctx context.Context, orderID string) (*Order, error)
 			Postcode:    ship.Postcode,
 			Country:     ship.CountryCode,
 		},
-		Currency:    orow.Currency,
-		TotalExVAT:  orow.TotalExVAT,
-		VATTotal:    orow.VATTotal,
-		TotalIncVAT: orow.TotalIncVAT,
-		Items:       orderItems,
-		Created:     orow.Created,
-		Modified:    orow.Modified,
+		Currency:       orow.Currency,
+		IncTax:         orow.IncTax,
+		Discount:       orow.Discount,
+		ShippingCharge: orderShippingFromRow(orow),
+		TotalExVAT:     orow.TotalExVAT,
+		VATTotal:       orow.VATTotal,
+		TotalIncVAT:    orow.TotalIncVAT,
+		Items:          orderItems,
+		Created:        orow.Created,
+		Modified:       orow.Modified,
 	}
 
 	return &order, nil
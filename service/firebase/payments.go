@@ -0,0 +1,461 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"bitbucket.org/andyfusniakteam/ecom-api-go/payment"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrWebhookSignatureInvalid is returned when a payment webhook cannot be
+// verified as coming from the payment provider.
+var ErrWebhookSignatureInvalid = errors.New("service: webhook signature invalid")
+
+// PaymentProviderName returns the name of the payment provider used to
+// take payments.
+func (s *Service) PaymentProviderName() string {
+	return s.payment.Name()
+}
+
+// CreateCheckout starts a checkout for an order with the payment provider
+// returning the checkout id.
+func (s *Service) CreateCheckout(ctx context.Context, orderID, successURL, cancelURL string) (string, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: CreateCheckout(ctx, orderID=%q, successURL=%q, cancelURL=%q)",
+		orderID, successURL, cancelURL)
+
+	order, err := s.GetOrder(ctx, orderID)
+	if err != nil {
+		// TODO: deal with ErrOrderNotFound and ErrOrderItemsNotFound
+		return "", errors.Wrapf(err, "s.GetOrder(ctx, orderID=%s", orderID)
+	}
+
+	lines := make([]*payment.CheckoutLine, 0, len(order.Items)+1)
+
+	// Each order item is sent as a single line priced at the amount
+	// paid for the line including VAT, after any discounts. This keeps
+	// the checkout total equal to the order total. The VAT is only added
+	// if the order was priced excluding tax.
+	for _, i := range order.Items {
+		discount := i.OrderDiscount
+		if i.Discount != nil {
+			discount += *i.Discount
+		}
+		amount := i.Qty*i.UnitPrice - discount
+		if !order.IncTax {
+			amount += i.VAT
+		}
+		if amount == 0 {
+			continue
+		}
+		lines = append(lines, &payment.CheckoutLine{
+			Name:        i.SKU,
+			Description: fmt.Sprintf("%d x %s", i.Qty, i.Name),
+			Amount:      amount,
+		})
+
+		contextLogger.Infof("service: checkout line added - product id=%s, path=%s, sku=%s, name=%q, qty=%d, unitPrice=%v, currency=%s, discount=%d, taxCode=%s, VAT=%d", i.ID, i.Path, i.SKU, i.Name, i.Qty, i.UnitPrice, i.Currency, discount, i.TaxCode, i.VAT)
+	}
+
+	if sc := order.ShippingCharge; sc != nil && sc.Price-sc.Discount+sc.VAT > 0 {
+		lines = append(lines, &payment.CheckoutLine{
+			Name:   sc.ShippingCode,
+			Amount: sc.Price - sc.Discount + sc.VAT,
+		})
+		contextLogger.Infof("service: shipping checkout line added - shippingCode=%s, price=%d, discount=%d, VAT=%d", sc.ShippingCode, sc.Price, sc.Discount, sc.VAT)
+	}
+
+	checkout, err := s.payment.CreateCheckout(ctx, &payment.CheckoutRequest{
+		OrderID:    orderID,
+		Currency:   order.Currency,
+		Lines:      lines,
+		SuccessURL: successURL,
+		CancelURL:  cancelURL,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "service: s.payment.CreateCheckout(ctx, orderID=%q) failed", orderID)
+	}
+	contextLogger.Debugf("service: %s checkout id %s", s.payment.Name(), checkout.ID)
+	contextLogger.Debugf("service: %s payment intent id %s", s.payment.Name(), checkout.PaymentIntentID)
+
+	err = s.SetStripePaymentIntentID(ctx, orderID, checkout.PaymentIntentID)
+	if err != nil {
+		return "", errors.Wrapf(err, "s.SetStripePaymentIntentID(ctx, orderID=%s, pi=%s", orderID, checkout.PaymentIntentID)
+	}
+	return checkout.ID, nil
+}
+
+// OrderPaymentFailedEventData is published with the order.payment_failed
+// event.
+type OrderPaymentFailedEventData struct {
+	Order  *Order `json:"order"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Dispute holds the details of a payment disputed by the customer with
+// their card issuer.
+type Dispute struct {
+	ID     string `json:"id"`
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+// OrderDisputedEventData is published with the order.disputed event.
+type OrderDisputedEventData struct {
+	Order   *Order   `json:"order"`
+	Dispute *Dispute `json:"dispute"`
+}
+
+// ProcessPaymentWebhook verifies and processes a webhook called by the
+// payment provider. Each event is processed once, keyed on the provider's
+// event id, so redeliveries are ignored. Payments, failed payments,
+// expired checkouts, refunds and disputes update the order. Other events
+// are ignored. Returns ErrWebhookSignatureInvalid if the request cannot
+// be verified or ErrOrderNotFound if the event is for an unknown order.
+func (s *Service) ProcessPaymentWebhook(ctx context.Context, body []byte, header http.Header) error {
+	contextLogger := log.WithContext(ctx)
+
+	event, err := s.payment.VerifyWebhook(ctx, body, header)
+	if err == payment.ErrWebhookSignatureInvalid {
+		return ErrWebhookSignatureInvalid
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.payment.VerifyWebhook(ctx, ...) failed")
+	}
+	contextLogger.Infof("service: %s webhook event id %q type %q received", s.payment.Name(), event.ID, event.Type)
+
+	switch event.Type {
+	case payment.EventPaymentSucceeded, payment.EventPaymentFailed,
+		payment.EventCheckoutExpired, payment.EventRefunded,
+		payment.EventDisputeCreated:
+	default:
+		return nil
+	}
+
+	claimed, err := s.model.ClaimPaymentEvent(ctx, s.payment.Name(), event.ID, event.Type)
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.ClaimPaymentEvent(ctx, typ=%q, eventID=%q, ...) failed", s.payment.Name(), event.ID)
+	}
+	if !claimed {
+		contextLogger.Infof("service: %s webhook event id %q already processed", s.payment.Name(), event.ID)
+		return nil
+	}
+
+	if err := s.processPaymentEvent(ctx, event); err != nil {
+		// release the event so it is processed again if redelivered.
+		if rerr := s.model.ReleasePaymentEvent(ctx, s.payment.Name(), event.ID); rerr != nil {
+			contextLogger.Errorf("service: s.model.ReleasePaymentEvent(ctx, typ=%q, eventID=%q) failed: %+v", s.payment.Name(), event.ID, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Service) processPaymentEvent(ctx context.Context, event *payment.Event) error {
+	switch event.Type {
+	case payment.EventPaymentSucceeded:
+		return s.recordPayment(ctx, event)
+	case payment.EventPaymentFailed:
+		return s.recordPaymentFailure(ctx, event)
+	case payment.EventCheckoutExpired:
+		return s.cancelExpiredCheckout(ctx, event)
+	case payment.EventRefunded:
+		return s.recordPaymentRefunds(ctx, event)
+	case payment.EventDisputeCreated:
+		return s.recordPaymentDispute(ctx, event)
+	}
+	return nil
+}
+
+// recordPayment records the payment of an order. Payments already
+// recorded are skipped as the payment can be reported by more than one
+// event. Once the payment is recorded the event stays processed, so
+// failures updating the order afterwards are logged rather than returned.
+func (s *Service) recordPayment(ctx context.Context, event *payment.Event) error {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: event.OrderID=%q", event.OrderID)
+	contextLogger.Debugf("service: event.PaymentIntentID=%q", event.PaymentIntentID)
+
+	orow, oirows, bill, ship, err := s.model.RecordPayment(ctx,
+		event.OrderID, event.PaymentIntentID, s.payment.Name(), event.Raw)
+	if err == postgres.ErrOrderNotFound {
+		return ErrOrderNotFound
+	}
+	if err == postgres.ErrOrderPaymentUnchanged {
+		contextLogger.Infof("service: payment for order %q already recorded", event.OrderID)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err,
+			"s.model.RecordPayment(ctx, orderID=%s, pi=%s",
+			event.OrderID,
+			event.PaymentIntentID)
+	}
+
+	orderItems := make([]*OrderItem, 0, len(oirows))
+	for _, row := range oirows {
+		oi := OrderItem{
+			Object:        "order_item",
+			ID:            row.UUID,
+			Path:          row.Path,
+			SKU:           row.SKU,
+			Name:          row.Name,
+			Qty:           row.Qty,
+			UnitPrice:     row.UnitPrice,
+			Currency:      row.Currency,
+			Discount:      row.Discount,
+			OrderDiscount: row.OrderDiscount,
+			TaxCode:       row.TaxCode,
+			VAT:           row.VAT,
+			Backordered:   row.Backordered,
+			Created:       &row.Created,
+		}
+		orderItems = append(orderItems, &oi)
+	}
+
+	order := Order{
+		Object:  "order",
+		ID:      orow.UUID,
+		OrderID: orow.ID,
+		Status:  orow.Status,
+		Payment: orow.Payment,
+		User: &OrderUser{
+			ID:          orow.UsrUUID,
+			ContactName: orow.ContactName,
+			Email:       orow.Email,
+		},
+		Billing: &OrderAddress{
+			ContactName: bill.ContactName,
+			Addr1:       bill.Addr1,
+			Addr2:       bill.Addr2,
+			City:        bill.City,
+			County:      bill.County,
+			Postcode:    bill.Postcode,
+			Country:     bill.CountryCode,
+		},
+		Shipping: &OrderAddress{
+			ContactName: ship.ContactName,
+			Addr1:       ship.Addr1,
+			Addr2:       ship.Addr2,
+			City:        ship.City,
+			County:      ship.County,
+			Postcode:    ship.Postcode,
+			Country:     ship.CountryCode,
+		},
+		Currency:       orow.Currency,
+		IncTax:         orow.IncTax,
+		Discount:       orow.Discount,
+		ShippingCharge: orderShippingFromRow(orow),
+		TotalExVAT:     orow.TotalExVAT,
+		VATTotal:       orow.VATTotal,
+		TotalIncVAT:    orow.TotalIncVAT,
+		Items:          orderItems,
+		Created:        orow.Created,
+		Modified:       orow.Modified,
+	}
+
+	// A redelivery of the event would find the payment unchanged so
+	// releasing the event cannot recover from a failure here.
+	if err := s.paymentRecorded(ctx, &order); err != nil {
+		contextLogger.Errorf("service: payment for order %q recorded but the order was not updated: %+v", order.ID, err)
+	}
+	return nil
+}
+
+// paymentRecorded moves a pending order to paid once its payment has been
+// recorded. The payment of an order cancelled before it was paid is
+// refunded in full. Orders already further along keep their status but
+// still publish the payment.
+func (s *Service) paymentRecorded(ctx context.Context, order *Order) error {
+	contextLogger := log.WithContext(ctx)
+
+	switch order.Status {
+	case OrderStatusPending:
+		if _, err := s.UpdateOrderStatus(ctx, order.ID, OrderStatusPaid, nil); err != nil {
+			return errors.Wrapf(err, "service: s.UpdateOrderStatus(ctx, orderID=%q, status=%q, ...) failed", order.ID, OrderStatusPaid)
+		}
+		return nil
+	case OrderStatusCancelled:
+		contextLogger.Warnf("service: order %q paid after it was cancelled - refunding", order.ID)
+		reason := "order cancelled before payment"
+		if _, err := s.createRefund(ctx, order.ID, nil, nil, false, &reason, false); err != nil {
+			return errors.Wrapf(err, "service: s.createRefund(ctx, orderID=%q, ...) failed", order.ID)
+		}
+		return nil
+	}
+
+	data := OrderUpdatedEventData{
+		Order:          order,
+		PreviousStatus: order.Status,
+	}
+	if err := s.PublishTopicEvent(ctx, EventOrderUpdated, &data); err != nil {
+		return errors.Wrapf(err,
+			"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+			EventOrderUpdated, data)
+	}
+	contextLogger.Infof("service: EventOrderUpdated published")
+	return nil
+}
+
+// recordPaymentRefunds records the refunds received with a webhook event.
+// Refunds still pending are settled by a later event. Refunds already
+// recorded are skipped so the webhook can be delivered more than once.
+func (s *Service) recordPaymentRefunds(ctx context.Context, event *payment.Event) error {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: recordPaymentRefunds(ctx, event.PaymentIntentID=%q) started", event.PaymentIntentID)
+
+	for _, re := range event.Refunds {
+		if re.Status == payment.RefundPending {
+			continue
+		}
+		if re.Status == payment.RefundFailed {
+			if err := s.recordRefundFailure(ctx, re); err != nil {
+				return err
+			}
+			continue
+		}
+		rrow, paymentStatus, recorded, err := s.model.RecordPaymentRefund(ctx, event.PaymentIntentID, re.ID, re.RefundID, re.Amount)
+		if err == postgres.ErrOrderNotFound {
+			return ErrOrderNotFound
+		}
+		if err != nil {
+			return errors.Wrapf(err, "service: s.model.RecordPaymentRefund(ctx, pi=%q, stripeRefundID=%q, ...) failed", event.PaymentIntentID, re.ID)
+		}
+		if !recorded {
+			continue
+		}
+		rirows, err := s.model.GetRefundItems(ctx, rrow.UUID)
+		if err != nil {
+			return errors.Wrapf(err, "service: s.model.GetRefundItems(ctx, refundUUID=%q) failed", rrow.UUID)
+		}
+		if err := s.refundSucceeded(ctx, refundFromRows(rrow, rirows), paymentStatus, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordRefundFailure marks a pending refund made through this API as
+// failed. Failed refunds made elsewhere and refunds no longer pending are
+// ignored.
+func (s *Service) recordRefundFailure(ctx context.Context, re *payment.RefundEvent) error {
+	if re.RefundID == nil {
+		return nil
+	}
+	_, _, err := s.model.CompleteRefund(ctx, *re.RefundID, &re.ID, payment.RefundFailed)
+	if err == postgres.ErrRefundNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.CompleteRefund(ctx, refundUUID=%q, ...) failed", *re.RefundID)
+	}
+	log.WithContext(ctx).Warnf("service: refund %q failed with the payment provider", *re.RefundID)
+	return nil
+}
+
+// recordPaymentFailure marks an unpaid order as failed and publishes an
+// order.payment_failed event. The customer may pay later.
+func (s *Service) recordPaymentFailure(ctx context.Context, event *payment.Event) error {
+	contextLogger := log.WithContext(ctx)
+
+	orderID, err := s.model.UpdateOrderPayment(ctx, event.PaymentIntentID, []string{"unpaid", "failed"}, "failed")
+	if err == postgres.ErrOrderNotFound {
+		return ErrOrderNotFound
+	}
+	if err == postgres.ErrOrderPaymentUnchanged {
+		contextLogger.Infof("service: payment failure ignored for payment intent %q", event.PaymentIntentID)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.UpdateOrderPayment(ctx, pi=%q, ...) failed", event.PaymentIntentID)
+	}
+
+	order, err := s.GetOrder(ctx, orderID)
+	if err != nil {
+		return errors.Wrapf(err, "service: s.GetOrder(ctx, orderID=%q) failed", orderID)
+	}
+	data := OrderPaymentFailedEventData{
+		Order:  order,
+		Reason: event.Reason,
+	}
+	if err := s.PublishTopicEvent(ctx, EventOrderPaymentFailed, &data); err != nil {
+		return errors.Wrapf(err,
+			"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+			EventOrderPaymentFailed, data)
+	}
+	contextLogger.Infof("service: EventOrderPaymentFailed published")
+	return nil
+}
+
+// cancelExpiredCheckout moves a pending order that has not been paid to
+// cancelled once its checkout expires. Orders that have since started
+// another checkout are left unchanged.
+func (s *Service) cancelExpiredCheckout(ctx context.Context, event *payment.Event) error {
+	contextLogger := log.WithContext(ctx)
+
+	orow, _, _, _, err := s.model.GetOrderDetailsByUUID(ctx, event.OrderID)
+	if err == postgres.ErrOrderNotFound {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.GetOrderDetailsByUUID(ctx, orderUUID=%q) failed", event.OrderID)
+	}
+	if event.PaymentIntentID != "" && (orow.StripePI == nil || *orow.StripePI != event.PaymentIntentID) {
+		contextLogger.Infof("service: expired checkout for order %q is not the latest checkout", event.OrderID)
+		return nil
+	}
+	if orow.Status != OrderStatusPending || (orow.Payment != "unpaid" && orow.Payment != "failed") {
+		return nil
+	}
+
+	note := "checkout expired"
+	_, err = s.UpdateOrderStatus(ctx, event.OrderID, OrderStatusCancelled, &note)
+	if err == ErrOrderStatusChanged {
+		return nil
+	}
+	return err
+}
+
+// recordPaymentDispute marks a paid order as disputed and publishes an
+// order.disputed event.
+func (s *Service) recordPaymentDispute(ctx context.Context, event *payment.Event) error {
+	contextLogger := log.WithContext(ctx)
+
+	orderID, err := s.model.UpdateOrderPayment(ctx, event.PaymentIntentID, []string{"paid", "partially_refunded", "refunded"}, "disputed")
+	if err == postgres.ErrOrderNotFound {
+		return ErrOrderNotFound
+	}
+	if err == postgres.ErrOrderPaymentUnchanged {
+		contextLogger.Infof("service: dispute ignored for payment intent %q", event.PaymentIntentID)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.UpdateOrderPayment(ctx, pi=%q, ...) failed", event.PaymentIntentID)
+	}
+
+	order, err := s.GetOrder(ctx, orderID)
+	if err != nil {
+		return errors.Wrapf(err, "service: s.GetOrder(ctx, orderID=%q) failed", orderID)
+	}
+	data := OrderDisputedEventData{
+		Order: order,
+	}
+	if d := event.Dispute; d != nil {
+		data.Dispute = &Dispute{
+			ID:     d.ID,
+			Amount: d.Amount,
+			Reason: d.Reason,
+		}
+	}
+	if err := s.PublishTopicEvent(ctx, EventOrderDisputed, &data); err != nil {
+		return errors.Wrapf(err,
+			"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+			EventOrderDisputed, data)
+	}
+	contextLogger.Infof("service: EventOrderDisputed published")
+	return nil
+}
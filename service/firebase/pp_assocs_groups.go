@@ -68,11 +68,15 @@ func (s *Service) GetPPAssocGroup(ctx context.Context, ppAssocGroupID string) (*
 	return &ppAssocGroup, nil
 }
 
-// GetPPAssocGroups returns a list of PPAssocGroups.
-func (s *Service) GetPPAssocGroups(ctx context.Context) ([]*PPAssocGroup, error) {
-	rows, err := s.model.GetPPAssocGroups(ctx)
+// GetPPAssocGroups returns a page of product to product associations
+// groups.
+func (s *Service) GetPPAssocGroups(ctx context.Context, opts *ListOptions) ([]*PPAssocGroup, *Pagination, error) {
+	rows, lc, err := s.model.GetPPAssocGroups(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "s.model.GetPriceLists(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "s.model.GetPriceLists(ctx) failed")
 	}
 
 	ppAssocGroups := make([]*PPAssocGroup, 0, len(rows))
@@ -87,7 +91,7 @@ func (s *Service) GetPPAssocGroups(ctx context.Context) ([]*PPAssocGroup, error)
 		}
 		ppAssocGroups = append(ppAssocGroups, &g)
 	}
-	return ppAssocGroups, nil
+	return ppAssocGroups, newPagination(opts, lc, len(ppAssocGroups), func(i int) string { return ppAssocGroups[i].ID }), nil
 }
 
 // DeletePPAssocGroup deletes a single product to product associations group.
@@ -90,14 +90,17 @@ func (s *Service) GetPriceList(ctx context.Context, priceListID string) (*PriceL
 	return &priceList, nil
 }
 
-// GetPriceLists returns a list of PriceLists.
-func (s *Service) GetPriceLists(ctx context.Context) ([]*PriceList, error) {
-	rows, err := s.model.GetPriceLists(ctx)
+// GetPriceLists returns a page of price lists.
+func (s *Service) GetPriceLists(ctx context.Context, opts *ListOptions) ([]*PriceList, *Pagination, error) {
+	rows, lc, err := s.model.GetPriceLists(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err == postgres.ErrPriceListNotFound {
-		return nil, ErrPriceListNotFound
+		return nil, nil, ErrPriceListNotFound
 	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "service: s.model.GetPriceLists(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "service: s.model.GetPriceLists(ctx) failed")
 	}
 
 	priceLists := make([]*PriceList, 0, len(rows))
@@ -116,7 +119,7 @@ func (s *Service) GetPriceLists(ctx context.Context) ([]*PriceList, error) {
 		}
 		priceLists = append(priceLists, &pl)
 	}
-	return priceLists, nil
+	return priceLists, newPagination(opts, lc, len(priceLists), func(i int) string { return priceLists[i].ID }), nil
 }
 
 // UpdatePriceList updates a price list with a new price list code, name
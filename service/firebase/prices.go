@@ -104,24 +104,27 @@ func (s *Service) GetPrices(ctx context.Context, productID, priceListID string)
 
 	prices := make([]*Price, 0, len(plist))
 	for _, p := range plist {
-		price := Price{
-			Object:        "price",
-			ID:            p.UUID,
-			ProductID:     p.ProductUUID,
-			ProductPath:   p.ProductPath,
-			ProductSKU:    p.ProductSKU,
-			PriceListID:   p.PriceListUUID,
-			PriceListCode: p.PriceListCode,
-			Break:         p.Break,
-			UnitPrice:     p.UnitPrice,
-			Created:       p.Created,
-			Modified:      p.Modified,
-		}
-		prices = append(prices, &price)
+		prices = append(prices, priceFromRow(p))
 	}
 	return prices, nil
 }
 
+func priceFromRow(p *postgres.PriceJoinRow) *Price {
+	return &Price{
+		Object:        "price",
+		ID:            p.UUID,
+		ProductID:     p.ProductUUID,
+		ProductPath:   p.ProductPath,
+		ProductSKU:    p.ProductSKU,
+		PriceListID:   p.PriceListUUID,
+		PriceListCode: p.PriceListCode,
+		Break:         p.Break,
+		UnitPrice:     p.UnitPrice,
+		Created:       p.Created,
+		Modified:      p.Modified,
+	}
+}
+
 // PriceMapByPriceList returns a map of product ids to Price.
 func (s *Service) PriceMapByPriceList(ctx context.Context, priceListID string) (map[string]*Price, error) {
 	plist, err := s.model.GetProductPriceByPriceList(ctx, priceListID)
@@ -0,0 +1,163 @@
+package firebase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ErrProductAttributeNotFound error
+var ErrProductAttributeNotFound = errors.New("service: product attribute not found")
+
+// ErrProductAttributeCodeExists error for duplicates.
+var ErrProductAttributeCodeExists = errors.New("service: product attribute code exists")
+
+// ErrProductAttributeInUse error
+var ErrProductAttributeInUse = errors.New("service: product attribute is used by products")
+
+// ProductAttributeError is returned when the attributes of a product
+// do not match the product attribute definitions.
+type ProductAttributeError struct {
+	Code    string
+	Message string
+}
+
+func (e *ProductAttributeError) Error() string {
+	return fmt.Sprintf("service: product attribute %q %s", e.Code, e.Message)
+}
+
+// ProductAttribute defines a custom attribute of products and the type
+// of its values. Typ is one of string, integer, number or boolean.
+type ProductAttribute struct {
+	Object   string    `json:"object"`
+	ID       string    `json:"id"`
+	Code     string    `json:"code"`
+	Name     string    `json:"name"`
+	Typ      string    `json:"type"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+func productAttributeFromRow(row *postgres.ProductAttributeRow) *ProductAttribute {
+	return &ProductAttribute{
+		Object:   "product_attribute",
+		ID:       row.UUID,
+		Code:     row.Code,
+		Name:     row.Name,
+		Typ:      row.Typ,
+		Created:  row.Created,
+		Modified: row.Modified,
+	}
+}
+
+// validAttributeValue returns true if the JSON decoded value v is of
+// the attribute type typ.
+func validAttributeValue(typ string, v interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	}
+	return false
+}
+
+// validateAttributes checks every attribute has a definition and a
+// value of the defined type.
+func validateAttributes(defs []*postgres.ProductAttributeRow, attrs map[string]interface{}) error {
+	types := make(map[string]string)
+	for _, d := range defs {
+		types[d.Code] = d.Typ
+	}
+	for code, v := range attrs {
+		typ, ok := types[code]
+		if !ok {
+			return &ProductAttributeError{Code: code, Message: "is not a defined product attribute"}
+		}
+		if !validAttributeValue(typ, v) {
+			return &ProductAttributeError{Code: code, Message: "must be of type " + typ}
+		}
+	}
+	return nil
+}
+
+// checkAttributes validates the attributes against the product
+// attribute definitions.
+func (s *Service) checkAttributes(ctx context.Context, attrs map[string]interface{}) error {
+	if len(attrs) == 0 {
+		return nil
+	}
+	defs, _, err := s.model.GetProductAttributes(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "service: s.model.GetProductAttributes(ctx) failed")
+	}
+	return validateAttributes(defs, attrs)
+}
+
+// CreateProductAttribute creates a new product attribute definition.
+func (s *Service) CreateProductAttribute(ctx context.Context, code, name, typ string) (*ProductAttribute, error) {
+	row, err := s.model.CreateProductAttribute(ctx, code, name, typ)
+	if err == postgres.ErrProductAttributeCodeExists {
+		return nil, ErrProductAttributeCodeExists
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateProductAttribute(ctx, code=%q, name=%q, typ=%q) failed", code, name, typ)
+	}
+	return productAttributeFromRow(row), nil
+}
+
+// GetProductAttribute returns a single product attribute definition by id.
+func (s *Service) GetProductAttribute(ctx context.Context, productAttributeID string) (*ProductAttribute, error) {
+	row, err := s.model.GetProductAttributeByUUID(ctx, productAttributeID)
+	if err == postgres.ErrProductAttributeNotFound {
+		return nil, ErrProductAttributeNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetProductAttributeByUUID(ctx, productAttributeUUID=%q) failed", productAttributeID)
+	}
+	return productAttributeFromRow(row), nil
+}
+
+// GetProductAttributes returns a page of product attribute definitions.
+func (s *Service) GetProductAttributes(ctx context.Context, opts *ListOptions) ([]*ProductAttribute, *Pagination, error) {
+	rows, lc, err := s.model.GetProductAttributes(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "service: s.model.GetProductAttributes(ctx) failed")
+	}
+	attributes := make([]*ProductAttribute, 0, len(rows))
+	for _, row := range rows {
+		attributes = append(attributes, productAttributeFromRow(row))
+	}
+	return attributes, newPagination(opts, lc, len(attributes), func(i int) string { return attributes[i].ID }), nil
+}
+
+// DeleteProductAttribute deletes a product attribute definition that
+// is not used by any products.
+func (s *Service) DeleteProductAttribute(ctx context.Context, productAttributeID string) error {
+	err := s.model.DeleteProductAttribute(ctx, productAttributeID)
+	if err == postgres.ErrProductAttributeNotFound {
+		return ErrProductAttributeNotFound
+	}
+	if err == postgres.ErrProductAttributeInUse {
+		return ErrProductAttributeInUse
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.DeleteProductAttribute(ctx, productAttributeUUID=%q) failed", productAttributeID)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package firebase
+
+import (
+	"testing"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidAttributeValue(t *testing.T) {
+	assert.True(t, validAttributeValue("string", "red"))
+	assert.True(t, validAttributeValue("integer", float64(750)))
+	assert.True(t, validAttributeValue("number", 1.5))
+	assert.True(t, validAttributeValue("boolean", false))
+
+	assert.False(t, validAttributeValue("string", float64(1)))
+	assert.False(t, validAttributeValue("integer", 1.5))
+	assert.False(t, validAttributeValue("number", "1.5"))
+	assert.False(t, validAttributeValue("boolean", "true"))
+	assert.False(t, validAttributeValue("date", "2019-12-11"))
+}
+
+func TestValidateAttributes(t *testing.T) {
+	defs := []*postgres.ProductAttributeRow{
+		{Code: "capacity_ml", Typ: "integer"},
+		{Code: "material", Typ: "string"},
+	}
+
+	assert.NoError(t, validateAttributes(defs, nil))
+	assert.NoError(t, validateAttributes(defs, map[string]interface{}{
+		"capacity_ml": float64(750),
+		"material":    "steel",
+	}))
+
+	err := validateAttributes(defs, map[string]interface{}{"colour": "red"})
+	if assert.IsType(t, &ProductAttributeError{}, err) {
+		assert.Equal(t, "colour", err.(*ProductAttributeError).Code)
+	}
+
+	err = validateAttributes(defs, map[string]interface{}{"capacity_ml": "750"})
+	if assert.IsType(t, &ProductAttributeError{}, err) {
+		assert.Equal(t, "must be of type integer", err.(*ProductAttributeError).Message)
+	}
+}
@@ -0,0 +1,101 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Product status values. Only active products within their publish
+// window are visible to shoppers.
+const (
+	ProductStatusDraft    = postgres.ProductStatusDraft
+	ProductStatusActive   = postgres.ProductStatusActive
+	ProductStatusArchived = postgres.ProductStatusArchived
+)
+
+// ErrProductStatusInvalid is returned when the product status is not one
+// of the known product status values.
+var ErrProductStatusInvalid = errors.New("service: product status invalid")
+
+// ProductStatusEventData is published with the product.published and
+// product.archived events.
+type ProductStatusEventData struct {
+	*Product
+	PreviousStatus string `json:"previous_status"`
+}
+
+// IsValidProductStatus returns true if status is a known product status.
+func IsValidProductStatus(status string) bool {
+	switch status {
+	case ProductStatusDraft, ProductStatusActive, ProductStatusArchived:
+		return true
+	}
+	return false
+}
+
+// productStatusEvent returns the event to publish when a product moves
+// from one status to another or an empty string if there is none.
+// Reaching the publish_at or unpublish_at time of a product does not
+// change its status so scheduled changes in visibility have no event.
+func productStatusEvent(from, to string) string {
+	if from == to {
+		return ""
+	}
+	switch to {
+	case ProductStatusActive:
+		return EventProductPublished
+	case ProductStatusArchived:
+		return EventProductArchived
+	}
+	return ""
+}
+
+// UpdateProductStatus sets the status and publish window of a product.
+// A nil publishAt or unpublishAt clears the time. A product.published
+// event is published when a product becomes active and a
+// product.archived event when it is archived. Both are published when
+// the status changes and not when the publish window opens or closes.
+func (s *Service) UpdateProductStatus(ctx context.Context, productID, status string, publishAt, unpublishAt *time.Time) (*Product, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: UpdateProductStatus(ctx, productID=%q, status=%q, publishAt=%v, unpublishAt=%v) started", productID, status, publishAt, unpublishAt)
+
+	if !IsValidProductStatus(status) {
+		return nil, ErrProductStatusInvalid
+	}
+
+	p, err := s.model.GetProduct(ctx, productID)
+	if err == postgres.ErrProductNotFound {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetProduct(ctx, productUUID=%q) failed", productID)
+	}
+	previous := p.Status
+
+	p, err = s.model.UpdateProductStatus(ctx, productID, status, publishAt, unpublishAt)
+	if err == postgres.ErrProductNotFound {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.UpdateProductStatus(ctx, productUUID=%q, status=%q, ...) failed", productID, status)
+	}
+	product := productFromRow(p)
+
+	if event := productStatusEvent(previous, status); event != "" {
+		data := ProductStatusEventData{
+			Product:        product,
+			PreviousStatus: previous,
+		}
+		if err := s.PublishTopicEvent(ctx, event, &data); err != nil {
+			return nil, errors.Wrapf(err,
+				"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+				event, data)
+		}
+		contextLogger.Infof("service: %s event published", event)
+	}
+	return product, nil
+}
@@ -0,0 +1,26 @@
+package firebase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductStatusEvent(t *testing.T) {
+	assert.Equal(t, EventProductPublished, productStatusEvent(ProductStatusDraft, ProductStatusActive))
+	assert.Equal(t, EventProductPublished, productStatusEvent(ProductStatusArchived, ProductStatusActive))
+	assert.Equal(t, EventProductArchived, productStatusEvent(ProductStatusActive, ProductStatusArchived))
+	assert.Equal(t, EventProductArchived, productStatusEvent(ProductStatusDraft, ProductStatusArchived))
+
+	assert.Equal(t, "", productStatusEvent(ProductStatusActive, ProductStatusActive))
+	assert.Equal(t, "", productStatusEvent(ProductStatusArchived, ProductStatusArchived))
+	assert.Equal(t, "", productStatusEvent(ProductStatusActive, ProductStatusDraft))
+}
+
+func TestIsValidProductStatus(t *testing.T) {
+	assert.True(t, IsValidProductStatus(ProductStatusDraft))
+	assert.True(t, IsValidProductStatus(ProductStatusActive))
+	assert.True(t, IsValidProductStatus(ProductStatusArchived))
+	assert.False(t, IsValidProductStatus("published"))
+	assert.False(t, IsValidProductStatus(""))
+}
@@ -19,6 +19,10 @@ var ErrProductPathExists = errors.New("service: product path exists")
 // ErrProductSKUExists error
 var ErrProductSKUExists = errors.New("service: product sku exists")
 
+// ErrProductHasVariants is returned when attempting to delete or add to
+// a cart a product that has variants.
+var ErrProductHasVariants = errors.New("service: product has variants")
+
 // ProductImageRequestBody contains the product image data.
 type ProductImageRequestBody struct {
 	Path  string `json:"path"`
@@ -32,17 +36,48 @@ type ProductPricingRequestBody struct {
 }
 
 // ProductCreateRequestBody contains fields required for creating a product.
+// If TaxCode is nil the default tax code is used.
 type ProductCreateRequestBody struct {
-	Path string `json:"path"`
-	SKU  string `json:"sku"`
-	Name string `json:"name"`
+	Path            string                 `json:"path"`
+	SKU             string                 `json:"sku"`
+	Name            string                 `json:"name"`
+	TaxCode         *string                `json:"tax_code"`
+	Description     *string                `json:"description"`
+	Attributes      map[string]interface{} `json:"attributes"`
+	MetaTitle       *string                `json:"meta_title"`
+	MetaDescription *string                `json:"meta_description"`
 }
 
 // ProductUpdateRequestBody contains fields required for updating a product.
+// If TaxCode, Description, Attributes, MetaTitle or MetaDescription are
+// nil they are left unchanged.
 type ProductUpdateRequestBody struct {
-	Path string `json:"path"`
-	SKU  string `json:"sku"`
-	Name string `json:"name"`
+	Path            string                 `json:"path"`
+	SKU             string                 `json:"sku"`
+	Name            string                 `json:"name"`
+	TaxCode         *string                `json:"tax_code"`
+	Description     *string                `json:"description"`
+	Attributes      map[string]interface{} `json:"attributes"`
+	MetaTitle       *string                `json:"meta_title"`
+	MetaDescription *string                `json:"meta_description"`
+}
+
+// productContent returns the product content with empty values for
+// any fields not set.
+func productContent(description *string, attributes map[string]interface{}, metaTitle, metaDescription *string) *postgres.ProductContent {
+	content := postgres.ProductContent{
+		Attributes: attributes,
+	}
+	if description != nil {
+		content.Description = *description
+	}
+	if metaTitle != nil {
+		content.MetaTitle = *metaTitle
+	}
+	if metaDescription != nil {
+		content.MetaDescription = *metaDescription
+	}
+	return &content
 }
 
 type imageListContainer struct {
@@ -55,17 +90,58 @@ type priceListContainer struct {
 	Data   []*Price `json:"data"`
 }
 
+type productListContainer struct {
+	Object string     `json:"object"`
+	Data   []*Product `json:"data"`
+}
+
 // Product contains all the fields that comprise a product in the catalog.
+// Variants of a product have a ParentID and the Options that distinguish
+// them from the other variants of the parent. Attributes holds the values
+// of custom product attributes keyed by product attribute code.
 type Product struct {
-	Object   string              `json:"object"`
-	ID       string              `json:"id"`
-	Path     string              `json:"path"`
-	SKU      string              `json:"sku"`
-	Name     string              `json:"name"`
-	Images   *imageListContainer `json:"images,omitempty"`
-	Prices   *priceListContainer `json:"prices,omitempty"`
-	Created  time.Time           `json:"created"`
-	Modified time.Time           `json:"modified"`
+	Object          string                 `json:"object"`
+	ID              string                 `json:"id"`
+	ParentID        *string                `json:"parent_id"`
+	Path            string                 `json:"path"`
+	SKU             string                 `json:"sku"`
+	Name            string                 `json:"name"`
+	TaxCode         string                 `json:"tax_code"`
+	Description     string                 `json:"description"`
+	Attributes      map[string]interface{} `json:"attributes"`
+	MetaTitle       string                 `json:"meta_title"`
+	MetaDescription string                 `json:"meta_description"`
+	Status          string                 `json:"status"`
+	PublishAt       *time.Time             `json:"publish_at"`
+	UnpublishAt     *time.Time             `json:"unpublish_at"`
+	Options         map[string]string      `json:"options,omitempty"`
+	Availability    *Availability          `json:"availability,omitempty"`
+	Images          *imageListContainer    `json:"images,omitempty"`
+	Prices          *priceListContainer    `json:"prices,omitempty"`
+	Variants        *productListContainer  `json:"variants,omitempty"`
+	Created         time.Time              `json:"created"`
+	Modified        time.Time              `json:"modified"`
+}
+
+func productFromRow(p *postgres.ProductRow) *Product {
+	return &Product{
+		Object:          "product",
+		ID:              p.UUID,
+		ParentID:        p.ParentUUID,
+		Path:            p.Path,
+		SKU:             p.SKU,
+		Name:            p.Name,
+		TaxCode:         p.TaxCode,
+		Description:     p.Description,
+		Attributes:      p.Attributes,
+		MetaTitle:       p.MetaTitle,
+		MetaDescription: p.MetaDescription,
+		Status:          p.Status,
+		PublishAt:       p.PublishAt,
+		UnpublishAt:     p.UnpublishAt,
+		Created:         p.Created,
+		Modified:        p.Modified,
+	}
 }
 
 // ProductList is a container for a list of product_slim objects.
@@ -99,7 +175,15 @@ func (s *Service) CreateProduct(ctx context.Context, userID string, pc *ProductC
 	// 	}
 	// 	pricingReq = append(pricingReq, &item)
 	// }
-	p, err := s.model.CreateProduct(ctx, userID, pc.Path, pc.SKU, pc.Name)
+	taxCode := postgres.DefaultTaxCode
+	if pc.TaxCode != nil {
+		taxCode = *pc.TaxCode
+	}
+	if err := s.checkAttributes(ctx, pc.Attributes); err != nil {
+		return nil, err
+	}
+	content := productContent(pc.Description, pc.Attributes, pc.MetaTitle, pc.MetaDescription)
+	p, err := s.model.CreateProduct(ctx, userID, pc.Path, pc.SKU, pc.Name, taxCode, content)
 	if err == postgres.ErrPriceListNotFound {
 		return nil, ErrPriceListNotFound
 	}
@@ -109,6 +193,9 @@ func (s *Service) CreateProduct(ctx context.Context, userID string, pc *ProductC
 	if err == postgres.ErrProductSKUExists {
 		return nil, ErrProductSKUExists
 	}
+	if err == postgres.ErrTaxCodeNotFound {
+		return nil, ErrTaxCodeNotFound
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "CreateProduct(ctx) failed")
 	}
@@ -137,19 +224,12 @@ func (s *Service) CreateProduct(ctx context.Context, userID string, pc *ProductC
 	// 	}
 	// 	prices[PriceListID(pr.UUID)] = &price
 	// }
-	return &Product{
-		Object: "product",
-		ID:     p.UUID,
-		Path:   p.Path,
-		SKU:    p.SKU,
-		Name:   p.Name,
-		Images: &imageListContainer{
-			Object: "list",
-			Data:   make([]*Image, 0),
-		},
-		Created:  p.Created,
-		Modified: p.Modified,
-	}, nil
+	product := productFromRow(p)
+	product.Images = &imageListContainer{
+		Object: "list",
+		Data:   make([]*Image, 0),
+	}
+	return product, nil
 }
 
 // UpdateProduct updates an existing product by ID.
@@ -177,10 +257,18 @@ func (s *Service) UpdateProduct(ctx context.Context, productID string, pu *Produ
 	// 	}
 	// 	pricingReq = append(pricingReq, &item)
 	// }
+	if err := s.checkAttributes(ctx, pu.Attributes); err != nil {
+		return nil, err
+	}
 	update := &postgres.ProductUpdate{
-		Path: pu.Path,
-		SKU:  pu.SKU,
-		Name: pu.Name,
+		Path:            pu.Path,
+		SKU:             pu.SKU,
+		Name:            pu.Name,
+		TaxCode:         pu.TaxCode,
+		Description:     pu.Description,
+		Attributes:      pu.Attributes,
+		MetaTitle:       pu.MetaTitle,
+		MetaDescription: pu.MetaDescription,
 	}
 	p, err := s.model.UpdateProduct(ctx, productID, update)
 	if err == postgres.ErrProductNotFound {
@@ -192,6 +280,9 @@ func (s *Service) UpdateProduct(ctx context.Context, productID string, pu *Produ
 	if err == postgres.ErrProductSKUExists {
 		return nil, ErrProductSKUExists
 	}
+	if err == postgres.ErrTaxCodeNotFound {
+		return nil, ErrTaxCodeNotFound
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "UpdateProduct(ctx, productID=%v, ...) failed", productID)
 	}
@@ -219,15 +310,7 @@ func (s *Service) UpdateProduct(ctx context.Context, productID string, pu *Produ
 	// 	}
 	// 	prices[PriceListID(pr.UUID)] = &price
 	// }
-	return &Product{
-		Object:   "product",
-		ID:       p.UUID,
-		Path:     p.Path,
-		SKU:      p.SKU,
-		Name:     p.Name,
-		Created:  p.Created,
-		Modified: p.Modified,
-	}, nil
+	return productFromRow(p), nil
 }
 
 // difference returns the elements in `a` that aren't in `b`.
@@ -257,8 +340,38 @@ func (s *Service) ProductsExist(ctx context.Context, productIDs []string) (exist
 	return exists, missing, nil
 }
 
-// GetProduct gets a product given the SKU.
-func (s *Service) GetProduct(ctx context.Context, userID, productID string, includeImages, includePrices bool) (*Product, error) {
+// userPriceListID returns the id of the price list of the user with the
+// given id. Anonymous users with an empty userID use the default price
+// list.
+func (s *Service) userPriceListID(ctx context.Context, userID string) (string, error) {
+	if userID == "" {
+		priceListID, err := s.model.GetDefaultPriceListUUID(ctx)
+		if err == postgres.ErrDefaultPriceListNotFound {
+			return "", ErrDefaultPriceListNotFound
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "service: s.model.GetDefaultPriceListUUID(ctx) failed")
+		}
+		return priceListID, nil
+	}
+
+	// Look up the user to determine their price list id
+	usrJoinRow, err := s.model.GetUserByUUID(ctx, userID)
+	if err == postgres.ErrUserNotFound {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "service: s.model.GetUserByUUID(ctx, userUUID=%q) failed", userID)
+	}
+	return usrJoinRow.PriceListUUID, nil
+}
+
+// GetProduct gets a product given the SKU. If includeVariants is true the
+// variants of the product are included along with their images and prices
+// if requested. If publishedOnly is true ErrProductNotFound is returned
+// for products that are not published and unpublished variants are
+// excluded.
+func (s *Service) GetProduct(ctx context.Context, userID, productID string, includeImages, includePrices, includeVariants, publishedOnly bool) (*Product, error) {
 	contextLogger := log.WithContext(ctx)
 
 	p, err := s.model.GetProduct(ctx, productID)
@@ -268,93 +381,111 @@ func (s *Service) GetProduct(ctx context.Context, userID, productID string, incl
 	if err != nil {
 		return nil, errors.Wrapf(err, "model: GetProduct(ctx, productID=%q) failed", productID)
 	}
+	if publishedOnly && !p.Published(time.Now()) {
+		return nil, ErrProductNotFound
+	}
+	availability, err := s.getAvailability(ctx, []string{p.UUID})
+	if err != nil {
+		return nil, err
+	}
 
 	// prices, err := s.PriceMap(ctx, p.UUID)
 	// if err != nil {
 	// 	return nil, errors.Wrapf(err, "service: PricingMapByProductID(ctx, productID=%q)", p.UUID)
 	// }
-	product := Product{
-		Object:   "product",
-		ID:       p.UUID,
-		Path:     p.Path,
-		SKU:      p.SKU,
-		Name:     p.Name,
-		Created:  p.Created,
-		Modified: p.Modified,
-	}
+	product := productFromRow(p)
+	product.Availability = availability[p.UUID]
 
-	// optional: include the images for this product
-	if includeImages {
-		contextLogger.Info("service: including the images for this product")
-		images, err := s.GetImagesByProductID(ctx, p.UUID)
+	if p.ParentUUID != nil {
+		options, err := s.model.GetProductOptions(ctx, p.UUID)
 		if err != nil {
-			return nil, errors.Wrapf(err, "service: ListProductImages(ctx, %q)", p.SKU)
+			return nil, errors.Wrapf(err, "service: s.model.GetProductOptions(ctx, productUUID=%q) failed", p.UUID)
 		}
+		product.Options = options
+	}
+
+	products := []*Product{product}
 
-		product.Images = &imageListContainer{
+	// optional: include the variants of this product
+	if includeVariants {
+		contextLogger.Info("service: including the variants for this product")
+		variants, err := s.GetVariants(ctx, p.UUID, publishedOnly)
+		if err != nil {
+			return nil, err
+		}
+		product.Variants = &productListContainer{
 			Object: "list",
-			Data:   images,
+			Data:   variants,
 		}
+		products = append(products, variants...)
 	}
 
-	// optional: include the prices for this product
-	if includePrices {
-		contextLogger.Info("service: including the prices for this product")
-
-		var priceListID string
-		if userID == "" {
-			// The user is signed in anonymously to use the default price
-			priceListID, err = s.model.GetDefaultPriceListUUID(ctx)
+	// optional: include the images for this product
+	if includeImages {
+		contextLogger.Info("service: including the images for this product")
+		for _, v := range products {
+			images, err := s.GetImagesByProductID(ctx, v.ID)
 			if err != nil {
-				if err == postgres.ErrDefaultPriceListNotFound {
-					return nil, ErrDefaultPriceListNotFound
-				}
+				return nil, errors.Wrapf(err, "service: ListProductImages(ctx, %q)", v.SKU)
 			}
-		} else {
-			// Look up the user to determine their price list id
-			usrJoinRow, err := s.model.GetUserByUUID(ctx, userID)
-			if err == postgres.ErrUserNotFound {
-				return nil, ErrUserNotFound
-			}
-			if err != nil {
-				return nil, errors.Wrapf(err, "service: s.model.GetUserByUUID(ctx, userUUID=%q) failed", userID)
+
+			v.Images = &imageListContainer{
+				Object: "list",
+				Data:   images,
 			}
-			priceListID = usrJoinRow.PriceListUUID
 		}
+	}
 
-		prices, err := s.GetPrices(ctx, product.ID, priceListID)
+	// optional: include the prices for this product
+	if includePrices {
+		contextLogger.Info("service: including the prices for this product")
+
+		priceListID, err := s.userPriceListID(ctx, userID)
 		if err != nil {
 			return nil, err
 		}
 
-		product.Prices = &priceListContainer{
-			Object: "list",
-			Data:   prices,
+		for _, v := range products {
+			prices, err := s.GetPrices(ctx, v.ID, priceListID)
+			if err != nil {
+				return nil, err
+			}
+
+			v.Prices = &priceListContainer{
+				Object: "list",
+				Data:   prices,
+			}
 		}
 	}
-	return &product, nil
+	return product, nil
 }
 
-// ListProducts returns a slice of all product SKUS.
-func (s *Service) ListProducts(ctx context.Context) ([]*Product, error) {
-	products, err := s.model.GetProducts(ctx)
+// ListProducts returns a page of products. If status is not empty only
+// products with the status are returned. If publishedOnly is true only
+// published products are returned.
+func (s *Service) ListProducts(ctx context.Context, opts *ListOptions, status string, publishedOnly bool) ([]*Product, *Pagination, error) {
+	products, lc, err := s.model.GetProducts(ctx, opts.model(), status, publishedOnly)
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "service: GetProduct")
+		return nil, nil, errors.Wrap(err, "service: GetProduct")
+	}
+	productIDs := make([]string, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.UUID)
+	}
+	availability, err := s.getAvailability(ctx, productIDs)
+	if err != nil {
+		return nil, nil, err
 	}
 	shortProducts := make([]*Product, 0, len(products))
 	for _, p := range products {
-		ps := Product{
-			Object:   "product",
-			ID:       p.UUID,
-			Path:     p.Path,
-			SKU:      p.SKU,
-			Name:     p.Name,
-			Created:  p.Created,
-			Modified: p.Modified,
-		}
-		shortProducts = append(shortProducts, &ps)
+		ps := productFromRow(p)
+		ps.Availability = availability[p.UUID]
+		shortProducts = append(shortProducts, ps)
 	}
-	return shortProducts, nil
+	return shortProducts, newPagination(opts, lc, len(shortProducts), func(i int) string { return shortProducts[i].ID }), nil
 }
 
 // DeleteProduct deletes the product with the given UUID.
@@ -363,6 +494,9 @@ func (s *Service) DeleteProduct(ctx context.Context, uuid string) error {
 	if err == postgres.ErrProductNotFound {
 		return ErrProductNotFound
 	}
+	if err == postgres.ErrProductHasVariants {
+		return ErrProductHasVariants
+	}
 	if err != nil {
 		return errors.Wrapf(err, "service: delete product uuid=%q failed", uuid)
 	}
@@ -2,6 +2,7 @@ package firebase
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
@@ -272,3 +273,32 @@ func (s *Service) DeleteAllProductCategoryRelations(ctx context.Context) error {
 	}
 	return nil
 }
+
+// UpdateProductsCategoriesAsync creates a job to batch update product to
+// category associations in the background. The result of the job is the
+// list of associations.
+func (s *Service) UpdateProductsCategoriesAsync(ctx context.Context, userID string, cpcs []*CreateProductsCategories) (*Job, error) {
+	return s.enqueueJob(ctx, JobTypeUpdateProductsCategories, userID, cpcs)
+}
+
+func (s *Service) runUpdateProductsCategories(ctx context.Context, j *jobRun) (interface{}, error) {
+	var cpcs []*CreateProductsCategories
+	if err := json.Unmarshal(j.payload, &cpcs); err != nil {
+		return nil, errors.Wrap(err, "service: json unmarshal of products categories failed")
+	}
+	if err := j.progress(ctx, 0, len(cpcs)); err != nil {
+		return nil, err
+	}
+	results, err := s.UpdateProductsCategories(ctx, cpcs)
+	if err != nil {
+		return nil, err
+	}
+	list := struct {
+		Object string                `json:"object"`
+		Data   []*ProductsCategories `json:"data"`
+	}{
+		Object: "list",
+		Data:   results,
+	}
+	return &list, j.progress(ctx, len(cpcs), len(cpcs))
+}
@@ -280,11 +280,14 @@ func (s *Service) GetPromoRule(ctx context.Context, promoRuleID string) (*PromoR
 	return &promoRule, nil
 }
 
-// GetPromoRules returns a list of promo rules.
-func (s *Service) GetPromoRules(ctx context.Context) ([]*PromoRule, error) {
-	rows, err := s.model.GetPromoRules(ctx)
+// GetPromoRules returns a page of promotion rules.
+func (s *Service) GetPromoRules(ctx context.Context, opts *ListOptions) ([]*PromoRule, *Pagination, error) {
+	rows, lc, err := s.model.GetPromoRules(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "service: s.model.GetPromoRules(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "service: s.model.GetPromoRules(ctx) failed")
 	}
 
 	rules := make([]*PromoRule, 0, len(rows))
@@ -313,7 +316,7 @@ func (s *Service) GetPromoRules(ctx context.Context) ([]*PromoRule, error) {
 		}
 		rules = append(rules, &rule)
 	}
-	return rules, nil
+	return rules, newPagination(opts, lc, len(rules), func(i int) string { return rules[i].ID }), nil
 }
 
 // DeletePromoRule deletes a promotion rule.
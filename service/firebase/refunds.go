@@ -0,0 +1,282 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"bitbucket.org/andyfusniakteam/ecom-api-go/payment"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrRefundNotFound error
+var ErrRefundNotFound = errors.New("service: refund not found")
+
+// ErrOrderNotRefundable is returned when a refund is made against an
+// order that has not been paid or has been refunded in full.
+var ErrOrderNotRefundable = errors.New("service: order not refundable")
+
+// ErrRefundOrderItemNotFound error
+var ErrRefundOrderItemNotFound = errors.New("service: refund order item not found")
+
+// ErrRefundQtyExceeded error
+var ErrRefundQtyExceeded = errors.New("service: refund qty exceeded")
+
+// ErrRefundAmountExceeded error
+var ErrRefundAmountExceeded = errors.New("service: refund amount exceeded")
+
+// ErrRefundFailed is returned when the payment provider declines the
+// refund.
+var ErrRefundFailed = errors.New("service: refund failed")
+
+// RefundItemRequest holds an order item and the quantity to refund.
+type RefundItemRequest struct {
+	OrderItemID *string `json:"order_item_id"`
+	Qty         *int    `json:"qty"`
+}
+
+// RefundItem holds the quantity and amount refunded for an order item.
+type RefundItem struct {
+	OrderItemID string `json:"order_item_id"`
+	Qty         int    `json:"qty"`
+	Amount      int    `json:"amount"`
+}
+
+// Refund holds the details of money returned against an order payment.
+type Refund struct {
+	Object         string        `json:"object"`
+	ID             string        `json:"id"`
+	OrderID        string        `json:"order_id"`
+	StripeRefundID *string       `json:"stripe_refund_id,omitempty"`
+	Status         string        `json:"status"`
+	Amount         int           `json:"amount"`
+	Reason         *string       `json:"reason,omitempty"`
+	Restock        bool          `json:"restock"`
+	Items          []*RefundItem `json:"items"`
+	Created        time.Time     `json:"created"`
+	Modified       time.Time     `json:"modified"`
+}
+
+// OrderRefundedEventData is published with the order.refunded event.
+type OrderRefundedEventData struct {
+	Order  *Order  `json:"order"`
+	Refund *Refund `json:"refund"`
+}
+
+func refundFromRows(r *postgres.RefundRow, items []*postgres.RefundItemRow) *Refund {
+	refundItems := make([]*RefundItem, 0, len(items))
+	for _, i := range items {
+		refundItems = append(refundItems, &RefundItem{
+			OrderItemID: i.OrderItemUUID,
+			Qty:         i.Qty,
+			Amount:      i.Amount,
+		})
+	}
+	return &Refund{
+		Object:         "refund",
+		ID:             r.UUID,
+		OrderID:        r.OrderUUID,
+		StripeRefundID: r.StripeRefundID,
+		Status:         r.Status,
+		Amount:         r.Amount,
+		Reason:         r.Reason,
+		Restock:        r.Restock,
+		Items:          refundItems,
+		Created:        r.Created,
+		Modified:       r.Modified,
+	}
+}
+
+// CreateRefund refunds some or all of the payment for an order using
+// the payment provider. If items are given the amount is the share of the amount paid
+// for each line unless amount is also given. If neither are given
+// everything left to refund is refunded. If restock is true the refunded
+// items, shipped or not, are returned to the locations they were taken
+// from. Units that were backordered and never taken from stock are not
+// returned. An order.refunded event is published and
+// once the order is refunded in full the order moves to refunded. If the
+// payment provider has yet to settle the refund it is returned pending
+// and completed by the refund webhook.
+func (s *Service) CreateRefund(ctx context.Context, orderID string, amount *int, items []*RefundItemRequest, restock bool, reason *string) (*Refund, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: CreateRefund(ctx, orderID=%q, amount=%v, items=%v, restock=%t, reason=%v) started", orderID, amount, items, restock, reason)
+
+	return s.createRefund(ctx, orderID, amount, items, restock, reason, true)
+}
+
+// createRefund makes a refund as described by CreateRefund. The order is
+// only moved to refunded if updateStatus is true.
+func (s *Service) createRefund(ctx context.Context, orderID string, amount *int, items []*RefundItemRequest, restock bool, reason *string, updateStatus bool) (*Refund, error) {
+	contextLogger := log.WithContext(ctx)
+
+	newItems := make([]*postgres.NewRefundItem, 0, len(items))
+	for _, i := range items {
+		newItems = append(newItems, &postgres.NewRefundItem{
+			OrderItemUUID: *i.OrderItemID,
+			Qty:           *i.Qty,
+		})
+	}
+	rrow, rirows, pi, err := s.model.CreateRefund(ctx, orderID, amount, newItems, restock, reason)
+	if err == postgres.ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err == postgres.ErrOrderNotRefundable {
+		return nil, ErrOrderNotRefundable
+	}
+	if err == postgres.ErrRefundOrderItemNotFound {
+		return nil, ErrRefundOrderItemNotFound
+	}
+	if err == postgres.ErrRefundQtyExceeded {
+		return nil, ErrRefundQtyExceeded
+	}
+	if err == postgres.ErrRefundAmountExceeded {
+		return nil, ErrRefundAmountExceeded
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateRefund(ctx, orderUUID=%q, ...) failed", orderID)
+	}
+
+	// Make the refund with the payment provider. The refund id is passed
+	// so the refund webhook can match the refund if it arrives before the
+	// refund is completed.
+	var stripeRefundID *string
+	status := payment.RefundFailed
+	if pi != nil {
+		pr, err := s.payment.Refund(ctx, &payment.RefundRequest{
+			PaymentIntentID: *pi,
+			Amount:          rrow.Amount,
+			RefundID:        rrow.UUID,
+			OrderID:         orderID,
+		})
+		if err != nil {
+			contextLogger.Warnf("service: s.payment.Refund(ctx, ...) for refund %q failed: %v", rrow.UUID, err)
+		} else {
+			stripeRefundID = &pr.ID
+			status = pr.Status
+		}
+	}
+
+	rrow2, paymentStatus, err := s.model.CompleteRefund(ctx, rrow.UUID, stripeRefundID, status)
+	if err == postgres.ErrRefundNotFound {
+		// the charge.refunded webhook has already recorded the refund.
+		return s.GetRefund(ctx, rrow.UUID)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CompleteRefund(ctx, refundUUID=%q, ...) failed", rrow.UUID)
+	}
+	if status == payment.RefundFailed {
+		return nil, ErrRefundFailed
+	}
+
+	r := refundFromRows(rrow2, rirows)
+	if status == payment.RefundPending {
+		contextLogger.Infof("service: refund %q is pending with the payment provider", r.ID)
+		return r, nil
+	}
+	if err := s.refundSucceeded(ctx, r, paymentStatus, updateStatus); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// refundSucceeded publishes the order.refunded event and if updateStatus
+// is true moves the order to refunded once the payment has been refunded
+// in full.
+func (s *Service) refundSucceeded(ctx context.Context, r *Refund, paymentStatus string, updateStatus bool) error {
+	contextLogger := log.WithContext(ctx)
+
+	order, err := s.GetOrder(ctx, r.OrderID)
+	if err != nil {
+		return errors.Wrapf(err, "service: s.GetOrder(ctx, orderID=%q) failed", r.OrderID)
+	}
+	data := OrderRefundedEventData{
+		Order:  order,
+		Refund: r,
+	}
+	if err := s.PublishTopicEvent(ctx, EventOrderRefunded, &data); err != nil {
+		return errors.Wrapf(err,
+			"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+			EventOrderRefunded, data)
+	}
+	contextLogger.Infof("service: EventOrderRefunded published")
+
+	if updateStatus && paymentStatus == "refunded" && validOrderTransition(order.Status, OrderStatusRefunded) {
+		_, err := s.UpdateOrderStatus(ctx, r.OrderID, OrderStatusRefunded, r.Reason)
+		if err != nil && err != ErrOrderStatusChanged && err != ErrOrderTransitionInvalid {
+			return errors.Wrapf(err, "service: s.UpdateOrderStatus(ctx, orderID=%q, status=%q, ...) failed", r.OrderID, OrderStatusRefunded)
+		}
+	}
+	return nil
+}
+
+// CancelOrder moves an order to cancelled. If the order has been paid
+// everything left to refund is refunded using the payment provider
+// first and the order is only cancelled once the refund has succeeded or
+// is pending with the payment provider. Cancelled orders have not
+// shipped so all the stock taken for the order is returned to the
+// locations it was taken from. Returns ErrRefundFailed leaving the order
+// as it was if the refund is declined.
+func (s *Service) CancelOrder(ctx context.Context, orderID string, reason *string) (*Order, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: CancelOrder(ctx, orderID=%q, reason=%v) started", orderID, reason)
+
+	order, err := s.GetOrder(ctx, orderID)
+	if err == ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.GetOrder(ctx, orderID=%q) failed", orderID)
+	}
+	if !validOrderTransition(order.Status, OrderStatusCancelled) {
+		return nil, ErrOrderTransitionInvalid
+	}
+
+	if order.Payment == "paid" || order.Payment == "partially_refunded" {
+		// the stock is released once the order is cancelled.
+		_, err := s.createRefund(ctx, orderID, nil, nil, false, reason, false)
+		if err == ErrRefundFailed {
+			return nil, ErrRefundFailed
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "service: s.createRefund(ctx, orderID=%q, ...) failed", orderID)
+		}
+	}
+	return s.UpdateOrderStatus(ctx, orderID, OrderStatusCancelled, reason)
+}
+
+// GetRefund returns a single refund.
+func (s *Service) GetRefund(ctx context.Context, refundID string) (*Refund, error) {
+	rrow, err := s.model.GetRefundByUUID(ctx, refundID)
+	if err == postgres.ErrRefundNotFound {
+		return nil, ErrRefundNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetRefundByUUID(ctx, refundUUID=%q) failed", refundID)
+	}
+	rirows, err := s.model.GetRefundItems(ctx, refundID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetRefundItems(ctx, refundUUID=%q) failed", refundID)
+	}
+	return refundFromRows(rrow, rirows), nil
+}
+
+// GetRefunds returns the refunds of an order oldest first.
+func (s *Service) GetRefunds(ctx context.Context, orderID string) ([]*Refund, error) {
+	rrows, err := s.model.GetRefundsByOrderUUID(ctx, orderID)
+	if err == postgres.ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetRefundsByOrderUUID(ctx, orderUUID=%q) failed", orderID)
+	}
+	refunds := make([]*Refund, 0, len(rrows))
+	for _, row := range rrows {
+		rirows, err := s.model.GetRefundItems(ctx, row.UUID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "service: s.model.GetRefundItems(ctx, refundUUID=%q) failed", row.UUID)
+		}
+		refunds = append(refunds, refundFromRows(row, rirows))
+	}
+	return refunds, nil
+}
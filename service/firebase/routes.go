@@ -0,0 +1,67 @@
+package firebase
+
+import (
+	"context"
+	"net/http"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ErrRouteNotFound is returned when a path resolves to no product or
+// category.
+var ErrRouteNotFound = errors.New("service: route not found")
+
+// Route types
+const (
+	RouteTypeProduct  = postgres.RouteTypeProduct
+	RouteTypeCategory = postgres.RouteTypeCategory
+)
+
+// Route is the product or category a storefront path resolves to. Status
+// is 301 if the path is an old path of the product or category, in which
+// case clients should redirect to Path.
+type Route struct {
+	Object        string `json:"object"`
+	Type          string `json:"type"`
+	ID            string `json:"id"`
+	Path          string `json:"path"`
+	RequestedPath string `json:"requested_path"`
+	Status        int    `json:"status"`
+}
+
+// pickRoute returns the first of the rows in order of precedence. If
+// visibleOnly is true unpublished products and hidden categories are
+// skipped.
+func pickRoute(path string, rows []*postgres.RouteRow, visibleOnly bool) (*Route, error) {
+	for _, r := range rows {
+		if visibleOnly && !r.Visible {
+			continue
+		}
+		status := http.StatusOK
+		if r.Redirect {
+			status = http.StatusMovedPermanently
+		}
+		return &Route{
+			Object:        "route",
+			Type:          r.Type,
+			ID:            r.UUID,
+			Path:          r.Path,
+			RequestedPath: path,
+			Status:        status,
+		}, nil
+	}
+	return nil, ErrRouteNotFound
+}
+
+// ResolvePath returns the product or category the given path resolves to
+// either directly or through the redirects left behind when products and
+// categories change path. If visibleOnly is true unpublished products and
+// hidden categories are not resolved.
+func (s *Service) ResolvePath(ctx context.Context, path string, visibleOnly bool) (*Route, error) {
+	rows, err := s.model.GetRoutes(ctx, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetRoutes(ctx, path=%q) failed", path)
+	}
+	return pickRoute(path, rows, visibleOnly)
+}
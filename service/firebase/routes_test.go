@@ -0,0 +1,38 @@
+package firebase
+
+import (
+	"testing"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickRoute(t *testing.T) {
+	rows := []*postgres.RouteRow{
+		{Type: RouteTypeProduct, UUID: "p1", Path: "shoes", Redirect: false, Visible: false},
+		{Type: RouteTypeCategory, UUID: "c1", Path: "women/shoes", Redirect: true, Visible: true},
+	}
+
+	route, err := pickRoute("shoes", rows, false)
+	assert.NoError(t, err)
+	assert.Equal(t, &Route{
+		Object:        "route",
+		Type:          RouteTypeProduct,
+		ID:            "p1",
+		Path:          "shoes",
+		RequestedPath: "shoes",
+		Status:        200,
+	}, route)
+
+	route, err = pickRoute("shoes", rows, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "c1", route.ID)
+	assert.Equal(t, "women/shoes", route.Path)
+	assert.Equal(t, 301, route.Status)
+
+	_, err = pickRoute("shoes", rows[:1], true)
+	assert.Equal(t, ErrRouteNotFound, err)
+
+	_, err = pickRoute("shoes", nil, false)
+	assert.Equal(t, ErrRouteNotFound, err)
+}
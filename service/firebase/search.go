@@ -0,0 +1,126 @@
+package firebase
+
+import (
+	"context"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ProductSearchRequest holds the query and filters of a product search.
+// Category is the path of a category whose subtree the products must be
+// in. MinPrice and MaxPrice are unit prices in the price list of the
+// user. Attributes holds attribute values keyed by attribute code.
+type ProductSearchRequest struct {
+	Query         string
+	Category      *string
+	MinPrice      *int
+	MaxPrice      *int
+	InStock       bool
+	Attributes    map[string]string
+	PublishedOnly bool
+	Limit         int
+}
+
+// CategoryFacet holds the number of matching products in a category and
+// its descendants.
+type CategoryFacet struct {
+	ID    string `json:"id"`
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// AttributeFacet holds the number of matching products with an attribute
+// value.
+type AttributeFacet struct {
+	Code  string `json:"code"`
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets holds the facet counts of a product search.
+type SearchFacets struct {
+	Categories []*CategoryFacet  `json:"categories"`
+	Attributes []*AttributeFacet `json:"attributes"`
+}
+
+// ProductSearchResult holds the products matching a search ordered by
+// relevance along with the total number of matching products and the
+// facet counts.
+type ProductSearchResult struct {
+	Object string       `json:"object"`
+	Total  int          `json:"total"`
+	Data   []*Product   `json:"data"`
+	Facets SearchFacets `json:"facets"`
+}
+
+// SearchProducts returns the products matching the search. Prices are
+// filtered using the price list of the user.
+func (s *Service) SearchProducts(ctx context.Context, userID string, req *ProductSearchRequest) (*ProductSearchResult, error) {
+	search := postgres.ProductSearch{
+		Query:         req.Query,
+		CategoryPath:  req.Category,
+		MinPrice:      req.MinPrice,
+		MaxPrice:      req.MaxPrice,
+		InStock:       req.InStock,
+		Attributes:    req.Attributes,
+		PublishedOnly: req.PublishedOnly,
+		Limit:         req.Limit,
+	}
+	if req.MinPrice != nil || req.MaxPrice != nil {
+		priceListID, err := s.userPriceListID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		search.PriceListUUID = priceListID
+	}
+
+	res, err := s.model.SearchProducts(ctx, &search)
+	if err == postgres.ErrCategoryNotFound {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.SearchProducts(ctx, search=%+v) failed", search)
+	}
+
+	productIDs := make([]string, 0, len(res.Products))
+	for _, p := range res.Products {
+		productIDs = append(productIDs, p.UUID)
+	}
+	availability, err := s.getAvailability(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ProductSearchResult{
+		Object: "list",
+		Total:  res.Total,
+		Data:   make([]*Product, 0, len(res.Products)),
+		Facets: SearchFacets{
+			Categories: make([]*CategoryFacet, 0, len(res.CategoryFacets)),
+			Attributes: make([]*AttributeFacet, 0, len(res.AttributeFacets)),
+		},
+	}
+	for _, p := range res.Products {
+		product := productFromRow(p)
+		product.Availability = availability[p.UUID]
+		result.Data = append(result.Data, product)
+	}
+	for _, f := range res.CategoryFacets {
+		result.Facets.Categories = append(result.Facets.Categories, &CategoryFacet{
+			ID:    f.UUID,
+			Path:  f.Path,
+			Name:  f.Name,
+			Count: f.Count,
+		})
+	}
+	for _, f := range res.AttributeFacets {
+		result.Facets.Attributes = append(result.Facets.Attributes, &AttributeFacet{
+			Code:  f.Code,
+			Value: f.Value,
+			Count: f.Count,
+		})
+	}
+	return &result, nil
+}
@@ -0,0 +1,170 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrShipmentNotFound error
+var ErrShipmentNotFound = errors.New("service: shipment not found")
+
+// ErrOrderNotShippable is returned when a shipment is created for an
+// order that cannot be shipped in its current status.
+var ErrOrderNotShippable = errors.New("service: order not shippable")
+
+// ErrShipmentOrderItemNotFound error
+var ErrShipmentOrderItemNotFound = errors.New("service: shipment order item not found")
+
+// ErrShipmentQtyExceeded error
+var ErrShipmentQtyExceeded = errors.New("service: shipment qty exceeded")
+
+// ShipmentItemRequest holds an order item and the quantity to ship.
+type ShipmentItemRequest struct {
+	OrderItemID *string `json:"order_item_id"`
+	Qty         *int    `json:"qty"`
+}
+
+// ShipmentItem holds the quantity of an order item in a shipment.
+type ShipmentItem struct {
+	OrderItemID string `json:"order_item_id"`
+	Qty         int    `json:"qty"`
+}
+
+// Shipment holds the details of the items dispatched for an order.
+type Shipment struct {
+	Object         string          `json:"object"`
+	ID             string          `json:"id"`
+	OrderID        string          `json:"order_id"`
+	Carrier        string          `json:"carrier"`
+	TrackingNumber string          `json:"tracking_number"`
+	TrackingURL    *string         `json:"tracking_url,omitempty"`
+	Items          []*ShipmentItem `json:"items"`
+	Created        time.Time       `json:"created"`
+	Modified       time.Time       `json:"modified"`
+}
+
+func shipmentFromRows(s *postgres.ShipmentRow, items []*postgres.ShipmentItemRow) *Shipment {
+	shipmentItems := make([]*ShipmentItem, 0, len(items))
+	for _, i := range items {
+		shipmentItems = append(shipmentItems, &ShipmentItem{
+			OrderItemID: i.OrderItemUUID,
+			Qty:         i.Qty,
+		})
+	}
+	return &Shipment{
+		Object:         "shipment",
+		ID:             s.UUID,
+		OrderID:        s.OrderUUID,
+		Carrier:        s.Carrier,
+		TrackingNumber: s.TrackingNumber,
+		TrackingURL:    s.TrackingURL,
+		Items:          shipmentItems,
+		Created:        s.Created,
+		Modified:       s.Modified,
+	}
+}
+
+// CreateShipment records a shipment of order items against an order and
+// publishes a shipment.created event. The order moves to shipped once
+// every order item has been shipped in full or partially_shipped
+// otherwise and an order.updated event is published. Returns
+// ErrOrderNotFound, ErrOrderNotShippable, ErrOrderStatusChanged,
+// ErrShipmentOrderItemNotFound or ErrShipmentQtyExceeded.
+func (s *Service) CreateShipment(ctx context.Context, orderID, carrier, trackingNumber string, trackingURL *string, items []*ShipmentItemRequest) (*Shipment, error) {
+	contextLogger := log.WithContext(ctx)
+	contextLogger.Debugf("service: CreateShipment(ctx, orderID=%q, carrier=%q, trackingNumber=%q, trackingURL=%v, ...) started", orderID, carrier, trackingNumber, trackingURL)
+
+	order, err := s.GetOrder(ctx, orderID)
+	if err == ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.GetOrder(ctx, orderID=%q) failed", orderID)
+	}
+	previous := order.Status
+	if !validOrderTransition(previous, OrderStatusShipped) {
+		return nil, ErrOrderNotShippable
+	}
+
+	newItems := make([]*postgres.NewShipmentItem, 0, len(items))
+	for _, i := range items {
+		newItems = append(newItems, &postgres.NewShipmentItem{
+			OrderItemUUID: *i.OrderItemID,
+			Qty:           *i.Qty,
+		})
+	}
+	srow, sirows, history, err := s.model.CreateShipment(ctx, orderID, previous, carrier, trackingNumber, trackingURL, newItems)
+	if err == postgres.ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err == postgres.ErrOrderStatusChanged {
+		return nil, ErrOrderStatusChanged
+	}
+	if err == postgres.ErrShipmentOrderItemNotFound {
+		return nil, ErrShipmentOrderItemNotFound
+	}
+	if err == postgres.ErrShipmentQtyExceeded {
+		return nil, ErrShipmentQtyExceeded
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateShipment(ctx, orderUUID=%q, ...) failed", orderID)
+	}
+	shipment := shipmentFromRows(srow, sirows)
+
+	if err := s.PublishTopicEvent(ctx, EventShipmentCreated, shipment); err != nil {
+		return nil, errors.Wrapf(err,
+			"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+			EventShipmentCreated, shipment)
+	}
+	contextLogger.Infof("service: EventShipmentCreated published")
+
+	if history != nil {
+		order, err := s.GetOrder(ctx, orderID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "service: s.GetOrder(ctx, orderID=%q) failed", orderID)
+		}
+		data := OrderUpdatedEventData{
+			Order:          order,
+			PreviousStatus: previous,
+		}
+		if err := s.PublishTopicEvent(ctx, EventOrderUpdated, &data); err != nil {
+			return nil, errors.Wrapf(err,
+				"service: s.PublishTopicEvent(ctx, event=%q, data=%v) failed",
+				EventOrderUpdated, data)
+		}
+		contextLogger.Infof("service: EventOrderUpdated published")
+	}
+	return shipment, nil
+}
+
+// GetShipment returns a single shipment.
+func (s *Service) GetShipment(ctx context.Context, shipmentID string) (*Shipment, error) {
+	srow, sirows, err := s.model.GetShipmentByUUID(ctx, shipmentID)
+	if err == postgres.ErrShipmentNotFound {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetShipmentByUUID(ctx, shipmentUUID=%q) failed", shipmentID)
+	}
+	return shipmentFromRows(srow, sirows), nil
+}
+
+// GetShipments returns the shipments of an order oldest first.
+func (s *Service) GetShipments(ctx context.Context, orderID string) ([]*Shipment, error) {
+	srows, items, err := s.model.GetShipmentsByOrderUUID(ctx, orderID)
+	if err == postgres.ErrOrderNotFound {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetShipmentsByOrderUUID(ctx, orderUUID=%q) failed", orderID)
+	}
+	shipments := make([]*Shipment, 0, len(srows))
+	for _, row := range srows {
+		shipments = append(shipments, shipmentFromRows(row, items[row.UUID]))
+	}
+	return shipments, nil
+}
@@ -75,11 +75,14 @@ func (s *Service) GetShippingTariff(ctx context.Context, shippingTariffID string
 	return &shippingTariff, nil
 }
 
-// GetShippingTariffs returns a list of ShippingTariffs.
-func (s *Service) GetShippingTariffs(ctx context.Context) ([]*ShippingTariff, error) {
-	rows, err := s.model.GetShippingTariffs(ctx)
+// GetShippingTariffs returns a page of shipping tariffs.
+func (s *Service) GetShippingTariffs(ctx context.Context, opts *ListOptions) ([]*ShippingTariff, *Pagination, error) {
+	rows, lc, err := s.model.GetShippingTariffs(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "s.model.GetShippingTariffs(ctx) failed")
+		return nil, nil, errors.Wrapf(err, "s.model.GetShippingTariffs(ctx) failed")
 	}
 
 	shippingTariffList := make([]*ShippingTariff, 0, len(rows))
@@ -96,7 +99,7 @@ func (s *Service) GetShippingTariffs(ctx context.Context) ([]*ShippingTariff, er
 			Modified:     row.Modified,
 		})
 	}
-	return shippingTariffList, nil
+	return shippingTariffList, newPagination(opts, lc, len(shippingTariffList), func(i int) string { return shippingTariffList[i].ID }), nil
 }
 
 // UpdateShippingTariff updates a shipping tariff.
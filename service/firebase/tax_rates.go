@@ -0,0 +1,101 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ErrTaxRateNotFound error
+var ErrTaxRateNotFound = errors.New("service: tax rate not found")
+
+// ErrTaxCodeNotFound is returned when a product is given a tax code that
+// has no tax rates.
+var ErrTaxCodeNotFound = errors.New("service: tax code not found")
+
+// TaxRate holds the rate of tax charged for a tax code in a country or
+// region of a country between the effective dates. Rate is held in
+// basis points so 2000 is 20.00%.
+type TaxRate struct {
+	Object        string     `json:"object"`
+	ID            string     `json:"id"`
+	TaxCode       string     `json:"tax_code"`
+	CountryCode   string     `json:"country_code"`
+	Region        *string    `json:"region"`
+	Rate          int        `json:"rate"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	Created       time.Time  `json:"created"`
+	Modified      time.Time  `json:"modified"`
+}
+
+func taxRateFromRow(row *postgres.TaxRateRow) *TaxRate {
+	return &TaxRate{
+		Object:        "tax_rate",
+		ID:            row.UUID,
+		TaxCode:       row.TaxCode,
+		CountryCode:   row.CountryCode,
+		Region:        row.Region,
+		Rate:          row.Rate,
+		EffectiveFrom: row.EffectiveFrom,
+		EffectiveTo:   row.EffectiveTo,
+		Created:       row.Created,
+		Modified:      row.Modified,
+	}
+}
+
+// CreateTaxRate creates a new tax rate. If effectiveFrom is nil the
+// rate takes effect immediately.
+func (s *Service) CreateTaxRate(ctx context.Context, taxCode, countryCode string, region *string, rate int, effectiveFrom, effectiveTo *time.Time) (*TaxRate, error) {
+	from := time.Now()
+	if effectiveFrom != nil {
+		from = *effectiveFrom
+	}
+	row, err := s.model.CreateTaxRate(ctx, taxCode, countryCode, region, rate, from, effectiveTo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateTaxRate(ctx, taxCode=%q, countryCode=%q, region=%v, rate=%d, ...) failed", taxCode, countryCode, region, rate)
+	}
+	return taxRateFromRow(row), nil
+}
+
+// GetTaxRate returns a single tax rate by ID.
+func (s *Service) GetTaxRate(ctx context.Context, taxRateID string) (*TaxRate, error) {
+	row, err := s.model.GetTaxRateByUUID(ctx, taxRateID)
+	if err == postgres.ErrTaxRateNotFound {
+		return nil, ErrTaxRateNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetTaxRateByUUID(ctx, taxRateUUID=%q) failed", taxRateID)
+	}
+	return taxRateFromRow(row), nil
+}
+
+// GetTaxRates returns a page of tax rates.
+func (s *Service) GetTaxRates(ctx context.Context, opts *ListOptions) ([]*TaxRate, *Pagination, error) {
+	rows, lc, err := s.model.GetTaxRates(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "service: s.model.GetTaxRates(ctx) failed")
+	}
+	rates := make([]*TaxRate, 0, len(rows))
+	for _, row := range rows {
+		rates = append(rates, taxRateFromRow(row))
+	}
+	return rates, newPagination(opts, lc, len(rates), func(i int) string { return rates[i].ID }), nil
+}
+
+// DeleteTaxRate deletes a tax rate by ID.
+func (s *Service) DeleteTaxRate(ctx context.Context, taxRateID string) error {
+	err := s.model.DeleteTaxRateByUUID(ctx, taxRateID)
+	if err == postgres.ErrTaxRateNotFound {
+		return ErrTaxRateNotFound
+	}
+	if err != nil {
+		return errors.Wrapf(err, "service: s.model.DeleteTaxRateByUUID(ctx, taxRateUUID=%q) failed", taxRateID)
+	}
+	return nil
+}
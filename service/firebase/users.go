@@ -36,31 +36,6 @@ type User struct {
 	Modified    time.Time `json:"modified"`
 }
 
-// PaginationQuery holds query
-type PaginationQuery struct {
-	OrderBy    string
-	OrderDir   string
-	Limit      int
-	StartAfter string
-	EndBefore  string
-}
-
-// PaginationContext holds the context for the currently retrieved set.
-type PaginationContext struct {
-	Total      int    `json:"total"`
-	FirstID    string `json:"first_id"`
-	LastID     string `json:"last_id"`
-	SetFirstID string `json:"set_first_id"`
-	SetLastID  string `json:"set_last_id"`
-}
-
-// PaginationResultSet contains the set retrieved from the last fetch
-// including the context information.
-type PaginationResultSet struct {
-	RContext PaginationContext
-	RSet     interface{}
-}
-
 // CreateRootIfNotExists create the root user if the root super admin does not exit.
 func (s *Service) CreateRootIfNotExists(ctx context.Context, email, password string) error {
 	authClient, err := s.fbApp.Auth(ctx)
@@ -155,22 +130,18 @@ func (s *Service) CreateUser(ctx context.Context, role, email, password, firstna
 	return &ac, nil
 }
 
-// GetUsers gets users with pagination.
-func (s *Service) GetUsers(ctx context.Context, pq *PaginationQuery) (*PaginationResultSet, error) {
-	q := &postgres.PaginationQuery{
-		OrderBy:    pq.OrderBy,
-		OrderDir:   pq.OrderDir,
-		Limit:      pq.Limit,
-		StartAfter: pq.StartAfter,
-		EndBefore:  pq.EndBefore,
+// GetUsers returns a page of users.
+func (s *Service) GetUsers(ctx context.Context, opts *ListOptions) ([]*User, *Pagination, error) {
+	rows, lc, err := s.model.GetUsers(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
 	}
-	prs, err := s.model.GetUsers(ctx, q)
 	if err != nil {
-		return nil, err
+		return nil, nil, errors.Wrap(err, "service: s.model.GetUsers(ctx, ...) failed")
 	}
 
-	users := make([]*User, 0)
-	for _, row := range prs.RSet.([]*postgres.UsrJoinRow) {
+	users := make([]*User, 0, len(rows))
+	for _, row := range rows {
 		c := User{
 			Object:      "user",
 			ID:          row.UUID,
@@ -185,16 +156,7 @@ func (s *Service) GetUsers(ctx context.Context, pq *PaginationQuery) (*Paginatio
 		}
 		users = append(users, &c)
 	}
-
-	aprs := &PaginationResultSet{
-		RContext: PaginationContext{
-			Total:   prs.RContext.Total,
-			FirstID: prs.RContext.FirstUUID,
-			LastID:  prs.RContext.LastUUID,
-		},
-		RSet: users,
-	}
-	return aprs, nil
+	return users, newPagination(opts, lc, len(users), func(i int) string { return users[i].ID }), nil
 }
 
 // GetUser retrieves a user by user ID.
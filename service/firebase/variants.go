@@ -0,0 +1,168 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/andyfusniakteam/ecom-api-go/model/postgres"
+	"github.com/pkg/errors"
+)
+
+// ErrVariantNotFound is returned when no variant of a product has the
+// requested options.
+var ErrVariantNotFound = errors.New("service: variant not found")
+
+// ErrVariantOptionsExist is returned when creating a variant with the
+// same options as an existing variant of the same product.
+var ErrVariantOptionsExist = errors.New("service: variant options exist")
+
+// ErrProductIsVariant is returned when attempting to create a variant
+// of a product that is itself a variant.
+var ErrProductIsVariant = errors.New("service: product is a variant")
+
+// VariantCreateRequestBody contains the fields required to create a
+// variant of a product. Options holds the value of each option type
+// keyed by option type code. If TaxCode is nil the default tax code is
+// used.
+type VariantCreateRequestBody struct {
+	Path            string                 `json:"path"`
+	SKU             string                 `json:"sku"`
+	Name            string                 `json:"name"`
+	TaxCode         *string                `json:"tax_code"`
+	Description     *string                `json:"description"`
+	Attributes      map[string]interface{} `json:"attributes"`
+	MetaTitle       *string                `json:"meta_title"`
+	MetaDescription *string                `json:"meta_description"`
+	Options         map[string]string      `json:"options"`
+}
+
+func variantFromRow(v *postgres.VariantRow) *Product {
+	p := productFromRow(&v.ProductRow)
+	p.Options = v.Options
+	return p
+}
+
+// CreateVariant creates a new variant of the product with the given id.
+func (s *Service) CreateVariant(ctx context.Context, userID, productID string, vc *VariantCreateRequestBody) (*Product, error) {
+	taxCode := postgres.DefaultTaxCode
+	if vc.TaxCode != nil {
+		taxCode = *vc.TaxCode
+	}
+	if err := s.checkAttributes(ctx, vc.Attributes); err != nil {
+		return nil, err
+	}
+	content := productContent(vc.Description, vc.Attributes, vc.MetaTitle, vc.MetaDescription)
+	v, err := s.model.CreateVariant(ctx, userID, productID, vc.Path, vc.SKU, vc.Name, taxCode, content, vc.Options)
+	if err == postgres.ErrProductNotFound {
+		return nil, ErrProductNotFound
+	}
+	if err == postgres.ErrProductIsVariant {
+		return nil, ErrProductIsVariant
+	}
+	if err == postgres.ErrOptionTypeNotFound {
+		return nil, ErrOptionTypeNotFound
+	}
+	if err == postgres.ErrVariantOptionsExist {
+		return nil, ErrVariantOptionsExist
+	}
+	if err == postgres.ErrProductPathExists {
+		return nil, ErrProductPathExists
+	}
+	if err == postgres.ErrProductSKUExists {
+		return nil, ErrProductSKUExists
+	}
+	if err == postgres.ErrTaxCodeNotFound {
+		return nil, ErrTaxCodeNotFound
+	}
+	if err == postgres.ErrUserNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err == postgres.ErrDefaultPriceListNotFound {
+		return nil, ErrDefaultPriceListNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.CreateVariant(ctx, userUUID=%q, parentUUID=%q, ...) failed", userID, productID)
+	}
+	return variantFromRow(v), nil
+}
+
+// GetVariants returns the variants of the product with the given id
+// including the availability of each variant. If publishedOnly is true
+// ErrProductNotFound is returned if the product is not published and
+// variants that are not published are excluded.
+func (s *Service) GetVariants(ctx context.Context, productID string, publishedOnly bool) ([]*Product, error) {
+	if publishedOnly {
+		p, err := s.model.GetProduct(ctx, productID)
+		if err == postgres.ErrProductNotFound {
+			return nil, ErrProductNotFound
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "service: s.model.GetProduct(ctx, productUUID=%q) failed", productID)
+		}
+		if !p.Published(time.Now()) {
+			return nil, ErrProductNotFound
+		}
+	}
+
+	rows, err := s.model.GetVariants(ctx, productID)
+	if err == postgres.ErrProductNotFound {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "service: s.model.GetVariants(ctx, productUUID=%q) failed", productID)
+	}
+	if publishedOnly {
+		now := time.Now()
+		published := make([]*postgres.VariantRow, 0, len(rows))
+		for _, v := range rows {
+			if v.Published(now) {
+				published = append(published, v)
+			}
+		}
+		rows = published
+	}
+	productIDs := make([]string, 0, len(rows))
+	for _, v := range rows {
+		productIDs = append(productIDs, v.UUID)
+	}
+	availability, err := s.getAvailability(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	variants := make([]*Product, 0, len(rows))
+	for _, v := range rows {
+		p := variantFromRow(v)
+		p.Availability = availability[v.UUID]
+		variants = append(variants, p)
+	}
+	return variants, nil
+}
+
+// resolveVariant returns the id of the product to add to a cart. If
+// options is not empty the id of the variant of the product with the
+// options is returned. Products with variants can only be added to a
+// cart by selecting one of the variants.
+func (s *Service) resolveVariant(ctx context.Context, productID string, options map[string]string) (string, error) {
+	if len(options) > 0 {
+		v, err := s.model.GetVariantByOptions(ctx, productID, options)
+		if err == postgres.ErrProductNotFound {
+			return "", ErrProductNotFound
+		}
+		if err == postgres.ErrVariantNotFound {
+			return "", ErrVariantNotFound
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "service: s.model.GetVariantByOptions(ctx, productUUID=%q, options=%v) failed", productID, options)
+		}
+		return v.UUID, nil
+	}
+
+	has, err := s.model.HasVariants(ctx, productID)
+	if err != nil {
+		return "", errors.Wrapf(err, "service: s.model.HasVariants(ctx, productUUID=%q) failed", productID)
+	}
+	if has {
+		return "", ErrProductHasVariants
+	}
+	return productID, nil
+}
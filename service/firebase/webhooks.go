@@ -39,6 +39,19 @@ func init() {
 		EventUserCreated,
 		EventOrderCreated,
 		EventOrderUpdated,
+		EventOrderRefunded,
+		EventOrderPaymentFailed,
+		EventOrderDisputed,
+		EventShipmentCreated,
+		EventInventoryLowStock,
+		EventInventoryOutOfStock,
+		EventInventoryRestocked,
+		EventProductPublished,
+		EventProductArchived,
+	}
+	validEvents = make(map[string]struct{}, len(eventTypes))
+	for _, v := range eventTypes {
+		validEvents[v] = struct{}{}
 	}
 
 	tr := &http.Transport{
@@ -88,12 +101,8 @@ type Webhook struct {
 // used with another webhook.
 func (s *Service) CreateWebhook(ctx context.Context, url string, events []string) (*Webhook, error) {
 	// Check the given event name is a known event type
-	eventTypeMap := make(map[string]bool)
-	for _, v := range eventTypes {
-		eventTypeMap[v] = true
-	}
 	for _, v := range events {
-		if _, ok := eventTypeMap[v]; !ok {
+		if _, ok := validEvents[v]; !ok {
 			return nil, ErrEventTypeNotFound
 		}
 	}
@@ -151,11 +160,15 @@ func (s *Service) GetWebhook(ctx context.Context, webhookID string) (*Webhook, e
 	return &webhook, nil
 }
 
-// GetWebhooks returns a slice of Webhooks for every webhook in the system.
-func (s *Service) GetWebhooks(ctx context.Context) ([]*Webhook, error) {
-	rows, err := s.model.GetWebhooks(ctx)
+// GetWebhooks returns a page of webhooks. If opts is nil all webhooks
+// are returned.
+func (s *Service) GetWebhooks(ctx context.Context, opts *ListOptions) ([]*Webhook, *Pagination, error) {
+	rows, lc, err := s.model.GetWebhooks(ctx, opts.model())
+	if lerr := listError(err); lerr != nil {
+		return nil, nil, lerr
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "s.model.GetWebhooks(ctx) failed")
+		return nil, nil, errors.Wrap(err, "s.model.GetWebhooks(ctx) failed")
 	}
 
 	webhooks := make([]*Webhook, 0, len(rows))
@@ -172,21 +185,17 @@ func (s *Service) GetWebhooks(ctx context.Context) ([]*Webhook, error) {
 		}
 		webhooks = append(webhooks, &wh)
 	}
-	return webhooks, nil
+	return webhooks, newPagination(opts, lc, len(webhooks), func(i int) string { return webhooks[i].ID }), nil
 }
 
 // UpdateWebhook partially updates a webhook.
 func (s *Service) UpdateWebhook(ctx context.Context, webhookUUID string, url *string, events []string, enabled *bool) (*Webhook, error) {
 	contextLogger := log.WithContext(ctx)
-	contextLogger.Infof("service: UpdateWebhook(ctx, webhookUUID=%q, ...) started")
+	contextLogger.Infof("service: UpdateWebhook(ctx, webhookUUID=%q, ...) started", webhookUUID)
 
 	// Check the given event name is a known event type
-	eventTypeMap := make(map[string]bool)
-	for _, v := range eventTypes {
-		eventTypeMap[v] = true
-	}
 	for _, v := range events {
-		if _, ok := eventTypeMap[v]; !ok {
+		if _, ok := validEvents[v]; !ok {
 			contextLogger.Warnf(
 				"service: event %q not a recognised event type", v)
 			return nil, ErrEventTypeNotFound
@@ -240,7 +249,7 @@ func (s *Service) BroadcastEvents(ctx context.Context, msg PubSubMsg) error {
 	contextLogger := log.WithContext(ctx)
 	contextLogger.Infof("service: BroadCastEvents(ctx, msg=%v) started", msg)
 
-	webhooks, err := s.GetWebhooks(ctx)
+	webhooks, _, err := s.GetWebhooks(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "service: s.GetWebhooks(ctx) failed")
 	}